@@ -74,6 +74,9 @@ func newDualWriteProxyV2(dependencies quesma_api.Dependencies, schemaLoader data
 
 	// tests should not be run with optimization enabled by default
 	queryProcessor.EnableQueryOptimization(config)
+	if ingestProcessor != nil {
+		queryProcessor.SetIngestProcessor(ingestProcessor)
+	}
 	esConn := backend_connectors.NewElasticsearchBackendConnector(config.Elasticsearch)
 
 	ingestRouter := frontend_connectors.ConfigureIngestRouterV2(config, dependencies, ingestProcessor, resolver, esConn)
@@ -115,8 +118,8 @@ func newDualWriteProxyV2(dependencies quesma_api.Dependencies, schemaLoader data
 		logManager:     logManager,
 		publicPort:     config.PublicTcpPort,
 		asyncQueriesEvictor: async_search_storage.NewAsyncQueriesEvictor(
-			queryProcessor.AsyncRequestStorage.(async_search_storage.AsyncSearchStorageInMemory),
-			queryProcessor.AsyncQueriesContexts.(async_search_storage.AsyncQueryContextStorageInMemory),
+			queryProcessor.AsyncRequestStorage,
+			queryProcessor.AsyncQueriesContexts,
 		),
 		queryRunner: queryProcessor,
 	}
@@ -15,7 +15,10 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/database_common"
 	"github.com/QuesmaOrg/quesma/platform/elasticsearch"
 	"github.com/QuesmaOrg/quesma/platform/elasticsearch/feature"
+	"github.com/QuesmaOrg/quesma/platform/index_templates"
 	"github.com/QuesmaOrg/quesma/platform/ingest"
+	"github.com/QuesmaOrg/quesma/platform/ingest/dead_letter"
+	"github.com/QuesmaOrg/quesma/platform/ingest/pipeline"
 	"github.com/QuesmaOrg/quesma/platform/licensing"
 	"github.com/QuesmaOrg/quesma/platform/logger"
 	"github.com/QuesmaOrg/quesma/platform/persistence"
@@ -106,6 +109,10 @@ func main() {
 
 	// TODO index configuration for ingest and query is the same for now
 	tableResolver := table_resolver.NewTableResolver(cfg, tableDisco, im)
+	indexTemplates := index_templates.NewStore(
+		persistence.NewElasticJSONDatabase(cfg.Elasticsearch, index_templates.IndexTemplatesElasticIndexName),
+		persistence.NewElasticJSONDatabase(cfg.Elasticsearch, index_templates.ComponentTemplatesElasticIndexName))
+	tableResolver.SetIndexTemplates(indexTemplates)
 	tableResolver.Start()
 
 	var ingestProcessor *ingest.IngestProcessor
@@ -120,6 +127,20 @@ func main() {
 		ingestProcessor = ingest.NewIngestProcessor(&cfg, connectionPool, phoneHomeAgent, tableDisco, schemaRegistry, sqlLowerer, tableResolver)
 		ingestProcessor.RegisterLowerer(sqlLowerer, quesma_api.ClickHouseSQLBackend)
 		ingestProcessor.RegisterLowerer(hydrolixLowerer, quesma_api.HydrolixSQLBackend)
+		ingestProcessor.SetPipelineStore(pipeline.NewStore(persistence.NewElasticJSONDatabase(cfg.Elasticsearch, pipeline.ElasticIndexName)))
+		ingestProcessor.SetIndexTemplateStore(indexTemplates)
+		if cfg.DeadLetter != nil {
+			deadLetterSink, err := dead_letter.NewSink(cfg.DeadLetter, connectionPool, cfg.Elasticsearch, cfg.ClusterName)
+			if err != nil {
+				log.Fatalf("error creating dead letter queue: %v", err)
+			}
+			ingestProcessor.SetDeadLetterSink(deadLetterSink)
+		}
+		if cfg.IngestBuffer != nil {
+			if err := ingestProcessor.EnableIngestBuffer(*cfg.IngestBuffer, telemetry.NewIngestBufferMetrics()); err != nil {
+				log.Fatalf("error starting ingest buffer: %v", err)
+			}
+		}
 	} else {
 		logger.Info().Msg("Ingest processor is disabled.")
 	}
@@ -127,6 +148,9 @@ func main() {
 	logger.Info().Msgf("loaded config: %s", cfg.String())
 
 	quesmaManagementConsole := ui.NewQuesmaManagementConsole(&cfg, lm, qmcLogChannel, phoneHomeAgent, schemaRegistry, tableResolver)
+	if ingestProcessor != nil {
+		quesmaManagementConsole.SetDeadLetterQueue(ingestProcessor)
+	}
 
 	abTestingController := sender.NewSenderCoordinator(&cfg, ingestProcessor)
 	abTestingController.Start()
@@ -147,6 +171,9 @@ func main() {
 	abTestingController.Stop()
 	tableResolver.Stop()
 	instance.Close(ctx)
+	if ingestProcessor != nil {
+		ingestProcessor.Stop()
+	}
 
 }
 
@@ -2,4 +2,346 @@
 // SPDX-License-Identifier: Elastic-2.0
 package async_search_storage
 
-// TODO :(
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/config"
+	"github.com/QuesmaOrg/quesma/platform/elasticsearch"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"github.com/goccy/go-json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Storages kept in Elasticsearch survive restarts of Quesma and are shared by all Quesma instances
+// using the same Elasticsearch, so an async search can be polled or cancelled through any of them.
+
+const DefaultElasticIndexName = "quesma_async_search"
+
+// queriesIndexSuffix is appended to the index name for the index of running queries
+const queriesIndexSuffix = "_queries"
+
+// elasticResult is a stored AsyncRequestResult, the body is encoded as base64 and isn't indexed
+type elasticResult struct {
+	Body         []byte `json:"body"`
+	Error        string `json:"error,omitempty"`
+	Added        int64  `json:"added"`
+	ExpiresAt    int64  `json:"expires_at"`
+	IsCompressed bool   `json:"is_compressed"`
+	Size         int64  `json:"size"`
+}
+
+// elasticQuery marks a query running on one of Quesma instances, removing it cancels the query
+type elasticQuery struct {
+	Added     int64 `json:"added"`
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+var resultsMappings = types.JSON{
+	"dynamic": false,
+	"properties": types.JSON{
+		"body":          types.JSON{"type": "binary"},
+		"error":         types.JSON{"type": "keyword", "index": false},
+		"added":         types.JSON{"type": "date", "format": "epoch_millis"},
+		"expires_at":    types.JSON{"type": "date", "format": "epoch_millis"},
+		"is_compressed": types.JSON{"type": "boolean"},
+		"size":          types.JSON{"type": "long"},
+	},
+}
+
+var queriesMappings = types.JSON{
+	"dynamic": false,
+	"properties": types.JSON{
+		"added":      types.JSON{"type": "date", "format": "epoch_millis"},
+		"expires_at": types.JSON{"type": "date", "format": "epoch_millis"},
+	},
+}
+
+type AsyncSearchStorageInElastic struct {
+	index *elasticIndex
+	// Size and SpaceInUse are checked on every async search, so they're not requested from Elasticsearch
+	// each time. They're refreshed on eviction, results stored by this instance are added in between.
+	size       atomic.Int64
+	spaceInUse atomic.Int64
+}
+
+func NewAsyncSearchStorageInElastic(cfg config.ElasticsearchConfiguration, indexName string) *AsyncSearchStorageInElastic {
+	return &AsyncSearchStorageInElastic{index: newElasticIndex(cfg, indexName, resultsMappings)}
+}
+
+func (s *AsyncSearchStorageInElastic) Store(id string, result *AsyncRequestResult) {
+	document := elasticResult{
+		Body:         result.responseBody,
+		Added:        result.added.UnixMilli(),
+		ExpiresAt:    result.expiresAt().UnixMilli(),
+		IsCompressed: result.isCompressed,
+		Size:         int64(len(result.responseBody)),
+	}
+	if result.err != nil {
+		document.Error = result.err.Error()
+	}
+	if err := s.index.put(id, document); err != nil {
+		logger.Error().Msgf("failed to store async search result %s: %v", id, err)
+		return
+	}
+	s.size.Add(1)
+	s.spaceInUse.Add(document.Size)
+}
+
+func (s *AsyncSearchStorageInElastic) Load(id string) (*AsyncRequestResult, bool) {
+	var document elasticResult
+	found, err := s.index.get(id, &document)
+	if err != nil {
+		logger.Error().Msgf("failed to load async search result %s: %v", id, err)
+		return nil, false
+	}
+	if !found || time.Now().UnixMilli() > document.ExpiresAt {
+		return nil, false
+	}
+
+	added := time.UnixMilli(document.Added)
+	result := NewAsyncRequestResult(document.Body, nil, added, time.UnixMilli(document.ExpiresAt).Sub(added), document.IsCompressed)
+	if document.Error != "" {
+		result.err = errors.New(document.Error)
+	}
+	return result, true
+}
+
+func (s *AsyncSearchStorageInElastic) Delete(id string) {
+	if err := s.index.delete(id); err != nil {
+		logger.Error().Msgf("failed to delete async search result %s: %v", id, err)
+	}
+}
+
+func (s *AsyncSearchStorageInElastic) Size() int {
+	return int(s.size.Load())
+}
+
+func (s *AsyncSearchStorageInElastic) SpaceInUse() int64 {
+	return s.spaceInUse.Load()
+}
+
+func (s *AsyncSearchStorageInElastic) Evict(now time.Time) {
+	if err := s.index.deleteExpired(now); err != nil {
+		logger.Error().Msgf("failed to evict async search results: %v", err)
+	}
+	s.refreshStats(now)
+}
+
+// refreshStats counts results stored by all instances, see Size and SpaceInUse
+func (s *AsyncSearchStorageInElastic) refreshStats(now time.Time) {
+	var response struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+		} `json:"hits"`
+		Aggregations struct {
+			Size struct {
+				Value float64 `json:"value"`
+			} `json:"size"`
+		} `json:"aggregations"`
+	}
+	query := types.JSON{
+		"size":             0,
+		"track_total_hits": true,
+		"query":            notExpiredQuery(now),
+		"aggs":             types.JSON{"size": types.JSON{"sum": types.JSON{"field": "size"}}},
+	}
+	if err := s.index.request("POST", "_search", query, &response); err != nil {
+		logger.Error().Msgf("failed to count async search results: %v", err)
+		return
+	}
+	s.size.Store(response.Hits.Total.Value)
+	s.spaceInUse.Store(int64(response.Aggregations.Size.Value))
+}
+
+// AsyncQueryContextStorageInElastic keeps contexts of queries running on this instance in memory,
+// and marks them as running in Elasticsearch. Cancelling a query removes the mark, so that
+// the instance running it notices that and cancels it.
+type AsyncQueryContextStorageInElastic struct {
+	local  AsyncQueryContextStorageInMemory
+	shared *util.SyncMap[string, *AsyncQueryContext] // local queries marked as running in Elasticsearch
+	index  *elasticIndex
+}
+
+func NewAsyncQueryContextStorageInElastic(cfg config.ElasticsearchConfiguration, indexName string) *AsyncQueryContextStorageInElastic {
+	return &AsyncQueryContextStorageInElastic{
+		local:  NewAsyncQueryContextStorageInMemory(),
+		shared: util.NewSyncMap[string, *AsyncQueryContext](),
+		index:  newElasticIndex(cfg, indexName+queriesIndexSuffix, queriesMappings),
+	}
+}
+
+func (s *AsyncQueryContextStorageInElastic) Store(id string, context *AsyncQueryContext) {
+	s.local.Store(id, context)
+	document := elasticQuery{Added: context.added.UnixMilli(), ExpiresAt: context.expiresAt().UnixMilli()}
+	if err := s.index.put(id, document); err != nil {
+		// the query can still be cancelled through this instance
+		logger.Error().Msgf("failed to store async query %s: %v", id, err)
+		return
+	}
+	s.shared.Store(id, context)
+}
+
+func (s *AsyncQueryContextStorageInElastic) Delete(id string) {
+	s.local.Delete(id)
+	if _, ok := s.shared.LoadAndDelete(id); ok {
+		if err := s.index.delete(id); err != nil {
+			logger.Error().Msgf("failed to delete async query %s: %v", id, err)
+		}
+	}
+}
+
+func (s *AsyncQueryContextStorageInElastic) Cancel(id string) {
+	s.local.Cancel(id)
+	s.shared.Delete(id)
+	// the query may be running on another instance
+	if err := s.index.delete(id); err != nil {
+		logger.Error().Msgf("failed to cancel async query %s: %v", id, err)
+	}
+}
+
+func (s *AsyncQueryContextStorageInElastic) Evict(now time.Time) {
+	for _, id := range s.local.evict(now) {
+		s.shared.Delete(id)
+	}
+	if err := s.index.deleteExpired(now); err != nil {
+		logger.Error().Msgf("failed to evict async queries: %v", err)
+	}
+}
+
+// cancelRemotelyCancelled cancels local queries, which aren't marked as running anymore
+func (s *AsyncQueryContextStorageInElastic) cancelRemotelyCancelled() {
+	ids := s.shared.Keys()
+	if len(ids) == 0 {
+		return
+	}
+	var response struct {
+		Docs []struct {
+			Id    string `json:"_id"`
+			Found bool   `json:"found"`
+		} `json:"docs"`
+	}
+	// `_mget` is real-time, unlike `_search`, so queries just stored are found
+	if err := s.index.request("POST", "_mget", types.JSON{"ids": ids}, &response); err != nil {
+		logger.Error().Msgf("failed to check cancelled async queries: %v", err)
+		return
+	}
+	for _, doc := range response.Docs {
+		if doc.Found {
+			continue
+		}
+		if _, ok := s.shared.LoadAndDelete(doc.Id); ok {
+			logger.Info().Msgf("async query %s has been cancelled by another Quesma instance", doc.Id)
+			s.local.Cancel(doc.Id)
+		}
+	}
+}
+
+// elasticIndex is an Elasticsearch index, which is created with its mappings on first use
+type elasticIndex struct {
+	name       string
+	mappings   types.JSON
+	httpClient *elasticsearch.SimpleClient
+	created    atomic.Bool
+}
+
+func newElasticIndex(cfg config.ElasticsearchConfiguration, name string, mappings types.JSON) *elasticIndex {
+	return &elasticIndex{name: name, mappings: mappings, httpClient: elasticsearch.NewSimpleClient(&cfg)}
+}
+
+func (i *elasticIndex) put(id string, document any) error {
+	if err := i.create(); err != nil {
+		return err
+	}
+	return i.request("PUT", "_doc/"+url.PathEscape(id), document, nil)
+}
+
+func (i *elasticIndex) get(id string, document any) (found bool, err error) {
+	var response struct {
+		Found  bool            `json:"found"`
+		Source json.RawMessage `json:"_source"`
+	}
+	if err = i.request("GET", "_doc/"+url.PathEscape(id), nil, &response); err != nil || !response.Found {
+		return false, err
+	}
+	return true, json.Unmarshal(response.Source, document)
+}
+
+func (i *elasticIndex) delete(id string) error {
+	return i.request("DELETE", "_doc/"+url.PathEscape(id), nil, nil)
+}
+
+func (i *elasticIndex) deleteExpired(now time.Time) error {
+	query := types.JSON{"query": types.JSON{"range": types.JSON{"expires_at": types.JSON{"lt": now.UnixMilli()}}}}
+	return i.request("POST", "_delete_by_query?conflicts=proceed", query, nil)
+}
+
+func (i *elasticIndex) create() error {
+	if i.created.Load() {
+		return nil
+	}
+	body, err := json.Marshal(types.JSON{"mappings": i.mappings})
+	if err != nil {
+		return err
+	}
+	resp, err := i.httpClient.Request(context.Background(), "PUT", i.name, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK && !strings.Contains(string(responseBody), "resource_already_exists_exception") {
+		return fmt.Errorf("failed to create index %s: %s, %s", i.name, resp.Status, string(responseBody))
+	}
+	i.created.Store(true)
+	return nil
+}
+
+// request sends the request to an endpoint of the index and decodes the response into result.
+// Missing index or document isn't an error, result is left empty then.
+func (i *elasticIndex) request(method, endpoint string, body any, result any) error {
+	var requestBody []byte
+	if body != nil {
+		var err error
+		if requestBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	resp, err := i.httpClient.Request(context.Background(), method, i.name+"/"+endpoint, requestBody)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+	case http.StatusNotFound:
+		return nil
+	default:
+		return fmt.Errorf("request %s %s failed: %s, %s", method, endpoint, resp.Status, string(responseBody))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(responseBody, result)
+}
+
+func notExpiredQuery(now time.Time) types.JSON {
+	return types.JSON{"range": types.JSON{"expires_at": types.JSON{"gte": now.UnixMilli()}}}
+}
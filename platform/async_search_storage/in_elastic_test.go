@@ -0,0 +1,176 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package async_search_storage
+
+import (
+	"context"
+	"errors"
+	"github.com/QuesmaOrg/quesma/platform/config"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeElasticsearch supports document APIs, `_mget` and `_search` used by the storages (the latter ignores queries)
+type fakeElasticsearch struct {
+	mutex     sync.Mutex
+	documents map[string]json.RawMessage // index/id -> source
+}
+
+func (f *fakeElasticsearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && r.Method == "PUT":
+		w.WriteHeader(http.StatusOK)
+	case len(parts) == 3 && parts[1] == "_doc":
+		key := parts[0] + "/" + parts[2]
+		source, found := f.documents[key]
+		switch r.Method {
+		case "PUT":
+			f.documents[key] = body
+			w.WriteHeader(http.StatusCreated)
+		case "GET":
+			if !found {
+				w.WriteHeader(http.StatusNotFound)
+			}
+			response, _ := json.Marshal(map[string]any{"found": found, "_source": source})
+			_, _ = w.Write(response)
+		case "DELETE":
+			delete(f.documents, key)
+			if !found {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}
+	case len(parts) == 2 && parts[1] == "_mget":
+		var request struct {
+			Ids []string `json:"ids"`
+		}
+		_ = json.Unmarshal(body, &request)
+		var docs []any
+		for _, id := range request.Ids {
+			_, found := f.documents[parts[0]+"/"+id]
+			docs = append(docs, map[string]any{"_id": id, "found": found})
+		}
+		response, _ := json.Marshal(map[string]any{"docs": docs})
+		_, _ = w.Write(response)
+	case len(parts) == 2 && parts[1] == "_search":
+		count, size := 0, int64(0)
+		for key, source := range f.documents {
+			if strings.HasPrefix(key, parts[0]+"/") {
+				var document elasticResult
+				_ = json.Unmarshal(source, &document)
+				count++
+				size += document.Size
+			}
+		}
+		response, _ := json.Marshal(map[string]any{
+			"hits":         map[string]any{"total": map[string]any{"value": count}},
+			"aggregations": map[string]any{"size": map[string]any{"value": size}},
+		})
+		_, _ = w.Write(response)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func newFakeElasticsearch(t *testing.T) config.ElasticsearchConfiguration {
+	server := httptest.NewServer(&fakeElasticsearch{documents: make(map[string]json.RawMessage)})
+	t.Cleanup(server.Close)
+	u, _ := url.Parse(server.URL)
+	return config.ElasticsearchConfiguration{Url: (*config.Url)(u)}
+}
+
+func TestAsyncSearchStorageInElastic(t *testing.T) {
+	cfg := newFakeElasticsearch(t)
+	storage := NewAsyncSearchStorageInElastic(cfg, DefaultElasticIndexName)
+
+	storage.Store("1", NewAsyncRequestResult([]byte(`{"took":1}`), nil, time.Now(), time.Hour, true))
+	storage.Store("2", NewAsyncRequestResult([]byte(`{}`), errors.New("no hits"), time.Now(), time.Hour, false))
+	storage.Store("3", NewAsyncRequestResult([]byte(`{}`), nil, time.Now().Add(-time.Hour), time.Minute, false))
+
+	// another instance sees the same results
+	other := NewAsyncSearchStorageInElastic(cfg, DefaultElasticIndexName)
+	result, ok := other.Load("1")
+	require.True(t, ok)
+	assert.Equal(t, []byte(`{"took":1}`), result.GetResponseBody())
+	assert.True(t, result.IsCompressed())
+	assert.NoError(t, result.GetErr())
+
+	result, ok = other.Load("2")
+	require.True(t, ok)
+	assert.EqualError(t, result.GetErr(), "no hits")
+
+	_, ok = other.Load("3")
+	assert.False(t, ok, "expired result")
+
+	other.Delete("1")
+	_, ok = storage.Load("1")
+	assert.False(t, ok)
+}
+
+func TestAsyncSearchStorageInElasticEscapesIds(t *testing.T) {
+	cfg := newFakeElasticsearch(t)
+	storage := NewAsyncSearchStorageInElastic(cfg, DefaultElasticIndexName)
+
+	const id = "a?b#c%d"
+	storage.Store(id, NewAsyncRequestResult([]byte(`{"took":1}`), nil, time.Now(), time.Hour, false))
+	_, ok := storage.Load("a")
+	assert.False(t, ok, "the id isn't cut at special characters")
+	result, ok := storage.Load(id)
+	require.True(t, ok)
+	assert.Equal(t, []byte(`{"took":1}`), result.GetResponseBody())
+
+	storage.Delete(id)
+	_, ok = storage.Load(id)
+	assert.False(t, ok)
+}
+
+func TestAsyncSearchStorageInElasticStats(t *testing.T) {
+	cfg := newFakeElasticsearch(t)
+	storage := NewAsyncSearchStorageInElastic(cfg, DefaultElasticIndexName)
+	other := NewAsyncSearchStorageInElastic(cfg, DefaultElasticIndexName)
+
+	storage.Store("1", NewAsyncRequestResult([]byte(`{"took":1}`), nil, time.Now(), time.Hour, false))
+	storage.Store("2", NewAsyncRequestResult([]byte(`{}`), nil, time.Now(), time.Hour, false))
+	assert.Equal(t, 2, storage.Size())
+	assert.Equal(t, int64(12), storage.SpaceInUse())
+
+	// results stored by other instances are counted after eviction
+	assert.Equal(t, 0, other.Size())
+	other.Evict(time.Now())
+	assert.Equal(t, 2, other.Size())
+	assert.Equal(t, int64(12), other.SpaceInUse())
+}
+
+func TestAsyncQueryCancelledByAnotherInstance(t *testing.T) {
+	cfg := newFakeElasticsearch(t)
+	first := NewAsyncQueryContextStorageInElastic(cfg, DefaultElasticIndexName)
+	second := NewAsyncQueryContextStorageInElastic(cfg, DefaultElasticIndexName)
+
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	first.Store("1", NewAsyncQueryContext(cancelledCtx, cancel, "1", time.Hour))
+	runningCtx, cancel := context.WithCancel(context.Background())
+	first.Store("2", NewAsyncQueryContext(runningCtx, cancel, "2", time.Hour))
+
+	second.Cancel("1")
+	assert.NoError(t, cancelledCtx.Err(), "the query is running on the first instance")
+
+	first.cancelRemotelyCancelled()
+	assert.Error(t, cancelledCtx.Err())
+	assert.NoError(t, runningCtx.Err())
+
+	first.Delete("2")
+	assert.Error(t, runningCtx.Err(), "resources of finished queries are released")
+	assert.Equal(t, 0, first.shared.Size())
+}
@@ -11,9 +11,12 @@ import (
 	"time"
 )
 
-const EvictionInterval = 15 * time.Minute
 const GCInterval = 1 * time.Minute
 
+// CancellationCheckInterval is how often queries cancelled by other Quesma instances are looked up,
+// when the storage is shared
+const CancellationCheckInterval = 5 * time.Second
+
 type AsyncSearchStorageInMemory struct {
 	idToResult *util.SyncMap[string, *AsyncRequestResult]
 }
@@ -28,12 +31,12 @@ func (s AsyncSearchStorageInMemory) Store(id string, result *AsyncRequestResult)
 	s.idToResult.Store(id, result)
 }
 
-func (s AsyncSearchStorageInMemory) Range(f func(key string, value *AsyncRequestResult) bool) {
-	s.idToResult.Range(f)
-}
-
 func (s AsyncSearchStorageInMemory) Load(id string) (*AsyncRequestResult, bool) {
-	return s.idToResult.Load(id)
+	result, ok := s.idToResult.Load(id)
+	if ok && time.Now().After(result.expiresAt()) {
+		return nil, false
+	}
+	return result, ok
 }
 
 func (s AsyncSearchStorageInMemory) Delete(id string) {
@@ -44,6 +47,28 @@ func (s AsyncSearchStorageInMemory) Size() int {
 	return s.idToResult.Size()
 }
 
+func (s AsyncSearchStorageInMemory) SpaceInUse() int64 {
+	size := int64(0)
+	s.idToResult.Range(func(key string, value *AsyncRequestResult) bool {
+		size += int64(len(value.GetResponseBody()))
+		return true
+	})
+	return size
+}
+
+func (s AsyncSearchStorageInMemory) Evict(now time.Time) {
+	var ids []string
+	s.idToResult.Range(func(key string, value *AsyncRequestResult) bool {
+		if now.After(value.expiresAt()) {
+			ids = append(ids, key)
+		}
+		return true
+	})
+	for _, id := range ids {
+		s.idToResult.Delete(id)
+	}
+}
+
 type AsyncQueryContextStorageInMemory struct {
 	idToContext *util.SyncMap[string, *AsyncQueryContext]
 }
@@ -58,50 +83,44 @@ func (s AsyncQueryContextStorageInMemory) Store(id string, context *AsyncQueryCo
 	s.idToContext.Store(id, context)
 }
 
-type AsyncQueriesEvictor struct {
-	ctx                  context.Context
-	cancel               context.CancelFunc
-	AsyncRequestStorage  AsyncSearchStorageInMemory
-	AsyncQueriesContexts AsyncQueryContextStorageInMemory
+func (s AsyncQueryContextStorageInMemory) Delete(id string) {
+	s.cancel(id)
 }
 
-func NewAsyncQueriesEvictor(AsyncRequestStorage AsyncSearchStorageInMemory, AsyncQueriesContexts AsyncQueryContextStorageInMemory) *AsyncQueriesEvictor {
-	ctx, cancel := context.WithCancel(context.Background())
-	return &AsyncQueriesEvictor{ctx: ctx, cancel: cancel, AsyncRequestStorage: AsyncRequestStorage, AsyncQueriesContexts: AsyncQueriesContexts}
+func (s AsyncQueryContextStorageInMemory) Cancel(id string) {
+	if s.cancel(id) {
+		logger.Info().Msgf("Cancelled async query: %s", id)
+	}
 }
 
-func elapsedTime(t time.Time) time.Duration {
-	return time.Since(t)
+// cancel cancels the context of the query and forgets it, it returns false if the query isn't known
+func (s AsyncQueryContextStorageInMemory) cancel(id string) bool {
+	asyncQueryContext, ok := s.idToContext.LoadAndDelete(id)
+	if !ok {
+		return false
+	}
+	if asyncQueryContext != nil && asyncQueryContext.cancel != nil {
+		asyncQueryContext.cancel()
+	}
+	return true
 }
 
-type asyncQueryIdWithTime struct {
-	id   string
-	time time.Time
+func (s AsyncQueryContextStorageInMemory) Evict(now time.Time) {
+	s.evict(now)
 }
 
-func (e *AsyncQueriesEvictor) tryEvictAsyncRequests(timeFun func(time.Time) time.Duration) {
-	var ids []asyncQueryIdWithTime
-	e.AsyncRequestStorage.Range(func(key string, value *AsyncRequestResult) bool {
-		if timeFun(value.added) > EvictionInterval {
-			ids = append(ids, asyncQueryIdWithTime{id: key, time: value.added})
-		}
-		return true
-	})
-	for _, id := range ids {
-		e.AsyncRequestStorage.idToResult.Delete(id.id)
-	}
+// evict cancels expired queries and returns their ids
+func (s AsyncQueryContextStorageInMemory) evict(now time.Time) []string {
 	var asyncQueriesContexts []*AsyncQueryContext
-	e.AsyncQueriesContexts.idToContext.Range(func(key string, value *AsyncQueryContext) bool {
-		if timeFun(value.added) > EvictionInterval {
-			if value != nil {
-				asyncQueriesContexts = append(asyncQueriesContexts, value)
-			}
+	s.idToContext.Range(func(key string, value *AsyncQueryContext) bool {
+		if value != nil && now.After(value.expiresAt()) {
+			asyncQueriesContexts = append(asyncQueriesContexts, value)
 		}
 		return true
 	})
 	evictedIds := make([]string, 0)
 	for _, asyncQueryContext := range asyncQueriesContexts {
-		e.AsyncQueriesContexts.idToContext.Delete(asyncQueryContext.id)
+		s.idToContext.Delete(asyncQueryContext.id)
 		if asyncQueryContext.cancel != nil {
 			evictedIds = append(evictedIds, asyncQueryContext.id)
 			asyncQueryContext.cancel()
@@ -110,17 +129,49 @@ func (e *AsyncQueriesEvictor) tryEvictAsyncRequests(timeFun func(time.Time) time
 	if len(evictedIds) > 0 {
 		logger.Info().Msgf("Evicted %d async queries : %s", len(evictedIds), strings.Join(evictedIds, ","))
 	}
+	return evictedIds
+}
+
+// sharedContextStorage is implemented by storages shared by many Quesma instances,
+// which have to check if their queries were cancelled by another instance
+type sharedContextStorage interface {
+	cancelRemotelyCancelled()
+}
+
+type AsyncQueriesEvictor struct {
+	ctx                  context.Context
+	cancel               context.CancelFunc
+	AsyncRequestStorage  AsyncRequestResultStorage
+	AsyncQueriesContexts AsyncQueryContextStorage
+}
+
+func NewAsyncQueriesEvictor(AsyncRequestStorage AsyncRequestResultStorage, AsyncQueriesContexts AsyncQueryContextStorage) *AsyncQueriesEvictor {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &AsyncQueriesEvictor{ctx: ctx, cancel: cancel, AsyncRequestStorage: AsyncRequestStorage, AsyncQueriesContexts: AsyncQueriesContexts}
+}
+
+func (e *AsyncQueriesEvictor) tryEvictAsyncRequests(now time.Time) {
+	e.AsyncRequestStorage.Evict(now)
+	e.AsyncQueriesContexts.Evict(now)
 }
 
 func (e *AsyncQueriesEvictor) AsyncQueriesGC() {
 	defer recovery.LogPanic()
+	gcTicker := time.NewTicker(GCInterval)
+	defer gcTicker.Stop()
+	cancellationTicker := time.NewTicker(CancellationCheckInterval)
+	defer cancellationTicker.Stop()
 	for {
 		select {
 		case <-e.ctx.Done():
 			logger.Debug().Msg("evictor stopped")
 			return
-		case <-time.After(GCInterval):
-			e.tryEvictAsyncRequests(elapsedTime)
+		case <-gcTicker.C:
+			e.tryEvictAsyncRequests(time.Now())
+		case <-cancellationTicker.C:
+			if shared, ok := e.AsyncQueriesContexts.(sharedContextStorage); ok {
+				shared.cancelRemotelyCancelled()
+			}
 		}
 	}
 }
@@ -3,7 +3,7 @@
 package async_search_storage
 
 import (
-	"github.com/QuesmaOrg/quesma/platform/util"
+	"context"
 	"github.com/stretchr/testify/assert"
 	"testing"
 	"time"
@@ -16,9 +16,7 @@ func TestAsyncQueriesEvictorTimePassed(t *testing.T) {
 	evictor.AsyncRequestStorage.Store("1", &AsyncRequestResult{added: time.Now()})
 	evictor.AsyncRequestStorage.Store("2", &AsyncRequestResult{added: time.Now()})
 	evictor.AsyncRequestStorage.Store("3", &AsyncRequestResult{added: time.Now()})
-	evictor.tryEvictAsyncRequests(func(time.Time) time.Duration {
-		return 20 * time.Minute
-	})
+	evictor.tryEvictAsyncRequests(time.Now().Add(20 * time.Minute))
 
 	assert.Equal(t, 0, evictor.AsyncRequestStorage.Size())
 }
@@ -27,13 +25,27 @@ func TestAsyncQueriesEvictorStillAlive(t *testing.T) {
 	queryContextStorage := NewAsyncQueryContextStorageInMemory()
 	queryContextStorage.idToContext.Store("1", &AsyncQueryContext{})
 	evictor := NewAsyncQueriesEvictor(NewAsyncSearchStorageInMemory(), queryContextStorage)
-	evictor.AsyncRequestStorage.idToResult = util.NewSyncMap[string, *AsyncRequestResult]()
 	evictor.AsyncRequestStorage.Store("1", &AsyncRequestResult{added: time.Now()})
 	evictor.AsyncRequestStorage.Store("2", &AsyncRequestResult{added: time.Now()})
 	evictor.AsyncRequestStorage.Store("3", &AsyncRequestResult{added: time.Now()})
-	evictor.tryEvictAsyncRequests(func(time.Time) time.Duration {
-		return time.Second
-	})
+	evictor.tryEvictAsyncRequests(time.Now().Add(time.Second))
 
 	assert.Equal(t, 3, evictor.AsyncRequestStorage.Size())
 }
+
+func TestAsyncQueriesEvictorKeepAlive(t *testing.T) {
+	queryContextStorage := NewAsyncQueryContextStorageInMemory()
+	ctx, cancel := context.WithCancel(context.Background())
+	queryContextStorage.Store("1", NewAsyncQueryContext(ctx, cancel, "1", time.Hour))
+	evictor := NewAsyncQueriesEvictor(NewAsyncSearchStorageInMemory(), queryContextStorage)
+	evictor.AsyncRequestStorage.Store("1", NewAsyncRequestResult([]byte("{}"), nil, time.Now(), time.Hour, false))
+	evictor.AsyncRequestStorage.Store("2", NewAsyncRequestResult([]byte("{}"), nil, time.Now(), 0, false))
+
+	evictor.tryEvictAsyncRequests(time.Now().Add(20 * time.Minute))
+	assert.Equal(t, 1, evictor.AsyncRequestStorage.Size())
+	assert.NoError(t, ctx.Err())
+
+	evictor.tryEvictAsyncRequests(time.Now().Add(2 * time.Hour))
+	assert.Equal(t, 0, evictor.AsyncRequestStorage.Size())
+	assert.Error(t, ctx.Err())
+}
@@ -7,28 +7,40 @@ import (
 	"time"
 )
 
+// DefaultKeepAlive is used when neither the request nor the configuration specify `keep_alive`.
+const DefaultKeepAlive = 15 * time.Minute
+
 type AsyncRequestResultStorage interface {
 	Store(id string, result *AsyncRequestResult)
-	Range(func(key string, value *AsyncRequestResult) bool) // ideally I'd like to get rid of this, but not sure if it's possible
 	Load(id string) (*AsyncRequestResult, bool)
 	Delete(id string)
 	Size() int
+	SpaceInUse() int64 // total size of stored response bodies, in bytes
+	// Evict removes results, whose keep alive has passed
+	Evict(now time.Time)
 }
 
-// TODO: maybe merge those 2?
+// AsyncQueryContextStorage keeps contexts of running async queries, so they can be cancelled.
 type AsyncQueryContextStorage interface {
 	Store(id string, context *AsyncQueryContext)
+	// Delete is called when the query has finished, it releases the resources of its context
+	Delete(id string)
+	// Cancel cancels the query, it may be running on another Quesma instance if the storage is shared
+	Cancel(id string)
+	// Evict cancels queries, whose keep alive has passed
+	Evict(now time.Time)
 }
 
 type AsyncRequestResult struct {
 	responseBody []byte
 	added        time.Time
+	keepAlive    time.Duration
 	isCompressed bool
 	err          error
 }
 
-func NewAsyncRequestResult(responseBody []byte, err error, added time.Time, isCompressed bool) *AsyncRequestResult {
-	return &AsyncRequestResult{responseBody: responseBody, err: err, added: added, isCompressed: isCompressed}
+func NewAsyncRequestResult(responseBody []byte, err error, added time.Time, keepAlive time.Duration, isCompressed bool) *AsyncRequestResult {
+	return &AsyncRequestResult{responseBody: responseBody, err: err, added: added, keepAlive: keepAlive, isCompressed: isCompressed}
 }
 
 func (r *AsyncRequestResult) GetResponseBody() []byte {
@@ -43,13 +55,29 @@ func (r *AsyncRequestResult) IsCompressed() bool {
 	return r.isCompressed
 }
 
+func (r *AsyncRequestResult) expiresAt() time.Time {
+	return expiresAt(r.added, r.keepAlive)
+}
+
 type AsyncQueryContext struct {
-	id     string
-	ctx    context.Context
-	cancel context.CancelFunc
-	added  time.Time
+	id        string
+	ctx       context.Context
+	cancel    context.CancelFunc
+	added     time.Time
+	keepAlive time.Duration
+}
+
+func NewAsyncQueryContext(ctx context.Context, cancel context.CancelFunc, id string, keepAlive time.Duration) *AsyncQueryContext {
+	return &AsyncQueryContext{ctx: ctx, cancel: cancel, added: time.Now(), keepAlive: keepAlive, id: id}
+}
+
+func (c *AsyncQueryContext) expiresAt() time.Time {
+	return expiresAt(c.added, c.keepAlive)
 }
 
-func NewAsyncQueryContext(ctx context.Context, cancel context.CancelFunc, id string) *AsyncQueryContext {
-	return &AsyncQueryContext{ctx: ctx, cancel: cancel, added: time.Now(), id: id}
+func expiresAt(added time.Time, keepAlive time.Duration) time.Time {
+	if keepAlive <= 0 {
+		keepAlive = DefaultKeepAlive
+	}
+	return added.Add(keepAlive)
 }
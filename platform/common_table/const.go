@@ -13,6 +13,10 @@ import (
 const TableName = "quesma_common_table"
 const IndexNameColumn = "__quesma_index_name"
 
+// DocumentIdColumn stores `_id` supplied by clients at ingest, both in regular tables and in the common table.
+// Rows ingested without an id have it empty and get an id generated from timestamp and source in search hits.
+const DocumentIdColumn = "__quesma_id"
+
 const singleTableDDL = `
 CREATE TABLE IF NOT EXISTS "quesma_common_table" %s
 (
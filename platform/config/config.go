@@ -59,6 +59,11 @@ type QuesmaConfiguration struct {
 	MapFieldsDiscoveringEnabled bool
 	IndexNameRewriteRules       []IndexNameRewriteRule // rules for rewriting index names, e.g. "index_name" -> "index_name_v2"
 	DefaultStringColumnType     string
+	DeadLetter                  *DeadLetterConfiguration   // nil if documents rejected by ingest aren't kept
+	IngestBuffer                *IngestBufferConfiguration // nil if documents are inserted right away
+	AsyncSearch                 *AsyncSearchConfiguration  // nil if results of async searches are kept in memory
+	DefaultDynamic              DynamicMapping             // applied from the "*" index configuration
+	DefaultWidenColumnTypes     bool                       // applied from the "*" index configuration
 
 	DefaultSchemaOverrides *SchemaConfiguration
 }
@@ -79,6 +84,19 @@ func (c *QuesmaConfiguration) AliasFields(indexName string) map[string]string {
 	return aliases
 }
 
+// IsRelevanceScoringEnabled returns true <=> relevance scoring is enabled for all given indexes
+func (c *QuesmaConfiguration) IsRelevanceScoringEnabled(indexNames []string) bool {
+	if len(indexNames) == 0 {
+		return false
+	}
+	for _, indexName := range indexNames {
+		if indexConfig, found := c.IndexConfig[indexName]; !found || !indexConfig.EnableRelevanceScoring {
+			return false
+		}
+	}
+	return true
+}
+
 func MatchName(pattern, name string) bool {
 	return util.TableNamePatternRegexp(pattern).MatchString(name)
 }
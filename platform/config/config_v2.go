@@ -10,6 +10,7 @@ import (
 	"regexp"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/QuesmaOrg/quesma/platform/util"
 	"github.com/hashicorp/go-multierror"
@@ -141,6 +142,11 @@ type (
 		DefaultTargetConnectorType string //it is not serialized to maintain configuration BWC, so it's basically just populated from '*' config in `config_v2.go`
 
 		IndexNameRewriteRules map[string]IndexNameRewriteRule `koanf:"indexNameRewriteRules"`
+
+		DeadLetter   *DeadLetterConfiguration   `koanf:"deadLetter"`   // ingest processor only
+		IngestBuffer *IngestBufferConfiguration `koanf:"ingestBuffer"` // ingest processor only
+
+		AsyncSearch *AsyncSearchConfiguration `koanf:"asyncSearch"` // query processor only
 	}
 	IndicesConfigs map[string]IndexConfiguration
 
@@ -148,6 +154,51 @@ type (
 		From string `koanf:"from"` // pattern to match
 		To   string `koanf:"to"`   // replacement string
 	}
+
+	// DeadLetterConfiguration configures where documents rejected by ingest are kept,
+	// so they can be inspected and re-ingested later.
+	DeadLetterConfiguration struct {
+		Sink             DeadLetterSink `koanf:"sink"`
+		TableName        string         `koanf:"tableName"`        // `clickhouse` sink
+		Path             string         `koanf:"path"`             // `file` sink, rotated files get `.1`, `.2`, ... suffixes
+		MaxFileSizeBytes int64          `koanf:"maxFileSizeBytes"` // `file` sink
+		MaxFiles         int            `koanf:"maxFiles"`         // `file` sink, the number of rotated files kept
+		IndexName        string         `koanf:"indexName"`        // `elasticsearch` sink
+	}
+	DeadLetterSink string
+
+	// IngestBufferConfiguration enables buffering of ingested documents in a local write-ahead log,
+	// from which they're inserted in batches. Zero values mean defaults.
+	IngestBufferConfiguration struct {
+		Path              string        `koanf:"path"`         // directory of the write-ahead log
+		MaxSizeBytes      int64         `koanf:"maxSizeBytes"` // above it, ingest requests are rejected with 429
+		MaxBatchDocuments int           `koanf:"maxBatchDocuments"`
+		MaxBatchSizeBytes int64         `koanf:"maxBatchSizeBytes"`
+		FlushInterval     time.Duration `koanf:"flushInterval"` // the longest time documents wait for a batch to fill up
+		MaxRetries        int           `koanf:"maxRetries"`
+		InitialBackoff    time.Duration `koanf:"initialBackoff"`
+		MaxBackoff        time.Duration `koanf:"maxBackoff"`
+	}
+
+	// AsyncSearchConfiguration configures where results of async searches are kept.
+	// Results kept in Elasticsearch survive restarts and are shared by all Quesma instances.
+	AsyncSearchConfiguration struct {
+		Storage          AsyncSearchStorage `koanf:"storage"`
+		IndexName        string             `koanf:"indexName"`        // `elasticsearch` storage
+		DefaultKeepAlive time.Duration      `koanf:"defaultKeepAlive"` // used when a request doesn't specify `keep_alive`
+	}
+	AsyncSearchStorage string
+)
+
+const (
+	DeadLetterSinkClickHouse    DeadLetterSink = "clickhouse"
+	DeadLetterSinkFile          DeadLetterSink = "file"
+	DeadLetterSinkElasticsearch DeadLetterSink = "elasticsearch"
+)
+
+const (
+	AsyncSearchStorageMemory        AsyncSearchStorage = "memory"
+	AsyncSearchStorageElasticsearch AsyncSearchStorage = "elasticsearch"
 )
 
 func (p *QuesmaProcessorConfig) IsFieldMapSyntaxEnabled(indexName string) bool {
@@ -385,6 +436,12 @@ func (c *QuesmaNewConfiguration) validatePipelines() error {
 				if queryIndexConf.PartitioningStrategy != "" && queryIndexConf.UseCommonTable {
 					return fmt.Errorf("partitioning strategy cannot be set for index '%s' - common table partitioning is NOT supported", indexName)
 				}
+				if ingestIndexConf.DeduplicateDocuments && ingestIndexConf.UseCommonTable {
+					return fmt.Errorf("deduplicateDocuments cannot be set for index '%s' - common table deduplication is NOT supported", indexName)
+				}
+				if (ingestIndexConf.Dynamic != "" || ingestIndexConf.WidenColumnTypes) && ingestIndexConf.UseCommonTable {
+					return fmt.Errorf("dynamic and widenColumnTypes cannot be set for index '%s' - common table schema is always dynamic", indexName)
+				}
 				allowedPartitioningStrategies := []PartitionStrategy{None, Hourly, Daily, Monthly, Yearly}
 				if !slices.Contains(allowedPartitioningStrategies, queryIndexConf.PartitioningStrategy) {
 					return fmt.Errorf("partitioning strategy '%s' is not allowed for index '%s', only %v are supported", queryIndexConf.PartitioningStrategy, indexName, allowedPartitioningStrategies)
@@ -448,6 +505,58 @@ func (c *QuesmaNewConfiguration) validateRewriteRules(rules map[string]IndexName
 	return nil
 }
 
+func (c *QuesmaNewConfiguration) validateDeadLetter(deadLetter *DeadLetterConfiguration) error {
+	if deadLetter == nil {
+		return nil
+	}
+	switch deadLetter.Sink {
+	case DeadLetterSinkClickHouse, DeadLetterSinkElasticsearch:
+	case DeadLetterSinkFile:
+		if deadLetter.Path == "" {
+			return fmt.Errorf("dead letter sink '%s' requires 'path'", deadLetter.Sink)
+		}
+	default:
+		return fmt.Errorf("dead letter sink '%s' is not supported, use one of: %s, %s, %s", deadLetter.Sink,
+			DeadLetterSinkClickHouse, DeadLetterSinkFile, DeadLetterSinkElasticsearch)
+	}
+	if deadLetter.MaxFileSizeBytes < 0 || deadLetter.MaxFiles < 0 {
+		return fmt.Errorf("dead letter 'maxFileSizeBytes' and 'maxFiles' can't be negative")
+	}
+	return nil
+}
+
+func (c *QuesmaNewConfiguration) validateIngestBuffer(ingestBuffer *IngestBufferConfiguration) error {
+	if ingestBuffer == nil {
+		return nil
+	}
+	if ingestBuffer.Path == "" {
+		return fmt.Errorf("ingest buffer requires 'path'")
+	}
+	if ingestBuffer.MaxSizeBytes < 0 || ingestBuffer.MaxBatchDocuments < 0 || ingestBuffer.MaxBatchSizeBytes < 0 || ingestBuffer.MaxRetries < 0 {
+		return fmt.Errorf("ingest buffer limits can't be negative")
+	}
+	if ingestBuffer.FlushInterval < 0 || ingestBuffer.InitialBackoff < 0 || ingestBuffer.MaxBackoff < 0 {
+		return fmt.Errorf("ingest buffer durations can't be negative")
+	}
+	return nil
+}
+
+func (c *QuesmaNewConfiguration) validateAsyncSearch(asyncSearch *AsyncSearchConfiguration) error {
+	if asyncSearch == nil {
+		return nil
+	}
+	switch asyncSearch.Storage {
+	case "", AsyncSearchStorageMemory, AsyncSearchStorageElasticsearch:
+	default:
+		return fmt.Errorf("async search storage '%s' is not supported, use one of: %s, %s", asyncSearch.Storage,
+			AsyncSearchStorageMemory, AsyncSearchStorageElasticsearch)
+	}
+	if asyncSearch.DefaultKeepAlive < 0 {
+		return fmt.Errorf("async search 'defaultKeepAlive' can't be negative")
+	}
+	return nil
+}
+
 func (c *QuesmaNewConfiguration) validateProcessor(p Processor) error {
 	if len(p.Name) == 0 {
 		return fmt.Errorf("processor must have a non-empty name")
@@ -483,6 +592,11 @@ func (c *QuesmaNewConfiguration) validateProcessor(p Processor) error {
 					return err
 				}
 
+				allowedDynamicMappings := []DynamicMapping{DynamicMappingTrue, DynamicMappingFalse, DynamicMappingStrict}
+				if !slices.Contains(allowedDynamicMappings, indexConfig.Dynamic.Normalized()) {
+					return fmt.Errorf("dynamic '%s' is not allowed for index '%s', only %v are supported", indexConfig.Dynamic, indexName, allowedDynamicMappings)
+				}
+
 			}
 			targets, errTarget := c.getTargetsExtendedConfig(indexConfig.Target)
 			if errTarget != nil {
@@ -495,6 +609,25 @@ func (c *QuesmaNewConfiguration) validateProcessor(p Processor) error {
 			}
 		}
 	}
+	if p.Type == QuesmaV1ProcessorIngest {
+		if err := c.validateDeadLetter(p.Config.DeadLetter); err != nil {
+			return err
+		}
+		if err := c.validateIngestBuffer(p.Config.IngestBuffer); err != nil {
+			return err
+		}
+	} else if p.Config.DeadLetter != nil {
+		return fmt.Errorf("dead letter queue is supported in ingest processor configuration only")
+	} else if p.Config.IngestBuffer != nil {
+		return fmt.Errorf("ingest buffer is supported in ingest processor configuration only")
+	}
+	if p.Type == QuesmaV1ProcessorQuery {
+		if err := c.validateAsyncSearch(p.Config.AsyncSearch); err != nil {
+			return err
+		}
+	} else if p.Config.AsyncSearch != nil {
+		return fmt.Errorf("async search storage is supported in query processor configuration only")
+	}
 	return nil
 }
 
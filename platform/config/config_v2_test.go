@@ -9,6 +9,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func loadConfig(t *testing.T) QuesmaNewConfiguration {
@@ -352,6 +353,74 @@ func TestIndexNameRewriteRules(t *testing.T) {
 	assert.Equal(t, "(.*?)(.\\d{4}-\\d{2}-\\d{2})$", legacyConf.IndexNameRewriteRules[3].From) // empty string means no rewrite rule
 }
 
+func TestDeadLetterQueue(t *testing.T) {
+	os.Setenv(configFileLocationEnvVar, "./test_configs/dead_letter_queue.yaml")
+	cfg := loadConfig(t)
+	legacyConf := cfg.TranslateToLegacyConfig()
+
+	assert.Equal(t, &DeadLetterConfiguration{Sink: DeadLetterSinkFile, Path: "/var/quesma/dead_letter.jsonl", MaxFileSizeBytes: 1048576}, legacyConf.DeadLetter)
+
+	cfg.Processors[1].Config.DeadLetter.Path = ""
+	assert.Error(t, cfg.validateProcessor(cfg.Processors[1]))
+	cfg.Processors[1].Config.DeadLetter = &DeadLetterConfiguration{Sink: "kafka"}
+	assert.Error(t, cfg.validateProcessor(cfg.Processors[1]))
+}
+
+func TestIngestBuffer(t *testing.T) {
+	os.Setenv(configFileLocationEnvVar, "./test_configs/ingest_buffer.yaml")
+	cfg := loadConfig(t)
+	legacyConf := cfg.TranslateToLegacyConfig()
+
+	assert.Equal(t, &IngestBufferConfiguration{
+		Path:          "/var/quesma/ingest_buffer",
+		MaxSizeBytes:  536870912,
+		FlushInterval: 2 * time.Second,
+		MaxRetries:    5,
+		MaxBackoff:    30 * time.Second,
+	}, legacyConf.IngestBuffer)
+
+	cfg.Processors[1].Config.IngestBuffer.Path = ""
+	assert.Error(t, cfg.validateProcessor(cfg.Processors[1]))
+	cfg.Processors[0].Config.IngestBuffer = &IngestBufferConfiguration{Path: "/tmp"}
+	assert.Error(t, cfg.validateProcessor(cfg.Processors[0]))
+}
+
+func TestAsyncSearch(t *testing.T) {
+	os.Setenv(configFileLocationEnvVar, "./test_configs/async_search.yaml")
+	cfg := loadConfig(t)
+	legacyConf := cfg.TranslateToLegacyConfig()
+
+	assert.Equal(t, &AsyncSearchConfiguration{
+		Storage:          AsyncSearchStorageElasticsearch,
+		IndexName:        "quesma_async_search",
+		DefaultKeepAlive: time.Hour,
+	}, legacyConf.AsyncSearch)
+
+	cfg.Processors[0].Config.AsyncSearch.Storage = "redis"
+	assert.Error(t, cfg.validateProcessor(cfg.Processors[0]))
+	cfg.Processors[1].Config.AsyncSearch = &AsyncSearchConfiguration{}
+	assert.Error(t, cfg.validateProcessor(cfg.Processors[1]))
+}
+
+func TestSchemaEvolution(t *testing.T) {
+	os.Setenv(configFileLocationEnvVar, "./test_configs/schema_evolution.yaml")
+	cfg := loadConfig(t)
+	legacyConf := cfg.TranslateToLegacyConfig()
+
+	assert.Equal(t, DynamicMappingStrict, legacyConf.IndexConfig["logs-1"].Dynamic)
+	assert.False(t, legacyConf.IndexConfig["logs-1"].WidenColumnTypes)
+	assert.Equal(t, DynamicMappingFalse, legacyConf.IndexConfig["logs-2"].Dynamic)
+	assert.True(t, legacyConf.IndexConfig["logs-2"].WidenColumnTypes)
+	assert.Equal(t, DynamicMappingTrue, legacyConf.IndexConfig["logs-3"].Dynamic)
+	assert.Equal(t, DynamicMappingTrue, legacyConf.DefaultDynamic)
+	assert.True(t, legacyConf.DefaultWidenColumnTypes)
+
+	logs1 := cfg.Processors[1].Config.IndexConfig["logs-1"]
+	logs1.Dynamic = "runtime"
+	cfg.Processors[1].Config.IndexConfig["logs-1"] = logs1
+	assert.Error(t, cfg.validateProcessor(cfg.Processors[1]))
+}
+
 func TestStringColumnIsTextDefaultBehavior(t *testing.T) {
 	os.Setenv(configFileLocationEnvVar, "./test_configs/partition_by.yaml")
 	cfg := loadConfig(t)
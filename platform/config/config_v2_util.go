@@ -23,6 +23,7 @@ func (c *QuesmaConfiguration) translateAndAddSinglePipeline(confNew *QuesmaNewCo
 	queryProcessor := processor
 
 	c.IndexConfig = make(map[string]IndexConfiguration)
+	c.AsyncSearch = queryProcessor.Config.AsyncSearch
 
 	// Handle default index configuration
 	defaultConfig := queryProcessor.Config.IndexConfig[DefaultWildcardIndexName]
@@ -169,6 +170,7 @@ func (c *QuesmaConfiguration) translateAndAddDualPipeline(confNew *QuesmaNewConf
 	}
 
 	c.IndexConfig = make(map[string]IndexConfiguration)
+	c.AsyncSearch = queryProcessor.Config.AsyncSearch
 
 	// Handle default index configuration
 	defaultConfig := queryProcessor.Config.IndexConfig[DefaultWildcardIndexName]
@@ -342,9 +344,13 @@ func (c *QuesmaConfiguration) translateAndAddDualPipeline(confNew *QuesmaNewConf
 
 	c.EnableIngest = true
 	c.IngestStatistics = confNew.IngestStatistics
+	c.DeadLetter = ingestProcessor.Config.DeadLetter
+	c.IngestBuffer = ingestProcessor.Config.IngestBuffer
 
 	if defaultIngestConfig, ok := ingestProcessor.Config.IndexConfig[DefaultWildcardIndexName]; ok {
 		c.DefaultIngestOptimizers = defaultIngestConfig.Optimizers
+		c.DefaultDynamic = defaultIngestConfig.Dynamic.Normalized()
+		c.DefaultWidenColumnTypes = defaultIngestConfig.WidenColumnTypes
 	} else {
 		c.DefaultIngestOptimizers = nil
 	}
@@ -436,6 +442,10 @@ func (c *QuesmaConfiguration) translateAndAddDualPipeline(confNew *QuesmaNewConf
 			}
 		}
 
+		// schema evolution is configured in the ingest processor only
+		processedConfig.Dynamic = indexConfig.Dynamic.Normalized()
+		processedConfig.WidenColumnTypes = indexConfig.WidenColumnTypes
+
 		// copy ingest optimizers to the destination
 		if indexConfig.Optimizers != nil {
 			if processedConfig.Optimizers == nil {
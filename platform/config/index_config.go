@@ -25,6 +25,27 @@ const (
 	None    PartitionStrategy = ""
 )
 
+// DynamicMapping controls what happens to ingested fields, which don't have columns yet, like `dynamic` in Elasticsearch mappings
+type DynamicMapping string
+
+const (
+	DynamicMappingTrue   DynamicMapping = "true"   // new columns are added (default)
+	DynamicMappingFalse  DynamicMapping = "false"  // fields are stored in attributes, but no columns are added
+	DynamicMappingStrict DynamicMapping = "strict" // documents with such fields are rejected
+)
+
+// Normalized returns the mapping as written in the configuration, unquoted `true` and `false` are decoded as "1" and "0".
+func (d DynamicMapping) Normalized() DynamicMapping {
+	switch d {
+	case "", "1":
+		return DynamicMappingTrue
+	case "0":
+		return DynamicMappingFalse
+	default:
+		return d
+	}
+}
+
 type IndexConfiguration struct {
 	SchemaOverrides *SchemaConfiguration              `koanf:"schemaOverrides"`
 	Optimizers      map[string]OptimizerConfiguration `koanf:"optimizers"`
@@ -35,6 +56,16 @@ type IndexConfiguration struct {
 	// PartitioningStrategy adds PARTITION BY clause to the table creation query
 	PartitioningStrategy PartitionStrategy `koanf:"partitioningStrategy"` // Experimental feature
 	EnableFieldMapSyntax bool              `koanf:"enableFieldMapSyntax"` // Experimental feature
+	// EnableRelevanceScoring makes Quesma compute relevance score (`_score`) of hits in SQL
+	EnableRelevanceScoring bool `koanf:"enableRelevanceScoring"` // Experimental feature
+	// DeduplicateDocuments makes new tables of the index ReplacingMergeTree, so that documents
+	// ingested again with the same `_id` (e.g. retried by shippers) are merged into one
+	DeduplicateDocuments bool `koanf:"deduplicateDocuments"` // Experimental feature
+	// Dynamic controls fields of ingested documents, which don't have columns yet, empty means `true`
+	Dynamic DynamicMapping `koanf:"dynamic"`
+	// WidenColumnTypes makes ingest change types of columns to wider ones (e.g. Int32 to Int64) when values
+	// don't fit, instead of storing these values in attributes
+	WidenColumnTypes bool `koanf:"widenColumnTypes"`
 
 	// Computed based on the overall configuration
 	QueryTarget  []string
@@ -77,6 +108,18 @@ func (c IndexConfiguration) String(indexName string) string {
 		builder.WriteString(", useSingleTable: true")
 	}
 	builder.WriteString(fmt.Sprintf(", enableFieldMapSyntax: %v", c.EnableFieldMapSyntax))
+	if c.EnableRelevanceScoring {
+		builder.WriteString(", enableRelevanceScoring: true")
+	}
+	if c.DeduplicateDocuments {
+		builder.WriteString(", deduplicateDocuments: true")
+	}
+	if dynamic := c.Dynamic.Normalized(); dynamic != DynamicMappingTrue {
+		builder.WriteString(fmt.Sprintf(", dynamic: %s", dynamic))
+	}
+	if c.WidenColumnTypes {
+		builder.WriteString(", widenColumnTypes: true")
+	}
 
 	return builder.String()
 }
@@ -67,6 +67,7 @@ type (
 		Attributes                            []Attribute
 		CastUnsupportedAttrValueTypesToString bool // if we have e.g. only attrs (String, String), we'll cast e.g. Date to String
 		PreferCastingToOthers                 bool // we'll put non-schema field in [String, String] attrs map instead of others, if we have both options
+		DeduplicateByDocumentId               bool // ReplacingMergeTree engine, which merges rows with the same timestamp and `_id`
 	}
 )
 
@@ -5,6 +5,7 @@ package database_common
 import (
 	"fmt"
 	"github.com/QuesmaOrg/quesma/platform/clickhouse"
+	"github.com/QuesmaOrg/quesma/platform/common_table"
 	"github.com/QuesmaOrg/quesma/platform/config"
 	"github.com/QuesmaOrg/quesma/platform/doris"
 	"github.com/QuesmaOrg/quesma/platform/logger"
@@ -297,6 +298,8 @@ func NewType(value any, valueOrigin string) (Type, error) {
 		return BaseType{Name: "Int64", GoType: reflect.TypeOf(int64(0))}, nil
 	case bool:
 		return BaseType{Name: "Bool", GoType: reflect.TypeOf(true)}, nil
+	case map[string]string: // values of flattened fields
+		return BaseType{Name: "Map(String, String)", GoType: reflect.TypeOf(map[string]string{})}, nil
 	case map[string]interface{}:
 		cols := make([]*Column, len(valueCasted))
 		for k, v := range valueCasted {
@@ -356,9 +359,19 @@ func (col *Column) createTableString(indentLvl int) string {
 
 // TODO TTL only by timestamp for now!
 func (config *ChTableConfig) CreateTablePostFieldsString() string {
-	s := "ENGINE = " + config.Engine + "\n"
-	if config.OrderBy != "" {
-		s += "ORDER BY " + config.OrderBy + "\n"
+	engine, orderBy := config.Engine, config.OrderBy
+	if config.DeduplicateByDocumentId {
+		// ReplacingMergeTree deduplicates rows with equal sorting key, so the id has to be a part of it
+		engine = "ReplacingMergeTree"
+		orderByColumns := strings.TrimSuffix(strings.TrimPrefix(orderBy, "("), ")")
+		if orderByColumns != "" {
+			orderByColumns += ","
+		}
+		orderBy = "(" + orderByColumns + strconv.Quote(common_table.DocumentIdColumn) + ")"
+	}
+	s := "ENGINE = " + engine + "\n"
+	if orderBy != "" {
+		s += "ORDER BY " + orderBy + "\n"
 	}
 	if partitioningFunc := getPartitioningFunc(config.PartitionStrategy); config.PartitionStrategy != "" && partitioningFunc != "" {
 		s += "PARTITION BY " + partitioningFunc + "(" + strconv.Quote(timestampFieldName) + ")" + "\n"
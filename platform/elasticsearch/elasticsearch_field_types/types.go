@@ -6,22 +6,23 @@ package elasticsearch_field_types
 
 // Common types
 const (
-	FieldTypeBinary           string = "binary"
-	FieldTypeBoolean          string = "boolean"
-	FieldTypeByte             string = "byte"
-	FieldTypeKeyword          string = "keyword"
-	FieldTypeConstantKeyword  string = "constant_keyword"
-	FieldTypeConstantWildcard string = "wildcard"
-	FieldTypeLong             string = "long"
-	FieldTypeUnsignedLong     string = "unsigned_long"
-	FieldTypeInteger          string = "integer"
-	FieldTypeShort            string = "short"
-	FieldTypeDouble           string = "double"
-	FieldTypeFloat            string = "float"
-	FieldTypeHalfFloat        string = "half_float"
-	FieldTypeDate             string = "date"
-	FieldTypeDateNanos        string = "date_nanos"
-	FieldTypeAlias            string = "alias"
+	FieldTypeBinary          string = "binary"
+	FieldTypeBoolean         string = "boolean"
+	FieldTypeByte            string = "byte"
+	FieldTypeKeyword         string = "keyword"
+	FieldTypeConstantKeyword string = "constant_keyword"
+	FieldTypeWildcard        string = "wildcard"
+	FieldTypeLong            string = "long"
+	FieldTypeUnsignedLong    string = "unsigned_long"
+	FieldTypeInteger         string = "integer"
+	FieldTypeShort           string = "short"
+	FieldTypeDouble          string = "double"
+	FieldTypeScaledFloat     string = "scaled_float"
+	FieldTypeFloat           string = "float"
+	FieldTypeHalfFloat       string = "half_float"
+	FieldTypeDate            string = "date"
+	FieldTypeDateNanos       string = "date_nanos"
+	FieldTypeAlias           string = "alias"
 )
 
 // Objects and relational types
@@ -34,13 +35,15 @@ const (
 
 // Structured data types
 const (
-	FieldTypeLongRange   string = "long_range"
-	FieldTypeDoubleRange string = "double_range"
-	FieldTypeDateRange   string = "date_range"
-	FieldTypeIpRange     string = "ip_range"
-	FieldTypeIp          string = "ip"
-	FieldTypeTypeVersion string = "version"
-	FieldTypeTypeMurMur3 string = "murmur3"
+	FieldTypeIntegerRange string = "integer_range"
+	FieldTypeLongRange    string = "long_range"
+	FieldTypeFloatRange   string = "float_range"
+	FieldTypeDoubleRange  string = "double_range"
+	FieldTypeDateRange    string = "date_range"
+	FieldTypeIpRange      string = "ip_range"
+	FieldTypeIp           string = "ip"
+	FieldTypeVersion      string = "version"
+	FieldTypeTypeMurMur3  string = "murmur3"
 )
 
 // Aggregate data types
@@ -89,10 +92,11 @@ var AllTypes = map[string]bool{
 	FieldTypeFloat:                 true,
 	FieldTypeKeyword:               true,
 	FieldTypeConstantKeyword:       true,
-	FieldTypeConstantWildcard:      true,
+	FieldTypeWildcard:              true,
 	FieldTypeLong:                  true,
 	FieldTypeUnsignedLong:          true,
 	FieldTypeDouble:                true,
+	FieldTypeScaledFloat:           true,
 	FieldTypeDate:                  true,
 	FieldTypeDateNanos:             true,
 	FieldTypeAlias:                 true,
@@ -100,12 +104,14 @@ var AllTypes = map[string]bool{
 	FieldTypeFlattened:             true,
 	FieldTypeNested:                true,
 	FieldTypeJoin:                  true,
+	FieldTypeIntegerRange:          true,
 	FieldTypeLongRange:             true,
+	FieldTypeFloatRange:            true,
 	FieldTypeDoubleRange:           true,
 	FieldTypeDateRange:             true,
 	FieldTypeIpRange:               true,
 	FieldTypeIp:                    true,
-	FieldTypeTypeVersion:           true,
+	FieldTypeVersion:               true,
 	FieldTypeTypeMurMur3:           true,
 	FieldTypeAggregateMetricDouble: true,
 	FieldTypeHistogram:             true,
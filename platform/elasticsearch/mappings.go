@@ -3,12 +3,170 @@
 package elasticsearch
 
 import (
+	"fmt"
 	"github.com/QuesmaOrg/quesma/platform/elasticsearch/elasticsearch_field_types"
 	"github.com/QuesmaOrg/quesma/platform/logger"
 	"github.com/QuesmaOrg/quesma/platform/schema"
 	"maps"
+	"slices"
 )
 
+// ParseIndexMappings reads `mappings` of `PUT /:index`, or the body of `PUT /:index/_mapping`:
+// fields with their types, `dynamic`, `dynamic_templates` and `_source`.
+func ParseIndexMappings(mappings map[string]interface{}) (schema.Table, error) {
+	table := schema.Table{Columns: make(map[string]schema.Column)}
+	if _, found := mappings["properties"]; found {
+		table.Columns = ParseMappings("", mappings)
+	}
+
+	if dynamic, found := mappings["dynamic"]; found {
+		switch dynamic {
+		case true, "true", "runtime": // runtime fields are stored like other new fields
+			table.Dynamic = "true"
+		case false, "false":
+			table.Dynamic = "false"
+		case "strict":
+			table.Dynamic = "strict"
+		default:
+			return schema.Table{}, fmt.Errorf("unknown value for dynamic: %v", dynamic)
+		}
+	}
+
+	if dynamicTemplates, found := mappings["dynamic_templates"]; found {
+		templatesList, ok := dynamicTemplates.([]interface{})
+		if !ok {
+			return schema.Table{}, fmt.Errorf("dynamic_templates should be a list, got %T", dynamicTemplates)
+		}
+		for _, templateRaw := range templatesList {
+			// each element is a single-key object: {"name": {"match": ..., "mapping": {...}}}
+			templateAsMap, ok := templateRaw.(map[string]interface{})
+			if !ok || len(templateAsMap) != 1 {
+				return schema.Table{}, fmt.Errorf("a dynamic template should be an object with a single key, got %v", templateRaw)
+			}
+			for name, definition := range templateAsMap {
+				template, err := parseDynamicTemplate(name, definition)
+				if err != nil {
+					return schema.Table{}, err
+				}
+				table.DynamicTemplates = append(table.DynamicTemplates, template)
+			}
+		}
+	}
+
+	if source, found := mappings["_source"]; found {
+		sourceAsMap, ok := source.(map[string]interface{})
+		if !ok {
+			return schema.Table{}, fmt.Errorf("_source should be an object, got %T", source)
+		}
+		table.Source.Disabled = isFalse(sourceAsMap["enabled"])
+		var err error
+		if table.Source.Includes, err = stringList("_source.includes", sourceAsMap["includes"]); err != nil {
+			return schema.Table{}, err
+		}
+		if table.Source.Excludes, err = stringList("_source.excludes", sourceAsMap["excludes"]); err != nil {
+			return schema.Table{}, err
+		}
+	}
+	return table, nil
+}
+
+// MergeMappings adds fields and dynamic templates of an update (`PUT /:index/_mapping`) to the current mapping.
+// Like in Elasticsearch, existing fields are kept, templates with the same name are replaced and other settings are overwritten.
+func MergeMappings(current, update schema.Table) schema.Table {
+	merged := schema.Table{
+		Columns:          maps.Clone(current.Columns),
+		DatabaseName:     current.DatabaseName,
+		DynamicTemplates: slices.Clone(current.DynamicTemplates),
+		Dynamic:          current.Dynamic,
+		Source:           current.Source,
+	}
+	if merged.Columns == nil {
+		merged.Columns = make(map[string]schema.Column)
+	}
+	for name, column := range update.Columns {
+		if _, exists := merged.Columns[name]; !exists {
+			merged.Columns[name] = column
+		} else {
+			logger.Warn().Msgf("field %s already exists in the mapping, its type can't be changed", name)
+		}
+	}
+	for _, template := range update.DynamicTemplates {
+		if i := slices.IndexFunc(merged.DynamicTemplates, func(t schema.DynamicTemplate) bool { return t.Name == template.Name }); i >= 0 {
+			merged.DynamicTemplates[i] = template
+		} else {
+			merged.DynamicTemplates = append(merged.DynamicTemplates, template)
+		}
+	}
+	if update.Dynamic != "" {
+		merged.Dynamic = update.Dynamic
+	}
+	if update.Source.Disabled || len(update.Source.Includes) > 0 || len(update.Source.Excludes) > 0 {
+		merged.Source = update.Source
+	}
+	return merged
+}
+
+func parseDynamicTemplate(name string, definition interface{}) (schema.DynamicTemplate, error) {
+	definitionAsMap, ok := definition.(map[string]interface{})
+	if !ok {
+		return schema.DynamicTemplate{}, fmt.Errorf("dynamic template [%s] should be an object, got %T", name, definition)
+	}
+	template := schema.DynamicTemplate{Name: name}
+	var err error
+	for key, target := range map[string]*[]string{
+		"match":                &template.Match,
+		"unmatch":              &template.Unmatch,
+		"path_match":           &template.PathMatch,
+		"path_unmatch":         &template.PathUnmatch,
+		"match_mapping_type":   &template.MatchMappingType,
+		"unmatch_mapping_type": &template.UnmatchMappingType,
+	} {
+		if *target, err = stringList(fmt.Sprintf("dynamic template [%s] %s", name, key), definitionAsMap[key]); err != nil {
+			return schema.DynamicTemplate{}, err
+		}
+	}
+	if matchPattern, ok := definitionAsMap["match_pattern"].(string); ok {
+		template.MatchPattern = matchPattern
+	}
+
+	mapping, _ := definitionAsMap["mapping"].(map[string]interface{})
+	if mappingType, ok := mapping["type"].(string); ok && mappingType != "{dynamic_type}" {
+		parsedType, _ := ParseElasticType(mappingType)
+		if parsedType.Name == schema.QuesmaTypeUnknown.Name {
+			logger.Warn().Msgf("unknown type '%s' in dynamic template [%s], it won't change types of fields", mappingType, name)
+		} else {
+			template.Type = parsedType.Name
+		}
+	}
+	return template, nil
+}
+
+// stringList reads a string or a list of strings, like match patterns of dynamic templates
+func stringList(name string, value interface{}) ([]string, error) {
+	switch valueTyped := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{valueTyped}, nil
+	case []interface{}:
+		result := make([]string, 0, len(valueTyped))
+		for _, element := range valueTyped {
+			elementAsString, ok := element.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s should be a string or a list of strings, got %v", name, value)
+			}
+			result = append(result, elementAsString)
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("%s should be a string or a list of strings, got %v", name, value)
+	}
+}
+
+func isFalse(value interface{}) bool {
+	return value == false || value == "false"
+}
+
 func ParseMappings(namespace string, mappings map[string]interface{}) map[string]schema.Column {
 	result := make(map[string]schema.Column)
 
@@ -33,7 +191,19 @@ func ParseMappings(namespace string, mappings map[string]interface{}) map[string
 			if parsedType.Name == schema.QuesmaTypeUnknown.Name {
 				logger.Warn().Msgf("unknown type '%v' of field %s", typeMapping, fieldName)
 			}
-			result[fieldName] = schema.Column{Name: fieldName, Type: parsedType.Name}
+			column := schema.Column{Name: fieldName, Type: parsedType.Name}
+			if multiFields, ok := fieldMappingAsMap["fields"].(map[string]interface{}); ok {
+				column.MultiFields = slices.Sorted(maps.Keys(multiFields))
+			}
+			column.NotSearchable = isFalse(fieldMappingAsMap["index"])
+			column.NotAggregatable = isFalse(fieldMappingAsMap["doc_values"])
+			if scalingFactor, ok := fieldMappingAsMap["scaling_factor"].(float64); ok && parsedType.Name == schema.QuesmaTypeScaledFloat.Name {
+				column.ScalingFactor = scalingFactor
+			}
+			if value, ok := fieldMappingAsMap["value"].(string); ok && parsedType.Name == schema.QuesmaTypeConstantKeyword.Name {
+				column.ConstantValue = value
+			}
+			result[fieldName] = column
 		} else if fieldMappingAsMap["properties"] != nil {
 			// Nested field
 			maps.Copy(result, ParseMappings(fieldName, fieldMappingAsMap))
@@ -47,10 +217,17 @@ func ParseMappings(namespace string, mappings map[string]interface{}) map[string
 func GenerateMappings(schemaNode *schema.SchemaTreeNode) map[string]any {
 	if schemaNode.Field != nil {
 		result := map[string]any{"type": schemaTypeToElasticType(schemaNode.Field.Type)}
-		if schemaNode.Field.Type.Name == schema.QuesmaTypeText.Name {
+		switch schemaNode.Field.Type.Name {
+		case schema.QuesmaTypeText.Name:
 			result["fields"] = map[string]any{
 				"keyword": map[string]any{"type": "keyword"},
 			}
+		case schema.QuesmaTypeScaledFloat.Name:
+			result["scaling_factor"] = schemaNode.Field.ScalingFactor
+		case schema.QuesmaTypeConstantKeyword.Name:
+			if schemaNode.Field.ConstantValue != "" {
+				result["value"] = schemaNode.Field.ConstantValue
+			}
 		}
 		return result
 	} else {
@@ -83,6 +260,30 @@ func ParseElasticType(t string) (schema.QuesmaType, bool) {
 		return schema.QuesmaTypePoint, true
 	case elasticsearch_field_types.FieldTypeObject:
 		return schema.QuesmaTypeObject, true
+	case elasticsearch_field_types.FieldTypeFlattened:
+		return schema.QuesmaTypeFlattened, true
+	case elasticsearch_field_types.FieldTypeWildcard:
+		return schema.QuesmaTypeWildcard, true
+	case elasticsearch_field_types.FieldTypeVersion:
+		return schema.QuesmaTypeVersion, true
+	case elasticsearch_field_types.FieldTypeScaledFloat:
+		return schema.QuesmaTypeScaledFloat, true
+	case elasticsearch_field_types.FieldTypeConstantKeyword:
+		return schema.QuesmaTypeConstantKeyword, true
+	case elasticsearch_field_types.FieldTypeMatchOnlyText:
+		return schema.QuesmaTypeMatchOnlyText, true
+	case elasticsearch_field_types.FieldTypeIntegerRange:
+		return schema.QuesmaTypeIntegerRange, true
+	case elasticsearch_field_types.FieldTypeLongRange:
+		return schema.QuesmaTypeLongRange, true
+	case elasticsearch_field_types.FieldTypeFloatRange:
+		return schema.QuesmaTypeFloatRange, true
+	case elasticsearch_field_types.FieldTypeDoubleRange:
+		return schema.QuesmaTypeDoubleRange, true
+	case elasticsearch_field_types.FieldTypeDateRange:
+		return schema.QuesmaTypeDateRange, true
+	case elasticsearch_field_types.FieldTypeIpRange:
+		return schema.QuesmaTypeIpRange, true
 	default:
 		return schema.QuesmaTypeUnknown, false
 	}
@@ -116,6 +317,30 @@ func schemaTypeToElasticType(t schema.QuesmaType) string {
 		return elasticsearch_field_types.FieldTypeGeoPoint
 	case schema.QuesmaTypeMap.Name:
 		return elasticsearch_field_types.FieldTypeObject
+	case schema.QuesmaTypeFlattened.Name:
+		return elasticsearch_field_types.FieldTypeFlattened
+	case schema.QuesmaTypeWildcard.Name:
+		return elasticsearch_field_types.FieldTypeWildcard
+	case schema.QuesmaTypeVersion.Name:
+		return elasticsearch_field_types.FieldTypeVersion
+	case schema.QuesmaTypeScaledFloat.Name:
+		return elasticsearch_field_types.FieldTypeScaledFloat
+	case schema.QuesmaTypeConstantKeyword.Name:
+		return elasticsearch_field_types.FieldTypeConstantKeyword
+	case schema.QuesmaTypeMatchOnlyText.Name:
+		return elasticsearch_field_types.FieldTypeMatchOnlyText
+	case schema.QuesmaTypeIntegerRange.Name:
+		return elasticsearch_field_types.FieldTypeIntegerRange
+	case schema.QuesmaTypeLongRange.Name:
+		return elasticsearch_field_types.FieldTypeLongRange
+	case schema.QuesmaTypeFloatRange.Name:
+		return elasticsearch_field_types.FieldTypeFloatRange
+	case schema.QuesmaTypeDoubleRange.Name:
+		return elasticsearch_field_types.FieldTypeDoubleRange
+	case schema.QuesmaTypeDateRange.Name:
+		return elasticsearch_field_types.FieldTypeDateRange
+	case schema.QuesmaTypeIpRange.Name:
+		return elasticsearch_field_types.FieldTypeIpRange
 	default:
 		logger.Error().Msgf("Unknown Quesma type '%s', defaulting to 'text' type", t.Name)
 		return elasticsearch_field_types.FieldTypeText
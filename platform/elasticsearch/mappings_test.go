@@ -44,14 +44,14 @@ var (
 		"timestamp":          {Name: "timestamp", Type: "timestamp"},
 	}
 	kibanaSampleEcommerceFields = map[string]schema.Column{
-		"category":                      {Name: "category", Type: "text"},
+		"category":                      {Name: "category", Type: "text", MultiFields: []string{"keyword"}},
 		"currency":                      {Name: "currency", Type: "keyword"},
 		"customer_birth_date":           {Name: "customer_birth_date", Type: "timestamp"},
-		"customer_first_name":           {Name: "customer_first_name", Type: "text"},
-		"customer_full_name":            {Name: "customer_full_name", Type: "text"},
+		"customer_first_name":           {Name: "customer_first_name", Type: "text", MultiFields: []string{"keyword"}},
+		"customer_full_name":            {Name: "customer_full_name", Type: "text", MultiFields: []string{"keyword"}},
 		"customer_gender":               {Name: "customer_gender", Type: "keyword"},
 		"customer_id":                   {Name: "customer_id", Type: "keyword"},
-		"customer_last_name":            {Name: "customer_last_name", Type: "text"},
+		"customer_last_name":            {Name: "customer_last_name", Type: "text", MultiFields: []string{"keyword"}},
 		"customer_phone":                {Name: "customer_phone", Type: "keyword"},
 		"day_of_week":                   {Name: "day_of_week", Type: "keyword"},
 		"day_of_week_i":                 {Name: "day_of_week_i", Type: "long"},
@@ -62,21 +62,21 @@ var (
 		"geoip.country_iso_code":        {Name: "geoip.country_iso_code", Type: "keyword"},
 		"geoip.location":                {Name: "geoip.location", Type: "point"},
 		"geoip.region_name":             {Name: "geoip.region_name", Type: "keyword"},
-		"manufacturer":                  {Name: "manufacturer", Type: "text"},
+		"manufacturer":                  {Name: "manufacturer", Type: "text", MultiFields: []string{"keyword"}},
 		"order_date":                    {Name: "order_date", Type: "timestamp"},
 		"order_id":                      {Name: "order_id", Type: "keyword"},
-		"products._id":                  {Name: "products._id", Type: "text"},
+		"products._id":                  {Name: "products._id", Type: "text", MultiFields: []string{"keyword"}},
 		"products.base_price":           {Name: "products.base_price", Type: "float"},
 		"products.base_unit_price":      {Name: "products.base_unit_price", Type: "float"},
-		"products.category":             {Name: "products.category", Type: "text"},
+		"products.category":             {Name: "products.category", Type: "text", MultiFields: []string{"keyword"}},
 		"products.created_on":           {Name: "products.created_on", Type: "timestamp"},
 		"products.discount_amount":      {Name: "products.discount_amount", Type: "float"},
 		"products.discount_percentage":  {Name: "products.discount_percentage", Type: "float"},
-		"products.manufacturer":         {Name: "products.manufacturer", Type: "text"},
+		"products.manufacturer":         {Name: "products.manufacturer", Type: "text", MultiFields: []string{"keyword"}},
 		"products.min_price":            {Name: "products.min_price", Type: "float"},
 		"products.price":                {Name: "products.price", Type: "float"},
 		"products.product_id":           {Name: "products.product_id", Type: "long"},
-		"products.product_name":         {Name: "products.product_name", Type: "text"},
+		"products.product_name":         {Name: "products.product_name", Type: "text", MultiFields: []string{"keyword"}},
 		"products.quantity":             {Name: "products.quantity", Type: "long"},
 		"products.sku":                  {Name: "products.sku", Type: "keyword"},
 		"products.tax_amount":           {Name: "products.tax_amount", Type: "float"},
@@ -706,3 +706,113 @@ func TestGenerateMappings_KibanaSampleEcommerce(t *testing.T) {
 	assert.Nil(t, err)
 	require.JSONEq(t, expectedJson, string(marshaled))
 }
+
+func TestParseIndexMappings(t *testing.T) {
+	mappings, err := types.ParseJSON(`{
+		"dynamic": "strict",
+		"_source": {"excludes": ["secret.*"]},
+		"dynamic_templates": [
+			{"strings_as_keywords": {"match_mapping_type": "string", "unmatch": "*_text", "mapping": {"type": "keyword"}}},
+			{"counters": {"path_match": ["metrics.*", "stats.*"], "mapping": {"type": "long"}}},
+			{"defaults": {"match": "*", "mapping": {"type": "{dynamic_type}", "index": false}}}
+		],
+		"properties": {
+			"message": {"type": "text", "fields": {"raw": {"type": "keyword"}, "english": {"type": "text"}}},
+			"payload": {"type": "keyword", "index": false, "doc_values": false}
+		}
+	}`)
+	require.NoError(t, err)
+
+	table, err := ParseIndexMappings(mappings)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]schema.Column{
+		"message": {Name: "message", Type: "text", MultiFields: []string{"english", "raw"}},
+		"payload": {Name: "payload", Type: "keyword", NotSearchable: true, NotAggregatable: true},
+	}, table.Columns)
+	assert.Equal(t, "strict", table.Dynamic)
+	assert.Equal(t, schema.SourceMapping{Excludes: []string{"secret.*"}}, table.Source)
+	assert.Equal(t, []schema.DynamicTemplate{
+		{Name: "strings_as_keywords", MatchMappingType: []string{"string"}, Unmatch: []string{"*_text"}, Type: "keyword"},
+		{Name: "counters", PathMatch: []string{"metrics.*", "stats.*"}, Type: "long"},
+		{Name: "defaults", Match: []string{"*"}},
+	}, table.DynamicTemplates)
+
+	_, err = ParseIndexMappings(types.JSON{"dynamic_templates": types.JSON{"a": "b"}})
+	assert.Error(t, err)
+	_, err = ParseIndexMappings(types.JSON{"dynamic": "sometimes"})
+	assert.Error(t, err)
+}
+
+func TestMergeMappings(t *testing.T) {
+	current := schema.Table{
+		Columns:          map[string]schema.Column{"message": {Name: "message", Type: "text"}},
+		DynamicTemplates: []schema.DynamicTemplate{{Name: "a", Type: "keyword"}, {Name: "b", Type: "long"}},
+		Source:           schema.SourceMapping{Excludes: []string{"secret"}},
+	}
+	update := schema.Table{
+		Columns: map[string]schema.Column{
+			"message": {Name: "message", Type: "keyword"},
+			"level":   {Name: "level", Type: "keyword"},
+		},
+		DynamicTemplates: []schema.DynamicTemplate{{Name: "b", Type: "float"}, {Name: "c", Type: "boolean"}},
+		Dynamic:          "false",
+	}
+
+	merged := MergeMappings(current, update)
+	assert.Equal(t, map[string]schema.Column{
+		"message": {Name: "message", Type: "text"},
+		"level":   {Name: "level", Type: "keyword"},
+	}, merged.Columns)
+	assert.Equal(t, []schema.DynamicTemplate{{Name: "a", Type: "keyword"}, {Name: "b", Type: "float"}, {Name: "c", Type: "boolean"}}, merged.DynamicTemplates)
+	assert.Equal(t, "false", merged.Dynamic)
+	assert.Equal(t, current.Source, merged.Source)
+	assert.Len(t, current.Columns, 1, "the current mapping shouldn't be modified")
+}
+
+func TestParseMappings_SpecializedTypes(t *testing.T) {
+	mappings, err := types.ParseJSON(`{
+		"properties": {
+			"labels":   {"type": "flattened"},
+			"path":     {"type": "wildcard"},
+			"release":  {"type": "version"},
+			"price":    {"type": "scaled_float", "scaling_factor": 100},
+			"service":  {"type": "constant_keyword", "value": "checkout"},
+			"body":     {"type": "match_only_text"},
+			"ages":     {"type": "integer_range"},
+			"validity": {"type": "date_range"},
+			"subnet":   {"type": "ip_range"}
+		}
+	}`)
+	require.NoError(t, err)
+
+	columns := ParseMappings("", mappings)
+	assert.Equal(t, map[string]schema.Column{
+		"labels":   {Name: "labels", Type: "flattened"},
+		"path":     {Name: "path", Type: "wildcard"},
+		"release":  {Name: "release", Type: "version"},
+		"price":    {Name: "price", Type: "scaled_float", ScalingFactor: 100},
+		"service":  {Name: "service", Type: "constant_keyword", ConstantValue: "checkout"},
+		"body":     {Name: "body", Type: "match_only_text"},
+		"ages":     {Name: "ages", Type: "integer_range"},
+		"validity": {Name: "validity", Type: "date_range"},
+		"subnet":   {Name: "subnet", Type: "ip_range"},
+	}, columns)
+
+	for _, column := range columns {
+		quesmaType, ok := schema.ParseQuesmaType(column.Type)
+		assert.True(t, ok, column.Type)
+		assert.Equal(t, column.Type, quesmaType.Name)
+		assert.Equal(t, column.Type, schemaTypeToElasticType(quesmaType))
+	}
+
+	s := schema.Schema{Fields: map[schema.FieldName]schema.Field{
+		"price":   {PropertyName: "price", InternalPropertyName: "price", Type: schema.QuesmaTypeScaledFloat, ScalingFactor: 100},
+		"service": {PropertyName: "service", InternalPropertyName: "service", Type: schema.QuesmaTypeConstantKeyword, ConstantValue: "checkout"},
+	}}
+	marshaled, err := json.Marshal(GenerateMappings(schema.SchemaToHierarchicalSchema(&s)))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"properties": {
+		"price":   {"type": "scaled_float", "scaling_factor": 100},
+		"service": {"type": "constant_keyword", "value": "checkout"}
+	}}`, string(marshaled))
+}
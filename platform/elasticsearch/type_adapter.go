@@ -30,6 +30,30 @@ func (e SchemaTypeAdapter) Convert(s string) (schema.QuesmaType, bool) {
 		return schema.QuesmaTypeIp, true
 	case elasticsearch_field_types.FieldTypeGeoPoint:
 		return schema.QuesmaTypePoint, true
+	case elasticsearch_field_types.FieldTypeFlattened:
+		return schema.QuesmaTypeFlattened, true
+	case elasticsearch_field_types.FieldTypeWildcard:
+		return schema.QuesmaTypeWildcard, true
+	case elasticsearch_field_types.FieldTypeVersion:
+		return schema.QuesmaTypeVersion, true
+	case elasticsearch_field_types.FieldTypeScaledFloat:
+		return schema.QuesmaTypeScaledFloat, true
+	case elasticsearch_field_types.FieldTypeConstantKeyword:
+		return schema.QuesmaTypeConstantKeyword, true
+	case elasticsearch_field_types.FieldTypeMatchOnlyText:
+		return schema.QuesmaTypeMatchOnlyText, true
+	case elasticsearch_field_types.FieldTypeIntegerRange:
+		return schema.QuesmaTypeIntegerRange, true
+	case elasticsearch_field_types.FieldTypeLongRange:
+		return schema.QuesmaTypeLongRange, true
+	case elasticsearch_field_types.FieldTypeFloatRange:
+		return schema.QuesmaTypeFloatRange, true
+	case elasticsearch_field_types.FieldTypeDoubleRange:
+		return schema.QuesmaTypeDoubleRange, true
+	case elasticsearch_field_types.FieldTypeDateRange:
+		return schema.QuesmaTypeDateRange, true
+	case elasticsearch_field_types.FieldTypeIpRange:
+		return schema.QuesmaTypeIpRange, true
 	default:
 		return schema.QuesmaTypeUnknown, false
 	}
@@ -90,6 +90,7 @@ func errorType(number int, message string) *ErrorType {
 
 var ErrExpectedJSON = errorType(1001, "Invalid request body. We're expecting JSON here.")
 var ErrExpectedNDJSON = errorType(1002, "Invalid request body. We're expecting NDJSON here.")
+var ErrQuerySyntax = errorType(1003, "Invalid query syntax.")
 
 var ErrSearchCondition = errorType(2001, "Not supported search condition.")
 var ErrNoSuchTable = errorType(2002, "Missing table.")
@@ -97,6 +98,7 @@ var ErrNoSuchSchema = errorType(2003, "Missing schema.")
 var ErrNoIngest = errorType(2004, "Ingest is not enabled.")
 var ErrNoConnector = errorType(2005, "No connector found.")
 var ErrIndexNameTooLong = errorType(2006, "Index name is too long.")
+var ErrUnsupportedScript = errorType(2007, "Script can't be translated to SQL.")
 
 var ErrDatabaseTableNotFound = errorType(3001, "Table not found in database.")
 var ErrDatabaseFieldNotFound = errorType(3002, "Field not found in database.")
@@ -0,0 +1,117 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package frontend_connectors
+
+import (
+	"context"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/config"
+	"github.com/QuesmaOrg/quesma/platform/functionality/by_query"
+	"github.com/QuesmaOrg/quesma/platform/ingest"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/parsers/elastic_query_dsl"
+	"github.com/QuesmaOrg/quesma/platform/schema"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	quesma_api "github.com/QuesmaOrg/quesma/platform/v2/core"
+	"net/http"
+	"net/url"
+)
+
+// HandleByQuery handles `_delete_by_query` and `_update_by_query` of an index stored in ClickHouse.
+func HandleByQuery(ctx context.Context, cfg *config.QuesmaConfiguration, operation by_query.Operation, index string, params url.Values, body types.JSON,
+	ip *ingest.IngestProcessor, tasks *by_query.TaskRegistry) (*quesma_api.Result, error) {
+
+	// documents indexed earlier may be still waiting in the ingest buffer
+	if err := ip.FlushIngestBuffer(ctx, index); err != nil {
+		return elasticsearchErrorResult(http.StatusServiceUnavailable, "quesma_error", err.Error()), nil
+	}
+	target, err := ip.ResolveMutationTarget(index)
+	if err != nil {
+		return elasticsearchErrorResult(http.StatusBadRequest, "illegal_argument_exception", err.Error()), nil
+	}
+	request, err := by_query.ParseRequest(operation, target, params, body)
+	if err != nil {
+		return elasticsearchErrorResult(http.StatusBadRequest, "illegal_argument_exception", err.Error()), nil
+	}
+	condition, err := byQueryCondition(ctx, cfg, ip, target, params, body)
+	if err != nil {
+		return elasticsearchErrorResult(http.StatusBadRequest, "parsing_exception", err.Error()), nil
+	}
+
+	run := func(ctx context.Context, progress func(by_query.Status)) (types.JSON, error) {
+		return by_query.Run(ctx, ip, target, condition, request, progress)
+	}
+
+	if !request.WaitForCompletion {
+		task := tasks.Start(operation.Action(), fmt.Sprintf("%s [%s]", operation, index), run)
+		return elasticsearchJSONResult(types.JSON{"task": task.TaskId()}, http.StatusOK)
+	}
+
+	response, err := run(ctx, func(by_query.Status) {})
+	if err != nil {
+		return elasticsearchErrorResult(http.StatusBadRequest, "illegal_argument_exception", err.Error()), nil
+	}
+	return elasticsearchJSONResult(response, http.StatusOK)
+}
+
+// byQueryCondition translates the query of the request to a WHERE clause, the same way we translate search requests.
+func byQueryCondition(ctx context.Context, cfg *config.QuesmaConfiguration, ip *ingest.IngestProcessor, target *ingest.MutationTarget,
+	params url.Values, body types.JSON) (model.Expr, error) {
+
+	indexSchema, ok := ip.GetSchemaRegistry().FindSchema(schema.IndexName(target.IndexName))
+	if !ok {
+		return nil, fmt.Errorf("can't load %s schema", target.IndexName)
+	}
+
+	searchBody := types.JSON{"size": 0.0, "track_total_hits": true}
+	if query, ok := body["query"]; ok {
+		searchBody["query"] = query
+	}
+	if params.Has("q") {
+		searchBody["query"] = types.JSON{"query_string": types.JSON{"query": params.Get("q")}}
+	}
+
+	// Documents of the common table are described by the virtual table of their index
+	table := target.Table
+	if target.IndexFilter != nil {
+		if virtualTable := ip.FindTable(target.IndexName); virtualTable != nil {
+			table = virtualTable
+		}
+	}
+
+	cw := &elastic_query_dsl.ClickhouseQueryTranslator{Ctx: ctx, Schema: indexSchema, Table: table, Indexes: []string{target.IndexName}}
+	plan, err := cw.ParseQuery(searchBody)
+	if err != nil {
+		return nil, err
+	}
+	if len(plan.Queries) != 1 {
+		return nil, fmt.Errorf("unexpected number of queries: %d", len(plan.Queries))
+	}
+	if plan, err = NewSchemaCheckPass(cfg, ip.GetTableDiscovery(), defaultSearchAfterStrategy).Transform(plan); err != nil {
+		return nil, err
+	}
+
+	if condition := plan.Queries[0].SelectCommand.WhereClause; condition != nil {
+		return condition, nil
+	}
+	return model.NewLiteral("true"), nil
+}
+
+func HandleGetTask(tasks *by_query.TaskRegistry, taskId string) (*quesma_api.Result, error) {
+	task, ok := tasks.Get(taskId)
+	if !ok {
+		return elasticsearchErrorResult(http.StatusNotFound, "resource_not_found_exception",
+			fmt.Sprintf("task [%s] isn't running and hasn't stored its results", taskId)), nil
+	}
+	return elasticsearchJSONResult(task.Render(), http.StatusOK)
+}
+
+func HandleCancelTask(tasks *by_query.TaskRegistry, taskId string) (*quesma_api.Result, error) {
+	task, ok := tasks.Get(taskId)
+	if !ok {
+		return elasticsearchErrorResult(http.StatusNotFound, "resource_not_found_exception",
+			fmt.Sprintf("task [%s] is missing", taskId)), nil
+	}
+	task.Cancel()
+	return elasticsearchJSONResult(types.JSON{"nodes": types.JSON{by_query.TaskNodeId: types.JSON{"tasks": types.JSON{taskId: task.Render()["task"]}}}}, http.StatusOK)
+}
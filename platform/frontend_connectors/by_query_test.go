@@ -0,0 +1,230 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package frontend_connectors
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/QuesmaOrg/quesma/platform/backend_connectors"
+	"github.com/QuesmaOrg/quesma/platform/common_table"
+	"github.com/QuesmaOrg/quesma/platform/config"
+	"github.com/QuesmaOrg/quesma/platform/database_common"
+	"github.com/QuesmaOrg/quesma/platform/functionality/by_query"
+	"github.com/QuesmaOrg/quesma/platform/ingest"
+	"github.com/QuesmaOrg/quesma/platform/persistence"
+	"github.com/QuesmaOrg/quesma/platform/schema"
+	"github.com/QuesmaOrg/quesma/platform/table_resolver"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"github.com/QuesmaOrg/quesma/platform/v2/core/diag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newByQueryTestProcessor(t *testing.T) (*ingest.IngestProcessor, sqlmock.Sqlmock, *config.QuesmaConfiguration) {
+	const tableName = "logs"
+	indexConfig := config.IndicesConfigs{tableName: {}, "legacy": {}}
+	cfg := &config.QuesmaConfiguration{IndexConfig: indexConfig}
+
+	tables := database_common.NewTableMap()
+	tables.Store(tableName, &database_common.Table{
+		Name:   tableName,
+		Config: database_common.NewChTableConfigTimestampStringAttr(),
+		Cols: map[string]*database_common.Column{
+			"message":                     {Name: "message", Type: database_common.NewBaseType("String")},
+			"counter":                     {Name: "counter", Type: database_common.NewBaseType("Int64")},
+			"host_name":                   {Name: "host_name", Type: database_common.NewBaseType("String")},
+			common_table.DocumentIdColumn: {Name: common_table.DocumentIdColumn, Type: database_common.NewBaseType("String")},
+		},
+	})
+	tables.Store("legacy", &database_common.Table{
+		Name:   "legacy",
+		Config: database_common.NewChTableConfigTimestampStringAttr(),
+		Cols:   map[string]*database_common.Column{"message": {Name: "message", Type: database_common.NewBaseType("String")}},
+	})
+	tableDiscovery := database_common.NewEmptyTableDiscovery()
+	tableDiscovery.TableMap = tables
+
+	registry := &schema.StaticRegistry{
+		Tables: map[schema.IndexName]schema.Schema{
+			tableName: schema.NewSchema(map[schema.FieldName]schema.Field{
+				"message":   {PropertyName: "message", InternalPropertyName: "message", Type: schema.QuesmaTypeKeyword},
+				"counter":   {PropertyName: "counter", InternalPropertyName: "counter", Type: schema.QuesmaTypeLong},
+				"host.name": {PropertyName: "host.name", InternalPropertyName: "host_name", Type: schema.QuesmaTypeKeyword},
+			}, true, ""),
+			"legacy": schema.NewSchema(map[schema.FieldName]schema.Field{
+				"message": {PropertyName: "message", InternalPropertyName: "message", Type: schema.QuesmaTypeKeyword},
+			}, true, ""),
+		},
+	}
+
+	conn, mock := util.InitSqlMockWithPrettySqlAndPrint(t, true)
+	t.Cleanup(func() { conn.Close() })
+	db := backend_connectors.NewClickHouseBackendConnectorWithConnection("", conn)
+
+	ip := ingest.NewIngestProcessor(cfg, db, diag.NewPhoneHomeEmptyAgent(), tableDiscovery, registry,
+		ingest.NewSqlLowerer(persistence.NewStaticJSONDatabase()), table_resolver.NewDummyTableResolver(indexConfig, false))
+	return ip, mock, cfg
+}
+
+func partitionCounts(counts map[string]int64, partitionIds ...string) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"_partition_id", "count()"})
+	for _, partitionId := range partitionIds {
+		rows.AddRow(partitionId, counts[partitionId])
+	}
+	return rows
+}
+
+func TestDeleteByQuery(t *testing.T) {
+	ip, mock, cfg := newByQueryTestProcessor(t)
+
+	mock.ExpectQuery(`SELECT _partition_id, count(*) FROM "logs" WHERE "message"='error' GROUP BY _partition_id ORDER BY _partition_id`).
+		WillReturnRows(partitionCounts(map[string]int64{"202401": 3, "202402": 2}, "202401", "202402"))
+	mock.ExpectExec(`DELETE FROM "logs" WHERE ("_partition_id"='202401' AND "message"='error')`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "logs" WHERE ("_partition_id"='202402' AND "message"='error')`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	body := types.JSON{"query": map[string]any{"term": map[string]any{"message": "error"}}}
+	result, err := HandleByQuery(context.Background(), cfg, by_query.DeleteByQuery, "logs", url.Values{}, body, ip, by_query.NewTaskRegistry())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+
+	response := resultJSON(t, result.Body)
+	assert.Equal(t, 5.0, response["total"])
+	assert.Equal(t, 5.0, response["deleted"])
+	assert.Equal(t, 2.0, response["batches"])
+	assert.Equal(t, 0.0, response["version_conflicts"])
+	assert.Equal(t, []any{}, response["failures"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateByQueryWithScript(t *testing.T) {
+	ip, mock, cfg := newByQueryTestProcessor(t)
+
+	mock.ExpectQuery(`SELECT _partition_id, count(*) FROM "logs" WHERE "host_name"='a' GROUP BY _partition_id ORDER BY _partition_id`).
+		WillReturnRows(partitionCounts(map[string]int64{"all": 4}, "all"))
+	mock.ExpectExec(`ALTER TABLE "logs" UPDATE "counter" = "counter" + ? WHERE ("_partition_id"='all' AND "host_name"='a')`).
+		WithArgs(2.0).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	body := types.JSON{
+		"query":  map[string]any{"term": map[string]any{"host.name": "a"}},
+		"script": map[string]any{"source": "ctx._source.counter += params.n", "params": map[string]any{"n": 2.0}},
+	}
+	result, err := HandleByQuery(context.Background(), cfg, by_query.UpdateByQuery, "logs", url.Values{}, body, ip, by_query.NewTaskRegistry())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+
+	response := resultJSON(t, result.Body)
+	assert.Equal(t, 4.0, response["total"])
+	assert.Equal(t, 4.0, response["updated"])
+	assert.Equal(t, 1.0, response["batches"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestByQueryInvalidRequests(t *testing.T) {
+	ip, mock, cfg := newByQueryTestProcessor(t)
+
+	testcases := []struct {
+		name      string
+		operation by_query.Operation
+		params    url.Values
+		body      types.JSON
+	}{
+		{"invalid conflicts", by_query.DeleteByQuery, url.Values{"conflicts": {"ignore"}}, types.JSON{}},
+		{"invalid requests_per_second", by_query.DeleteByQuery, url.Values{"requests_per_second": {"0"}}, types.JSON{}},
+		{"script in delete", by_query.DeleteByQuery, url.Values{}, types.JSON{"script": "ctx._source.counter = 1"}},
+		{"unsupported script", by_query.UpdateByQuery, url.Values{}, types.JSON{"script": "ctx._source.counter = Math.max(1, 2)"}},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := HandleByQuery(context.Background(), cfg, tc.operation, "logs", tc.params, tc.body, ip, by_query.NewTaskRegistry())
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		})
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestByQueryMaxDocs(t *testing.T) {
+	ip, mock, cfg := newByQueryTestProcessor(t)
+
+	// 13 documents match, only the 5 with the lowest ids are deleted
+	mock.ExpectQuery(`SELECT _partition_id, count(*) FROM "logs" WHERE "message"='error' GROUP BY _partition_id ORDER BY _partition_id`).
+		WillReturnRows(partitionCounts(map[string]int64{"202401": 3, "202402": 10}, "202401", "202402"))
+	mock.ExpectExec(`DELETE FROM "logs" WHERE ("_partition_id"='202401' AND "message"='error')`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT DISTINCT "__quesma_id" FROM "logs" WHERE (("_partition_id"='202402' AND "message"='error') AND "__quesma_id"!='') ORDER BY "__quesma_id" LIMIT 2`).
+		WillReturnRows(sqlmock.NewRows([]string{"__quesma_id"}).AddRow("a").AddRow("b"))
+	mock.ExpectExec(`DELETE FROM "logs" WHERE (("_partition_id"='202402' AND "message"='error') AND "__quesma_id" IN tuple('a', 'b'))`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	body := types.JSON{"query": map[string]any{"term": map[string]any{"message": "error"}}, "max_docs": 5.0}
+	result, err := HandleByQuery(context.Background(), cfg, by_query.DeleteByQuery, "logs", url.Values{}, body, ip, by_query.NewTaskRegistry())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+
+	response := resultJSON(t, result.Body)
+	assert.Equal(t, 5.0, response["total"])
+	assert.Equal(t, 5.0, response["deleted"])
+	assert.Equal(t, 2.0, response["batches"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// counts come from the ids, when some matching documents have no id
+	mock.ExpectQuery(`SELECT _partition_id, count(*) FROM "logs" WHERE "message"='error' GROUP BY _partition_id ORDER BY _partition_id`).
+		WillReturnRows(partitionCounts(map[string]int64{"all": 10}, "all"))
+	mock.ExpectQuery(`SELECT DISTINCT "__quesma_id" FROM "logs" WHERE (("_partition_id"='all' AND "message"='error') AND "__quesma_id"!='') ORDER BY "__quesma_id" LIMIT 5`).
+		WillReturnRows(sqlmock.NewRows([]string{"__quesma_id"}).AddRow("a"))
+	mock.ExpectExec(`DELETE FROM "logs" WHERE (("_partition_id"='all' AND "message"='error') AND "__quesma_id" IN 'a')`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	result, err = HandleByQuery(context.Background(), cfg, by_query.DeleteByQuery, "logs", url.Values{}, body, ip, by_query.NewTaskRegistry())
+	require.NoError(t, err)
+	response = resultJSON(t, result.Body)
+	assert.Equal(t, 1.0, response["total"])
+	assert.Equal(t, 1.0, response["deleted"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// documents of tables without ids can't be picked
+	mock.ExpectQuery(`SELECT _partition_id, count(*) FROM "legacy" WHERE true GROUP BY _partition_id ORDER BY _partition_id`).
+		WillReturnRows(partitionCounts(map[string]int64{"all": 10}, "all"))
+
+	result, err = HandleByQuery(context.Background(), cfg, by_query.DeleteByQuery, "legacy", url.Values{"max_docs": {"5"}}, types.JSON{}, ip, by_query.NewTaskRegistry())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	assert.Contains(t, result.Body, "only documents of tables storing `_id` can be limited")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteByQueryTask(t *testing.T) {
+	ip, mock, cfg := newByQueryTestProcessor(t)
+	tasks := by_query.NewTaskRegistry()
+
+	mock.ExpectQuery(`SELECT _partition_id, count(*) FROM "logs" WHERE "counter">=10 GROUP BY _partition_id ORDER BY _partition_id`).
+		WillReturnRows(partitionCounts(map[string]int64{"all": 7}, "all"))
+	mock.ExpectExec(`DELETE FROM "logs" WHERE ("_partition_id"='all' AND "counter">=10)`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	body := types.JSON{"query": map[string]any{"range": map[string]any{"counter": map[string]any{"gte": 10}}}}
+	result, err := HandleByQuery(context.Background(), cfg, by_query.DeleteByQuery, "logs", url.Values{"wait_for_completion": {"false"}}, body, ip, tasks)
+	require.NoError(t, err)
+	taskId, ok := resultJSON(t, result.Body)["task"].(string)
+	require.True(t, ok)
+	assert.Equal(t, "quesma:1", taskId)
+
+	var task map[string]any
+	assert.Eventually(t, func() bool {
+		result, err = HandleGetTask(tasks, taskId)
+		require.NoError(t, err)
+		task = resultJSON(t, result.Body)
+		return task["completed"] == true
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "indices:data/write/delete/byquery", task["task"].(map[string]any)["action"])
+	assert.Equal(t, 7.0, task["response"].(map[string]any)["deleted"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	result, err = HandleGetTask(tasks, "quesma:2")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, result.StatusCode)
+}
@@ -8,8 +8,10 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/elasticsearch"
 	"github.com/QuesmaOrg/quesma/platform/end_user_errors"
 	"github.com/QuesmaOrg/quesma/platform/functionality/bulk"
+	"github.com/QuesmaOrg/quesma/platform/ingest/buffer"
 	"github.com/QuesmaOrg/quesma/platform/logger"
 	"github.com/QuesmaOrg/quesma/platform/parsers/elastic_query_dsl"
+	"github.com/QuesmaOrg/quesma/platform/types"
 	quesma_api "github.com/QuesmaOrg/quesma/platform/v2/core"
 	"github.com/goccy/go-json"
 	"net/http"
@@ -73,6 +75,10 @@ func noIngestEnabledButThereIngestRequest() {
 
 func bulkInsertResult(ctx context.Context, ops []bulk.BulkItem, err error) (*quesma_api.Result, error) {
 
+	if errors.Is(err, buffer.ErrFull) {
+		return elasticsearchErrorResult(http.StatusTooManyRequests, "es_rejected_execution_exception", err.Error()), nil
+	}
+
 	if err != nil {
 		var msg string
 		var reason string
@@ -144,6 +150,25 @@ func elasticsearchInsertResult(body string, statusCode int) *quesma_api.Result {
 		GenericResult: []byte(body)}
 }
 
+func elasticsearchJSONResult(body types.JSON, statusCode int) (*quesma_api.Result, error) {
+	responseBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return elasticsearchInsertResult(string(responseBytes), statusCode), nil
+}
+
+// elasticsearchErrorResult renders an error in the format of Elasticsearch
+func elasticsearchErrorResult(statusCode int, errorType, reason string) *quesma_api.Result {
+	cause := types.JSON{"type": errorType, "reason": reason}
+	body := types.JSON{
+		"error":  types.JSON{"root_cause": []any{cause}, "type": errorType, "reason": reason},
+		"status": statusCode,
+	}
+	responseBytes, _ := json.Marshal(body)
+	return elasticsearchInsertResult(string(responseBytes), statusCode)
+}
+
 func resolveIndexResult(sources elasticsearch.Sources) (*quesma_api.Result, error) {
 	if len(sources.Aliases) == 0 && len(sources.DataStreams) == 0 && len(sources.Indices) == 0 {
 		return &quesma_api.Result{StatusCode: http.StatusNotFound}, nil
@@ -166,6 +191,9 @@ func indexDocResult(bulkItem bulk.BulkItem) (*quesma_api.Result, error) {
 	if err != nil {
 		return nil, err
 	}
+	if response, ok := bulkItem.Index.(bulk.BulkSingleResponse); ok && response.Status == http.StatusTooManyRequests {
+		return elasticsearchInsertResult(string(body), http.StatusTooManyRequests), nil
+	}
 	return elasticsearchInsertResult(string(body), http.StatusOK), nil
 }
 
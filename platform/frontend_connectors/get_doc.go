@@ -0,0 +1,271 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package frontend_connectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	quesma_errors "github.com/QuesmaOrg/quesma/platform/errors"
+	"github.com/QuesmaOrg/quesma/platform/functionality/doc"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/schema"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	quesma_api "github.com/QuesmaOrg/quesma/platform/v2/core"
+	"github.com/goccy/go-json"
+	"net/http"
+	"net/url"
+)
+
+// Document APIs (get, `_source`, `_mget`) are served by searching for documents with the `ids` query.
+// Ids point to our rows if they were supplied at ingest, or if we generated them in search hits.
+// Ref: https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-get.html
+// Ref: https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-multi-get.html
+
+// fetchDocuments returns documents of the index with given ids, by id.
+func fetchDocuments(ctx context.Context, index string, ids []string, queryRunner QueryRunnerIFace) (map[string]model.SearchHit, error) {
+	values := make([]any, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id)
+	}
+	documents := make(map[string]model.SearchHit)
+	if len(values) == 0 {
+		return documents, nil
+	}
+
+	// documents indexed earlier may be still waiting in the ingest buffer
+	if flusher, ok := queryRunner.(interface {
+		FlushIngestBuffer(ctx context.Context, index string) error
+	}); ok {
+		if err := flusher.FlushIngestBuffer(ctx, index); err != nil {
+			return nil, err
+		}
+	}
+
+	// Nested objects are plain maps, like in parsed request bodies. `size` isn't needed,
+	// as ids queries aren't limited by the default size.
+	body := types.JSON{"query": map[string]any{"ids": map[string]any{"values": values}}, "track_total_hits": false}
+	responseBody, err := queryRunner.HandleSearch(ctx, index, body)
+	if err != nil {
+		return nil, err
+	}
+	var response model.SearchResp
+	if err = json.Unmarshal(responseBody, &response); err != nil {
+		return nil, err
+	}
+	for _, hit := range response.Hits.Hits {
+		if _, alreadyFound := documents[hit.ID]; !alreadyFound {
+			documents[hit.ID] = hit
+		}
+	}
+	return documents, nil
+}
+
+// mappingSourceFilter returns `_source` of the index mapping. It's applied before the filter of the request,
+// as fields excluded in the mapping are never returned.
+func mappingSourceFilter(sr schema.Registry, index string) doc.SourceFilter {
+	if sr == nil {
+		return doc.SourceFilter{}
+	}
+	mapping, _ := sr.GetDynamicConfiguration(schema.IndexName(index))
+	return doc.SourceFilter{Disabled: mapping.Source.Disabled, Includes: mapping.Source.Includes, Excludes: mapping.Source.Excludes}
+}
+
+// renderGetResponse renders a single document like `GET /:index/_doc/:id` does.
+func renderGetResponse(index, id string, hit model.SearchHit, found bool, mappingFilter, sourceFilter doc.SourceFilter) (types.JSON, error) {
+	if !found {
+		return types.JSON{"_index": index, "_id": id, "found": false}, nil
+	}
+	response := types.JSON{
+		"_index":        hit.Index,
+		"_id":           hit.ID,
+		"_version":      1,
+		"_seq_no":       0,
+		"_primary_term": 1,
+		"found":         true,
+	}
+	if !sourceFilter.Disabled && !mappingFilter.Disabled {
+		var source map[string]any
+		if err := json.Unmarshal(hit.Source, &source); err != nil {
+			return nil, err
+		}
+		response["_source"] = sourceFilter.Apply(mappingFilter.Apply(source))
+	}
+	return response, nil
+}
+
+func HandleGetDoc(ctx context.Context, index, id string, params url.Values, headOnly bool, queryRunner QueryRunnerIFace, sr schema.Registry) (*quesma_api.Result, error) {
+	documents, err := fetchDocuments(ctx, index, []string{id}, queryRunner)
+	if err != nil {
+		return documentErrorResult(index, err)
+	}
+	hit, found := documents[id]
+
+	statusCode := http.StatusOK
+	if !found {
+		statusCode = http.StatusNotFound
+	}
+	if headOnly {
+		return &quesma_api.Result{StatusCode: statusCode, GenericResult: make([]byte, 0)}, nil
+	}
+
+	response, err := renderGetResponse(index, id, hit, found, mappingSourceFilter(sr, index), doc.ParseSourceFilterParams(params))
+	if err != nil {
+		return nil, err
+	}
+	return elasticsearchJSONResult(response, statusCode)
+}
+
+// HandleGetSource handles `GET /:index/_source/:id`, which returns only the (filtered) source of the document.
+func HandleGetSource(ctx context.Context, index, id string, params url.Values, headOnly bool, queryRunner QueryRunnerIFace, sr schema.Registry) (*quesma_api.Result, error) {
+	documents, err := fetchDocuments(ctx, index, []string{id}, queryRunner)
+	if err != nil {
+		return documentErrorResult(index, err)
+	}
+	hit, found := documents[id]
+	mappingFilter := mappingSourceFilter(sr, index)
+	if !found || mappingFilter.Disabled {
+		if headOnly {
+			return &quesma_api.Result{StatusCode: http.StatusNotFound, GenericResult: make([]byte, 0)}, nil
+		}
+		if found {
+			return elasticsearchErrorResult(http.StatusNotFound, "resource_not_found_exception", fmt.Sprintf("Source not found [%s]/[%s]", index, id)), nil
+		}
+		return elasticsearchErrorResult(http.StatusNotFound, "resource_not_found_exception", fmt.Sprintf("Document not found [%s]/[%s]", index, id)), nil
+	}
+	if headOnly {
+		return &quesma_api.Result{StatusCode: http.StatusOK, GenericResult: make([]byte, 0)}, nil
+	}
+
+	var source map[string]any
+	if err = json.Unmarshal(hit.Source, &source); err != nil {
+		return nil, err
+	}
+	sourceFilter := doc.ParseSourceFilterParams(params)
+	sourceFilter.Disabled = false // `_source=false` makes no sense here
+	return elasticsearchJSONResult(sourceFilter.Apply(mappingFilter.Apply(source)), http.StatusOK)
+}
+
+type mgetDocument struct {
+	index        string
+	id           string
+	sourceFilter doc.SourceFilter
+}
+
+// HandleMget handles `_mget`, documents are fetched with a single search per index.
+func HandleMget(ctx context.Context, defaultIndex string, params url.Values, body types.JSON, queryRunner QueryRunnerIFace, sr schema.Registry) (*quesma_api.Result, error) {
+	requested, err := parseMgetRequest(defaultIndex, params, body)
+	if err != nil {
+		return elasticsearchErrorResult(http.StatusBadRequest, "action_request_validation_exception", err.Error()), nil
+	}
+
+	idsByIndex := make(map[string][]string)
+	var indexes []string
+	for _, document := range requested {
+		if _, seen := idsByIndex[document.index]; !seen {
+			indexes = append(indexes, document.index)
+		}
+		idsByIndex[document.index] = append(idsByIndex[document.index], document.id)
+	}
+
+	documentsByIndex := make(map[string]map[string]model.SearchHit)
+	errorsByIndex := make(map[string]error)
+	for _, index := range indexes {
+		if documentsByIndex[index], err = fetchDocuments(ctx, index, idsByIndex[index], queryRunner); err != nil {
+			errorsByIndex[index] = err
+		}
+	}
+
+	docs := make([]any, 0, len(requested))
+	for _, document := range requested {
+		if err := errorsByIndex[document.index]; err != nil {
+			errorType := "quesma_error"
+			if errors.Is(quesma_errors.ErrIndexNotExists(), err) {
+				errorType = "index_not_found_exception"
+			}
+			docs = append(docs, types.JSON{
+				"_index": document.index,
+				"_id":    document.id,
+				"error":  types.JSON{"root_cause": []any{types.JSON{"type": errorType, "reason": err.Error()}}, "type": errorType, "reason": err.Error()},
+			})
+			continue
+		}
+		hit, found := documentsByIndex[document.index][document.id]
+		response, err := renderGetResponse(document.index, document.id, hit, found, mappingSourceFilter(sr, document.index), document.sourceFilter)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, response)
+	}
+	return elasticsearchJSONResult(types.JSON{"docs": docs}, http.StatusOK)
+}
+
+func parseMgetRequest(defaultIndex string, params url.Values, body types.JSON) ([]mgetDocument, error) {
+	defaultSourceFilter := doc.ParseSourceFilterParams(params)
+
+	if ids, hasIds := body["ids"].([]any); hasIds {
+		if defaultIndex == "" {
+			return nil, fmt.Errorf("Validation Failed: 1: index is missing for doc 0;")
+		}
+		documents := make([]mgetDocument, 0, len(ids))
+		for _, id := range ids {
+			idAsString, ok := id.(string)
+			if !ok {
+				return nil, fmt.Errorf("ids should be strings, got %T", id)
+			}
+			documents = append(documents, mgetDocument{index: defaultIndex, id: idAsString, sourceFilter: defaultSourceFilter})
+		}
+		return documents, nil
+	}
+
+	docs, ok := body["docs"].([]any)
+	if !ok {
+		return nil, fmt.Errorf("Validation Failed: 1: no documents to get;")
+	}
+	documents := make([]mgetDocument, 0, len(docs))
+	for i, docRaw := range docs {
+		docMap, ok := docRaw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("docs should be objects, got %T", docRaw)
+		}
+		document := mgetDocument{index: defaultIndex, sourceFilter: defaultSourceFilter}
+		if index, ok := docMap["_index"].(string); ok {
+			document.index = index
+		}
+		if document.index == "" {
+			return nil, fmt.Errorf("Validation Failed: 1: index is missing for doc %d;", i)
+		}
+		if document.id, ok = docMap["_id"].(string); !ok {
+			return nil, fmt.Errorf("Validation Failed: 1: id is missing for doc %d;", i)
+		}
+		if source, hasSource := docMap["_source"]; hasSource {
+			sourceFilter, err := doc.ParseSourceFilter(source)
+			if err != nil {
+				return nil, err
+			}
+			document.sourceFilter = sourceFilter.Merge(defaultSourceFilter)
+		}
+		documents = append(documents, document)
+	}
+	return documents, nil
+}
+
+// mgetIndexes returns all indexes referenced by a `_mget` request, used to check if we can serve it.
+func mgetIndexes(defaultIndex string, body types.JSON) []string {
+	documents, err := parseMgetRequest(defaultIndex, url.Values{}, body)
+	if err != nil {
+		return nil
+	}
+	indexes := make([]string, 0, len(documents))
+	for _, document := range documents {
+		indexes = append(indexes, document.index)
+	}
+	return indexes
+}
+
+func documentErrorResult(index string, err error) (*quesma_api.Result, error) {
+	if errors.Is(quesma_errors.ErrIndexNotExists(), err) {
+		return elasticsearchErrorResult(http.StatusNotFound, "index_not_found_exception", fmt.Sprintf("no such index [%s]", index)), nil
+	}
+	return nil, err
+}
@@ -0,0 +1,218 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package frontend_connectors
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/QuesmaOrg/quesma/platform/backend_connectors"
+	"github.com/QuesmaOrg/quesma/platform/common_table"
+	"github.com/QuesmaOrg/quesma/platform/database_common"
+	quesma_errors "github.com/QuesmaOrg/quesma/platform/errors"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/schema"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const (
+	docId1 = "323032342d30312d30312030303a30303a30302e303030202b3030303020555443qqq61"
+	docId2 = "323032342d30312d30322030303a30303a30302e303030202b3030303020555443qqq62"
+)
+
+// searchRunnerStub returns stored hits of an index with ids from the `ids` query
+type searchRunnerStub struct {
+	QueryRunnerIFace
+	hits     map[string][]model.SearchHit
+	searches []types.JSON
+}
+
+func (s *searchRunnerStub) HandleSearch(_ context.Context, indexPattern string, body types.JSON) ([]byte, error) {
+	hits, ok := s.hits[indexPattern]
+	if !ok {
+		return nil, quesma_errors.ErrIndexNotExists()
+	}
+	s.searches = append(s.searches, body)
+
+	ids := body["query"].(map[string]any)["ids"].(map[string]any)["values"].([]any)
+	var matching []model.SearchHit
+	for _, hit := range hits {
+		if slices.Contains(ids, any(hit.ID)) {
+			matching = append(matching, hit)
+		}
+	}
+	return json.Marshal(model.SearchResp{Hits: model.SearchHits{Hits: matching}})
+}
+
+func newSearchRunnerStub() *searchRunnerStub {
+	return &searchRunnerStub{hits: map[string][]model.SearchHit{
+		"logs": {
+			{Index: "logs", ID: docId1, Source: json.RawMessage(`{"message":"a","host":{"name":"h1","ip":"10.0.0.1"},"user.name":"u1"}`)},
+			{Index: "logs", ID: docId2, Source: json.RawMessage(`{"message":"b","host":{"name":"h2","ip":"10.0.0.2"},"user.name":"u2"}`)},
+			{Index: "logs", ID: "client-id", Source: json.RawMessage(`{"message":"c"}`)},
+		},
+	}}
+}
+
+func resultJSON(t *testing.T, result string) map[string]any {
+	var response map[string]any
+	require.NoError(t, json.Unmarshal([]byte(result), &response))
+	return response
+}
+
+func TestHandleGetDoc(t *testing.T) {
+	runner := newSearchRunnerStub()
+
+	result, err := HandleGetDoc(context.Background(), "logs", docId1, url.Values{"_source_excludes": {"host.ip"}}, false, runner, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	response := resultJSON(t, result.Body)
+	assert.Equal(t, true, response["found"])
+	assert.Equal(t, docId1, response["_id"])
+	assert.Equal(t, map[string]any{"message": "a", "host": map[string]any{"name": "h1"}, "user.name": "u1"}, response["_source"])
+	require.Len(t, runner.searches, 1)
+	assert.Equal(t, map[string]any{"ids": map[string]any{"values": []any{docId1}}}, runner.searches[0]["query"])
+
+	result, err = HandleGetDoc(context.Background(), "logs", "client-id", url.Values{}, false, runner, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "client-id", resultJSON(t, result.Body)["_id"])
+
+	result, err = HandleGetDoc(context.Background(), "logs", "unknown-id", url.Values{}, false, runner, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, result.StatusCode)
+	assert.Equal(t, false, resultJSON(t, result.Body)["found"])
+
+	result, err = HandleGetDoc(context.Background(), "logs", docId2, url.Values{}, true, runner, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Empty(t, result.Body)
+
+	result, err = HandleGetDoc(context.Background(), "missing", docId2, url.Values{}, false, runner, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, result.StatusCode)
+	assert.Equal(t, "index_not_found_exception", resultJSON(t, result.Body)["error"].(map[string]any)["type"])
+}
+
+func TestHandleGetSource(t *testing.T) {
+	runner := newSearchRunnerStub()
+
+	result, err := HandleGetSource(context.Background(), "logs", docId2, url.Values{"_source_includes": {"host.*,user"}}, false, runner, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, map[string]any{"host": map[string]any{"name": "h2", "ip": "10.0.0.2"}, "user.name": "u2"}, resultJSON(t, result.Body))
+
+	result, err = HandleGetSource(context.Background(), "logs", "unknown-id", url.Values{}, false, runner, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, result.StatusCode)
+}
+
+func TestHandleMget(t *testing.T) {
+	runner := newSearchRunnerStub()
+
+	body := types.JSON{"docs": []any{
+		map[string]any{"_id": docId2, "_source": []any{"message"}},
+		map[string]any{"_index": "missing", "_id": docId1},
+		map[string]any{"_id": docId1, "_source": false},
+		map[string]any{"_id": "unknown-id"},
+	}}
+	result, err := HandleMget(context.Background(), "logs", url.Values{}, body, runner, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Len(t, runner.searches, 1, "documents of the same index should be fetched at once")
+
+	docs := resultJSON(t, result.Body)["docs"].([]any)
+	require.Len(t, docs, 4)
+	assert.Equal(t, map[string]any{"message": "b"}, docs[0].(map[string]any)["_source"])
+	assert.Equal(t, "index_not_found_exception", docs[1].(map[string]any)["error"].(map[string]any)["type"])
+	assert.Equal(t, true, docs[2].(map[string]any)["found"])
+	assert.NotContains(t, docs[2], "_source")
+	assert.Equal(t, false, docs[3].(map[string]any)["found"])
+
+	result, err = HandleMget(context.Background(), "logs", url.Values{"_source": {"false"}}, types.JSON{"ids": []any{docId1}}, runner, nil)
+	require.NoError(t, err)
+	docs = resultJSON(t, result.Body)["docs"].([]any)
+	require.Len(t, docs, 1)
+	assert.NotContains(t, docs[0], "_source")
+
+	result, err = HandleMget(context.Background(), "", url.Values{}, types.JSON{"ids": []any{docId1}}, runner, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+}
+
+func TestGetDocWithSourceMapping(t *testing.T) {
+	runner := newSearchRunnerStub()
+	sr := schema.NewStaticRegistry(nil, map[string]schema.Table{
+		"logs": {Source: schema.SourceMapping{Excludes: []string{"host.*"}}},
+	}, nil)
+
+	result, err := HandleGetDoc(context.Background(), "logs", docId1, url.Values{"_source_includes": {"host.*,message"}}, false, runner, sr)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"message": "a"}, resultJSON(t, result.Body)["_source"])
+
+	sr.DynamicConfiguration["logs"] = schema.Table{Source: schema.SourceMapping{Disabled: true}}
+	result, err = HandleGetDoc(context.Background(), "logs", docId1, url.Values{}, false, runner, sr)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.NotContains(t, resultJSON(t, result.Body), "_source")
+
+	result, err = HandleGetSource(context.Background(), "logs", docId1, url.Values{}, false, runner, sr)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, result.StatusCode)
+}
+
+func TestHandleMgetManyDocuments(t *testing.T) {
+	table := database_common.Table{
+		Name:   tableName,
+		Config: database_common.NewDefaultCHConfig(),
+		Cols: map[string]*database_common.Column{
+			"message":                     {Name: "message", Type: database_common.NewBaseType("String")},
+			common_table.DocumentIdColumn: {Name: common_table.DocumentIdColumn, Type: database_common.NewBaseType("String")},
+		},
+	}
+	s := &schema.StaticRegistry{
+		Tables: map[schema.IndexName]schema.Schema{
+			tableName: schema.NewSchema(map[schema.FieldName]schema.Field{
+				"message": {PropertyName: "message", InternalPropertyName: "message", Type: schema.QuesmaTypeText},
+			}, true, ""),
+		},
+	}
+	conn, mock := util.InitSqlMockWithPrettySqlAndPrint(t, false)
+	defer conn.Close()
+	db := backend_connectors.NewClickHouseBackendConnectorWithConnection("", conn)
+	queryRunner := NewQueryRunnerDefaultForTests(db, &DefaultConfig, tableName, util.NewSyncMapWith(tableName, &table), s)
+
+	var ids, quotedIds []string
+	rows := sqlmock.NewRows([]string{"message", common_table.DocumentIdColumn})
+	for i := 0; i < 15; i++ {
+		id := "id-" + strconv.Itoa(i)
+		ids = append(ids, id)
+		quotedIds = append(quotedIds, "'"+id+"'")
+		rows.AddRow("m", id)
+	}
+	mock.ExpectQuery(`SELECT "message", "__quesma_id" FROM __quesma_table_name WHERE "__quesma_id" IN tuple(` + strings.Join(quotedIds, ", ") + `) LIMIT 10000`).
+		WillReturnRows(rows)
+
+	idsRaw := make([]any, len(ids))
+	for i, id := range ids {
+		idsRaw[i] = id
+	}
+	result, err := HandleMget(context.Background(), tableName, url.Values{}, types.JSON{"ids": idsRaw}, queryRunner, nil)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	docs := resultJSON(t, result.Body)["docs"].([]any)
+	require.Len(t, docs, 15)
+	for i, doc := range docs {
+		assert.Equal(t, true, doc.(map[string]any)["found"], "document %d", i)
+		assert.Equal(t, ids[i], doc.(map[string]any)["_id"])
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package frontend_connectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/backend_connectors"
+	"github.com/QuesmaOrg/quesma/platform/index_templates"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	quesma_api "github.com/QuesmaOrg/quesma/platform/v2/core"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Index and component templates are stored and applied by Quesma. Changes are mirrored to Elasticsearch,
+// which applies them to its own indexes.
+// Ref: https://www.elastic.co/guide/en/elasticsearch/reference/current/index-templates.html
+
+func HandlePutIndexTemplate(ctx context.Context, store *index_templates.Store, esConn *backend_connectors.ElasticsearchBackendConnector, name string, body types.JSON) (*quesma_api.Result, error) {
+	if err := store.PutIndexTemplate(name, body); err != nil {
+		return elasticsearchErrorResult(http.StatusBadRequest, "illegal_argument_exception", err.Error()), nil
+	}
+	if requestBody, err := body.Bytes(); err == nil {
+		mirrorToElasticsearch(ctx, esConn, "PUT", "_index_template/"+url.PathEscape(name), requestBody)
+	}
+	return elasticsearchJSONResult(types.JSON{"acknowledged": true}, http.StatusOK)
+}
+
+func HandlePutComponentTemplate(ctx context.Context, store *index_templates.Store, esConn *backend_connectors.ElasticsearchBackendConnector, name string, body types.JSON) (*quesma_api.Result, error) {
+	if err := store.PutComponentTemplate(name, body); err != nil {
+		return elasticsearchErrorResult(http.StatusBadRequest, "illegal_argument_exception", err.Error()), nil
+	}
+	if requestBody, err := body.Bytes(); err == nil {
+		mirrorToElasticsearch(ctx, esConn, "PUT", "_component_template/"+url.PathEscape(name), requestBody)
+	}
+	return elasticsearchJSONResult(types.JSON{"acknowledged": true}, http.StatusOK)
+}
+
+// HandleGetIndexTemplate returns index templates matching name, which can be a comma-separated list of names or wildcard patterns.
+// An empty name returns all templates. With exists=true (HEAD requests) only the status is returned.
+func HandleGetIndexTemplate(store *index_templates.Store, name string, exists bool) (*quesma_api.Result, error) {
+	templates, err := store.IndexTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []any
+	for _, template := range templates {
+		if templateNameMatches(name, template.Name) {
+			result = append(result, types.JSON{"name": template.Name, "index_template": template.Definition})
+		}
+	}
+	return templatesResult("index", name, exists, "index_templates", result)
+}
+
+// HandleGetComponentTemplate works like HandleGetIndexTemplate for component templates.
+func HandleGetComponentTemplate(store *index_templates.Store, name string, exists bool) (*quesma_api.Result, error) {
+	templates, err := store.ComponentTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []any
+	for _, template := range templates {
+		if templateNameMatches(name, template.Name) {
+			result = append(result, types.JSON{"name": template.Name, "component_template": template.Definition})
+		}
+	}
+	return templatesResult("component", name, exists, "component_templates", result)
+}
+
+func templateNameMatches(names, templateName string) bool {
+	if names == "" || names == "*" {
+		return true
+	}
+	for _, pattern := range strings.Split(names, ",") {
+		if matched, _ := path.Match(pattern, templateName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func templatesResult(kind, name string, exists bool, key string, templates []any) (*quesma_api.Result, error) {
+	found := len(templates) > 0 || name == ""
+	if exists {
+		statusCode := http.StatusOK
+		if !found {
+			statusCode = http.StatusNotFound
+		}
+		return &quesma_api.Result{StatusCode: statusCode, GenericResult: make([]byte, 0)}, nil
+	}
+	if !found {
+		return elasticsearchErrorResult(http.StatusNotFound, "resource_not_found_exception", fmt.Sprintf("%s template matching [%s] not found", kind, name)), nil
+	}
+	if templates == nil {
+		templates = []any{}
+	}
+	return elasticsearchJSONResult(types.JSON{key: templates}, http.StatusOK)
+}
+
+func HandleDeleteIndexTemplate(ctx context.Context, store *index_templates.Store, esConn *backend_connectors.ElasticsearchBackendConnector, name string) (*quesma_api.Result, error) {
+	found, err := store.DeleteIndexTemplate(name)
+	if err != nil {
+		return nil, err
+	}
+	mirrorToElasticsearch(ctx, esConn, "DELETE", "_index_template/"+url.PathEscape(name), nil)
+	if !found {
+		return elasticsearchErrorResult(http.StatusNotFound, "resource_not_found_exception", fmt.Sprintf("index_template [%s] missing", name)), nil
+	}
+	return elasticsearchJSONResult(types.JSON{"acknowledged": true}, http.StatusOK)
+}
+
+func HandleDeleteComponentTemplate(ctx context.Context, store *index_templates.Store, esConn *backend_connectors.ElasticsearchBackendConnector, name string) (*quesma_api.Result, error) {
+	found, err := store.DeleteComponentTemplate(name)
+	var inUseErr *index_templates.InUseError
+	if errors.As(err, &inUseErr) {
+		return elasticsearchErrorResult(http.StatusBadRequest, "illegal_argument_exception", err.Error()), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	mirrorToElasticsearch(ctx, esConn, "DELETE", "_component_template/"+url.PathEscape(name), nil)
+	if !found {
+		return elasticsearchErrorResult(http.StatusNotFound, "resource_not_found_exception", fmt.Sprintf("component template matching [%s] not found", name)), nil
+	}
+	return elasticsearchJSONResult(types.JSON{"acknowledged": true}, http.StatusOK)
+}
+
+// HandleSimulateIndex returns the template, which would be applied to a new index of the given name.
+func HandleSimulateIndex(store *index_templates.Store, index string) (*quesma_api.Result, error) {
+	resolved, ok := store.Resolve(index)
+	if !ok {
+		return elasticsearchJSONResult(types.JSON{}, http.StatusOK)
+	}
+
+	overlapping := make([]any, 0, len(resolved.Overlapping))
+	for _, template := range resolved.Overlapping {
+		overlapping = append(overlapping, types.JSON{"name": template.Name, "index_patterns": template.IndexPatterns})
+	}
+	return elasticsearchJSONResult(types.JSON{
+		"template": types.JSON{
+			"settings": resolved.Settings,
+			"mappings": resolved.Mappings,
+			"aliases":  types.JSON{},
+		},
+		"overlapping": overlapping,
+	}, http.StatusOK)
+}
@@ -0,0 +1,41 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package frontend_connectors
+
+import (
+	"context"
+	"github.com/QuesmaOrg/quesma/platform/index_templates"
+	"github.com/QuesmaOrg/quesma/platform/persistence"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestTemplateChangesAreMirroredToElasticsearch(t *testing.T) {
+	esConn, requests := newRecordingElasticsearch(t)
+	store := index_templates.NewStore(persistence.NewStaticJSONDatabase(), persistence.NewStaticJSONDatabase())
+	ctx := context.Background()
+
+	result, err := HandlePutComponentTemplate(ctx, store, esConn, "settings", types.MustJSON(`{"template":{"settings":{"number_of_shards":1}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	result, err = HandlePutIndexTemplate(ctx, store, esConn, "logs", types.MustJSON(`{"index_patterns":["logs-*"],"composed_of":["settings"]}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+
+	result, err = HandleDeleteIndexTemplate(ctx, store, esConn, "logs")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	result, err = HandleDeleteComponentTemplate(ctx, store, esConn, "settings")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+
+	assert.Equal(t, []string{
+		`PUT /_component_template/settings {"template":{"settings":{"number_of_shards":1}}}`,
+		`PUT /_index_template/logs {"composed_of":["settings"],"index_patterns":["logs-*"]}`,
+		`DELETE /_index_template/logs `,
+		`DELETE /_component_template/settings `,
+	}, *requests)
+}
@@ -0,0 +1,98 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package frontend_connectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/backend_connectors"
+	"github.com/QuesmaOrg/quesma/platform/ingest/pipeline"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	quesma_api "github.com/QuesmaOrg/quesma/platform/v2/core"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Ingest pipelines are stored and executed by Quesma. Changes are mirrored to Elasticsearch, which runs
+// the same pipelines for documents of its indexes (the `pipeline` parameter is forwarded to it).
+// Ref: https://www.elastic.co/guide/en/elasticsearch/reference/current/ingest-apis.html
+
+func HandlePutIngestPipeline(ctx context.Context, store *pipeline.Store, esConn *backend_connectors.ElasticsearchBackendConnector, id string, body types.JSON) (*quesma_api.Result, error) {
+	if err := store.Put(id, body); err != nil {
+		return elasticsearchErrorResult(http.StatusBadRequest, "parse_exception", err.Error()), nil
+	}
+	if requestBody, err := body.Bytes(); err == nil {
+		mirrorToElasticsearch(ctx, esConn, "PUT", "_ingest/pipeline/"+url.PathEscape(id), requestBody)
+	}
+	return elasticsearchJSONResult(types.JSON{"acknowledged": true}, http.StatusOK)
+}
+
+// HandleGetIngestPipeline returns pipelines matching id, which can be a comma-separated list of ids or wildcard patterns.
+// An empty id returns all pipelines.
+func HandleGetIngestPipeline(store *pipeline.Store, id string) (*quesma_api.Result, error) {
+	storedIds, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var matchingIds []string
+	if id == "" || id == "*" {
+		matchingIds = storedIds
+	} else {
+		for _, pattern := range strings.Split(id, ",") {
+			for _, storedId := range storedIds {
+				if matched, _ := path.Match(pattern, storedId); matched {
+					matchingIds = append(matchingIds, storedId)
+				}
+			}
+		}
+	}
+
+	result := types.JSON{}
+	for _, matchingId := range matchingIds {
+		stored, err := store.Get(matchingId)
+		if err != nil {
+			return nil, err
+		}
+		result[matchingId] = stored.Definition
+	}
+	if len(result) == 0 && id != "" {
+		return elasticsearchJSONResult(types.JSON{}, http.StatusNotFound)
+	}
+	return elasticsearchJSONResult(result, http.StatusOK)
+}
+
+func HandleDeleteIngestPipeline(ctx context.Context, store *pipeline.Store, esConn *backend_connectors.ElasticsearchBackendConnector, id string) (*quesma_api.Result, error) {
+	found, err := store.Delete(id)
+	if err != nil {
+		return nil, err
+	}
+	mirrorToElasticsearch(ctx, esConn, "DELETE", "_ingest/pipeline/"+url.PathEscape(id), nil)
+	if !found {
+		return elasticsearchErrorResult(http.StatusNotFound, "resource_not_found_exception", fmt.Sprintf("pipeline [%s] is missing", id)), nil
+	}
+	return elasticsearchJSONResult(types.JSON{"acknowledged": true}, http.StatusOK)
+}
+
+// HandleSimulateIngestPipeline runs the stored (if id is not empty) or inlined pipeline on documents from the request.
+func HandleSimulateIngestPipeline(store *pipeline.Store, id string, body types.JSON) (*quesma_api.Result, error) {
+	var stored *pipeline.Pipeline
+	if id != "" {
+		var err error
+		if stored, err = store.Get(id); err != nil {
+			if errors.Is(err, pipeline.ErrNotFound) {
+				return elasticsearchErrorResult(http.StatusNotFound, "resource_not_found_exception", err.Error()), nil
+			}
+			return nil, err
+		}
+	}
+
+	response, err := pipeline.Simulate(body, stored, store.Get)
+	if err != nil {
+		return elasticsearchErrorResult(http.StatusBadRequest, "parse_exception", err.Error()), nil
+	}
+	return elasticsearchJSONResult(response, http.StatusOK)
+}
@@ -0,0 +1,54 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package frontend_connectors
+
+import (
+	"context"
+	"github.com/QuesmaOrg/quesma/platform/backend_connectors"
+	"github.com/QuesmaOrg/quesma/platform/config"
+	"github.com/QuesmaOrg/quesma/platform/ingest/pipeline"
+	"github.com/QuesmaOrg/quesma/platform/persistence"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// newRecordingElasticsearch returns a connector to Elasticsearch, which records requests and acknowledges them
+func newRecordingElasticsearch(t *testing.T) (*backend_connectors.ElasticsearchBackendConnector, *[]string) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+string(body))
+		_, _ = w.Write([]byte(`{"acknowledged":true}`))
+	}))
+	t.Cleanup(server.Close)
+	serverUrl, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	return backend_connectors.NewElasticsearchBackendConnector(config.ElasticsearchConfiguration{Url: (*config.Url)(serverUrl)}), &requests
+}
+
+func TestIngestPipelineChangesAreMirroredToElasticsearch(t *testing.T) {
+	esConn, requests := newRecordingElasticsearch(t)
+	store := pipeline.NewStore(persistence.NewStaticJSONDatabase())
+	ctx := context.Background()
+
+	result, err := HandlePutIngestPipeline(ctx, store, esConn, "tag", types.MustJSON(`{"processors":[{"set":{"field":"tag","value":"x"}}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	_, err = store.Get("tag")
+	assert.NoError(t, err, "Quesma keeps its own copy")
+
+	result, err = HandleDeleteIngestPipeline(ctx, store, esConn, "tag")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+
+	assert.Equal(t, []string{
+		`PUT /_ingest/pipeline/tag {"processors":[{"set":{"field":"tag","value":"x"}}]}`,
+		`DELETE /_ingest/pipeline/tag `,
+	}, *requests)
+}
@@ -3,6 +3,7 @@
 package frontend_connectors
 
 import (
+	"github.com/QuesmaOrg/quesma/platform/functionality/by_query"
 	"github.com/QuesmaOrg/quesma/platform/parsers/painful"
 	"github.com/QuesmaOrg/quesma/platform/table_resolver"
 	"github.com/QuesmaOrg/quesma/platform/types"
@@ -21,6 +22,13 @@ func matchedAgainstAsyncId() quesma_api.RequestMatcher {
 	})
 }
 
+func matchedAgainstQuesmaTaskId() quesma_api.RequestMatcher {
+	return quesma_api.RequestMatcherFunc(func(req *quesma_api.Request) quesma_api.MatchResult {
+		_, isQuesmaTask := by_query.ParseTaskId(req.Params["id"])
+		return quesma_api.MatchResult{Matched: isQuesmaTask}
+	})
+}
+
 func matchedAgainstPattern(indexRegistry table_resolver.TableResolver) quesma_api.RequestMatcher {
 	return matchAgainstTableResolver(indexRegistry, quesma_api.QueryPipeline)
 }
@@ -41,6 +49,36 @@ func matchAgainstTableResolver(indexRegistry table_resolver.TableResolver, pipel
 	})
 }
 
+// matchedAgainstMgetIndexes matches `_mget` requests referencing only indexes stored in ClickHouse.
+func matchedAgainstMgetIndexes(indexRegistry table_resolver.TableResolver) quesma_api.RequestMatcher {
+	return quesma_api.RequestMatcherFunc(func(req *quesma_api.Request) quesma_api.MatchResult {
+		body, err := types.ExpectJSON(req.ParsedBody)
+		if err != nil {
+			return quesma_api.MatchResult{Matched: false}
+		}
+		indexNames := mgetIndexes(req.Params["index"], body)
+		if len(indexNames) == 0 {
+			return quesma_api.MatchResult{Matched: false}
+		}
+		for _, indexName := range indexNames {
+			decision := indexRegistry.Resolve(quesma_api.QueryPipeline, indexName)
+			if decision.Err != nil {
+				return quesma_api.MatchResult{Matched: false, Decision: decision}
+			}
+			usesClickhouse := false
+			for _, connector := range decision.UseConnectors {
+				if _, ok := connector.(*quesma_api.ConnectorDecisionClickhouse); ok {
+					usesClickhouse = true
+				}
+			}
+			if !usesClickhouse {
+				return quesma_api.MatchResult{Matched: false, Decision: decision}
+			}
+		}
+		return quesma_api.MatchResult{Matched: true}
+	})
+}
+
 // getPitIdFromRequest gets the PIT ID from the request body,
 // depending on request kind it can be either at root or under `pit` key, e.g.:
 // {"id": "pit_id"} or {"pit": {"id": "pit_id"}}
@@ -18,6 +18,7 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/functionality/field_capabilities"
 	"github.com/QuesmaOrg/quesma/platform/functionality/resolve"
 	"github.com/QuesmaOrg/quesma/platform/ingest"
+	"github.com/QuesmaOrg/quesma/platform/ingest/buffer"
 	"github.com/QuesmaOrg/quesma/platform/logger"
 	"github.com/QuesmaOrg/quesma/platform/parsers/elastic_query_dsl"
 	"github.com/QuesmaOrg/quesma/platform/schema"
@@ -25,7 +26,9 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/types"
 	quesma_api "github.com/QuesmaOrg/quesma/platform/v2/core"
 	"github.com/QuesmaOrg/quesma/platform/v2/core/tracing"
+	"io"
 	"net/http"
+	"time"
 )
 
 const quesmaPitPrefix = "quesma_"
@@ -73,8 +76,8 @@ func HandleIndexSearch(ctx context.Context, indexPattern string, query types.JSO
 	return elasticsearchQueryResult(string(responseBody), http.StatusOK), nil
 }
 
-func HandleIndexAsyncSearch(ctx context.Context, indexPattern string, query types.JSON, waitForResultsMs int, keepOnCompletion bool, queryRunner QueryRunnerIFace) (*quesma_api.Result, error) {
-	responseBody, err := queryRunner.HandleAsyncSearch(ctx, indexPattern, query, waitForResultsMs, keepOnCompletion)
+func HandleIndexAsyncSearch(ctx context.Context, indexPattern string, query types.JSON, waitForResultsMs int, keepOnCompletion bool, keepAlive time.Duration, queryRunner QueryRunnerIFace) (*quesma_api.Result, error) {
+	responseBody, err := queryRunner.HandleAsyncSearch(ctx, indexPattern, query, waitForResultsMs, keepOnCompletion, keepAlive)
 	if err != nil {
 		if errors.Is(quesma_errors.ErrIndexNotExists(), err) {
 			return &quesma_api.Result{StatusCode: http.StatusNotFound, GenericResult: make([]byte, 0)}, nil
@@ -143,13 +146,34 @@ func HandlePutIndex(index string, reqBody types.JSON, sr schema.Registry) (*ques
 		logger.Warn().Msgf("no mappings found in PUT /%s request, ignoring that request. Full content: %s", index, reqBody)
 		return putIndexResult(index)
 	}
-	columns := elasticsearch.ParseMappings("", mappings.(map[string]interface{}))
+	mappingsAsMap, ok := mappings.(map[string]interface{})
+	if !ok {
+		return elasticsearchErrorResult(http.StatusBadRequest, "mapper_parsing_exception", fmt.Sprintf("mappings should be an object, got %T", mappings)), nil
+	}
+	table, err := elasticsearch.ParseIndexMappings(mappingsAsMap)
+	if err != nil {
+		return elasticsearchErrorResult(http.StatusBadRequest, "mapper_parsing_exception", err.Error()), nil
+	}
 
-	sr.UpdateDynamicConfiguration(schema.IndexName(index), schema.Table{Columns: columns})
+	sr.UpdateDynamicConfiguration(schema.IndexName(index), table)
 
 	return putIndexResult(index)
 }
 
+// HandlePutIndexMapping handles `PUT /:index/_mapping`, which adds new fields and dynamic templates to the mapping.
+// Existing fields are kept, and their columns are not changed.
+func HandlePutIndexMapping(index string, reqBody types.JSON, sr schema.Registry) (*quesma_api.Result, error) {
+	update, err := elasticsearch.ParseIndexMappings(reqBody)
+	if err != nil {
+		return elasticsearchErrorResult(http.StatusBadRequest, "mapper_parsing_exception", err.Error()), nil
+	}
+
+	current, _ := sr.GetDynamicConfiguration(schema.IndexName(index))
+	sr.UpdateDynamicConfiguration(schema.IndexName(index), elasticsearch.MergeMappings(current, update))
+
+	return elasticsearchJSONResult(types.JSON{"acknowledged": true}, http.StatusOK)
+}
+
 func HandleGetIndex(sr schema.Registry, index string) (*quesma_api.Result, error) {
 	foundSchema, found := sr.FindSchema(schema.IndexName(index))
 	if !found {
@@ -239,13 +263,16 @@ func PitDeletedResponse() (*quesma_api.Result, error) {
 	}, nil
 }
 
-func HandleBulkIndex(ctx context.Context, index string, body types.NDJSON, ip *ingest.IngestProcessor, ingestStatsEnabled bool, esConn *backend_connectors.ElasticsearchBackendConnector, dependencies quesma_api.Dependencies, tableResolver table_resolver.TableResolver) (*quesma_api.Result, error) {
-	results, err := bulk.Write(ctx, &index, body, ip, ingestStatsEnabled, esConn, dependencies.PhoneHomeAgent(), tableResolver)
+func HandleBulkIndex(ctx context.Context, index string, pipeline string, body types.NDJSON, ip *ingest.IngestProcessor, ingestStatsEnabled bool, esConn *backend_connectors.ElasticsearchBackendConnector, dependencies quesma_api.Dependencies, tableResolver table_resolver.TableResolver) (*quesma_api.Result, error) {
+	results, err := bulk.Write(ctx, &index, pipeline, body, ip, ingestStatsEnabled, esConn, dependencies.PhoneHomeAgent(), tableResolver)
 	return bulkInsertResult(ctx, results, err)
 }
 
-func HandleIndexDoc(ctx context.Context, index string, body types.JSON, ip *ingest.IngestProcessor, ingestStatsEnabled bool, esConn *backend_connectors.ElasticsearchBackendConnector, dependencies quesma_api.Dependencies, tableResolver table_resolver.TableResolver) (*quesma_api.Result, error) {
-	result, err := doc.Write(ctx, &index, body, ip, ingestStatsEnabled, dependencies.PhoneHomeAgent(), tableResolver, esConn)
+func HandleIndexDoc(ctx context.Context, index string, pipeline string, body types.JSON, ip *ingest.IngestProcessor, ingestStatsEnabled bool, esConn *backend_connectors.ElasticsearchBackendConnector, dependencies quesma_api.Dependencies, tableResolver table_resolver.TableResolver) (*quesma_api.Result, error) {
+	result, err := doc.Write(ctx, &index, pipeline, body, ip, ingestStatsEnabled, dependencies.PhoneHomeAgent(), tableResolver, esConn)
+	if errors.Is(err, buffer.ErrFull) {
+		return elasticsearchErrorResult(http.StatusTooManyRequests, "es_rejected_execution_exception", err.Error()), nil
+	}
 	if err != nil {
 		return &quesma_api.Result{
 			Body:          string(elastic_query_dsl.BadRequestParseError(err)),
@@ -257,8 +284,8 @@ func HandleIndexDoc(ctx context.Context, index string, body types.JSON, ip *inge
 	return indexDocResult(result)
 }
 
-func HandleBulk(ctx context.Context, body types.NDJSON, ip *ingest.IngestProcessor, ingestStatsEnabled bool, esConn *backend_connectors.ElasticsearchBackendConnector, dependencies quesma_api.Dependencies, tableResolver table_resolver.TableResolver) (*quesma_api.Result, error) {
-	results, err := bulk.Write(ctx, nil, body, ip, ingestStatsEnabled, esConn, dependencies.PhoneHomeAgent(), tableResolver)
+func HandleBulk(ctx context.Context, pipeline string, body types.NDJSON, ip *ingest.IngestProcessor, ingestStatsEnabled bool, esConn *backend_connectors.ElasticsearchBackendConnector, dependencies quesma_api.Dependencies, tableResolver table_resolver.TableResolver) (*quesma_api.Result, error) {
+	results, err := bulk.Write(ctx, nil, pipeline, body, ip, ingestStatsEnabled, esConn, dependencies.PhoneHomeAgent(), tableResolver)
 	return bulkInsertResult(ctx, results, err)
 }
 
@@ -287,3 +314,22 @@ func HandleMultiSearch(ctx context.Context, req *quesma_api.Request, defaultInde
 
 	return elasticsearchQueryResult(string(responseBody), http.StatusOK), nil
 }
+
+// mirrorToElasticsearch sends a change of an object Quesma keeps on its own (e.g. an ingest pipeline or a template)
+// to Elasticsearch, so that its indexes use it too. Failures (e.g. features Elasticsearch doesn't have) are only logged.
+func mirrorToElasticsearch(ctx context.Context, esConn *backend_connectors.ElasticsearchBackendConnector, method, endpoint string, body []byte) {
+	if esConn == nil {
+		return
+	}
+	response, err := esConn.Request(ctx, method, endpoint, body)
+	if err != nil {
+		logger.WarnWithCtx(ctx).Msgf("failed to mirror %s %s to Elasticsearch: %v", method, endpoint, err)
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusOK || (method == "DELETE" && response.StatusCode == http.StatusNotFound) {
+		return
+	}
+	responseBody, _ := io.ReadAll(response.Body)
+	logger.WarnWithCtx(ctx).Msgf("failed to mirror %s %s to Elasticsearch: %s, %s", method, endpoint, response.Status, string(responseBody))
+}
@@ -0,0 +1,45 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package frontend_connectors
+
+import (
+	"github.com/QuesmaOrg/quesma/platform/schema"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestHandlePutIndexMapping(t *testing.T) {
+	sr := schema.NewStaticRegistry(nil, map[string]schema.Table{}, nil)
+
+	result, err := HandlePutIndex("logs", types.MustJSON(`{"mappings": {
+		"properties": {"message": {"type": "text"}},
+		"dynamic_templates": [{"ids": {"match": "*_id", "mapping": {"type": "keyword"}}}]
+	}}`), sr)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+
+	// `_mapping` has fields at the top level, and they're added to the existing ones
+	result, err = HandlePutIndexMapping("logs", types.MustJSON(`{
+		"properties": {"message": {"type": "keyword"}, "level": {"type": "keyword"}},
+		"_source": {"enabled": false}
+	}`), sr)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, true, resultJSON(t, result.Body)["acknowledged"])
+
+	mapping := sr.DynamicConfiguration["logs"]
+	assert.Equal(t, map[string]schema.Column{
+		"message": {Name: "message", Type: "text"},
+		"level":   {Name: "level", Type: "keyword"},
+	}, mapping.Columns)
+	assert.Equal(t, []schema.DynamicTemplate{{Name: "ids", Match: []string{"*_id"}, Type: "keyword"}}, mapping.DynamicTemplates)
+	assert.True(t, mapping.Source.Disabled)
+
+	result, err = HandlePutIndexMapping("logs", types.MustJSON(`{"dynamic_templates": "none"}`), sr)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	assert.Equal(t, "mapper_parsing_exception", resultJSON(t, result.Body)["error"].(map[string]any)["type"])
+}
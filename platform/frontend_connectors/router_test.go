@@ -6,6 +6,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/QuesmaOrg/quesma/platform/async_search_storage"
 	"github.com/QuesmaOrg/quesma/platform/backend_connectors"
 	"github.com/QuesmaOrg/quesma/platform/clickhouse"
 	"github.com/QuesmaOrg/quesma/platform/config"
@@ -16,6 +18,7 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/functionality/doc"
 	"github.com/QuesmaOrg/quesma/platform/functionality/field_capabilities"
 	"github.com/QuesmaOrg/quesma/platform/functionality/resolve"
+	"github.com/QuesmaOrg/quesma/platform/index_templates"
 	"github.com/QuesmaOrg/quesma/platform/ingest"
 	"github.com/QuesmaOrg/quesma/platform/logger"
 	"github.com/QuesmaOrg/quesma/platform/parsers/elastic_query_dsl"
@@ -31,7 +34,9 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/v2/core/tracing"
 	"github.com/goccy/go-json"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"net/http"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -106,7 +111,7 @@ func configureRouter(cfg *config.QuesmaConfiguration, sr schema.Registry, lm *da
 			return nil, err
 		}
 
-		results, err := bulk.Write(ctx, nil, body, ip, cfg.IngestStatistics, elasticsearchConnector, phoneHomeAgent, tableResolver)
+		results, err := bulk.Write(ctx, nil, "", body, ip, cfg.IngestStatistics, elasticsearchConnector, phoneHomeAgent, tableResolver)
 		return bulkInsertResult(ctx, results, err)
 	})
 
@@ -126,7 +131,7 @@ func configureRouter(cfg *config.QuesmaConfiguration, sr schema.Registry, lm *da
 			}, nil
 		}
 
-		result, err := doc.Write(ctx, &index, body, ip, cfg.IngestStatistics, phoneHomeAgent, tableResolver, elasticsearchConnector)
+		result, err := doc.Write(ctx, &index, "", body, ip, cfg.IngestStatistics, phoneHomeAgent, tableResolver, elasticsearchConnector)
 		if err != nil {
 			return &quesma_api.Result{
 				Body:          string(elastic_query_dsl.BadRequestParseError(err)),
@@ -146,7 +151,7 @@ func configureRouter(cfg *config.QuesmaConfiguration, sr schema.Registry, lm *da
 			return nil, err
 		}
 
-		results, err := bulk.Write(ctx, &index, body, ip, cfg.IngestStatistics, elasticsearchConnector, phoneHomeAgent, tableResolver)
+		results, err := bulk.Write(ctx, &index, "", body, ip, cfg.IngestStatistics, elasticsearchConnector, phoneHomeAgent, tableResolver)
 		return bulkInsertResult(ctx, results, err)
 	})
 
@@ -242,7 +247,7 @@ func configureRouter(cfg *config.QuesmaConfiguration, sr schema.Registry, lm *da
 			return nil, err
 		}
 
-		responseBody, err := queryRunner.HandleAsyncSearch(ctx, req.Params["index"], body, waitForResultsMs, keepOnCompletion)
+		responseBody, err := queryRunner.HandleAsyncSearch(ctx, req.Params["index"], body, waitForResultsMs, keepOnCompletion, 0)
 		if err != nil {
 			if errors.Is(quesma_errors.ErrIndexNotExists(), err) {
 				return &quesma_api.Result{StatusCode: http.StatusNotFound}, nil
@@ -794,3 +799,54 @@ func (t TestTableResolver) Pipelines() []string { return []string{} }
 func (t TestTableResolver) RecentDecisions() []quesma_api.PatternDecisions {
 	return []quesma_api.PatternDecisions{}
 }
+
+func (t TestTableResolver) SetIndexTemplates(_ *index_templates.Store) {}
+
+func TestAsyncSearchKeepAliveFromQueryString(t *testing.T) {
+	table := database_common.Table{
+		Name:   tableName,
+		Config: database_common.NewDefaultCHConfig(),
+		Cols: map[string]*database_common.Column{
+			"message": {Name: "message", Type: database_common.NewBaseType("String")},
+		},
+	}
+	s := &schema.StaticRegistry{
+		Tables: map[schema.IndexName]schema.Schema{
+			tableName: schema.NewSchema(map[schema.FieldName]schema.Field{
+				"message": {PropertyName: "message", InternalPropertyName: "message", Type: schema.QuesmaTypeText},
+			}, true, ""),
+		},
+	}
+	conn, mock := util.InitSqlMockWithPrettySqlAndPrint(t, false)
+	defer conn.Close()
+	db := backend_connectors.NewClickHouseBackendConnectorWithConnection("", conn)
+	mock.ExpectQuery(`SELECT "message" FROM __quesma_table_name LIMIT 10`).WillReturnRows(sqlmock.NewRows([]string{"message"}))
+
+	queryRunner := NewQueryRunnerDefaultForTests(db, &DefaultConfig, tableName, util.NewSyncMapWith(tableName, &table), s)
+	storage := async_search_storage.NewAsyncSearchStorageInMemory()
+	queryRunner.AsyncRequestStorage = storage
+
+	resolver := table_resolver.NewEmptyTableResolver()
+	resolver.Decisions[tableName] = &quesma_api.Decision{
+		UseConnectors: []quesma_api.ConnectorDecision{&quesma_api.ConnectorDecisionClickhouse{ClickhouseTableName: tableName}},
+	}
+	router := ConfigureSearchRouterV2(&DefaultConfig, quesma_api.EmptyDependencies(), s, nil, queryRunner, resolver)
+
+	req := &quesma_api.Request{
+		Method:      "POST",
+		Path:        strings.Replace(routes.IndexAsyncSearchPath, ":index", tableName, 1),
+		QueryParams: url.Values{"keep_alive": {"2h"}, "keep_on_completion": {"true"}},
+		ParsedBody:  types.MustJSON(`{"query": {"match_all": {}}, "track_total_hits": false}`),
+	}
+	handler, _ := router.Matches(req)
+	require.NotNil(t, handler)
+	_, err := handler.Handler(context.Background(), req, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, storage.Size())
+
+	// the default keep alive is shorter than an hour
+	storage.Evict(time.Now().Add(time.Hour))
+	assert.Equal(t, 1, storage.Size())
+	storage.Evict(time.Now().Add(3 * time.Hour))
+	assert.Equal(t, 0, storage.Size())
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/config"
 	"github.com/QuesmaOrg/quesma/platform/database_common"
 	"github.com/QuesmaOrg/quesma/platform/errors"
+	"github.com/QuesmaOrg/quesma/platform/functionality/by_query"
 	"github.com/QuesmaOrg/quesma/platform/ingest"
 	"github.com/QuesmaOrg/quesma/platform/logger"
 	"github.com/QuesmaOrg/quesma/platform/parsers/elastic_query_dsl"
@@ -16,6 +17,7 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/schema"
 	"github.com/QuesmaOrg/quesma/platform/table_resolver"
 	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/QuesmaOrg/quesma/platform/util"
 	quesma_api "github.com/QuesmaOrg/quesma/platform/v2/core"
 	"github.com/QuesmaOrg/quesma/platform/v2/core/routes"
 	"github.com/goccy/go-json"
@@ -73,7 +75,7 @@ func ConfigureIngestRouterV2(cfg *config.QuesmaConfiguration, dependencies quesm
 		if err != nil {
 			return nil, err
 		}
-		return HandleBulk(ctx, body, ip, cfg.IngestStatistics, esConn, dependencies, tableResolver)
+		return HandleBulk(ctx, req.QueryParams.Get("pipeline"), body, ip, cfg.IngestStatistics, esConn, dependencies, tableResolver)
 	})
 	router.Register(routes.IndexDocPath, and(method("POST"), matchedExactIngestPath(tableResolver)), func(ctx context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
 		index := req.Params["index"]
@@ -87,7 +89,7 @@ func ConfigureIngestRouterV2(cfg *config.QuesmaConfiguration, dependencies quesm
 			}, nil
 		}
 
-		return HandleIndexDoc(ctx, index, body, ip, cfg.IngestStatistics, esConn, dependencies, tableResolver)
+		return HandleIndexDoc(ctx, index, req.QueryParams.Get("pipeline"), body, ip, cfg.IngestStatistics, esConn, dependencies, tableResolver)
 	})
 
 	router.Register(routes.IndexBulkPath, and(method("POST", "PUT"), matchedExactIngestPath(tableResolver)), func(ctx context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
@@ -98,11 +100,121 @@ func ConfigureIngestRouterV2(cfg *config.QuesmaConfiguration, dependencies quesm
 			return nil, err
 		}
 
-		return HandleBulkIndex(ctx, index, body, ip, cfg.IngestStatistics, esConn, dependencies, tableResolver)
+		return HandleBulkIndex(ctx, index, req.QueryParams.Get("pipeline"), body, ip, cfg.IngestStatistics, esConn, dependencies, tableResolver)
 	})
+
+	if ip != nil {
+		configureIngestPipelineRoutes(router, ip, esConn)
+		configureIndexTemplateRoutes(router, ip, esConn)
+		configureByQueryRoutes(router, cfg, ip, tableResolver)
+	}
 	return router
 }
 
+func configureIngestPipelineRoutes(router *quesma_api.PathRouter, ip *ingest.IngestProcessor, esConn *backend_connectors.ElasticsearchBackendConnector) {
+	method := quesma_api.IsHTTPMethod
+	store := ip.GetPipelineStore()
+
+	// `_simulate` paths have to be registered before `/_ingest/pipeline/:id`, the first matching route wins
+	simulate := func(_ context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+		body, err := types.ExpectJSON(req.ParsedBody)
+		if err != nil {
+			return elasticsearchErrorResult(http.StatusBadRequest, "parse_exception", err.Error()), nil
+		}
+		return HandleSimulateIngestPipeline(store, req.Params["id"], body)
+	}
+	router.Register(routes.IngestPipelineSimulatePath, method("GET", "POST"), simulate)
+	router.Register(routes.IngestPipelineIdSimulatePath, method("GET", "POST"), simulate)
+
+	router.Register(routes.IngestPipelinesPath, method("GET"), func(_ context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+		return HandleGetIngestPipeline(store, "")
+	})
+	router.Register(routes.IngestPipelinePath, method("GET"), func(_ context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+		return HandleGetIngestPipeline(store, req.Params["id"])
+	})
+	router.Register(routes.IngestPipelinePath, method("PUT"), func(ctx context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+		body, err := types.ExpectJSON(req.ParsedBody)
+		if err != nil {
+			return elasticsearchErrorResult(http.StatusBadRequest, "parse_exception", err.Error()), nil
+		}
+		return HandlePutIngestPipeline(ctx, store, esConn, req.Params["id"], body)
+	})
+	router.Register(routes.IngestPipelinePath, method("DELETE"), func(ctx context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+		return HandleDeleteIngestPipeline(ctx, store, esConn, req.Params["id"])
+	})
+}
+
+func configureIndexTemplateRoutes(router *quesma_api.PathRouter, ip *ingest.IngestProcessor, esConn *backend_connectors.ElasticsearchBackendConnector) {
+	method := quesma_api.IsHTTPMethod
+	store := ip.GetIndexTemplateStore()
+
+	router.Register(routes.IndexTemplateSimulateIndexPath, method("POST"), func(_ context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+		return HandleSimulateIndex(store, req.Params["index"])
+	})
+	router.Register(routes.IndexTemplatesPath, method("GET"), func(_ context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+		return HandleGetIndexTemplate(store, "", false)
+	})
+	router.Register(routes.IndexTemplatePath, method("GET", "HEAD"), func(_ context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+		return HandleGetIndexTemplate(store, req.Params["name"], req.Method == "HEAD")
+	})
+	router.Register(routes.IndexTemplatePath, method("PUT", "POST"), func(ctx context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+		body, err := types.ExpectJSON(req.ParsedBody)
+		if err != nil {
+			return elasticsearchErrorResult(http.StatusBadRequest, "parse_exception", err.Error()), nil
+		}
+		return HandlePutIndexTemplate(ctx, store, esConn, req.Params["name"], body)
+	})
+	router.Register(routes.IndexTemplatePath, method("DELETE"), func(ctx context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+		return HandleDeleteIndexTemplate(ctx, store, esConn, req.Params["name"])
+	})
+
+	router.Register(routes.ComponentTemplatesPath, method("GET"), func(_ context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+		return HandleGetComponentTemplate(store, "", false)
+	})
+	router.Register(routes.ComponentTemplatePath, method("GET", "HEAD"), func(_ context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+		return HandleGetComponentTemplate(store, req.Params["name"], req.Method == "HEAD")
+	})
+	router.Register(routes.ComponentTemplatePath, method("PUT", "POST"), func(ctx context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+		body, err := types.ExpectJSON(req.ParsedBody)
+		if err != nil {
+			return elasticsearchErrorResult(http.StatusBadRequest, "parse_exception", err.Error()), nil
+		}
+		return HandlePutComponentTemplate(ctx, store, esConn, req.Params["name"], body)
+	})
+	router.Register(routes.ComponentTemplatePath, method("DELETE"), func(ctx context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+		return HandleDeleteComponentTemplate(ctx, store, esConn, req.Params["name"])
+	})
+}
+
+func configureByQueryRoutes(router *quesma_api.PathRouter, cfg *config.QuesmaConfiguration, ip *ingest.IngestProcessor, tableResolver table_resolver.TableResolver) {
+	method := quesma_api.IsHTTPMethod
+	and := quesma_api.And
+	tasks := by_query.NewTaskRegistry()
+
+	byQuery := func(operation by_query.Operation) quesma_api.HTTPFrontendHandler {
+		return func(ctx context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+			body := types.JSON{} // body is optional, the query can be passed in `q` parameter
+			if strings.TrimSpace(req.Body) != "" {
+				var err error
+				if body, err = types.ExpectJSON(req.ParsedBody); err != nil {
+					return elasticsearchErrorResult(http.StatusBadRequest, "parse_exception", err.Error()), nil
+				}
+			}
+			return HandleByQuery(ctx, cfg, operation, req.Params["index"], req.QueryParams, body, ip, tasks)
+		}
+	}
+	router.Register(routes.IndexDeleteByQueryPath, and(method("POST"), matchedExactIngestPath(tableResolver)), byQuery(by_query.DeleteByQuery))
+	router.Register(routes.IndexUpdateByQueryPath, and(method("POST"), matchedExactIngestPath(tableResolver)), byQuery(by_query.UpdateByQuery))
+
+	// `_cancel` has to be registered before `/_tasks/:id`, the first matching route wins
+	router.Register(routes.TaskCancelPath, and(method("POST"), matchedAgainstQuesmaTaskId()), func(_ context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+		return HandleCancelTask(tasks, req.Params["id"])
+	})
+	router.Register(routes.TaskPath, and(method("GET"), matchedAgainstQuesmaTaskId()), func(_ context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+		return HandleGetTask(tasks, req.Params["id"])
+	})
+}
+
 func ConfigureSearchRouterV2(cfg *config.QuesmaConfiguration, dependencies quesma_api.Dependencies, sr schema.Registry, lm *database_common.LogManager, queryRunner *QueryRunner, tableResolver table_resolver.TableResolver) quesma_api.Router {
 
 	// some syntactic sugar
@@ -182,21 +294,24 @@ func ConfigureSearchRouterV2(cfg *config.QuesmaConfiguration, dependencies quesm
 			return nil, err
 		}
 		waitForResultsMs := 1000 // Defaults to 1 second as in docs
-		if v, ok := req.Params["wait_for_completion_timeout"]; ok {
+		if v := req.QueryParams.Get("wait_for_completion_timeout"); v != "" {
 			if w, err := time.ParseDuration(v); err == nil {
 				waitForResultsMs = int(w.Milliseconds())
 			} else {
 				logger.Warn().Msgf("Can't parse wait_for_completion_timeout value: %s", v)
 			}
 		}
-		keepOnCompletion := false
-		if v, ok := req.Params["keep_on_completion"]; ok {
-			if v == "true" {
-				keepOnCompletion = true
+		keepOnCompletion := req.QueryParams.Get("keep_on_completion") == "true"
+		var keepAlive time.Duration // zero means the configured default
+		if v := req.QueryParams.Get("keep_alive"); v != "" {
+			if k, err := util.ParseInterval(v); err == nil {
+				keepAlive = k
+			} else {
+				logger.Warn().Msgf("Can't parse keep_alive value: %s", v)
 			}
 		}
 
-		return HandleIndexAsyncSearch(ctx, req.Params["index"], query, waitForResultsMs, keepOnCompletion, queryRunner)
+		return HandleIndexAsyncSearch(ctx, req.Params["index"], query, waitForResultsMs, keepOnCompletion, keepAlive, queryRunner)
 	})
 
 	router.Register(routes.IndexMsearchPath, and(method("GET", "POST"), matchedAgainstPattern(tableResolver)), func(ctx context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
@@ -207,6 +322,24 @@ func ConfigureSearchRouterV2(cfg *config.QuesmaConfiguration, dependencies quesm
 		return HandleMultiSearch(ctx, req, "", queryRunner)
 	})
 
+	router.Register(routes.IndexDocIdPath, and(method("GET", "HEAD"), matchedAgainstPattern(tableResolver)), func(ctx context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+		return HandleGetDoc(ctx, req.Params["index"], req.Params["id"], req.QueryParams, req.Method == "HEAD", queryRunner, sr)
+	})
+
+	router.Register(routes.IndexSourceIdPath, and(method("GET", "HEAD"), matchedAgainstPattern(tableResolver)), func(ctx context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+		return HandleGetSource(ctx, req.Params["index"], req.Params["id"], req.QueryParams, req.Method == "HEAD", queryRunner, sr)
+	})
+
+	mget := func(ctx context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
+		body, err := types.ExpectJSON(req.ParsedBody)
+		if err != nil {
+			return nil, err
+		}
+		return HandleMget(ctx, req.Params["index"], req.QueryParams, body, queryRunner, sr)
+	}
+	router.Register(routes.IndexMgetPath, and(method("GET", "POST"), matchedAgainstMgetIndexes(tableResolver)), mget)
+	router.Register(routes.GlobalMgetPath, and(method("GET", "POST"), matchedAgainstMgetIndexes(tableResolver)), mget)
+
 	router.Register(routes.IndexMappingPath, and(method("GET", "PUT"), matchAgainstTableResolver(tableResolver, quesma_api.MetaPipeline)), func(ctx context.Context, req *quesma_api.Request, _ http.ResponseWriter) (*quesma_api.Result, error) {
 		index := req.Params["index"]
 		switch req.Method {
@@ -216,7 +349,7 @@ func ConfigureSearchRouterV2(cfg *config.QuesmaConfiguration, dependencies quesm
 			if body, err := types.ExpectJSON(req.ParsedBody); err != nil {
 				return nil, err
 			} else {
-				return HandlePutIndex(index, body, sr)
+				return HandlePutIndexMapping(index, body, sr)
 			}
 		}
 		return nil, errors.New("unsupported method")
@@ -3,6 +3,7 @@
 package frontend_connectors
 
 import (
+	"fmt"
 	"github.com/QuesmaOrg/quesma/platform/logger"
 	"github.com/QuesmaOrg/quesma/platform/model"
 	"github.com/QuesmaOrg/quesma/platform/schema"
@@ -64,6 +65,8 @@ func parseFunctionWithCombinator(funcName string) (result functionWithCombinator
 
 type arrayTypeResolver struct {
 	indexSchema schema.Schema
+	// arrayJoinedColumns are unnested by ARRAY JOIN, so (outside WHERE) they hold single elements, not arrays
+	arrayJoinedColumns map[string]bool
 }
 
 func (v *arrayTypeResolver) dbColumnType(columName string) string {
@@ -81,9 +84,29 @@ func (v *arrayTypeResolver) dbColumnType(columName string) string {
 		return ""
 	}
 
+	if v.arrayJoinedColumns[columName] {
+		return arrayElementType(field.InternalPropertyType)
+	}
 	return field.InternalPropertyType
 }
 
+// withArrayJoin returns resolver, which treats columns unnested by selectCommand's ARRAY JOIN as single elements.
+func (v *arrayTypeResolver) withArrayJoin(selectCommand model.SelectCommand) arrayTypeResolver {
+	arrayJoinedColumns := make(map[string]bool, len(selectCommand.ArrayJoin))
+	for _, col := range model.GetUsedColumns(model.NewTupleExpr(selectCommand.ArrayJoin...)) {
+		arrayJoinedColumns[strings.TrimSuffix(col.ColumnName, ".keyword")] = true
+	}
+	return arrayTypeResolver{indexSchema: v.indexSchema, arrayJoinedColumns: arrayJoinedColumns}
+}
+
+// arrayElementType returns type of elements of the array type, e.g. Array(Nullable(String)) -> Nullable(String)
+func arrayElementType(dbType string) string {
+	if strings.HasPrefix(dbType, "Array(") && strings.HasSuffix(dbType, ")") {
+		return strings.TrimSuffix(strings.TrimPrefix(dbType, "Array("), ")")
+	}
+	return dbType
+}
+
 func NewArrayTypeVisitor(resolver arrayTypeResolver) (exprVisitor model.ExprVisitor, anyError bool) {
 
 	visitor := model.NewBaseVisitor()
@@ -129,6 +152,11 @@ func NewArrayTypeVisitor(resolver arrayTypeResolver) (exprVisitor model.ExprVisi
 	var childGotArrayFunc bool
 	visitor.OverrideVisitFunction = func(b *model.BaseExprVisitor, e model.FunctionExpr) interface{} {
 
+		if e.Name == model.NestedQueryFunction {
+			// arrays inside are handled as a whole later, in nested query transformation
+			return e
+		}
+
 		if len(e.Args) > 0 {
 			arg := e.Args[0]
 			column, ok := arg.(model.ColumnRef)
@@ -170,12 +198,47 @@ func NewArrayTypeVisitor(resolver arrayTypeResolver) (exprVisitor model.ExprVisi
 		return e
 	}
 
+	visitor.OverrideVisitSelectCommand = func(b *model.BaseExprVisitor, e model.SelectCommand) interface{} {
+		outerResolver := resolver
+		defer func() { resolver = outerResolver }()
+
+		// WHERE (and sampling) is evaluated before ARRAY JOIN, so there array columns are still arrays
+		resolver = outerResolver.withArrayJoin(model.SelectCommand{})
+		var where, sampleLimitBy model.Expr
+		if e.WhereClause != nil {
+			where = e.WhereClause.Accept(b).(model.Expr)
+		}
+		if e.SampleLimitBy != nil {
+			sampleLimitBy = e.SampleLimitBy.Accept(b).(model.Expr)
+		}
+
+		resolver = outerResolver.withArrayJoin(e)
+		var orderBy []model.OrderByExpr
+		for _, expr := range e.OrderBy {
+			orderBy = append(orderBy, expr.Accept(b).(model.OrderByExpr))
+		}
+		var from model.Expr
+		if e.FromClause != nil {
+			from = e.FromClause.Accept(b).(model.Expr)
+		}
+		var namedCTEs []*model.CTE
+		for _, cte := range e.NamedCTEs {
+			namedCTEs = append(namedCTEs, cte.Accept(b).(*model.CTE))
+		}
+
+		result := model.NewSelectCommand(b.VisitChildren(e.Columns), b.VisitChildren(e.GroupBy), orderBy, from, where,
+			b.VisitChildren(e.LimitBy), e.Limit, e.SampleLimit, e.IsDistinct, namedCTEs)
+		result.SampleLimitBy, result.SampleLimitPerValue = sampleLimitBy, e.SampleLimitPerValue
+		result.ArrayJoin = e.ArrayJoin
+		return result
+	}
+
 	return visitor, anyError
 }
 
 func checkIfGroupingByArrayColumn(selectCommand model.SelectCommand, resolver arrayTypeResolver) bool {
 
-	isArrayColumn := func(e model.Expr) bool {
+	isArrayColumn := func(e model.Expr, resolver arrayTypeResolver) bool {
 		columnIsArray := false
 		findArrayColumn := model.NewBaseVisitor()
 
@@ -198,9 +261,11 @@ func checkIfGroupingByArrayColumn(selectCommand model.SelectCommand, resolver ar
 
 	visitor.OverrideVisitSelectCommand = func(b *model.BaseExprVisitor, e model.SelectCommand) interface{} {
 
+		// grouping by column unnested with ARRAY JOIN is grouping by its elements, which is fine
+		resolverAfterArrayJoin := resolver.withArrayJoin(e)
 		for _, expr := range e.GroupBy {
 
-			if isArrayColumn(expr) {
+			if isArrayColumn(expr, resolverAfterArrayJoin) {
 				found = true
 			}
 		}
@@ -239,3 +304,52 @@ func NewArrayJoinVisitor(resolver arrayTypeResolver) model.ExprVisitor {
 
 	return visitor
 }
+
+// NewNestedQueryVisitor translates nested queries, whose condition must hold for a single object of an array of objects.
+// Such arrays are stored as parallel arrays of object's fields, e.g. events[].type -> events_type Array(String), so
+// __quesma_nested(events_type = 'click' AND events_duration > 5) becomes
+// arrayExists((x0, x1) -> x0 = 'click' AND x1 > 5, events_type, events_duration)
+func NewNestedQueryVisitor(resolver arrayTypeResolver) model.ExprVisitor {
+
+	visitor := model.NewBaseVisitor()
+
+	visitor.OverrideVisitFunction = func(b *model.BaseExprVisitor, e model.FunctionExpr) interface{} {
+		if e.Name != model.NestedQueryFunction {
+			return model.NewFunction(e.Name, b.VisitChildren(e.Args)...)
+		}
+		if len(e.Args) != 1 {
+			logger.ErrorWithReason("invalid nested query").Msgf("%s expects 1 argument, got: %v", model.NestedQueryFunction, e.Args)
+			return model.NewLiteral(false)
+		}
+
+		condition := e.Args[0].Accept(b).(model.Expr)
+
+		var arrayColumns []model.Expr
+		lambdaArgs := make(map[string]string)
+		var lambdaArgsOrdered []string
+		toLambdaArgs := model.NewBaseVisitor()
+		toLambdaArgs.OverrideVisitColumnRef = func(_ *model.BaseExprVisitor, col model.ColumnRef) interface{} {
+			if !strings.HasPrefix(resolver.dbColumnType(col.ColumnName), "Array") {
+				return col
+			}
+			arg, ok := lambdaArgs[col.ColumnName]
+			if !ok {
+				arg = fmt.Sprintf("x%d", len(lambdaArgs))
+				lambdaArgs[col.ColumnName] = arg
+				lambdaArgsOrdered = append(lambdaArgsOrdered, arg)
+				arrayColumns = append(arrayColumns, col)
+			}
+			return model.NewLiteral(arg)
+		}
+		condition = condition.Accept(toLambdaArgs).(model.Expr)
+
+		if len(arrayColumns) == 0 {
+			// no arrays, every document is a single "nested" object
+			return condition
+		}
+		args := append([]model.Expr{model.NewLambdaExpr(lambdaArgsOrdered, condition)}, arrayColumns...)
+		return model.NewFunction("arrayExists", args...)
+	}
+
+	return visitor
+}
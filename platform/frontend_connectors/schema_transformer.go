@@ -123,6 +123,54 @@ func (s *SchemaCheckPass) applyBooleanLiteralLowering(index schema.Schema, query
 	return query, nil
 }
 
+// versionSortKey returns the key, by which version values are compared, like Elasticsearch does for `version` fields:
+// numeric parts are compared as numbers (1.10.0 > 1.9.0), and a release is greater than its pre-releases (1.0.0 > 1.0.0-beta)
+func versionSortKey(version model.Expr) model.Expr {
+	const variableName = "x"
+	numbers := model.NewFunction("arrayMap",
+		model.NewLambdaExpr([]string{variableName}, model.NewFunction("toUInt64OrZero", model.NewLiteral(variableName))),
+		model.NewFunction("splitByChar", model.NewLiteral("'.'"), model.NewFunction("extract", version, model.NewLiteral("'^[0-9.]+'"))))
+	preRelease := model.NewFunction("extract", version, model.NewLiteral("'^[^-+]*-([^+]*)'"))
+	return model.NewTupleExpr(numbers, model.NewInfixExpr(preRelease, "=", model.NewLiteral("''")), preRelease)
+}
+
+// applyVersionOrdering makes range comparisons and sorting of `version` fields use versionSortKey instead of comparing strings
+func (s *SchemaCheckPass) applyVersionOrdering(indexSchema schema.Schema, query *model.Query) (*model.Query, error) {
+
+	isVersion := func(e model.Expr) bool {
+		if col, ok := e.(model.ColumnRef); ok {
+			field, found := indexSchema.ResolveFieldByInternalName(col.ColumnName)
+			return found && field.Type.Name == schema.QuesmaTypeVersion.Name
+		}
+		return false
+	}
+
+	visitor := model.NewBaseVisitor()
+
+	visitor.OverrideVisitInfix = func(b *model.BaseExprVisitor, e model.InfixExpr) interface{} {
+		switch e.Op {
+		case "<", "<=", ">", ">=":
+			if isVersion(e.Left) || isVersion(e.Right) {
+				return model.NewInfixExpr(versionSortKey(e.Left), e.Op, versionSortKey(e.Right))
+			}
+		}
+		return visitInfix(b, e)
+	}
+
+	visitor.OverrideVisitOrderByExpr = func(b *model.BaseExprVisitor, e model.OrderByExpr) interface{} {
+		if isVersion(e.Expr) {
+			return model.NewOrderByExpr(versionSortKey(e.Expr), e.Direction)
+		}
+		return model.NewOrderByExpr(e.Expr.Accept(b).(model.Expr), e.Direction)
+	}
+
+	expr := query.SelectCommand.Accept(visitor)
+	if _, ok := expr.(*model.SelectCommand); ok {
+		query.SelectCommand = *expr.(*model.SelectCommand)
+	}
+	return query, nil
+}
+
 // Below function applies schema transformations to the query regarding ip addresses.
 // Internally, it converts sql statement like
 // SELECT * FROM "kibana_sample_data_logs" WHERE lhs op rhs
@@ -258,6 +306,19 @@ func (s *SchemaCheckPass) applyGeoTransformations(schemaInstance schema.Schema,
 			//replace[field.PropertyName.AsString()+".lon"] = model.NewFunction("give_me_lon", model.NewColumnRef(field.InternalPropertyName.AsString()))
 
 		}
+		if field.Type.IsRange() {
+			// ranges are stored the same way, in `_gte` and `_lte` columns, and returned as {"gte": ..., "lte": ...}
+			gte := model.NewColumnRef(field.InternalPropertyName.AsString() + "_" + schema.RangeLowerBound)
+			lte := model.NewColumnRef(field.InternalPropertyName.AsString() + "_" + schema.RangeUpperBound)
+
+			replace[field.InternalPropertyName.AsString()] = model.NewFunction("map",
+				model.NewLiteral("'"+schema.RangeLowerBound+"'"),
+				gte,
+				model.NewLiteral("'"+schema.RangeUpperBound+"'"),
+				lte)
+			replace[field.InternalPropertyName.AsString()+"."+schema.RangeLowerBound] = gte
+			replace[field.InternalPropertyName.AsString()+"."+schema.RangeUpperBound] = lte
+		}
 	}
 
 	visitor := model.NewBaseVisitor()
@@ -277,6 +338,10 @@ func (s *SchemaCheckPass) applyGeoTransformations(schemaInstance schema.Schema,
 			suffix = ".lat"
 		case model.QuesmaGeoLonFunction:
 			suffix = ".lon"
+		case model.QuesmaRangeGteFunction:
+			suffix = "." + schema.RangeLowerBound
+		case model.QuesmaRangeLteFunction:
+			suffix = "." + schema.RangeUpperBound
 		}
 
 		if suffix != "" && len(e.Args) == 1 {
@@ -338,7 +403,15 @@ func (s *SchemaCheckPass) applyGeoTransformations(schemaInstance schema.Schema,
 				limitBy = append(limitBy, expr.Accept(v).(model.Expr))
 			}
 		}
-		return model.NewSelectCommand(columns, groupBy, orderBy, from, where, limitBy, query.Limit, query.SampleLimit, query.IsDistinct, namedCTEs)
+		result := model.NewSelectCommand(columns, groupBy, orderBy, from, where, limitBy, query.Limit, query.SampleLimit, query.IsDistinct, namedCTEs)
+		if query.SampleLimitBy != nil {
+			result.SampleLimitBy = query.SampleLimitBy.Accept(v).(model.Expr)
+			result.SampleLimitPerValue = query.SampleLimitPerValue
+		}
+		if query.ArrayJoin != nil {
+			result.ArrayJoin = v.VisitChildren(query.ArrayJoin)
+		}
+		return result
 	}
 
 	expr := query.SelectCommand.Accept(visitor)
@@ -391,6 +464,14 @@ func (s *SchemaCheckPass) applyArrayTransformations(indexSchema schema.Schema, q
 	return query, nil
 }
 
+func (s *SchemaCheckPass) applyNestedQueryTransformations(indexSchema schema.Schema, query *model.Query) (*model.Query, error) {
+	expr := query.SelectCommand.Accept(NewNestedQueryVisitor(arrayTypeResolver{indexSchema: indexSchema}))
+	if _, ok := expr.(*model.SelectCommand); ok {
+		query.SelectCommand = *expr.(*model.SelectCommand)
+	}
+	return query, nil
+}
+
 func (s *SchemaCheckPass) applyMapTransformations(indexSchema schema.Schema, query *model.Query) (*model.Query, error) {
 
 	mapResolver := mapTypeResolver{indexSchema: indexSchema}
@@ -567,7 +648,10 @@ func (s *SchemaCheckPass) applyPhysicalFromExpression(currentSchema schema.Schem
 			}
 		}
 
-		return model.NewSelectCommand(columns, groupBy, orderBy, from, where, selectStm.LimitBy, selectStm.Limit, selectStm.SampleLimit, selectStm.IsDistinct, namedCTEs)
+		result := model.NewSelectCommand(columns, groupBy, orderBy, from, where, selectStm.LimitBy, selectStm.Limit, selectStm.SampleLimit, selectStm.IsDistinct, namedCTEs)
+		result.SampleLimitBy, result.SampleLimitPerValue = selectStm.SampleLimitBy, selectStm.SampleLimitPerValue
+		result.ArrayJoin = b.VisitChildren(selectStm.ArrayJoin)
+		return result
 	}
 
 	expr := query.SelectCommand.Accept(visitor)
@@ -620,6 +704,15 @@ func (s *SchemaCheckPass) applyWildcardExpansion(indexSchema schema.Schema, quer
 		if len(query.Indexes) > 1 {
 			newColumns = append(newColumns, model.NewColumnRef(common_table.IndexNameColumn))
 		}
+
+		// ids supplied at ingest aren't a part of the schema, but hits need them
+		if s.tableDiscovery != nil {
+			if table, ok := s.tableDiscovery.TableDefinitions().Load(query.TableName); ok {
+				if _, hasDocumentIds := table.Cols[common_table.DocumentIdColumn]; hasDocumentIds {
+					newColumns = append(newColumns, model.NewColumnRef(common_table.DocumentIdColumn))
+				}
+			}
+		}
 	}
 
 	if len(newColumns) == 0 {
@@ -687,6 +780,28 @@ func (s *SchemaCheckPass) applyFullTextField(indexSchema schema.Schema, query *m
 		return visitInfix(b, e)
 	}
 
+	visitor.OverrideVisitFunction = func(b *model.BaseExprVisitor, e model.FunctionExpr) interface{} {
+		// relevance score of a full-text search is the sum of scores over all full-text fields
+		if e.Name == model.MatchScoreFunction && len(e.Args) == 2 {
+			if col, ok := e.Args[0].(model.ColumnRef); ok && col.ColumnName == model.FullTextFieldNamePlaceHolder {
+				if len(fullTextFields) == 0 {
+					return model.NewLiteral(0)
+				}
+				var sum model.Expr
+				for _, field := range fullTextFields {
+					score := model.NewFunction(e.Name, model.NewColumnRefWithTable(field, col.TableAlias), e.Args[1])
+					if sum == nil {
+						sum = score
+					} else {
+						sum = model.NewInfixExpr(sum, "+", score)
+					}
+				}
+				return model.NewParenExpr(sum)
+			}
+		}
+		return model.NewFunction(e.Name, b.VisitChildren(e.Args)...)
+	}
+
 	expr := query.SelectCommand.Accept(visitor)
 
 	if err != nil {
@@ -782,7 +897,7 @@ func (s *SchemaCheckPass) applyFieldEncoding(indexSchema schema.Schema, query *m
 	visitor.OverrideVisitColumnRef = func(b *model.BaseExprVisitor, e model.ColumnRef) interface{} {
 
 		// we don't want to resolve our well know technical fields
-		if e.ColumnName == model.FullTextFieldNamePlaceHolder || e.ColumnName == common_table.IndexNameColumn {
+		if e.ColumnName == model.FullTextFieldNamePlaceHolder || e.ColumnName == common_table.IndexNameColumn || e.ColumnName == common_table.DocumentIdColumn {
 			return e
 		}
 
@@ -795,6 +910,9 @@ func (s *SchemaCheckPass) applyFieldEncoding(indexSchema schema.Schema, query *m
 
 		if resolvedField, ok := indexSchema.ResolveField(e.ColumnName); ok {
 			return model.NewColumnRefWithTable(resolvedField.InternalPropertyName.AsString(), e.TableAlias)
+		} else if flattenedField, key, ok := indexSchema.ResolveFlattenedSubfield(e.ColumnName); ok {
+			// subfields of flattened fields are keys of their maps
+			return model.NewFunction("arrayElement", model.NewColumnRefWithTable(flattenedField.InternalPropertyName.AsString(), e.TableAlias), model.NewLiteral(fmt.Sprintf("'%s'", key)))
 		} else {
 			// here we didn't find a column by field name,
 			// maybe we should use attributes
@@ -855,7 +973,15 @@ func (s *SchemaCheckPass) applyFieldEncoding(indexSchema schema.Schema, query *m
 				limitBy = append(limitBy, expr.Accept(v).(model.Expr))
 			}
 		}
-		return model.NewSelectCommand(columns, groupBy, orderBy, from, where, limitBy, query.Limit, query.SampleLimit, query.IsDistinct, namedCTEs)
+		result := model.NewSelectCommand(columns, groupBy, orderBy, from, where, limitBy, query.Limit, query.SampleLimit, query.IsDistinct, namedCTEs)
+		if query.SampleLimitBy != nil {
+			result.SampleLimitBy = query.SampleLimitBy.Accept(v).(model.Expr)
+			result.SampleLimitPerValue = query.SampleLimitPerValue
+		}
+		if query.ArrayJoin != nil {
+			result.ArrayJoin = v.VisitChildren(query.ArrayJoin)
+		}
+		return result
 	}
 
 	expr := query.SelectCommand.Accept(visitor)
@@ -1177,9 +1303,12 @@ func (s *SchemaCheckPass) makeTransformations(backendConnectorType quesma_api.Ba
 		[]TransformationsChain{
 			{TransformationName: "IpTransformation", Transformation: s.applyIpTransformations},
 			{TransformationName: "GeoTransformation", Transformation: s.applyGeoTransformations},
+			{TransformationName: "VersionOrderingTransformation", Transformation: s.applyVersionOrdering},
 			{TransformationName: "ArrayTransformation", Transformation: s.applyArrayTransformations},
 			{TransformationName: "MapTransformation", Transformation: s.applyMapTransformations},
 			{TransformationName: "MatchOperatorTransformation", Transformation: s.applyMatchOperator},
+			{TransformationName: "NestedQueryTransformation", Transformation: s.applyNestedQueryTransformations},
+			{TransformationName: "MatchScoreTransformation", Transformation: s.applyMatchScore},
 			{TransformationName: "AggOverUnsupportedType", Transformation: s.checkAggOverUnsupportedType},
 			{TransformationName: "ApplySelectFromCluster", Transformation: s.ApplySelectFromCluster},
 			{TransformationName: "BooleanLiteralTransformation", Transformation: s.applyBooleanLiteralLowering},
@@ -1314,14 +1443,22 @@ func (s *SchemaCheckPass) applyMatchOperator(indexSchema schema.Schema, query *m
 			// handling case when e.Left is a simple column ref
 			// TODO: improve? we seem to be `ilike'ing` too much
 			switch field.Type.String() {
-			case schema.QuesmaTypeInteger.Name, schema.QuesmaTypeLong.Name, schema.QuesmaTypeUnsignedLong.Name, schema.QuesmaTypeFloat.Name, schema.QuesmaTypeBoolean.Name:
+			case schema.QuesmaTypeInteger.Name, schema.QuesmaTypeLong.Name, schema.QuesmaTypeUnsignedLong.Name, schema.QuesmaTypeFloat.Name, schema.QuesmaTypeBoolean.Name, schema.QuesmaTypeScaledFloat.Name:
 				rhs.Value = strings.Trim(rhsValue, "%")
 				rhs.Attrs[model.EscapeKey] = model.NormalNotEscaped
 				return equal()
-			case schema.QuesmaTypeKeyword.Name: // similar as above, but for keyword type, un-ilike it
+			case schema.QuesmaTypeKeyword.Name, schema.QuesmaTypeWildcard.Name, schema.QuesmaTypeVersion.Name, schema.QuesmaTypeConstantKeyword.Name: // similar as above, but for keyword type, un-ilike it
 				rhs.Value = strings.Trim(rhsValue, "%")
 				rhs.Attrs[model.EscapeKey] = model.FullyEscaped
 				return equal()
+			case schema.QuesmaTypeFlattened.Name: // values of flattened fields are keywords too
+				rhs.Value = strings.Trim(rhsValue, "%")
+				rhs.Attrs[model.EscapeKey] = model.FullyEscaped
+				if _, isWholeField := lhs.(model.ColumnRef); isWholeField {
+					// the whole flattened field matches, if any of its leaves does
+					return model.NewFunction("has", model.NewFunction("mapValues", lhs), rhs.Clone())
+				}
+				return equal()
 			default:
 				if rhsValue == "%%" { // ILIKE '%%' has terrible performance, but semantically means "is not null", hence this transformation
 					return model.NewInfixExpr(lhs, "IS", model.NewLiteral("NOT NULL"))
@@ -1391,3 +1528,45 @@ func (s *SchemaCheckPass) applyMatchOperator(indexSchema schema.Schema, query *m
 	return query, nil
 
 }
+
+// matchScoreK1 is BM25's term frequency saturation parameter
+const matchScoreK1 = 1.2
+
+// applyMatchScore lowers relevance score of a single term, `__quesma_match_score(field, 'term')` (see elastic_query_dsl/relevance_scoring.go):
+//   - for full-text fields into BM25-like saturated term frequency: tf * (k1 + 1) / (tf + k1), where tf is the number of
+//     case-insensitive occurrences of the term in the field. There's no IDF and no length normalization (b = 0),
+//     as both would require corpus statistics,
+//   - for other fields into 1 if field is equal to the term, 0 otherwise.
+func (s *SchemaCheckPass) applyMatchScore(indexSchema schema.Schema, query *model.Query) (*model.Query, error) {
+
+	visitor := model.NewBaseVisitor()
+
+	visitor.OverrideVisitFunction = func(b *model.BaseExprVisitor, e model.FunctionExpr) interface{} {
+		if e.Name != model.MatchScoreFunction {
+			return model.NewFunction(e.Name, b.VisitChildren(e.Args)...)
+		}
+		if len(e.Args) != 2 {
+			logger.Error().Msgf("%s expects 2 arguments, got: %v", model.MatchScoreFunction, e.Args)
+			return model.NewLiteral(0)
+		}
+
+		field, term := e.Args[0].Accept(b).(model.Expr), e.Args[1]
+		if col, ok := field.(model.ColumnRef); ok {
+			if schemaField, found := indexSchema.ResolveFieldByInternalName(col.ColumnName); found && !schemaField.Type.IsFullText() {
+				return model.NewFunction("if", model.NewInfixExpr(model.NewFunction("toString", field), "=", term), model.NewLiteral(1), model.NewLiteral(0))
+			}
+		}
+
+		tf := model.NewFunction("coalesce", model.NewFunction("countSubstringsCaseInsensitiveUTF8", field, term), model.NewLiteral(0))
+		numerator := model.NewInfixExpr(tf, "*", model.NewLiteral(matchScoreK1+1))
+		denominator := model.NewParenExpr(model.NewInfixExpr(tf, "+", model.NewLiteral(matchScoreK1)))
+		return model.NewParenExpr(model.NewInfixExpr(numerator, "/", denominator))
+	}
+
+	expr := query.SelectCommand.Accept(visitor)
+
+	if _, ok := expr.(*model.SelectCommand); ok {
+		query.SelectCommand = *expr.(*model.SelectCommand)
+	}
+	return query, nil
+}
@@ -743,6 +743,66 @@ func Test_arrayType(t *testing.T) {
 				},
 			},
 		},
+
+		{
+			name: "nested query",
+			query: &model.Query{
+				TableName: "kibana_sample_data_ecommerce",
+				SelectCommand: model.SelectCommand{
+					FromClause: model.NewTableRef("kibana_sample_data_ecommerce"),
+					Columns:    []model.Expr{model.NewColumnRef("order_date")},
+					WhereClause: model.NewFunction(model.NestedQueryFunction, model.And([]model.Expr{
+						model.NewInfixExpr(model.NewColumnRef("products.sku"), "=", model.NewLiteral("'XYZ'")),
+						model.NewInfixExpr(model.NewColumnRef("products.quantity"), ">", model.NewLiteral(2)),
+					})),
+				},
+			},
+			expected: &model.Query{
+				TableName: "kibana_sample_data_ecommerce",
+				SelectCommand: model.SelectCommand{
+					FromClause: model.NewTableRef("kibana_sample_data_ecommerce"),
+					Columns:    []model.Expr{model.NewColumnRef("order_date")},
+					WhereClause: model.NewFunction("arrayExists",
+						model.NewLambdaExpr([]string{"x0", "x1"}, model.And([]model.Expr{
+							model.NewInfixExpr(model.NewLiteral("x0"), "=", model.NewLiteral("'XYZ'")),
+							model.NewInfixExpr(model.NewLiteral("x1"), ">", model.NewLiteral(2)),
+						})),
+						model.NewColumnRef("products_sku"),
+						model.NewColumnRef("products_quantity"),
+					),
+				},
+			},
+		},
+
+		{
+			name: "array join",
+			query: &model.Query{
+				TableName: "kibana_sample_data_ecommerce",
+				SelectCommand: model.SelectCommand{
+					FromClause: model.NewTableRef("kibana_sample_data_ecommerce"),
+					Columns: []model.Expr{
+						model.NewColumnRef("products.name"),
+						model.NewFunction("sumOrNull", model.NewColumnRef("products.quantity")),
+					},
+					WhereClause: model.NewInfixExpr(model.NewColumnRef("products.sku"), "=", model.NewLiteral("'XYZ'")),
+					GroupBy:     []model.Expr{model.NewColumnRef("products.name")},
+					ArrayJoin:   []model.Expr{model.NewColumnRef("products.name"), model.NewColumnRef("products.quantity"), model.NewColumnRef("products.sku")},
+				},
+			},
+			expected: &model.Query{
+				TableName: "kibana_sample_data_ecommerce",
+				SelectCommand: model.SelectCommand{
+					FromClause: model.NewTableRef("kibana_sample_data_ecommerce"),
+					Columns: []model.Expr{
+						model.NewColumnRef("products_name"),
+						model.NewAliasedExpr(model.NewFunction("sumOrNull", model.NewColumnRef("products_quantity")), "column_1"),
+					},
+					WhereClause: model.NewFunction("has", model.NewColumnRef("products_sku"), model.NewLiteral("'XYZ'")),
+					GroupBy:     []model.Expr{model.NewColumnRef("products_name")},
+					ArrayJoin:   []model.Expr{model.NewColumnRef("products_name"), model.NewColumnRef("products_quantity"), model.NewColumnRef("products_sku")},
+				},
+			},
+		},
 	}
 
 	asString := func(query *model.Query) string {
@@ -978,6 +1038,33 @@ func TestApplyPhysicalFromExpression(t *testing.T) {
 			},
 		},
 
+		{
+			name:    "scalar subquery with common table",
+			indexes: []string{"test2"},
+			input: model.SelectCommand{
+				FromClause: model.NewTableRef(model.SingleTableNamePlaceHolder),
+				Columns: []model.Expr{
+					model.NewParenExpr(model.SelectCommand{
+						FromClause: model.NewTableRef(model.SingleTableNamePlaceHolder),
+						Columns:    []model.Expr{model.NewCountFunc()},
+					}),
+					model.NewCountFunc(),
+				},
+			},
+			expected: model.SelectCommand{
+				FromClause: model.NewTableRef(common_table.TableName),
+				Columns: []model.Expr{
+					model.NewParenExpr(model.SelectCommand{
+						FromClause:  model.NewTableRef(common_table.TableName),
+						Columns:     []model.Expr{model.NewCountFunc()},
+						WhereClause: model.NewInfixExpr(model.NewColumnRef(common_table.IndexNameColumn), "=", model.NewLiteral("'test2'")),
+					}),
+					model.NewCountFunc(),
+				},
+				WhereClause: model.NewInfixExpr(model.NewColumnRef(common_table.IndexNameColumn), "=", model.NewLiteral("'test2'")),
+			},
+		},
+
 		{
 			name: "cte with fixed table name",
 			input: model.SelectCommand{
@@ -1513,6 +1600,70 @@ func Test_applyMatchOperator(t *testing.T) {
 	}
 }
 
+func Test_applyMatchScore(t *testing.T) {
+	schemaTable := schema.Table{
+		Columns: map[string]schema.Column{
+			"message": {Name: "message", Type: "String"},
+			"level":   {Name: "level", Type: "LowCardinality(String)"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		score       model.Expr
+		expectedSql string
+	}{
+		{
+			name:        "full-text field",
+			score:       model.NewFunction(model.MatchScoreFunction, model.NewColumnRef("message"), model.NewLiteral("'error'")),
+			expectedSql: `SELECT "message", (coalesce(countSubstringsCaseInsensitiveUTF8("message",'error'),0)*2.2/(coalesce(countSubstringsCaseInsensitiveUTF8("message",'error'),0)+1.2)) AS "__quesma_score" FROM test`,
+		},
+		{
+			name:        "keyword field",
+			score:       model.NewFunction(model.MatchScoreFunction, model.NewColumnRef("level"), model.NewLiteral("'error'")),
+			expectedSql: `SELECT "message", if(toString("level")='error',1,0) AS "__quesma_score" FROM test`,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(util.PrettyTestName(tt.name, i), func(t *testing.T) {
+			tableDiscovery :=
+				fixedTableProvider{tables: map[string]schema.Table{
+					"test": schemaTable,
+				}}
+
+			cfg := config.QuesmaConfiguration{
+				IndexConfig: map[string]config.IndexConfiguration{"test": {EnableRelevanceScoring: true}},
+			}
+
+			s := schema.NewSchemaRegistry(tableDiscovery, &cfg, clickhouse.ClickhouseSchemaTypeAdapter{})
+			s.Start()
+			defer s.Stop()
+
+			transform := NewSchemaCheckPass(&cfg, nil, defaultSearchAfterStrategy)
+
+			indexSchema, ok := s.FindSchema("test")
+			if !ok {
+				t.Fatal("schema not found")
+			}
+
+			query := &model.Query{
+				TableName: "test",
+				SelectCommand: model.SelectCommand{
+					FromClause: model.NewTableRef("test"),
+					Columns:    []model.Expr{model.NewColumnRef("message"), model.NewAliasedExpr(tt.score, model.ScoreColumnName)},
+				},
+			}
+			actual, err := transform.applyMatchScore(indexSchema, query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.expectedSql, model.AsString(actual.SelectCommand))
+		})
+	}
+}
+
 func Test_checkAggOverUnsupportedType(t *testing.T) {
 	schemaTable := schema.Table{
 		Columns: map[string]schema.Column{
@@ -2250,3 +2401,97 @@ func TestApplySelectFromCluster(t *testing.T) {
 		})
 	}
 }
+
+func Test_specializedFieldTypes(t *testing.T) {
+	indexSchema := schema.Schema{Fields: map[schema.FieldName]schema.Field{
+		"message": {PropertyName: "message", InternalPropertyName: "message", InternalPropertyType: "String", Type: schema.QuesmaTypeText},
+		"release": {PropertyName: "release", InternalPropertyName: "release", InternalPropertyType: "String", Type: schema.QuesmaTypeVersion},
+		"labels":  {PropertyName: "labels", InternalPropertyName: "labels", InternalPropertyType: "Map(String, String)", Type: schema.QuesmaTypeFlattened},
+		"ages":    {PropertyName: "ages", InternalPropertyName: "ages", Type: schema.QuesmaTypeIntegerRange},
+	}}
+	const versionKey = `tuple(arrayMap((x) -> toUInt64OrZero(x),splitByChar('.',extract(%s,'^[0-9.]+'))), extract(%s,'^[^-+]*-([^+]*)')='', extract(%s,'^[^-+]*-([^+]*)'))`
+	versionKeyOf := func(expr string) string { return fmt.Sprintf(versionKey, expr, expr, expr) }
+
+	tests := []struct {
+		name           string
+		transformation func(*SchemaCheckPass) func(schema.Schema, *model.Query) (*model.Query, error)
+		columns        []model.Expr
+		where          model.Expr
+		orderBy        []model.OrderByExpr
+		expectedSql    string
+	}{
+		{
+			name: "versions are compared and sorted by their numeric parts",
+			transformation: func(s *SchemaCheckPass) func(schema.Schema, *model.Query) (*model.Query, error) {
+				return s.applyVersionOrdering
+			},
+			columns: []model.Expr{model.NewColumnRef("release")},
+			where: model.And([]model.Expr{
+				model.NewInfixExpr(model.NewColumnRef("release"), ">=", model.NewLiteral("'1.10.0'")),
+				model.NewInfixExpr(model.NewColumnRef("release"), "=", model.NewLiteral("'2.0.0'")),
+			}),
+			orderBy: []model.OrderByExpr{model.NewOrderByExpr(model.NewColumnRef("release"), model.DescOrder)},
+			expectedSql: `SELECT "release" FROM test WHERE (` + versionKeyOf(`"release"`) + `>=` + versionKeyOf(`'1.10.0'`) + ` AND "release"='2.0.0') ` +
+				`ORDER BY ` + versionKeyOf(`"release"`) + ` DESC`,
+		},
+		{
+			name: "range fields are read from their bound columns",
+			transformation: func(s *SchemaCheckPass) func(schema.Schema, *model.Query) (*model.Query, error) {
+				return s.applyGeoTransformations
+			},
+			columns:     []model.Expr{model.NewColumnRef("ages")},
+			where:       model.NewInfixExpr(model.NewRangeGte("ages"), "<=", model.NewLiteral("10")),
+			expectedSql: `SELECT map('gte',"ages_gte",'lte',"ages_lte") AS "ages" FROM test WHERE "ages_gte"<=10`,
+		},
+		{
+			name: "match of a subfield of a flattened field",
+			transformation: func(s *SchemaCheckPass) func(schema.Schema, *model.Query) (*model.Query, error) {
+				return s.applyMatchOperator
+			},
+			columns:     []model.Expr{model.NewColumnRef("message")},
+			where:       model.NewInfixExpr(model.NewFunction("arrayElement", model.NewColumnRef("labels"), model.NewLiteral("'env'")), model.MatchOperator, model.NewLiteral("'prod'")),
+			expectedSql: `SELECT "message" FROM test WHERE arrayElement("labels",'env')='prod'`,
+		},
+		{
+			name: "match of a whole flattened field",
+			transformation: func(s *SchemaCheckPass) func(schema.Schema, *model.Query) (*model.Query, error) {
+				return s.applyMatchOperator
+			},
+			columns:     []model.Expr{model.NewColumnRef("message")},
+			where:       model.NewInfixExpr(model.NewColumnRef("labels"), model.MatchOperator, model.NewLiteral("'prod'")),
+			expectedSql: `SELECT "message" FROM test WHERE has(mapValues("labels"),'prod')`,
+		},
+		{
+			name: "match of a version field",
+			transformation: func(s *SchemaCheckPass) func(schema.Schema, *model.Query) (*model.Query, error) {
+				return s.applyMatchOperator
+			},
+			columns:     []model.Expr{model.NewColumnRef("message")},
+			where:       model.NewInfixExpr(model.NewColumnRef("release"), model.MatchOperator, model.NewLiteral("'1.0.0'")),
+			expectedSql: `SELECT "message" FROM test WHERE "release"='1.0.0'`,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(util.PrettyTestName(tt.name, i), func(t *testing.T) {
+			cfg := config.QuesmaConfiguration{IndexConfig: map[string]config.IndexConfiguration{"test": {}}}
+			transform := NewSchemaCheckPass(&cfg, nil, defaultSearchAfterStrategy)
+
+			query := &model.Query{
+				TableName: "test",
+				SelectCommand: model.SelectCommand{
+					FromClause:  model.NewTableRef("test"),
+					Columns:     tt.columns,
+					WhereClause: tt.where,
+					OrderBy:     tt.orderBy,
+				},
+			}
+			actual, err := tt.transformation(transform)(indexSchema, query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.expectedSql, model.AsString(actual.SelectCommand))
+		})
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/elasticsearch"
 	"github.com/QuesmaOrg/quesma/platform/end_user_errors"
 	"github.com/QuesmaOrg/quesma/platform/errors"
+	"github.com/QuesmaOrg/quesma/platform/ingest"
 	"github.com/QuesmaOrg/quesma/platform/logger"
 	"github.com/QuesmaOrg/quesma/platform/model"
 	"github.com/QuesmaOrg/quesma/platform/optimize"
@@ -62,13 +63,15 @@ type QueryRunner struct {
 	tableResolver            table_resolver.TableResolver
 
 	maxParallelQueries int // if set to 0, we run queries in sequence, it's fine for testing purposes
+
+	ingestProcessor *ingest.IngestProcessor // optional, to flush the ingest buffer before document APIs
 }
 
 // QueryRunnerIFace is a temporary interface to bridge gap between QueryRunner and QueryRunner2 in `router_v2.go`.
 // moving forwards as we remove two implementations we might look at making all these methods private again.
 type QueryRunnerIFace interface {
 	HandleSearch(ctx context.Context, indexPattern string, body types.JSON) ([]byte, error)
-	HandleAsyncSearch(ctx context.Context, indexPattern string, body types.JSON, waitForResultsMs int, keepOnCompletion bool) ([]byte, error)
+	HandleAsyncSearch(ctx context.Context, indexPattern string, body types.JSON, waitForResultsMs int, keepOnCompletion bool, keepAlive time.Duration) ([]byte, error)
 	HandleAsyncSearchStatus(_ context.Context, id string) ([]byte, error)
 	HandleCount(ctx context.Context, indexPattern string) (int64, error)
 	HandleTermsEnum(ctx context.Context, indexPattern string, body types.JSON, something bool) ([]byte, error)
@@ -80,6 +83,19 @@ type QueryRunnerIFace interface {
 	HandleMultiSearch(ctx context.Context, defaultIndexName string, body types.NDJSON) ([]byte, error)
 }
 
+// SetIngestProcessor lets document APIs (get, `_source`, `_mget`) see documents still waiting in the ingest buffer
+func (q *QueryRunner) SetIngestProcessor(ip *ingest.IngestProcessor) {
+	q.ingestProcessor = ip
+}
+
+// FlushIngestBuffer inserts documents of the index waiting in the ingest buffer, see ingest.IngestProcessor.FlushIngestBuffer
+func (q *QueryRunner) FlushIngestBuffer(ctx context.Context, index string) error {
+	if q.ingestProcessor == nil {
+		return nil
+	}
+	return q.ingestProcessor.FlushIngestBuffer(ctx, index)
+}
+
 func (q *QueryRunner) EnableQueryOptimization(cfg *config.QuesmaConfiguration) {
 	q.transformationPipeline.AddTransformer(optimize.NewOptimizePipeline(cfg))
 }
@@ -95,10 +111,11 @@ func NewQueryRunner(lm database_common.LogManagerIFace,
 	ctx, cancel := context.WithCancel(context.Background())
 	transformationPipeline := model.NewTransformationPipeline()
 	transformationPipeline.AddTransformer(NewSchemaCheckPass(cfg, tableDiscovery, defaultSearchAfterStrategy))
+	asyncRequestStorage, asyncQueriesContexts := newAsyncSearchStorage(cfg)
 	return &QueryRunner{logManager: lm, cfg: cfg, debugInfoCollector: qmc,
 		executionCtx: ctx, cancel: cancel,
-		AsyncRequestStorage:    async_search_storage.NewAsyncSearchStorageInMemory(),
-		AsyncQueriesContexts:   async_search_storage.NewAsyncQueryContextStorageInMemory(),
+		AsyncRequestStorage:    asyncRequestStorage,
+		AsyncQueriesContexts:   asyncQueriesContexts,
 		transformationPipeline: *transformationPipeline,
 		schemaRegistry:         schemaRegistry,
 		ABResultsSender:        abResultsRepository,
@@ -108,6 +125,18 @@ func NewQueryRunner(lm database_common.LogManagerIFace,
 	}
 }
 
+func newAsyncSearchStorage(cfg *config.QuesmaConfiguration) (async_search_storage.AsyncRequestResultStorage, async_search_storage.AsyncQueryContextStorage) {
+	if cfg == nil || cfg.AsyncSearch == nil || cfg.AsyncSearch.Storage != config.AsyncSearchStorageElasticsearch {
+		return async_search_storage.NewAsyncSearchStorageInMemory(), async_search_storage.NewAsyncQueryContextStorageInMemory()
+	}
+	indexName := cfg.AsyncSearch.IndexName
+	if indexName == "" {
+		indexName = async_search_storage.DefaultElasticIndexName
+	}
+	return async_search_storage.NewAsyncSearchStorageInElastic(cfg.Elasticsearch, indexName),
+		async_search_storage.NewAsyncQueryContextStorageInElastic(cfg.Elasticsearch, indexName)
+}
+
 func (q *QueryRunner) GetSchemaRegistry() schema.Registry {
 	return q.schemaRegistry
 }
@@ -332,12 +361,16 @@ func (q *QueryRunner) HandleSearch(ctx context.Context, indexPattern string, bod
 }
 
 func (q *QueryRunner) HandleAsyncSearch(ctx context.Context, indexPattern string, body types.JSON,
-	waitForResultsMs int, keepOnCompletion bool) ([]byte, error) {
+	waitForResultsMs int, keepOnCompletion bool, keepAlive time.Duration) ([]byte, error) {
+	if keepAlive <= 0 && q.cfg != nil && q.cfg.AsyncSearch != nil {
+		keepAlive = q.cfg.AsyncSearch.DefaultKeepAlive
+	}
 	// AsyncQuery marker should be result of ParseQuery
 	async := AsyncQuery{
 		asyncId:          tracing.GetAsyncId(),
 		waitForResultsMs: waitForResultsMs,
 		keepOnCompletion: keepOnCompletion,
+		keepAlive:        keepAlive,
 		startTime:        time.Now(),
 	}
 	ctx = context.WithValue(ctx, tracing.AsyncIdCtxKey, async.asyncId)
@@ -359,6 +392,7 @@ type AsyncQuery struct {
 	asyncId          string
 	waitForResultsMs int
 	keepOnCompletion bool
+	keepAlive        time.Duration // zero means the default keep alive
 	startTime        time.Time
 }
 
@@ -445,13 +479,13 @@ func (q *QueryRunner) executePlan(ctx context.Context, plan *model.ExecutionPlan
 			go func() { // Async search takes longer. Return partial results and wait for
 				defer recovery.LogPanicWithCtx(ctx)
 				res := <-doneCh
-				responseBody, err = q.storeAsyncSearch(q.debugInfoCollector, id, optAsync.asyncId, optAsync.startTime, path, body, res, true, opaqueId)
+				responseBody, err = q.storeAsyncSearch(q.debugInfoCollector, id, optAsync.asyncId, optAsync.startTime, path, body, res, true, optAsync.keepAlive, opaqueId)
 				sendMainPlanResult(responseBody, err)
 			}()
 			return q.HandlePartialAsyncSearch(ctx, optAsync.asyncId)
 		case res := <-doneCh:
 			responseBody, err = q.storeAsyncSearch(q.debugInfoCollector, id, optAsync.asyncId, optAsync.startTime, path, body, res,
-				optAsync.keepOnCompletion, opaqueId)
+				optAsync.keepOnCompletion, optAsync.keepAlive, opaqueId)
 			sendMainPlanResult(responseBody, err)
 			return responseBody, err
 		}
@@ -498,7 +532,7 @@ func (q *QueryRunner) handleSearchCommon(ctx context.Context, indexPattern strin
 		goto logErrorAndReturn
 	}
 
-	queryTranslator = NewQueryTranslator(ctx, currentSchema, table, q.logManager, q.DateMathRenderer, resolvedIndexes)
+	queryTranslator = NewQueryTranslator(ctx, currentSchema, table, q.logManager, q.DateMathRenderer, resolvedIndexes, q.cfg.IsRelevanceScoringEnabled(resolvedIndexes))
 
 	plan, err = queryTranslator.ParseQuery(body)
 
@@ -512,7 +546,10 @@ func (q *QueryRunner) handleSearchCommon(ctx context.Context, indexPattern strin
 			queriesBodyConcat += query.SelectCommand.String() + "\n"
 		}
 		resp = []byte(fmt.Sprintf("Invalid Queries: %v, err: %v", queriesBody, err))
-		err = errors.New(string(resp))
+		var endUserError *end_user_errors.EndUserError
+		if !errors.As(err, &endUserError) { // keep end user errors, so that the user gets a meaningful response
+			err = errors.New(string(resp))
+		}
 		logger.ErrorWithCtxAndReason(ctx, "Quesma generated invalid SQL query").Msg(queriesBodyConcat)
 		goto logErrorAndReturn
 	}
@@ -543,7 +580,7 @@ logErrorAndReturn:
 }
 
 func (q *QueryRunner) storeAsyncSearch(qmc diag.DebugInfoCollector, id, asyncId string,
-	startTime time.Time, path string, body types.JSON, result asyncSearchWithError, keep bool, opaqueId string) (responseBody []byte, err error) {
+	startTime time.Time, path string, body types.JSON, result asyncSearchWithError, keep bool, keepAlive time.Duration, opaqueId string) (responseBody []byte, err error) {
 
 	if result.err == nil {
 		okStatus := 200
@@ -578,21 +615,12 @@ func (q *QueryRunner) storeAsyncSearch(qmc diag.DebugInfoCollector, id, asyncId
 				isCompressed = true
 			}
 		}
-		q.AsyncRequestStorage.Store(asyncId, async_search_storage.NewAsyncRequestResult(compressedBody, err, time.Now(), isCompressed))
+		q.AsyncRequestStorage.Store(asyncId, async_search_storage.NewAsyncRequestResult(compressedBody, err, time.Now(), keepAlive, isCompressed))
 	}
 
 	return
 }
 
-func (q *QueryRunner) asyncQueriesCumulatedBodySize() int {
-	size := 0
-	q.AsyncRequestStorage.Range(func(key string, value *async_search_storage.AsyncRequestResult) bool {
-		size += len(value.GetResponseBody())
-		return true
-	})
-	return size
-}
-
 func (q *QueryRunner) HandleAsyncSearchStatus(ctx context.Context, id string) ([]byte, error) {
 	logger.DebugWithCtx(ctx).Msgf("handling async search status for id: %s", id)
 	if _, ok := q.AsyncRequestStorage.Load(id); ok { // there IS a result in storage, so query is completed/no longer running,
@@ -643,12 +671,13 @@ func (q *QueryRunner) DeleteAsyncSearch(id string) ([]byte, error) {
 	if !strings.Contains(id, tracing.AsyncIdPrefix) {
 		return nil, errors.New("invalid quesma async search id : " + id)
 	}
+	q.AsyncQueriesContexts.Cancel(id)
 	q.AsyncRequestStorage.Delete(id)
 	return []byte(`{"acknowledged":true}`), nil
 }
 
 func (q *QueryRunner) reachedQueriesLimit(ctx context.Context, asyncId string, doneCh chan<- asyncSearchWithError) bool {
-	if q.AsyncRequestStorage.Size() < asyncQueriesLimit && q.asyncQueriesCumulatedBodySize() < asyncQueriesLimitBytes {
+	if q.AsyncRequestStorage.Size() < asyncQueriesLimit && q.AsyncRequestStorage.SpaceInUse() < asyncQueriesLimitBytes {
 		return false
 	}
 	err := errors.New("too many async queries")
@@ -657,8 +686,8 @@ func (q *QueryRunner) reachedQueriesLimit(ctx context.Context, asyncId string, d
 	return true
 }
 
-func (q *QueryRunner) addAsyncQueryContext(ctx context.Context, cancel context.CancelFunc, asyncRequestIdStr string) {
-	q.AsyncQueriesContexts.Store(asyncRequestIdStr, async_search_storage.NewAsyncQueryContext(ctx, cancel, asyncRequestIdStr))
+func (q *QueryRunner) addAsyncQueryContext(ctx context.Context, cancel context.CancelFunc, asyncRequestIdStr string, keepAlive time.Duration) {
+	q.AsyncQueriesContexts.Store(asyncRequestIdStr, async_search_storage.NewAsyncQueryContext(ctx, cancel, asyncRequestIdStr, keepAlive))
 }
 
 // This is a HACK
@@ -899,7 +928,8 @@ func (q *QueryRunner) searchWorker(ctx context.Context,
 		}
 		// We need different ctx as our cancel is no longer tied to HTTP request, but to overall timeout.
 		dbQueryCtx, dbCancel := context.WithCancel(tracing.NewContextWithRequest(ctx))
-		q.addAsyncQueryContext(dbQueryCtx, dbCancel, optAsync.asyncId)
+		q.addAsyncQueryContext(dbQueryCtx, dbCancel, optAsync.asyncId, optAsync.keepAlive)
+		defer q.AsyncQueriesContexts.Delete(optAsync.asyncId)
 		ctx = dbQueryCtx
 	}
 
@@ -936,15 +966,17 @@ func (q *QueryRunner) postProcessResults(plan *model.ExecutionPlan, results [][]
 		// painless scripts rely on field names not column names
 
 		fieldScripts := make(map[string]painful.Expr)
+		fieldParams := make(map[string]map[string]any)
 
 		for field, runtimeMapping := range plan.Queries[0].RuntimeMappings {
 			if runtimeMapping.PostProcessExpression != nil {
 				fieldScripts[field] = runtimeMapping.PostProcessExpression
+				fieldParams[field] = runtimeMapping.Params
 			}
 		}
 
 		if len(fieldScripts) > 0 {
-			pipeline = append(pipeline, pipelineElement{"applyPainlessScripts", &EvalPainlessScriptOnColumnsTransformer{FieldScripts: fieldScripts}})
+			pipeline = append(pipeline, pipelineElement{"applyPainlessScripts", &EvalPainlessScriptOnColumnsTransformer{FieldScripts: fieldScripts, FieldParams: fieldParams}})
 		}
 
 	}
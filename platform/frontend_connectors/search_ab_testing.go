@@ -222,12 +222,12 @@ func (q *QueryRunner) executePlanElastic(ctx context.Context, plan *model.Execut
 			go func() { // Async search takes longer. Return partial results and wait for
 				defer recovery.LogPanicWithCtx(ctx)
 				res := <-doneCh
-				responseBody, err = q.storeAsyncSearchWithRaw(q.debugInfoCollector, id, optAsync.asyncId, optAsync.startTime, path, requestBody, res.response, res.err, res.translatedQueryBody, true, opaqueId)
+				responseBody, err = q.storeAsyncSearchWithRaw(q.debugInfoCollector, id, optAsync.asyncId, optAsync.startTime, path, requestBody, res.response, res.err, res.translatedQueryBody, true, optAsync.keepAlive, opaqueId)
 				sendABResult(responseBody, err)
 			}()
 			return q.HandlePartialAsyncSearch(ctx, optAsync.asyncId)
 		case res := <-doneCh:
-			responseBody, err = q.storeAsyncSearchWithRaw(q.debugInfoCollector, id, optAsync.asyncId, optAsync.startTime, path, requestBody, res.response, res.err, res.translatedQueryBody, true, opaqueId)
+			responseBody, err = q.storeAsyncSearchWithRaw(q.debugInfoCollector, id, optAsync.asyncId, optAsync.startTime, path, requestBody, res.response, res.err, res.translatedQueryBody, true, optAsync.keepAlive, opaqueId)
 			sendABResult(responseBody, err)
 			return responseBody, err
 		}
@@ -313,7 +313,7 @@ func WrapElasticResponseAsAsync(searchResponse any, asyncId string, isPartial bo
 
 // TODO rename and change signature to use asyncElasticSearchWithError
 func (q *QueryRunner) storeAsyncSearchWithRaw(qmc diag.DebugInfoCollector, id, asyncId string,
-	startTime time.Time, path string, body types.JSON, resultJSON types.JSON, resultError error, translatedQueryBody []diag.TranslatedSQLQuery, keep bool, opaqueId string) (responseBody []byte, err error) {
+	startTime time.Time, path string, body types.JSON, resultJSON types.JSON, resultError error, translatedQueryBody []diag.TranslatedSQLQuery, keep bool, keepAlive time.Duration, opaqueId string) (responseBody []byte, err error) {
 
 	took := time.Since(startTime)
 
@@ -354,7 +354,7 @@ func (q *QueryRunner) storeAsyncSearchWithRaw(qmc diag.DebugInfoCollector, id, a
 				isCompressed = true
 			}
 		}
-		q.AsyncRequestStorage.Store(asyncId, async_search_storage.NewAsyncRequestResult(compressedBody, err, time.Now(), isCompressed))
+		q.AsyncRequestStorage.Store(asyncId, async_search_storage.NewAsyncRequestResult(compressedBody, err, time.Now(), keepAlive, isCompressed))
 	}
 
 	return
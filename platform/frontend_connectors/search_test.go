@@ -94,7 +94,7 @@ func TestAsyncSearchHandler(t *testing.T) {
 			}
 
 			queryRunner := NewQueryRunnerDefaultForTests(db, &DefaultConfig, tableName, table, s)
-			_, err := queryRunner.HandleAsyncSearch(ctx, tableName, types.MustJSON(tt.QueryJson), defaultAsyncSearchTimeout, true)
+			_, err := queryRunner.HandleAsyncSearch(ctx, tableName, types.MustJSON(tt.QueryJson), defaultAsyncSearchTimeout, true, 0)
 			assert.NoError(t, err)
 
 			if err = mock.ExpectationsWereMet(); err != nil {
@@ -145,7 +145,7 @@ func TestAsyncSearchHandlerSpecialCharacters(t *testing.T) {
 			mock.ExpectQuery(tt.ExpectedPancakeSQL).WillReturnRows(sqlmock.NewRows([]string{"@timestamp", "host.name"}))
 
 			queryRunner := NewQueryRunnerDefaultForTests(db, &DefaultConfig, tableName, util.NewSyncMapWith(tableName, &table), s)
-			_, err := queryRunner.HandleAsyncSearch(ctx, tableName, types.MustJSON(tt.QueryRequestJson), defaultAsyncSearchTimeout, true)
+			_, err := queryRunner.HandleAsyncSearch(ctx, tableName, types.MustJSON(tt.QueryRequestJson), defaultAsyncSearchTimeout, true, 0)
 			assert.NoError(t, err)
 
 			if err = mock.ExpectationsWereMet(); err != nil {
@@ -505,7 +505,7 @@ func TestAsyncSearchFilter(t *testing.T) {
 			}
 
 			queryRunner := NewQueryRunnerDefaultForTests(db, &DefaultConfig, tableName, util.NewSyncMapWith(tableName, table), s)
-			_, _ = queryRunner.HandleAsyncSearch(ctx, tableName, types.MustJSON(tt.QueryJson), defaultAsyncSearchTimeout, true)
+			_, _ = queryRunner.HandleAsyncSearch(ctx, tableName, types.MustJSON(tt.QueryJson), defaultAsyncSearchTimeout, true, 0)
 			if err := mock.ExpectationsWereMet(); err != nil {
 				t.Fatal("there were unfulfilled expections:", err)
 			}
@@ -629,7 +629,7 @@ func TestHandlingDateTimeFields(t *testing.T) {
 
 		// .AddRow(1000, uint64(10)).AddRow(1001, uint64(20))) // here rows should be added if uint64 were supported
 		queryRunner := NewQueryRunnerDefaultForTests(db, &DefaultConfig, tableName, util.NewSyncMapWith(tableName, &table), s)
-		response, err := queryRunner.HandleAsyncSearch(ctx, tableName, types.MustJSON(query(fieldName)), defaultAsyncSearchTimeout, true)
+		response, err := queryRunner.HandleAsyncSearch(ctx, tableName, types.MustJSON(query(fieldName)), defaultAsyncSearchTimeout, true, 0)
 		assert.NoError(t, err)
 
 		var responseMap model.JsonMap
@@ -707,7 +707,7 @@ func TestNumericFacetsQueries(t *testing.T) {
 				if handlerName == "handleSearch" {
 					response, err = queryRunner.HandleSearch(ctx, tableName, types.MustJSON(tt.QueryJson))
 				} else if handlerName == "handleAsyncSearch" {
-					response, err = queryRunner.HandleAsyncSearch(ctx, tableName, types.MustJSON(tt.QueryJson), defaultAsyncSearchTimeout, true)
+					response, err = queryRunner.HandleAsyncSearch(ctx, tableName, types.MustJSON(tt.QueryJson), defaultAsyncSearchTimeout, true, 0)
 				}
 				assert.NoError(t, err)
 
@@ -805,7 +805,7 @@ func TestSearchTrackTotalCount(t *testing.T) {
 			response, err = queryRunner.HandleSearch(ctx, tableName, types.MustJSON(testcase.QueryRequestJson))
 		} else if handlerName == "handleAsyncSearch" {
 			response, err = queryRunner.HandleAsyncSearch(
-				ctx, tableName, types.MustJSON(testcase.QueryRequestJson), defaultAsyncSearchTimeout, true)
+				ctx, tableName, types.MustJSON(testcase.QueryRequestJson), defaultAsyncSearchTimeout, true, 0)
 		}
 		assert.NoError(t, err)
 
@@ -900,7 +900,7 @@ func TestFullQueryTestWIP(t *testing.T) {
 			response, err = queryRunner.HandleSearch(ctx, tableName, types.MustJSON(testcase.QueryRequestJson))
 		} else if handlerName == "handleAsyncSearch" {
 			response, err = queryRunner.HandleAsyncSearch(
-				ctx, tableName, types.MustJSON(testcase.QueryRequestJson), defaultAsyncSearchTimeout, true)
+				ctx, tableName, types.MustJSON(testcase.QueryRequestJson), defaultAsyncSearchTimeout, true, 0)
 		}
 		assert.NoError(t, err)
 
@@ -1060,7 +1060,7 @@ func TestSearchAfterParameter_sortByJustTimestamp(t *testing.T) {
 			case "handleSearch":
 				response, err = queryRunner.HandleSearch(ctx, tableName, types.MustJSON(iteration.request))
 			case "handleAsyncSearch":
-				response, err = queryRunner.HandleAsyncSearch(ctx, tableName, types.MustJSON(iteration.request), defaultAsyncSearchTimeout, true)
+				response, err = queryRunner.HandleAsyncSearch(ctx, tableName, types.MustJSON(iteration.request), defaultAsyncSearchTimeout, true, 0)
 			default:
 				t.Fatalf("Unknown handler name: %s", handlerName)
 			}
@@ -1173,7 +1173,7 @@ func TestSearchAfterParameter_sortByJustOneStringField(t *testing.T) {
 			case "handleSearch":
 				response, err = queryRunner.HandleSearch(ctx, tableName, types.MustJSON(iteration.request))
 			case "handleAsyncSearch":
-				response, err = queryRunner.HandleAsyncSearch(ctx, tableName, types.MustJSON(iteration.request), defaultAsyncSearchTimeout, true)
+				response, err = queryRunner.HandleAsyncSearch(ctx, tableName, types.MustJSON(iteration.request), defaultAsyncSearchTimeout, true, 0)
 			default:
 				t.Fatalf("Unknown handler name: %s", handlerName)
 			}
@@ -1332,7 +1332,7 @@ func TestSearchAfterParameter_sortByMultipleFields(t *testing.T) {
 			case "handleSearch":
 				response, err = queryRunner.HandleSearch(ctx, tableName, types.MustJSON(iteration.request))
 			case "handleAsyncSearch":
-				response, err = queryRunner.HandleAsyncSearch(ctx, tableName, types.MustJSON(iteration.request), defaultAsyncSearchTimeout, true)
+				response, err = queryRunner.HandleAsyncSearch(ctx, tableName, types.MustJSON(iteration.request), defaultAsyncSearchTimeout, true, 0)
 			default:
 				t.Fatalf("Unknown handler name: %s", handlerName)
 			}
@@ -1438,7 +1438,7 @@ func TestSearchAfterParameter_sortByNoField(t *testing.T) {
 			case "handleSearch":
 				response, err = queryRunner.HandleSearch(ctx, tableName, types.MustJSON(iteration.request))
 			case "handleAsyncSearch":
-				response, err = queryRunner.HandleAsyncSearch(ctx, tableName, types.MustJSON(iteration.request), defaultAsyncSearchTimeout, true)
+				response, err = queryRunner.HandleAsyncSearch(ctx, tableName, types.MustJSON(iteration.request), defaultAsyncSearchTimeout, true, 0)
 			default:
 				t.Fatalf("Unknown handler name: %s", handlerName)
 			}
@@ -30,6 +30,7 @@ func (t *replaceColumNamesWithFieldNames) Transform(plan *model.ExecutionPlan, r
 
 type EvalPainlessScriptOnColumnsTransformer struct {
 	FieldScripts map[string]painful.Expr
+	FieldParams  map[string]map[string]any
 }
 
 func (t *EvalPainlessScriptOnColumnsTransformer) Transform(plan *model.ExecutionPlan, result [][]model.QueryResultRow) (*model.ExecutionPlan, [][]model.QueryResultRow, error) {
@@ -45,14 +46,19 @@ func (t *EvalPainlessScriptOnColumnsTransformer) Transform(plan *model.Execution
 
 				if script, exists := t.FieldScripts[row.Cols[j].ColName]; exists {
 					env := &painful.Env{
-						Doc: doc,
+						Doc:    doc,
+						Params: t.FieldParams[row.Cols[j].ColName],
 					}
 
-					_, err := script.Eval(env)
+					value, err := script.Eval(env)
 					if err != nil {
 						return plan, nil, err
 					}
-					row.Cols[j].Value = env.EmitValue
+					// scripted fields return their value instead of emitting it
+					if env.EmitValue != nil {
+						value = env.EmitValue
+					}
+					row.Cols[j].Value = value
 				}
 			}
 		}
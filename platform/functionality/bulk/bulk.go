@@ -4,13 +4,16 @@ package bulk
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/QuesmaOrg/quesma/platform/backend_connectors"
+	"github.com/QuesmaOrg/quesma/platform/common_table"
 	"github.com/QuesmaOrg/quesma/platform/database_common"
 	"github.com/QuesmaOrg/quesma/platform/end_user_errors"
 	"github.com/QuesmaOrg/quesma/platform/ingest"
+	"github.com/QuesmaOrg/quesma/platform/ingest/buffer"
+	"github.com/QuesmaOrg/quesma/platform/ingest/pipeline"
 	"github.com/QuesmaOrg/quesma/platform/logger"
-	"github.com/QuesmaOrg/quesma/platform/parsers/elastic_query_dsl"
 	"github.com/QuesmaOrg/quesma/platform/recovery"
 	"github.com/QuesmaOrg/quesma/platform/stats"
 	"github.com/QuesmaOrg/quesma/platform/table_resolver"
@@ -20,6 +23,7 @@ import (
 	"github.com/goccy/go-json"
 	"io"
 	"net/http"
+	"net/url"
 	"sort"
 	"strings"
 )
@@ -28,6 +32,8 @@ type (
 	BulkRequestEntry struct {
 		operation string
 		index     string
+		id        string // only used for update and delete in Clickhouse
+		pipeline  string // ingest pipeline to run before inserting to Clickhouse, empty if none
 		document  types.JSON
 		response  *BulkItem
 	}
@@ -66,7 +72,7 @@ type (
 	}
 )
 
-func Write(ctx context.Context, defaultIndex *string, bulk types.NDJSON, ip *ingest.IngestProcessor,
+func Write(ctx context.Context, defaultIndex *string, defaultPipeline string, bulk types.NDJSON, ip *ingest.IngestProcessor,
 	ingestStatsEnabled bool, esBackendConn *backend_connectors.ElasticsearchBackendConnector, phoneHomeClient diag.PhoneHomeClient, tableResolver table_resolver.TableResolver) (results []BulkItem, err error) {
 	defer recovery.LogPanic()
 
@@ -81,7 +87,7 @@ func Write(ctx context.Context, defaultIndex *string, bulk types.NDJSON, ip *ing
 		indexNameRewriter = ip.GetIndexNameRewriter()
 	}
 
-	results, clickhouseBulkEntries, elasticRequestBody, elasticBulkEntries, err := SplitBulk(ctx, defaultIndex, bulk, maxBulkSize, tableResolver, indexNameRewriter)
+	results, clickhouseBulkEntries, elasticRequestBody, elasticBulkEntries, err := SplitBulk(ctx, defaultIndex, defaultPipeline, bulk, maxBulkSize, tableResolver, indexNameRewriter)
 	if err != nil {
 		return []BulkItem{}, err
 	}
@@ -103,7 +109,12 @@ func Write(ctx context.Context, defaultIndex *string, bulk types.NDJSON, ip *ing
 		return []BulkItem{}, end_user_errors.ErrNoIngest.New(fmt.Errorf("ingest processor is not available, but documents are targeted to Clickhouse indexes: %s", strings.Join(indexesAsList, ",")))
 	}
 
-	err = sendToElastic(elasticRequestBody, esBackendConn, elasticBulkEntries)
+	// shippers back off when the whole bulk is rejected, so we don't send a part of it to Elastic
+	if len(clickhouseBulkEntries) > 0 && ip.IngestBufferFull() {
+		return []BulkItem{}, fmt.Errorf("can't ingest documents to Clickhouse indexes: %w", buffer.ErrFull)
+	}
+
+	err = sendToElastic(elasticRequestBody, defaultPipeline, esBackendConn, elasticBulkEntries)
 	if err != nil {
 		return []BulkItem{}, err
 	}
@@ -124,7 +135,7 @@ func Write(ctx context.Context, defaultIndex *string, bulk types.NDJSON, ip *ing
 	return nonEmptyResults, nil
 }
 
-func SplitBulk(ctx context.Context, defaultIndex *string, bulk types.NDJSON, maxBulkSize int, tableResolver table_resolver.TableResolver, rewriter ingest.IndexNameRewriter) ([]BulkItem, map[string][]BulkRequestEntry, []byte, []BulkRequestEntry, error) {
+func SplitBulk(ctx context.Context, defaultIndex *string, defaultPipeline string, bulk types.NDJSON, maxBulkSize int, tableResolver table_resolver.TableResolver, rewriter ingest.IndexNameRewriter) ([]BulkItem, map[string][]BulkRequestEntry, []byte, []BulkRequestEntry, error) {
 	results := make([]BulkItem, maxBulkSize)
 
 	clickhouseBulkEntries := make(map[string][]BulkRequestEntry, maxBulkSize)
@@ -139,13 +150,6 @@ func SplitBulk(ctx context.Context, defaultIndex *string, bulk types.NDJSON, max
 			index = rewriter.RewriteIndex(index)
 		}
 
-		entryWithResponse := BulkRequestEntry{
-			operation: operation,
-			index:     index,
-			document:  document,
-			response:  &results[entryNumber],
-		}
-
 		if index == "" {
 			if defaultIndex != nil {
 				index = *defaultIndex
@@ -156,6 +160,20 @@ func SplitBulk(ctx context.Context, defaultIndex *string, bulk types.NDJSON, max
 			}
 		}
 
+		pipelineName := defaultPipeline
+		if opPipeline := op.GetPipeline(); opPipeline != nil {
+			pipelineName = *opPipeline
+		}
+
+		entryWithResponse := BulkRequestEntry{
+			operation: operation,
+			index:     index,
+			id:        op.GetId(),
+			pipeline:  pipelineName,
+			document:  document,
+			response:  &results[entryNumber],
+		}
+
 		decision := tableResolver.Resolve(quesma_api.IngestPipeline, index)
 
 		if decision.Err != nil {
@@ -163,33 +181,8 @@ func SplitBulk(ctx context.Context, defaultIndex *string, bulk types.NDJSON, max
 		}
 
 		if decision.IsClosed || len(decision.UseConnectors) == 0 {
-			bulkSingleResponse := BulkSingleResponse{
-				Shards: BulkShardsResponse{
-					Failed:     1,
-					Successful: 0,
-					Total:      1,
-				},
-				Status: 403,
-				Type:   "_doc",
-				Error: elastic_query_dsl.Error{
-					RootCause: []elastic_query_dsl.RootCause{
-						{
-							Type:   "index_closed_exception",
-							Reason: fmt.Sprintf("index %s is not routed to any connector", index),
-						},
-					},
-					Type:   "index_closed_exception",
-					Reason: fmt.Sprintf("index %s is not routed to any connector", index),
-				},
-			}
-			switch operation {
-			case "create":
-				entryWithResponse.response.Create = bulkSingleResponse
-
-			case "index":
-				entryWithResponse.response.Index = bulkSingleResponse
-
-			default:
+			bulkSingleResponse := newErrorResponse(entryWithResponse, 403, "index_closed_exception", fmt.Sprintf("index %s is not routed to any connector", index))
+			if !entryWithResponse.setResponse(bulkSingleResponse) {
 				return fmt.Errorf("unsupported bulk operation type: %s. Document: %v", operation, document)
 			}
 		}
@@ -220,7 +213,7 @@ func SplitBulk(ctx context.Context, defaultIndex *string, bulk types.NDJSON, max
 			case *quesma_api.ConnectorDecisionClickhouse:
 
 				// Bulk entry for Clickhouse
-				if operation != "create" && operation != "index" {
+				if operation != "create" && operation != "index" && operation != "update" && operation != "delete" {
 					// Elastic also fails the entire bulk in such case
 					logger.ErrorWithCtxAndReason(ctx, "unsupported bulk operation type").Msgf("unsupported bulk operation type: %s", operation)
 					return fmt.Errorf("unsupported bulk operation type: %s. Operation: %v, Document: %v", operation, rawOp, document)
@@ -242,13 +235,20 @@ func SplitBulk(ctx context.Context, defaultIndex *string, bulk types.NDJSON, max
 	return results, clickhouseBulkEntries, elasticRequestBody, elasticBulkEntries, err
 }
 
-func sendToElastic(elasticRequestBody []byte, esBackendConn *backend_connectors.ElasticsearchBackendConnector, elasticBulkEntries []BulkRequestEntry) error {
+func sendToElastic(elasticRequestBody []byte, defaultPipeline string, esBackendConn *backend_connectors.ElasticsearchBackendConnector, elasticBulkEntries []BulkRequestEntry) error {
 	if len(elasticRequestBody) == 0 {
 		// Fast path - no need to contact Elastic!
 		return nil
 	}
 
-	response, err := esBackendConn.RequestWithHeaders(context.Background(), "POST", "/_bulk", elasticRequestBody, http.Header{"Content-Type": {"application/x-ndjson"}})
+	// pipelines are mirrored to Elasticsearch (see frontend_connectors.HandlePutIngestPipeline), per-document ones
+	// are already in the forwarded metadata
+	endpoint := "/_bulk"
+	if defaultPipeline != "" {
+		endpoint += "?pipeline=" + url.QueryEscape(defaultPipeline)
+	}
+
+	response, err := esBackendConn.RequestWithHeaders(context.Background(), "POST", endpoint, elasticRequestBody, http.Header{"Content-Type": {"application/x-ndjson"}})
 	if err != nil {
 		return err
 	}
@@ -276,69 +276,157 @@ func sendToElastic(elasticRequestBody []byte, esBackendConn *backend_connectors.
 	return nil
 }
 
-func sendToClickhouse(ctx context.Context, clickhouseBulkEntries map[string][]BulkRequestEntry, emptyPhoneHomeClient diag.PhoneHomeClient, ingestStatsEnabled bool, ip *ingest.IngestProcessor) {
-	for indexName, documents := range clickhouseBulkEntries {
-		emptyPhoneHomeClient.IngestCounters().Add(indexName, int64(len(documents)))
-
-		for _, document := range documents {
-			stats.GlobalStatistics.Process(ingestStatsEnabled, indexName, document.document, database_common.NestedSeparator)
-		}
-
-		inserts := make([]types.JSON, len(documents))
-		for i, document := range documents {
-			inserts[i] = document.document
-		}
+func sendToClickhouse(ctx context.Context, clickhouseBulkEntries map[string][]BulkRequestEntry, phoneHomeClient diag.PhoneHomeClient, ingestStatsEnabled bool, ip *ingest.IngestProcessor) {
+	for indexName, entries := range clickhouseBulkEntries {
+		// Operations are applied in the order of the bulk (like in Elastic), so we batch only consecutive inserts,
+		// while each update or delete is executed on its own, after preceding inserts.
+		existingIds, existingIdsErr := existingDocumentIds(ctx, ip, indexName, entries)
+		var inserts []BulkRequestEntry
+		for _, entry := range entries {
+			switch entry.operation {
+			case "create", "index":
+				if entry.operation == "create" && entry.id != "" {
+					if existingIdsErr != nil {
+						entry.setResponse(newErrorResponse(entry, 503, "quesma_error", existingIdsErr.Error()))
+						continue
+					}
+					if response, conflict := createConflict(entry, existingIds); conflict {
+						entry.setResponse(response)
+						continue
+					}
+				}
+				if entry.id != "" && existingIds != nil {
+					existingIds[entry.id] = true
+				}
+				inserts = append(inserts, entry)
 
-		err := ip.Ingest(ctx, indexName, inserts)
-
-		for _, document := range documents {
-			bulkSingleResponse := BulkSingleResponse{
-				ID:          "fakeId",
-				Index:       document.index,
-				PrimaryTerm: 1,
-				SeqNo:       0,
-				Shards: BulkShardsResponse{
-					Failed:     0,
-					Successful: 1,
-					Total:      1,
-				},
-				Version: 0,
-				Result:  "created",
-				Status:  201,
-				Type:    "_doc",
-			}
+			case "update", "delete":
+				insertToClickhouse(ctx, indexName, inserts, phoneHomeClient, ingestStatsEnabled, ip)
+				inserts = nil
 
-			if err != nil {
-				bulkSingleResponse.Result = ""
-				bulkSingleResponse.Status = 400
-				bulkSingleResponse.Shards = BulkShardsResponse{
-					Failed:     1,
-					Successful: 0,
-					Total:      1,
+				// documents indexed earlier may be still waiting in the ingest buffer
+				if err := ip.FlushIngestBuffer(ctx, indexName); err != nil {
+					entry.setResponse(newErrorResponse(entry, 503, "quesma_error", err.Error()))
+					continue
+				}
+				var response BulkSingleResponse
+				if entry.operation == "update" {
+					response = updateInClickhouse(ctx, ip, entry)
+				} else {
+					response = deleteInClickhouse(ctx, ip, entry)
 				}
-				bulkSingleResponse.Error = elastic_query_dsl.Error{
-					RootCause: []elastic_query_dsl.RootCause{
-						{
-							Type:   "quesma_error",
-							Reason: err.Error(),
-						},
-					},
-					Type:   "quesma_error",
-					Reason: err.Error(),
+				// keep track of documents, which later `create` operations could conflict with
+				if existingIds != nil && entry.id != "" {
+					switch response.Result {
+					case "created":
+						existingIds[entry.id] = true
+					case "deleted":
+						delete(existingIds, entry.id)
+					}
 				}
+				entry.setResponse(response)
+
+			default:
+				logger.Error().Msgf("unsupported bulk operation type: %s. Document: %v", entry.operation, entry.document)
 			}
+		}
+		insertToClickhouse(ctx, indexName, inserts, phoneHomeClient, ingestStatsEnabled, ip)
+	}
+}
 
-			// Fill out the response pointer (a pointer to the results array we will return for a bulk)
-			switch document.operation {
-			case "create":
-				document.response.Create = bulkSingleResponse
+func insertToClickhouse(ctx context.Context, indexName string, documents []BulkRequestEntry, phoneHomeClient diag.PhoneHomeClient, ingestStatsEnabled bool, ip *ingest.IngestProcessor) {
+	if len(documents) == 0 {
+		return
+	}
 
-			case "index":
-				document.response.Index = bulkSingleResponse
+	phoneHomeClient.IngestCounters().Add(indexName, int64(len(documents)))
 
-			default:
-				logger.Error().Msgf("unsupported bulk operation type: %s. Document: %v", document.operation, document.document)
-			}
+	for _, document := range documents {
+		stats.GlobalStatistics.Process(ingestStatsEnabled, indexName, document.document, database_common.NestedSeparator)
+	}
+
+	inserts := make([]types.JSON, len(documents))
+	pipelines := make([]string, len(documents))
+	for i, document := range documents {
+		inserts[i] = document.document
+		if document.id != "" {
+			inserts[i][common_table.DocumentIdColumn] = document.id
 		}
+		pipelines[i] = document.pipeline
+	}
+
+	ids, err := ip.IngestWithPipelines(ctx, indexName, inserts, pipelines)
+
+	for i, document := range documents {
+		id := ids[i] // pipelines may set it
+		if id == "" {
+			id = "fakeId"
+		}
+		bulkSingleResponse := BulkSingleResponse{
+			ID:          id,
+			Index:       document.index,
+			PrimaryTerm: 1,
+			SeqNo:       0,
+			Shards: BulkShardsResponse{
+				Failed:     0,
+				Successful: 1,
+				Total:      1,
+			},
+			Version: 0,
+			Result:  "created",
+			Status:  201,
+			Type:    "_doc",
+		}
+
+		// other documents of the batch are stored, when only some of them are rejected
+		documentErr := err
+		var rejectedErr *ingest.RejectedDocumentsError
+		if errors.As(err, &rejectedErr) {
+			documentErr = rejectedErr.Errors[i]
+		}
+		if errors.Is(documentErr, ingest.ErrDocumentDropped) {
+			bulkSingleResponse = newResponse(document, "noop", 200)
+		} else if documentErr != nil {
+			bulkSingleResponse = ingestErrorResponse(document, documentErr)
+			bulkSingleResponse.ID = id
+		}
+
+		// Fill out the response pointer (a pointer to the results array we will return for a bulk)
+		document.setResponse(bulkSingleResponse)
+	}
+}
+
+// ingestErrorResponse describes why a document hasn't been ingested, like Elasticsearch does
+func ingestErrorResponse(entry BulkRequestEntry, err error) BulkSingleResponse {
+	var strictMappingErr *ingest.StrictDynamicMappingError
+	var pipelineErr *ingest.PipelineError
+	switch {
+	case errors.Is(err, buffer.ErrFull):
+		return newErrorResponse(entry, http.StatusTooManyRequests, "es_rejected_execution_exception", err.Error())
+	case errors.As(err, &strictMappingErr):
+		return newErrorResponse(entry, http.StatusBadRequest, "strict_dynamic_mapping_exception", strictMappingErr.Error())
+	case errors.As(err, &pipelineErr):
+		response := newErrorResponse(entry, http.StatusBadRequest, "", "")
+		response.Error = pipeline.RenderError(pipelineErr.Err)
+		return response
+	default:
+		return newErrorResponse(entry, 400, "quesma_error", err.Error())
+	}
+}
+
+// setResponse fills out the response of the entry, returns false for an unknown operation.
+func (entry BulkRequestEntry) setResponse(response BulkSingleResponse) bool {
+	switch entry.operation {
+	case "create":
+		entry.response.Create = response
+	case "index":
+		entry.response.Index = response
+	case "update":
+		entry.response.Update = response
+	case "delete":
+		entry.response.Delete = response
+	default:
+		return false
 	}
+	return true
 }
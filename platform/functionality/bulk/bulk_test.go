@@ -4,11 +4,21 @@ package bulk
 
 import (
 	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/QuesmaOrg/quesma/platform/backend_connectors"
+	"github.com/QuesmaOrg/quesma/platform/common_table"
 	"github.com/QuesmaOrg/quesma/platform/config"
+	"github.com/QuesmaOrg/quesma/platform/database_common"
+	"github.com/QuesmaOrg/quesma/platform/end_user_errors"
 	"github.com/QuesmaOrg/quesma/platform/ingest"
+	"github.com/QuesmaOrg/quesma/platform/parsers/elastic_query_dsl"
+	"github.com/QuesmaOrg/quesma/platform/persistence"
+	"github.com/QuesmaOrg/quesma/platform/schema"
 	"github.com/QuesmaOrg/quesma/platform/table_resolver"
 	"github.com/QuesmaOrg/quesma/platform/types"
 	"github.com/QuesmaOrg/quesma/platform/util"
+	quesma_api "github.com/QuesmaOrg/quesma/platform/v2/core"
+	"github.com/QuesmaOrg/quesma/platform/v2/core/diag"
 	"github.com/goccy/go-json"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -141,7 +151,7 @@ func TestSplitBulkSampleData(t *testing.T) {
 	maxBulkSize := len(bulk)
 
 	// first returned value here is a result of side effects (writes to ClickHouse and Elasticsearch) so it is not tested here
-	_, clickhouseBulkEntries, elasticRequestBody, elasticBulkEntries, err := SplitBulk(ctx, &defaultIndex, bulk, maxBulkSize, testTableResolver, &ingest.NoOpIndexNameRewriter{})
+	_, clickhouseBulkEntries, elasticRequestBody, elasticBulkEntries, err := SplitBulk(ctx, &defaultIndex, "", bulk, maxBulkSize, testTableResolver, &ingest.NoOpIndexNameRewriter{})
 
 	assert.NoError(t, err)
 	assert.Len(t, clickhouseBulkEntries["kibana_sample_data_ecommerce"], 5)
@@ -161,7 +171,7 @@ func TestSplitBulkDelete(t *testing.T) {
 	maxBulkSize := len(bulk)
 
 	// first returned value here is a result of side effects (writes to ClickHouse and Elasticsearch) so it is not tested here
-	_, clickhouseBulkEntries, elasticRequestBody, elasticBulkEntries, err := SplitBulk(ctx, &defaultIndex, bulk, maxBulkSize, testTableResolver, &ingest.NoOpIndexNameRewriter{})
+	_, clickhouseBulkEntries, elasticRequestBody, elasticBulkEntries, err := SplitBulk(ctx, &defaultIndex, "", bulk, maxBulkSize, testTableResolver, &ingest.NoOpIndexNameRewriter{})
 
 	assert.NoError(t, err)
 	assert.Len(t, clickhouseBulkEntries, 0)
@@ -189,7 +199,7 @@ func TestSplitBulkUpdatePayload(t *testing.T) {
 	maxBulkSize := len(bulk)
 
 	// first returned value here is a result of side effects (writes to ClickHouse and Elasticsearch) so it is not tested here
-	_, clickhouseBulkEntries, elasticRequestBody, elasticBulkEntries, err := SplitBulk(ctx, &defaultIndex, bulk, maxBulkSize, testTableResolver, &ingest.NoOpIndexNameRewriter{})
+	_, clickhouseBulkEntries, elasticRequestBody, elasticBulkEntries, err := SplitBulk(ctx, &defaultIndex, "", bulk, maxBulkSize, testTableResolver, &ingest.NoOpIndexNameRewriter{})
 
 	assert.NoError(t, err)
 	assert.Len(t, clickhouseBulkEntries, 0)
@@ -217,7 +227,7 @@ func TestSplitBulkMixedPayload(t *testing.T) {
 	}
 	maxBulkSize := len(bulk)
 
-	results, clickhouseBulkEntries, elasticRequestBody, elasticBulkEntries, err := SplitBulk(ctx, &defaultIndex, bulk, maxBulkSize, testTableResolver, &ingest.NoOpIndexNameRewriter{})
+	results, clickhouseBulkEntries, elasticRequestBody, elasticBulkEntries, err := SplitBulk(ctx, &defaultIndex, "", bulk, maxBulkSize, testTableResolver, &ingest.NoOpIndexNameRewriter{})
 
 	assert.NoError(t, err)
 	assert.Len(t, results, maxBulkSize)
@@ -225,3 +235,326 @@ func TestSplitBulkMixedPayload(t *testing.T) {
 	assert.NotEmpty(t, elasticRequestBody)
 	assert.Len(t, elasticBulkEntries, 4)
 }
+
+func TestSplitBulkClickhouseUpdateAndDelete(t *testing.T) {
+	ctx := context.Background()
+	defaultIndex := "kibana_sample_data_ecommerce"
+	var payload = `{"create":{}}
+{"customer_first_name":"Robbie","order_date":"2025-01-29T08:03:50+00:00"}
+{"update":{"_id":"323032352d30312d32392030383a30333a3530202b3030303020555443qqq3131"}}
+{"doc":{"customer_first_name":"Rob"}}
+{"delete":{"_id":"323032352d30312d32392030383a30333a3530202b3030303020555443qqq3131","_index":"kibana_sample_data_ecommerce"}}
+`
+
+	bulk, err := types.ExpectNDJSON(types.ParseRequestBody(payload))
+	require.NoError(t, err)
+	maxBulkSize := len(bulk)
+
+	_, clickhouseBulkEntries, elasticRequestBody, elasticBulkEntries, err := SplitBulk(ctx, &defaultIndex, "", bulk, maxBulkSize, testTableResolver, &ingest.NoOpIndexNameRewriter{})
+
+	assert.NoError(t, err)
+	assert.Empty(t, elasticRequestBody)
+	assert.Len(t, elasticBulkEntries, 0)
+
+	entries := clickhouseBulkEntries["kibana_sample_data_ecommerce"]
+	require.Len(t, entries, 3)
+	for i, expectedOperation := range []string{"create", "update", "delete"} {
+		assert.Equal(t, expectedOperation, entries[i].operation)
+		assert.Equal(t, "kibana_sample_data_ecommerce", entries[i].index)
+	}
+	assert.Equal(t, "", entries[0].id)
+	assert.Equal(t, "323032352d30312d32392030383a30333a3530202b3030303020555443qqq3131", entries[1].id)
+	assert.Equal(t, map[string]any{"customer_first_name": "Rob"}, entries[1].document["doc"])
+}
+
+func Test_parseUpdateScript(t *testing.T) {
+	target := &ingest.MutationTarget{
+		IndexName: "tasks",
+		Table: &database_common.Table{
+			Name: "tasks",
+			Cols: map[string]*database_common.Column{
+				"attempts":     {Name: "attempts", Type: database_common.NewBaseType("Int64")},
+				"status":       {Name: "status", Type: database_common.NewBaseType("String")},
+				"task_runat":   {Name: "task_runat", Type: database_common.NewBaseType("DateTime64(3)")},
+				"@timestamp":   {Name: "@timestamp", Type: database_common.NewBaseType("DateTime64(3)")},
+				"task_enabled": {Name: "task_enabled", Type: database_common.NewBaseType("Bool")},
+			},
+			Config: &database_common.ChTableConfig{},
+		},
+	}
+
+	tests := []struct {
+		name                string
+		script              any
+		expectedAssignments []ingest.ColumnAssignment
+		expectedOp          string
+		expectedError       bool
+	}{
+		{
+			name:   "inline source",
+			script: `ctx._source.status = "running"; ctx._source.attempts += 1`,
+			expectedAssignments: []ingest.ColumnAssignment{
+				{ColumnName: "status", Expression: "?", Args: []any{"running"}},
+				{ColumnName: "attempts", Expression: `"attempts" + ?`, Args: []any{1.0}},
+			},
+			expectedOp: "index",
+		},
+		{
+			name: "params, nested fields and dates",
+			script: map[string]any{
+				"lang":   "painless",
+				"source": "ctx._source['task.runAt'] = params.runAt;\nctx._source.task.enabled = params['enabled']; ctx._source.status += ';done'",
+				"params": map[string]any{"runAt": "2025-01-17T16:02:28.305Z", "enabled": false},
+			},
+			expectedAssignments: []ingest.ColumnAssignment{
+				{ColumnName: "task_runat", Expression: "parseDateTime64BestEffort(?, 9)", Args: []any{"2025-01-17T16:02:28.305Z"}},
+				{ColumnName: "task_enabled", Expression: "?", Args: []any{false}},
+				{ColumnName: "status", Expression: `concat("status", ?)`, Args: []any{";done"}},
+			},
+			expectedOp: "index",
+		},
+		{
+			name:       "noop",
+			script:     map[string]any{"source": "ctx.op = 'noop'"},
+			expectedOp: "noop",
+		},
+		{
+			name:       "delete from params",
+			script:     map[string]any{"source": "ctx.op = params.op", "params": map[string]any{"op": "delete"}},
+			expectedOp: "delete",
+		},
+		{
+			name:   "increment and expressions of params",
+			script: map[string]any{"source": "ctx._source.attempts++; ctx._source.status = 'retry-' + params.retry", "params": map[string]any{"retry": 2.0}},
+			expectedAssignments: []ingest.ColumnAssignment{
+				{ColumnName: "attempts", Expression: `"attempts" + ?`, Args: []any{1.0}},
+				{ColumnName: "status", Expression: "?", Args: []any{"retry-2"}},
+			},
+			expectedOp: "index",
+		},
+		{
+			name:          "value from the document",
+			script:        "ctx._source.attempts = ctx._source.attempts * 2",
+			expectedError: true,
+		},
+		{
+			name:          "unknown field",
+			script:        "ctx._source.unknown = 1",
+			expectedError: true,
+		},
+		{
+			name:          "missing param",
+			script:        "ctx._source.attempts = params.attempts",
+			expectedError: true,
+		},
+		{
+			name:          "unsupported statement",
+			script:        "if (ctx._source.attempts > 5) { ctx.op = 'delete' }",
+			expectedError: true,
+		},
+		{
+			name:          "unsupported language",
+			script:        map[string]any{"lang": "expression", "source": "ctx._source.attempts = 1"},
+			expectedError: true,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(util.PrettyTestName(tt.name, i), func(t *testing.T) {
+			script, err := parseUpdateScript(tt.script, target)
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedAssignments, script.assignments)
+			assert.Equal(t, tt.expectedOp, script.op)
+		})
+	}
+
+	_, err := parseUpdateScript("ctx._source.tags.add('new')", target)
+	var endUserErr *end_user_errors.EndUserError
+	require.ErrorAs(t, err, &endUserErr)
+	assert.Equal(t, end_user_errors.ErrUnsupportedScript, endUserErr.ErrorType())
+}
+
+func TestSplitBulkPipeline(t *testing.T) {
+	ctx := context.Background()
+	defaultIndex := ""
+	var payload = `{"index":{"_index":"kibana_sample_data_ecommerce"}}
+{"message":"uses the default pipeline"}
+{"index":{"_index":"kibana_sample_data_ecommerce","pipeline":"other"}}
+{"message":"uses its own pipeline"}
+{"index":{"_index":"kibana_sample_data_ecommerce","pipeline":"_none"}}
+{"message":"skips pipelines"}`
+
+	bulk, err := types.ExpectNDJSON(types.ParseRequestBody(payload))
+	assert.NoError(t, err)
+
+	_, clickhouseBulkEntries, _, _, err := SplitBulk(ctx, &defaultIndex, "default", bulk, len(bulk), testTableResolver, &ingest.NoOpIndexNameRewriter{})
+	assert.NoError(t, err)
+
+	entries := clickhouseBulkEntries["kibana_sample_data_ecommerce"]
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "default", entries[0].pipeline)
+	assert.Equal(t, "other", entries[1].pipeline)
+	assert.Equal(t, "_none", entries[2].pipeline)
+}
+
+// newBulkTestProcessor returns a processor of given tables, which sends queries to sqlmock
+func newBulkTestProcessor(t *testing.T, indexConfig config.IndicesConfigs, tables *database_common.TableMap) (*ingest.IngestProcessor, sqlmock.Sqlmock) {
+	tableDiscovery := database_common.NewEmptyTableDiscovery()
+	tableDiscovery.TableMap = tables
+
+	conn, mock := util.InitSqlMockWithPrettySqlAndPrint(t, true)
+	t.Cleanup(func() { conn.Close() })
+	db := backend_connectors.NewClickHouseBackendConnectorWithConnection("", conn)
+	lowerer := ingest.NewSqlLowerer(persistence.NewStaticJSONDatabase())
+	ip := ingest.NewIngestProcessor(&config.QuesmaConfiguration{IndexConfig: indexConfig}, db, diag.NewPhoneHomeEmptyAgent(), tableDiscovery,
+		&schema.StaticRegistry{}, lowerer, table_resolver.NewDummyTableResolver(indexConfig, false))
+	ip.RegisterLowerer(lowerer, quesma_api.ClickHouseSQLBackend)
+	return ip, mock
+}
+
+func TestCreateConflict(t *testing.T) {
+	indexConfig := config.IndicesConfigs{"logs": {}, "legacy": {}}
+	tables := database_common.NewTableMap()
+	tables.Store("logs", &database_common.Table{
+		Name: "logs",
+		Cols: map[string]*database_common.Column{
+			"message":                     {Name: "message", Type: database_common.NewBaseType("String")},
+			common_table.DocumentIdColumn: {Name: common_table.DocumentIdColumn, Type: database_common.NewBaseType("String")},
+		},
+	})
+	tables.Store("legacy", &database_common.Table{
+		Name: "legacy",
+		Cols: map[string]*database_common.Column{"message": {Name: "message", Type: database_common.NewBaseType("String")}},
+	})
+	ip, mock := newBulkTestProcessor(t, indexConfig, tables)
+
+	mock.ExpectQuery(`SELECT DISTINCT "__quesma_id" FROM "logs" WHERE "__quesma_id" IN tuple('stored', 'new')`).
+		WillReturnRows(sqlmock.NewRows([]string{"__quesma_id"}).AddRow("stored"))
+
+	ctx := context.Background()
+	entries := []BulkRequestEntry{
+		{operation: "create", index: "logs", id: "stored"},
+		{operation: "create", index: "logs", id: "new"},
+		{operation: "create", index: "logs"},
+		{operation: "index", index: "logs", id: "other"},
+	}
+	existingIds, err := existingDocumentIds(ctx, ip, "logs", entries)
+	require.NoError(t, err)
+
+	response, conflict := createConflict(entries[0], existingIds)
+	assert.True(t, conflict)
+	assert.Equal(t, 409, response.Status)
+	assert.Equal(t, "version_conflict_engine_exception", response.Error.(elastic_query_dsl.Error).Type)
+	assert.Equal(t, "[stored]: version conflict, document already exists (current version [1])", response.Error.(elastic_query_dsl.Error).Reason)
+
+	_, conflict = createConflict(entries[1], existingIds)
+	assert.False(t, conflict)
+
+	_, conflict = createConflict(entries[2], existingIds)
+	assert.False(t, conflict, "documents without id never conflict")
+
+	existingIds, err = existingDocumentIds(ctx, ip, "legacy", []BulkRequestEntry{{operation: "create", index: "legacy", id: "new"}})
+	require.NoError(t, err)
+	assert.Empty(t, existingIds, "table without stored ids can't have documents with this id")
+
+	existingIds, err = existingDocumentIds(ctx, ip, "logs", entries[2:])
+	require.NoError(t, err)
+	assert.Empty(t, existingIds, "no query, when there are no ids to check")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpsertStoresDocumentId(t *testing.T) {
+	indexConfig := config.IndicesConfigs{"logs": {}}
+	tables := database_common.NewTableMap()
+	tables.Store("logs", &database_common.Table{
+		Name: "logs",
+		Cols: map[string]*database_common.Column{
+			"message":                     {Name: "message", Type: database_common.NewBaseType("String")},
+			common_table.DocumentIdColumn: {Name: common_table.DocumentIdColumn, Type: database_common.NewBaseType("String")},
+		},
+		Config: &database_common.ChTableConfig{},
+	})
+	ip, mock := newBulkTestProcessor(t, indexConfig, tables)
+
+	mock.ExpectQuery(`SELECT count(*) FROM "logs" WHERE "__quesma_id" = 'new'`).WillReturnRows(sqlmock.NewRows([]string{"count()"}).AddRow(0))
+	mock.ExpectExec(`INSERT INTO "logs" FORMAT JSONEachRow {"__quesma_id":"new","message":"hello"}`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	entry := BulkRequestEntry{operation: "update", index: "logs", id: "new",
+		document: types.JSON{"doc": map[string]any{"message": "hello"}, "doc_as_upsert": true}}
+	response := updateInClickhouse(context.Background(), ip, entry)
+	assert.Equal(t, 201, response.Status)
+	assert.Equal(t, "created", response.Result)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStrictMappingRejectsOnlyOffendingDocuments(t *testing.T) {
+	indexConfig := config.IndicesConfigs{"logs": {Dynamic: config.DynamicMappingStrict}}
+	tables := database_common.NewTableMap()
+	tables.Store("logs", &database_common.Table{
+		Name:   "logs",
+		Cols:   map[string]*database_common.Column{"message": {Name: "message", Type: database_common.NewBaseType("String")}},
+		Config: &database_common.ChTableConfig{},
+	})
+	ip, mock := newBulkTestProcessor(t, indexConfig, tables)
+
+	mock.ExpectExec(`INSERT INTO "logs" FORMAT JSONEachRow {"message":"a"}, {"message":"c"}`).WillReturnResult(sqlmock.NewResult(0, 2))
+
+	responses := make([]BulkItem, 3)
+	var entries []BulkRequestEntry
+	for i, document := range []string{`{"message":"a"}`, `{"message":"b","unknown":1}`, `{"message":"c"}`} {
+		entries = append(entries, BulkRequestEntry{operation: "index", index: "logs", document: types.MustJSON(document), response: &responses[i]})
+	}
+	insertToClickhouse(context.Background(), "logs", entries, diag.NewPhoneHomeEmptyAgent(), false, ip)
+
+	assert.Equal(t, 201, responses[0].Index.(BulkSingleResponse).Status)
+	assert.Equal(t, 400, responses[1].Index.(BulkSingleResponse).Status)
+	assert.Equal(t, "strict_dynamic_mapping_exception", responses[1].Index.(BulkSingleResponse).Error.(elastic_query_dsl.Error).Type)
+	assert.Equal(t, 201, responses[2].Index.(BulkSingleResponse).Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInsertRunsPipelines(t *testing.T) {
+	indexConfig := config.IndicesConfigs{"logs": {}}
+	tables := database_common.NewTableMap()
+	tables.Store("logs", &database_common.Table{
+		Name: "logs",
+		Cols: map[string]*database_common.Column{
+			"message":                     {Name: "message", Type: database_common.NewBaseType("String")},
+			"tag":                         {Name: "tag", Type: database_common.NewBaseType("String")},
+			common_table.DocumentIdColumn: {Name: common_table.DocumentIdColumn, Type: database_common.NewBaseType("String")},
+		},
+		Config: &database_common.ChTableConfig{},
+	})
+	ip, mock := newBulkTestProcessor(t, indexConfig, tables)
+	require.NoError(t, ip.GetPipelineStore().Put("tag", types.MustJSON(`{"processors":[{"set":{"field":"tag","value":"x"}}]}`)))
+	require.NoError(t, ip.GetPipelineStore().Put("drop", types.MustJSON(`{"processors":[{"drop":{}}]}`)))
+	require.NoError(t, ip.GetPipelineStore().Put("fingerprint", types.MustJSON(`{"processors":[{"set":{"field":"_id","value":"fp-{{message}}"}}]}`)))
+	require.NoError(t, ip.GetPipelineStore().Put("reroute", types.MustJSON(`{"processors":[{"set":{"field":"_index","value":"other"}}]}`)))
+
+	mock.ExpectExec(`INSERT INTO "logs" FORMAT JSONEachRow {"message":"a","tag":"x"}, {"message":"d"}, {"__quesma_id":"fp-e","message":"e"}`).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	responses := make([]BulkItem, 6)
+	var entries []BulkRequestEntry
+	for i, pipelineName := range []string{"tag", "drop", "missing", "", "fingerprint", "reroute"} {
+		document := types.JSON{"message": string(rune('a' + i))}
+		entries = append(entries, BulkRequestEntry{operation: "index", index: "logs", pipeline: pipelineName, document: document, response: &responses[i]})
+	}
+	insertToClickhouse(context.Background(), "logs", entries, diag.NewPhoneHomeEmptyAgent(), false, ip)
+
+	assert.Equal(t, 201, responses[0].Index.(BulkSingleResponse).Status)
+	assert.Equal(t, "noop", responses[1].Index.(BulkSingleResponse).Result)
+	assert.Equal(t, 400, responses[2].Index.(BulkSingleResponse).Status)
+	assert.Equal(t, 201, responses[3].Index.(BulkSingleResponse).Status)
+	assert.Equal(t, 201, responses[4].Index.(BulkSingleResponse).Status)
+	assert.Equal(t, "fp-e", responses[4].Index.(BulkSingleResponse).ID, "id set by the pipeline")
+	assert.Equal(t, 400, responses[5].Index.(BulkSingleResponse).Status)
+	assert.Contains(t, responses[5].Index.(BulkSingleResponse).Error.(types.JSON)["reason"], "changing [_index] to [other] isn't supported")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
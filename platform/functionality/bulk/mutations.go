@@ -0,0 +1,220 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/common_table"
+	"github.com/QuesmaOrg/quesma/platform/ingest"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/parsers/elastic_query_dsl"
+	"github.com/QuesmaOrg/quesma/platform/types"
+)
+
+// Documents ingested with `_id` have it stored in common_table.DocumentIdColumn. The other ids pointing to our rows
+// are the ones we generate in search hits, and those identify documents by timestamp (see `ids` query). That's why
+// before each update/delete we check that the id matches exactly one row - we'd rather fail a single bulk item
+// than modify unrelated documents.
+
+// documentCondition returns WHERE clause selecting the document with given id, or false if id can't point to any row.
+func documentCondition(ctx context.Context, target *ingest.MutationTarget, id string) (model.Expr, bool) {
+	if _, hasStoredIds := target.Table.Cols[common_table.DocumentIdColumn]; !hasStoredIds && !elastic_query_dsl.IsGeneratedDocumentId(id) {
+		return nil, false
+	}
+	cw := &elastic_query_dsl.ClickhouseQueryTranslator{Ctx: ctx, Table: target.Table}
+	query := cw.ParseIds([]string{id})
+	if !query.CanParse {
+		return nil, false
+	}
+	return query.WhereClause, true
+}
+
+// existingDocumentIds returns those ids of `create` operations of the bulk, which are stored already.
+// Earlier requests may still wait in the ingest buffer, so it's flushed - once for the whole bulk, and the ids
+// are looked up with a single query.
+func existingDocumentIds(ctx context.Context, ip *ingest.IngestProcessor, indexName string, entries []BulkRequestEntry) (map[string]bool, error) {
+	existingIds := make(map[string]bool)
+	var ids []string
+	for _, entry := range entries {
+		if entry.operation == "create" && entry.id != "" {
+			ids = append(ids, entry.id)
+		}
+	}
+	if len(ids) == 0 {
+		return existingIds, nil
+	}
+
+	if err := ip.FlushIngestBuffer(ctx, indexName); err != nil {
+		return nil, err
+	}
+	target, err := ip.ResolveMutationTarget(indexName)
+	if err != nil {
+		return existingIds, nil // e.g. the table doesn't exist yet, so there's nothing to conflict with
+	}
+	if _, hasStoredIds := target.Table.Cols[common_table.DocumentIdColumn]; !hasStoredIds {
+		return existingIds, nil
+	}
+	return ip.StoredDocumentIds(ctx, target, ids)
+}
+
+// createConflict checks if `create` would overwrite a document with the same id: either stored already,
+// or written by an earlier operation of the bulk. If so, it returns the response for the entry.
+func createConflict(entry BulkRequestEntry, existingIds map[string]bool) (BulkSingleResponse, bool) {
+	if entry.id == "" || !existingIds[entry.id] {
+		return BulkSingleResponse{}, false
+	}
+	return newVersionConflictResponse(entry), true
+}
+
+// countDocuments returns the number of rows matching the document id, together with the condition selecting them.
+func countDocuments(ctx context.Context, ip *ingest.IngestProcessor, target *ingest.MutationTarget, id string) (model.Expr, int64, error) {
+	condition, ok := documentCondition(ctx, target, id)
+	if !ok {
+		return nil, 0, nil
+	}
+	count, err := ip.CountDocuments(ctx, target, condition)
+	return condition, count, err
+}
+
+func deleteInClickhouse(ctx context.Context, ip *ingest.IngestProcessor, entry BulkRequestEntry) BulkSingleResponse {
+	target, err := ip.ResolveMutationTarget(entry.index)
+	if err != nil {
+		return newErrorResponse(entry, 400, "quesma_error", err.Error())
+	}
+	condition, count, err := countDocuments(ctx, ip, target, entry.id)
+	if err != nil {
+		return newErrorResponse(entry, 400, "quesma_error", err.Error())
+	}
+
+	switch count {
+	case 0:
+		return newResponse(entry, "not_found", 404)
+	case 1:
+		if err = ip.DeleteDocuments(ctx, target, condition); err != nil {
+			return newErrorResponse(entry, 400, "quesma_error", err.Error())
+		}
+		return newResponse(entry, "deleted", 200)
+	default:
+		return newAmbiguousIdResponse(entry, count)
+	}
+}
+
+func updateInClickhouse(ctx context.Context, ip *ingest.IngestProcessor, entry BulkRequestEntry) BulkSingleResponse {
+	doc, hasDoc := entry.document["doc"].(map[string]any)
+	script, hasScript := entry.document["script"]
+	if hasDoc == hasScript {
+		return newErrorResponse(entry, 400, "action_request_validation_exception", "Validation Failed: 1: exactly one of script or doc is required;")
+	}
+
+	target, err := ip.ResolveMutationTarget(entry.index)
+	if err != nil {
+		return newErrorResponse(entry, 400, "quesma_error", err.Error())
+	}
+	condition, count, err := countDocuments(ctx, ip, target, entry.id)
+	if err != nil {
+		return newErrorResponse(entry, 400, "quesma_error", err.Error())
+	}
+
+	if count == 0 {
+		// Upserts are regular inserts of a document with the id from the request
+		var upsert map[string]any
+		if docAsUpsert, _ := entry.document["doc_as_upsert"].(bool); docAsUpsert && hasDoc {
+			upsert = doc
+		} else if upsert, _ = entry.document["upsert"].(map[string]any); upsert == nil {
+			return newErrorResponse(entry, 404, "document_missing_exception", fmt.Sprintf("[%s]: document missing", entry.id))
+		}
+		upsert = types.JSON(upsert).Clone()
+		upsert[common_table.DocumentIdColumn] = entry.id
+		if _, err = ip.IngestWithPipelines(ctx, entry.index, []types.JSON{upsert}, []string{entry.pipeline}); errors.Is(err, ingest.ErrDocumentDropped) {
+			return newResponse(entry, "noop", 200)
+		} else if err != nil {
+			return ingestErrorResponse(entry, err)
+		}
+		return newResponse(entry, "created", 201)
+	}
+	if count > 1 {
+		return newAmbiguousIdResponse(entry, count)
+	}
+
+	var assignments []ingest.ColumnAssignment
+	if hasDoc {
+		if assignments, err = target.AssignmentsFromDocument(doc); err != nil {
+			return newErrorResponse(entry, 400, "illegal_argument_exception", err.Error())
+		}
+	} else {
+		parsedScript, err := parseUpdateScript(script, target)
+		if err != nil {
+			return newErrorResponse(entry, 400, "script_exception", err.Error())
+		}
+		switch parsedScript.op {
+		case "noop":
+			return newResponse(entry, "noop", 200)
+		case "delete":
+			if err = ip.DeleteDocuments(ctx, target, condition); err != nil {
+				return newErrorResponse(entry, 400, "quesma_error", err.Error())
+			}
+			return newResponse(entry, "deleted", 200)
+		}
+		assignments = parsedScript.assignments
+	}
+
+	if len(assignments) == 0 {
+		return newResponse(entry, "noop", 200)
+	}
+	if err = ip.UpdateDocuments(ctx, target, assignments, condition); err != nil {
+		return newErrorResponse(entry, 400, "quesma_error", err.Error())
+	}
+	return newResponse(entry, "updated", 200)
+}
+
+func newResponse(entry BulkRequestEntry, result string, status int) BulkSingleResponse {
+	return BulkSingleResponse{
+		ID:          entry.id,
+		Index:       entry.index,
+		PrimaryTerm: 1,
+		Shards: BulkShardsResponse{
+			Failed:     0,
+			Successful: 1,
+			Total:      1,
+		},
+		Result: result,
+		Status: status,
+		Type:   "_doc",
+	}
+}
+
+func newErrorResponse(entry BulkRequestEntry, status int, errorType, reason string) BulkSingleResponse {
+	return BulkSingleResponse{
+		ID:    entry.id,
+		Index: entry.index,
+		Shards: BulkShardsResponse{
+			Failed:     1,
+			Successful: 0,
+			Total:      1,
+		},
+		Status: status,
+		Type:   "_doc",
+		Error: elastic_query_dsl.Error{
+			RootCause: []elastic_query_dsl.RootCause{
+				{
+					Type:   errorType,
+					Reason: reason,
+				},
+			},
+			Type:   errorType,
+			Reason: reason,
+		},
+	}
+}
+
+func newVersionConflictResponse(entry BulkRequestEntry) BulkSingleResponse {
+	return newErrorResponse(entry, 409, "version_conflict_engine_exception",
+		fmt.Sprintf("[%s]: version conflict, document already exists (current version [1])", entry.id))
+}
+
+func newAmbiguousIdResponse(entry BulkRequestEntry, count int64) BulkSingleResponse {
+	return newErrorResponse(entry, 409, "quesma_error",
+		fmt.Sprintf("[%s]: document id matches %d documents with the same timestamp, refusing to modify them", entry.id, count))
+}
@@ -0,0 +1,213 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package bulk
+
+import (
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/end_user_errors"
+	"github.com/QuesmaOrg/quesma/platform/ingest"
+	"github.com/QuesmaOrg/quesma/platform/parsers/painful"
+)
+
+// We don't run update scripts on documents, we only translate the most common kind of them, which assign
+// constants or params to fields, to ALTER TABLE ... UPDATE assignments, e.g.:
+//
+//	ctx._source.counter += params.count; ctx._source['user.name'] = 'john'
+//
+// Additionally, `ctx.op = 'noop'` and `ctx.op = 'delete'` are supported. Scripts are parsed with painful,
+// and any other statement is rejected with end_user_errors.ErrUnsupportedScript.
+type updateScript struct {
+	assignments []ingest.ColumnAssignment
+	op          string // "index" (default), "noop" or "delete"
+}
+
+const (
+	ctxVariableName   = "ctx"
+	ctxSourceProperty = "_source"
+	ctxOpProperty     = "op"
+)
+
+// ParseUpdateScript is parseUpdateScript for other kinds of requests running update scripts, like _update_by_query.
+func ParseUpdateScript(script any, target *ingest.MutationTarget) (assignments []ingest.ColumnAssignment, op string, err error) {
+	parsed, err := parseUpdateScript(script, target)
+	if err != nil {
+		return nil, "", err
+	}
+	return parsed.assignments, parsed.op, nil
+}
+
+func parseUpdateScript(script any, target *ingest.MutationTarget) (*updateScript, error) {
+	var source string
+	params := make(map[string]any)
+
+	switch scriptTyped := script.(type) {
+	case string:
+		source = scriptTyped
+	case map[string]any:
+		if lang, ok := scriptTyped["lang"]; ok && lang != "painless" {
+			return nil, fmt.Errorf("unsupported script language: %v", lang)
+		}
+		if _, ok := scriptTyped["id"]; ok {
+			return nil, fmt.Errorf("stored scripts are not supported")
+		}
+		var ok bool
+		if source, ok = scriptTyped["source"].(string); !ok {
+			return nil, fmt.Errorf("script source is missing")
+		}
+		if paramsRaw, exists := scriptTyped["params"]; exists {
+			if params, ok = paramsRaw.(map[string]any); !ok {
+				return nil, fmt.Errorf("script params should be an object, got %T", paramsRaw)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid script: %v", script)
+	}
+
+	parsed, err := painful.ParsePainless(source)
+	if err != nil {
+		return nil, err
+	}
+	var statements []painful.Statement
+	if scriptExpr, ok := parsed.(*painful.ScriptExpr); ok {
+		statements = scriptExpr.Statements
+	} else {
+		statements = []painful.Statement{&painful.ExprStatement{Expr: parsed}}
+	}
+
+	env := &painful.Env{Params: painful.NormalizeParams(params)}
+	result := &updateScript{op: "index"}
+	for _, statement := range statements {
+		assignment, ok := statement.(*painful.AssignmentStatement)
+		if !ok {
+			return nil, unsupportedUpdateScript(source, "only assignments to fields of ctx._source and to ctx.op are supported")
+		}
+
+		if isCtxProperty(assignment.Target, ctxOpProperty) {
+			if assignment.Op != "=" {
+				return nil, unsupportedUpdateScript(source, "ctx.op can only be assigned with =")
+			}
+			op, err := updateScriptValue(source, assignment.Expr, env)
+			if err != nil {
+				return nil, err
+			}
+			switch op {
+			case "index", "noop", "delete":
+				result.op = op.(string)
+			default:
+				return nil, fmt.Errorf("unsupported ctx.op: %v", op)
+			}
+			continue
+		}
+
+		fieldName, ok := sourceFieldName(assignment.Target)
+		if !ok {
+			return nil, unsupportedUpdateScript(source, "only assignments to fields of ctx._source and to ctx.op are supported")
+		}
+		columnName, err := target.ColumnName(fieldName)
+		if err != nil {
+			return nil, err
+		}
+
+		operator, valueExpr := assignment.Op, assignment.Expr
+		if operator == "++" || operator == "--" {
+			operator, valueExpr = operator[:1]+"=", &painful.LiteralExpr{Value: 1}
+		}
+		value, err := updateScriptValue(source, valueExpr, env)
+		if err != nil {
+			return nil, err
+		}
+
+		columnAssignment := ingest.ColumnAssignment{ColumnName: columnName, Args: []any{value}}
+		switch {
+		case operator == "=":
+			if columnAssignment.Expression, err = target.ValueExpression(columnName, value); err != nil {
+				return nil, err
+			}
+		case operator == "+=" || operator == "-=" || operator == "*=":
+			switch value.(type) {
+			case float64:
+				columnAssignment.Expression = fmt.Sprintf(`"%s" %s ?`, columnName, operator[:1])
+			case string:
+				if operator != "+=" {
+					return nil, fmt.Errorf("operator %s can't be applied to a string: %s", operator, source)
+				}
+				columnAssignment.Expression = fmt.Sprintf(`concat("%s", ?)`, columnName)
+			default:
+				return nil, fmt.Errorf("operator %s can't be applied to %T: %s", operator, value, source)
+			}
+		default:
+			return nil, unsupportedUpdateScript(source, fmt.Sprintf("operator %s is not supported", operator))
+		}
+		result.assignments = append(result.assignments, columnAssignment)
+	}
+	return result, nil
+}
+
+func unsupportedUpdateScript(source, reason string) error {
+	return end_user_errors.ErrUnsupportedScript.New(fmt.Errorf("%s: %s", reason, source))
+}
+
+// isCtxProperty checks if the expression is `ctx.<name>` or `ctx['<name>']`
+func isCtxProperty(expr painful.Expr, name string) bool {
+	parent, property, ok := propertyOf(expr)
+	if !ok || property != name {
+		return false
+	}
+	variable, ok := parent.(*painful.VariableExpr)
+	return ok && variable.Name == ctxVariableName
+}
+
+// sourceFieldName returns the name of the field of `ctx._source.a.b`, `ctx._source['a.b']` or `ctx._source['a']['b']`
+func sourceFieldName(expr painful.Expr) (string, bool) {
+	parent, property, ok := propertyOf(expr)
+	if !ok {
+		return "", false
+	}
+	if isCtxProperty(parent, ctxSourceProperty) {
+		return property, true
+	}
+	parentName, ok := sourceFieldName(parent)
+	if !ok {
+		return "", false
+	}
+	return parentName + "." + property, true
+}
+
+// propertyOf splits `a.b` and `a['b']` into `a` and "b"
+func propertyOf(expr painful.Expr) (parent painful.Expr, property string, ok bool) {
+	switch exprTyped := expr.(type) {
+	case *painful.AccessorExpr:
+		return exprTyped.Expr, exprTyped.PropertyName, !exprTyped.NullSafe
+	case *painful.IndexExpr:
+		if literal, isLiteral := exprTyped.Index.(*painful.LiteralExpr); isLiteral {
+			if name, isString := literal.Value.(string); isString {
+				return exprTyped.Expr, name, true
+			}
+		}
+	}
+	return nil, "", false
+}
+
+// updateScriptValue evaluates the right-hand side of an assignment, which can use params, but not the document
+func updateScriptValue(source string, expr painful.Expr, env *painful.Env) (any, error) {
+	if name, ok := paramName(expr); ok {
+		if _, exists := env.Params[name]; !exists {
+			return nil, fmt.Errorf("script parameter %s is missing", name)
+		}
+	}
+	value, err := expr.Eval(env)
+	if err != nil {
+		return nil, unsupportedUpdateScript(source, fmt.Sprintf("only constants and params can be assigned (%v)", err))
+	}
+	return painful.JSONValue(value), nil
+}
+
+// paramName returns the name of the param of `params.x` or `params['x']`
+func paramName(expr painful.Expr) (string, bool) {
+	parent, property, ok := propertyOf(expr)
+	if !ok {
+		return "", false
+	}
+	variable, ok := parent.(*painful.VariableExpr)
+	return property, ok && variable.Name == painful.ParamsVariableName
+}
@@ -0,0 +1,300 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package by_query
+
+import (
+	"context"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/common_table"
+	"github.com/QuesmaOrg/quesma/platform/functionality/bulk"
+	"github.com/QuesmaOrg/quesma/platform/ingest"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// `_delete_by_query` and `_update_by_query` are executed as ClickHouse mutations (lightweight DELETE
+// and ALTER TABLE ... UPDATE), in batches of one table partition each. Elasticsearch batches by scroll pages,
+// we can't do that, as mutations can't be limited to a number of rows. When `max_docs` is lower than the number
+// of matching documents, the last batch picks documents by the lowest ids stored at ingest (`_id`), so tables
+// without stored ids can't be limited and documents ingested without `_id` are never picked.
+//
+// ClickHouse doesn't version rows, so there are never any version conflicts. We still validate
+// the `conflicts` parameter to reject the same requests as Elasticsearch does.
+//
+// Ref: https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-delete-by-query.html
+// Ref: https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-update-by-query.html
+
+type Operation int
+
+const (
+	DeleteByQuery Operation = iota
+	UpdateByQuery
+)
+
+func (o Operation) String() string {
+	if o == DeleteByQuery {
+		return "delete-by-query"
+	}
+	return "update-by-query"
+}
+
+// Action is the name of the operation in the tasks API
+func (o Operation) Action() string {
+	if o == DeleteByQuery {
+		return "indices:data/write/delete/byquery"
+	}
+	return "indices:data/write/update/byquery"
+}
+
+const (
+	ConflictsAbort   = "abort"
+	ConflictsProceed = "proceed"
+
+	unlimited = -1
+)
+
+type Request struct {
+	Operation         Operation
+	Conflicts         string
+	MaxDocs           int64
+	RequestsPerSecond float64
+	WaitForCompletion bool
+
+	// Script of an update request, translated by bulk.ParseUpdateScript.
+	// If there's no script, documents are only counted as updated, ClickHouse rows always hold their latest version.
+	ScriptOp    string
+	Assignments []ingest.ColumnAssignment
+}
+
+// ParseRequest reads parameters of the request, both from the URL and the body (URL takes precedence).
+// The query itself is translated separately, as it needs the schema of the index.
+func ParseRequest(operation Operation, target *ingest.MutationTarget, params url.Values, body types.JSON) (*Request, error) {
+	request := &Request{
+		Operation:         operation,
+		Conflicts:         ConflictsAbort,
+		MaxDocs:           unlimited,
+		RequestsPerSecond: unlimited,
+		WaitForCompletion: true,
+		ScriptOp:          "index",
+	}
+
+	if conflicts, ok := body["conflicts"].(string); ok {
+		request.Conflicts = conflicts
+	}
+	if params.Has("conflicts") {
+		request.Conflicts = params.Get("conflicts")
+	}
+	if request.Conflicts != ConflictsAbort && request.Conflicts != ConflictsProceed {
+		return nil, fmt.Errorf("conflicts may only be \"%s\" or \"%s\" but was [%s]", ConflictsProceed, ConflictsAbort, request.Conflicts)
+	}
+
+	if maxDocs, ok := body["max_docs"].(float64); ok {
+		request.MaxDocs = int64(maxDocs)
+	}
+	if params.Has("max_docs") {
+		maxDocs, err := strconv.ParseInt(params.Get("max_docs"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse [max_docs] value [%s]", params.Get("max_docs"))
+		}
+		request.MaxDocs = maxDocs
+	}
+	if request.MaxDocs < 0 && request.MaxDocs != unlimited {
+		return nil, fmt.Errorf("[max_docs] parameter cannot be negative, found [%d]", request.MaxDocs)
+	}
+
+	if params.Has("requests_per_second") {
+		requestsPerSecond, err := strconv.ParseFloat(params.Get("requests_per_second"), 64)
+		if err != nil || (requestsPerSecond <= 0 && requestsPerSecond != unlimited) {
+			return nil, fmt.Errorf("[requests_per_second] must be a float greater than 0. Use -1 to disable throttling. Found [%s]", params.Get("requests_per_second"))
+		}
+		request.RequestsPerSecond = requestsPerSecond
+	}
+
+	if params.Has("wait_for_completion") {
+		waitForCompletion, err := strconv.ParseBool(params.Get("wait_for_completion"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse [wait_for_completion] value [%s]", params.Get("wait_for_completion"))
+		}
+		request.WaitForCompletion = waitForCompletion
+	}
+
+	if script, hasScript := body["script"]; hasScript {
+		if operation != UpdateByQuery {
+			return nil, fmt.Errorf("request does not support [script]")
+		}
+		var err error
+		if request.Assignments, request.ScriptOp, err = bulk.ParseUpdateScript(script, target); err != nil {
+			return nil, err
+		}
+	}
+	return request, nil
+}
+
+// Status is the progress of the request, it's rendered both in the response and by the tasks API.
+type Status struct {
+	Total             int64
+	Updated           int64
+	Deleted           int64
+	Batches           int64
+	Noops             int64
+	ThrottledMillis   int64
+	RequestsPerSecond float64
+}
+
+func (s Status) Render() types.JSON {
+	return types.JSON{
+		"total":                  s.Total,
+		"updated":                s.Updated,
+		"created":                0,
+		"deleted":                s.Deleted,
+		"batches":                s.Batches,
+		"version_conflicts":      0,
+		"noops":                  s.Noops,
+		"retries":                types.JSON{"bulk": 0, "search": 0},
+		"throttled_millis":       s.ThrottledMillis,
+		"requests_per_second":    s.RequestsPerSecond,
+		"throttled_until_millis": 0,
+	}
+}
+
+// Run executes the request, calling progress after each batch. It returns the response body.
+func Run(ctx context.Context, ip *ingest.IngestProcessor, target *ingest.MutationTarget, condition model.Expr, request *Request, progress func(Status)) (types.JSON, error) {
+	startTime := time.Now()
+	status := Status{RequestsPerSecond: request.RequestsPerSecond}
+
+	partitions, err := ip.CountDocumentsByPartition(ctx, target, condition)
+	if err != nil {
+		return nil, err
+	}
+	// like Elasticsearch, we process at most max_docs documents, the rest is left untouched
+	batches := limitPartitions(partitions, request.MaxDocs)
+	for _, partition := range batches {
+		status.Total += partition.Count
+	}
+	if len(batches) > 0 && batches[len(batches)-1].limited {
+		if _, hasStoredIds := target.Table.Cols[common_table.DocumentIdColumn]; !hasStoredIds {
+			return nil, fmt.Errorf("query matches more documents than [max_docs] %d: only documents of tables storing `_id` can be limited", request.MaxDocs)
+		}
+	}
+	progress(status)
+
+	var failures []any
+	var previousBatchCount int64
+	var previousBatchTook time.Duration
+	for i, partition := range batches {
+		if i > 0 {
+			if err = throttle(ctx, request.RequestsPerSecond, previousBatchCount, previousBatchTook, &status); err != nil {
+				return nil, err
+			}
+		}
+
+		batchStartTime := time.Now()
+		batchCondition := ingest.InPartition(condition, partition.PartitionId)
+		if partition.limited {
+			// the limited batch is applied to the exact documents we count, picked by their ids
+			var ids []string
+			ids, err = ip.FirstDocumentIds(ctx, target, batchCondition, partition.Count)
+			if err == nil {
+				batchCondition = ingest.WithDocumentIds(batchCondition, ids)
+				status.Total -= partition.Count - int64(len(ids))
+				partition.Count = int64(len(ids))
+			}
+		}
+		if err == nil {
+			err = runBatch(ctx, ip, target, batchCondition, request, partition.Count, &status)
+		}
+		if err != nil {
+			logger.ErrorWithCtx(ctx).Msgf("%s failed on partition %s of %s: %v", request.Operation, partition.PartitionId, target.Table.Name, err)
+			failures = append(failures, types.JSON{
+				"index":  target.IndexName,
+				"cause":  types.JSON{"type": "quesma_error", "reason": err.Error()},
+				"status": 500,
+			})
+			break
+		}
+		previousBatchCount, previousBatchTook = partition.Count, time.Since(batchStartTime)
+		status.Batches++
+		progress(status)
+	}
+
+	response := status.Render()
+	response["took"] = time.Since(startTime).Milliseconds()
+	response["timed_out"] = false
+	if failures == nil {
+		failures = []any{}
+	}
+	response["failures"] = failures
+	return response, nil
+}
+
+type partitionBatch struct {
+	ingest.PartitionCount
+	limited bool // only some of the matching rows of the partition are processed
+}
+
+// limitPartitions returns batches with at most maxDocs documents in total
+func limitPartitions(partitions []ingest.PartitionCount, maxDocs int64) []partitionBatch {
+	var batches []partitionBatch
+	for _, partition := range partitions {
+		batch := partitionBatch{PartitionCount: partition}
+		if maxDocs == unlimited {
+			batches = append(batches, batch)
+			continue
+		}
+		if maxDocs == 0 {
+			break
+		}
+		if partition.Count > maxDocs {
+			batch.Count, batch.limited = maxDocs, true
+		}
+		batches = append(batches, batch)
+		maxDocs -= batch.Count
+	}
+	return batches
+}
+
+func runBatch(ctx context.Context, ip *ingest.IngestProcessor, target *ingest.MutationTarget, condition model.Expr, request *Request, count int64, status *Status) error {
+	if count == 0 {
+		return nil // e.g. none of the documents of a limited batch has an id
+	}
+	if request.Operation == DeleteByQuery || request.ScriptOp == "delete" {
+		if err := ip.DeleteDocuments(ctx, target, condition); err != nil {
+			return err
+		}
+		status.Deleted += count
+		return nil
+	}
+
+	if request.ScriptOp == "noop" {
+		status.Noops += count
+		return nil
+	}
+	if err := ip.UpdateDocuments(ctx, target, request.Assignments, condition); err != nil {
+		return err
+	}
+	status.Updated += count
+	return nil
+}
+
+// throttle delays the next batch, so that the previous one took 1/requestsPerSecond seconds per document, the same as Elasticsearch does.
+func throttle(ctx context.Context, requestsPerSecond float64, count int64, took time.Duration, status *Status) error {
+	if requestsPerSecond == unlimited {
+		return nil
+	}
+	wait := time.Duration(float64(count)/requestsPerSecond*float64(time.Second)) - took
+	if wait <= 0 {
+		return nil
+	}
+
+	select {
+	case <-time.After(wait):
+		status.ThrottledMillis += wait.Milliseconds()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
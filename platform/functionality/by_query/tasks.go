@@ -0,0 +1,166 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package by_query
+
+import (
+	"context"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Requests with `wait_for_completion=false` run in the background as tasks, which clients poll with `GET _tasks/<task_id>`.
+// Elasticsearch task ids are `<node_id>:<number>`, ours use TaskNodeId, so we can tell them apart from tasks
+// of the Elasticsearch cluster.
+//
+// Unlike Elasticsearch, which stores results in the `.tasks` index, we keep them in memory for taskResultsRetention.
+
+const (
+	TaskNodeId           = "quesma"
+	taskResultsRetention = 24 * time.Hour
+)
+
+type Task struct {
+	Id          int64
+	Action      string
+	Description string
+	StartTime   time.Time
+
+	mu        sync.Mutex
+	status    Status
+	completed bool
+	endTime   time.Time
+	response  types.JSON
+	err       error
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+func (t *Task) TaskId() string {
+	return fmt.Sprintf("%s:%d", TaskNodeId, t.Id)
+}
+
+func (t *Task) Cancel() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.completed {
+		t.cancelled = true
+		t.cancel()
+	}
+}
+
+// Render returns the task in the format of `GET _tasks/<task_id>` response
+func (t *Task) Render() types.JSON {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	runningTime := time.Since(t.StartTime)
+	if t.completed {
+		runningTime = t.endTime.Sub(t.StartTime)
+	}
+
+	result := types.JSON{
+		"completed": t.completed,
+		"task": types.JSON{
+			"node":                  TaskNodeId,
+			"id":                    t.Id,
+			"type":                  "transport",
+			"action":                t.Action,
+			"status":                t.status.Render(),
+			"description":           t.Description,
+			"start_time_in_millis":  t.StartTime.UnixMilli(),
+			"running_time_in_nanos": runningTime.Nanoseconds(),
+			"cancellable":           true,
+			"cancelled":             t.cancelled,
+			"headers":               types.JSON{},
+		},
+	}
+	if t.err != nil {
+		result["error"] = types.JSON{"type": "quesma_error", "reason": t.err.Error()}
+	} else if t.response != nil {
+		result["response"] = t.response
+	}
+	return result
+}
+
+func (t *Task) setStatus(status Status) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status = status
+}
+
+func (t *Task) complete(response types.JSON, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.completed = true
+	t.endTime = time.Now()
+	t.response, t.err = response, err
+}
+
+type TaskRegistry struct {
+	mu     sync.Mutex
+	tasks  map[int64]*Task
+	lastId int64
+}
+
+func NewTaskRegistry() *TaskRegistry {
+	return &TaskRegistry{tasks: make(map[int64]*Task)}
+}
+
+// Start runs the function in the background. It gets a context that's cancelled when the task is,
+// and reports its progress with the status callback.
+func (r *TaskRegistry) Start(action, description string, run func(ctx context.Context, progress func(Status)) (types.JSON, error)) *Task {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r.mu.Lock()
+	r.evictCompleted()
+	r.lastId++
+	task := &Task{Id: r.lastId, Action: action, Description: description, StartTime: time.Now(), cancel: cancel}
+	r.tasks[task.Id] = task
+	r.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		response, err := run(ctx, task.setStatus)
+		task.complete(response, err)
+	}()
+	return task
+}
+
+// Get returns a task by its id (`quesma:<number>`)
+func (r *TaskRegistry) Get(taskId string) (*Task, bool) {
+	id, ok := ParseTaskId(taskId)
+	if !ok {
+		return nil, false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	task, ok := r.tasks[id]
+	return task, ok
+}
+
+// evictCompleted must be called with r.mu held
+func (r *TaskRegistry) evictCompleted() {
+	for id, task := range r.tasks {
+		task.mu.Lock()
+		expired := task.completed && time.Since(task.endTime) > taskResultsRetention
+		task.mu.Unlock()
+		if expired {
+			delete(r.tasks, id)
+		}
+	}
+}
+
+// ParseTaskId returns the number of our task, ok is false if it's not a Quesma task id
+func ParseTaskId(taskId string) (id int64, ok bool) {
+	number, found := strings.CutPrefix(taskId, TaskNodeId+":")
+	if !found {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(number, 10, 64)
+	return id, err == nil
+}
@@ -12,13 +12,13 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/v2/core/diag"
 )
 
-func Write(ctx context.Context, tableName *string, body types.JSON, ip *ingest.IngestProcessor, ingestStatsEnabled bool, phoneHomeAgent diag.PhoneHomeClient, registry table_resolver.TableResolver, elasticsearchConnector *backend_connectors.ElasticsearchBackendConnector) (bulk.BulkItem, error) {
+func Write(ctx context.Context, tableName *string, pipeline string, body types.JSON, ip *ingest.IngestProcessor, ingestStatsEnabled bool, phoneHomeAgent diag.PhoneHomeClient, registry table_resolver.TableResolver, elasticsearchConnector *backend_connectors.ElasticsearchBackendConnector) (bulk.BulkItem, error) {
 	// Translate single doc write to a bulk request, reusing exiting logic of bulk ingest
 	payload := []types.JSON{
 		map[string]interface{}{"index": map[string]interface{}{"_index": *tableName}},
 		body,
 	}
-	results, err := bulk.Write(ctx, tableName, payload, ip, ingestStatsEnabled, elasticsearchConnector, phoneHomeAgent, registry)
+	results, err := bulk.Write(ctx, tableName, pipeline, payload, ip, ingestStatsEnabled, elasticsearchConnector, phoneHomeAgent, registry)
 
 	if err != nil {
 		return bulk.BulkItem{}, err
@@ -0,0 +1,176 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package doc
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// SourceFilter selects fields of `_source` returned by document APIs, like `_source`, `_source_includes`
+// and `_source_excludes` parameters of Elasticsearch. Patterns may contain `*` wildcards and match full,
+// dot-separated paths of fields. A pattern matching an object matches all of its fields.
+// Ref: https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-get.html#get-source-filtering
+type SourceFilter struct {
+	Disabled bool // `_source=false`, no source is returned at all
+	Includes []string
+	Excludes []string
+}
+
+// ParseSourceFilterParams reads the source filter from URL parameters.
+func ParseSourceFilterParams(params url.Values) SourceFilter {
+	var filter SourceFilter
+	if params.Has("_source") {
+		switch source := params.Get("_source"); source {
+		case "true":
+		case "false":
+			filter.Disabled = true
+		default:
+			filter.Includes = splitPatterns(source)
+		}
+	}
+	if params.Has("_source_includes") {
+		filter.Disabled, filter.Includes = false, splitPatterns(params.Get("_source_includes"))
+	}
+	if params.Has("_source_excludes") {
+		filter.Disabled, filter.Excludes = false, splitPatterns(params.Get("_source_excludes"))
+	}
+	return filter
+}
+
+// ParseSourceFilter reads the source filter from a request body, e.g. of a single `_mget` document:
+// a boolean, a pattern, a list of patterns or an object with `includes` and `excludes` lists.
+func ParseSourceFilter(source any) (SourceFilter, error) {
+	switch sourceTyped := source.(type) {
+	case bool:
+		return SourceFilter{Disabled: !sourceTyped}, nil
+	case string:
+		return SourceFilter{Includes: splitPatterns(sourceTyped)}, nil
+	case []any:
+		includes, err := patternList(sourceTyped)
+		return SourceFilter{Includes: includes}, err
+	case map[string]any:
+		var filter SourceFilter
+		var err error
+		for key, value := range sourceTyped {
+			var patterns []string
+			switch valueTyped := value.(type) {
+			case string:
+				patterns = splitPatterns(valueTyped)
+			case []any:
+				if patterns, err = patternList(valueTyped); err != nil {
+					return SourceFilter{}, err
+				}
+			default:
+				return SourceFilter{}, fmt.Errorf("[_source] %s should be a string or an array of strings, got %T", key, value)
+			}
+			switch key {
+			case "includes", "include":
+				filter.Includes = patterns
+			case "excludes", "exclude":
+				filter.Excludes = patterns
+			default:
+				return SourceFilter{}, fmt.Errorf("unknown key for a [_source] object: %s", key)
+			}
+		}
+		return filter, nil
+	default:
+		return SourceFilter{}, fmt.Errorf("[_source] should be a boolean, a string, an array or an object, got %T", source)
+	}
+}
+
+// Merge returns the filter, but with the fields that are not set taken from defaults (e.g. URL parameters of `_mget`).
+func (f SourceFilter) Merge(defaults SourceFilter) SourceFilter {
+	if f.Disabled || len(f.Includes) > 0 || len(f.Excludes) > 0 {
+		return f
+	}
+	return defaults
+}
+
+// Apply returns filtered copy of the source, or nil if the source is disabled.
+func (f SourceFilter) Apply(source map[string]any) map[string]any {
+	if f.Disabled {
+		return nil
+	}
+	if len(f.Includes) == 0 && len(f.Excludes) == 0 {
+		return source
+	}
+	return filterObject(source, "", compilePatterns(f.Includes), compilePatterns(f.Excludes), len(f.Includes) == 0)
+}
+
+func filterObject(object map[string]any, prefix string, includes, excludes []*regexp.Regexp, included bool) map[string]any {
+	result := make(map[string]any)
+	for key, value := range object {
+		path := prefix + key
+		if matchesAny(excludes, path) {
+			continue
+		}
+		fieldIncluded := included || matchesAny(includes, path)
+
+		if nested, isObject := value.(map[string]any); isObject {
+			if filtered := filterObject(nested, path+".", includes, excludes, fieldIncluded); len(filtered) > 0 || (fieldIncluded && len(nested) == 0) {
+				result[key] = filtered
+			}
+			continue
+		}
+		if fieldIncluded {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// matchesAny checks the path and all its parents, as source may contain both objects and dotted keys, e.g. {"host.name": "a"}
+func matchesAny(patterns []*regexp.Regexp, path string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(path) {
+			return true
+		}
+		for i := strings.IndexByte(path, '.'); i >= 0; i = nextDot(path, i) {
+			if pattern.MatchString(path[:i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func nextDot(path string, previous int) int {
+	if next := strings.IndexByte(path[previous+1:], '.'); next >= 0 {
+		return previous + 1 + next
+	}
+	return -1
+}
+
+func compilePatterns(patterns []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		quoted := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+		compiled = append(compiled, regexp.MustCompile("^"+quoted+"$"))
+	}
+	return compiled
+}
+
+func splitPatterns(patterns string) []string {
+	var result []string
+	for _, pattern := range strings.Split(patterns, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			result = append(result, pattern)
+		}
+	}
+	return result
+}
+
+func patternList(patterns []any) ([]string, error) {
+	result := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		patternAsString, ok := pattern.(string)
+		if !ok {
+			return nil, fmt.Errorf("[_source] patterns should be strings, got %T", pattern)
+		}
+		result = append(result, patternAsString)
+	}
+	return result, nil
+}
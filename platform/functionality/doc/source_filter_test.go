@@ -0,0 +1,56 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package doc
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"testing"
+)
+
+func TestSourceFilter(t *testing.T) {
+	source := map[string]any{
+		"message": "hello",
+		"host":    map[string]any{"name": "h1", "os": map[string]any{"name": "linux", "version": "6.1"}},
+		"user.id": 7.0,
+		"tags":    []any{"a", "b"},
+	}
+
+	testcases := []struct {
+		name     string
+		filter   SourceFilter
+		expected map[string]any
+	}{
+		{"no filter", SourceFilter{}, source},
+		{"disabled", SourceFilter{Disabled: true}, nil},
+		{"include field", SourceFilter{Includes: []string{"message"}}, map[string]any{"message": "hello"}},
+		{"include object", SourceFilter{Includes: []string{"host.os"}}, map[string]any{"host": map[string]any{"os": map[string]any{"name": "linux", "version": "6.1"}}}},
+		{"include wildcard", SourceFilter{Includes: []string{"*.name"}}, map[string]any{"host": map[string]any{"name": "h1", "os": map[string]any{"name": "linux"}}}},
+		{"include dotted key by prefix", SourceFilter{Includes: []string{"user"}}, map[string]any{"user.id": 7.0}},
+		{"exclude", SourceFilter{Excludes: []string{"host.os", "tags"}}, map[string]any{"message": "hello", "host": map[string]any{"name": "h1"}, "user.id": 7.0}},
+		{"include and exclude", SourceFilter{Includes: []string{"host"}, Excludes: []string{"host.os.version"}}, map[string]any{"host": map[string]any{"name": "h1", "os": map[string]any{"name": "linux"}}}},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.filter.Apply(source))
+		})
+	}
+}
+
+func TestParseSourceFilter(t *testing.T) {
+	assert.Equal(t, SourceFilter{Disabled: true}, ParseSourceFilterParams(url.Values{"_source": {"false"}}))
+	assert.Equal(t, SourceFilter{Includes: []string{"a", "b.*"}}, ParseSourceFilterParams(url.Values{"_source": {"a, b.*"}}))
+	assert.Equal(t, SourceFilter{Includes: []string{"a"}, Excludes: []string{"b"}}, ParseSourceFilterParams(url.Values{"_source_includes": {"a"}, "_source_excludes": {"b"}}))
+
+	filter, err := ParseSourceFilter(map[string]any{"includes": []any{"a"}, "excludes": "b,c"})
+	require.NoError(t, err)
+	assert.Equal(t, SourceFilter{Includes: []string{"a"}, Excludes: []string{"b", "c"}}, filter)
+
+	filter, err = ParseSourceFilter(false)
+	require.NoError(t, err)
+	assert.True(t, filter.Disabled)
+
+	_, err = ParseSourceFilter(map[string]any{"fields": []any{"a"}})
+	assert.Error(t, err)
+}
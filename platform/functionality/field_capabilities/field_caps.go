@@ -144,6 +144,30 @@ func asElasticType(t schema.QuesmaType) string {
 		return elasticsearch_field_types.FieldTypeInteger
 	case schema.QuesmaTypeMap.Name:
 		return elasticsearch_field_types.FieldTypeObject
+	case schema.QuesmaTypeFlattened.Name:
+		return elasticsearch_field_types.FieldTypeFlattened
+	case schema.QuesmaTypeWildcard.Name:
+		return elasticsearch_field_types.FieldTypeWildcard
+	case schema.QuesmaTypeVersion.Name:
+		return elasticsearch_field_types.FieldTypeVersion
+	case schema.QuesmaTypeScaledFloat.Name:
+		return elasticsearch_field_types.FieldTypeScaledFloat
+	case schema.QuesmaTypeConstantKeyword.Name:
+		return elasticsearch_field_types.FieldTypeConstantKeyword
+	case schema.QuesmaTypeMatchOnlyText.Name:
+		return elasticsearch_field_types.FieldTypeMatchOnlyText
+	case schema.QuesmaTypeIntegerRange.Name:
+		return elasticsearch_field_types.FieldTypeIntegerRange
+	case schema.QuesmaTypeLongRange.Name:
+		return elasticsearch_field_types.FieldTypeLongRange
+	case schema.QuesmaTypeFloatRange.Name:
+		return elasticsearch_field_types.FieldTypeFloatRange
+	case schema.QuesmaTypeDoubleRange.Name:
+		return elasticsearch_field_types.FieldTypeDoubleRange
+	case schema.QuesmaTypeDateRange.Name:
+		return elasticsearch_field_types.FieldTypeDateRange
+	case schema.QuesmaTypeIpRange.Name:
+		return elasticsearch_field_types.FieldTypeIpRange
 	default:
 		return elasticsearch_field_types.FieldTypeText
 	}
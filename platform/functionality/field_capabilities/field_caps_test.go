@@ -176,6 +176,75 @@ func TestFieldCapsWithAliases(t *testing.T) {
 	assert.Empty(t, difference2)
 }
 
+func TestFieldCapsSpecializedTypes(t *testing.T) {
+	expected := []byte(`{
+  "fields": {
+    "labels": {
+      "flattened": {
+        "aggregatable": true,
+        "indices": [
+          "logs-generic-default"
+        ],
+        "metadata_field": false,
+        "searchable": true,
+        "type": "flattened"
+      }
+    },
+    "release": {
+      "version": {
+        "aggregatable": true,
+        "indices": [
+          "logs-generic-default"
+        ],
+        "metadata_field": false,
+        "searchable": true,
+        "type": "version"
+      }
+    },
+    "price": {
+      "scaled_float": {
+        "aggregatable": true,
+        "indices": [
+          "logs-generic-default"
+        ],
+        "metadata_field": false,
+        "searchable": true,
+        "type": "scaled_float"
+      }
+    },
+    "validity": {
+      "date_range": {
+        "aggregatable": false,
+        "indices": [
+          "logs-generic-default"
+        ],
+        "metadata_field": false,
+        "searchable": true,
+        "type": "date_range"
+      }
+    }
+  },
+  "indices": [
+    "logs-generic-default"
+  ]
+}`)
+	resp, err := handleFieldCapsIndex(
+		map[string]config.IndexConfiguration{"logs-generic-default": {QueryTarget: []string{config.ClickhouseTarget}, IngestTarget: []string{config.ClickhouseTarget}}}, &schema.StaticRegistry{
+			Tables: map[schema.IndexName]schema.Schema{
+				"logs-generic-default": {
+					Fields: map[schema.FieldName]schema.Field{
+						"labels":   {PropertyName: "labels", InternalPropertyName: "labels", Type: schema.QuesmaTypeFlattened},
+						"release":  {PropertyName: "release", InternalPropertyName: "release", Type: schema.QuesmaTypeVersion},
+						"price":    {PropertyName: "price", InternalPropertyName: "price", Type: schema.QuesmaTypeScaledFloat, ScalingFactor: 100},
+						"validity": {PropertyName: "validity", InternalPropertyName: "validity", Type: schema.QuesmaTypeDateRange},
+					},
+				},
+			},
+		}, []string{"logs-generic-default"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(resp))
+}
+
 func TestFieldCapsMultipleIndexes(t *testing.T) {
 	tableMap := database_common.NewTableMap()
 	tableMap.Store("logs-1", &database_common.Table{
@@ -0,0 +1,418 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package index_templates
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/persistence"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Elasticsearch indexes keeping template definitions.
+const (
+	IndexTemplatesElasticIndexName     = "quesma_index_templates"
+	ComponentTemplatesElasticIndexName = "quesma_component_templates"
+)
+
+// InUseError is returned when a component template, which is used by index templates, is deleted.
+type InUseError struct {
+	ComponentTemplate string
+	IndexTemplates    []string
+}
+
+func (e *InUseError) Error() string {
+	return fmt.Sprintf("component templates [%s] cannot be removed as they are still in use by index templates [%s]", e.ComponentTemplate, strings.Join(e.IndexTemplates, ", "))
+}
+
+// Store keeps index and component templates in JSONDatabases. All templates are cached, as new indexes are
+// matched against all of them.
+type Store struct {
+	indexTemplatesDb     persistence.JSONDatabase
+	componentTemplatesDb persistence.JSONDatabase
+
+	mutex              sync.Mutex
+	loaded             bool
+	indexTemplates     map[string]*IndexTemplate
+	componentTemplates map[string]*ComponentTemplate
+	listeners          []func()
+}
+
+func NewStore(indexTemplatesDb, componentTemplatesDb persistence.JSONDatabase) *Store {
+	return &Store{indexTemplatesDb: indexTemplatesDb, componentTemplatesDb: componentTemplatesDb}
+}
+
+// AddChangeListener registers a function called after templates change, e.g. to forget decisions made with the old ones.
+func (s *Store) AddChangeListener(listener func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+func (s *Store) notifyListeners() {
+	s.mutex.Lock()
+	listeners := slices.Clone(s.listeners)
+	s.mutex.Unlock()
+
+	for _, listener := range listeners {
+		listener()
+	}
+}
+
+// load reads all templates, it has to be called with the mutex held
+func (s *Store) load() error {
+	if s.loaded {
+		return nil
+	}
+	indexTemplates := make(map[string]*IndexTemplate)
+	componentTemplates := make(map[string]*ComponentTemplate)
+
+	err := loadAll(s.componentTemplatesDb, func(name string, definition types.JSON) error {
+		template, err := ParseComponentTemplate(name, definition)
+		if err == nil {
+			componentTemplates[name] = template
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	err = loadAll(s.indexTemplatesDb, func(name string, definition types.JSON) error {
+		template, err := ParseIndexTemplate(name, definition)
+		if err == nil {
+			indexTemplates[name] = template
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.indexTemplates = indexTemplates
+	s.componentTemplates = componentTemplates
+	s.loaded = true
+	return nil
+}
+
+func loadAll(db persistence.JSONDatabase, parse func(name string, definition types.JSON) error) error {
+	names, err := db.List()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		data, found, err := db.Get(name)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		var definition types.JSON
+		if err = json.Unmarshal([]byte(data), &definition); err != nil {
+			return fmt.Errorf("template [%s] is corrupted: %w", name, err)
+		}
+		if err = parse(name, definition); err != nil {
+			// a template, which can't be parsed anymore, shouldn't make the other ones unusable
+			logger.Warn().Msgf("skipping stored template [%s]: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func put(db persistence.JSONDatabase, name string, definition types.JSON) error {
+	data, err := json.Marshal(definition)
+	if err != nil {
+		return err
+	}
+	return db.Put(name, string(data))
+}
+
+// PutIndexTemplate validates and stores the index template, replacing the existing one. Like Elasticsearch,
+// it rejects templates with missing component templates, or with the same priority as an overlapping template.
+func (s *Store) PutIndexTemplate(name string, definition types.JSON) error {
+	template, err := ParseIndexTemplate(name, definition)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	err = s.putIndexTemplate(template)
+	s.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.notifyListeners()
+	return nil
+}
+
+func (s *Store) putIndexTemplate(template *IndexTemplate) error {
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, componentName := range template.ComposedOf {
+		if _, ok := s.componentTemplates[componentName]; !ok {
+			missing = append(missing, componentName)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("index template [%s] specifies component templates %v that do not exist", template.Name, missing)
+	}
+	if _, err := s.compose(template); err != nil {
+		return err
+	}
+	if err := s.checkTableNames(template); err != nil {
+		return err
+	}
+
+	for _, other := range s.sortedIndexTemplates() {
+		if other.Name != template.Name && other.Priority == template.Priority && other.overlaps(*template) {
+			return fmt.Errorf("index template [%s] has index patterns %v matching patterns from existing templates [%s] with patterns (%s => %v) that have the same priority [%d], multiple index templates may not match during index creation, please use a different priority",
+				template.Name, template.IndexPatterns, other.Name, other.Name, other.IndexPatterns, template.Priority)
+		}
+	}
+
+	if err := put(s.indexTemplatesDb, template.Name, template.Definition); err != nil {
+		return err
+	}
+	s.indexTemplates[template.Name] = template
+	return nil
+}
+
+// PutComponentTemplate validates and stores the component template, replacing the existing one.
+func (s *Store) PutComponentTemplate(name string, definition types.JSON) error {
+	template, err := ParseComponentTemplate(name, definition)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	err = s.putComponentTemplate(template)
+	s.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.notifyListeners()
+	return nil
+}
+
+func (s *Store) putComponentTemplate(template *ComponentTemplate) error {
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	// the new version has to compose with index templates using it
+	previous, existed := s.componentTemplates[template.Name]
+	s.componentTemplates[template.Name] = template
+	for _, indexTemplate := range s.sortedIndexTemplates() {
+		if !slices.Contains(indexTemplate.ComposedOf, template.Name) {
+			continue
+		}
+		if _, err := s.compose(indexTemplate); err != nil {
+			if existed {
+				s.componentTemplates[template.Name] = previous
+			} else {
+				delete(s.componentTemplates, template.Name)
+			}
+			return err
+		}
+	}
+	if err := s.checkTableNames(nil); err != nil {
+		if existed {
+			s.componentTemplates[template.Name] = previous
+		} else {
+			delete(s.componentTemplates, template.Name)
+		}
+		return err
+	}
+
+	if err := put(s.componentTemplatesDb, template.Name, template.Definition); err != nil {
+		if existed {
+			s.componentTemplates[template.Name] = previous
+		} else {
+			delete(s.componentTemplates, template.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+// IndexTemplates returns all index templates sorted by name.
+func (s *Store) IndexTemplates() ([]*IndexTemplate, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s.sortedIndexTemplates(), nil
+}
+
+// ComponentTemplates returns all component templates sorted by name.
+func (s *Store) ComponentTemplates() ([]*ComponentTemplate, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	result := make([]*ComponentTemplate, 0, len(s.componentTemplates))
+	for _, template := range s.componentTemplates {
+		result = append(result, template)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+func (s *Store) sortedIndexTemplates() []*IndexTemplate {
+	result := make([]*IndexTemplate, 0, len(s.indexTemplates))
+	for _, template := range s.indexTemplates {
+		result = append(result, template)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+func (s *Store) DeleteIndexTemplate(name string) (found bool, err error) {
+	s.mutex.Lock()
+	if err = s.load(); err != nil {
+		s.mutex.Unlock()
+		return false, err
+	}
+	delete(s.indexTemplates, name)
+	found, err = s.indexTemplatesDb.Delete(name)
+	s.mutex.Unlock()
+
+	if found {
+		s.notifyListeners()
+	}
+	return found, err
+}
+
+// DeleteComponentTemplate deletes the component template, unless it's used by index templates.
+func (s *Store) DeleteComponentTemplate(name string) (found bool, err error) {
+	s.mutex.Lock()
+	if err = s.load(); err != nil {
+		s.mutex.Unlock()
+		return false, err
+	}
+	var usedBy []string
+	for _, indexTemplate := range s.sortedIndexTemplates() {
+		if slices.Contains(indexTemplate.ComposedOf, name) {
+			usedBy = append(usedBy, indexTemplate.Name)
+		}
+	}
+	if len(usedBy) > 0 {
+		s.mutex.Unlock()
+		return true, &InUseError{ComponentTemplate: name, IndexTemplates: usedBy}
+	}
+	delete(s.componentTemplates, name)
+	found, err = s.componentTemplatesDb.Delete(name)
+	s.mutex.Unlock()
+
+	if found {
+		s.notifyListeners()
+	}
+	return found, err
+}
+
+// Resolve returns the template of a new index: the matching index template with the highest priority,
+// composed with its component templates. Templates with the same priority are chosen by name.
+func (s *Store) Resolve(indexName string) (Resolved, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.load(); err != nil {
+		logger.Error().Msgf("can't load index templates: %v", err)
+		return Resolved{}, false
+	}
+
+	var best *IndexTemplate
+	var matching []*IndexTemplate
+	for _, template := range s.sortedIndexTemplates() {
+		if !template.Matches(indexName) {
+			continue
+		}
+		matching = append(matching, template)
+		if best == nil || template.Priority > best.Priority {
+			best = template
+		}
+	}
+	if best == nil {
+		return Resolved{}, false
+	}
+	resolved, err := s.compose(best)
+	if err != nil {
+		logger.Error().Msgf("can't apply index template [%s] to index [%s]: %v", best.Name, indexName, err)
+		return Resolved{}, false
+	}
+	for _, template := range matching {
+		if template != best {
+			resolved.Overlapping = append(resolved.Overlapping, template)
+		}
+	}
+	return resolved, true
+}
+
+// compose merges component templates in order, and the index template itself on top of them
+func (s *Store) compose(indexTemplate *IndexTemplate) (Resolved, error) {
+	resolved := Resolved{IndexTemplate: indexTemplate.Name, Mappings: types.JSON{}, Settings: types.JSON{}}
+	apply := func(template Template) {
+		resolved.Mappings = mergeMappings(resolved.Mappings, template.Mappings)
+		for key, value := range template.Settings {
+			resolved.Settings[key] = value
+		}
+		resolved.Quesma = resolved.Quesma.merge(template.Quesma)
+	}
+	for _, componentName := range indexTemplate.ComposedOf {
+		if component, ok := s.componentTemplates[componentName]; ok {
+			apply(component.Template)
+		}
+	}
+	apply(indexTemplate.Template)
+
+	if err := resolved.Quesma.validate(); err != nil {
+		return Resolved{}, fmt.Errorf("index template [%s] composed with its component templates is invalid: %w", indexTemplate.Name, err)
+	}
+	// a table has no column telling its indexes apart (unlike the common table), so it can keep one index only
+	if resolved.Quesma.TableName != "" && !indexTemplate.matchesSingleIndex() {
+		return Resolved{}, fmt.Errorf("index template [%s] with patterns %v cannot set [%s]: all matching indexes would share table [%s], use [%s] to store them in one table",
+			indexTemplate.Name, indexTemplate.IndexPatterns, tableNameSetting, resolved.Quesma.TableName, useCommonTableSetting)
+	}
+	return resolved, nil
+}
+
+// checkTableNames makes sure that indexes of different templates aren't stored in the same table.
+// The candidate template replaces the stored one of the same name.
+func (s *Store) checkTableNames(candidate *IndexTemplate) error {
+	owners := make(map[string]string)
+	check := func(template *IndexTemplate) error {
+		resolved, err := s.compose(template)
+		if err != nil || resolved.Quesma.TableName == "" {
+			return err
+		}
+		if owner, ok := owners[resolved.Quesma.TableName]; ok {
+			return fmt.Errorf("index templates [%s] and [%s] cannot both set [%s] to [%s], their indexes would share the table", owner, template.Name, tableNameSetting, resolved.Quesma.TableName)
+		}
+		owners[resolved.Quesma.TableName] = template.Name
+		return nil
+	}
+
+	for _, template := range s.sortedIndexTemplates() {
+		if candidate != nil && template.Name == candidate.Name {
+			continue
+		}
+		if err := check(template); err != nil {
+			return err
+		}
+	}
+	if candidate != nil {
+		return check(candidate)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package index_templates
+
+import (
+	"github.com/QuesmaOrg/quesma/platform/config"
+	"github.com/QuesmaOrg/quesma/platform/persistence"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func newTestStore() *Store {
+	return NewStore(persistence.NewStaticJSONDatabase(), persistence.NewStaticJSONDatabase())
+}
+
+func TestResolveComposedTemplate(t *testing.T) {
+	store := newTestStore()
+	require.NoError(t, store.PutComponentTemplate("base", types.MustJSON(`{"template":{
+		"settings":{"index":{"number_of_shards":1,"quesma":{"partitioningStrategy":"monthly"}}},
+		"mappings":{"properties":{"message":{"type":"text"},"host":{"properties":{"name":{"type":"keyword"}}}},
+			"dynamic_templates":[{"strings":{"match_mapping_type":"string","mapping":{"type":"keyword"}}}]}}}`)))
+	require.NoError(t, store.PutComponentTemplate("daily", types.MustJSON(`{"template":{"settings":{"index.quesma.partitioningStrategy":"daily"}}}`)))
+	require.NoError(t, store.PutIndexTemplate("logs", types.MustJSON(`{"index_patterns":["logs-*"],"composed_of":["base","daily"],"priority":10,
+		"template":{"settings":{"index.quesma.partitioningStrategy":"hourly"},
+			"mappings":{"properties":{"host":{"properties":{"ip":{"type":"ip"}}}},
+				"dynamic_templates":[{"strings":{"match_mapping_type":"string","mapping":{"type":"text"}}},{"counts":{"match":"*_count","mapping":{"type":"long"}}}]}}}`)))
+	require.NoError(t, store.PutIndexTemplate("catch-all", types.MustJSON(`{"index_patterns":"*","priority":1,"template":{"settings":{"index.quesma.useCommonTable":true}}}`)))
+
+	resolved, ok := store.Resolve("logs-2026.10.18")
+	require.True(t, ok)
+	assert.Equal(t, "logs", resolved.IndexTemplate)
+	assert.Equal(t, Settings{PartitioningStrategy: config.Hourly}, resolved.Quesma)
+	assert.Equal(t, float64(1), resolved.Settings["index.number_of_shards"])
+	assert.Equal(t, types.MustJSON(`{
+		"properties":{"message":{"type":"text"},"host":{"properties":{"name":{"type":"keyword"},"ip":{"type":"ip"}}}},
+		"dynamic_templates":[{"strings":{"match_mapping_type":"string","mapping":{"type":"text"}}},{"counts":{"match":"*_count","mapping":{"type":"long"}}}]}`),
+		resolved.Mappings)
+	require.Len(t, resolved.Overlapping, 1)
+	assert.Equal(t, "catch-all", resolved.Overlapping[0].Name)
+
+	resolved, ok = store.Resolve("metrics")
+	require.True(t, ok)
+	assert.Equal(t, "catch-all", resolved.IndexTemplate)
+	assert.True(t, resolved.Quesma.UseCommonTable)
+
+	// templates are read again from the database
+	reloaded := NewStore(store.indexTemplatesDb, store.componentTemplatesDb)
+	resolved, ok = reloaded.Resolve("logs-2026.10.18")
+	require.True(t, ok)
+	assert.Equal(t, "logs", resolved.IndexTemplate)
+	assert.Equal(t, Settings{PartitioningStrategy: config.Hourly}, resolved.Quesma)
+
+	// a table name is kept for a single index
+	require.NoError(t, store.PutIndexTemplate("main", types.MustJSON(`{"index_patterns":["logs-main"],"priority":20,"template":{"settings":{"index.quesma.tableName":"logs"}}}`)))
+	resolved, ok = store.Resolve("logs-main")
+	require.True(t, ok)
+	assert.Equal(t, Settings{TableName: "logs"}, resolved.Quesma)
+}
+
+func TestInvalidTemplates(t *testing.T) {
+	store := newTestStore()
+	require.NoError(t, store.PutIndexTemplate("logs", types.MustJSON(`{"index_patterns":["logs-*"]}`)))
+	require.NoError(t, store.PutIndexTemplate("main", types.MustJSON(`{"index_patterns":["main"],"template":{"settings":{"index.quesma.tableName":"main_table"}}}`)))
+	require.NoError(t, store.PutComponentTemplate("table", types.MustJSON(`{"template":{"settings":{"index.quesma.tableName":"other_table"}}}`)))
+
+	tests := []struct {
+		name       string
+		definition string
+		expected   string
+	}{
+		{"no patterns", `{"template":{}}`, "is missing [index_patterns]"},
+		{"missing component", `{"index_patterns":["a-*"],"composed_of":["missing"]}`, "specifies component templates [missing] that do not exist"},
+		{"same priority", `{"index_patterns":["logs-app-*"]}`, "matching patterns from existing templates [logs]"},
+		{"negative priority", `{"index_patterns":["a-*"],"priority":-1}`, "invalid [priority]"},
+		{"unknown setting", `{"index_patterns":["a-*"],"template":{"settings":{"index.quesma.unknown":1}}}`, "unknown setting [index.quesma.unknown]"},
+		{"partitioning", `{"index_patterns":["a-*"],"template":{"settings":{"index.quesma.partitioningStrategy":"weekly"}}}`, "partitioning strategy [weekly] is not allowed"},
+		{"common table with table name", `{"index_patterns":["a-*"],"template":{"settings":{"index.quesma.useCommonTable":true,"index.quesma.tableName":"a"}}}`, "cannot be set together"},
+		{"table shared by indexes", `{"index_patterns":["a-*"],"template":{"settings":{"index.quesma.tableName":"a"}}}`, "all matching indexes would share table [a]"},
+		{"table shared by patterns", `{"index_patterns":["a","b"],"template":{"settings":{"index.quesma.tableName":"a"}}}`, "all matching indexes would share table [a]"},
+		{"table shared through component", `{"index_patterns":["a-*"],"composed_of":["table"]}`, "all matching indexes would share table [other_table]"},
+		{"table shared by templates", `{"index_patterns":["other"],"template":{"settings":{"index.quesma.tableName":"main_table"}}}`, "index templates [main] and [invalid] cannot both set [quesma.tableName] to [main_table]"},
+		{"invalid mappings", `{"index_patterns":["a-*"],"template":{"mappings":{"dynamic":"sometimes"}}}`, "failed to parse [mappings]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := store.PutIndexTemplate("invalid", types.MustJSON(tt.definition))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+
+	templates, err := store.IndexTemplates()
+	require.NoError(t, err)
+	assert.Len(t, templates, 2)
+}
+
+func TestComponentTemplateInUse(t *testing.T) {
+	store := newTestStore()
+	require.NoError(t, store.PutComponentTemplate("partitioning", types.MustJSON(`{"template":{"settings":{"index.quesma.partitioningStrategy":"daily"}}}`)))
+	require.NoError(t, store.PutIndexTemplate("logs", types.MustJSON(`{"index_patterns":["logs-*"],"composed_of":["partitioning"],"template":{"settings":{"index.number_of_shards":1}}}`)))
+
+	found, err := store.DeleteComponentTemplate("partitioning")
+	var inUseErr *InUseError
+	require.ErrorAs(t, err, &inUseErr)
+	assert.True(t, found)
+	assert.Equal(t, "component templates [partitioning] cannot be removed as they are still in use by index templates [logs]", err.Error())
+
+	// the new version has to compose with index templates using it
+	err = store.PutComponentTemplate("partitioning", types.MustJSON(`{"template":{"settings":{"index.quesma.tableName":"logs"}}}`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "index template [logs] with patterns [logs-*] cannot set [quesma.tableName]")
+
+	resolved, ok := store.Resolve("logs-1")
+	require.True(t, ok)
+	assert.Equal(t, Settings{PartitioningStrategy: config.Daily}, resolved.Quesma)
+
+	found, err = store.DeleteIndexTemplate("logs")
+	require.NoError(t, err)
+	assert.True(t, found)
+	found, err = store.DeleteComponentTemplate("partitioning")
+	require.NoError(t, err)
+	assert.True(t, found)
+	_, ok = store.Resolve("logs-1")
+	assert.False(t, ok)
+}
@@ -0,0 +1,294 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package index_templates
+
+import (
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/config"
+	"github.com/QuesmaOrg/quesma/platform/elasticsearch"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Index templates and component templates are stored by Quesma, Elasticsearch doesn't know about them.
+// They are applied when Quesma creates a table for a new index, like Elasticsearch applies them to new indices.
+// Ref: https://www.elastic.co/guide/en/elasticsearch/reference/current/index-templates.html
+
+// Quesma-specific settings of a template, e.g. `"settings": {"index.quesma.tableName": "logs"}`.
+// The `index.` prefix is optional, like in Elasticsearch.
+const (
+	settingsPrefix              = "quesma."
+	partitioningStrategySetting = settingsPrefix + "partitioningStrategy"
+	tableNameSetting            = settingsPrefix + "tableName"
+	useCommonTableSetting       = settingsPrefix + "useCommonTable"
+)
+
+var allowedPartitioningStrategies = []config.PartitionStrategy{config.None, config.Hourly, config.Daily, config.Monthly, config.Yearly}
+
+type (
+	// Settings are Quesma-specific settings of tables created for matching indexes, they work like
+	// the options of the same names in the index configuration.
+	Settings struct {
+		PartitioningStrategy config.PartitionStrategy
+		TableName            string
+		UseCommonTable       bool
+	}
+
+	// Template is the `template` part of index and component templates.
+	Template struct {
+		Mappings types.JSON
+		Settings types.JSON // flattened, e.g. `index.number_of_shards`
+		Quesma   Settings
+	}
+
+	ComponentTemplate struct {
+		Name       string
+		Template   Template
+		Definition types.JSON // as sent by the client
+	}
+
+	IndexTemplate struct {
+		Name          string
+		IndexPatterns []string
+		ComposedOf    []string
+		Priority      int64
+		Template      Template
+		Definition    types.JSON // as sent by the client
+	}
+
+	// Resolved is the template of a new index, composed of the matching index template and its component templates.
+	Resolved struct {
+		IndexTemplate string
+		Mappings      types.JSON
+		Settings      types.JSON
+		Quesma        Settings
+		Overlapping   []*IndexTemplate // other matching index templates, which have lower priorities
+	}
+)
+
+func (s Settings) validate() error {
+	if !slices.Contains(allowedPartitioningStrategies, s.PartitioningStrategy) {
+		return fmt.Errorf("partitioning strategy [%s] is not allowed, only %v are supported", s.PartitioningStrategy, allowedPartitioningStrategies)
+	}
+	if s.UseCommonTable && s.PartitioningStrategy != config.None {
+		return fmt.Errorf("[%s] cannot be set together with [%s] - common table partitioning is NOT supported", partitioningStrategySetting, useCommonTableSetting)
+	}
+	if s.UseCommonTable && s.TableName != "" {
+		return fmt.Errorf("[%s] cannot be set together with [%s]", tableNameSetting, useCommonTableSetting)
+	}
+	return nil
+}
+
+// merge overrides settings with the ones set by a later template of the composition
+func (s Settings) merge(other Settings) Settings {
+	if other.PartitioningStrategy != config.None {
+		s.PartitioningStrategy = other.PartitioningStrategy
+	}
+	if other.TableName != "" {
+		s.TableName = other.TableName
+	}
+	if other.UseCommonTable {
+		s.UseCommonTable = true
+	}
+	return s
+}
+
+func (t IndexTemplate) Matches(indexName string) bool {
+	for _, pattern := range t.IndexPatterns {
+		if matches, _ := util.IndexPatternMatches(pattern, indexName); matches {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesSingleIndex tells whether the template can be applied to one index only, i.e. it has a single pattern without wildcards
+func (t IndexTemplate) matchesSingleIndex() bool {
+	return len(t.IndexPatterns) == 1 && !strings.Contains(t.IndexPatterns[0], "*")
+}
+
+// overlaps is an approximation of Elasticsearch's check, it doesn't detect all overlapping patterns (e.g. `logs-*` and `*-prod`)
+func (t IndexTemplate) overlaps(other IndexTemplate) bool {
+	for _, pattern := range t.IndexPatterns {
+		for _, otherPattern := range other.IndexPatterns {
+			matches, _ := util.IndexPatternMatches(pattern, otherPattern)
+			matchedBy, _ := util.IndexPatternMatches(otherPattern, pattern)
+			if matches || matchedBy {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ParseComponentTemplate(name string, definition types.JSON) (*ComponentTemplate, error) {
+	templateDefinition, ok := definition["template"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("component template [%s] is missing [template]", name)
+	}
+	template, err := parseTemplate(templateDefinition)
+	if err != nil {
+		return nil, fmt.Errorf("component template [%s]: %w", name, err)
+	}
+	return &ComponentTemplate{Name: name, Template: template, Definition: definition}, nil
+}
+
+func ParseIndexTemplate(name string, definition types.JSON) (*IndexTemplate, error) {
+	result := &IndexTemplate{Name: name, Definition: definition}
+
+	switch patterns := definition["index_patterns"].(type) {
+	case string:
+		result.IndexPatterns = strings.Split(patterns, ",")
+	case []interface{}:
+		for _, pattern := range patterns {
+			patternString, ok := pattern.(string)
+			if !ok {
+				return nil, fmt.Errorf("index template [%s] has invalid [index_patterns]: %v", name, pattern)
+			}
+			result.IndexPatterns = append(result.IndexPatterns, patternString)
+		}
+	}
+	if len(result.IndexPatterns) == 0 {
+		return nil, fmt.Errorf("index template [%s] is missing [index_patterns]", name)
+	}
+
+	if composedOf, ok := definition["composed_of"]; ok {
+		list, ok := composedOf.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("index template [%s] has invalid [composed_of]: %v", name, composedOf)
+		}
+		for _, component := range list {
+			componentName, ok := component.(string)
+			if !ok {
+				return nil, fmt.Errorf("index template [%s] has invalid [composed_of]: %v", name, component)
+			}
+			result.ComposedOf = append(result.ComposedOf, componentName)
+		}
+	}
+
+	switch priority := definition["priority"].(type) {
+	case nil:
+	case float64:
+		if priority < 0 || priority != float64(int64(priority)) {
+			return nil, fmt.Errorf("index template [%s] has invalid [priority]: %v, it must be a non-negative integer", name, priority)
+		}
+		result.Priority = int64(priority)
+	default:
+		return nil, fmt.Errorf("index template [%s] has invalid [priority]: %v, it must be a non-negative integer", name, priority)
+	}
+
+	if templateDefinition, ok := definition["template"]; ok {
+		templateMap, ok := templateDefinition.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("index template [%s] has invalid [template]", name)
+		}
+		template, err := parseTemplate(templateMap)
+		if err != nil {
+			return nil, fmt.Errorf("index template [%s]: %w", name, err)
+		}
+		result.Template = template
+	}
+	return result, nil
+}
+
+func parseTemplate(definition map[string]interface{}) (Template, error) {
+	var template Template
+	if mappings, ok := definition["mappings"]; ok {
+		mappingsMap, ok := mappings.(map[string]interface{})
+		if !ok {
+			return template, fmt.Errorf("[mappings] should be an object, got %T", mappings)
+		}
+		// mappings are parsed again when they're applied, but errors should be reported when the template is stored
+		if _, err := elasticsearch.ParseIndexMappings(mappingsMap); err != nil {
+			return template, fmt.Errorf("failed to parse [mappings]: %w", err)
+		}
+		template.Mappings = mappingsMap
+	}
+	if settings, ok := definition["settings"]; ok {
+		settingsMap, ok := settings.(map[string]interface{})
+		if !ok {
+			return template, fmt.Errorf("[settings] should be an object, got %T", settings)
+		}
+		template.Settings = util.FlattenMap(settingsMap, ".")
+	}
+
+	for key, value := range template.Settings {
+		setting := strings.TrimPrefix(key, "index.")
+		if !strings.HasPrefix(setting, settingsPrefix) {
+			continue
+		}
+		stringValue := fmt.Sprintf("%v", value)
+		switch setting {
+		case partitioningStrategySetting:
+			template.Quesma.PartitioningStrategy = config.PartitionStrategy(stringValue)
+		case tableNameSetting:
+			template.Quesma.TableName = stringValue
+		case useCommonTableSetting:
+			useCommonTable, err := strconv.ParseBool(stringValue)
+			if err != nil {
+				return template, fmt.Errorf("failed to parse value [%s] for setting [%s] as a boolean", stringValue, key)
+			}
+			template.Quesma.UseCommonTable = useCommonTable
+		default:
+			return template, fmt.Errorf("unknown setting [%s]", key)
+		}
+	}
+	if err := template.Quesma.validate(); err != nil {
+		return template, err
+	}
+	return template, nil
+}
+
+// mergeMappings merges mappings of a later template of the composition into the earlier ones: objects (e.g. `properties`)
+// are merged recursively, dynamic templates with the same name are replaced, and other values are overridden.
+func mergeMappings(base, override map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(base)+len(override))
+	for key, value := range base {
+		result[key] = value
+	}
+	for key, value := range override {
+		baseValue, exists := result[key]
+		if !exists {
+			result[key] = value
+			continue
+		}
+		baseMap, baseIsMap := baseValue.(map[string]interface{})
+		valueMap, valueIsMap := value.(map[string]interface{})
+		baseList, baseIsList := baseValue.([]interface{})
+		valueList, valueIsList := value.([]interface{})
+		switch {
+		case baseIsMap && valueIsMap:
+			result[key] = mergeMappings(baseMap, valueMap)
+		case key == "dynamic_templates" && baseIsList && valueIsList:
+			result[key] = mergeDynamicTemplates(baseList, valueList)
+		default:
+			result[key] = value
+		}
+	}
+	return result
+}
+
+func mergeDynamicTemplates(base, override []interface{}) []interface{} {
+	templateName := func(template interface{}) string {
+		if templateMap, ok := template.(map[string]interface{}); ok && len(templateMap) == 1 {
+			for name := range templateMap {
+				return name
+			}
+		}
+		return ""
+	}
+	result := slices.Clone(base)
+	for _, template := range override {
+		name := templateName(template)
+		i := slices.IndexFunc(result, func(existing interface{}) bool { return name != "" && templateName(existing) == name })
+		if i >= 0 {
+			result[i] = template
+		} else {
+			result = append(result, template)
+		}
+	}
+	return result
+}
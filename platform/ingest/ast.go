@@ -30,6 +30,7 @@ type AlterStatementType int
 const (
 	AddColumn AlterStatementType = iota
 	CommentColumn
+	ModifyColumn
 )
 
 type AlterStatement struct {
@@ -37,7 +38,7 @@ type AlterStatement struct {
 	TableName  string
 	OnCluster  string
 	ColumnName string
-	ColumnType string // used only for AddColumn
+	ColumnType string // used only for AddColumn and ModifyColumn
 	Comment    string // used only for CommentColumn
 }
 
@@ -109,6 +110,11 @@ func (stmt AlterStatement) ToSql() string {
 			`ALTER TABLE "%s"%s ADD COLUMN IF NOT EXISTS "%s" %s`,
 			stmt.TableName, onCluster, stmt.ColumnName, stmt.ColumnType,
 		)
+	case ModifyColumn:
+		return fmt.Sprintf(
+			`ALTER TABLE "%s"%s MODIFY COLUMN IF EXISTS "%s" %s`,
+			stmt.TableName, onCluster, stmt.ColumnName, stmt.ColumnType,
+		)
 	case CommentColumn:
 		return fmt.Sprintf(
 			`ALTER TABLE "%s"%s COMMENT COLUMN "%s" '%s'`,
@@ -122,3 +128,47 @@ func (stmt AlterStatement) ToSql() string {
 func (s InsertStatement) ToSQL() string {
 	return fmt.Sprintf(`INSERT INTO "%s" FORMAT JSONEachRow %s`, s.TableName, s.InsertValues)
 }
+
+// ColumnAssignment is a single `"column" = expression` of UpdateStatement.
+// Expression may contain `?` placeholders, bound to Args when the statement is executed.
+type ColumnAssignment struct {
+	ColumnName string
+	Expression string
+	Args       []any
+}
+
+type UpdateStatement struct {
+	TableName   string
+	OnCluster   string
+	Assignments []ColumnAssignment
+	Where       string
+}
+
+type DeleteStatement struct {
+	TableName string
+	OnCluster string
+	Where     string
+}
+
+func (stmt UpdateStatement) ToSQL() (string, []any) {
+	var onCluster string
+	if stmt.OnCluster != "" {
+		onCluster = fmt.Sprintf(` ON CLUSTER "%s"`, stmt.OnCluster)
+	}
+
+	assignments := make([]string, 0, len(stmt.Assignments))
+	var args []any
+	for _, assignment := range stmt.Assignments {
+		assignments = append(assignments, fmt.Sprintf(`"%s" = %s`, assignment.ColumnName, assignment.Expression))
+		args = append(args, assignment.Args...)
+	}
+	return fmt.Sprintf(`ALTER TABLE "%s"%s UPDATE %s WHERE %s`, stmt.TableName, onCluster, strings.Join(assignments, ", "), stmt.Where), args
+}
+
+func (stmt DeleteStatement) ToSQL() string {
+	var onCluster string
+	if stmt.OnCluster != "" {
+		onCluster = fmt.Sprintf(` ON CLUSTER "%s"`, stmt.OnCluster)
+	}
+	return fmt.Sprintf(`DELETE FROM "%s"%s WHERE %s`, stmt.TableName, onCluster, stmt.Where)
+}
@@ -65,3 +65,21 @@ COMMENT 'created by Quesma'`,
 		})
 	}
 }
+
+func TestMutationStatements_ToSQL(t *testing.T) {
+	update := UpdateStatement{
+		TableName: "my_table",
+		OnCluster: "quesma_cluster",
+		Assignments: []ColumnAssignment{
+			{ColumnName: "status", Expression: "?", Args: []any{"idle"}},
+			{ColumnName: "attempts", Expression: `"attempts" + ?`, Args: []any{1.0}},
+		},
+		Where: `"@timestamp"=toDateTime64('2025-01-20 12:56:58.042', 3)`,
+	}
+	query, args := update.ToSQL()
+	assert.Equal(t, `ALTER TABLE "my_table" ON CLUSTER "quesma_cluster" UPDATE "status" = ?, "attempts" = "attempts" + ? WHERE "@timestamp"=toDateTime64('2025-01-20 12:56:58.042', 3)`, query)
+	assert.Equal(t, []any{"idle", 1.0}, args)
+
+	del := DeleteStatement{TableName: "my_table", Where: `"@timestamp"=toDateTime64('2025-01-20 12:56:58.042', 3)`}
+	assert.Equal(t, `DELETE FROM "my_table" WHERE "@timestamp"=toDateTime64('2025-01-20 12:56:58.042', 3)`, del.ToSQL())
+}
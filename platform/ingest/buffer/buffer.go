@@ -0,0 +1,370 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+
+// Package buffer keeps ingested documents in a local write-ahead log, and inserts them in batches per index,
+// retrying failed inserts with backoff. Documents are kept until they're inserted, also over restarts.
+package buffer
+
+import (
+	"context"
+	"errors"
+	"github.com/QuesmaOrg/quesma/platform/config"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/recovery"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxSizeBytes      = 1024 * 1024 * 1024
+	DefaultMaxBatchDocuments = 10_000
+	DefaultMaxBatchSizeBytes = 16 * 1024 * 1024
+	DefaultFlushInterval     = 1 * time.Second
+	DefaultMaxRetries        = 10
+	DefaultInitialBackoff    = 1 * time.Second
+	DefaultMaxBackoff        = 1 * time.Minute
+
+	maxSegmentSize = 64 * 1024 * 1024
+)
+
+// ErrFull is returned when there's no room for more documents, clients should retry later.
+var ErrFull = errors.New("ingest buffer is full")
+
+// ErrStopped is returned by Flush, when the buffer is stopped before documents have been inserted.
+var ErrStopped = errors.New("ingest buffer is stopped")
+
+// InsertFunc inserts documents of the index. lastAttempt is set if the batch won't be retried after a failure.
+// Errors wrapped with Permanent aren't retried at all, so the function has to keep such documents itself
+// (e.g. in the dead letter queue).
+type InsertFunc func(ctx context.Context, index string, documents []types.JSON, lastAttempt bool) error
+
+// PermanentError is an insert error, which would happen again, e.g. documents not matching the table.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent marks the error of an insert, so that the batch isn't retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+func IsPermanent(err error) bool {
+	var permanentErr *PermanentError
+	return errors.As(err, &permanentErr)
+}
+
+// Metrics receives the state of the buffer, see telemetry.IngestBufferMetrics
+type Metrics interface {
+	SetQueueDepth(index string, documents int)
+	SetSize(bytes int64)
+	Retried(index string)
+	Rejected()
+}
+
+type NoopMetrics struct{}
+
+func (NoopMetrics) SetQueueDepth(string, int) {}
+func (NoopMetrics) SetSize(int64)             {}
+func (NoopMetrics) Retried(string)            {}
+func (NoopMetrics) Rejected()                 {}
+
+// queue keeps records of a single index, which are inserted by its own goroutine
+type queue struct {
+	index     string
+	pending   []*record // waiting for a batch
+	documents int       // in pending and in the batch being inserted
+	wake      chan struct{}
+
+	// records are inserted in the order they're added, so we count them to know if a record has been inserted
+	enqueued     int64
+	acknowledged int64
+	flushUntil   int64         // records up to this one are inserted without waiting for a full batch, see Flush
+	progress     chan struct{} // closed (and replaced) when records are acknowledged
+}
+
+type Buffer struct {
+	cfg     config.IngestBufferConfiguration
+	insert  InsertFunc
+	metrics Metrics
+	wal     *wal
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mutex  sync.Mutex
+	queues map[string]*queue
+	size   int64 // bytes of records, which haven't been inserted yet
+}
+
+func New(cfg config.IngestBufferConfiguration, insert InsertFunc, metrics Metrics) (*Buffer, error) {
+	if cfg.MaxSizeBytes == 0 {
+		cfg.MaxSizeBytes = DefaultMaxSizeBytes
+	}
+	if cfg.MaxBatchDocuments == 0 {
+		cfg.MaxBatchDocuments = DefaultMaxBatchDocuments
+	}
+	if cfg.MaxBatchSizeBytes == 0 {
+		cfg.MaxBatchSizeBytes = DefaultMaxBatchSizeBytes
+	}
+	if cfg.FlushInterval == 0 {
+		cfg.FlushInterval = DefaultFlushInterval
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = DefaultMaxRetries
+	}
+	if cfg.InitialBackoff == 0 {
+		cfg.InitialBackoff = DefaultInitialBackoff
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+
+	log, err := openWAL(cfg.Path, maxSegmentSize)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Buffer{cfg: cfg, insert: insert, metrics: metrics, wal: log, ctx: ctx, cancel: cancel, queues: make(map[string]*queue)}, nil
+}
+
+// Start queues records left in the write-ahead log by the previous run, so they're inserted again.
+func (b *Buffer) Start() error {
+	records, err := b.wal.recover()
+	if err != nil {
+		return err
+	}
+	if len(records) > 0 {
+		logger.Info().Msgf("ingest buffer recovered %d requests", len(records))
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, r := range records {
+		b.enqueue(r)
+	}
+	return nil
+}
+
+// Stop waits for inserts in progress. Documents, which haven't been inserted, are kept in the write-ahead log.
+func (b *Buffer) Stop() {
+	b.cancel()
+	b.wg.Wait()
+	if err := b.wal.close(); err != nil {
+		logger.Error().Msgf("can't close ingest buffer: %v", err)
+	}
+}
+
+// Add stores documents in the write-ahead log, they're inserted later. Documents mustn't be modified afterward.
+func (b *Buffer) Add(index string, documents []types.JSON) error {
+	if len(documents) == 0 {
+		return nil
+	}
+	if b.Full() {
+		b.metrics.Rejected()
+		return ErrFull
+	}
+	r, err := b.wal.append(index, documents)
+	if err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.enqueue(r)
+	return nil
+}
+
+// Flush inserts documents of the index added so far, without waiting for a full batch, and returns when they're inserted
+// (or given up after retries). Operations on stored documents (e.g. updates and deletes by id) call it first,
+// so that they see documents indexed before them.
+func (b *Buffer) Flush(ctx context.Context, index string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	q, exists := b.queues[index]
+	if !exists {
+		return nil
+	}
+	target := q.enqueued
+	if q.acknowledged < target {
+		q.flushUntil = max(q.flushUntil, target)
+		q.wakeUp()
+	}
+	for q.acknowledged < target {
+		progress := q.progress
+		b.mutex.Unlock()
+		select {
+		case <-progress:
+		case <-ctx.Done():
+			b.mutex.Lock()
+			return ctx.Err()
+		case <-b.ctx.Done():
+			b.mutex.Lock()
+			return ErrStopped
+		}
+		b.mutex.Lock()
+	}
+	return nil
+}
+
+func (b *Buffer) Full() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.size >= b.cfg.MaxSizeBytes
+}
+
+// enqueue adds the record to the queue of its index, starting the queue if needed. b.mutex must be held.
+func (b *Buffer) enqueue(r *record) {
+	q, exists := b.queues[r.Index]
+	if !exists {
+		q = &queue{index: r.Index, wake: make(chan struct{}, 1), progress: make(chan struct{})}
+		b.queues[r.Index] = q
+		b.wg.Add(1)
+		go b.run(q)
+	}
+	q.pending = append(q.pending, r)
+	q.enqueued++
+	q.documents += len(r.Documents)
+	b.size += r.size
+	b.metrics.SetQueueDepth(q.index, q.documents)
+	b.metrics.SetSize(b.size)
+	q.wakeUp()
+}
+
+func (q *queue) wakeUp() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (b *Buffer) run(q *queue) {
+	defer b.wg.Done()
+	defer recovery.LogPanic()
+	for {
+		batch := b.nextBatch(q)
+		if batch == nil {
+			return
+		}
+		if b.insertWithRetries(q.index, batch) {
+			b.acknowledge(q, batch)
+		}
+	}
+}
+
+// nextBatch waits until a batch is full, or its oldest record has waited for FlushInterval.
+// It returns nil when the buffer is stopped.
+func (b *Buffer) nextBatch(q *queue) []*record {
+	for {
+		var timeout <-chan time.Time
+		b.mutex.Lock()
+		if len(q.pending) > 0 {
+			wait := b.cfg.FlushInterval - time.Since(q.pending[0].added)
+			if batch := b.takeBatch(q, wait <= 0 || q.acknowledged < q.flushUntil); batch != nil {
+				b.mutex.Unlock()
+				return batch
+			}
+			timeout = time.After(wait)
+		}
+		b.mutex.Unlock()
+
+		select {
+		case <-b.ctx.Done():
+			return nil
+		case <-q.wake:
+		case <-timeout:
+		}
+	}
+}
+
+// takeBatch takes records from the beginning of the queue, up to batch limits. Unless force is set,
+// it takes them only if the limits have been reached. b.mutex must be held.
+func (b *Buffer) takeBatch(q *queue, force bool) []*record {
+	var documents int
+	var size int64
+	count := 0
+	for _, r := range q.pending {
+		if count > 0 && (documents+len(r.Documents) > b.cfg.MaxBatchDocuments || size+r.size > b.cfg.MaxBatchSizeBytes) {
+			break
+		}
+		documents += len(r.Documents)
+		size += r.size
+		count++
+	}
+	limitReached := count < len(q.pending) || documents >= b.cfg.MaxBatchDocuments || size >= b.cfg.MaxBatchSizeBytes
+	if !limitReached && !force {
+		return nil
+	}
+	batch := make([]*record, count)
+	copy(batch, q.pending)
+	q.pending = q.pending[count:]
+	return batch
+}
+
+// insertWithRetries returns false if the buffer has been stopped before the batch has been inserted.
+// After the last attempt fails, or the error is permanent, the batch is given up (lastAttempt lets the insert
+// keep it in the dead letter queue).
+func (b *Buffer) insertWithRetries(index string, batch []*record) bool {
+	backoff := b.cfg.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		lastAttempt := attempt >= b.cfg.MaxRetries
+
+		// inserts modify documents, and we may need them again
+		var documents []types.JSON
+		for _, r := range batch {
+			for _, document := range r.Documents {
+				documents = append(documents, document.Clone())
+			}
+		}
+		err := b.insert(context.Background(), index, documents, lastAttempt)
+		if err == nil {
+			return true
+		}
+		if lastAttempt {
+			logger.Error().Msgf("giving up inserting %d documents to %s after %d attempts: %v", len(documents), index, attempt+1, err)
+			return true
+		}
+		if IsPermanent(err) {
+			logger.Error().Msgf("giving up inserting %d documents to %s, retrying wouldn't help: %v", len(documents), index, err)
+			return true
+		}
+
+		b.metrics.Retried(index)
+		logger.Warn().Msgf("inserting %d documents to %s has failed, retrying in %v: %v", len(documents), index, backoff, err)
+		select {
+		case <-b.ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, b.cfg.MaxBackoff)
+	}
+}
+
+func (b *Buffer) acknowledge(q *queue, batch []*record) {
+	if err := b.wal.acknowledge(batch); err != nil {
+		logger.Error().Msgf("can't acknowledge inserted documents in the ingest buffer, they'll be inserted again after restart: %v", err)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, r := range batch {
+		q.documents -= len(r.Documents)
+		b.size -= r.size
+	}
+	q.acknowledged += int64(len(batch))
+	close(q.progress)
+	q.progress = make(chan struct{})
+	b.metrics.SetQueueDepth(q.index, q.documents)
+	b.metrics.SetSize(b.size)
+}
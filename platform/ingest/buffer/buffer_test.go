@@ -0,0 +1,240 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package buffer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/config"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+type insertCall struct {
+	index       string
+	documents   []types.JSON
+	lastAttempt bool
+}
+
+type recordingInserter struct {
+	mutex sync.Mutex
+	calls []insertCall
+	fail  int // number of calls, which fail
+	err   error
+}
+
+func (r *recordingInserter) insert(_ context.Context, index string, documents []types.JSON, lastAttempt bool) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls = append(r.calls, insertCall{index: index, documents: documents, lastAttempt: lastAttempt})
+	if r.fail > 0 {
+		r.fail--
+		if r.err != nil {
+			return r.err
+		}
+		return errors.New("too many parts")
+	}
+	return nil
+}
+
+func (r *recordingInserter) recorded() []insertCall {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]insertCall(nil), r.calls...)
+}
+
+func documents(n int) []types.JSON {
+	result := make([]types.JSON, n)
+	for i := range result {
+		result[i] = types.JSON{"message": fmt.Sprintf("m%d", i)}
+	}
+	return result
+}
+
+func TestBufferBatchesBySize(t *testing.T) {
+	inserter := &recordingInserter{}
+	b, err := New(config.IngestBufferConfiguration{Path: t.TempDir(), MaxBatchDocuments: 4, FlushInterval: time.Hour}, inserter.insert, NoopMetrics{})
+	require.NoError(t, err)
+	require.NoError(t, b.Start())
+	defer b.Stop()
+
+	require.NoError(t, b.Add("logs", documents(2)))
+	require.NoError(t, b.Add("logs", documents(2)))
+	require.NoError(t, b.Add("logs", documents(1)))
+
+	assert.Eventually(t, func() bool { return len(inserter.recorded()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	calls := inserter.recorded()
+	assert.Equal(t, "logs", calls[0].index)
+	assert.Len(t, calls[0].documents, 4)
+	assert.False(t, calls[0].lastAttempt)
+
+	// the last document waits for a full batch or the flush interval
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, inserter.recorded(), 1)
+}
+
+func TestBufferFlushesAfterInterval(t *testing.T) {
+	inserter := &recordingInserter{}
+	b, err := New(config.IngestBufferConfiguration{Path: t.TempDir(), FlushInterval: 20 * time.Millisecond}, inserter.insert, NoopMetrics{})
+	require.NoError(t, err)
+	require.NoError(t, b.Start())
+	defer b.Stop()
+
+	require.NoError(t, b.Add("logs", documents(1)))
+	require.NoError(t, b.Add("metrics", documents(2)))
+
+	assert.Eventually(t, func() bool { return len(inserter.recorded()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	inserted := make(map[string]int)
+	for _, call := range inserter.recorded() {
+		inserted[call.index] += len(call.documents)
+	}
+	assert.Equal(t, map[string]int{"logs": 1, "metrics": 2}, inserted)
+}
+
+func TestBufferFlush(t *testing.T) {
+	inserter := &recordingInserter{}
+	b, err := New(config.IngestBufferConfiguration{Path: t.TempDir(), FlushInterval: time.Hour}, inserter.insert, NoopMetrics{})
+	require.NoError(t, err)
+	require.NoError(t, b.Start())
+	defer b.Stop()
+
+	require.NoError(t, b.Flush(context.Background(), "logs"), "nothing to flush")
+
+	require.NoError(t, b.Add("logs", documents(2)))
+	require.NoError(t, b.Add("metrics", documents(1)))
+	require.NoError(t, b.Flush(context.Background(), "logs"))
+
+	// documents of other indexes still wait for a full batch or the flush interval
+	calls := inserter.recorded()
+	require.Len(t, calls, 1)
+	assert.Equal(t, "logs", calls[0].index)
+	assert.Len(t, calls[0].documents, 2)
+}
+
+func TestBufferRetries(t *testing.T) {
+	inserter := &recordingInserter{fail: 10}
+	cfg := config.IngestBufferConfiguration{Path: t.TempDir(), FlushInterval: time.Millisecond, MaxRetries: 2, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+	b, err := New(cfg, inserter.insert, NoopMetrics{})
+	require.NoError(t, err)
+	require.NoError(t, b.Start())
+	defer b.Stop()
+
+	require.NoError(t, b.Add("logs", documents(3)))
+
+	// the first attempt and two retries, the last one is given up
+	assert.Eventually(t, func() bool { return len(inserter.recorded()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	calls := inserter.recorded()
+	require.Len(t, calls, 3)
+	assert.False(t, calls[1].lastAttempt)
+	assert.True(t, calls[2].lastAttempt)
+	assert.Equal(t, documents(3), calls[2].documents)
+	assert.Eventually(t, func() bool { return !b.Full() }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestBufferDoesntRetryPermanentErrors(t *testing.T) {
+	inserter := &recordingInserter{fail: 10, err: Permanent(errors.New("cannot parse input"))}
+	cfg := config.IngestBufferConfiguration{Path: t.TempDir(), FlushInterval: time.Millisecond, MaxRetries: 2, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+	b, err := New(cfg, inserter.insert, NoopMetrics{})
+	require.NoError(t, err)
+	require.NoError(t, b.Start())
+	defer b.Stop()
+
+	require.NoError(t, b.Add("logs", documents(3)))
+
+	assert.Eventually(t, func() bool { return len(inserter.recorded()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return !b.Full() }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, inserter.recorded(), 1)
+}
+
+func TestBufferRecoversAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	// inserts fail, so documents stay in the buffer until it's stopped
+	failing := &recordingInserter{fail: 1000}
+	cfg := config.IngestBufferConfiguration{Path: dir, FlushInterval: time.Millisecond, InitialBackoff: time.Hour}
+	b, err := New(cfg, failing.insert, NoopMetrics{})
+	require.NoError(t, err)
+	require.NoError(t, b.Start())
+	require.NoError(t, b.Add("logs", documents(2)))
+	require.NoError(t, b.Add("logs", documents(3)))
+	assert.Eventually(t, func() bool { return len(failing.recorded()) > 0 }, 5*time.Second, 10*time.Millisecond)
+	b.Stop()
+
+	inserter := &recordingInserter{}
+	b, err = New(cfg, inserter.insert, NoopMetrics{})
+	require.NoError(t, err)
+	require.NoError(t, b.Start())
+	assert.Eventually(t, func() bool {
+		inserted := 0
+		for _, call := range inserter.recorded() {
+			inserted += len(call.documents)
+		}
+		return inserted == 5
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return !b.Full() }, 5*time.Second, 10*time.Millisecond)
+	b.Stop()
+
+	// all documents have been acknowledged, nothing is inserted again
+	inserter = &recordingInserter{}
+	b, err = New(cfg, inserter.insert, NoopMetrics{})
+	require.NoError(t, err)
+	require.NoError(t, b.Start())
+	time.Sleep(50 * time.Millisecond)
+	b.Stop()
+	assert.Empty(t, inserter.recorded())
+}
+
+func TestBufferFull(t *testing.T) {
+	failing := &recordingInserter{fail: 1000}
+	cfg := config.IngestBufferConfiguration{Path: t.TempDir(), MaxSizeBytes: 100, FlushInterval: time.Millisecond, InitialBackoff: time.Hour}
+	b, err := New(cfg, failing.insert, NoopMetrics{})
+	require.NoError(t, err)
+	require.NoError(t, b.Start())
+	defer b.Stop()
+
+	require.NoError(t, b.Add("logs", documents(5)))
+	assert.True(t, b.Full())
+	assert.ErrorIs(t, b.Add("logs", documents(1)), ErrFull)
+}
+
+func TestWALSegments(t *testing.T) {
+	dir := t.TempDir()
+	w, err := openWAL(dir, 200)
+	require.NoError(t, err)
+	_, err = w.recover()
+	require.NoError(t, err)
+
+	var records []*record
+	for i := 0; i < 6; i++ {
+		r, err := w.append("logs", documents(1))
+		require.NoError(t, err)
+		records = append(records, r)
+	}
+	segments, err := w.segments()
+	require.NoError(t, err)
+	assert.Greater(t, len(segments), 1)
+
+	require.NoError(t, w.acknowledge(records[:4]))
+	require.NoError(t, w.close())
+
+	w, err = openWAL(dir, 200)
+	require.NoError(t, err)
+	recovered, err := w.recover()
+	require.NoError(t, err)
+	require.Len(t, recovered, 2)
+	assert.Equal(t, records[4].Id, recovered[0].Id)
+	assert.Equal(t, records[5].Id, recovered[1].Id)
+
+	// segments keeping only acknowledged records are removed
+	remaining, err := w.segments()
+	require.NoError(t, err)
+	assert.NotContains(t, remaining, records[0].segment)
+	assert.Equal(t, records[4].segment, remaining[0])
+}
@@ -0,0 +1,253 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package buffer
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/goccy/go-json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const walSegmentSuffix = ".wal"
+
+// record is a single `Add` call: documents of one index.
+type record struct {
+	Id        uint64       `json:"id"`
+	Index     string       `json:"index"`
+	Documents []types.JSON `json:"documents"`
+
+	size    int64     // bytes taken in the log
+	segment uint64    // sequence number of the segment keeping the record
+	added   time.Time // when the record has been added to the buffer, or recovered
+}
+
+// walLine is a line of a segment file: either a record, or acknowledgement of records, which have been inserted.
+type walLine struct {
+	Record *record  `json:"record,omitempty"`
+	Ack    []uint64 `json:"ack,omitempty"`
+}
+
+// wal is a write-ahead log kept in segment files `<sequence number>.wal` of a directory.
+// Records are appended to the active segment, which is rotated when it grows above maxSegmentSize.
+//
+// Acknowledgements are appended to the active segment too, so they're always in the segment of their record
+// or in a later one. That's why segments are removed only from the beginning of the log, when all their
+// records have been acknowledged.
+type wal struct {
+	dir            string
+	maxSegmentSize int64
+
+	mutex          sync.Mutex
+	nextId         uint64
+	active         *os.File
+	activeSegment  uint64
+	activeSize     int64
+	unacknowledged map[uint64]int // segment -> number of its records, which haven't been acknowledged yet
+}
+
+func openWAL(dir string, maxSegmentSize int64) (*wal, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("can't create ingest buffer directory %s: %w", dir, err)
+	}
+	return &wal{dir: dir, maxSegmentSize: maxSegmentSize, nextId: 1, unacknowledged: make(map[uint64]int)}, nil
+}
+
+func (w *wal) segmentPath(segment uint64) string {
+	return filepath.Join(w.dir, strconv.FormatUint(segment, 10)+walSegmentSuffix)
+}
+
+func (w *wal) segments() ([]uint64, error) {
+	files, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+	var segments []uint64
+	for _, file := range files {
+		name, isSegment := strings.CutSuffix(file.Name(), walSegmentSuffix)
+		if !isSegment || file.IsDir() {
+			continue
+		}
+		if segment, err := strconv.ParseUint(name, 10, 64); err == nil {
+			segments = append(segments, segment)
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	return segments, nil
+}
+
+// recover reads existing segments and returns records, which haven't been acknowledged, in the order they were added.
+// It must be called before the log is written to.
+func (w *wal) recover() ([]*record, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	segments, err := w.segments()
+	if err != nil {
+		return nil, err
+	}
+	records := make(map[uint64]*record)
+	for _, segment := range segments {
+		if err = w.readSegment(segment, records); err != nil {
+			return nil, err
+		}
+		w.activeSegment = segment
+	}
+
+	result := make([]*record, 0, len(records))
+	for _, r := range records {
+		r.added = time.Now()
+		w.unacknowledged[r.segment]++
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+
+	// new records go to a new segment, the last one may end with a partially written line
+	w.activeSegment++
+	w.removeAcknowledgedSegments()
+	return result, nil
+}
+
+func (w *wal) readSegment(segment uint64, records map[uint64]*record) error {
+	file, err := os.Open(w.segmentPath(segment))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024*1024)
+	for scanner.Scan() {
+		var line walLine
+		if err = json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			// a crash while writing leaves a partial line, records after it have never been acknowledged to clients
+			logger.Warn().Msgf("ingest buffer segment %s has an invalid line, skipping the rest of it: %v", w.segmentPath(segment), err)
+			break
+		}
+		if r := line.Record; r != nil {
+			r.size = int64(len(scanner.Bytes()) + 1)
+			r.segment = segment
+			records[r.Id] = r
+			w.nextId = max(w.nextId, r.Id+1)
+		}
+		for _, id := range line.Ack {
+			delete(records, id)
+		}
+	}
+	return scanner.Err()
+}
+
+// append writes the record and syncs it to the disk.
+func (w *wal) append(index string, documents []types.JSON) (*record, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	r := &record{Id: w.nextId, Index: index, Documents: documents, added: time.Now()}
+	line, err := json.Marshal(walLine{Record: r})
+	if err != nil {
+		return nil, err
+	}
+	line = append(line, '\n')
+	if err = w.write(line, true); err != nil {
+		return nil, err
+	}
+	w.nextId++
+	r.size = int64(len(line))
+	r.segment = w.activeSegment
+	w.unacknowledged[r.segment]++
+	return r, nil
+}
+
+// acknowledge marks records as inserted. It's not synced: if it's lost, records will be inserted again.
+func (w *wal) acknowledge(records []*record) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	ids := make([]uint64, 0, len(records))
+	for _, r := range records {
+		ids = append(ids, r.Id)
+	}
+	line, err := json.Marshal(walLine{Ack: ids})
+	if err != nil {
+		return err
+	}
+	if err = w.write(append(line, '\n'), false); err != nil {
+		return err
+	}
+	for _, r := range records {
+		w.unacknowledged[r.segment]--
+	}
+	w.removeAcknowledgedSegments()
+	return nil
+}
+
+// write appends the line to the active segment, rotating it first if needed. w.mutex must be held.
+func (w *wal) write(line []byte, sync bool) error {
+	if w.active != nil && w.activeSize > 0 && w.activeSize+int64(len(line)) > w.maxSegmentSize {
+		if err := w.active.Close(); err != nil {
+			return err
+		}
+		w.active = nil
+		w.activeSegment++
+		w.removeAcknowledgedSegments()
+	}
+	if w.active == nil {
+		file, err := os.OpenFile(w.segmentPath(w.activeSegment), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			_ = file.Close()
+			return err
+		}
+		w.active, w.activeSize = file, info.Size()
+	}
+	if _, err := w.active.Write(line); err != nil {
+		return err
+	}
+	w.activeSize += int64(len(line))
+	if sync {
+		return w.active.Sync()
+	}
+	return nil
+}
+
+// removeAcknowledgedSegments removes segments from the beginning of the log, as long as all their records
+// have been acknowledged. The active segment is kept. w.mutex must be held.
+func (w *wal) removeAcknowledgedSegments() {
+	segments, err := w.segments()
+	if err != nil {
+		logger.Error().Msgf("can't list ingest buffer segments: %v", err)
+		return
+	}
+	for _, segment := range segments {
+		if segment >= w.activeSegment || w.unacknowledged[segment] > 0 {
+			return
+		}
+		if err = os.Remove(w.segmentPath(segment)); err != nil {
+			logger.Error().Msgf("can't remove ingest buffer segment %s: %v", w.segmentPath(segment), err)
+			return
+		}
+		delete(w.unacknowledged, segment)
+	}
+}
+
+func (w *wal) close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.active == nil {
+		return nil
+	}
+	err := w.active.Close()
+	w.active = nil
+	return err
+}
@@ -16,5 +16,6 @@ type Lowerer interface {
 		table *chLib.Table,
 		invalidJsons []types.JSON,
 		encodings map[schema.FieldEncodingKey]schema.EncodedFieldName,
-		createTableCmd CreateTableStatement) ([]string, error)
+		createTableCmd CreateTableStatement,
+		policy SchemaEvolution) ([]string, error)
 }
@@ -0,0 +1,99 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/ingest/dead_letter"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"sort"
+	"strings"
+)
+
+var ErrDeadLetterQueueDisabled = errors.New("dead letter queue is not configured")
+
+// SetDeadLetterSink enables keeping documents rejected by ingest in the sink.
+func (ip *IngestProcessor) SetDeadLetterSink(sink dead_letter.Sink) {
+	ip.deadLetter = sink
+}
+
+func (ip *IngestProcessor) DeadLetterEntries(ctx context.Context, limit int) ([]dead_letter.Entry, error) {
+	if ip.deadLetter == nil {
+		return nil, ErrDeadLetterQueueDisabled
+	}
+	return ip.deadLetter.List(ctx, limit)
+}
+
+// ReplayDeadLetter ingests the document of the entry again, e.g. after the schema has been fixed.
+// The entry is removed if that succeeds. Ingest pipelines have already been run on stored documents,
+// so they're not run again.
+func (ip *IngestProcessor) ReplayDeadLetter(ctx context.Context, id string) error {
+	if ip.deadLetter == nil {
+		return ErrDeadLetterQueueDisabled
+	}
+	entry, found, err := ip.deadLetter.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("dead letter entry %s not found", id)
+	}
+	if err = ip.Ingest(ctx, entry.Index, []types.JSON{entry.Payload}); err != nil {
+		return fmt.Errorf("can't ingest dead letter entry %s: %w", id, err)
+	}
+	return ip.deadLetter.Delete(ctx, id)
+}
+
+func (ip *IngestProcessor) DiscardDeadLetter(ctx context.Context, id string) error {
+	if ip.deadLetter == nil {
+		return ErrDeadLetterQueueDisabled
+	}
+	return ip.deadLetter.Delete(ctx, id)
+}
+
+// recordRejectedDocuments stores all documents of a batch, which hasn't been stored at all.
+func (ip *IngestProcessor) recordRejectedDocuments(ctx context.Context, indexName string, originalJsons []types.JSON, reason error) {
+	if ip.deadLetter == nil || len(originalJsons) == 0 {
+		return
+	}
+	entries := make([]dead_letter.Entry, 0, len(originalJsons))
+	for _, jsonValue := range originalJsons {
+		entries = append(entries, dead_letter.NewEntry(indexName, reason.Error(), jsonValue))
+	}
+	ip.writeDeadLetters(ctx, entries)
+}
+
+// recordDocumentsWithInvalidFields stores documents with values, which didn't match types of their columns.
+// These documents have been stored, but without those values in their columns (see validateIngest).
+// invalidJsons are aligned with originalJsons.
+func (ip *IngestProcessor) recordDocumentsWithInvalidFields(ctx context.Context, indexName string, originalJsons, invalidJsons []types.JSON) {
+	if ip.deadLetter == nil || len(originalJsons) != len(invalidJsons) {
+		return
+	}
+	var entries []dead_letter.Entry
+	for i, invalidJson := range invalidJsons {
+		if len(invalidJson) == 0 {
+			continue
+		}
+		columns := make([]string, 0, len(invalidJson))
+		for column := range invalidJson {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+		reason := fmt.Sprintf("values of columns [%s] don't match column types", strings.Join(columns, ", "))
+		entries = append(entries, dead_letter.NewEntry(indexName, reason, originalJsons[i]))
+	}
+	ip.writeDeadLetters(ctx, entries)
+}
+
+func (ip *IngestProcessor) writeDeadLetters(ctx context.Context, entries []dead_letter.Entry) {
+	if len(entries) == 0 {
+		return
+	}
+	if err := ip.deadLetter.Write(ctx, entries); err != nil {
+		logger.ErrorWithCtx(ctx).Msgf("can't store %d documents in the dead letter queue: %v", len(entries), err)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package dead_letter
+
+import (
+	"context"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	quesma_api "github.com/QuesmaOrg/quesma/platform/v2/core"
+	"github.com/goccy/go-json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const clickHouseTableDDL = `CREATE TABLE IF NOT EXISTS "%s" %s
+(
+    "id"        String,
+    "timestamp" DateTime64(3),
+    "index"     LowCardinality(String),
+    "reason"    String,
+    "payload"   String
+)
+    ENGINE = MergeTree
+    ORDER BY ("timestamp")
+    COMMENT 'Quesma managed. Documents rejected by ingest.'`
+
+const clickHouseTimestampFormat = "2006-01-02 15:04:05.000"
+
+// ClickHouseSink keeps entries in a table, which is created on the first write.
+type ClickHouseSink struct {
+	db          quesma_api.BackendConnector
+	tableName   string
+	clusterName string
+
+	mutex        sync.Mutex
+	tableCreated bool
+}
+
+func NewClickHouseSink(db quesma_api.BackendConnector, tableName, clusterName string) *ClickHouseSink {
+	return &ClickHouseSink{db: db, tableName: tableName, clusterName: clusterName}
+}
+
+func (s *ClickHouseSink) ensureTableExists(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.tableCreated {
+		return nil
+	}
+	var maybeOnClusterClause string
+	if s.clusterName != "" {
+		maybeOnClusterClause = "ON CLUSTER " + strconv.Quote(s.clusterName)
+	}
+	if err := s.db.Exec(ctx, fmt.Sprintf(clickHouseTableDDL, s.tableName, maybeOnClusterClause)); err != nil {
+		return fmt.Errorf("can't create dead letter table %s: %w", s.tableName, err)
+	}
+	logger.InfoWithCtx(ctx).Msgf("dead letter table '%s' created", s.tableName)
+	s.tableCreated = true
+	return nil
+}
+
+func (s *ClickHouseSink) Write(ctx context.Context, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := s.ensureTableExists(ctx); err != nil {
+		return err
+	}
+	var rows strings.Builder
+	for _, entry := range entries {
+		payload, err := json.Marshal(entry.Payload)
+		if err != nil {
+			return err
+		}
+		row, err := json.Marshal(map[string]any{
+			"id":        entry.Id,
+			"timestamp": entry.Timestamp.UTC().Format(clickHouseTimestampFormat),
+			"index":     entry.Index,
+			"reason":    entry.Reason,
+			"payload":   string(payload),
+		})
+		if err != nil {
+			return err
+		}
+		rows.Write(row)
+		rows.WriteString("\n")
+	}
+	return s.db.Exec(ctx, fmt.Sprintf(`INSERT INTO "%s" FORMAT JSONEachRow %s`, s.tableName, rows.String()))
+}
+
+func (s *ClickHouseSink) List(ctx context.Context, limit int) ([]Entry, error) {
+	return s.query(ctx, fmt.Sprintf(`SELECT "id", "timestamp", "index", "reason", "payload" FROM "%s" ORDER BY "timestamp" DESC LIMIT %d`, s.tableName, limit))
+}
+
+func (s *ClickHouseSink) Get(ctx context.Context, id string) (Entry, bool, error) {
+	entries, err := s.query(ctx, fmt.Sprintf(`SELECT "id", "timestamp", "index", "reason", "payload" FROM "%s" WHERE "id" = ? LIMIT 1`, s.tableName), id)
+	if err != nil || len(entries) == 0 {
+		return Entry{}, false, err
+	}
+	return entries[0], true, nil
+}
+
+// Delete uses a lightweight delete, rows disappear from queries immediately and are removed on merges.
+func (s *ClickHouseSink) Delete(ctx context.Context, id string) error {
+	return s.db.Exec(ctx, fmt.Sprintf(`DELETE FROM "%s" WHERE "id" = ?`, s.tableName), id)
+}
+
+func (s *ClickHouseSink) query(ctx context.Context, query string, args ...any) ([]Entry, error) {
+	if err := s.ensureTableExists(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var entry Entry
+		var timestamp time.Time
+		var payload string
+		if err = rows.Scan(&entry.Id, &timestamp, &entry.Index, &entry.Reason, &payload); err != nil {
+			return nil, err
+		}
+		entry.Timestamp = timestamp.UTC()
+		if err = json.Unmarshal([]byte(payload), &entry.Payload); err != nil {
+			entry.Payload = types.JSON{}
+			logger.WarnWithCtx(ctx).Msgf("dead letter entry %s has an invalid payload: %v", entry.Id, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
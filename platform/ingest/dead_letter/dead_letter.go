@@ -0,0 +1,73 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+
+// Package dead_letter keeps documents rejected by ingest, so they can be inspected and re-ingested
+// e.g. after the schema has been fixed.
+package dead_letter
+
+import (
+	"context"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/config"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	quesma_api "github.com/QuesmaOrg/quesma/platform/v2/core"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	DefaultTableName        = "quesma_dead_letter"
+	DefaultIndexName        = "quesma_dead_letter"
+	DefaultMaxFileSizeBytes = 100 * 1024 * 1024
+	DefaultMaxFiles         = 5
+)
+
+// Entry is a single rejected document.
+type Entry struct {
+	Id        string     `json:"id"`
+	Timestamp time.Time  `json:"timestamp"`
+	Index     string     `json:"index"`
+	Reason    string     `json:"reason"`
+	Payload   types.JSON `json:"payload"` // the document as it was sent to us
+}
+
+func NewEntry(index, reason string, payload types.JSON) Entry {
+	return Entry{Id: uuid.New().String(), Timestamp: time.Now().UTC(), Index: index, Reason: reason, Payload: payload}
+}
+
+// Sink stores entries. List returns the most recent entries first.
+type Sink interface {
+	Write(ctx context.Context, entries []Entry) error
+	List(ctx context.Context, limit int) ([]Entry, error)
+	Get(ctx context.Context, id string) (entry Entry, found bool, err error)
+	Delete(ctx context.Context, id string) error
+}
+
+// NewSink creates the sink described by the configuration, chDb is used by the `clickhouse` sink only.
+func NewSink(cfg *config.DeadLetterConfiguration, chDb quesma_api.BackendConnector, elasticsearch config.ElasticsearchConfiguration, clusterName string) (Sink, error) {
+	switch cfg.Sink {
+	case config.DeadLetterSinkClickHouse:
+		tableName := cfg.TableName
+		if tableName == "" {
+			tableName = DefaultTableName
+		}
+		return NewClickHouseSink(chDb, tableName, clusterName), nil
+	case config.DeadLetterSinkFile:
+		maxFileSize, maxFiles := cfg.MaxFileSizeBytes, cfg.MaxFiles
+		if maxFileSize == 0 {
+			maxFileSize = DefaultMaxFileSizeBytes
+		}
+		if maxFiles == 0 {
+			maxFiles = DefaultMaxFiles
+		}
+		return NewFileSink(cfg.Path, maxFileSize, maxFiles)
+	case config.DeadLetterSinkElasticsearch:
+		indexName := cfg.IndexName
+		if indexName == "" {
+			indexName = DefaultIndexName
+		}
+		return NewElasticsearchSink(elasticsearch, indexName), nil
+	default:
+		return nil, fmt.Errorf("unsupported dead letter sink: %s", cfg.Sink)
+	}
+}
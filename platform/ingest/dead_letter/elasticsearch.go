@@ -0,0 +1,144 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package dead_letter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/config"
+	"github.com/QuesmaOrg/quesma/platform/elasticsearch"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/goccy/go-json"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ElasticsearchSink keeps entries as documents of an Elasticsearch index, with the entry id as the document id.
+type ElasticsearchSink struct {
+	indexName  string
+	httpClient *elasticsearch.SimpleClient
+}
+
+// elasticDocument keeps the payload as a string, so that documents of many indexes don't pollute
+// the mapping of the dead letter index, and documents with conflicting types can be stored at all.
+type elasticDocument struct {
+	Timestamp time.Time `json:"timestamp"`
+	Index     string    `json:"index"`
+	Reason    string    `json:"reason"`
+	Payload   string    `json:"payload"`
+}
+
+func NewElasticsearchSink(cfg config.ElasticsearchConfiguration, indexName string) *ElasticsearchSink {
+	return &ElasticsearchSink{indexName: indexName, httpClient: elasticsearch.NewSimpleClient(&cfg)}
+}
+
+func (s *ElasticsearchSink) Write(ctx context.Context, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	var body bytes.Buffer
+	for _, entry := range entries {
+		payload, err := json.Marshal(entry.Payload)
+		if err != nil {
+			return err
+		}
+		action, _ := json.Marshal(types.JSON{"index": types.JSON{"_index": s.indexName, "_id": entry.Id}})
+		document, err := json.Marshal(elasticDocument{Timestamp: entry.Timestamp, Index: entry.Index, Reason: entry.Reason, Payload: string(payload)})
+		if err != nil {
+			return err
+		}
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(document)
+		body.WriteByte('\n')
+	}
+
+	var response struct {
+		Errors bool `json:"errors"`
+	}
+	if err := s.request(ctx, "POST", "_bulk", body.Bytes(), &response); err != nil {
+		return err
+	}
+	if response.Errors {
+		return fmt.Errorf("some dead letter entries haven't been stored in %s", s.indexName)
+	}
+	return nil
+}
+
+func (s *ElasticsearchSink) List(ctx context.Context, limit int) ([]Entry, error) {
+	query, _ := json.Marshal(types.JSON{
+		"size":  limit,
+		"sort":  []any{types.JSON{"timestamp": types.JSON{"order": "desc"}}},
+		"query": types.JSON{"match_all": types.JSON{}},
+	})
+	var response struct {
+		Hits struct {
+			Hits []struct {
+				Id     string          `json:"_id"`
+				Source elasticDocument `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := s.request(ctx, "GET", s.indexName+"/_search", query, &response); err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(response.Hits.Hits))
+	for _, hit := range response.Hits.Hits {
+		entries = append(entries, hit.Source.toEntry(hit.Id))
+	}
+	return entries, nil
+}
+
+func (s *ElasticsearchSink) Get(ctx context.Context, id string) (Entry, bool, error) {
+	var response struct {
+		Found  bool            `json:"found"`
+		Source elasticDocument `json:"_source"`
+	}
+	if err := s.request(ctx, "GET", s.indexName+"/_doc/"+id, nil, &response); err != nil {
+		return Entry{}, false, err
+	}
+	if !response.Found {
+		return Entry{}, false, nil
+	}
+	return response.Source.toEntry(id), true, nil
+}
+
+func (s *ElasticsearchSink) Delete(ctx context.Context, id string) error {
+	return s.request(ctx, "DELETE", s.indexName+"/_doc/"+id+"?refresh=true", nil, nil)
+}
+
+// request sends the request and decodes the response into result. Missing index or document isn't an error,
+// result is left empty then.
+func (s *ElasticsearchSink) request(ctx context.Context, method, endpoint string, body []byte, result any) error {
+	resp, err := s.httpClient.Request(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+	case http.StatusNotFound:
+		return nil
+	default:
+		return fmt.Errorf("dead letter request %s %s failed: %s, %s", method, endpoint, resp.Status, string(responseBody))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(responseBody, result)
+}
+
+func (d elasticDocument) toEntry(id string) Entry {
+	entry := Entry{Id: id, Timestamp: d.Timestamp, Index: d.Index, Reason: d.Reason}
+	if err := json.Unmarshal([]byte(d.Payload), &entry.Payload); err != nil {
+		entry.Payload = types.JSON{}
+	}
+	return entry
+}
@@ -0,0 +1,210 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package dead_letter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/goccy/go-json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileSink appends entries as JSON lines to a file. When the file would exceed maxFileSize,
+// it's rotated: `path` becomes `path.1`, `path.1` becomes `path.2` and so on, up to maxFiles rotated files.
+type FileSink struct {
+	path        string
+	maxFileSize int64
+	maxFiles    int
+
+	mutex sync.Mutex
+}
+
+func NewFileSink(path string, maxFileSize int64, maxFiles int) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("can't create directory of dead letter file %s: %w", path, err)
+	}
+	return &FileSink{path: path, maxFileSize: maxFileSize, maxFiles: maxFiles}, nil
+}
+
+func (s *FileSink) Write(_ context.Context, entries []Entry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
+		if err = s.rotateIfNeeded(int64(len(line))); err != nil {
+			return err
+		}
+		if err = s.append(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileSink) append(line []byte) error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(line); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (s *FileSink) rotateIfNeeded(incomingBytes int64) error {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 || info.Size()+incomingBytes <= s.maxFileSize {
+		return nil
+	}
+	if s.maxFiles == 0 {
+		return os.Remove(s.path)
+	}
+	if err = os.Remove(s.rotatedPath(s.maxFiles)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for i := s.maxFiles - 1; i >= 1; i-- {
+		if err = os.Rename(s.rotatedPath(i), s.rotatedPath(i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Rename(s.path, s.rotatedPath(1))
+}
+
+func (s *FileSink) rotatedPath(i int) string {
+	return fmt.Sprintf("%s.%d", s.path, i)
+}
+
+// files returns paths of all files, the most recent first
+func (s *FileSink) files() []string {
+	paths := []string{s.path}
+	for i := 1; i <= s.maxFiles; i++ {
+		paths = append(paths, s.rotatedPath(i))
+	}
+	return paths
+}
+
+func readEntries(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid dead letter entry in %s: %w", path, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+func (s *FileSink) List(_ context.Context, limit int) ([]Entry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var result []Entry
+	for _, path := range s.files() {
+		entries, err := readEntries(path)
+		if err != nil {
+			return nil, err
+		}
+		// entries are appended, so the most recent are at the end
+		for i := len(entries) - 1; i >= 0 && len(result) < limit; i-- {
+			result = append(result, entries[i])
+		}
+		if len(result) >= limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (s *FileSink) Get(_ context.Context, id string) (Entry, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, path := range s.files() {
+		entries, err := readEntries(path)
+		if err != nil {
+			return Entry{}, false, err
+		}
+		for _, entry := range entries {
+			if entry.Id == id {
+				return entry, true, nil
+			}
+		}
+	}
+	return Entry{}, false, nil
+}
+
+// Delete rewrites the file containing the entry without it.
+func (s *FileSink) Delete(_ context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, path := range s.files() {
+		entries, err := readEntries(path)
+		if err != nil {
+			return err
+		}
+		for i, entry := range entries {
+			if entry.Id != id {
+				continue
+			}
+			entries = append(entries[:i], entries[i+1:]...)
+			return rewriteEntries(path, entries)
+		}
+	}
+	return nil
+}
+
+func rewriteEntries(path string, entries []Entry) error {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			_ = file.Close()
+			return err
+		}
+		_, _ = writer.Write(line)
+		_ = writer.WriteByte('\n')
+	}
+	if err = writer.Flush(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
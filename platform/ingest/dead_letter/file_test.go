@@ -0,0 +1,66 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package dead_letter
+
+import (
+	"context"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSink(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dead_letter", "entries.jsonl")
+
+	// every entry is ~190 bytes, so each file keeps two of them
+	sink, err := NewFileSink(path, 450, 2)
+	require.NoError(t, err)
+
+	var entries []Entry
+	for i := 0; i < 7; i++ {
+		entry := NewEntry("logs", "values of columns [code] don't match column types", types.JSON{"code": fmt.Sprintf("c%d", i)})
+		entries = append(entries, entry)
+		require.NoError(t, sink.Write(ctx, []Entry{entry}))
+	}
+
+	for _, rotated := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(rotated)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, info.Size(), int64(450))
+	}
+	assert.NoFileExists(t, path+".3")
+
+	// the oldest file has been dropped, entries [2, 3], [4, 5] and [6] are left
+	listed, err := sink.List(ctx, 100)
+	require.NoError(t, err)
+	require.Len(t, listed, 5)
+	assert.Equal(t, entries[6].Id, listed[0].Id)
+	assert.Equal(t, entries[2].Id, listed[4].Id)
+	assert.Equal(t, types.JSON{"code": "c6"}, listed[0].Payload)
+
+	listed, err = sink.List(ctx, 3)
+	require.NoError(t, err)
+	assert.Len(t, listed, 3)
+
+	entry, found, err := sink.Get(ctx, entries[3].Id)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "logs", entry.Index)
+
+	require.NoError(t, sink.Delete(ctx, entries[3].Id))
+	_, found, err = sink.Get(ctx, entries[3].Id)
+	require.NoError(t, err)
+	assert.False(t, found)
+	listed, err = sink.List(ctx, 100)
+	require.NoError(t, err)
+	assert.Len(t, listed, 4)
+
+	_, found, err = sink.Get(ctx, entries[1].Id)
+	require.NoError(t, err)
+	assert.False(t, found)
+}
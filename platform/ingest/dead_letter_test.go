@@ -0,0 +1,88 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package ingest
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/QuesmaOrg/quesma/platform/config"
+	"github.com/QuesmaOrg/quesma/platform/ingest/dead_letter"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"slices"
+	"testing"
+)
+
+type memoryDeadLetterSink struct {
+	entries []dead_letter.Entry
+}
+
+func (s *memoryDeadLetterSink) Write(_ context.Context, entries []dead_letter.Entry) error {
+	s.entries = append(s.entries, entries...)
+	return nil
+}
+
+func (s *memoryDeadLetterSink) List(_ context.Context, limit int) ([]dead_letter.Entry, error) {
+	return s.entries[:min(limit, len(s.entries))], nil
+}
+
+func (s *memoryDeadLetterSink) Get(_ context.Context, id string) (dead_letter.Entry, bool, error) {
+	for _, entry := range s.entries {
+		if entry.Id == id {
+			return entry, true, nil
+		}
+	}
+	return dead_letter.Entry{}, false, nil
+}
+
+func (s *memoryDeadLetterSink) Delete(_ context.Context, id string) error {
+	s.entries = slices.DeleteFunc(s.entries, func(entry dead_letter.Entry) bool { return entry.Id == id })
+	return nil
+}
+
+func TestDeadLetterInvalidFields(t *testing.T) {
+	sink := &memoryDeadLetterSink{}
+	ip, mock, _ := newIngestProcessorWithSqlMock(t, config.IndexConfiguration{}, sink)
+
+	mock.ExpectExec(`INSERT INTO "test_table" FORMAT JSONEachRow {"int_field":15}, {"attributes_metadata":{"int_field":"v1;String"},"attributes_values":{"int_field":"1.5"}}`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	err := ip.ProcessInsertQuery(context.Background(), tableName,
+		[]types.JSON{types.MustJSON(`{"int_field":15}`), types.MustJSON(`{"int_field":"1.5"}`)}, &IngestTransformerTest{}, &columNameFormatter{separator: "::"})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	require.Len(t, sink.entries, 1)
+	assert.Equal(t, tableName, sink.entries[0].Index)
+	assert.Equal(t, "values of columns [int_field] don't match column types", sink.entries[0].Reason)
+	assert.Equal(t, types.MustJSON(`{"int_field":"1.5"}`), sink.entries[0].Payload)
+	assert.NotEmpty(t, sink.entries[0].Id)
+}
+
+func TestDeadLetterFailedInsert(t *testing.T) {
+	sink := &memoryDeadLetterSink{}
+	ip, mock, _ := newIngestProcessorWithSqlMock(t, config.IndexConfiguration{}, sink)
+
+	mock.ExpectExec(`INSERT INTO "test_table" FORMAT JSONEachRow {"int_field":15}, {"int_field":16}`).WillReturnError(errors.New("too many parts"))
+	err := ip.ProcessInsertQuery(context.Background(), tableName,
+		[]types.JSON{types.MustJSON(`{"int_field":15}`), types.MustJSON(`{"int_field":16}`)}, &IngestTransformerTest{}, &columNameFormatter{separator: "::"})
+	require.Error(t, err)
+
+	require.Len(t, sink.entries, 2)
+	for _, entry := range sink.entries {
+		assert.Equal(t, "too many parts", entry.Reason)
+	}
+	assert.Equal(t, types.MustJSON(`{"int_field":16}`), sink.entries[1].Payload)
+
+	// replaying succeeds now, so the entry is removed
+	mock.ExpectExec(`INSERT INTO "test_table" FORMAT JSONEachRow {"int_field":15}`).WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, ip.ReplayDeadLetter(context.Background(), sink.entries[0].Id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, sink.entries, 1)
+
+	require.NoError(t, ip.DiscardDeadLetter(context.Background(), sink.entries[0].Id))
+	assert.Empty(t, sink.entries)
+
+	assert.Error(t, ip.ReplayDeadLetter(context.Background(), "unknown"))
+}
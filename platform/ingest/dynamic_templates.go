@@ -0,0 +1,135 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package ingest
+
+import (
+	chLib "github.com/QuesmaOrg/quesma/platform/database_common"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/schema"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"strings"
+)
+
+// Types of new columns come from the index mapping (PUT /:index, PUT /:index/_mapping): fields defined in it
+// have their types, other fields may match one of its dynamic templates. Types of remaining fields are inferred
+// from values, like before.
+
+// mappingType returns the JSON type of values, which a column of the inferred type has been created for,
+// as seen by `match_mapping_type` of dynamic templates.
+func mappingType(inferredType string) string {
+	baseType := unwrapNullable(inferredType)
+	switch {
+	case baseType == "String":
+		return "string"
+	case baseType == "Int64" || baseType == "UInt64":
+		return "long"
+	case baseType == "Float64":
+		return "double"
+	case baseType == "Bool":
+		return "boolean"
+	case strings.HasPrefix(baseType, "DateTime64"):
+		return "date"
+	default:
+		return "object"
+	}
+}
+
+// hasMultipleValues returns true for types, which the mapping doesn't describe (it doesn't say if a field is an array)
+func hasMultipleValues(inferredType string) bool {
+	return strings.Contains(inferredType, "Array") || strings.Contains(inferredType, "Tuple") || strings.Contains(inferredType, "Map")
+}
+
+// mappedColumnType returns the (Nullable) type of the new column from the mapping, if it defines the field,
+// or from a matching dynamic template.
+func (p SchemaEvolution) mappedColumnType(columnName, propertyName, inferredType string) (string, bool) {
+	if hasMultipleValues(inferredType) {
+		return "", false
+	}
+	if mapped, ok := p.MappedColumns[schema.FieldName(columnName)]; ok {
+		return mapped.ClickHouseType, true
+	}
+	template, ok := schema.MatchDynamicTemplate(p.DynamicTemplates, propertyName, mappingType(inferredType))
+	if !ok || template.Type == "" {
+		return "", false
+	}
+	columnType, ok := clickHouseColumnType(template.Type)
+	if !ok {
+		logger.Warn().Msgf("type '%s' of dynamic template [%s] can't be used for field '%s'", template.Type, template.Name, propertyName)
+		return "", false
+	}
+	return columnType, true
+}
+
+// applyDynamicTemplates changes types of columns of a new table, which match dynamic templates.
+// Columns defined by the mapping are handled by columnsToProperties.
+func (p SchemaEvolution) applyDynamicTemplates(columns []CreateTableEntry, columnsFromSchema map[schema.FieldName]CreateTableEntry,
+	encodings map[schema.FieldEncodingKey]schema.EncodedFieldName, tableName string) {
+	if len(p.DynamicTemplates) == 0 {
+		return
+	}
+	reverseMap := reverseFieldEncoding(encodings, tableName)
+	for i, column := range columns {
+		if _, mapped := columnsFromSchema[schema.FieldName(column.ClickHouseColumnName)]; mapped {
+			continue
+		}
+		propertyName := column.ClickHouseColumnName
+		if field, ok := reverseMap[schema.EncodedFieldName(column.ClickHouseColumnName)]; ok {
+			propertyName = field.FieldName
+		}
+		if columnType, ok := p.mappedColumnType(column.ClickHouseColumnName, propertyName, column.ClickHouseType); ok {
+			columns[i].ClickHouseType = columnType
+		}
+	}
+}
+
+// applyMappedColumnTypes changes types of attributes, which are going to be promoted to columns, to the types
+// from the mapping. It returns names of columns with changed types.
+func (p SchemaEvolution) applyMappedColumnTypes(attrsMap map[string][]interface{}, alteredAttributesIndexes []int,
+	encodings map[schema.FieldEncodingKey]schema.EncodedFieldName, tableName string) []string {
+	if len(p.MappedColumns) == 0 && len(p.DynamicTemplates) == 0 {
+		return nil
+	}
+	attrKeys := getAttributesByArrayName(chLib.DeprecatedAttributesKeyColumn, attrsMap)
+	attrTypes := getAttributesByArrayName(chLib.DeprecatedAttributesValueType, attrsMap)
+	reverseMap := reverseFieldEncoding(encodings, tableName)
+
+	var changed []string
+	for _, i := range alteredAttributesIndexes {
+		if attrTypes[i] == chLib.UndefinedType {
+			continue
+		}
+		propertyName := attrKeys[i]
+		if field, ok := reverseMap[schema.EncodedFieldName(attrKeys[i])]; ok {
+			propertyName = field.FieldName
+		}
+		columnType, ok := p.mappedColumnType(attrKeys[i], propertyName, attrTypes[i])
+		if !ok {
+			continue
+		}
+		// generateNewColumns makes the column Nullable itself
+		baseType := unwrapNullable(columnType)
+		if baseType != attrTypes[i] {
+			attrsMap[chLib.DeprecatedAttributesValueType][i] = baseType
+			changed = append(changed, attrKeys[i])
+		}
+	}
+	return changed
+}
+
+// moveValuesNotMatchingColumns moves values of the document, which don't fit types of the columns, to invalidJson,
+// so they're stored in attributes. It's needed when new columns have types from the mapping instead of types of values.
+func moveValuesNotMatchingColumns(table *chLib.Table, document, invalidJson types.JSON, columnNames []string) types.JSON {
+	for _, columnName := range columnNames {
+		value, ok := document[columnName]
+		column, hasColumn := table.Cols[columnName]
+		if !ok || !hasColumn || validateValueAgainstType(columnName, value, column.Type) {
+			continue
+		}
+		if invalidJson == nil {
+			invalidJson = make(types.JSON)
+		}
+		invalidJson[columnName] = value
+		delete(document, columnName)
+	}
+	return invalidJson
+}
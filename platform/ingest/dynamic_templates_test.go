@@ -0,0 +1,71 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package ingest
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/QuesmaOrg/quesma/platform/config"
+	"github.com/QuesmaOrg/quesma/platform/schema"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewColumnsFromMapping(t *testing.T) {
+	ip, mock, table := newIngestProcessorWithSqlMock(t, config.IndexConfiguration{}, nil)
+	ip.schemaRegistry = schema.NewStaticRegistry(
+		map[schema.IndexName]schema.Schema{tableName: schema.NewSchema(map[schema.FieldName]schema.Field{
+			"level": {PropertyName: "level", InternalPropertyName: "level", Type: schema.QuesmaTypeLong, Origin: schema.FieldSourceMapping},
+		}, false, "")},
+		map[string]schema.Table{tableName: {
+			Columns:          map[string]schema.Column{"level": {Name: "level", Type: schema.QuesmaTypeLong.Name}},
+			DynamicTemplates: []schema.DynamicTemplate{{Name: "counts", Match: []string{"*_count"}, Type: schema.QuesmaTypeLong.Name}},
+		}},
+		map[schema.FieldEncodingKey]schema.EncodedFieldName{{TableName: tableName, FieldName: "level"}: "level"},
+	)
+
+	// "level" is a long in the mapping, "request_count" is a long because of the template, and "message" has inferred type.
+	// "abc" doesn't fit the Int64 column of "level", so it's kept in attributes.
+	mock.ExpectExec(`ALTER TABLE "test_table" ADD COLUMN IF NOT EXISTS "level" Nullable(Int64)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "test_table" COMMENT COLUMN "level" 'quesmaMetadataV1:fieldName=level'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "test_table" ADD COLUMN IF NOT EXISTS "message" Nullable(String)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "test_table" COMMENT COLUMN "message" 'quesmaMetadataV1:fieldName=message'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "test_table" ADD COLUMN IF NOT EXISTS "request_count" Nullable(Int64)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE "test_table" COMMENT COLUMN "request_count" 'quesmaMetadataV1:fieldName=request_count'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "test_table" FORMAT JSONEachRow {"attributes_metadata":{"level":"v1;String"},"attributes_values":{"level":"abc"},"int_field":1,"message":"hi","request_count":"42"}`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, ip.ProcessInsertQuery(context.Background(), tableName,
+		[]types.JSON{types.MustJSON(`{"int_field":1,"level":"abc","request_count":"42","message":"hi"}`)}, &IngestTransformerTest{}, &columNameFormatter{separator: "::"}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, "Int64", table.Cols["level"].Type.String())
+	assert.Equal(t, "Int64", table.Cols["request_count"].Type.String())
+	assert.Equal(t, "String", table.Cols["message"].Type.String())
+}
+
+func TestApplyDynamicTemplates(t *testing.T) {
+	policy := SchemaEvolution{DynamicTemplates: []schema.DynamicTemplate{
+		{Name: "ids", Match: []string{"*_id"}, Type: schema.QuesmaTypeKeyword.Name},
+		{Name: "strings", MatchMappingType: []string{"string"}, Type: schema.QuesmaTypeText.Name},
+		{Name: "numbers", MatchMappingType: []string{"long"}, Type: schema.QuesmaTypeFloat.Name},
+	}}
+	columns := []CreateTableEntry{
+		{ClickHouseColumnName: "user_id", ClickHouseType: "Nullable(Int64)"},
+		{ClickHouseColumnName: "count", ClickHouseType: "Nullable(Int64)"},
+		{ClickHouseColumnName: "tags", ClickHouseType: "Array(String)"},
+		{ClickHouseColumnName: "level", ClickHouseType: "Nullable(Int64)"},
+		{ClickHouseColumnName: "flag", ClickHouseType: "Nullable(Bool)"},
+	}
+	columnsFromSchema := map[schema.FieldName]CreateTableEntry{"level": {ClickHouseColumnName: "level", ClickHouseType: "Nullable(String)"}}
+
+	policy.applyDynamicTemplates(columns, columnsFromSchema, nil, tableName)
+	assert.Equal(t, []CreateTableEntry{
+		{ClickHouseColumnName: "user_id", ClickHouseType: "Nullable(String)"},
+		{ClickHouseColumnName: "count", ClickHouseType: "Nullable(Float64)"},
+		{ClickHouseColumnName: "tags", ClickHouseType: "Array(String)"}, // arrays aren't described by mappings
+		{ClickHouseColumnName: "level", ClickHouseType: "Nullable(Int64)"},
+		{ClickHouseColumnName: "flag", ClickHouseType: "Nullable(Bool)"},
+	}, columns)
+}
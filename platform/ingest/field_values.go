@@ -0,0 +1,186 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package ingest
+
+import (
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/schema"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/goccy/go-json"
+	"math"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// normalizeFieldValues rewrites (in place) values of fields, which are stored differently than they're sent:
+//   - flattened objects become maps from paths of their leaves to the leaf values, e.g. {"a": {"b": 1}} is {"a.b": "1"},
+//   - bounds of ranges are always inclusive, e.g. {"gt": 1, "lt": 5} of an integer_range is {"gte": 2, "lte": 4},
+//     and an ip_range given in CIDR notation is converted to its bounds,
+//   - scaled floats are rounded to their scaling factor, as Elasticsearch does.
+func normalizeFieldValues(document types.JSON, indexSchema *schema.Schema) {
+	if indexSchema == nil {
+		return
+	}
+	for _, field := range indexSchema.Fields {
+		switch {
+		case field.Type.Name == schema.QuesmaTypeFlattened.Name:
+			updateFieldValue(document, field.PropertyName.AsString(), func(value any) any {
+				object, ok := value.(map[string]any)
+				if !ok {
+					return value
+				}
+				leaves := make(map[string]string)
+				flattenLeaves("", object, leaves)
+				return leaves
+			})
+		case field.Type.IsRange():
+			boundType, _ := field.Type.RangeBoundType()
+			updateFieldValue(document, field.PropertyName.AsString(), func(value any) any {
+				return normalizeRange(value, boundType)
+			})
+		case field.Type.Name == schema.QuesmaTypeScaledFloat.Name && field.ScalingFactor > 0:
+			updateFieldValue(document, field.PropertyName.AsString(), func(value any) any {
+				if number, ok := value.(float64); ok {
+					return math.Round(number*field.ScalingFactor) / field.ScalingFactor
+				}
+				return value
+			})
+		}
+	}
+}
+
+// updateFieldValue replaces the value of the field given by its dotted path. Parts of the path may be
+// nested objects or dotted keys, e.g. `a.b.c` is found both in {"a": {"b.c": 1}} and {"a.b": {"c": 1}}.
+func updateFieldValue(document map[string]any, path string, update func(any) any) {
+	if value, ok := document[path]; ok {
+		document[path] = update(value)
+		return
+	}
+	for i := strings.IndexByte(path, '.'); i != -1; i = nextDot(path, i) {
+		if nested, ok := document[path[:i]].(map[string]any); ok {
+			updateFieldValue(nested, path[i+1:], update)
+		}
+	}
+}
+
+func nextDot(path string, previous int) int {
+	if i := strings.IndexByte(path[previous+1:], '.'); i != -1 {
+		return previous + 1 + i
+	}
+	return -1
+}
+
+func flattenLeaves(prefix string, object map[string]any, leaves map[string]string) {
+	for key, value := range object {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch value := value.(type) {
+		case nil:
+		case map[string]any:
+			flattenLeaves(key, value, leaves)
+		case string:
+			leaves[key] = value
+		case float64:
+			leaves[key] = strconv.FormatFloat(value, 'f', -1, 64)
+		case []any:
+			if encoded, err := json.Marshal(value); err == nil {
+				leaves[key] = string(encoded)
+			}
+		default:
+			leaves[key] = fmt.Sprint(value)
+		}
+	}
+}
+
+// normalizeRange returns the range with inclusive bounds only (`gte` and `lte`)
+func normalizeRange(value any, boundType schema.QuesmaType) any {
+	if cidr, ok := value.(string); ok && boundType.Name == schema.QuesmaTypeIp.Name {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return value
+		}
+		prefix = prefix.Masked()
+		return map[string]any{schema.RangeLowerBound: prefix.Addr().String(), schema.RangeUpperBound: lastAddr(prefix).String()}
+	}
+
+	bounds, ok := value.(map[string]any)
+	if !ok {
+		return value
+	}
+	result := make(map[string]any)
+	if gte, ok := bounds["gte"]; ok && gte != nil {
+		result[schema.RangeLowerBound] = normalizeRangeBound(gte, boundType, 0)
+	} else if gt, ok := bounds["gt"]; ok && gt != nil {
+		result[schema.RangeLowerBound] = normalizeRangeBound(gt, boundType, 1)
+	}
+	if lte, ok := bounds["lte"]; ok && lte != nil {
+		result[schema.RangeUpperBound] = normalizeRangeBound(lte, boundType, 0)
+	} else if lt, ok := bounds["lt"]; ok && lt != nil {
+		result[schema.RangeUpperBound] = normalizeRangeBound(lt, boundType, -1)
+	}
+	return result
+}
+
+// normalizeRangeBound returns the bound moved by the smallest possible step in the given direction (-1, 0 or 1)
+func normalizeRangeBound(bound any, boundType schema.QuesmaType, direction int) any {
+	switch boundType.Name {
+	case schema.QuesmaTypeLong.Name:
+		if number, ok := bound.(float64); ok {
+			return number + float64(direction)
+		}
+	case schema.QuesmaTypeFloat.Name:
+		if number, ok := bound.(float64); ok && direction != 0 {
+			return math.Nextafter(number, float64(direction)*math.Inf(1))
+		}
+	case schema.QuesmaTypeTimestamp.Name:
+		if t, ok := parseRangeDate(bound); ok {
+			return t.Add(time.Duration(direction) * time.Millisecond).Format(time.RFC3339Nano)
+		}
+	case schema.QuesmaTypeIp.Name:
+		if s, ok := bound.(string); ok {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return bound
+			}
+			switch direction {
+			case 1:
+				addr = addr.Next()
+			case -1:
+				addr = addr.Prev()
+			}
+			if addr.IsValid() {
+				return addr.String()
+			}
+		}
+	}
+	return bound
+}
+
+func parseRangeDate(value any) (time.Time, bool) {
+	switch value := value.(type) {
+	case float64:
+		return time.UnixMilli(int64(value)).UTC(), true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t.UTC(), true
+			}
+		}
+		if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.UnixMilli(millis).UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	addr := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(addr)*8; bit++ {
+		addr[bit/8] |= 1 << (7 - bit%8)
+	}
+	last, _ := netip.AddrFromSlice(addr)
+	return last
+}
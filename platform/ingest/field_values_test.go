@@ -0,0 +1,67 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package ingest
+
+import (
+	"github.com/QuesmaOrg/quesma/platform/schema"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNormalizeFieldValues(t *testing.T) {
+	indexSchema := &schema.Schema{Fields: map[schema.FieldName]schema.Field{
+		"labels":         {PropertyName: "labels", InternalPropertyName: "labels", Type: schema.QuesmaTypeFlattened},
+		"ages":           {PropertyName: "ages", InternalPropertyName: "ages", Type: schema.QuesmaTypeIntegerRange},
+		"ratio":          {PropertyName: "ratio", InternalPropertyName: "ratio", Type: schema.QuesmaTypeDoubleRange},
+		"event.validity": {PropertyName: "event.validity", InternalPropertyName: "event_validity", Type: schema.QuesmaTypeDateRange},
+		"subnet":         {PropertyName: "subnet", InternalPropertyName: "subnet", Type: schema.QuesmaTypeIpRange},
+		"hosts":          {PropertyName: "hosts", InternalPropertyName: "hosts", Type: schema.QuesmaTypeIpRange},
+		"price":          {PropertyName: "price", InternalPropertyName: "price", Type: schema.QuesmaTypeScaledFloat, ScalingFactor: 100},
+	}}
+
+	document := types.MustJSON(`{
+		"labels": {"env": "prod", "build": {"number": 42, "tags": ["a", "b"], "ok": true}},
+		"ages": {"gt": 10, "lt": 20},
+		"ratio": {"gte": 0.5},
+		"event": {"validity": {"gte": "2024-01-01", "lt": "2024-02-01T00:00:00Z"}},
+		"subnet": "192.168.0.0/16",
+		"hosts": {"gt": "10.0.0.1", "lte": "10.0.0.9"},
+		"price": 1.23456,
+		"message": {"gt": 1}
+	}`)
+	normalizeFieldValues(document, indexSchema)
+
+	assert.Equal(t, types.JSON{
+		"labels":  map[string]string{"env": "prod", "build.number": "42", "build.tags": `["a","b"]`, "build.ok": "true"},
+		"ages":    map[string]any{"gte": 11.0, "lte": 19.0},
+		"ratio":   map[string]any{"gte": 0.5},
+		"event":   map[string]any{"validity": map[string]any{"gte": "2024-01-01T00:00:00Z", "lte": "2024-01-31T23:59:59.999Z"}},
+		"subnet":  map[string]any{"gte": "192.168.0.0", "lte": "192.168.255.255"},
+		"hosts":   map[string]any{"gte": "10.0.0.2", "lte": "10.0.0.9"},
+		"price":   1.23,
+		"message": map[string]any{"gt": 1.0},
+	}, document)
+}
+
+func TestSchemaToColumnsOfSpecializedTypes(t *testing.T) {
+	indexSchema := &schema.Schema{Fields: map[schema.FieldName]schema.Field{
+		"labels":  {PropertyName: "labels", InternalPropertyName: "labels", Type: schema.QuesmaTypeFlattened},
+		"ages":    {PropertyName: "ages", InternalPropertyName: "ages", Type: schema.QuesmaTypeIntegerRange},
+		"service": {PropertyName: "service", InternalPropertyName: "service", Type: schema.QuesmaTypeConstantKeyword, ConstantValue: "it's"},
+	}}
+	encodings := map[schema.FieldEncodingKey]schema.EncodedFieldName{
+		{TableName: tableName, FieldName: "labels"}:   "labels",
+		{TableName: tableName, FieldName: "ages.gte"}: "ages_gte",
+		{TableName: tableName, FieldName: "ages.lte"}: "ages_lte",
+		{TableName: tableName, FieldName: "service"}:  "service",
+	}
+
+	columns := SchemaToColumns(indexSchema, &columNameFormatter{separator: "::"}, tableName, encodings)
+	assert.Equal(t, map[schema.FieldName]CreateTableEntry{
+		"labels":   {ClickHouseColumnName: "labels", ClickHouseType: "Map(String, String)"},
+		"ages_gte": {ClickHouseColumnName: "ages_gte", ClickHouseType: "Nullable(Int64)"},
+		"ages_lte": {ClickHouseColumnName: "ages_lte", ClickHouseType: "Nullable(Int64)"},
+		"service":  {ClickHouseColumnName: "service", ClickHouseType: `LowCardinality(String) DEFAULT 'it\'s'`},
+	}, columns)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/schema"
 	"github.com/QuesmaOrg/quesma/platform/types"
 	"github.com/goccy/go-json"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -35,10 +36,15 @@ func NewHydrolixLowerer(virtualTableStorage persistence.JSONDatabase) *HydrolixL
 		tableCreteStatementMapping: make(map[*chLib.Table]CreateTableStatement),
 	}
 }
-func (ip *HydrolixLowerer) shouldAlterColumns(table *chLib.Table, attrsMap map[string][]interface{}) (bool, []int) {
+func (ip *HydrolixLowerer) shouldAlterColumns(table *chLib.Table, attrsMap map[string][]interface{}, policy SchemaEvolution) (bool, []int) {
 	attrKeys := getAttributesByArrayName(chLib.DeprecatedAttributesKeyColumn, attrsMap)
 	alterColumnIndexes := make([]int, 0)
 
+	if !policy.addsColumns() && table.Name != common_table.TableName {
+		alterColumnIndexes = documentIdAttributeIndexes(attrKeys)
+		return len(alterColumnIndexes) > 0, alterColumnIndexes
+	}
+
 	// this is special case for common table storage
 	// we do always add columns for common table storage
 	if table.Name == common_table.TableName {
@@ -116,7 +122,7 @@ func (ip *HydrolixLowerer) generateNewColumns(
 		newColumns[k] = v
 	}
 
-	for i := range alteredAttributesIndexes {
+	for _, i := range alteredAttributesIndexes {
 
 		columnType := ""
 		modifiers := ""
@@ -174,6 +180,7 @@ func (ip *HydrolixLowerer) generateNewColumns(
 		}
 	}
 
+	sort.Ints(deleteIndexes) // removed from the end, so that indexes of the remaining ones don't change
 	for i := len(deleteIndexes) - 1; i >= 0; i-- {
 		attrsMap[chLib.DeprecatedAttributesKeyColumn] = append(attrsMap[chLib.DeprecatedAttributesKeyColumn][:deleteIndexes[i]], attrsMap[chLib.DeprecatedAttributesKeyColumn][deleteIndexes[i]+1:]...)
 		attrsMap[chLib.DeprecatedAttributesValueType] = append(attrsMap[chLib.DeprecatedAttributesValueType][:deleteIndexes[i]], attrsMap[chLib.DeprecatedAttributesValueType][deleteIndexes[i]+1:]...)
@@ -185,16 +192,20 @@ func (ip *HydrolixLowerer) generateNewColumns(
 func (ip *HydrolixLowerer) GenerateIngestContent(table *chLib.Table,
 	data types.JSON,
 	inValidJson types.JSON,
-	encodings map[schema.FieldEncodingKey]schema.EncodedFieldName) ([]AlterStatement, types.JSON, []NonSchemaField, error) {
+	encodings map[schema.FieldEncodingKey]schema.EncodedFieldName,
+	policy SchemaEvolution) ([]AlterStatement, types.JSON, []NonSchemaField, error) {
 
-	if len(table.Config.Attributes) == 0 {
-		return nil, data, nil, nil
-	}
+	alterStatements := policy.widenColumns(table, data, inValidJson)
 
+	// documents breaking `dynamic: strict` have been rejected before lowering, see rejectStrictDynamicMapping
 	mDiff := DifferenceMap(data, table) // TODO change to DifferenceMap(m, t)
 
+	if len(table.Config.Attributes) == 0 {
+		return alterStatements, data, nil, nil
+	}
+
 	if len(mDiff) == 0 && len(inValidJson) == 0 { // no need to modify, just insert 'js'
-		return nil, data, nil, nil
+		return alterStatements, data, nil, nil
 	}
 
 	// check attributes precondition
@@ -208,10 +219,11 @@ func (ip *HydrolixLowerer) GenerateIngestContent(table *chLib.Table,
 	// otherwise it would contain invalid fields e.g. with wrong types
 	// we only want to add fields that are not part of the schema e.g we don't
 	// have columns for them
-	var alterStatements []AlterStatement
 	ip.ingestCounter.Add(1)
-	if ok, alteredAttributesIndexes := ip.shouldAlterColumns(table, attrsMap); ok {
-		alterStatements = ip.generateNewColumns(attrsMap, table, alteredAttributesIndexes, encodings)
+	if ok, alteredAttributesIndexes := ip.shouldAlterColumns(table, attrsMap, policy); ok {
+		mappedColumns := policy.applyMappedColumnTypes(attrsMap, alteredAttributesIndexes, encodings, table.Name)
+		alterStatements = append(alterStatements, ip.generateNewColumns(attrsMap, table, alteredAttributesIndexes, encodings)...)
+		inValidJson = moveValuesNotMatchingColumns(table, data, inValidJson, mappedColumns)
 	}
 	// If there are some invalid fields, we need to add them to the attributes map
 	// to not lose them and be able to store them later by
@@ -230,6 +242,23 @@ func (ip *HydrolixLowerer) GenerateIngestContent(table *chLib.Table,
 	return alterStatements, onlySchemaFields, nonSchemaFields, nil
 }
 
+// modifyCachedColumnType changes the column type in the cached table definition, and returns the definition.
+func (l *HydrolixLowerer) modifyCachedColumnType(table *chLib.Table, columnName, columnType string) CreateTableStatement {
+	l.tableCreationLock.Lock()
+	defer l.tableCreationLock.Unlock()
+	createTableCmd := l.tableCreteStatementMapping[table]
+	columns := make([]ColumnStatement, len(createTableCmd.Columns))
+	copy(columns, createTableCmd.Columns)
+	for i := range columns {
+		if columns[i].ColumnName == columnName {
+			columns[i].ColumnType = columnType
+		}
+	}
+	createTableCmd.Columns = columns
+	l.tableCreteStatementMapping[table] = createTableCmd
+	return createTableCmd
+}
+
 type TypeId int
 
 const (
@@ -450,6 +479,7 @@ func (l *HydrolixLowerer) LowerToDDL(
 	invalidJsons []types.JSON,
 	encodings map[schema.FieldEncodingKey]schema.EncodedFieldName,
 	createTableCmd CreateTableStatement,
+	policy SchemaEvolution,
 ) ([]string, error) {
 
 	l.tableCreationLock.Lock()
@@ -460,6 +490,27 @@ func (l *HydrolixLowerer) LowerToDDL(
 	}
 	l.tableCreationLock.Unlock()
 
+	// Hydrolix tables are changed by the transform of the ingest request, so instead of altering columns,
+	// types of widened columns are changed in the cached table definition
+	events := make([]map[string]any, 0, len(validatedJsons))
+	for i, preprocessedJson := range validatedJsons {
+		alterStatements, onlySchemaFields, nonSchemaFields, err := l.GenerateIngestContent(table, preprocessedJson,
+			invalidJsons[i], encodings, policy)
+		if err != nil {
+			return nil, fmt.Errorf("error BuildInsertJson, tablename: '%s' : %w", table.Name, err)
+		}
+		for _, alter := range alterStatements {
+			if alter.Type == ModifyColumn {
+				createTableCmd = l.modifyCachedColumnType(table, alter.ColumnName, alter.ColumnType)
+			}
+		}
+		event := convertNonSchemaFieldsToMap(nonSchemaFields)
+		for k, v := range onlySchemaFields {
+			event[k] = v
+		}
+		events = append(events, event)
+	}
+
 	// --- Create Table Section ---
 	createTable := map[string]interface{}{
 		"name": table.Name,
@@ -569,17 +620,7 @@ func (l *HydrolixLowerer) LowerToDDL(
 	// --- Ingest Section ---
 	ingestSlice := make([]map[string]interface{}, 0)
 
-	for i, preprocessedJson := range validatedJsons {
-		_, onlySchemaFields, nonSchemaFields, err := l.GenerateIngestContent(table, preprocessedJson,
-			invalidJsons[i], encodings)
-		if err != nil {
-			return nil, fmt.Errorf("error BuildInsertJson, tablename: '%s' : %v", table.Name, err)
-		}
-		events := convertNonSchemaFieldsToMap(nonSchemaFields)
-
-		for k, v := range onlySchemaFields {
-			events[k] = v
-		}
+	for _, event := range events {
 		ingest := map[string]interface{}{}
 
 		for _, col := range createTableCmd.Columns {
@@ -591,10 +632,10 @@ func (l *HydrolixLowerer) LowerToDDL(
 
 			switch typeInfo.TypeId {
 			case PrimitiveType:
-				if _, exists := events[colName]; !exists {
+				if _, exists := event[colName]; !exists {
 					value = defaultForType(typeInfo.Elements[0].Name)
 				} else {
-					val, _ := CastToType(events[colName], typeInfo.Elements[0].Name)
+					val, _ := CastToType(event[colName], typeInfo.Elements[0].Name)
 					value = val
 
 				}
@@ -602,8 +643,8 @@ func (l *HydrolixLowerer) LowerToDDL(
 			case ArrayType:
 				elemType := typeInfo.Elements[0].Name
 				value = []any{}
-				if events[colName] != nil {
-					for _, elem := range events[colName].([]any) {
+				if event[colName] != nil {
+					for _, elem := range event[colName].([]any) {
 						castedElem, err := CastToType(elem, elemType)
 						if err != nil {
 							logger.ErrorWithCtx(context.Background()).Msgf("Error casting element %v to type %s: %v", elem, elemType, err)
@@ -613,8 +654,8 @@ func (l *HydrolixLowerer) LowerToDDL(
 					}
 				}
 			case MapType:
-				if events[colName] != nil {
-					rawMap, ok := events[colName].(map[string]any)
+				if event[colName] != nil {
+					rawMap, ok := event[colName].(map[string]any)
 					if ok {
 						valType := typeInfo.Elements[1].Name
 						typedMap := make(map[string]any)
@@ -0,0 +1,60 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package ingest
+
+import (
+	"github.com/QuesmaOrg/quesma/platform/config"
+	"github.com/QuesmaOrg/quesma/platform/elasticsearch"
+	"github.com/QuesmaOrg/quesma/platform/index_templates"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/schema"
+	"maps"
+)
+
+// SetIndexTemplateStore replaces the default, in-memory storage of index templates.
+func (ip *IngestProcessor) SetIndexTemplateStore(store *index_templates.Store) {
+	ip.indexTemplates = store
+}
+
+func (ip *IngestProcessor) GetIndexTemplateStore() *index_templates.Store {
+	return ip.indexTemplates
+}
+
+// applyIndexTemplate adds mappings of the index template matching a new index to the index mapping, before
+// the table of the index is created. Fields of the mapping sent with `PUT /:index` take precedence over the template.
+func (ip *IngestProcessor) applyIndexTemplate(indexName string) {
+	if ip.indexTemplates == nil || ip.schemaRegistry == nil {
+		return
+	}
+	resolved, ok := ip.indexTemplates.Resolve(indexName)
+	if !ok || len(resolved.Mappings) == 0 {
+		return
+	}
+	mapping, err := elasticsearch.ParseIndexMappings(resolved.Mappings)
+	if err != nil {
+		logger.Warn().Msgf("can't apply mappings of index template '%s' to index '%s': %v", resolved.IndexTemplate, indexName, err)
+		return
+	}
+
+	if current, found := ip.schemaRegistry.GetDynamicConfiguration(schema.IndexName(indexName)); found {
+		mapping.Columns = maps.Clone(mapping.Columns)
+		for name := range current.Columns {
+			delete(mapping.Columns, name)
+		}
+		mapping = elasticsearch.MergeMappings(mapping, current)
+	}
+	logger.Info().Msgf("applying mappings of index template '%s' to index '%s'", resolved.IndexTemplate, indexName)
+	ip.schemaRegistry.UpdateDynamicConfiguration(schema.IndexName(indexName), mapping)
+}
+
+// templatePartitioningStrategy returns the partitioning strategy of the index template matching a new index, if any.
+func (ip *IngestProcessor) templatePartitioningStrategy(indexName string) config.PartitionStrategy {
+	if ip.indexTemplates == nil {
+		return config.None
+	}
+	resolved, ok := ip.indexTemplates.Resolve(indexName)
+	if !ok {
+		return config.None
+	}
+	return resolved.Quesma.PartitioningStrategy
+}
@@ -0,0 +1,53 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package ingest
+
+import (
+	"github.com/QuesmaOrg/quesma/platform/config"
+	"github.com/QuesmaOrg/quesma/platform/index_templates"
+	"github.com/QuesmaOrg/quesma/platform/persistence"
+	"github.com/QuesmaOrg/quesma/platform/schema"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestApplyIndexTemplate(t *testing.T) {
+	store := index_templates.NewStore(persistence.NewStaticJSONDatabase(), persistence.NewStaticJSONDatabase())
+	require.NoError(t, store.PutIndexTemplate("logs", types.MustJSON(`{"index_patterns":["logs-*"],"template":{
+		"settings":{"index.quesma.partitioningStrategy":"daily"},
+		"mappings":{"dynamic":"strict","properties":{"message":{"type":"text"},"host":{"properties":{"name":{"type":"keyword"}}}},
+			"dynamic_templates":[{"counts":{"match":"*_count","mapping":{"type":"long"}}}]}}}`)))
+
+	// the mapping sent with `PUT /:index` takes precedence over the template
+	registry := schema.NewStaticRegistry(nil, map[string]schema.Table{
+		"logs-2": {Columns: map[string]schema.Column{"message": {Name: "message", Type: "keyword"}}},
+	}, nil)
+
+	ip := newIngestProcessorEmpty()
+	ip.schemaRegistry = registry
+	ip.SetIndexTemplateStore(store)
+
+	ip.applyIndexTemplate("logs-1")
+	mapping, found := registry.GetDynamicConfiguration("logs-1")
+	require.True(t, found)
+	assert.Equal(t, "text", mapping.Columns["message"].Type)
+	assert.Equal(t, "keyword", mapping.Columns["host.name"].Type)
+	assert.Equal(t, "strict", mapping.Dynamic)
+	require.Len(t, mapping.DynamicTemplates, 1)
+	assert.Equal(t, "counts", mapping.DynamicTemplates[0].Name)
+
+	ip.applyIndexTemplate("logs-2")
+	mapping, found = registry.GetDynamicConfiguration("logs-2")
+	require.True(t, found)
+	assert.Equal(t, "keyword", mapping.Columns["message"].Type)
+	assert.Equal(t, "keyword", mapping.Columns["host.name"].Type)
+
+	ip.applyIndexTemplate("metrics")
+	_, found = registry.GetDynamicConfiguration("metrics")
+	assert.False(t, found)
+
+	assert.Equal(t, config.Daily, ip.templatePartitioningStrategy("logs-1"))
+	assert.Equal(t, config.None, ip.templatePartitioningStrategy("metrics"))
+}
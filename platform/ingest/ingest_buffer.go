@@ -0,0 +1,124 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package ingest
+
+import (
+	"context"
+	"errors"
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/QuesmaOrg/quesma/platform/config"
+	"github.com/QuesmaOrg/quesma/platform/ingest/buffer"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	quesma_api "github.com/QuesmaOrg/quesma/platform/v2/core"
+)
+
+// errTableAlreadyExists is returned, when the table has been created by another insert in the meantime.
+// Unlike other errors of lowering documents, trying again succeeds.
+var errTableAlreadyExists = errors.New("table already exists")
+
+// permanentClickhouseErrors are codes of ClickHouse errors caused by inserted documents or the table,
+// inserting the same documents again fails too.
+var permanentClickhouseErrors = map[int32]bool{
+	6:   true, // CANNOT_PARSE_TEXT
+	8:   true, // THERE_IS_NO_COLUMN
+	16:  true, // NO_SUCH_COLUMN_IN_TABLE
+	26:  true, // CANNOT_PARSE_QUOTED_STRING
+	27:  true, // CANNOT_PARSE_INPUT_ASSERTION_FAILED
+	38:  true, // CANNOT_PARSE_DATE
+	41:  true, // CANNOT_PARSE_DATETIME
+	44:  true, // ILLEGAL_COLUMN
+	47:  true, // UNKNOWN_IDENTIFIER
+	53:  true, // TYPE_MISMATCH
+	60:  true, // UNKNOWN_TABLE
+	62:  true, // SYNTAX_ERROR
+	70:  true, // CANNOT_CONVERT_TYPE
+	72:  true, // CANNOT_PARSE_NUMBER
+	81:  true, // UNKNOWN_DATABASE
+	117: true, // INCORRECT_DATA
+	349: true, // CANNOT_INSERT_NULL_IN_ORDINARY_COLUMN
+}
+
+// classifyExecuteError marks errors of ClickHouse, which would happen again, as permanent (see buffer.Permanent).
+// Other ones, e.g. network errors or too many parts, are worth retrying.
+func classifyExecuteError(err error) error {
+	var exception *clickhouse.Exception
+	if errors.As(err, &exception) && permanentClickhouseErrors[exception.Code] {
+		return buffer.Permanent(err)
+	}
+	return err
+}
+
+// EnableIngestBuffer makes Ingest keep documents in a disk-backed buffer, which inserts them in batches later.
+// Documents left in the buffer by the previous run are inserted again.
+func (ip *IngestProcessor) EnableIngestBuffer(cfg config.IngestBufferConfiguration, metrics buffer.Metrics) error {
+	b, err := buffer.New(cfg, ip.insertBuffered, metrics)
+	if err != nil {
+		return err
+	}
+	ip.buffer = b
+	return b.Start()
+}
+
+// IngestBufferFull returns true if the ingest buffer has no room for more documents, so Ingest would fail with buffer.ErrFull.
+func (ip *IngestProcessor) IngestBufferFull() bool {
+	return ip.buffer != nil && ip.buffer.Full()
+}
+
+func (ip *IngestProcessor) insertBuffered(ctx context.Context, index string, documents []types.JSON, lastAttempt bool) error {
+	nameFormatter := DefaultColumnNameFormatter()
+	transformer := IngestTransformerFor(index, ip.cfg)
+	err := ip.processInsertQueryToConnectors(ctx, index, documents, transformer, nameFormatter, lastAttempt)
+	// the other documents have been stored, inserting the batch again would duplicate them
+	var rejectedErr *RejectedDocumentsError
+	if errors.As(err, &rejectedErr) {
+		logger.WarnWithCtx(ctx).Msgf("%d buffered documents of %s have been rejected: %v", len(rejectedErr.Errors), index, err)
+		return nil
+	}
+	return err
+}
+
+// FlushIngestBuffer inserts documents of the index waiting in the ingest buffer (if it's enabled), and waits until
+// they're inserted. It's called before reading or modifying stored documents, so that they include earlier ingested ones.
+func (ip *IngestProcessor) FlushIngestBuffer(ctx context.Context, index string) error {
+	if ip.buffer == nil {
+		return nil
+	}
+	return ip.buffer.Flush(ctx, index)
+}
+
+// rejectBeforeBuffering returns errors of documents, which would be rejected when inserted, by their positions.
+// Documents are inserted from the buffer later, so the client learns about it only this way. Only documents
+// breaking `dynamic: strict` can be found before inserting (see rejectStrictDynamicMapping).
+func (ip *IngestProcessor) rejectBeforeBuffering(indexName string, jsonData []types.JSON) map[int]error {
+	policy := ip.schemaEvolution(indexName)
+	if policy.Dynamic.Normalized() != config.DynamicMappingStrict {
+		return nil
+	}
+	decision := ip.tableResolver.Resolve(quesma_api.IngestPipeline, indexName)
+	if decision.Err != nil {
+		return nil
+	}
+	for _, connectorDecision := range decision.UseConnectors {
+		// the common table keeps documents of many indexes, so its schema is always dynamic
+		clickhouseDecision, ok := connectorDecision.(*quesma_api.ConnectorDecisionClickhouse)
+		if !ok || clickhouseDecision.IsCommonTable {
+			continue
+		}
+		table := ip.FindTable(clickhouseDecision.ClickhouseTableName)
+		if table == nil {
+			return nil
+		}
+		documents := make([]types.JSON, 0, len(jsonData))
+		for _, jsonValue := range jsonData {
+			documents = append(documents, jsonValue.Clone())
+		}
+		prepared, encodings, err := ip.prepareDocuments(table.Name, indexName, documents, IngestTransformerFor(indexName, ip.cfg))
+		if err != nil {
+			return nil
+		}
+		_, _, rejected := policy.rejectStrictDynamicMapping(table, prepared, make([]types.JSON, len(prepared)), encodings)
+		return rejected
+	}
+	return nil
+}
@@ -13,7 +13,7 @@ import (
 )
 
 func removeLowCardinality(columnType string) string {
-	if columnType == "LowCardinality(String)" {
+	if columnType == "LowCardinality(String)" || columnType == "LowCardinality(Nullable(String))" {
 		return "String"
 	}
 	return columnType
@@ -4,9 +4,11 @@ package ingest
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/QuesmaOrg/quesma/platform/backend_connectors"
+	"github.com/QuesmaOrg/quesma/platform/common_table"
 	"github.com/QuesmaOrg/quesma/platform/config"
 	"github.com/QuesmaOrg/quesma/platform/database_common"
 	"github.com/QuesmaOrg/quesma/platform/persistence"
@@ -16,7 +18,9 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/util"
 	mux "github.com/QuesmaOrg/quesma/platform/v2/core"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -429,6 +433,96 @@ func TestCreateTableIfSomeFieldsExistsInSchemaAlready(t *testing.T) {
 	}
 }
 
+// TestCreateDeduplicatingTable verifies that tables of indexes with `deduplicateDocuments` are ReplacingMergeTree sorted by document id
+func TestCreateDeduplicatingTable(t *testing.T) {
+	const indexName = "test_index"
+	quesmaConfig := &config.QuesmaConfiguration{
+		IndexConfig: map[string]config.IndexConfiguration{
+			indexName: {DeduplicateDocuments: true},
+		},
+	}
+
+	conn, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	db := backend_connectors.NewClickHouseBackendConnectorWithConnection("", conn)
+
+	resolver := table_resolver.NewEmptyTableResolver()
+	resolver.Decisions[indexName] = &mux.Decision{
+		UseConnectors: []mux.ConnectorDecision{&mux.ConnectorDecisionClickhouse{ClickhouseTableName: indexName}}}
+
+	ingest := newIngestProcessorWithEmptyTableMap(NewTableMap(), quesmaConfig)
+	ingest.chDb = db
+	ingest.lowerer.virtualTableStorage = persistence.NewStaticJSONDatabase()
+	ingest.schemaRegistry = &schema.StaticRegistry{Tables: make(map[schema.IndexName]schema.Schema)}
+	ingest.tableResolver = resolver
+
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "test_index" ( "@timestamp" DateTime64(3) DEFAULT now64(), "__quesma_id" String DEFAULT '', "attributes_values" Map(String,String), "attributes_metadata" Map(String,String), "message" Nullable(String) COMMENT 'quesmaMetadataV1:fieldName=message', ) ENGINE = ReplacingMergeTree ORDER BY ("@timestamp","__quesma_id") COMMENT 'created by Quesma'`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO "test_index" FORMAT JSONEachRow {"__quesma_id":"a","message":"bar"}`).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	documents := []types.JSON{{"message": "bar", common_table.DocumentIdColumn: "a"}}
+	err = ingest.ProcessInsertQuery(context.Background(), indexName, documents, IngestTransformerFor(indexName, quesmaConfig), DefaultColumnNameFormatter())
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestDeduplicatingTableGeneratesMissingIds verifies that documents ingested without `_id` to a deduplicating table
+// get distinct ids, so that ReplacingMergeTree doesn't merge ones with the same timestamp
+func TestDeduplicatingTableGeneratesMissingIds(t *testing.T) {
+	const indexName = "test_index"
+	quesmaConfig := &config.QuesmaConfiguration{
+		IndexConfig: map[string]config.IndexConfiguration{
+			indexName: {DeduplicateDocuments: true},
+		},
+	}
+
+	var insert string
+	queryMatcher := sqlmock.QueryMatcherFunc(func(expectedSQL, actualSQL string) error {
+		if !strings.HasPrefix(actualSQL, expectedSQL) {
+			return fmt.Errorf("expected SQL '%s' is not a prefix of '%s'", expectedSQL, actualSQL)
+		}
+		if strings.HasPrefix(actualSQL, "INSERT") {
+			insert = actualSQL
+		}
+		return nil
+	})
+	conn, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(queryMatcher))
+	require.NoError(t, err)
+	db := backend_connectors.NewClickHouseBackendConnectorWithConnection("", conn)
+
+	resolver := table_resolver.NewEmptyTableResolver()
+	resolver.Decisions[indexName] = &mux.Decision{
+		UseConnectors: []mux.ConnectorDecision{&mux.ConnectorDecisionClickhouse{ClickhouseTableName: indexName}}}
+
+	ingest := newIngestProcessorWithEmptyTableMap(NewTableMap(), quesmaConfig)
+	ingest.chDb = db
+	ingest.lowerer.virtualTableStorage = persistence.NewStaticJSONDatabase()
+	ingest.schemaRegistry = &schema.StaticRegistry{Tables: make(map[schema.IndexName]schema.Schema)}
+	ingest.tableResolver = resolver
+
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "test_index"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO "test_index" FORMAT JSONEachRow `).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	documents := []types.JSON{
+		{"@timestamp": "2024-01-01T00:00:00.000Z", "message": "foo"},
+		{"@timestamp": "2024-01-01T00:00:00.000Z", "message": "bar"},
+	}
+	err = ingest.ProcessInsertQuery(context.Background(), indexName, documents, IngestTransformerFor(indexName, quesmaConfig), DefaultColumnNameFormatter())
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	var rows []types.JSON
+	require.NoError(t, json.Unmarshal([]byte("["+strings.TrimPrefix(insert, `INSERT INTO "test_index" FORMAT JSONEachRow `)+"]"), &rows))
+	require.Len(t, rows, 2)
+	ids := make(map[string]bool)
+	for _, row := range rows {
+		id, _ := row[common_table.DocumentIdColumn].(string)
+		assert.NotEmpty(t, id)
+		ids[id] = true
+	}
+	assert.Len(t, ids, 2)
+}
+
 func TestHydrolixIngest(t *testing.T) {
 	t.Skip("TODO: this test is not implemented yet, need to implement the Hydrolix backend connector Exec method")
 	indexName := "test_index"
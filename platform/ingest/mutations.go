@@ -0,0 +1,271 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/QuesmaOrg/quesma/platform/common_table"
+	"github.com/QuesmaOrg/quesma/platform/database_common"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"github.com/QuesmaOrg/quesma/platform/v2/core"
+	"sort"
+)
+
+// MutationTarget is the ClickHouse table holding documents of a single index,
+// which we update or delete in place (as opposed to appending new ones with Ingest).
+type MutationTarget struct {
+	IndexName string
+	Table     *database_common.Table
+	// IndexFilter, if not nil, restricts mutations to rows of this index (used for the common table)
+	IndexFilter model.Expr
+}
+
+func (ip *IngestProcessor) ResolveMutationTarget(indexName string) (*MutationTarget, error) {
+	decision := ip.tableResolver.Resolve(quesma_api.IngestPipeline, indexName)
+	if decision.Err != nil {
+		return nil, decision.Err
+	}
+	if decision.IsClosed {
+		return nil, fmt.Errorf("table %s is closed", indexName)
+	}
+
+	for _, connectorDecision := range decision.UseConnectors {
+		clickhouseDecision, ok := connectorDecision.(*quesma_api.ConnectorDecisionClickhouse)
+		if !ok {
+			continue
+		}
+
+		target := &MutationTarget{IndexName: indexName}
+		tableName := clickhouseDecision.ClickhouseTableName
+		if clickhouseDecision.IsCommonTable {
+			tableName = common_table.TableName
+			target.IndexFilter = model.NewInfixExpr(model.NewColumnRef(common_table.IndexNameColumn), "=", model.NewLiteralSingleQuoteString(indexName))
+		}
+		if target.Table = ip.FindTable(tableName); target.Table == nil {
+			return nil, fmt.Errorf("table %s not found", tableName)
+		}
+		return target, nil
+	}
+	return nil, fmt.Errorf("index %s is not stored in ClickHouse", indexName)
+}
+
+// Where returns the WHERE clause selecting rows matching condition, restricted to the target index.
+func (t *MutationTarget) Where(condition model.Expr) model.Expr {
+	if t.IndexFilter == nil {
+		return condition
+	}
+	return model.And([]model.Expr{t.IndexFilter, condition})
+}
+
+// ColumnName returns the column storing a (possibly nested, dot-separated) field, the same one Ingest would use.
+func (t *MutationTarget) ColumnName(fieldName string) (string, error) {
+	columnName := util.FieldToColumnEncoder(fieldName)
+	if _, ok := t.Table.Cols[columnName]; !ok {
+		return "", fmt.Errorf("field [%s] doesn't exist in table %s", fieldName, t.Table.Name)
+	}
+	return columnName, nil
+}
+
+// ValueExpression returns an expression (with a single `?` placeholder) that converts a JSON value to the type of the column.
+func (t *MutationTarget) ValueExpression(columnName string, value any) (string, error) {
+	switch valueTyped := value.(type) {
+	case types.JSON, map[string]any:
+		return "", fmt.Errorf("object value of column %s should be flattened", columnName)
+	case []any:
+		for _, element := range valueTyped {
+			if _, isObject := element.(map[string]any); isObject {
+				return "", fmt.Errorf("updating arrays of objects (column %s) is not supported", columnName)
+			}
+		}
+		return "?", nil
+	}
+
+	_, isString := value.(string)
+	switch t.Table.GetDateTimeType(context.Background(), columnName, false) {
+	case database_common.DateTime64:
+		if isString {
+			return "parseDateTime64BestEffort(?, 9)", nil
+		}
+		return "fromUnixTimestamp64Milli(toInt64(?))", nil
+	case database_common.DateTime:
+		if isString {
+			return "parseDateTimeBestEffort(?)", nil
+		}
+		return "toDateTime(intDiv(toInt64(?), 1000))", nil
+	default:
+		return "?", nil
+	}
+}
+
+// AssignmentsFromDocument translates a partial document (like `doc` of an update request)
+// to assignments of the columns its fields are stored in.
+func (t *MutationTarget) AssignmentsFromDocument(document types.JSON) ([]ColumnAssignment, error) {
+	flattened := util.FlattenMap(document, ".")
+
+	fieldNames := make([]string, 0, len(flattened))
+	for fieldName := range flattened {
+		fieldNames = append(fieldNames, fieldName)
+	}
+	sort.Strings(fieldNames)
+
+	assignments := make([]ColumnAssignment, 0, len(fieldNames))
+	for _, fieldName := range fieldNames {
+		columnName, err := t.ColumnName(fieldName)
+		if err != nil {
+			return nil, err
+		}
+		expression, err := t.ValueExpression(columnName, flattened[fieldName])
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, ColumnAssignment{ColumnName: columnName, Expression: expression, Args: []any{flattened[fieldName]}})
+	}
+	return assignments, nil
+}
+
+func (ip *IngestProcessor) CountDocuments(ctx context.Context, target *MutationTarget, condition model.Expr) (int64, error) {
+	query := fmt.Sprintf(`SELECT count(*) FROM "%s" WHERE %s`, target.Table.Name, model.AsString(target.Where(condition)))
+
+	var count int64
+	if err := ip.chDb.QueryRow(ctx, query).Scan(&count); err != nil {
+		return 0, fmt.Errorf("clickhouse: query row failed: %v", err)
+	}
+	return count, nil
+}
+
+// StoredDocumentIds returns those of ids, which are stored in common_table.DocumentIdColumn of the table.
+func (ip *IngestProcessor) StoredDocumentIds(ctx context.Context, target *MutationTarget, ids []string) (map[string]bool, error) {
+	query := fmt.Sprintf(`SELECT DISTINCT "%s" FROM "%s" WHERE %s`, common_table.DocumentIdColumn, target.Table.Name, model.AsString(target.Where(WithDocumentIds(nil, ids))))
+	storedIds, err := ip.queryDocumentIds(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]bool, len(storedIds))
+	for _, id := range storedIds {
+		stored[id] = true
+	}
+	return stored, nil
+}
+
+// FirstDocumentIds returns at most limit ids of documents matching the condition, the lowest ones. Mutations can't be
+// limited to a number of rows, so that's how a limited set of documents is picked: the same one every time.
+// Documents ingested without `_id` are never picked.
+func (ip *IngestProcessor) FirstDocumentIds(ctx context.Context, target *MutationTarget, condition model.Expr, limit int64) ([]string, error) {
+	hasId := model.NewInfixExpr(model.NewColumnRef(common_table.DocumentIdColumn), "!=", model.NewLiteralSingleQuoteString(""))
+	query := fmt.Sprintf(`SELECT DISTINCT "%s" FROM "%s" WHERE %s ORDER BY "%s" LIMIT %d`, common_table.DocumentIdColumn, target.Table.Name,
+		model.AsString(target.Where(model.And([]model.Expr{condition, hasId}))), common_table.DocumentIdColumn, limit)
+	return ip.queryDocumentIds(ctx, query)
+}
+
+func (ip *IngestProcessor) queryDocumentIds(ctx context.Context, query string) ([]string, error) {
+	rows, err := ip.chDb.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: query failed: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("clickhouse: scan failed: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// WithDocumentIds narrows the condition (if any) to documents with given ids
+func WithDocumentIds(condition model.Expr, ids []string) model.Expr {
+	values := make([]model.Expr, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, model.NewLiteralSingleQuoteString(id))
+	}
+	inIds := model.NewInfixExpr(model.NewColumnRef(common_table.DocumentIdColumn), " IN ", model.NewTupleExpr(values...))
+	if condition == nil {
+		return inIds
+	}
+	return model.And([]model.Expr{condition, inIds})
+}
+
+// PartitionCount is the number of rows matching a condition in a single partition of the table.
+type PartitionCount struct {
+	PartitionId string
+	Count       int64
+}
+
+// CountDocumentsByPartition is used to mutate many documents in batches, one partition at a time.
+func (ip *IngestProcessor) CountDocumentsByPartition(ctx context.Context, target *MutationTarget, condition model.Expr) ([]PartitionCount, error) {
+	query := fmt.Sprintf(`SELECT _partition_id, count(*) FROM "%s" WHERE %s GROUP BY _partition_id ORDER BY _partition_id`,
+		target.Table.Name, model.AsString(target.Where(condition)))
+
+	rows, err := ip.chDb.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: query failed: %v", err)
+	}
+	defer rows.Close()
+
+	var counts []PartitionCount
+	for rows.Next() {
+		var partition PartitionCount
+		if err = rows.Scan(&partition.PartitionId, &partition.Count); err != nil {
+			return nil, fmt.Errorf("clickhouse: scan failed: %v", err)
+		}
+		counts = append(counts, partition)
+	}
+	return counts, rows.Err()
+}
+
+// InPartition restricts condition to rows of a single partition (see CountDocumentsByPartition).
+func InPartition(condition model.Expr, partitionId string) model.Expr {
+	return model.And([]model.Expr{
+		model.NewInfixExpr(model.NewColumnRef("_partition_id"), "=", model.NewLiteralSingleQuoteString(partitionId)),
+		condition,
+	})
+}
+
+// DeleteDocuments removes matching rows with a lightweight DELETE, so they disappear from query results immediately.
+func (ip *IngestProcessor) DeleteDocuments(ctx context.Context, target *MutationTarget, condition model.Expr) error {
+	statement := DeleteStatement{
+		TableName: target.Table.Name,
+		OnCluster: target.Table.ClusterName,
+		Where:     model.AsString(target.Where(condition)),
+	}
+	return ip.executeMutation(ctx, statement.ToSQL())
+}
+
+// UpdateDocuments runs ALTER TABLE ... UPDATE mutation and waits until it's applied on all replicas.
+func (ip *IngestProcessor) UpdateDocuments(ctx context.Context, target *MutationTarget, assignments []ColumnAssignment, condition model.Expr) error {
+	if len(assignments) == 0 {
+		return nil
+	}
+	statement := UpdateStatement{
+		TableName:   target.Table.Name,
+		OnCluster:   target.Table.ClusterName,
+		Assignments: assignments,
+		Where:       model.AsString(target.Where(condition)),
+	}
+	query, args := statement.ToSQL()
+
+	ctx = clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{"mutations_sync": 2}))
+	return ip.executeMutation(ctx, query, args...)
+}
+
+func (ip *IngestProcessor) executeMutation(ctx context.Context, query string, args ...any) error {
+	if ip.cfg.Logging.EnableSQLTracing {
+		logger.InfoWithCtx(ctx).Msgf("mutation execution: %s", query)
+	}
+
+	span := ip.phoneHomeClient.ClickHouseInsertDuration().Begin()
+	err := ip.chDb.Exec(ctx, query, args...)
+	span.End(err)
+	if err != nil {
+		logger.ErrorWithCtx(ctx).Msgf("error executing mutation: %v, query: %s", err, query)
+	}
+	return err
+}
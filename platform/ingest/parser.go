@@ -130,7 +130,7 @@ func JsonToColumns(m SchemaMap, chConfig *database_common.ChTableConfig) []Creat
 		}
 
 		fTypeString := fType.String()
-		if (!strings.Contains(fTypeString, "Array") && !strings.Contains(fTypeString, "Tuple")) && !strings.Contains(fTypeString, "DateTime") {
+		if (!strings.Contains(fTypeString, "Array") && !strings.Contains(fTypeString, "Tuple") && !strings.HasPrefix(fTypeString, "Map")) && !strings.Contains(fTypeString, "DateTime") {
 			fTypeString = "Nullable(" + fTypeString + ")"
 		}
 
@@ -153,13 +153,8 @@ func SchemaToColumns(schemaMapping *schema.Schema, nameFormatter TableColumNameF
 	}
 
 	for _, field := range schemaMapping.Fields {
-		var fType string
 		internalPropertyName := string(fieldEncodings[schema.FieldEncodingKey{TableName: tableName, FieldName: field.PropertyName.AsString()}])
-		switch field.Type.Name {
-		default:
-			logger.Warn().Msgf("Unsupported field type '%s' for field '%s' when trying to create a table. Ignoring that field.", field.Type.Name, field.PropertyName.AsString())
-			continue
-		case schema.QuesmaTypePoint.Name:
+		if field.Type.Name == schema.QuesmaTypePoint.Name {
 			lat := string(fieldEncodings[schema.FieldEncodingKey{TableName: tableName, FieldName: field.PropertyName.AsString() + ".lat"}])
 			lon := string(fieldEncodings[schema.FieldEncodingKey{TableName: tableName, FieldName: field.PropertyName.AsString() + ".lon"}])
 			if len(lat) == 0 || len(lon) == 0 {
@@ -169,28 +164,29 @@ func SchemaToColumns(schemaMapping *schema.Schema, nameFormatter TableColumNameF
 			resultColumns[schema.FieldName(lat)] = CreateTableEntry{ClickHouseColumnName: lat, ClickHouseType: "Nullable(Float64)"}
 			resultColumns[schema.FieldName(lon)] = CreateTableEntry{ClickHouseColumnName: lon, ClickHouseType: "Nullable(Float64)"}
 			continue
-
-		// Simple types:
-		case schema.QuesmaTypeText.Name:
-			fType = "Nullable(String)"
-		case schema.QuesmaTypeKeyword.Name:
-			fType = "Nullable(String)"
-		case schema.QuesmaTypeLong.Name:
-			fType = "Nullable(Int64)"
-		case schema.QuesmaTypeUnsignedLong.Name:
-			fType = "Nullable(Uint64)"
-		case schema.QuesmaTypeTimestamp.Name:
-			fType = "Nullable(DateTime64)"
-		case schema.QuesmaTypeDate.Name:
-			fType = "Nullable(Date)"
-			// TODO: This (and Nullable(DateTime64) above) can be problematic for ingest when when set by user explicitly. We should either not use Nullable in this case
-			// or add some validation logic so that its handled properly.
-			// Example if someone sets `type: date` to a field in schemaOverrides AND this is a timestamp field for which we have dedicated logic (use DateTime64 + add DEFAULT now64())
-			// Ingest will FAIL creating table with "Sorting key contains nullable columns, but merge tree setting `allow_nullable_key` is disabled"
-		case schema.QuesmaTypeFloat.Name:
-			fType = "Nullable(Float64)"
-		case schema.QuesmaTypeBoolean.Name:
-			fType = "Nullable(Bool)"
+		}
+		if boundType, isRange := field.Type.RangeBoundType(); isRange {
+			// like points, ranges are stored in two columns, missing bounds are NULLs
+			fType, _ := clickHouseColumnType(boundType.Name)
+			for _, bound := range []string{schema.RangeLowerBound, schema.RangeUpperBound} {
+				boundColumn := string(fieldEncodings[schema.FieldEncodingKey{TableName: tableName, FieldName: field.PropertyName.AsString() + "." + bound}])
+				if len(boundColumn) == 0 {
+					logger.Error().Msgf("Empty internal property name for %s of range field '%s'. This might result in incorrect table schema.", bound, field.PropertyName.AsString())
+				}
+				resultColumns[schema.FieldName(boundColumn)] = CreateTableEntry{ClickHouseColumnName: boundColumn, ClickHouseType: fType}
+			}
+			continue
+		}
+		if field.Type.Name == schema.QuesmaTypeConstantKeyword.Name && field.ConstantValue != "" {
+			// documents without the field have the value from the mapping
+			constant := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(field.ConstantValue)
+			resultColumns[schema.FieldName(internalPropertyName)] = CreateTableEntry{ClickHouseColumnName: internalPropertyName, ClickHouseType: "LowCardinality(String) DEFAULT '" + constant + "'"}
+			continue
+		}
+		fType, ok := clickHouseColumnType(field.Type.Name)
+		if !ok {
+			logger.Warn().Msgf("Unsupported field type '%s' for field '%s' when trying to create a table. Ignoring that field.", field.Type.Name, field.PropertyName.AsString())
+			continue
 		}
 		if len(internalPropertyName) == 0 {
 			logger.Error().Msgf("Empty internal property name for field '%s'. This might result in incorrect table schema.", field.PropertyName.AsString())
@@ -200,6 +196,44 @@ func SchemaToColumns(schemaMapping *schema.Schema, nameFormatter TableColumNameF
 	return resultColumns
 }
 
+// clickHouseColumnType returns the type of a column storing fields of the (simple) Quesma type.
+func clickHouseColumnType(quesmaType string) (string, bool) {
+	switch quesmaType {
+	case schema.QuesmaTypeText.Name:
+		return "Nullable(String)", true
+	case schema.QuesmaTypeKeyword.Name:
+		return "Nullable(String)", true
+	case schema.QuesmaTypeLong.Name:
+		return "Nullable(Int64)", true
+	case schema.QuesmaTypeUnsignedLong.Name:
+		return "Nullable(Uint64)", true
+	case schema.QuesmaTypeTimestamp.Name:
+		return "Nullable(DateTime64)", true
+	case schema.QuesmaTypeDate.Name:
+		// TODO: This (and Nullable(DateTime64) above) can be problematic for ingest when when set by user explicitly. We should either not use Nullable in this case
+		// or add some validation logic so that its handled properly.
+		// Example if someone sets `type: date` to a field in schemaOverrides AND this is a timestamp field for which we have dedicated logic (use DateTime64 + add DEFAULT now64())
+		// Ingest will FAIL creating table with "Sorting key contains nullable columns, but merge tree setting `allow_nullable_key` is disabled"
+		return "Nullable(Date)", true
+	case schema.QuesmaTypeFloat.Name:
+		return "Nullable(Float64)", true
+	case schema.QuesmaTypeBoolean.Name:
+		return "Nullable(Bool)", true
+	case schema.QuesmaTypeWildcard.Name, schema.QuesmaTypeVersion.Name, schema.QuesmaTypeConstantKeyword.Name, schema.QuesmaTypeMatchOnlyText.Name:
+		return "Nullable(String)", true
+	case schema.QuesmaTypeIp.Name:
+		return "Nullable(String)", true
+	case schema.QuesmaTypeScaledFloat.Name:
+		// values are rounded to the scaling factor when ingested, see normalizeFieldValues
+		return "Nullable(Float64)", true
+	case schema.QuesmaTypeFlattened.Name:
+		// leaves of the object are keys of the map, e.g. {"a": {"b": 1}} is {'a.b': '1'}
+		return "Map(String, String)", true
+	default:
+		return "", false
+	}
+}
+
 // Returns map with fields that are in 'sm', but not in our table schema 't'.
 // Works with nested JSONs.
 // Doesn't check any types of fields, only names.
@@ -0,0 +1,178 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultDateOutputFormat = "yyyy-MM-dd'T'HH:mm:ss.SSSXXX"
+
+type dateProcessor struct {
+	fieldProcessor
+	formats      []string
+	timezone     *time.Location
+	outputLayout string
+}
+
+func newDateProcessor(opts *options) (Processor, error) {
+	p := &dateProcessor{}
+	var err error
+	if p.field, err = opts.requiredString("field"); err != nil {
+		return nil, err
+	}
+	if p.targetField, err = opts.optionalString("target_field", "@timestamp"); err != nil {
+		return nil, err
+	}
+	if p.ignoreMissing, err = opts.optionalBool("ignore_missing", false); err != nil {
+		return nil, err
+	}
+	if p.formats, err = opts.stringList("formats", true); err != nil {
+		return nil, err
+	}
+	timezone, err := opts.optionalString("timezone", "UTC")
+	if err != nil {
+		return nil, err
+	}
+	if p.timezone, err = parseTimezone(timezone); err != nil {
+		return nil, err
+	}
+	if _, err = opts.optionalString("locale", "ENGLISH"); err != nil {
+		return nil, err
+	}
+	outputFormat, err := opts.optionalString("output_format", defaultDateOutputFormat)
+	if err != nil {
+		return nil, err
+	}
+	p.outputLayout = javaDateFormatToGoLayout(outputFormat)
+	return p, nil
+}
+
+func (p *dateProcessor) Process(doc *Document) error {
+	value, ok, err := p.value(doc)
+	if !ok {
+		return err
+	}
+	asString := stringify(value)
+	for _, format := range p.formats {
+		if parsed, err := parseDate(asString, format, p.timezone); err == nil {
+			return p.setTarget(doc, parsed.Format(p.outputLayout))
+		}
+	}
+	return fmt.Errorf("unable to parse date [%s] with formats %v", asString, p.formats)
+}
+
+// stringify formats the value of a field as a string, with integral numbers (e.g. epoch millis) without a fraction
+func stringify(value any) string {
+	if number, ok := value.(float64); ok && number == math.Trunc(number) && math.Abs(number) < 1e15 {
+		return strconv.FormatInt(int64(number), 10)
+	}
+	if value == nil {
+		return "null"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func parseTimezone(timezone string) (*time.Location, error) {
+	if timezone == "UTC" || timezone == "Z" {
+		return time.UTC, nil
+	}
+	if location, err := time.LoadLocation(timezone); err == nil {
+		return location, nil
+	}
+	// fixed offsets, e.g. +01:00 or -0500
+	if offset, err := time.Parse("-07:00", timezone); err == nil {
+		_, seconds := offset.Zone()
+		return time.FixedZone(timezone, seconds), nil
+	}
+	if offset, err := time.Parse("-0700", timezone); err == nil {
+		_, seconds := offset.Zone()
+		return time.FixedZone(timezone, seconds), nil
+	}
+	return nil, fmt.Errorf("unknown timezone [%s]", timezone)
+}
+
+var iso8601Layouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+func parseDate(value, format string, timezone *time.Location) (time.Time, error) {
+	switch format {
+	case "ISO8601":
+		for _, layout := range iso8601Layouts {
+			if parsed, err := time.ParseInLocation(layout, value, timezone); err == nil {
+				return parsed, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("not an ISO8601 date: %s", value)
+	case "UNIX":
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.UnixMilli(int64(seconds * 1000)).In(timezone), nil
+	case "UNIX_MS":
+		milliseconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.UnixMilli(milliseconds).In(timezone), nil
+	default:
+		return time.ParseInLocation(javaDateFormatToGoLayout(format), value, timezone)
+	}
+}
+
+// javaDateFormatToGoLayout converts Java's DateTimeFormatter patterns (used by Elasticsearch) to Go layouts.
+var javaDateTokens = []struct{ java, golang string }{
+	{"yyyy", "2006"}, {"uuuu", "2006"}, {"yy", "06"}, {"uu", "06"},
+	{"MMMM", "January"}, {"MMM", "Jan"}, {"MM", "01"}, {"M", "1"},
+	{"dd", "02"}, {"d", "2"},
+	{"EEEE", "Monday"}, {"EEE", "Mon"},
+	{"HH", "15"}, {"H", "15"}, {"hh", "03"}, {"h", "3"},
+	{"mm", "04"}, {"m", "4"},
+	{"ss", "05"}, {"s", "5"},
+	{"SSSSSSSSS", "000000000"}, {"SSSSSS", "000000"}, {"SSS", "000"}, {"SS", "00"}, {"S", "0"},
+	{"a", "PM"},
+	{"XXX", "Z07:00"}, {"XX", "Z0700"}, {"X", "Z07"},
+	{"ZZZ", "-07:00"}, {"ZZ", "-07:00"}, {"Z", "-0700"},
+	{"z", "MST"},
+}
+
+func javaDateFormatToGoLayout(format string) string {
+	var layout strings.Builder
+	for i := 0; i < len(format); {
+		if format[i] == '\'' {
+			end := strings.IndexByte(format[i+1:], '\'')
+			if end == -1 {
+				layout.WriteString(format[i+1:])
+				break
+			}
+			layout.WriteString(format[i+1 : i+1+end])
+			i += end + 2
+			continue
+		}
+		matched := false
+		for _, token := range javaDateTokens {
+			if strings.HasPrefix(format[i:], token.java) {
+				layout.WriteString(token.golang)
+				i += len(token.java)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			layout.WriteByte(format[i])
+			i++
+		}
+	}
+	return layout.String()
+}
@@ -0,0 +1,186 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// dissectKey is a single `%{...}` of a dissect pattern, e.g. `%{+name/2}` or `%{status->}`.
+type dissectKey struct {
+	name         string
+	skip         bool   // `%{}` or `%{?name}`
+	appendOrder  int    // -1 unless `+` modifier
+	reference    string // "" or "*" (key name) or "&" (value)
+	rightPadding bool   // `->` modifier, skips repeated delimiters
+	// delimiter following the key, empty for the last key
+	delimiter string
+}
+
+type dissectPattern struct {
+	pattern string
+	prefix  string
+	keys    []dissectKey
+}
+
+var dissectKeyRegexp = regexp.MustCompile(`%\{([^}]*)}`)
+
+func parseDissectPattern(pattern string) (*dissectPattern, error) {
+	result := &dissectPattern{pattern: pattern}
+	matches := dissectKeyRegexp.FindAllStringSubmatchIndex(pattern, -1)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("Unable to parse pattern: %s", pattern)
+	}
+	result.prefix = pattern[:matches[0][0]]
+
+	for i, match := range matches {
+		key := dissectKey{name: pattern[match[2]:match[3]], appendOrder: -1}
+		if i+1 < len(matches) {
+			key.delimiter = pattern[match[1]:matches[i+1][0]]
+			if key.delimiter == "" {
+				return nil, fmt.Errorf("Unable to parse pattern: %s, keys must be separated by delimiters", pattern)
+			}
+		} else if suffix := pattern[match[1]:]; suffix != "" {
+			key.delimiter = suffix
+		}
+
+		if strings.HasSuffix(key.name, "->") {
+			key.rightPadding = true
+			key.name = strings.TrimSuffix(key.name, "->")
+		}
+		switch {
+		case key.name == "":
+			key.skip = true
+		case strings.HasPrefix(key.name, "?"):
+			key.skip = true
+			key.name = key.name[1:]
+		case strings.HasPrefix(key.name, "+"):
+			key.name = key.name[1:]
+			key.appendOrder = 0
+			if name, order, hasOrder := strings.Cut(key.name, "/"); hasOrder {
+				parsedOrder, err := strconv.Atoi(order)
+				if err != nil {
+					return nil, fmt.Errorf("Unable to parse pattern: %s, invalid append order [%s]", pattern, order)
+				}
+				key.name, key.appendOrder = name, parsedOrder
+			}
+		case strings.HasPrefix(key.name, "*"), strings.HasPrefix(key.name, "&"):
+			key.reference = key.name[:1]
+			key.name = key.name[1:]
+		}
+		result.keys = append(result.keys, key)
+	}
+	return result, nil
+}
+
+// parse returns values of keys, or an error if the value doesn't match the pattern
+func (d *dissectPattern) parse(value, appendSeparator string) (map[string]string, error) {
+	noMatch := fmt.Errorf("Unable to find match for dissect pattern: %s against source: %s", d.pattern, value)
+	if !strings.HasPrefix(value, d.prefix) {
+		return nil, noMatch
+	}
+	rest := value[len(d.prefix):]
+
+	type appended struct {
+		order, position int
+		value           string
+	}
+	appends := make(map[string][]appended)
+	referenceNames := make(map[string]string)
+	referenceValues := make(map[string]string)
+	result := make(map[string]string)
+
+	for i, key := range d.keys {
+		var keyValue string
+		if key.delimiter == "" {
+			keyValue, rest = rest, ""
+		} else {
+			index := strings.Index(rest, key.delimiter)
+			if index == -1 {
+				return nil, noMatch
+			}
+			keyValue, rest = rest[:index], rest[index+len(key.delimiter):]
+			if key.rightPadding {
+				for strings.HasPrefix(rest, key.delimiter) {
+					rest = rest[len(key.delimiter):]
+				}
+			}
+			if i == len(d.keys)-1 && rest != "" {
+				return nil, noMatch
+			}
+		}
+
+		switch {
+		case key.skip:
+		case key.appendOrder >= 0:
+			appends[key.name] = append(appends[key.name], appended{order: key.appendOrder, position: i, value: keyValue})
+		case key.reference == "*":
+			referenceNames[key.name] = keyValue
+		case key.reference == "&":
+			referenceValues[key.name] = keyValue
+		default:
+			result[key.name] = keyValue
+		}
+	}
+
+	for name, values := range appends {
+		sort.SliceStable(values, func(i, j int) bool { return values[i].order < values[j].order })
+		parts := make([]string, len(values))
+		for i, value := range values {
+			parts[i] = value.value
+		}
+		result[name] = strings.Join(parts, appendSeparator)
+	}
+	for reference, name := range referenceNames {
+		if referenceValue, ok := referenceValues[reference]; ok {
+			result[name] = referenceValue
+		}
+	}
+	return result, nil
+}
+
+type dissectProcessor struct {
+	fieldProcessor
+	pattern         *dissectPattern
+	appendSeparator string
+}
+
+func newDissectProcessor(opts *options) (Processor, error) {
+	p := &dissectProcessor{}
+	var err error
+	if p.fieldProcessor, err = parseFieldOptions(opts); err != nil {
+		return nil, err
+	}
+	pattern, err := opts.requiredString("pattern")
+	if err != nil {
+		return nil, err
+	}
+	if p.pattern, err = parseDissectPattern(pattern); err != nil {
+		return nil, err
+	}
+	if p.appendSeparator, err = opts.optionalString("append_separator", ""); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *dissectProcessor) Process(doc *Document) error {
+	value, ok, err := p.stringValue(doc)
+	if !ok {
+		return err
+	}
+	parsed, err := p.pattern.parse(value, p.appendSeparator)
+	if err != nil {
+		return err
+	}
+	for field, fieldValue := range parsed {
+		if err = doc.Set(field, fieldValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// grokPatterns are the most popular patterns of Elasticsearch's grok library (legacy, non-ECS field names).
+// Go's regexp (RE2) doesn't support look-arounds nor atomic groups, so some of them are simplified.
+var grokPatterns = map[string]string{
+	"USERNAME":          `[a-zA-Z0-9._-]+`,
+	"USER":              `%{USERNAME}`,
+	"EMAILLOCALPART":    `[a-zA-Z0-9!#$%&'*+\-/=?^_{|}~]+(?:\.[a-zA-Z0-9!#$%&'*+\-/=?^_{|}~]+)*`,
+	"EMAILADDRESS":      `%{EMAILLOCALPART}@%{HOSTNAME}`,
+	"INT":               `(?:[+-]?(?:[0-9]+))`,
+	"BASE10NUM":         `(?:[+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+))`,
+	"NUMBER":            `(?:%{BASE10NUM})`,
+	"BASE16NUM":         `(?:[+-]?(?:0x)?(?:[0-9A-Fa-f]+))`,
+	"POSINT":            `\b(?:[1-9][0-9]*)\b`,
+	"NONNEGINT":         `\b(?:[0-9]+)\b`,
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"QUOTEDSTRING":      `(?:"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|` + "`(?:[^`\\\\]|\\\\.)*`)",
+	"QS":                `%{QUOTEDSTRING}`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"CISCOMAC":          `(?:(?:[A-Fa-f0-9]{4}\.){2}[A-Fa-f0-9]{4})`,
+	"WINDOWSMAC":        `(?:(?:[A-Fa-f0-9]{2}-){5}[A-Fa-f0-9]{2})`,
+	"COMMONMAC":         `(?:(?:[A-Fa-f0-9]{2}:){5}[A-Fa-f0-9]{2})`,
+	"MAC":               `(?:%{CISCOMAC}|%{WINDOWSMAC}|%{COMMONMAC})`,
+	"IPV6":              `(?:(?:[0-9A-Fa-f]{1,4}:){7}[0-9A-Fa-f]{1,4}|(?:[0-9A-Fa-f]{1,4}:){1,7}:|(?:[0-9A-Fa-f]{1,4}:){1,6}(?::[0-9A-Fa-f]{1,4}){1,6}|:(?::[0-9A-Fa-f]{1,4}){1,7}|::)(?:%\w+)?`,
+	"IPV4":              `(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9]{1,2})\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9]{1,2})`,
+	"IP":                `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":          `\b(?:[0-9A-Za-z][0-9A-Za-z-]{0,62})(?:\.(?:[0-9A-Za-z][0-9A-Za-z-]{0,62}))*\.?`,
+	"HOST":              `%{HOSTNAME}`,
+	"IPORHOST":          `(?:%{IP}|%{HOSTNAME})`,
+	"HOSTPORT":          `%{IPORHOST}:%{POSINT}`,
+	"UNIXPATH":          `(?:/[\w_%!$@:.,+~-]*)+`,
+	"WINPATH":           `(?:[A-Za-z]+:|\\)(?:\\[^\\?*]*)+`,
+	"PATH":              `(?:%{UNIXPATH}|%{WINPATH})`,
+	"URIPROTO":          `[A-Za-z][A-Za-z0-9+\-.]+`,
+	"URIHOST":           `%{IPORHOST}(?::%{POSINT})?`,
+	"URIPATH":           `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_\-]*)+`,
+	"URIPARAM":          `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\-\[\]<>]*`,
+	"URIPATHPARAM":      `%{URIPATH}(?:%{URIPARAM})?`,
+	"URI":               `%{URIPROTO}://(?:%{USER}(?::[^@]*)?@)?(?:%{URIHOST})?(?:%{URIPATHPARAM})?`,
+	"MONTH":             `\b(?:[Jj]an(?:uary|uar)?|[Ff]eb(?:ruary|ruar)?|[Mm](?:a|ä)?r(?:ch|z)?|[Aa]pr(?:il)?|[Mm]a(?:y|i)?|[Jj]un(?:e|i)?|[Jj]ul(?:y|i)?|[Aa]ug(?:ust)?|[Ss]ep(?:tember)?|[Oo](?:c|k)?t(?:ober)?|[Nn]ov(?:ember)?|[Dd]e(?:c|z)(?:ember)?)\b`,
+	"MONTHNUM":          `(?:0?[1-9]|1[0-2])`,
+	"MONTHNUM2":         `(?:0[1-9]|1[0-2])`,
+	"MONTHDAY":          `(?:(?:0[1-9])|(?:[12][0-9])|(?:3[01])|[1-9])`,
+	"DAY":               `(?:Mon(?:day)?|Tue(?:sday)?|Wed(?:nesday)?|Thu(?:rsday)?|Fri(?:day)?|Sat(?:urday)?|Sun(?:day)?)`,
+	"YEAR":              `(?:\d\d){1,2}`,
+	"HOUR":              `(?:2[0123]|[01]?[0-9])`,
+	"MINUTE":            `(?:[0-5][0-9])`,
+	"SECOND":            `(?:(?:[0-5]?[0-9]|60)(?:[:.,][0-9]+)?)`,
+	"TIME":              `%{HOUR}:%{MINUTE}(?::%{SECOND})?`,
+	"DATE_US":           `%{MONTHNUM}[/-]%{MONTHDAY}[/-]%{YEAR}`,
+	"DATE_EU":           `%{MONTHDAY}[./-]%{MONTHNUM}[./-]%{YEAR}`,
+	"ISO8601_TIMEZONE":  `(?:Z|[+-]%{HOUR}(?::?%{MINUTE}))`,
+	"ISO8601_SECOND":    `%{SECOND}`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?%{ISO8601_TIMEZONE}?`,
+	"DATE":              `%{DATE_US}|%{DATE_EU}`,
+	"DATESTAMP":         `%{DATE}[- ]%{TIME}`,
+	"TZ":                `(?:[APMCE][SD]T|UTC)`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}`,
+	"SYSLOGTIMESTAMP":   `%{MONTH} +%{MONTHDAY} %{TIME}`,
+	"PROG":              `[\x21-\x5a\x5c\x5e-\x7e]+`,
+	"SYSLOGPROG":        `%{PROG:program}(?:\[%{POSINT:pid}\])?`,
+	"SYSLOGHOST":        `%{IPORHOST}`,
+	"LOGLEVEL":          `(?:[Aa]lert|ALERT|[Tt]race|TRACE|[Dd]ebug|DEBUG|[Nn]otice|NOTICE|[Ii]nfo?(?:rmation)?|INFO?(?:RMATION)?|[Ww]arn?(?:ing)?|WARN?(?:ING)?|[Ee]rr?(?:or)?|ERR?(?:OR)?|[Cc]rit?(?:ical)?|CRIT?(?:ICAL)?|[Ff]atal|FATAL|[Ss]evere|SEVERE|EMERG(?:ENCY)?|[Ee]merg(?:ency)?)`,
+	"HTTPDUSER":         `%{EMAILADDRESS}|%{USER}`,
+	"COMMONAPACHELOG":   `%{IPORHOST:clientip} %{HTTPDUSER:ident} %{HTTPDUSER:auth} \[%{HTTPDATE:timestamp}\] "(?:%{WORD:verb} %{NOTSPACE:request}(?: HTTP/%{NUMBER:httpversion})?|%{DATA:rawrequest})" %{NUMBER:response} (?:%{NUMBER:bytes}|-)`,
+	"COMBINEDAPACHELOG": `%{COMMONAPACHELOG} %{QS:referrer} %{QS:agent}`,
+}
+
+var (
+	grokReference    = regexp.MustCompile(`%\{(\w+)(?::([^:}]+))?(?::(\w+))?}`)
+	grokNamedCapture = regexp.MustCompile(`\(\?P?<([^>]+)>`)
+)
+
+// maximum nesting of pattern references, protects against recursive definitions
+const grokMaxDepth = 16
+
+type grokCapture struct {
+	field     string
+	valueType string // "", "int", "long", "float", "double" or "boolean"
+}
+
+type grokExpression struct {
+	regexp *regexp.Regexp
+	// captures by regexp group name
+	captures map[string]grokCapture
+}
+
+func compileGrok(pattern string, definitions map[string]string) (*grokExpression, error) {
+	expression := &grokExpression{captures: make(map[string]grokCapture)}
+	expanded, err := expression.expand(pattern, definitions, 0)
+	if err != nil {
+		return nil, err
+	}
+	if expression.regexp, err = regexp.Compile(expanded); err != nil {
+		return nil, fmt.Errorf("invalid grok pattern [%s]: %w", pattern, err)
+	}
+	return expression, nil
+}
+
+func (g *grokExpression) addCapture(field, valueType string) string {
+	groupName := "g" + strconv.Itoa(len(g.captures))
+	g.captures[groupName] = grokCapture{field: field, valueType: valueType}
+	return groupName
+}
+
+// expand replaces `%{NAME:field:type}` references with regexp groups. Field names can't be used
+// as group names directly (they may contain dots), so groups are numbered and mapped to fields.
+func (g *grokExpression) expand(pattern string, definitions map[string]string, depth int) (string, error) {
+	if depth > grokMaxDepth {
+		return "", fmt.Errorf("grok pattern references are nested too deeply, circular reference?")
+	}
+
+	// Oniguruma named groups, e.g. (?<queue_id>[0-9A-F]{10,11})
+	pattern = grokNamedCapture.ReplaceAllStringFunc(pattern, func(match string) string {
+		field := grokNamedCapture.FindStringSubmatch(match)[1]
+		return "(?P<" + g.addCapture(field, "") + ">"
+	})
+
+	var expandErr error
+	result := grokReference.ReplaceAllStringFunc(pattern, func(match string) string {
+		if expandErr != nil {
+			return ""
+		}
+		parts := grokReference.FindStringSubmatch(match)
+		name, field, valueType := parts[1], parts[2], parts[3]
+
+		definition, exists := definitions[name]
+		if !exists {
+			if definition, exists = grokPatterns[name]; !exists {
+				expandErr = fmt.Errorf("Unable to find pattern [%s] in Grok's pattern dictionary", name)
+				return ""
+			}
+		}
+		switch valueType {
+		case "", "int", "long", "float", "double", "boolean", "string":
+		default:
+			expandErr = fmt.Errorf("unsupported grok type [%s] of field [%s]", valueType, field)
+			return ""
+		}
+
+		expanded, err := g.expand(definition, definitions, depth+1)
+		if err != nil {
+			expandErr = err
+			return ""
+		}
+		if field == "" {
+			return "(?:" + expanded + ")"
+		}
+		return "(?P<" + g.addCapture(field, valueType) + ">" + expanded + ")"
+	})
+	return result, expandErr
+}
+
+// match returns captured fields, or nil if the value doesn't match
+func (g *grokExpression) match(value string) (map[string]any, error) {
+	submatches := g.regexp.FindStringSubmatchIndex(value)
+	if submatches == nil {
+		return nil, nil
+	}
+	result := make(map[string]any)
+	for i, groupName := range g.regexp.SubexpNames() {
+		capture, isCapture := g.captures[groupName]
+		if !isCapture || submatches[2*i] < 0 {
+			continue
+		}
+		if _, alreadyCaptured := result[capture.field]; alreadyCaptured {
+			continue
+		}
+		captured := value[submatches[2*i]:submatches[2*i+1]]
+		converted, err := convertGrokValue(captured, capture.valueType)
+		if err != nil {
+			return nil, err
+		}
+		result[capture.field] = converted
+	}
+	return result, nil
+}
+
+func convertGrokValue(value, valueType string) (any, error) {
+	switch valueType {
+	case "int", "long":
+		return convertValue(value, "long")
+	case "float", "double":
+		return convertValue(value, "double")
+	case "boolean":
+		return convertValue(value, "boolean")
+	default:
+		return value, nil
+	}
+}
+
+type grokProcessor struct {
+	fieldProcessor
+	expressions []*grokExpression
+	traceMatch  bool
+}
+
+func newGrokProcessor(opts *options) (Processor, error) {
+	p := &grokProcessor{}
+	var err error
+	if p.fieldProcessor, err = parseFieldOptions(opts); err != nil {
+		return nil, err
+	}
+	if p.field != p.targetField {
+		return nil, fmt.Errorf("[target_field] is not supported")
+	}
+	patterns, err := opts.stringList("patterns", true)
+	if err != nil {
+		return nil, err
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("[patterns] List of patterns must not be empty")
+	}
+
+	definitions := make(map[string]string)
+	if definitionsRaw, exists := opts.get("pattern_definitions"); exists {
+		asMap, ok := asObject(definitionsRaw)
+		if !ok {
+			return nil, fmt.Errorf("[pattern_definitions] property should be an object, got %T", definitionsRaw)
+		}
+		for name, definition := range asMap {
+			if definitions[name], ok = definition.(string); !ok {
+				return nil, fmt.Errorf("[pattern_definitions] pattern [%s] should be a string, got %T", name, definition)
+			}
+		}
+	}
+	if p.traceMatch, err = opts.optionalBool("trace_match", false); err != nil {
+		return nil, err
+	}
+	if _, err = opts.optionalString("ecs_compatibility", ""); err != nil {
+		return nil, err
+	}
+
+	for _, pattern := range patterns {
+		expression, err := compileGrok(pattern, definitions)
+		if err != nil {
+			return nil, err
+		}
+		p.expressions = append(p.expressions, expression)
+	}
+	return p, nil
+}
+
+func (p *grokProcessor) Process(doc *Document) error {
+	value, ok, err := p.stringValue(doc)
+	if !ok {
+		return err
+	}
+	for i, expression := range p.expressions {
+		captured, err := expression.match(value)
+		if err != nil {
+			return err
+		}
+		if captured == nil {
+			continue
+		}
+		for field, capturedValue := range captured {
+			if err = doc.Set(field, capturedValue); err != nil {
+				return err
+			}
+		}
+		if p.traceMatch && len(p.expressions) > 1 {
+			doc.Ingest["_grok_match_index"] = strconv.Itoa(i)
+		}
+		return nil
+	}
+	return fmt.Errorf("Provided Grok expressions do not match field value: [%s]", strings.TrimSpace(value))
+}
@@ -0,0 +1,295 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+
+// Package pipeline implements Elasticsearch ingest pipelines, so that documents stored in ClickHouse
+// can be transformed the same way Elasticsearch would do it before indexing.
+// Ref: https://www.elastic.co/guide/en/elasticsearch/reference/current/ingest.html
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NonePipeline is a special pipeline name, which disables running any pipeline.
+const NonePipeline = "_none"
+
+type Pipeline struct {
+	Id          string
+	Description string
+	Processors  []Processor
+	OnFailure   []Processor
+	// Definition is the original JSON definition, returned by GET _ingest/pipeline
+	Definition types.JSON
+}
+
+// Getter returns a stored pipeline by id, it's used by the `pipeline` processor.
+type Getter func(id string) (*Pipeline, error)
+
+type Document struct {
+	Source types.JSON
+	// Metadata holds `_index`, `_id`, `_routing` and `_version` of the document
+	Metadata map[string]any
+	// Ingest holds `_ingest.*` fields, e.g. `_ingest.timestamp` and error details in `on_failure` handlers
+	Ingest map[string]any
+
+	Dropped bool
+
+	getPipeline Getter
+	// pipelines being executed, to detect cycles of `pipeline` processors
+	pipelineStack []string
+}
+
+func NewDocument(source types.JSON, index, id string) *Document {
+	metadata := map[string]any{"_index": index}
+	if id != "" {
+		metadata["_id"] = id
+	}
+	if source == nil {
+		source = types.JSON{}
+	}
+	return &Document{
+		Source:   source,
+		Metadata: metadata,
+		Ingest:   map[string]any{"timestamp": time.Now().UTC().Format(time.RFC3339Nano)},
+	}
+}
+
+func Parse(id string, definition types.JSON) (*Pipeline, error) {
+	pipeline := &Pipeline{Id: id, Definition: definition}
+
+	for key, value := range definition {
+		switch key {
+		case "description":
+			description, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("[description] should be a string, got %T", value)
+			}
+			pipeline.Description = description
+		case "processors", "on_failure":
+			processors, err := parseProcessors(key, value)
+			if err != nil {
+				return nil, err
+			}
+			if key == "processors" {
+				pipeline.Processors = processors
+			} else {
+				pipeline.OnFailure = processors
+			}
+		case "version", "_meta", "deprecated":
+			// accepted, but irrelevant for execution
+		default:
+			return nil, fmt.Errorf("pipeline [%s] doesn't support property [%s]", id, key)
+		}
+	}
+
+	if _, ok := definition["processors"]; !ok {
+		return nil, fmt.Errorf("[processors] required property is missing")
+	}
+	return pipeline, nil
+}
+
+// Run executes the pipeline on the document in place.
+// If the document is dropped by a `drop` processor, doc.Dropped is set and no error is returned.
+func (p *Pipeline) Run(doc *Document, getPipeline Getter) error {
+	doc.getPipeline = getPipeline
+	return p.run(doc)
+}
+
+func (p *Pipeline) run(doc *Document) error {
+	for _, running := range doc.pipelineStack {
+		if running == p.Id {
+			return fmt.Errorf("cycle detected for pipeline: %s", strings.Join(append(doc.pipelineStack, p.Id), " -> "))
+		}
+	}
+	doc.pipelineStack = append(doc.pipelineStack, p.Id)
+	defer func() { doc.pipelineStack = doc.pipelineStack[:len(doc.pipelineStack)-1] }()
+
+	err := runProcessors(p.Processors, doc)
+	if err != nil && len(p.OnFailure) > 0 {
+		return handleFailure(err, p.OnFailure, doc)
+	}
+	return err
+}
+
+func runProcessors(processors []Processor, doc *Document) error {
+	for _, processor := range processors {
+		if doc.Dropped {
+			return nil
+		}
+		if err := processor.Process(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleFailure exposes details of the failed processor in `_ingest` metadata and runs `on_failure` processors.
+func handleFailure(err error, onFailure []Processor, doc *Document) error {
+	var processorErr *ProcessorError
+	if errors.As(err, &processorErr) {
+		doc.Ingest["on_failure_message"] = processorErr.Err.Error()
+		doc.Ingest["on_failure_processor_type"] = processorErr.Type
+		doc.Ingest["on_failure_processor_tag"] = processorErr.Tag
+	} else {
+		doc.Ingest["on_failure_message"] = err.Error()
+	}
+	defer func() {
+		delete(doc.Ingest, "on_failure_message")
+		delete(doc.Ingest, "on_failure_processor_type")
+		delete(doc.Ingest, "on_failure_processor_tag")
+	}()
+	return runProcessors(onFailure, doc)
+}
+
+// ProcessorError is returned when a processor fails, it's rendered as Elastic's error cause.
+type ProcessorError struct {
+	Type string
+	Tag  string
+	Err  error
+}
+
+func (e *ProcessorError) Error() string {
+	if e.Tag != "" {
+		return fmt.Sprintf("processor [%s] with tag [%s] failed: %v", e.Type, e.Tag, e.Err)
+	}
+	return fmt.Sprintf("processor [%s] failed: %v", e.Type, e.Err)
+}
+
+func (e *ProcessorError) Unwrap() error {
+	return e.Err
+}
+
+// Field access. Field names are dot-separated paths into the source, e.g. `user.name`,
+// with the exception of metadata (`_index`, `_id`, ...) and `_ingest.*` fields.
+
+func (doc *Document) container(field string) (map[string]any, string) {
+	if strings.HasPrefix(field, "_ingest.") {
+		return doc.Ingest, strings.TrimPrefix(field, "_ingest.")
+	}
+	if _, isMetadata := metadataFields[field]; isMetadata {
+		return doc.Metadata, field
+	}
+	return doc.Source, strings.TrimPrefix(field, "_source.")
+}
+
+var metadataFields = map[string]struct{}{"_index": {}, "_id": {}, "_routing": {}, "_version": {}, "_version_type": {}}
+
+func (doc *Document) Get(field string) (any, bool) {
+	root, path := doc.container(field)
+	var current any = root
+	for _, part := range strings.Split(path, ".") {
+		asMap, ok := asObject(current)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = asMap[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func (doc *Document) Has(field string) bool {
+	_, ok := doc.Get(field)
+	return ok
+}
+
+// Set assigns value to the field, creating intermediate objects when needed.
+func (doc *Document) Set(field string, value any) error {
+	root, path := doc.container(field)
+	parts := strings.Split(path, ".")
+	current := root
+	for _, part := range parts[:len(parts)-1] {
+		next, exists := current[part]
+		if !exists || next == nil {
+			created := make(map[string]any)
+			current[part] = created
+			current = created
+			continue
+		}
+		asMap, ok := asObject(next)
+		if !ok {
+			return fmt.Errorf("cannot set [%s] with parent object of type [%T] as part of path [%s]", parts[len(parts)-1], next, field)
+		}
+		current = asMap
+	}
+	current[parts[len(parts)-1]] = value
+	return nil
+}
+
+func (doc *Document) Remove(field string) bool {
+	root, path := doc.container(field)
+	parts := strings.Split(path, ".")
+	current := root
+	for _, part := range parts[:len(parts)-1] {
+		asMap, ok := asObject(current[part])
+		if !ok {
+			return false
+		}
+		current = asMap
+	}
+	if _, exists := current[parts[len(parts)-1]]; !exists {
+		return false
+	}
+	delete(current, parts[len(parts)-1])
+	return true
+}
+
+// topLevelFields returns sorted names of all top-level source fields.
+func (doc *Document) topLevelFields() []string {
+	fields := make([]string, 0, len(doc.Source))
+	for field := range doc.Source {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func asObject(value any) (map[string]any, bool) {
+	switch valueTyped := value.(type) {
+	case map[string]any:
+		return valueTyped, true
+	case types.JSON:
+		return valueTyped, true
+	default:
+		return nil, false
+	}
+}
+
+// renderTemplate renders Mustache-like `{{field}}` / `{{{field}}}` references, used in field names and `set` values.
+func (doc *Document) renderTemplate(template string) string {
+	if !strings.Contains(template, "{{") {
+		return template
+	}
+
+	var result strings.Builder
+	rest := template
+	for {
+		start := strings.Index(rest, "{{")
+		if start == -1 {
+			result.WriteString(rest)
+			break
+		}
+		opening, closing := "{{", "}}"
+		if strings.HasPrefix(rest[start:], "{{{") {
+			opening, closing = "{{{", "}}}"
+		}
+		end := strings.Index(rest[start+len(opening):], closing)
+		if end == -1 {
+			result.WriteString(rest)
+			break
+		}
+		result.WriteString(rest[:start])
+		field := strings.TrimSpace(rest[start+len(opening) : start+len(opening)+end])
+		if value, ok := doc.Get(field); ok && value != nil {
+			result.WriteString(fmt.Sprintf("%v", value))
+		}
+		rest = rest[start+len(opening)+end+len(closing):]
+	}
+	return result.String()
+}
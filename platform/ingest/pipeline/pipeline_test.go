@@ -0,0 +1,317 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+
+package pipeline
+
+import (
+	"github.com/QuesmaOrg/quesma/platform/persistence"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func parseJSON(t *testing.T, s string) types.JSON {
+	var result types.JSON
+	require.NoError(t, json.Unmarshal([]byte(s), &result))
+	return result
+}
+
+func runPipeline(t *testing.T, definition string, source string) (*Document, error) {
+	p, err := Parse("test", parseJSON(t, definition))
+	require.NoError(t, err)
+	doc := NewDocument(parseJSON(t, source), "my_index", "1")
+	return doc, p.Run(doc, nil)
+}
+
+func TestPipeline_Processors(t *testing.T) {
+	tests := []struct {
+		name       string
+		processors string
+		source     string
+		expected   string
+	}{
+		{
+			name:       "set, with template and override",
+			processors: `[{"set": {"field": "host.name", "value": "{{service}}-{{_index}}"}}, {"set": {"field": "service", "value": "other", "override": false}}]`,
+			source:     `{"service": "web"}`,
+			expected:   `{"service": "web", "host": {"name": "web-my_index"}}`,
+		},
+		{
+			name:       "set with copy_from, rename and remove",
+			processors: `[{"set": {"field": "copy", "copy_from": "user"}}, {"rename": {"field": "user.name", "target_field": "user_name"}}, {"remove": {"field": ["copy.id", "missing"], "ignore_missing": true}}]`,
+			source:     `{"user": {"name": "john", "id": 1}}`,
+			expected:   `{"user": {"id": 1}, "copy": {"name": "john"}, "user_name": "john"}`,
+		},
+		{
+			name:       "remove with keep",
+			processors: `[{"remove": {"keep": ["a", "b.c"]}}]`,
+			source:     `{"a": 1, "b": {"c": 2, "d": 3}, "e": 4}`,
+			expected:   `{"a": 1, "b": {"c": 2}}`,
+		},
+		{
+			name:       "string processors",
+			processors: `[{"lowercase": {"field": "a"}}, {"uppercase": {"field": "b", "target_field": "c"}}, {"trim": {"field": "d"}}, {"gsub": {"field": "e", "pattern": "[-.]", "replacement": "_"}}]`,
+			source:     `{"a": "MiXeD", "b": "up", "d": "  spaces ", "e": "a-b.c"}`,
+			expected:   `{"a": "mixed", "b": "up", "c": "UP", "d": "spaces", "e": "a_b_c"}`,
+		},
+		{
+			name:       "split, join and append",
+			processors: `[{"split": {"field": "tags", "separator": ",\\s*"}}, {"append": {"field": "tags", "value": ["x", "a"], "allow_duplicates": false}}, {"join": {"field": "tags", "separator": "|", "target_field": "joined"}}]`,
+			source:     `{"tags": "a, b,c"}`,
+			expected:   `{"tags": ["a", "b", "c", "x"], "joined": "a|b|c|x"}`,
+		},
+		{
+			name:       "convert and json",
+			processors: `[{"convert": {"field": "count", "type": "integer"}}, {"convert": {"field": "ok", "type": "boolean"}}, {"json": {"field": "payload", "target_field": "parsed"}}]`,
+			source:     `{"count": "42", "ok": "TRUE", "payload": "{\"x\": [1, 2]}"}`,
+			expected:   `{"count": 42, "ok": true, "payload": "{\"x\": [1, 2]}", "parsed": {"x": [1, 2]}}`,
+		},
+		{
+			name:       "date",
+			processors: `[{"date": {"field": "ts", "formats": ["dd/MMM/yyyy:HH:mm:ss Z", "ISO8601"]}}, {"date": {"field": "epoch", "formats": ["UNIX_MS"], "target_field": "from_epoch", "output_format": "yyyy-MM-dd"}}]`,
+			source:     `{"ts": "10/Oct/2000:13:55:36 -0700", "epoch": 1700000000000}`,
+			expected:   `{"ts": "10/Oct/2000:13:55:36 -0700", "@timestamp": "2000-10-10T13:55:36.000-07:00", "epoch": 1700000000000, "from_epoch": "2023-11-14"}`,
+		},
+		{
+			name:       "if condition and foreach",
+			processors: `[{"set": {"field": "level", "value": "error", "if": "ctx.status >= 500 && ctx.tags?.size() > 0"}}, {"set": {"field": "level2", "value": "x", "if": "ctx.status < 500"}}, {"foreach": {"field": "tags", "processor": {"uppercase": {"field": "_ingest._value"}}}}]`,
+			source:     `{"status": 503, "tags": ["a", "b"]}`,
+			expected:   `{"status": 503, "tags": ["A", "B"], "level": "error"}`,
+		},
+		{
+			name:       "failing processor with ignore_failure and on_failure",
+			processors: `[{"rename": {"field": "missing", "target_field": "x", "ignore_failure": true}}, {"fail": {"message": "boom {{a}}", "tag": "my_tag", "on_failure": [{"set": {"field": "error", "value": "{{_ingest.on_failure_message}} in {{_ingest.on_failure_processor_tag}}"}}]}}]`,
+			source:     `{"a": 1}`,
+			expected:   `{"a": 1, "error": "boom 1 in my_tag"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := runPipeline(t, `{"processors": `+tt.processors+`}`, tt.source)
+			require.NoError(t, err)
+			assert.Equal(t, parseJSON(t, tt.expected), normalize(t, doc.Source))
+		})
+	}
+}
+
+// normalize makes values comparable with parsed JSON (e.g. int64 become float64)
+func normalize(t *testing.T, source types.JSON) types.JSON {
+	data, err := json.Marshal(source)
+	require.NoError(t, err)
+	return parseJSON(t, string(data))
+}
+
+func TestPipeline_Errors(t *testing.T) {
+	_, err := Parse("p", parseJSON(t, `{"processors": [{"unknown": {}}]}`))
+	assert.ErrorContains(t, err, "no processor type exists with name [unknown]")
+
+	_, err = Parse("p", parseJSON(t, `{"processors": [{"set": {"field": "a", "value": 1, "typo": 2}}]}`))
+	assert.ErrorContains(t, err, "doesn't support options: typo")
+
+	_, err = Parse("p", parseJSON(t, `{"description": "no processors"}`))
+	assert.ErrorContains(t, err, "[processors] required property is missing")
+
+	_, err = runPipeline(t, `{"processors": [{"lowercase": {"field": "missing", "tag": "t"}}]}`, `{}`)
+	var processorErr *ProcessorError
+	require.ErrorAs(t, err, &processorErr)
+	assert.Equal(t, "lowercase", processorErr.Type)
+	assert.Equal(t, "t", processorErr.Tag)
+
+	// the pipeline-level on_failure handles errors of all processors
+	doc, err := runPipeline(t, `{"processors": [{"fail": {"message": "boom"}}], "on_failure": [{"set": {"field": "failed", "value": "{{_ingest.on_failure_processor_type}}"}}]}`, `{}`)
+	require.NoError(t, err)
+	assert.Equal(t, "fail", doc.Source["failed"])
+}
+
+func TestPipeline_DropAndNestedPipelines(t *testing.T) {
+	store := NewStore(persistence.NewStaticJSONDatabase())
+	require.NoError(t, store.Put("inner", parseJSON(t, `{"processors": [{"set": {"field": "inner", "value": true}}, {"drop": {"if": "ctx.drop == true"}}]}`)))
+	require.NoError(t, store.Put("outer", parseJSON(t, `{"processors": [{"pipeline": {"name": "inner"}}, {"set": {"field": "outer", "value": true}}]}`)))
+	require.NoError(t, store.Put("cycle", parseJSON(t, `{"processors": [{"pipeline": {"name": "cycle"}}]}`)))
+
+	outer, err := store.Get("outer")
+	require.NoError(t, err)
+
+	doc := NewDocument(types.JSON{}, "idx", "")
+	require.NoError(t, outer.Run(doc, store.Get))
+	assert.False(t, doc.Dropped)
+	assert.Equal(t, types.JSON{"inner": true, "outer": true}, doc.Source)
+
+	doc = NewDocument(types.JSON{"drop": true}, "idx", "")
+	require.NoError(t, outer.Run(doc, store.Get))
+	assert.True(t, doc.Dropped)
+	assert.NotContains(t, doc.Source, "outer")
+
+	cycle, err := store.Get("cycle")
+	require.NoError(t, err)
+	assert.ErrorContains(t, cycle.Run(NewDocument(types.JSON{}, "idx", ""), store.Get), "cycle detected for pipeline: cycle -> cycle")
+
+	_, err = store.Get("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	found, err := store.Delete("inner")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.ErrorContains(t, outer.Run(NewDocument(types.JSON{}, "idx", ""), store.Get), "pipeline with id [inner] does not exist")
+}
+
+func TestGrok(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  string
+		source   string
+		expected string
+	}{
+		{
+			name:     "common apache log",
+			options:  `{"field": "message", "patterns": ["%{COMMONAPACHELOG}"]}`,
+			source:   `{"message": "127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] \"GET /apache_pb.gif HTTP/1.0\" 200 2326"}`,
+			expected: `{"message": "127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] \"GET /apache_pb.gif HTTP/1.0\" 200 2326", "clientip": "127.0.0.1", "ident": "-", "auth": "frank", "timestamp": "10/Oct/2000:13:55:36 -0700", "verb": "GET", "request": "/apache_pb.gif", "httpversion": "1.0", "response": "200", "bytes": "2326"}`,
+		},
+		{
+			name:     "types, custom definitions, dotted fields and the second pattern",
+			options:  `{"field": "message", "patterns": ["%{NUMBER:nope:int} never", "%{IP:client.ip} %{WORD:method} %{ID:id} took %{NUMBER:took:float}ms"], "pattern_definitions": {"ID": "[a-z]{3}-\\d+"}, "trace_match": true}`,
+			source:   `{"message": "10.0.0.1 GET abc-123 took 1.5ms"}`,
+			expected: `{"message": "10.0.0.1 GET abc-123 took 1.5ms", "client": {"ip": "10.0.0.1"}, "method": "GET", "id": "abc-123", "took": 1.5}`,
+		},
+		{
+			name:     "oniguruma named capture",
+			options:  `{"field": "message", "patterns": ["(?<queue.id>[0-9A-F]{4}): %{GREEDYDATA:rest}"]}`,
+			source:   `{"message": "BEEF: hello world"}`,
+			expected: `{"message": "BEEF: hello world", "queue": {"id": "BEEF"}, "rest": "hello world"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := runPipeline(t, `{"processors": [{"grok": `+tt.options+`}]}`, tt.source)
+			require.NoError(t, err)
+			assert.Equal(t, parseJSON(t, tt.expected), normalize(t, doc.Source))
+		})
+	}
+
+	_, err := runPipeline(t, `{"processors": [{"grok": {"field": "message", "patterns": ["%{INT:x}"]}}]}`, `{"message": "no numbers"}`)
+	assert.ErrorContains(t, err, "Provided Grok expressions do not match field value: [no numbers]")
+
+	_, err = Parse("p", parseJSON(t, `{"processors": [{"grok": {"field": "message", "patterns": ["%{UNKNOWN:x}"]}}]}`))
+	assert.ErrorContains(t, err, "Unable to find pattern [UNKNOWN]")
+
+	_, err = Parse("p", parseJSON(t, `{"processors": [{"grok": {"field": "message", "patterns": ["%{A}"], "pattern_definitions": {"A": "%{A}"}}}]}`))
+	assert.ErrorContains(t, err, "circular reference")
+}
+
+func TestDissect(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  string
+		message  string
+		expected map[string]any
+	}{
+		{
+			name:     "basic with skip and padding",
+			options:  `{"field": "message", "pattern": "[%{ts}] %{level->} %{?skipped} %{msg}"}`,
+			message:  "[2024-01-01] INFO    ignored hello world",
+			expected: map[string]any{"ts": "2024-01-01", "level": "INFO", "msg": "hello world"},
+		},
+		{
+			name:     "append with order and separator",
+			options:  `{"field": "message", "pattern": "%{+name/2} %{+name/1} %{}", "append_separator": ", "}`,
+			message:  "john smith 42",
+			expected: map[string]any{"name": "smith, john"},
+		},
+		{
+			name:     "reference keys",
+			options:  `{"field": "message", "pattern": "%{*key}=%{&key}"}`,
+			message:  "user=alice",
+			expected: map[string]any{"user": "alice"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := runPipeline(t, `{"processors": [{"dissect": `+tt.options+`}]}`, `{"message": "`+tt.message+`"}`)
+			require.NoError(t, err)
+			delete(doc.Source, "message")
+			assert.Equal(t, types.JSON(tt.expected), doc.Source)
+		})
+	}
+
+	_, err := runPipeline(t, `{"processors": [{"dissect": {"field": "message", "pattern": "%{a} - %{b}"}}]}`, `{"message": "no delimiter"}`)
+	assert.ErrorContains(t, err, "Unable to find match for dissect pattern")
+}
+
+func TestScript(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		source   string
+		expected string
+	}{
+		{
+			name:     "assignments and params",
+			script:   `{"source": "ctx.total = ctx.price * params.quantity; ctx['label'] = 'item-' + ctx.id; ctx.count += 1", "params": {"quantity": 3}}`,
+			source:   `{"price": 2.5, "id": 7, "count": 1}`,
+			expected: `{"price": 2.5, "id": 7, "count": 2, "total": 7.5, "label": "item-7"}`,
+		},
+		{
+			name:     "if/else, def and string methods",
+			script:   `{"source": "def name = ctx.user?.name; if (name != null && name.startsWith('adm')) { ctx.role = 'admin'; } else { ctx.role = 'user' } ctx.user.name = name.toUpperCase();"}`,
+			source:   `{"user": {"name": "admin1"}}`,
+			expected: `{"user": {"name": "ADMIN1"}, "role": "admin"}`,
+		},
+		{
+			name:     "remove, containsKey and ternary",
+			script:   `{"source": "ctx.had_tmp = ctx.containsKey('tmp') ? 'yes' : 'no'; ctx.remove('tmp'); ctx._index = 'other'"}`,
+			source:   `{"tmp": 1}`,
+			expected: `{"had_tmp": "yes"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := runPipeline(t, `{"processors": [{"script": `+tt.script+`}]}`, tt.source)
+			require.NoError(t, err)
+			assert.Equal(t, parseJSON(t, tt.expected), normalize(t, doc.Source))
+		})
+	}
+
+	doc, err := runPipeline(t, `{"processors": [{"script": {"source": "ctx._index = 'other'"}}]}`, `{}`)
+	require.NoError(t, err)
+	assert.Equal(t, "other", doc.Metadata["_index"])
+
+	_, err = Parse("p", parseJSON(t, `{"processors": [{"script": {"source": "ctx.a = = 1"}}]}`))
+	assert.ErrorContains(t, err, "1:9 [8]: no match found")
+
+	_, err = runPipeline(t, `{"processors": [{"script": {"source": "ctx.a = ctx.b.c"}}]}`, `{}`)
+	assert.ErrorContains(t, err, "cannot access property [c] of null")
+}
+
+func TestSimulate(t *testing.T) {
+	body := parseJSON(t, `{
+		"pipeline": {"processors": [{"set": {"field": "x", "value": "{{y}}"}}, {"drop": {"if": "ctx.y == 'drop'"}}, {"fail": {"message": "bad", "if": "ctx.y == 'fail'"}}]},
+		"docs": [
+			{"_index": "idx", "_id": "1", "_source": {"y": "a"}},
+			{"_source": {"y": "drop"}},
+			{"_source": {"y": "fail"}}
+		]
+	}`)
+	response, err := Simulate(body, nil, nil)
+	require.NoError(t, err)
+
+	docs := response["docs"].([]any)
+	require.Len(t, docs, 3)
+
+	first := docs[0].(types.JSON)["doc"].(types.JSON)
+	assert.Equal(t, "idx", first["_index"])
+	assert.Equal(t, "1", first["_id"])
+	assert.Equal(t, types.JSON{"x": "a", "y": "a"}, first["_source"])
+	assert.Contains(t, first["_ingest"], "timestamp")
+
+	assert.Nil(t, docs[1])
+
+	failed := docs[2].(types.JSON)["error"].(types.JSON)
+	assert.Equal(t, "bad", failed["reason"])
+	assert.Equal(t, "fail", failed["processor_type"])
+
+	_, err = Simulate(types.JSON{"docs": []any{}}, nil, nil)
+	assert.ErrorContains(t, err, "[pipeline] required property is missing")
+}
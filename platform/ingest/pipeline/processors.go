@@ -0,0 +1,984 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Processor interface {
+	Process(doc *Document) error
+}
+
+// processorConstructors maps processor type to a function parsing its options (without the common ones).
+var processorConstructors map[string]func(options *options) (Processor, error)
+
+func init() {
+	// initialized here, because `pipeline` and `foreach` constructors refer back to parseProcessor
+	processorConstructors = map[string]func(options *options) (Processor, error){
+		"append":    newAppendProcessor,
+		"convert":   newConvertProcessor,
+		"date":      newDateProcessor,
+		"dissect":   newDissectProcessor,
+		"drop":      newDropProcessor,
+		"fail":      newFailProcessor,
+		"foreach":   newForeachProcessor,
+		"grok":      newGrokProcessor,
+		"gsub":      newGsubProcessor,
+		"join":      newJoinProcessor,
+		"json":      newJsonProcessor,
+		"lowercase": newStringProcessor(strings.ToLower),
+		"pipeline":  newPipelineProcessor,
+		"remove":    newRemoveProcessor,
+		"rename":    newRenameProcessor,
+		"script":    newScriptProcessor,
+		"set":       newSetProcessor,
+		"split":     newSplitProcessor,
+		"trim":      newStringProcessor(strings.TrimSpace),
+		"uppercase": newStringProcessor(strings.ToUpper),
+		"urldecode": newStringProcessorWithError(url.QueryUnescape),
+	}
+}
+
+// SupportedProcessors returns sorted names of processors we can run.
+func SupportedProcessors() []string {
+	names := make([]string, 0, len(processorConstructors))
+	for name := range processorConstructors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func parseProcessors(propertyName string, value any) ([]Processor, error) {
+	definitions, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("[%s] should be an array of processors, got %T", propertyName, value)
+	}
+	processors := make([]Processor, 0, len(definitions))
+	for _, definition := range definitions {
+		processor, err := parseProcessor(definition)
+		if err != nil {
+			return nil, err
+		}
+		processors = append(processors, processor)
+	}
+	return processors, nil
+}
+
+func parseProcessor(definition any) (Processor, error) {
+	asMap, ok := asObject(definition)
+	if !ok || len(asMap) != 1 {
+		return nil, fmt.Errorf("processor definition should be an object with a single key, got %v", definition)
+	}
+
+	for processorType, optionsRaw := range asMap {
+		constructor, supported := processorConstructors[processorType]
+		if !supported {
+			return nil, fmt.Errorf("no processor type exists with name [%s], supported ones are: %s", processorType, strings.Join(SupportedProcessors(), ", "))
+		}
+		optionsMap, ok := asObject(optionsRaw)
+		if !ok {
+			return nil, fmt.Errorf("[%s] processor options should be an object, got %T", processorType, optionsRaw)
+		}
+
+		opts := &options{processorType: processorType, values: optionsMap, used: make(map[string]bool)}
+		common, err := parseCommonOptions(opts)
+		if err != nil {
+			return nil, err
+		}
+		common.inner, err = constructor(opts)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] processor: %w", processorType, err)
+		}
+		if err = opts.checkAllUsed(); err != nil {
+			return nil, err
+		}
+		return common, nil
+	}
+	panic("unreachable")
+}
+
+// commonProcessor handles options supported by all processors: `if`, `tag`, `ignore_failure` and `on_failure`.
+type commonProcessor struct {
+	processorType string
+	tag           string
+	condition     *script
+	ignoreFailure bool
+	onFailure     []Processor
+	inner         Processor
+}
+
+func parseCommonOptions(opts *options) (*commonProcessor, error) {
+	common := &commonProcessor{processorType: opts.processorType}
+	var err error
+	if common.tag, err = opts.optionalString("tag", ""); err != nil {
+		return nil, err
+	}
+	if _, err = opts.optionalString("description", ""); err != nil {
+		return nil, err
+	}
+	if common.ignoreFailure, err = opts.optionalBool("ignore_failure", false); err != nil {
+		return nil, err
+	}
+	if condition, err := opts.optionalString("if", ""); err != nil {
+		return nil, err
+	} else if condition != "" {
+		if common.condition, err = parseScript(condition, nil); err != nil {
+			return nil, fmt.Errorf("[%s] processor: invalid [if] condition: %w", opts.processorType, err)
+		}
+	}
+	if onFailure, exists := opts.get("on_failure"); exists {
+		if common.onFailure, err = parseProcessors("on_failure", onFailure); err != nil {
+			return nil, err
+		}
+	}
+	return common, nil
+}
+
+func (p *commonProcessor) Process(doc *Document) error {
+	if p.condition != nil {
+		result, err := p.condition.evalCondition(doc)
+		if err != nil {
+			return &ProcessorError{Type: p.processorType, Tag: p.tag, Err: fmt.Errorf("failed to evaluate [if] condition: %w", err)}
+		}
+		if !result {
+			return nil
+		}
+	}
+
+	err := p.inner.Process(doc)
+	if err == nil {
+		return nil
+	}
+	var processorErr *ProcessorError
+	if !errors.As(err, &processorErr) {
+		err = &ProcessorError{Type: p.processorType, Tag: p.tag, Err: err}
+	}
+
+	switch {
+	case len(p.onFailure) > 0:
+		return handleFailure(err, p.onFailure, doc)
+	case p.ignoreFailure:
+		return nil
+	default:
+		return err
+	}
+}
+
+// options is a helper for reading processor options, which also verifies there are no unknown ones.
+type options struct {
+	processorType string
+	values        map[string]any
+	used          map[string]bool
+}
+
+func (o *options) get(name string) (any, bool) {
+	o.used[name] = true
+	value, ok := o.values[name]
+	return value, ok
+}
+
+func (o *options) checkAllUsed() error {
+	var unknown []string
+	for name := range o.values {
+		if !o.used[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("[%s] processor doesn't support options: %s", o.processorType, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+func (o *options) requiredString(name string) (string, error) {
+	value, exists := o.get(name)
+	if !exists {
+		return "", fmt.Errorf("[%s] required property is missing", name)
+	}
+	asString, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("[%s] property should be a string, got %T", name, value)
+	}
+	return asString, nil
+}
+
+func (o *options) optionalString(name, defaultValue string) (string, error) {
+	if _, exists := o.values[name]; !exists {
+		o.used[name] = true
+		return defaultValue, nil
+	}
+	return o.requiredString(name)
+}
+
+func (o *options) optionalBool(name string, defaultValue bool) (bool, error) {
+	value, exists := o.get(name)
+	if !exists {
+		return defaultValue, nil
+	}
+	switch valueTyped := value.(type) {
+	case bool:
+		return valueTyped, nil
+	case string:
+		return strconv.ParseBool(valueTyped)
+	default:
+		return false, fmt.Errorf("[%s] property should be a boolean, got %T", name, value)
+	}
+}
+
+// stringList accepts both a single string and an array of strings.
+func (o *options) stringList(name string, required bool) ([]string, error) {
+	value, exists := o.get(name)
+	if !exists {
+		if required {
+			return nil, fmt.Errorf("[%s] required property is missing", name)
+		}
+		return nil, nil
+	}
+	switch valueTyped := value.(type) {
+	case string:
+		return []string{valueTyped}, nil
+	case []any:
+		result := make([]string, 0, len(valueTyped))
+		for _, element := range valueTyped {
+			asString, ok := element.(string)
+			if !ok {
+				return nil, fmt.Errorf("[%s] property should contain only strings, got %T", name, element)
+			}
+			result = append(result, asString)
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("[%s] property should be a string or an array of strings, got %T", name, value)
+	}
+}
+
+// fieldProcessor contains `field`, `target_field` and `ignore_missing`, shared by most processors.
+type fieldProcessor struct {
+	field         string
+	targetField   string
+	ignoreMissing bool
+}
+
+func parseFieldOptions(opts *options) (fieldProcessor, error) {
+	var result fieldProcessor
+	var err error
+	if result.field, err = opts.requiredString("field"); err != nil {
+		return result, err
+	}
+	if result.targetField, err = opts.optionalString("target_field", result.field); err != nil {
+		return result, err
+	}
+	if result.ignoreMissing, err = opts.optionalBool("ignore_missing", false); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// value returns field value, ok=false means the processor should be skipped (the field is missing and it's allowed).
+func (p fieldProcessor) value(doc *Document) (value any, ok bool, err error) {
+	field := doc.renderTemplate(p.field)
+	value, exists := doc.Get(field)
+	if !exists || value == nil {
+		if p.ignoreMissing {
+			return nil, false, nil
+		}
+		if !exists {
+			return nil, false, fmt.Errorf("field [%s] not present as part of path [%s]", lastPart(field), field)
+		}
+		return nil, false, fmt.Errorf("field [%s] is null, cannot process it", field)
+	}
+	return value, true, nil
+}
+
+func (p fieldProcessor) stringValue(doc *Document) (string, bool, error) {
+	value, ok, err := p.value(doc)
+	if !ok {
+		return "", false, err
+	}
+	asString, isString := value.(string)
+	if !isString {
+		return "", false, fmt.Errorf("field [%s] of type [%T] cannot be cast to [string]", p.field, value)
+	}
+	return asString, true, nil
+}
+
+func (p fieldProcessor) setTarget(doc *Document, value any) error {
+	return doc.Set(doc.renderTemplate(p.targetField), value)
+}
+
+func lastPart(field string) string {
+	return field[strings.LastIndex(field, ".")+1:]
+}
+
+// set
+
+type setProcessor struct {
+	field            string
+	value            any
+	copyFrom         string
+	override         bool
+	ignoreEmptyValue bool
+}
+
+func newSetProcessor(opts *options) (Processor, error) {
+	p := &setProcessor{}
+	var err error
+	if p.field, err = opts.requiredString("field"); err != nil {
+		return nil, err
+	}
+	value, hasValue := opts.get("value")
+	if p.copyFrom, err = opts.optionalString("copy_from", ""); err != nil {
+		return nil, err
+	}
+	if hasValue == (p.copyFrom != "") {
+		return nil, fmt.Errorf("exactly one of [value] or [copy_from] is required")
+	}
+	p.value = value
+	if p.override, err = opts.optionalBool("override", true); err != nil {
+		return nil, err
+	}
+	if p.ignoreEmptyValue, err = opts.optionalBool("ignore_empty_value", false); err != nil {
+		return nil, err
+	}
+	if _, err = opts.optionalString("media_type", ""); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *setProcessor) Process(doc *Document) error {
+	field := doc.renderTemplate(p.field)
+	if !p.override {
+		if existing, exists := doc.Get(field); exists && existing != nil {
+			return nil
+		}
+	}
+
+	var value any
+	if p.copyFrom != "" {
+		copied, exists := doc.Get(p.copyFrom)
+		if !exists {
+			return fmt.Errorf("field [%s] not present as part of path [%s]", lastPart(p.copyFrom), p.copyFrom)
+		}
+		value = deepCopy(copied)
+	} else {
+		value = renderValue(doc, p.value)
+	}
+
+	if p.ignoreEmptyValue && (value == nil || value == "") {
+		return nil
+	}
+	return doc.Set(field, value)
+}
+
+func renderValue(doc *Document, value any) any {
+	switch valueTyped := value.(type) {
+	case string:
+		return doc.renderTemplate(valueTyped)
+	case []any:
+		result := make([]any, len(valueTyped))
+		for i, element := range valueTyped {
+			result[i] = renderValue(doc, element)
+		}
+		return result
+	case map[string]any:
+		result := make(map[string]any, len(valueTyped))
+		for key, element := range valueTyped {
+			result[key] = renderValue(doc, element)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+func deepCopy(value any) any {
+	switch valueTyped := value.(type) {
+	case []any:
+		result := make([]any, len(valueTyped))
+		for i, element := range valueTyped {
+			result[i] = deepCopy(element)
+		}
+		return result
+	default:
+		if asMap, ok := asObject(value); ok {
+			result := make(map[string]any, len(asMap))
+			for key, element := range asMap {
+				result[key] = deepCopy(element)
+			}
+			return result
+		}
+		return value
+	}
+}
+
+// append
+
+type appendProcessor struct {
+	field           string
+	value           any
+	allowDuplicates bool
+}
+
+func newAppendProcessor(opts *options) (Processor, error) {
+	p := &appendProcessor{}
+	var err error
+	if p.field, err = opts.requiredString("field"); err != nil {
+		return nil, err
+	}
+	var exists bool
+	if p.value, exists = opts.get("value"); !exists {
+		return nil, fmt.Errorf("[value] required property is missing")
+	}
+	if p.allowDuplicates, err = opts.optionalBool("allow_duplicates", true); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *appendProcessor) Process(doc *Document) error {
+	field := doc.renderTemplate(p.field)
+	var values []any
+	if existing, exists := doc.Get(field); exists && existing != nil {
+		if asArray, ok := existing.([]any); ok {
+			values = append(values, asArray...)
+		} else {
+			values = append(values, existing)
+		}
+	}
+
+	toAppend, isArray := p.value.([]any)
+	if !isArray {
+		toAppend = []any{p.value}
+	}
+	for _, value := range toAppend {
+		value = renderValue(doc, value)
+		if !p.allowDuplicates && containsValue(values, value) {
+			continue
+		}
+		values = append(values, value)
+	}
+	return doc.Set(field, values)
+}
+
+func containsValue(values []any, value any) bool {
+	for _, existing := range values {
+		if fmt.Sprintf("%v", existing) == fmt.Sprintf("%v", value) {
+			return true
+		}
+	}
+	return false
+}
+
+// remove
+
+type removeProcessor struct {
+	fields        []string
+	keep          []string
+	ignoreMissing bool
+}
+
+func newRemoveProcessor(opts *options) (Processor, error) {
+	p := &removeProcessor{}
+	var err error
+	if p.fields, err = opts.stringList("field", false); err != nil {
+		return nil, err
+	}
+	if p.keep, err = opts.stringList("keep", false); err != nil {
+		return nil, err
+	}
+	if (len(p.fields) > 0) == (len(p.keep) > 0) {
+		return nil, fmt.Errorf("exactly one of [field] or [keep] is required")
+	}
+	if p.ignoreMissing, err = opts.optionalBool("ignore_missing", false); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *removeProcessor) Process(doc *Document) error {
+	if len(p.keep) > 0 {
+		kept := make(map[string]any)
+		for _, field := range p.keep {
+			if value, exists := doc.Get(field); exists {
+				kept[field] = value
+			}
+		}
+		for _, field := range doc.topLevelFields() {
+			delete(doc.Source, field)
+		}
+		for field, value := range kept {
+			if err := doc.Set(field, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, field := range p.fields {
+		field = doc.renderTemplate(field)
+		if !doc.Remove(field) && !p.ignoreMissing {
+			return fmt.Errorf("field [%s] not present as part of path [%s]", lastPart(field), field)
+		}
+	}
+	return nil
+}
+
+// rename
+
+type renameProcessor struct {
+	fieldProcessor
+	override bool
+}
+
+func newRenameProcessor(opts *options) (Processor, error) {
+	p := &renameProcessor{}
+	var err error
+	if p.fieldProcessor, err = parseFieldOptions(opts); err != nil {
+		return nil, err
+	}
+	if _, exists := opts.values["target_field"]; !exists {
+		return nil, fmt.Errorf("[target_field] required property is missing")
+	}
+	if p.override, err = opts.optionalBool("override", false); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *renameProcessor) Process(doc *Document) error {
+	field := doc.renderTemplate(p.field)
+	value, exists := doc.Get(field)
+	if !exists {
+		if p.ignoreMissing {
+			return nil
+		}
+		return fmt.Errorf("field [%s] doesn't exist", field)
+	}
+	target := doc.renderTemplate(p.targetField)
+	if doc.Has(target) && !p.override {
+		return fmt.Errorf("field [%s] already exists", target)
+	}
+	doc.Remove(field)
+	return doc.Set(target, value)
+}
+
+// lowercase, uppercase, trim, urldecode
+
+func newStringProcessor(transform func(string) string) func(opts *options) (Processor, error) {
+	return newStringProcessorWithError(func(s string) (string, error) { return transform(s), nil })
+}
+
+func newStringProcessorWithError(transform func(string) (string, error)) func(opts *options) (Processor, error) {
+	return func(opts *options) (Processor, error) {
+		fieldOptions, err := parseFieldOptions(opts)
+		if err != nil {
+			return nil, err
+		}
+		return &stringProcessor{fieldProcessor: fieldOptions, transform: transform}, nil
+	}
+}
+
+type stringProcessor struct {
+	fieldProcessor
+	transform func(string) (string, error)
+}
+
+func (p *stringProcessor) Process(doc *Document) error {
+	value, ok, err := p.value(doc)
+	if !ok {
+		return err
+	}
+
+	transformOne := func(value any) (any, error) {
+		asString, isString := value.(string)
+		if !isString {
+			return nil, fmt.Errorf("field [%s] of type [%T] cannot be cast to [string]", p.field, value)
+		}
+		return p.transform(asString)
+	}
+
+	if asArray, isArray := value.([]any); isArray {
+		result := make([]any, len(asArray))
+		for i, element := range asArray {
+			if result[i], err = transformOne(element); err != nil {
+				return err
+			}
+		}
+		return p.setTarget(doc, result)
+	}
+	result, err := transformOne(value)
+	if err != nil {
+		return err
+	}
+	return p.setTarget(doc, result)
+}
+
+// split, join, gsub
+
+type splitProcessor struct {
+	fieldProcessor
+	separator        *regexp.Regexp
+	preserveTrailing bool
+}
+
+func newSplitProcessor(opts *options) (Processor, error) {
+	p := &splitProcessor{}
+	var err error
+	if p.fieldProcessor, err = parseFieldOptions(opts); err != nil {
+		return nil, err
+	}
+	separator, err := opts.requiredString("separator")
+	if err != nil {
+		return nil, err
+	}
+	if p.separator, err = regexp.Compile(separator); err != nil {
+		return nil, fmt.Errorf("invalid [separator]: %w", err)
+	}
+	if p.preserveTrailing, err = opts.optionalBool("preserve_trailing", false); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *splitProcessor) Process(doc *Document) error {
+	value, ok, err := p.stringValue(doc)
+	if !ok {
+		return err
+	}
+	parts := p.separator.Split(value, -1)
+	if !p.preserveTrailing {
+		for len(parts) > 0 && parts[len(parts)-1] == "" {
+			parts = parts[:len(parts)-1]
+		}
+	}
+	result := make([]any, len(parts))
+	for i, part := range parts {
+		result[i] = part
+	}
+	return p.setTarget(doc, result)
+}
+
+type joinProcessor struct {
+	fieldProcessor
+	separator string
+}
+
+func newJoinProcessor(opts *options) (Processor, error) {
+	p := &joinProcessor{}
+	var err error
+	if p.fieldProcessor, err = parseFieldOptions(opts); err != nil {
+		return nil, err
+	}
+	if p.separator, err = opts.requiredString("separator"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *joinProcessor) Process(doc *Document) error {
+	value, ok, err := p.value(doc)
+	if !ok {
+		return err
+	}
+	asArray, isArray := value.([]any)
+	if !isArray {
+		return fmt.Errorf("field [%s] of type [%T] cannot be cast to [list]", p.field, value)
+	}
+	parts := make([]string, len(asArray))
+	for i, element := range asArray {
+		parts[i] = fmt.Sprintf("%v", element)
+	}
+	return p.setTarget(doc, strings.Join(parts, p.separator))
+}
+
+type gsubProcessor struct {
+	fieldProcessor
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+func newGsubProcessor(opts *options) (Processor, error) {
+	p := &gsubProcessor{}
+	var err error
+	if p.fieldProcessor, err = parseFieldOptions(opts); err != nil {
+		return nil, err
+	}
+	pattern, err := opts.requiredString("pattern")
+	if err != nil {
+		return nil, err
+	}
+	if p.pattern, err = regexp.Compile(pattern); err != nil {
+		return nil, fmt.Errorf("invalid [pattern]: %w", err)
+	}
+	if p.replacement, err = opts.requiredString("replacement"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *gsubProcessor) Process(doc *Document) error {
+	value, ok, err := p.stringValue(doc)
+	if !ok {
+		return err
+	}
+	return p.setTarget(doc, p.pattern.ReplaceAllString(value, p.replacement))
+}
+
+// json
+
+type jsonProcessor struct {
+	fieldProcessor
+	addToRoot bool
+}
+
+func newJsonProcessor(opts *options) (Processor, error) {
+	p := &jsonProcessor{}
+	var err error
+	if p.fieldProcessor, err = parseFieldOptions(opts); err != nil {
+		return nil, err
+	}
+	if p.addToRoot, err = opts.optionalBool("add_to_root", false); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *jsonProcessor) Process(doc *Document) error {
+	value, ok, err := p.stringValue(doc)
+	if !ok {
+		return err
+	}
+	var parsed any
+	if err = json.Unmarshal([]byte(value), &parsed); err != nil {
+		return fmt.Errorf("field [%s] is not a valid JSON: %w", p.field, err)
+	}
+	if !p.addToRoot {
+		return p.setTarget(doc, parsed)
+	}
+	asMap, isMap := parsed.(map[string]any)
+	if !isMap {
+		return fmt.Errorf("cannot add non-map fields to root of document")
+	}
+	for key, element := range asMap {
+		doc.Source[key] = element
+	}
+	return nil
+}
+
+// convert
+
+type convertProcessor struct {
+	fieldProcessor
+	targetType string
+}
+
+func newConvertProcessor(opts *options) (Processor, error) {
+	p := &convertProcessor{}
+	var err error
+	if p.fieldProcessor, err = parseFieldOptions(opts); err != nil {
+		return nil, err
+	}
+	if p.targetType, err = opts.requiredString("type"); err != nil {
+		return nil, err
+	}
+	switch p.targetType {
+	case "integer", "long", "float", "double", "boolean", "string", "ip", "auto":
+	default:
+		return nil, fmt.Errorf("type [%s] not supported, cannot convert field", p.targetType)
+	}
+	return p, nil
+}
+
+func (p *convertProcessor) Process(doc *Document) error {
+	value, ok, err := p.value(doc)
+	if !ok {
+		return err
+	}
+	if asArray, isArray := value.([]any); isArray {
+		result := make([]any, len(asArray))
+		for i, element := range asArray {
+			if result[i], err = convertValue(element, p.targetType); err != nil {
+				return err
+			}
+		}
+		return p.setTarget(doc, result)
+	}
+	converted, err := convertValue(value, p.targetType)
+	if err != nil {
+		return err
+	}
+	return p.setTarget(doc, converted)
+}
+
+func convertValue(value any, targetType string) (any, error) {
+	asString := fmt.Sprintf("%v", value)
+	switch targetType {
+	case "integer", "long":
+		if number, isNumber := value.(float64); isNumber {
+			return int64(number), nil
+		}
+		number, err := strconv.ParseInt(strings.TrimSpace(asString), 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to convert [%s] to %s", asString, targetType)
+		}
+		return number, nil
+	case "float", "double":
+		number, err := strconv.ParseFloat(strings.TrimSpace(asString), 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to convert [%s] to %s", asString, targetType)
+		}
+		return number, nil
+	case "boolean":
+		switch strings.ToLower(asString) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return nil, fmt.Errorf("[%s] is not a boolean value, cannot convert to boolean", asString)
+	case "string":
+		return asString, nil
+	case "ip":
+		if net.ParseIP(asString) == nil {
+			return nil, fmt.Errorf("'%s' is not an IP string literal", asString)
+		}
+		return asString, nil
+	case "auto":
+		if _, isString := value.(string); !isString {
+			return value, nil
+		}
+		if number, err := strconv.ParseInt(asString, 10, 64); err == nil {
+			return number, nil
+		}
+		if number, err := strconv.ParseFloat(asString, 64); err == nil {
+			return number, nil
+		}
+		if boolean, err := convertValue(value, "boolean"); err == nil {
+			return boolean, nil
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("type [%s] not supported", targetType)
+}
+
+// drop, fail
+
+type dropProcessor struct{}
+
+func newDropProcessor(_ *options) (Processor, error) {
+	return &dropProcessor{}, nil
+}
+
+func (p *dropProcessor) Process(doc *Document) error {
+	doc.Dropped = true
+	return nil
+}
+
+type failProcessor struct {
+	message string
+}
+
+func newFailProcessor(opts *options) (Processor, error) {
+	message, err := opts.requiredString("message")
+	if err != nil {
+		return nil, err
+	}
+	return &failProcessor{message: message}, nil
+}
+
+func (p *failProcessor) Process(doc *Document) error {
+	return fmt.Errorf("%s", doc.renderTemplate(p.message))
+}
+
+// pipeline, foreach
+
+type pipelineProcessor struct {
+	name                  string
+	ignoreMissingPipeline bool
+}
+
+func newPipelineProcessor(opts *options) (Processor, error) {
+	p := &pipelineProcessor{}
+	var err error
+	if p.name, err = opts.requiredString("name"); err != nil {
+		return nil, err
+	}
+	if p.ignoreMissingPipeline, err = opts.optionalBool("ignore_missing_pipeline", false); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *pipelineProcessor) Process(doc *Document) error {
+	name := doc.renderTemplate(p.name)
+	var pipeline *Pipeline
+	var err error
+	if doc.getPipeline != nil {
+		pipeline, err = doc.getPipeline(name)
+	} else {
+		err = fmt.Errorf("pipeline with id [%s] does not exist", name)
+	}
+	if err != nil {
+		if p.ignoreMissingPipeline {
+			return nil
+		}
+		return err
+	}
+	return pipeline.run(doc)
+}
+
+type foreachProcessor struct {
+	fieldProcessor
+	processor Processor
+}
+
+func newForeachProcessor(opts *options) (Processor, error) {
+	p := &foreachProcessor{}
+	var err error
+	if p.fieldProcessor, err = parseFieldOptions(opts); err != nil {
+		return nil, err
+	}
+	definition, exists := opts.get("processor")
+	if !exists {
+		return nil, fmt.Errorf("[processor] required property is missing")
+	}
+	if p.processor, err = parseProcessor(definition); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Process runs the processor for each element, available as `_ingest._value`.
+func (p *foreachProcessor) Process(doc *Document) error {
+	value, ok, err := p.value(doc)
+	if !ok {
+		return err
+	}
+	asArray, isArray := value.([]any)
+	if !isArray {
+		return fmt.Errorf("field [%s] of type [%T] cannot be cast to [list]", p.field, value)
+	}
+
+	defer delete(doc.Ingest, "_value")
+	result := make([]any, len(asArray))
+	for i, element := range asArray {
+		doc.Ingest["_value"] = element
+		if err = p.processor.Process(doc); err != nil {
+			return err
+		}
+		result[i] = doc.Ingest["_value"]
+	}
+	return doc.Set(doc.renderTemplate(p.field), result)
+}
@@ -0,0 +1,135 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package pipeline
+
+import (
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/parsers/painful"
+	"github.com/QuesmaOrg/quesma/platform/types"
+)
+
+// Ingest scripts and `if` conditions are run by the painful interpreter. Their `ctx` holds source fields
+// of the document, together with its metadata (`_index`, `_id`, ...) and `_ingest`, e.g.:
+//
+//	if (ctx.status >= 500 && ctx.tags != null) { ctx.level = 'error'; } else { ctx.remove('tags'); }
+//	def name = ctx.user?.name; ctx.user_name = name.toLowerCase();
+
+const ctxVariableName = "ctx"
+
+type scriptProcessor struct {
+	script *script
+}
+
+func newScriptProcessor(opts *options) (Processor, error) {
+	lang, err := opts.optionalString("lang", "painless")
+	if err != nil {
+		return nil, err
+	}
+	if lang != "painless" {
+		return nil, fmt.Errorf("unsupported script language [%s]", lang)
+	}
+	if _, isStored := opts.get("id"); isStored {
+		return nil, fmt.Errorf("stored scripts are not supported")
+	}
+	source, err := opts.requiredString("source")
+	if err != nil {
+		return nil, err
+	}
+	var params map[string]any
+	if paramsRaw, exists := opts.get("params"); exists {
+		var ok bool
+		if params, ok = asObject(paramsRaw); !ok {
+			return nil, fmt.Errorf("[params] property should be an object, got %T", paramsRaw)
+		}
+	}
+	parsed, err := parseScript(source, params)
+	if err != nil {
+		return nil, err
+	}
+	return &scriptProcessor{script: parsed}, nil
+}
+
+func (p *scriptProcessor) Process(doc *Document) error {
+	ctx := newScriptCtx(doc)
+	if _, err := p.script.eval(ctx); err != nil {
+		return err
+	}
+	doc.updateFromScriptCtx(ctx)
+	return nil
+}
+
+type script struct {
+	source string
+	expr   painful.Expr
+	params map[string]any
+}
+
+func parseScript(source string, params map[string]any) (*script, error) {
+	expr, err := painful.ParsePainless(source)
+	if err != nil {
+		return nil, err
+	}
+	if params = painful.NormalizeParams(params); params == nil {
+		params = map[string]any{}
+	}
+	return &script{source: source, expr: expr, params: params}, nil
+}
+
+func (s *script) eval(ctx map[string]any) (any, error) {
+	return s.expr.Eval(&painful.Env{Vars: map[string]any{ctxVariableName: ctx}, Params: s.params})
+}
+
+// evalCondition evaluates the script as an `if` condition. Like in Elasticsearch, conditions don't modify the document.
+func (s *script) evalCondition(doc *Document) (bool, error) {
+	value, err := s.eval(newScriptCtx(doc))
+	if err != nil {
+		return false, err
+	}
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("condition [%s] should return a boolean, got %T", s.source, value)
+	}
+	return result, nil
+}
+
+// newScriptCtx returns `ctx` of the document: a copy of its source with metadata fields and `_ingest`.
+// Integral numbers are integers, as Painless sees numbers of JSON documents.
+func newScriptCtx(doc *Document) map[string]any {
+	ctx := painful.NormalizeParams(doc.Source)
+	if ctx == nil {
+		ctx = map[string]any{}
+	}
+	for name := range metadataFields {
+		if value, ok := doc.Metadata[name]; ok {
+			ctx[name] = value
+		}
+	}
+	if doc.Ingest != nil {
+		ctx["_ingest"] = painful.NormalizeParams(doc.Ingest)
+	}
+	return ctx
+}
+
+// updateFromScriptCtx replaces the document with `ctx` modified by a script
+func (doc *Document) updateFromScriptCtx(ctx map[string]any) {
+	for name := range metadataFields {
+		if value, ok := ctx[name]; ok {
+			doc.Metadata[name] = painful.JSONValue(value)
+			delete(ctx, name)
+		} else {
+			delete(doc.Metadata, name)
+		}
+	}
+	if ingest, ok := asObject(ctx["_ingest"]); ok {
+		doc.Ingest = painful.JSONValue(ingest).(map[string]any)
+	}
+	delete(ctx, "_ingest")
+
+	if doc.Source == nil {
+		doc.Source = types.JSON{}
+	}
+	clear(doc.Source)
+	for name, value := range ctx {
+		doc.Source[name] = painful.JSONValue(value)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/types"
+)
+
+// Simulate runs the pipeline on documents of a `_ingest/pipeline/_simulate` request and renders the response.
+// The pipeline is either a stored one (`pipeline` is not nil) or inlined in the request body.
+func Simulate(body types.JSON, pipeline *Pipeline, getPipeline Getter) (types.JSON, error) {
+	if inlined, hasInlined := body["pipeline"]; hasInlined {
+		if pipeline != nil {
+			return nil, fmt.Errorf("pipeline can't be specified both in the path and in the request body")
+		}
+		definition, ok := asObject(inlined)
+		if !ok {
+			return nil, fmt.Errorf("[pipeline] should be an object, got %T", inlined)
+		}
+		var err error
+		if pipeline, err = Parse("_simulate_pipeline", definition); err != nil {
+			return nil, err
+		}
+	}
+	if pipeline == nil {
+		return nil, fmt.Errorf("[pipeline] required property is missing")
+	}
+
+	docs, ok := body["docs"].([]any)
+	if !ok || len(docs) == 0 {
+		return nil, fmt.Errorf("must specify at least one document in [docs]")
+	}
+
+	results := make([]any, 0, len(docs))
+	for _, docRaw := range docs {
+		docMap, ok := asObject(docRaw)
+		if !ok {
+			return nil, fmt.Errorf("[docs] should contain objects, got %T", docRaw)
+		}
+		source, ok := asObject(docMap["_source"])
+		if !ok {
+			return nil, fmt.Errorf("[_source] required property is missing")
+		}
+		index, _ := docMap["_index"].(string)
+		if index == "" {
+			index = "_index"
+		}
+		id, _ := docMap["_id"].(string)
+		if id == "" {
+			id = "_id"
+		}
+
+		doc := NewDocument(deepCopy(source).(map[string]any), index, id)
+		if err := pipeline.Run(doc, getPipeline); err != nil {
+			results = append(results, types.JSON{"error": RenderError(err)})
+			continue
+		}
+		if doc.Dropped {
+			results = append(results, nil)
+			continue
+		}
+
+		rendered := types.JSON{"_source": doc.Source, "_ingest": doc.Ingest}
+		for key, value := range doc.Metadata {
+			rendered[key] = value
+		}
+		results = append(results, types.JSON{"doc": rendered})
+	}
+	return types.JSON{"docs": results}, nil
+}
+
+// RenderError renders the error in Elasticsearch's format, used both by _simulate and in bulk responses.
+func RenderError(err error) types.JSON {
+	errorType, reason := "illegal_argument_exception", err.Error()
+	var processorErr *ProcessorError
+	if errors.As(err, &processorErr) {
+		reason = processorErr.Err.Error()
+	}
+	cause := types.JSON{"type": errorType, "reason": reason}
+	rendered := types.JSON{"root_cause": []any{cause}, "type": errorType, "reason": reason}
+	if processorErr != nil {
+		rendered["processor_type"] = processorErr.Type
+		if processorErr.Tag != "" {
+			rendered["processor_tag"] = processorErr.Tag
+		}
+	}
+	return rendered
+}
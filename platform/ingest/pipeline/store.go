@@ -0,0 +1,100 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/persistence"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"sort"
+	"sync"
+)
+
+// ElasticIndexName is the Elasticsearch index keeping pipeline definitions.
+const ElasticIndexName = "quesma_ingest_pipelines"
+
+var ErrNotFound = errors.New("pipeline not found")
+
+// Store keeps pipeline definitions in a JSONDatabase. Parsed pipelines are cached,
+// as they're looked up for every ingested document.
+type Store struct {
+	db persistence.JSONDatabase
+
+	mutex sync.Mutex
+	cache map[string]*Pipeline
+}
+
+func NewStore(db persistence.JSONDatabase) *Store {
+	return &Store{db: db, cache: make(map[string]*Pipeline)}
+}
+
+// Put validates and stores the pipeline definition, replacing the existing one.
+func (s *Store) Put(id string, definition types.JSON) error {
+	pipeline, err := Parse(id, definition)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(definition)
+	if err != nil {
+		return err
+	}
+	if err = s.db.Put(id, string(data)); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cache[id] = pipeline
+	return nil
+}
+
+// Get returns the pipeline, or an error wrapping ErrNotFound if it doesn't exist.
+func (s *Store) Get(id string) (*Pipeline, error) {
+	s.mutex.Lock()
+	if pipeline, ok := s.cache[id]; ok {
+		s.mutex.Unlock()
+		return pipeline, nil
+	}
+	s.mutex.Unlock()
+
+	data, found, err := s.db.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("pipeline with id [%s] does not exist: %w", id, ErrNotFound)
+	}
+	var definition types.JSON
+	if err = json.Unmarshal([]byte(data), &definition); err != nil {
+		return nil, fmt.Errorf("pipeline with id [%s] is corrupted: %w", id, err)
+	}
+	pipeline, err := Parse(id, definition)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cache[id] = pipeline
+	return pipeline, nil
+}
+
+// List returns sorted ids of all stored pipelines.
+func (s *Store) List() ([]string, error) {
+	ids, err := s.db.List()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (s *Store) Delete(id string) (found bool, err error) {
+	s.mutex.Lock()
+	delete(s.cache, id)
+	s.mutex.Unlock()
+
+	return s.db.Delete(id)
+}
@@ -0,0 +1,131 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/common_table"
+	"github.com/QuesmaOrg/quesma/platform/ingest/pipeline"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/types"
+)
+
+// ErrDocumentDropped is reported for documents dropped by their ingest pipeline, see IngestWithPipelines
+var ErrDocumentDropped = errors.New("document has been dropped by the ingest pipeline")
+
+// PipelineError is reported for documents, for which their ingest pipeline has failed, see IngestWithPipelines
+type PipelineError struct {
+	Pipeline string
+	Err      error
+}
+
+func (e *PipelineError) Error() string {
+	return fmt.Sprintf("ingest pipeline [%s] has failed: %v", e.Pipeline, e.Err)
+}
+
+func (e *PipelineError) Unwrap() error {
+	return e.Err
+}
+
+// SetPipelineStore replaces the default, in-memory storage of ingest pipelines.
+func (ip *IngestProcessor) SetPipelineStore(store *pipeline.Store) {
+	ip.pipelines = store
+}
+
+func (ip *IngestProcessor) GetPipelineStore() *pipeline.Store {
+	return ip.pipelines
+}
+
+// RunPipeline runs the ingest pipeline on a single document, before it's flattened and its schema is inferred,
+// so processors see the same (nested) document Elasticsearch would. It returns the transformed document, along with
+// its metadata (`_id`, `_index`, ...), which the pipeline may change too. Empty name and `_none` mean no pipeline.
+func (ip *IngestProcessor) RunPipeline(name, indexName, id string, document types.JSON) (*pipeline.Document, error) {
+	doc := pipeline.NewDocument(document, indexName, id)
+	if name == "" || name == pipeline.NonePipeline {
+		return doc, nil
+	}
+	p, err := ip.pipelines.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.Run(doc, ip.pipelines.Get); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// IngestWithPipelines runs ingest pipelines on documents (see RunPipeline) and ingests them. pipelines[i] is
+// the pipeline of jsonData[i], empty if none. Documents, for which the pipeline has failed or which have been
+// dropped, aren't ingested - RejectedDocumentsError reports them with PipelineError or ErrDocumentDropped.
+// Pipelines may set `_id` of documents, so ids of ingested documents are returned (empty for documents without id).
+// Documents can't be moved to another index, those for which the pipeline changes `_index` are rejected.
+func (ip *IngestProcessor) IngestWithPipelines(ctx context.Context, indexName string, jsonData []types.JSON, pipelines []string) ([]string, error) {
+	documents := make([]types.JSON, len(jsonData))
+	ids := make([]string, len(jsonData))
+	notIngested := make(map[int]error)
+	for i, document := range jsonData {
+		ids[i], _ = document[common_table.DocumentIdColumn].(string)
+		if pipelines[i] == "" {
+			documents[i] = document
+			continue
+		}
+		// the stored id is `_id` of the document for the pipeline, not its field
+		delete(document, common_table.DocumentIdColumn)
+		doc, err := ip.RunPipeline(pipelines[i], indexName, ids[i], document)
+		if err == nil && doc.Metadata["_index"] != indexName {
+			err = fmt.Errorf("changing [_index] to [%v] isn't supported", doc.Metadata["_index"])
+		}
+		switch {
+		case err != nil:
+			logger.WarnWithCtx(ctx).Msgf("ingest pipeline [%s] failed for a document of index %s: %v", pipelines[i], indexName, err)
+			notIngested[i] = &PipelineError{Pipeline: pipelines[i], Err: err}
+		case doc.Dropped:
+			notIngested[i] = ErrDocumentDropped
+		default:
+			ids[i] = pipelineDocumentId(doc)
+			if ids[i] != "" {
+				doc.Source[common_table.DocumentIdColumn] = ids[i]
+			}
+			documents[i] = doc.Source
+		}
+	}
+	if len(notIngested) == 0 {
+		return ids, ip.Ingest(ctx, indexName, documents)
+	}
+
+	// positions of ingested documents in jsonData, errors of Ingest refer to them by their positions in the batch
+	var ingested []int
+	for i := range documents {
+		if _, ok := notIngested[i]; !ok {
+			ingested = append(ingested, i)
+		}
+	}
+	if len(ingested) > 0 {
+		err := ip.Ingest(ctx, indexName, withoutRejected(documents, notIngested))
+		var rejectedErr *RejectedDocumentsError
+		if errors.As(err, &rejectedErr) {
+			for position, documentErr := range rejectedErr.Errors {
+				notIngested[ingested[position]] = documentErr
+			}
+		} else if err != nil {
+			for _, position := range ingested {
+				notIngested[position] = err
+			}
+		}
+	}
+	return ids, &RejectedDocumentsError{Errors: notIngested}
+}
+
+// pipelineDocumentId returns `_id` of the document after its pipeline, Elasticsearch converts other values to strings
+func pipelineDocumentId(doc *pipeline.Document) string {
+	switch id := doc.Metadata["_id"].(type) {
+	case nil:
+		return ""
+	case string:
+		return id
+	default:
+		return fmt.Sprint(id)
+	}
+}
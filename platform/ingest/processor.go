@@ -4,6 +4,7 @@ package ingest
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/QuesmaOrg/quesma/platform/common_table"
@@ -11,6 +12,10 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/database_common"
 	"github.com/QuesmaOrg/quesma/platform/elasticsearch"
 	"github.com/QuesmaOrg/quesma/platform/end_user_errors"
+	"github.com/QuesmaOrg/quesma/platform/index_templates"
+	"github.com/QuesmaOrg/quesma/platform/ingest/buffer"
+	"github.com/QuesmaOrg/quesma/platform/ingest/dead_letter"
+	"github.com/QuesmaOrg/quesma/platform/ingest/pipeline"
 	"github.com/QuesmaOrg/quesma/platform/logger"
 	"github.com/QuesmaOrg/quesma/platform/model"
 	"github.com/QuesmaOrg/quesma/platform/persistence"
@@ -23,6 +28,7 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/v2/core"
 	"github.com/QuesmaOrg/quesma/platform/v2/core/diag"
 	"github.com/goccy/go-json"
+	"github.com/google/uuid"
 	"slices"
 	"sort"
 	"strings"
@@ -66,6 +72,10 @@ type Processor struct {
 	errorLogCounter atomic.Int64
 	lowerers        map[quesma_api.BackendConnectorType]Lowerer
 	lowerer         *SqlLowerer
+	pipelines       *pipeline.Store
+	indexTemplates  *index_templates.Store
+	deadLetter      dead_letter.Sink // nil if disabled
+	buffer          *buffer.Buffer   // nil if disabled
 }
 
 type (
@@ -128,6 +138,9 @@ func (ip *IngestProcessor) Start() {
 
 func (ip *IngestProcessor) Stop() {
 	ip.cancel()
+	if ip.buffer != nil {
+		ip.buffer.Stop()
+	}
 }
 
 func (ip *IngestProcessor) Close() {
@@ -140,7 +153,7 @@ func addOurFieldsToCreateTableStatement(
 	table *database_common.Table,
 ) CreateTableStatement {
 	// Early exit if no attributes and timestamp is already handled
-	if len(config.Attributes) == 0 {
+	if len(config.Attributes) == 0 && !config.DeduplicateByDocumentId {
 		_, ok := table.Cols[timestampFieldName]
 		if !config.HasTimestamp || ok {
 			return stmt
@@ -185,6 +198,23 @@ func addOurFieldsToCreateTableStatement(
 
 	}
 
+	// Handle document id column, part of the sorting key of deduplicating tables
+	if config.DeduplicateByDocumentId {
+		if _, ok := table.Cols[common_table.DocumentIdColumn]; !ok {
+			stmt.Columns = append([]ColumnStatement{
+				{
+					ColumnName:         common_table.DocumentIdColumn,
+					ColumnType:         "String",
+					AdditionalMetadata: "DEFAULT ''",
+				},
+			}, stmt.Columns...)
+			table.Cols[common_table.DocumentIdColumn] = &database_common.Column{
+				Name: common_table.DocumentIdColumn,
+				Type: database_common.NewBaseType("String"),
+			}
+		}
+	}
+
 	// Handle timestamp column
 	if config.HasTimestamp {
 		if _, ok := table.Cols[timestampFieldName]; !ok {
@@ -273,7 +303,7 @@ func (ip *IngestProcessor) createTableObjectAndAttributes(ctx context.Context, t
 	// if exists only then createTable
 	noSuchTable := ip.AddTableIfDoesntExist(table)
 	if !noSuchTable {
-		return nil, fmt.Errorf("table %s already exists", table.Name)
+		return nil, fmt.Errorf("table %s: %w", table.Name, errTableAlreadyExists)
 	}
 
 	return table, nil
@@ -416,10 +446,15 @@ func generateNonSchemaFields(attrsMap map[string][]interface{}) ([]NonSchemaFiel
 }
 
 // This function implements heuristic for deciding if we should add new columns
-func (ip *SqlLowerer) shouldAlterColumns(table *database_common.Table, attrsMap map[string][]interface{}) (bool, []int) {
+func (ip *SqlLowerer) shouldAlterColumns(table *database_common.Table, attrsMap map[string][]interface{}, policy SchemaEvolution) (bool, []int) {
 	attrKeys := getAttributesByArrayName(database_common.DeprecatedAttributesKeyColumn, attrsMap)
 	alterColumnIndexes := make([]int, 0)
 
+	if !policy.addsColumns() && table.Name != common_table.TableName {
+		alterColumnIndexes = documentIdAttributeIndexes(attrKeys)
+		return len(alterColumnIndexes) > 0, alterColumnIndexes
+	}
+
 	// this is special case for common table storage
 	// we do always add columns for common table storage
 	if table.Name == common_table.TableName {
@@ -437,8 +472,10 @@ func (ip *SqlLowerer) shouldAlterColumns(table *database_common.Table, attrsMap
 		return true, alterColumnIndexes
 	}
 
+	alterColumnIndexes = append(alterColumnIndexes, documentIdAttributeIndexes(attrKeys)...)
+
 	if len(table.Cols) > alterColumnUpperLimit {
-		return false, nil
+		return len(alterColumnIndexes) > 0, alterColumnIndexes
 	}
 	ip.ingestFieldStatisticsLock.Lock()
 	if ip.ingestFieldStatistics == nil {
@@ -461,7 +498,7 @@ func (ip *SqlLowerer) shouldAlterColumns(table *database_common.Table, attrsMap
 		// if field is present more or equal fieldFrequency
 		// during each alwaysAddColumnLimit iteration
 		// promote it to column
-		if fieldCounter >= fieldFrequency {
+		if fieldCounter >= fieldFrequency && attrKeys[i] != common_table.DocumentIdColumn {
 			alterColumnIndexes = append(alterColumnIndexes, i)
 		}
 	}
@@ -510,10 +547,11 @@ func populateFieldEncodings(jsonData []types.JSON, tableName string) map[schema.
 	return encodings
 }
 
-func (ip *IngestProcessor) processInsertQuery(ctx context.Context,
-	tableName string,
-	jsonData []types.JSON, transformer IngestTransformer,
-	tableFormatter TableColumNameFormatter, tableDefinitionChangeOnly bool) ([]string, error) {
+// prepareDocuments changes documents to the form, in which they're inserted: arrays of objects are rewritten,
+// values are normalized according to the schema, field names are encoded and the transformer is applied.
+// It returns encodings of their fields too. Documents are modified in place.
+func (ip *IngestProcessor) prepareDocuments(tableName, indexName string, jsonData []types.JSON,
+	transformer IngestTransformer) ([]types.JSON, map[schema.FieldEncodingKey]schema.EncodedFieldName, error) {
 	// this is pre ingest transformer
 	// here we transform the data before it's structure evaluation and insertion
 	//
@@ -522,12 +560,19 @@ func (ip *IngestProcessor) processInsertQuery(ctx context.Context,
 	for _, jsonValue := range jsonData {
 		result, err := preIngestTransformer.Transform(jsonValue)
 		if err != nil {
-			return nil, fmt.Errorf("error while rewriting json: %v", err)
+			return nil, nil, fmt.Errorf("error while rewriting json: %v", err)
 		}
 		processed = append(processed, result)
 	}
 	jsonData = processed
 
+	if ip.schemaRegistry != nil {
+		indexSchema := findSchemaPointer(ip.schemaRegistry, indexName)
+		for _, jsonValue := range jsonData {
+			normalizeFieldValues(jsonValue, indexSchema)
+		}
+	}
+
 	// we are doing two passes, e.g. calling transformFieldName twice
 	// first time we populate encodings map
 	// second time we do field encoding
@@ -538,10 +583,6 @@ func (ip *IngestProcessor) processInsertQuery(ctx context.Context,
 	// and requires some rewrite of json flattening
 	encodings := populateFieldEncodings(jsonData, tableName)
 
-	if ip.schemaRegistry != nil {
-		ip.schemaRegistry.UpdateFieldEncodings(encodings)
-	}
-
 	// Do field encoding here, once for all jsons
 	// This is in-place operation
 	for _, jsonValue := range jsonData {
@@ -552,22 +593,46 @@ func (ip *IngestProcessor) processInsertQuery(ctx context.Context,
 	for _, jsonValue := range jsonData {
 		transformedJson, err := transformer.Transform(jsonValue)
 		if err != nil {
-			return nil, fmt.Errorf("error while transforming json: %v", err)
+			return nil, nil, fmt.Errorf("error while transforming json: %v", err)
 		}
 		transformedJsons = append(transformedJsons, transformedJson)
 	}
 
+	return transformedJsons, encodings, nil
+}
+
+func (ip *IngestProcessor) processInsertQuery(ctx context.Context,
+	tableName, indexName string,
+	jsonData []types.JSON, transformer IngestTransformer,
+	tableFormatter TableColumNameFormatter, tableDefinitionChangeOnly bool, policy SchemaEvolution) (statements []string, invalidJsons []types.JSON, rejected map[int]error, err error) {
+	transformedJsons, encodings, err := ip.prepareDocuments(tableName, indexName, jsonData, transformer)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if ip.schemaRegistry != nil {
+		ip.schemaRegistry.UpdateFieldEncodings(encodings)
+	}
+
 	table := ip.FindTable(tableName)
 	var tableConfig *database_common.ChTableConfig
 	var createTableCmd CreateTableStatement
 	if table == nil {
 		tableConfig = NewOnlySchemaFieldsCHConfig(ip.cfg.ClusterName)
-		if indexConfig, ok := ip.cfg.IndexConfig[tableName]; ok {
+		if indexConfig, ok := ip.indexConfiguration(indexName, tableName); ok {
 			tableConfig.PartitionStrategy = indexConfig.PartitioningStrategy
+			tableConfig.DeduplicateByDocumentId = indexConfig.DeduplicateDocuments
+		} else if strategy := ip.templatePartitioningStrategy(indexName); strategy != "" {
+			tableConfig.PartitionStrategy = strategy
 		} else if strategy := ip.cfg.DefaultPartitioningStrategy; strategy != "" {
 			tableConfig.PartitionStrategy = strategy
 		}
 		columnsFromJson := JsonToColumns(transformedJsons[0], tableConfig)
+		if tableConfig.DeduplicateByDocumentId {
+			// the id is a part of the sorting key, so it can't be Nullable - addOurFieldsToCreateTableStatement adds it
+			columnsFromJson = slices.DeleteFunc(columnsFromJson, func(column CreateTableEntry) bool {
+				return column.ClickHouseColumnName == common_table.DocumentIdColumn
+			})
+		}
 
 		fieldOrigins := make(map[schema.FieldName]schema.FieldSource)
 
@@ -578,14 +643,15 @@ func (ip *IngestProcessor) processInsertQuery(ctx context.Context,
 		ip.schemaRegistry.UpdateFieldsOrigins(schema.IndexName(tableName), fieldOrigins)
 
 		// This comes externally from (configuration), therefore we need to convert that separately
-		columnsFromSchema := SchemaToColumns(findSchemaPointer(ip.schemaRegistry, tableName), tableFormatter, tableName, ip.schemaRegistry.GetFieldEncodings())
+		// the schema is kept for the index, its table may have a different name (`tableName` option)
+		columnsFromSchema := SchemaToColumns(findSchemaPointer(ip.schemaRegistry, indexName), tableFormatter, tableName, ip.schemaRegistry.GetFieldEncodings())
+		policy.applyDynamicTemplates(columnsFromJson, columnsFromSchema, ip.schemaRegistry.GetFieldEncodings(), tableName)
 		resultColumns := columnsToProperties(columnsFromJson, columnsFromSchema, ip.schemaRegistry.GetFieldEncodings(), tableName)
 		createTableCmd = BuildCreateTable(tableName, resultColumns, Indexes(transformedJsons[0]), tableConfig)
-		var err error
 		table, err = ip.createTableObjectAndAttributes(ctx, tableName, columnsFromJson, columnsFromSchema, tableConfig, tableDefinitionChangeOnly)
 		if err != nil {
 			logger.ErrorWithCtx(ctx).Msgf("error createTableObjectAndAttributes, can't create table: %v", err)
-			return nil, err
+			return nil, nil, nil, err
 		} else {
 			// Likely we want to remove below line
 			createTableCmd = addOurFieldsToCreateTableStatement(createTableCmd, tableConfig, table)
@@ -593,19 +659,53 @@ func (ip *IngestProcessor) processInsertQuery(ctx context.Context,
 	}
 
 	if table == nil {
-		return nil, fmt.Errorf("table %s not found", tableName)
+		return nil, nil, nil, fmt.Errorf("table %s not found", tableName)
+	}
+	if _, hasStoredIds := table.Cols[common_table.DocumentIdColumn]; hasStoredIds && ip.deduplicatesDocuments(indexName, tableName) {
+		addMissingDocumentIds(transformedJsons)
 	}
 	var validatedJsons []types.JSON
-	var invalidJsons []types.JSON
-	validatedJsons, invalidJsons, err := ip.preprocessJsons(ctx, table.Name, transformedJsons)
+	validatedJsons, invalidJsons, err = ip.preprocessJsons(ctx, table.Name, transformedJsons)
 	if err != nil {
-		return nil, fmt.Errorf("error preprocessJsons: %v", err)
+		return nil, nil, nil, fmt.Errorf("error preprocessJsons: %v", err)
+	}
+	validatedJsons, invalidJsons, rejected = policy.rejectStrictDynamicMapping(table, validatedJsons, invalidJsons, encodings)
+	if len(validatedJsons) == 0 && createTableCmd.Name == "" {
+		return nil, nil, rejected, nil
 	}
 	ddlLowerer, ok := ip.lowerers[ip.chDb.GetId()]
 	if !ok {
-		return nil, fmt.Errorf("no lowerer registered for connector type %s", quesma_api.GetBackendConnectorNameFromType(ip.chDb.GetId()))
+		return nil, nil, nil, fmt.Errorf("no lowerer registered for connector type %s", quesma_api.GetBackendConnectorNameFromType(ip.chDb.GetId()))
+	}
+	statements, err = ddlLowerer.LowerToDDL(validatedJsons, table, invalidJsons, encodings, createTableCmd, policy)
+	return statements, invalidJsons, rejected, err
+}
+
+// indexConfiguration returns the configuration of the index. Tables named differently than their indexes
+// may have the configuration under the table name.
+func (ip *IngestProcessor) indexConfiguration(indexName, tableName string) (config.IndexConfiguration, bool) {
+	if indexConfig, ok := ip.cfg.IndexConfig[indexName]; ok {
+		return indexConfig, true
+	}
+	indexConfig, ok := ip.cfg.IndexConfig[tableName]
+	return indexConfig, ok
+}
+
+// deduplicatesDocuments returns true if the table of the index is created as ReplacingMergeTree sorted by document ids
+// (see database_common.ChTableConfig.DeduplicateByDocumentId)
+func (ip *IngestProcessor) deduplicatesDocuments(indexName, tableName string) bool {
+	indexConfig, ok := ip.indexConfiguration(indexName, tableName)
+	return ok && indexConfig.DeduplicateDocuments
+}
+
+// addMissingDocumentIds gives a random id to documents ingested without `_id`. Otherwise, they'd all have an empty id,
+// and ReplacingMergeTree would merge the ones with the same timestamp into one row.
+func addMissingDocumentIds(documents []types.JSON) {
+	for _, document := range documents {
+		if id, ok := document[common_table.DocumentIdColumn].(string); !ok || id == "" {
+			document[common_table.DocumentIdColumn] = uuid.New().String()
+		}
 	}
-	return ddlLowerer.LowerToDDL(validatedJsons, table, invalidJsons, encodings, createTableCmd)
 }
 
 func (lm *IngestProcessor) Ingest(ctx context.Context, indexName string, jsonData []types.JSON) error {
@@ -615,6 +715,17 @@ func (lm *IngestProcessor) Ingest(ctx context.Context, indexName string, jsonDat
 		return err
 	}
 
+	if lm.buffer != nil {
+		rejected := lm.rejectBeforeBuffering(indexName, jsonData)
+		if err = lm.buffer.Add(indexName, withoutRejected(jsonData, rejected)); err != nil {
+			return err
+		}
+		if len(rejected) > 0 {
+			return &RejectedDocumentsError{Errors: rejected}
+		}
+		return nil
+	}
+
 	nameFormatter := DefaultColumnNameFormatter()
 	transformer := IngestTransformerFor(indexName, lm.cfg)
 	return lm.ProcessInsertQuery(ctx, indexName, jsonData, transformer, nameFormatter)
@@ -623,6 +734,14 @@ func (lm *IngestProcessor) Ingest(ctx context.Context, indexName string, jsonDat
 func (lm *IngestProcessor) ProcessInsertQuery(ctx context.Context, tableName string,
 	jsonData []types.JSON, transformer IngestTransformer,
 	tableFormatter TableColumNameFormatter) error {
+	return lm.processInsertQueryToConnectors(ctx, tableName, jsonData, transformer, tableFormatter, true)
+}
+
+// processInsertQueryToConnectors inserts documents to tables of the index. Unless deadLetterOnFailure is set,
+// documents of a failed insert aren't kept in the dead letter queue, because the caller is going to retry it.
+func (lm *IngestProcessor) processInsertQueryToConnectors(ctx context.Context, tableName string,
+	jsonData []types.JSON, transformer IngestTransformer,
+	tableFormatter TableColumNameFormatter, deadLetterOnFailure bool) error {
 
 	decision := lm.tableResolver.Resolve(quesma_api.IngestPipeline, tableName)
 
@@ -655,7 +774,7 @@ func (lm *IngestProcessor) ProcessInsertQuery(ctx context.Context, tableName str
 				clonedJsonData = append(clonedJsonData, jsonValue.Clone())
 			}
 
-			err := lm.processInsertQueryInternal(ctx, tableName, clonedJsonData, transformer, tableFormatter, true)
+			err := lm.processInsertQueryInternal(ctx, tableName, tableName, clonedJsonData, transformer, tableFormatter, true, false)
 			if err != nil {
 				// we ignore an error here, because we want to process the data and don't lose it
 				logger.ErrorWithCtx(ctx).Msgf("error processing insert query - virtual table schema update: %v", err)
@@ -665,13 +784,13 @@ func (lm *IngestProcessor) ProcessInsertQuery(ctx context.Context, tableName str
 			pipeline = append(pipeline, &common_table.IngestAddIndexNameTransformer{IndexName: tableName})
 			pipeline = append(pipeline, transformer)
 
-			err = lm.processInsertQueryInternal(ctx, common_table.TableName, jsonData, pipeline, tableFormatter, false)
+			err = lm.processInsertQueryInternal(ctx, common_table.TableName, tableName, jsonData, pipeline, tableFormatter, false, deadLetterOnFailure)
 			if err != nil {
 				return fmt.Errorf("error processing insert query to a common table: %w", err)
 			}
 
 		} else {
-			err := lm.processInsertQueryInternal(ctx, clickhouseDecision.ClickhouseTableName, jsonData, transformer, tableFormatter, false)
+			err := lm.processInsertQueryInternal(ctx, clickhouseDecision.ClickhouseTableName, tableName, jsonData, transformer, tableFormatter, false, deadLetterOnFailure)
 			if err != nil {
 				return fmt.Errorf("error processing insert query: %w", err)
 			}
@@ -718,14 +837,48 @@ func (ip *IngestProcessor) applyAsyncInsertOptimizer(tableName string, clickhous
 	return clickhouseSettings
 }
 
-func (ip *IngestProcessor) processInsertQueryInternal(ctx context.Context, tableName string,
+func (ip *IngestProcessor) processInsertQueryInternal(ctx context.Context, tableName, indexName string,
 	jsonData []types.JSON, transformer IngestTransformer,
-	tableFormatter TableColumNameFormatter, isVirtualTable bool) error {
-	statements, err := ip.processInsertQuery(ctx, tableName, jsonData, transformer, tableFormatter, isVirtualTable)
+	tableFormatter TableColumNameFormatter, isVirtualTable, deadLetterOnFailure bool) error {
+	// documents are modified in place while processed, so we keep them as they came for the dead letter queue
+	var originalJsons []types.JSON
+	if ip.deadLetter != nil && !isVirtualTable {
+		originalJsons = make([]types.JSON, 0, len(jsonData))
+		for _, jsonValue := range jsonData {
+			originalJsons = append(originalJsons, jsonValue.Clone())
+		}
+	}
+
+	// the common table keeps documents of many indexes, so its schema is always dynamic
+	var policy SchemaEvolution
+	if tableName != common_table.TableName && !isVirtualTable {
+		if ip.FindTable(tableName) == nil {
+			ip.applyIndexTemplate(indexName)
+		}
+		policy = ip.schemaEvolution(indexName)
+	}
+
+	statements, invalidJsons, rejected, err := ip.processInsertQuery(ctx, tableName, indexName, jsonData, transformer, tableFormatter, isVirtualTable, policy)
 	if err != nil {
 		logger.ErrorWithCtx(ctx).Msgf("error processing insert query: %v", err)
+		// lowering depends only on the documents and the table, so it would fail again
+		if !errors.Is(err, errTableAlreadyExists) {
+			err = buffer.Permanent(err)
+		}
+		if deadLetterOnFailure || buffer.IsPermanent(err) {
+			ip.recordRejectedDocuments(ctx, indexName, originalJsons, err)
+		}
 		return err
 	}
+	// rejected documents are reported to the client, so they aren't kept in the dead letter queue
+	var rejectedErr error
+	if len(rejected) > 0 {
+		originalJsons = withoutRejected(originalJsons, rejected)
+		rejectedErr = &RejectedDocumentsError{Errors: rejected}
+	}
+	if len(statements) == 0 {
+		return rejectedErr
+	}
 
 	var logVirtualTableDDL bool // maybe this should be a part of the config or sth
 
@@ -740,7 +893,7 @@ func (ip *IngestProcessor) processInsertQueryInternal(ctx context.Context, table
 	}
 
 	if isVirtualTable {
-		return nil
+		return rejectedErr
 	}
 
 	clickhouseSettings := clickhouse.Settings{
@@ -752,7 +905,15 @@ func (ip *IngestProcessor) processInsertQueryInternal(ctx context.Context, table
 	// We expect to have date format set to `best_effort`
 	ctx = clickhouse.Context(ctx, clickhouse.WithSettings(clickhouseSettings))
 
-	return ip.executeStatements(ctx, statements)
+	if err = ip.executeStatements(ctx, statements); err != nil {
+		err = classifyExecuteError(err)
+		if deadLetterOnFailure || buffer.IsPermanent(err) {
+			ip.recordRejectedDocuments(ctx, indexName, originalJsons, err)
+		}
+		return err
+	}
+	ip.recordDocumentsWithInvalidFields(ctx, indexName, originalJsons, invalidJsons)
+	return rejectedErr
 }
 
 // This function removes fields that are part of anotherDoc from inputDoc
@@ -923,6 +1084,10 @@ func (ip *IngestProcessor) GetSchemaRegistry() schema.Registry {
 	return ip.schemaRegistry
 }
 
+func (ip *IngestProcessor) GetTableDiscovery() database_common.TableDiscovery {
+	return ip.tableDiscovery
+}
+
 func (ip *IngestProcessor) GetTableResolver() table_resolver.TableResolver {
 	return ip.tableResolver
 }
@@ -941,7 +1106,9 @@ func NewIngestProcessor(cfg *config.QuesmaConfiguration, chDb quesma_api.Backend
 	return &IngestProcessor{ctx: ctx, cancel: cancel, chDb: chDb,
 		tableDiscovery: loader, cfg: cfg, phoneHomeClient: phoneHomeClient,
 		schemaRegistry: schemaRegistry, lowerers: make(map[quesma_api.BackendConnectorType]Lowerer),
-		lowerer: lowerer, tableResolver: tableResolver, indexNameRewriter: indexRewriter}
+		lowerer: lowerer, tableResolver: tableResolver, indexNameRewriter: indexRewriter,
+		pipelines:      pipeline.NewStore(persistence.NewStaticJSONDatabase()),
+		indexTemplates: index_templates.NewStore(persistence.NewStaticJSONDatabase(), persistence.NewStaticJSONDatabase())}
 }
 
 func NewOnlySchemaFieldsCHConfig(clusterName string) *database_common.ChTableConfig {
@@ -3,11 +3,14 @@
 package ingest
 
 import (
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/QuesmaOrg/quesma/platform/backend_connectors"
 	"github.com/QuesmaOrg/quesma/platform/config"
 	"github.com/QuesmaOrg/quesma/platform/database_common"
+	"github.com/QuesmaOrg/quesma/platform/ingest/dead_letter"
 	"github.com/QuesmaOrg/quesma/platform/persistence"
 	"github.com/QuesmaOrg/quesma/platform/schema"
+	"github.com/QuesmaOrg/quesma/platform/table_resolver"
 	"github.com/QuesmaOrg/quesma/platform/types"
 	"github.com/QuesmaOrg/quesma/platform/util"
 	quesma_api "github.com/QuesmaOrg/quesma/platform/v2/core"
@@ -32,6 +35,36 @@ func newIngestProcessorWithEmptyTableMap(tables *TableMap, cfg *config.QuesmaCon
 	return processor
 }
 
+// newIngestProcessorWithSqlMock returns a processor inserting to sqlmock, with a single `tableName` table
+// configured by indexConfig. Documents are dead-lettered to sink, if it's not nil.
+func newIngestProcessorWithSqlMock(t *testing.T, indexConfig config.IndexConfiguration, sink dead_letter.Sink) (*IngestProcessor, sqlmock.Sqlmock, *database_common.Table) {
+	conn, mock := util.InitSqlMockWithPrettySqlAndPrint(t, false)
+	db := backend_connectors.NewClickHouseBackendConnectorWithConnection("", conn)
+	t.Cleanup(func() { _ = db.Close() })
+
+	table := &database_common.Table{
+		Name:   tableName,
+		Config: NewChTableConfigFourAttrs(),
+		Cols: map[string]*database_common.Column{
+			"int_field": {Name: "int_field", Type: database_common.BaseType{Name: "Int32", Nullable: true}, Modifiers: "Nullable"},
+		},
+	}
+	tableMap := util.NewSyncMapWith(tableName, table)
+
+	ip := newIngestProcessorEmpty()
+	ip.chDb = db
+	ip.cfg.IndexConfig = map[string]config.IndexConfiguration{tableName: indexConfig}
+	ip.tableDiscovery = database_common.NewTableDiscoveryWith(&config.QuesmaConfiguration{}, nil, *tableMap)
+	resolver := table_resolver.NewEmptyTableResolver()
+	resolver.Decisions[tableName] = &quesma_api.Decision{
+		UseConnectors: []quesma_api.ConnectorDecision{&quesma_api.ConnectorDecisionClickhouse{ClickhouseTableName: tableName}}}
+	ip.tableResolver = resolver
+	if sink != nil {
+		ip.SetDeadLetterSink(sink)
+	}
+	return ip, mock, table
+}
+
 func newIngestProcessorWithHydrolixLowerer(tables *TableMap, cfg *config.QuesmaConfiguration) *IngestProcessor {
 	var tableDefinitions = atomic.Pointer[TableMap]{}
 	tableDefinitions.Store(tables)
@@ -91,7 +124,7 @@ func TestInsertNonSchemaFieldsToOthers_1(t *testing.T) {
 	assert.True(t, exists)
 	f := func(t1, t2 TableMap) {
 		ip := newIngestProcessorWithEmptyTableMap(fieldsMap, &config.QuesmaConfiguration{})
-		alter, onlySchemaFields, nonSchemaFields, err := ip.lowerer.GenerateIngestContent(tableName, types.MustJSON(rowToInsert), nil, encodings)
+		alter, onlySchemaFields, nonSchemaFields, err := ip.lowerer.GenerateIngestContent(tableName, types.MustJSON(rowToInsert), nil, encodings, SchemaEvolution{})
 		assert.NoError(t, err)
 		j, err := generateInsertJson(nonSchemaFields, onlySchemaFields)
 		assert.NoError(t, err)
@@ -0,0 +1,326 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package ingest
+
+import (
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/common_table"
+	"github.com/QuesmaOrg/quesma/platform/config"
+	chLib "github.com/QuesmaOrg/quesma/platform/database_common"
+	"github.com/QuesmaOrg/quesma/platform/schema"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// SchemaEvolution is the policy of changing the table of an index, when ingested documents don't match its columns.
+// The zero value is the default one: new columns are added, values not matching column types are stored in attributes.
+type SchemaEvolution struct {
+	Dynamic          config.DynamicMapping
+	WidenColumnTypes bool
+
+	// MappedColumns are columns of fields defined by the index mapping (see SchemaToColumns), new columns get their types
+	MappedColumns map[schema.FieldName]CreateTableEntry
+	// DynamicTemplates of the index mapping choose types of other new columns
+	DynamicTemplates []schema.DynamicTemplate
+}
+
+// schemaEvolution returns the policy configured for the index, or the default one from the "*" index configuration,
+// together with the index mapping (PUT /:index or PUT /:index/_mapping).
+func (ip *IngestProcessor) schemaEvolution(indexName string) SchemaEvolution {
+	var policy SchemaEvolution
+	if ip.cfg != nil {
+		if indexConfig, ok := ip.cfg.IndexConfig[indexName]; ok {
+			policy = SchemaEvolution{Dynamic: indexConfig.Dynamic.Normalized(), WidenColumnTypes: indexConfig.WidenColumnTypes}
+		} else {
+			policy = SchemaEvolution{Dynamic: ip.cfg.DefaultDynamic.Normalized(), WidenColumnTypes: ip.cfg.DefaultWidenColumnTypes}
+		}
+	}
+	if ip.schemaRegistry == nil {
+		return policy
+	}
+	mapping, found := ip.schemaRegistry.GetDynamicConfiguration(schema.IndexName(indexName))
+	if !found {
+		return policy
+	}
+	// `dynamic` of the mapping can only restrict the configuration
+	if mapping.Dynamic != "" && policy.Dynamic.Normalized() == config.DynamicMappingTrue {
+		policy.Dynamic = config.DynamicMapping(mapping.Dynamic)
+	}
+	policy.DynamicTemplates = mapping.DynamicTemplates
+	if len(mapping.Columns) > 0 {
+		policy.MappedColumns = SchemaToColumns(findSchemaPointer(ip.schemaRegistry, indexName), DefaultColumnNameFormatter(), indexName, ip.schemaRegistry.GetFieldEncodings())
+	}
+	return policy
+}
+
+func (p SchemaEvolution) addsColumns() bool {
+	return p.Dynamic.Normalized() == config.DynamicMappingTrue
+}
+
+// StrictDynamicMappingError is returned when documents of an index with `dynamic: strict` have fields without columns.
+type StrictDynamicMappingError struct {
+	Fields []string
+}
+
+func (e *StrictDynamicMappingError) Error() string {
+	// the same message as Elasticsearch's strict_dynamic_mapping_exception
+	return fmt.Sprintf("mapping set to strict, dynamic introduction of [%s] within [_doc] is not allowed", strings.Join(e.Fields, ", "))
+}
+
+// RejectedDocumentsError is returned when some of ingested documents have been rejected, e.g. with
+// StrictDynamicMappingError. The other documents have been stored.
+type RejectedDocumentsError struct {
+	Errors map[int]error // by position of the document in the ingested batch
+}
+
+func (e *RejectedDocumentsError) Error() string {
+	positions := make([]int, 0, len(e.Errors))
+	for i := range e.Errors {
+		positions = append(positions, i)
+	}
+	sort.Ints(positions)
+	return fmt.Sprintf("%d documents have been rejected, e.g. document %d: %v", len(positions), positions[0], e.Errors[positions[0]])
+}
+
+func (e *RejectedDocumentsError) Unwrap() []error {
+	result := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		result = append(result, err)
+	}
+	return result
+}
+
+// rejectStrictDynamicMapping removes documents with fields, which don't have columns, if the policy is strict.
+// Like Elasticsearch, only these documents are rejected, the other ones are stored. It returns the remaining
+// documents and their invalid values (aligned), and errors of rejected documents by their positions.
+func (p SchemaEvolution) rejectStrictDynamicMapping(table *chLib.Table, documents, invalidJsons []types.JSON,
+	encodings map[schema.FieldEncodingKey]schema.EncodedFieldName) ([]types.JSON, []types.JSON, map[int]error) {
+	if p.Dynamic.Normalized() != config.DynamicMappingStrict {
+		return documents, invalidJsons, nil
+	}
+	var rejected map[int]error
+	var accepted, acceptedInvalidJsons []types.JSON
+	for i, document := range documents {
+		if err := p.checkStrictDynamicMapping(table, DifferenceMap(document, table), encodings); err != nil {
+			if rejected == nil {
+				rejected = make(map[int]error)
+			}
+			rejected[i] = err
+			continue
+		}
+		accepted = append(accepted, document)
+		acceptedInvalidJsons = append(acceptedInvalidJsons, invalidJsons[i])
+	}
+	return accepted, acceptedInvalidJsons, rejected
+}
+
+// withoutRejected returns documents, which haven't been rejected, in their order
+func withoutRejected(documents []types.JSON, rejected map[int]error) []types.JSON {
+	if len(rejected) == 0 {
+		return documents
+	}
+	result := make([]types.JSON, 0, len(documents))
+	for i, document := range documents {
+		if _, ok := rejected[i]; !ok {
+			result = append(result, document)
+		}
+	}
+	return result
+}
+
+// checkStrictDynamicMapping returns StrictDynamicMappingError if the policy is strict, and the document
+// has fields, which don't have columns. Document ids are stored in their own column anyway.
+func (p SchemaEvolution) checkStrictDynamicMapping(table *chLib.Table, fieldsWithoutColumns SchemaMap,
+	encodings map[schema.FieldEncodingKey]schema.EncodedFieldName) error {
+	if p.Dynamic.Normalized() != config.DynamicMappingStrict {
+		return nil
+	}
+	reverseMap := reverseFieldEncoding(encodings, table.Name)
+	var fields []string
+	for columnName := range fieldsWithoutColumns {
+		if columnName == common_table.DocumentIdColumn {
+			continue
+		}
+		if field, ok := reverseMap[schema.EncodedFieldName(columnName)]; ok {
+			fields = append(fields, field.FieldName)
+		} else {
+			fields = append(fields, columnName)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	sort.Strings(fields)
+	return &StrictDynamicMappingError{Fields: fields}
+}
+
+// documentIdAttributeIndexes returns indexes of document ids in attributes. They are always promoted to their
+// own column, otherwise we couldn't look documents up by id.
+func documentIdAttributeIndexes(attrKeys []string) []int {
+	var indexes []int
+	for i, key := range attrKeys {
+		if key == common_table.DocumentIdColumn {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// columnTypeWidenings lists column types, which can be safely changed to wider ones, in order of preference.
+// Float32 and String columns accept all numbers and strings respectively, so values never make them change.
+// String columns become LowCardinality(String) though, when the mapping asks for it (e.g. `constant_keyword`).
+var columnTypeWidenings = map[string][]string{
+	"Int8":   {"Int64", "Float64"},
+	"Int16":  {"Int64", "Float64"},
+	"Int32":  {"Int64", "Float64"},
+	"UInt8":  {"Int64", "Float64"},
+	"UInt16": {"Int64", "Float64"},
+	"UInt32": {"Int64", "Float64"},
+	"Int64":  {"Float64"},
+	"UInt64": {"Float64"},
+	"String": {"LowCardinality(String)"},
+}
+
+// widenedType returns the wider type of a column, keeping it Nullable. ClickHouse requires Nullable
+// to be wrapped by LowCardinality, not the other way around.
+func widenedType(wider string, nullable bool) chLib.BaseType {
+	if wider == "LowCardinality(String)" && nullable {
+		return chLib.NewBaseType("LowCardinality(Nullable(String))")
+	}
+	widerType := chLib.NewBaseType(wider)
+	widerType.Nullable = nullable
+	return widerType
+}
+
+// widenColumns returns statements widening columns of the table, if the policy allows it: to the types
+// from the mapping, and to types accepting values of the document (see widenColumnTypes).
+func (p SchemaEvolution) widenColumns(table *chLib.Table, document, invalidJson types.JSON) []AlterStatement {
+	if !p.WidenColumnTypes {
+		return nil
+	}
+	widened := widenMappedColumnTypes(table, p.MappedColumns)
+	for columnName, columnType := range widenColumnTypes(table, document, invalidJson) {
+		if widened == nil {
+			widened = make(map[string]chLib.BaseType)
+		}
+		widened[columnName] = columnType
+	}
+	return modifyColumnStatements(table, widened)
+}
+
+// widenMappedColumnTypes changes types of existing columns to the types of their fields in the mapping,
+// if that's a safe widening, e.g. when a field is mapped after its column has been created by ingest.
+// Like widenColumnTypes, it returns new types of the columns and replaces table.Cols.
+func widenMappedColumnTypes(table *chLib.Table, mappedColumns map[schema.FieldName]CreateTableEntry) map[string]chLib.BaseType {
+	var widened map[string]chLib.BaseType
+	var newColumns map[string]*chLib.Column
+	for _, mapped := range mappedColumns {
+		column, ok := table.Cols[mapped.ClickHouseColumnName]
+		if !ok || column == nil {
+			continue
+		}
+		baseType, ok := column.Type.(chLib.BaseType)
+		if !ok {
+			continue
+		}
+		// e.g. `Nullable(Int64)` or `LowCardinality(String) DEFAULT 'x'`
+		mappedType, _, _ := strings.Cut(mapped.ClickHouseType, " ")
+		if strings.HasPrefix(mappedType, "Nullable(") {
+			mappedType = strings.TrimSuffix(strings.TrimPrefix(mappedType, "Nullable("), ")")
+		}
+		if !slices.Contains(columnTypeWidenings[baseType.Name], mappedType) {
+			continue
+		}
+		if newColumns == nil {
+			newColumns = maps.Clone(table.Cols)
+			widened = make(map[string]chLib.BaseType)
+		}
+		widenedColumn := *column
+		widenedColumn.Type = widenedType(mappedType, baseType.Nullable)
+		newColumns[column.Name] = &widenedColumn
+		widened[column.Name] = widenedColumn.Type.(chLib.BaseType)
+	}
+	if newColumns != nil {
+		table.Cols = newColumns
+	}
+	return widened
+}
+
+// widerColumnType returns the narrowest of safe widenings of the column type, which accepts the value.
+func widerColumnType(columnName string, columnType chLib.Type, value any) (chLib.BaseType, bool) {
+	baseType, ok := columnType.(chLib.BaseType)
+	if !ok {
+		return chLib.BaseType{}, false
+	}
+	for _, wider := range columnTypeWidenings[baseType.Name] {
+		widerType := widenedType(wider, baseType.Nullable)
+		if validateValueAgainstType(columnName, value, widerType) {
+			return widerType, true
+		}
+	}
+	return chLib.BaseType{}, false
+}
+
+// widenColumnTypes changes types of columns, which are too narrow for values of the document, to wider ones.
+// These values are moved from invalidJson back to the document. It returns new types of the columns, and
+// like generateNewColumns, it replaces table.Cols.
+func widenColumnTypes(table *chLib.Table, document, invalidJson types.JSON) map[string]chLib.BaseType {
+	if len(invalidJson) == 0 {
+		return nil
+	}
+	var widened map[string]chLib.BaseType
+	var newColumns map[string]*chLib.Column
+	for columnName, value := range invalidJson {
+		column, ok := table.Cols[columnName]
+		if !ok || column == nil {
+			continue
+		}
+		// the column may have been widened by a previous document already
+		if !validateValueAgainstType(columnName, value, column.Type) {
+			widerType, ok := widerColumnType(columnName, column.Type, value)
+			if !ok {
+				continue
+			}
+			if newColumns == nil {
+				newColumns = make(map[string]*chLib.Column, len(table.Cols))
+				for k, v := range table.Cols {
+					newColumns[k] = v
+				}
+				widened = make(map[string]chLib.BaseType)
+			}
+			widenedColumn := *column
+			widenedColumn.Type = widerType
+			newColumns[columnName] = &widenedColumn
+			widened[columnName] = widerType
+		}
+		document[columnName] = value
+		delete(invalidJson, columnName)
+	}
+	if newColumns != nil {
+		table.Cols = newColumns
+	}
+	return widened
+}
+
+func modifyColumnStatements(table *chLib.Table, widened map[string]chLib.BaseType) []AlterStatement {
+	columnNames := make([]string, 0, len(widened))
+	for columnName := range widened {
+		columnNames = append(columnNames, columnName)
+	}
+	sort.Strings(columnNames)
+
+	var alterStatements []AlterStatement
+	for _, columnName := range columnNames {
+		alterStatements = append(alterStatements, AlterStatement{
+			Type:       ModifyColumn,
+			TableName:  table.Name,
+			OnCluster:  table.ClusterName,
+			ColumnName: columnName,
+			ColumnType: widened[columnName].StringWithNullable(),
+		})
+	}
+	return alterStatements
+}
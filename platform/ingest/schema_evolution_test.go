@@ -0,0 +1,134 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package ingest
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/QuesmaOrg/quesma/platform/config"
+	"github.com/QuesmaOrg/quesma/platform/database_common"
+	"github.com/QuesmaOrg/quesma/platform/schema"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSchemaEvolutionStrict(t *testing.T) {
+	ip, mock, _ := newIngestProcessorWithSqlMock(t, config.IndexConfiguration{Dynamic: config.DynamicMappingStrict}, nil)
+
+	// only documents with unknown fields are rejected, like in Elasticsearch
+	mock.ExpectExec(`INSERT INTO "test_table" FORMAT JSONEachRow {"int_field":15}, {"int_field":17}`).WillReturnResult(sqlmock.NewResult(0, 0))
+	err := ip.ProcessInsertQuery(context.Background(), tableName,
+		[]types.JSON{types.MustJSON(`{"int_field":15}`), types.MustJSON(`{"int_field":16,"new_field":"x"}`), types.MustJSON(`{"int_field":17}`)},
+		&IngestTransformerTest{}, &columNameFormatter{separator: "::"})
+	var rejectedErr *RejectedDocumentsError
+	require.ErrorAs(t, err, &rejectedErr)
+	require.Len(t, rejectedErr.Errors, 1)
+	var strictErr *StrictDynamicMappingError
+	require.ErrorAs(t, rejectedErr.Errors[1], &strictErr)
+	assert.Equal(t, []string{"new_field"}, strictErr.Fields)
+	assert.Equal(t, "mapping set to strict, dynamic introduction of [new_field] within [_doc] is not allowed", strictErr.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// nothing is inserted, when all documents are rejected
+	err = ip.ProcessInsertQuery(context.Background(), tableName,
+		[]types.JSON{types.MustJSON(`{"new_field":"x"}`)}, &IngestTransformerTest{}, &columNameFormatter{separator: "::"})
+	require.ErrorAs(t, err, &strictErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// known fields are fine, also if their values are stored in attributes
+	mock.ExpectExec(`INSERT INTO "test_table" FORMAT JSONEachRow {"attributes_metadata":{"int_field":"v1;String"},"attributes_values":{"int_field":"abc"}}`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, ip.ProcessInsertQuery(context.Background(), tableName,
+		[]types.JSON{types.MustJSON(`{"int_field":"abc"}`)}, &IngestTransformerTest{}, &columNameFormatter{separator: "::"}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSchemaEvolutionDynamicFalse(t *testing.T) {
+	ip, mock, table := newIngestProcessorWithSqlMock(t, config.IndexConfiguration{Dynamic: config.DynamicMappingFalse}, nil)
+
+	mock.ExpectExec(`INSERT INTO "test_table" FORMAT JSONEachRow {"attributes_metadata":{"new_field":"v1;String"},"attributes_values":{"new_field":"x"},"int_field":16}`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, ip.ProcessInsertQuery(context.Background(), tableName,
+		[]types.JSON{types.MustJSON(`{"int_field":16,"new_field":"x"}`)}, &IngestTransformerTest{}, &columNameFormatter{separator: "::"}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NotContains(t, table.Cols, "new_field")
+}
+
+func TestSchemaEvolutionWidenColumnTypes(t *testing.T) {
+	ip, mock, table := newIngestProcessorWithSqlMock(t, config.IndexConfiguration{Dynamic: config.DynamicMappingFalse, WidenColumnTypes: true}, nil)
+
+	mock.ExpectExec(`ALTER TABLE "test_table" MODIFY COLUMN IF EXISTS "int_field" Nullable(Int64)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "test_table" FORMAT JSONEachRow {"int_field":3000000000}, {"int_field":16}, {"attributes_metadata":{"int_field":"v1;String"},"attributes_values":{"int_field":"abc"}}`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, ip.ProcessInsertQuery(context.Background(), tableName,
+		[]types.JSON{types.MustJSON(`{"int_field":3000000000}`), types.MustJSON(`{"int_field":16}`), types.MustJSON(`{"int_field":"abc"}`)},
+		&IngestTransformerTest{}, &columNameFormatter{separator: "::"}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, "Nullable(Int64)", table.Cols["int_field"].Type.StringWithNullable())
+
+	// a fractional value doesn't fit Int64, so the column becomes Float64
+	mock.ExpectExec(`ALTER TABLE "test_table" MODIFY COLUMN IF EXISTS "int_field" Nullable(Float64)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "test_table" FORMAT JSONEachRow {"int_field":1.5}`).WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, ip.ProcessInsertQuery(context.Background(), tableName,
+		[]types.JSON{types.MustJSON(`{"int_field":1.5}`)}, &IngestTransformerTest{}, &columNameFormatter{separator: "::"}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWiderColumnType(t *testing.T) {
+	tests := []struct {
+		columnType string
+		value      any
+		expected   string
+	}{
+		{"Int32", float64(1 << 40), "Int64"},
+		{"UInt8", float64(-1), "Int64"},
+		{"Int16", 0.5, "Float64"},
+		{"Int64", 0.5, "Float64"},
+		{"Int32", "abc", ""},
+		{"String", float64(1), ""},
+		{"DateTime64", float64(1), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.columnType, func(t *testing.T) {
+			widened, ok := widerColumnType("field", database_common.NewBaseType(tt.columnType), tt.value)
+			assert.Equal(t, tt.expected != "", ok)
+			assert.Equal(t, tt.expected, widened.Name)
+		})
+	}
+}
+
+func TestWidenMappedColumnTypes(t *testing.T) {
+	table := &database_common.Table{
+		Name: tableName,
+		Cols: map[string]*database_common.Column{
+			"int_field": {Name: "int_field", Type: database_common.NewBaseType("Int32")},
+			"tag":       {Name: "tag", Type: database_common.BaseType{Name: "String", Nullable: true}},
+			"message":   {Name: "message", Type: database_common.BaseType{Name: "String", Nullable: true}},
+			"other":     {Name: "other", Type: database_common.NewBaseType("String")},
+		},
+	}
+	policy := SchemaEvolution{WidenColumnTypes: true, MappedColumns: map[schema.FieldName]CreateTableEntry{
+		"int_field": {ClickHouseColumnName: "int_field", ClickHouseType: "Nullable(Int64)"},
+		"tag":       {ClickHouseColumnName: "tag", ClickHouseType: "LowCardinality(String) DEFAULT 'prod'"},
+		"message":   {ClickHouseColumnName: "message", ClickHouseType: "Nullable(String)"},
+	}}
+
+	assert.Empty(t, SchemaEvolution{MappedColumns: policy.MappedColumns}.widenColumns(table, types.JSON{}, types.JSON{}))
+
+	var statements []string
+	for _, alter := range policy.widenColumns(table, types.JSON{}, types.JSON{}) {
+		statements = append(statements, alter.ToSql())
+	}
+	assert.Equal(t, []string{
+		`ALTER TABLE "test_table" MODIFY COLUMN IF EXISTS "int_field" Int64`,
+		`ALTER TABLE "test_table" MODIFY COLUMN IF EXISTS "tag" LowCardinality(Nullable(String))`,
+	}, statements)
+	assert.Equal(t, "LowCardinality(Nullable(String))", table.Cols["tag"].Type.StringWithNullable())
+	assert.Equal(t, "Nullable(String)", table.Cols["message"].Type.StringWithNullable())
+
+	// strings still fit the column, and it isn't changed again
+	assert.True(t, validateValueAgainstType("tag", "abc", table.Cols["tag"].Type))
+	assert.Empty(t, policy.widenColumns(table, types.JSON{}, types.JSON{}))
+}
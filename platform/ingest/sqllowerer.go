@@ -10,6 +10,7 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/persistence"
 	"github.com/QuesmaOrg/quesma/platform/schema"
 	"github.com/QuesmaOrg/quesma/platform/types"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -55,7 +56,7 @@ func (ip *SqlLowerer) generateNewColumns(
 		newColumns[k] = v
 	}
 
-	for i := range alteredAttributesIndexes {
+	for _, i := range alteredAttributesIndexes {
 
 		columnType := ""
 		modifiers := ""
@@ -113,6 +114,7 @@ func (ip *SqlLowerer) generateNewColumns(
 		}
 	}
 
+	sort.Ints(deleteIndexes) // removed from the end, so that indexes of the remaining ones don't change
 	for i := len(deleteIndexes) - 1; i >= 0; i-- {
 		attrsMap[chLib.DeprecatedAttributesKeyColumn] = append(attrsMap[chLib.DeprecatedAttributesKeyColumn][:deleteIndexes[i]], attrsMap[chLib.DeprecatedAttributesKeyColumn][deleteIndexes[i]+1:]...)
 		attrsMap[chLib.DeprecatedAttributesValueType] = append(attrsMap[chLib.DeprecatedAttributesValueType][:deleteIndexes[i]], attrsMap[chLib.DeprecatedAttributesValueType][deleteIndexes[i]+1:]...)
@@ -124,16 +126,20 @@ func (ip *SqlLowerer) generateNewColumns(
 func (ip *SqlLowerer) GenerateIngestContent(table *chLib.Table,
 	data types.JSON,
 	inValidJson types.JSON,
-	encodings map[schema.FieldEncodingKey]schema.EncodedFieldName) ([]AlterStatement, types.JSON, []NonSchemaField, error) {
+	encodings map[schema.FieldEncodingKey]schema.EncodedFieldName,
+	policy SchemaEvolution) ([]AlterStatement, types.JSON, []NonSchemaField, error) {
 
-	if len(table.Config.Attributes) == 0 {
-		return nil, data, nil, nil
-	}
+	alterStatements := policy.widenColumns(table, data, inValidJson)
 
+	// documents breaking `dynamic: strict` have been rejected before lowering, see rejectStrictDynamicMapping
 	mDiff := DifferenceMap(data, table) // TODO change to DifferenceMap(m, t)
 
+	if len(table.Config.Attributes) == 0 {
+		return alterStatements, data, nil, nil
+	}
+
 	if len(mDiff) == 0 && len(inValidJson) == 0 { // no need to modify, just insert 'js'
-		return nil, data, nil, nil
+		return alterStatements, data, nil, nil
 	}
 
 	// check attributes precondition
@@ -147,10 +153,11 @@ func (ip *SqlLowerer) GenerateIngestContent(table *chLib.Table,
 	// otherwise it would contain invalid fields e.g. with wrong types
 	// we only want to add fields that are not part of the schema e.g we don't
 	// have columns for them
-	var alterStatements []AlterStatement
 	atomic.AddInt64(&ip.ingestCounter, 1)
-	if ok, alteredAttributesIndexes := ip.shouldAlterColumns(table, attrsMap); ok {
-		alterStatements = ip.generateNewColumns(attrsMap, table, alteredAttributesIndexes, encodings)
+	if ok, alteredAttributesIndexes := ip.shouldAlterColumns(table, attrsMap, policy); ok {
+		mappedColumns := policy.applyMappedColumnTypes(attrsMap, alteredAttributesIndexes, encodings, table.Name)
+		alterStatements = append(alterStatements, ip.generateNewColumns(attrsMap, table, alteredAttributesIndexes, encodings)...)
+		inValidJson = moveValuesNotMatchingColumns(table, data, inValidJson, mappedColumns)
 	}
 	// If there are some invalid fields, we need to add them to the attributes map
 	// to not lose them and be able to store them later by
@@ -173,16 +180,17 @@ func (l *SqlLowerer) LowerToDDL(validatedJsons []types.JSON,
 	table *chLib.Table,
 	invalidJsons []types.JSON,
 	encodings map[schema.FieldEncodingKey]schema.EncodedFieldName,
-	createTableCmd CreateTableStatement) ([]string, error) {
+	createTableCmd CreateTableStatement,
+	policy SchemaEvolution) ([]string, error) {
 	var jsonsReadyForInsertion []string
 	var alterStatements []AlterStatement
 
 	for i, preprocessedJson := range validatedJsons {
 		alter, onlySchemaFields, nonSchemaFields, err := l.GenerateIngestContent(table, preprocessedJson,
-			invalidJsons[i], encodings)
+			invalidJsons[i], encodings, policy)
 
 		if err != nil {
-			return nil, fmt.Errorf("error BuildInsertJson, tablename: '%s' : %v", table.Name, err)
+			return nil, fmt.Errorf("error BuildInsertJson, tablename: '%s' : %w", table.Name, err)
 		}
 		insertJson, err := generateInsertJson(nonSchemaFields, onlySchemaFields)
 		if err != nil {
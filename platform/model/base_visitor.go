@@ -183,7 +183,15 @@ func (v *BaseExprVisitor) VisitSelectCommand(query SelectCommand) interface{} {
 		}
 	}
 
-	return NewSelectCommand(columns, groupBy, orderBy, from, where, limitBy, query.Limit, query.SampleLimit, query.IsDistinct, namedCTEs)
+	result := NewSelectCommand(columns, groupBy, orderBy, from, where, limitBy, query.Limit, query.SampleLimit, query.IsDistinct, namedCTEs)
+	if query.SampleLimitBy != nil {
+		result.SampleLimitBy = query.SampleLimitBy.Accept(v).(Expr)
+		result.SampleLimitPerValue = query.SampleLimitPerValue
+	}
+	if query.ArrayJoin != nil {
+		result.ArrayJoin = v.VisitChildren(query.ArrayJoin)
+	}
+	return result
 }
 
 func (v *BaseExprVisitor) VisitParenExpr(p ParenExpr) interface{} {
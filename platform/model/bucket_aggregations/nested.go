@@ -0,0 +1,48 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package bucket_aggregations
+
+import (
+	"context"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/model"
+)
+
+// Nested aggregates objects of an array of objects (path), instead of whole documents.
+// We store such arrays as parallel arrays of objects' fields (e.g. events[].type -> events_type Array(String)),
+// so we unnest all of them together with ARRAY JOIN, and then i-th elements of each array form the i-th object.
+type Nested struct {
+	ctx              context.Context
+	path             string
+	arrayJoinColumns []model.Expr
+}
+
+func NewNested(ctx context.Context, path string, arrayJoinColumns []model.Expr) Nested {
+	return Nested{ctx: ctx, path: path, arrayJoinColumns: arrayJoinColumns}
+}
+
+func (query Nested) AggregationType() model.AggregationType {
+	return model.BucketAggregation
+}
+
+func (query Nested) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.JsonMap {
+	if len(rows) == 0 {
+		logger.WarnWithCtx(query.ctx).Msg("no rows returned for nested aggregation")
+		return model.JsonMap{"doc_count": 0}
+	}
+	return model.JsonMap{"doc_count": rows[0].LastColValue()}
+}
+
+func (query Nested) String() string {
+	return fmt.Sprintf("nested(path: %s)", query.path)
+}
+
+func (query Nested) DoesNotHaveGroupBy() bool {
+	return true
+}
+
+// GetArrayJoinColumns returns array columns, which need to be unnested with ARRAY JOIN
+func (query Nested) GetArrayJoinColumns() []model.Expr {
+	return query.arrayJoinColumns
+}
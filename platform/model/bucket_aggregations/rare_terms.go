@@ -0,0 +1,74 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package bucket_aggregations
+
+import (
+	"context"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/util"
+)
+
+// RareTerms returns terms with at most 'max_doc_count' documents, least frequent first (then by key, like Elastic).
+// Elastic uses an approximate (CuckooFilter) algorithm, and we're exact, as we simply filter groups with
+// count() <= max_doc_count in SQL (see pancake's generateBucketSqlParts).
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/search-aggregations-bucket-rare-terms-aggregation.html
+type RareTerms struct {
+	ctx         context.Context
+	maxDocCount int
+	exclude     any // same as in Terms
+}
+
+// RareTermsMaxDocCountLimit is the maximum 'max_doc_count' Elastic allows
+const RareTermsMaxDocCountLimit = 100
+
+func NewRareTerms(ctx context.Context, maxDocCount int, exclude any) RareTerms {
+	return RareTerms{ctx: ctx, maxDocCount: maxDocCount, exclude: exclude}
+}
+
+func (query RareTerms) AggregationType() model.AggregationType {
+	return model.BucketAggregation
+}
+
+func (query RareTerms) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.JsonMap {
+	buckets := make([]model.JsonMap, 0, len(rows))
+	for _, row := range rows {
+		if len(row.Cols) < 2 {
+			logger.ErrorWithCtx(query.ctx).Msgf(
+				"unexpected number of columns in rare_terms aggregation response, len: %d, row: %v", len(row.Cols), row)
+			return model.JsonMap{"buckets": []model.JsonMap{}}
+		}
+		bucket := model.JsonMap{
+			"doc_count": row.Cols[len(row.Cols)-1].Value,
+		}
+
+		// response for bool keys is different
+		key := row.Cols[len(row.Cols)-2].Value
+		if boolPtr, isBoolPtr := key.(*bool); isBoolPtr {
+			key = *boolPtr
+		}
+		if keyAsBool, ok := key.(bool); ok {
+			bucket["key"] = util.BoolToInt(keyAsBool)
+			bucket["key_as_string"] = util.BoolToString(keyAsBool)
+		} else {
+			bucket["key"] = key
+		}
+
+		buckets = append(buckets, bucket)
+	}
+	return model.JsonMap{"buckets": buckets}
+}
+
+func (query RareTerms) String() string {
+	return fmt.Sprintf("rare_terms(max_doc_count: %d)", query.maxDocCount)
+}
+
+// MaxDocCountCondition returns condition which rare terms' count needs to satisfy
+func (query RareTerms) MaxDocCountCondition(count model.Expr) model.Expr {
+	return model.NewInfixExpr(count, "<=", model.NewLiteral(query.maxDocCount))
+}
+
+func (query RareTerms) UpdateFieldForIncludeAndExclude(field model.Expr) (updatedField model.Expr, didWeUpdateField bool) {
+	return updateFieldForIncludeAndExclude(field, query.exclude)
+}
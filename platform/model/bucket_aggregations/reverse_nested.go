@@ -0,0 +1,45 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package bucket_aggregations
+
+import (
+	"context"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"strconv"
+)
+
+// ReverseNested goes back from nested objects (see Nested) to their parent documents.
+// Its doc_count is the number of distinct parent documents, not of nested objects.
+type ReverseNested struct {
+	ctx context.Context
+}
+
+func NewReverseNested(ctx context.Context) ReverseNested {
+	return ReverseNested{ctx: ctx}
+}
+
+func (query ReverseNested) AggregationType() model.AggregationType {
+	return model.BucketAggregation
+}
+
+func (query ReverseNested) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.JsonMap {
+	if len(rows) == 0 {
+		logger.WarnWithCtx(query.ctx).Msg("no rows returned for reverse_nested aggregation")
+		return model.JsonMap{"doc_count": 0}
+	}
+	return model.JsonMap{"doc_count": rows[0].LastColValue()}
+}
+
+func (query ReverseNested) String() string {
+	return "reverse_nested"
+}
+
+func (query ReverseNested) DoesNotHaveGroupBy() bool {
+	return true
+}
+
+// DocCountExpr counts parent documents. After ARRAY JOIN each of them is repeated once per its nested object.
+func (query ReverseNested) DocCountExpr() model.Expr {
+	return model.NewFunction("uniqExact", model.LiteralExpr{Value: strconv.Quote(model.ArrayJoinParentRowColumnName)})
+}
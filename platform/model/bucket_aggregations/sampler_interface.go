@@ -2,6 +2,15 @@
 // SPDX-License-Identifier: Elastic-2.0
 package bucket_aggregations
 
+import "github.com/QuesmaOrg/quesma/platform/model"
+
 type SamplerInterface interface {
 	GetSampleLimit() int
 }
+
+// DiversifiedSamplerInterface is a sampler, which additionally limits the number of sampled documents sharing the same value
+type DiversifiedSamplerInterface interface {
+	SamplerInterface
+	GetSampleLimitBy() model.Expr
+	GetMaxDocsPerValue() int
+}
@@ -0,0 +1,175 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package bucket_aggregations
+
+import (
+	"fmt"
+	"math"
+)
+
+// SignificanceHeuristic scores a term of significant_terms/significant_text aggregation, based on its
+// frequency in the foreground set (subset) and in the background set (superset).
+// All heuristics follow Elastic's implementation, so scores should be the same as Elastic's.
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/search-aggregations-bucket-significantterms-aggregation.html#_parameters
+type SignificanceHeuristic struct {
+	name SignificanceHeuristicName
+	// includeNegatives and backgroundIsSuperset are only used by mutual_information, chi_square and gnd (the latter only uses backgroundIsSuperset)
+	includeNegatives     bool
+	backgroundIsSuperset bool
+}
+
+type SignificanceHeuristicName string
+
+const (
+	JLH               SignificanceHeuristicName = "jlh"
+	MutualInformation SignificanceHeuristicName = "mutual_information"
+	ChiSquare         SignificanceHeuristicName = "chi_square"
+	GND               SignificanceHeuristicName = "gnd"
+	Percentage        SignificanceHeuristicName = "percentage"
+)
+
+const DefaultSignificanceHeuristic = JLH
+
+func NewSignificanceHeuristic(name SignificanceHeuristicName, includeNegatives, backgroundIsSuperset bool) (SignificanceHeuristic, error) {
+	switch name {
+	case JLH, MutualInformation, ChiSquare, GND, Percentage:
+		return SignificanceHeuristic{name: name, includeNegatives: includeNegatives, backgroundIsSuperset: backgroundIsSuperset}, nil
+	default:
+		return SignificanceHeuristic{}, fmt.Errorf("unsupported significance heuristic: %s", name)
+	}
+}
+
+func (h SignificanceHeuristic) String() string {
+	return string(h.name)
+}
+
+// Score returns the score of a term. Terms with score <= 0 aren't significant (Elastic doesn't return them).
+//   - subsetFreq: number of documents with the term in the foreground set
+//   - subsetSize: number of documents in the foreground set
+//   - supersetFreq: number of documents with the term in the background set
+//   - supersetSize: number of documents in the background set
+func (h SignificanceHeuristic) Score(subsetFreq, subsetSize, supersetFreq, supersetSize int64) float64 {
+	switch h.name {
+	case JLH:
+		return h.jlh(subsetFreq, subsetSize, supersetFreq, supersetSize)
+	case MutualInformation:
+		return h.mutualInformation(subsetFreq, subsetSize, supersetFreq, supersetSize)
+	case ChiSquare:
+		return h.chiSquare(subsetFreq, subsetSize, supersetFreq, supersetSize)
+	case GND:
+		return h.gnd(subsetFreq, subsetSize, supersetFreq, supersetSize)
+	default: // percentage
+		if supersetFreq == 0 {
+			return 0
+		}
+		return float64(subsetFreq) / float64(supersetFreq)
+	}
+}
+
+func (h SignificanceHeuristic) jlh(subsetFreq, subsetSize, supersetFreq, supersetSize int64) float64 {
+	if subsetSize == 0 || supersetSize == 0 {
+		return 0
+	}
+	if supersetFreq == 0 {
+		// avoid a division by zero. Can happen e.g. if background_filter excludes all documents with the term.
+		supersetFreq = 1
+	}
+	subsetProbability := float64(subsetFreq) / float64(subsetSize)
+	supersetProbability := float64(supersetFreq) / float64(supersetSize)
+	absoluteProbabilityChange := subsetProbability - supersetProbability
+	if absoluteProbabilityChange <= 0 {
+		return 0
+	}
+	relativeProbabilityChange := subsetProbability / supersetProbability
+	return absoluteProbabilityChange * relativeProbabilityChange
+}
+
+// frequencies is a contingency table of documents: N<term><class>, e.g.
+// n10 - number of documents with the term (1), not in the foreground set (0).
+// '_' means 'any', e.g. n1_ - number of documents with the term.
+type frequencies struct {
+	n00, n01, n10, n11, n0_, n1_, n_0, n_1, n float64
+}
+
+func (h SignificanceHeuristic) computeFrequencies(subsetFreq, subsetSize, supersetFreq, supersetSize int64) frequencies {
+	if h.backgroundIsSuperset {
+		return frequencies{
+			n00: float64(supersetSize - supersetFreq - (subsetSize - subsetFreq)),
+			n01: float64(subsetSize - subsetFreq),
+			n10: float64(supersetFreq - subsetFreq),
+			n11: float64(subsetFreq),
+			n0_: float64(supersetSize - supersetFreq),
+			n1_: float64(supersetFreq),
+			n_0: float64(supersetSize - subsetSize),
+			n_1: float64(subsetSize),
+			n:   float64(supersetSize),
+		}
+	}
+	return frequencies{
+		n00: float64(supersetSize - supersetFreq),
+		n01: float64(subsetSize - subsetFreq),
+		n10: float64(supersetFreq),
+		n11: float64(subsetFreq),
+		n0_: float64(supersetSize - supersetFreq + subsetSize - subsetFreq),
+		n1_: float64(supersetFreq + subsetFreq),
+		n_0: float64(supersetSize),
+		n_1: float64(subsetSize),
+		n:   float64(supersetSize + subsetSize),
+	}
+}
+
+// isUnderRepresented returns true if the term is less frequent in the foreground set than in the rest of the background.
+func (f frequencies) isUnderRepresented() bool {
+	return f.n11/f.n_1 < f.n10/f.n_0
+}
+
+func (h SignificanceHeuristic) mutualInformation(subsetFreq, subsetSize, supersetFreq, supersetSize int64) float64 {
+	f := h.computeFrequencies(subsetFreq, subsetSize, supersetFreq, supersetSize)
+	miTerm := func(nxy, nx_, n_y, n float64) float64 {
+		numerator := math.Abs(n * nxy)
+		denominator := math.Abs(nx_ * n_y)
+		factor := math.Abs(nxy / n)
+		if numerator < 1e-7 && factor < 1e-7 {
+			return 0
+		}
+		return factor * math.Log(numerator/denominator)
+	}
+
+	score := (miTerm(f.n00, f.n0_, f.n_0, f.n) +
+		miTerm(f.n01, f.n0_, f.n_1, f.n) +
+		miTerm(f.n10, f.n1_, f.n_0, f.n) +
+		miTerm(f.n11, f.n1_, f.n_1, f.n)) / math.Log(2)
+	if math.IsNaN(score) {
+		return math.Inf(-1)
+	}
+	if !h.includeNegatives && f.isUnderRepresented() {
+		return math.Inf(-1)
+	}
+	return score
+}
+
+func (h SignificanceHeuristic) chiSquare(subsetFreq, subsetSize, supersetFreq, supersetSize int64) float64 {
+	f := h.computeFrequencies(subsetFreq, subsetSize, supersetFreq, supersetSize)
+	if !h.includeNegatives && f.isUnderRepresented() {
+		return math.Inf(-1)
+	}
+	return f.n * math.Pow(f.n11*f.n00-f.n01*f.n10, 2) / (f.n_1 * f.n1_ * f.n0_ * f.n_0)
+}
+
+// gnd is Google Normalized Distance, as described in "The Google Similarity Distance", Cilibrasi and Vitanyi, 2007
+func (h SignificanceHeuristic) gnd(subsetFreq, subsetSize, supersetFreq, supersetSize int64) float64 {
+	fx, fy, fxy, n := float64(supersetFreq), float64(subsetSize), float64(subsetFreq), float64(supersetSize)
+	if !h.backgroundIsSuperset {
+		fx += float64(subsetFreq)
+		n += float64(subsetSize)
+	}
+	if fxy == 0 { // no co-occurrence
+		return 0
+	}
+	if fx == fy && fx == fxy { // perfect co-occurrence
+		return 1
+	}
+	score := (math.Max(math.Log(fx), math.Log(fy)) - math.Log(fxy)) / (math.Log(n) - math.Min(math.Log(fx), math.Log(fy)))
+	// GND scores relevant terms low, so we need to invert the order
+	return math.Exp(-score)
+}
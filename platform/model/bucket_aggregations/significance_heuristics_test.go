@@ -0,0 +1,70 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package bucket_aggregations
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+// Expected scores mostly come from Elastic's own tests of significance heuristics.
+func TestSignificanceHeuristicScore(t *testing.T) {
+	type freqs struct {
+		subsetFreq, subsetSize, supersetFreq, supersetSize int64
+	}
+	testcases := []struct {
+		heuristic            SignificanceHeuristicName
+		includeNegatives     bool
+		backgroundIsSuperset bool
+		freqs                freqs
+		expectedScore        float64
+	}{
+		{JLH, false, true, freqs{500, 825, 1200, 10000}, 2.4548515457606372},
+		{JLH, false, true, freqs{100, 825, 8000, 10000}, 0},
+		{JLH, false, true, freqs{1, 1, 0, 10}, 9}, // term missing in the background set
+		{JLH, false, true, freqs{1, 0, 1, 10}, 0},
+		{MutualInformation, true, true, freqs{2, 2, 2, 4}, 1},
+		{MutualInformation, true, true, freqs{0, 2, 2, 4}, 1},
+		{MutualInformation, true, true, freqs{2, 2, 4, 4}, 0},
+		{MutualInformation, true, true, freqs{1, 2, 2, 4}, 0},
+		{MutualInformation, true, true, freqs{3, 6, 9, 18}, 0},
+		{MutualInformation, false, true, freqs{0, 1, 2, 3}, math.Inf(-1)},
+		{ChiSquare, true, true, freqs{2, 2, 2, 4}, 4},
+		{ChiSquare, false, true, freqs{0, 1, 2, 3}, math.Inf(-1)},
+		{ChiSquare, false, true, freqs{3, 6, 9, 18}, 0},
+		{GND, false, true, freqs{0, 2, 0, 3}, 0},
+		{GND, false, true, freqs{0, 1, 2, 5}, 0},
+		{GND, false, true, freqs{0, 0, 0, 1}, 0},
+		{GND, false, true, freqs{1, 1, 1, 1}, 1},
+		{GND, false, false, freqs{0, 0, 0, 0}, 0},
+		{Percentage, false, true, freqs{3, 10, 4, 100}, 0.75},
+		{Percentage, false, true, freqs{3, 10, 0, 100}, 0},
+	}
+	for i, tc := range testcases {
+		t.Run(fmt.Sprintf("%s(%v)_%d", tc.heuristic, tc.freqs, i), func(t *testing.T) {
+			heuristic, err := NewSignificanceHeuristic(tc.heuristic, tc.includeNegatives, tc.backgroundIsSuperset)
+			assert.NoError(t, err)
+			score := heuristic.Score(tc.freqs.subsetFreq, tc.freqs.subsetSize, tc.freqs.supersetFreq, tc.freqs.supersetSize)
+			if math.IsInf(tc.expectedScore, 0) {
+				assert.Equal(t, tc.expectedScore, score)
+			} else {
+				assert.InDelta(t, tc.expectedScore, score, 1e-9)
+			}
+		})
+	}
+}
+
+func TestSignificanceHeuristicOrdering(t *testing.T) {
+	mutualInformation, _ := NewSignificanceHeuristic(MutualInformation, true, true)
+	assert.Greater(t, mutualInformation.Score(1, 1, 1, 3), 0.0)
+	assert.Less(t, mutualInformation.Score(1, 1, 2, 3), mutualInformation.Score(1, 1, 1, 3))
+
+	jlh, _ := NewSignificanceHeuristic(JLH, false, true)
+	// the same relative frequency in the foreground set, but the second term is much rarer in the background set
+	assert.Less(t, jlh.Score(10, 100, 100, 1000), jlh.Score(10, 100, 20, 1000))
+
+	_, err := NewSignificanceHeuristic("script_heuristic", false, true)
+	assert.Error(t, err)
+}
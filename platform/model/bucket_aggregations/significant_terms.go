@@ -0,0 +1,212 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package bucket_aggregations
+
+import (
+	"context"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"sort"
+)
+
+// SignificantTerms is both significant_terms and significant_text aggregation.
+// They return terms, which are much more frequent in the foreground set (documents matching the query, in the parent bucket)
+// than in the background set (whole index, or documents matching 'background_filter').
+//
+// Foreground and background frequencies of each term are computed in Clickhouse, and scored here with SignificanceHeuristic.
+// Each SQL row has (in this order): background set size, background frequency of the term,
+// foreground set size, the term, and foreground frequency of the term (see pancake's generateBucketSqlParts).
+//
+// significant_text splits text into lowercase alphanumeric tokens, and a term is then a single token.
+//
+// Limitation: Elastic scores all terms, and we only score 'shard_size' most frequent terms in the foreground set.
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/search-aggregations-bucket-significantterms-aggregation.html
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/search-aggregations-bucket-significanttext-aggregation.html
+type SignificantTerms struct {
+	ctx         context.Context
+	isText      bool // true <=> significant_text, false <=> significant_terms
+	size        int
+	minDocCount int
+	heuristic   SignificanceHeuristic
+	// backgroundFilter restricts the background set. Nil if missing in request (background set is the whole index then).
+	backgroundFilter model.Expr
+	include          any // same as in Terms
+	exclude          any // same as in Terms
+}
+
+func NewSignificantTerms(ctx context.Context, isText bool, size, minDocCount int, heuristic SignificanceHeuristic,
+	backgroundFilter model.Expr, include, exclude any) SignificantTerms {
+	return SignificantTerms{ctx: ctx, isText: isText, size: size, minDocCount: minDocCount, heuristic: heuristic,
+		backgroundFilter: backgroundFilter, include: include, exclude: exclude}
+}
+
+func (query SignificantTerms) AggregationType() model.AggregationType {
+	return model.BucketAggregation
+}
+
+// TranslateSqlResponseToJson expects rows already filtered and sorted by MostSignificantRows.
+func (query SignificantTerms) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.JsonMap {
+	if len(rows) > 0 && len(rows[0].Cols) < 5 {
+		logger.ErrorWithCtx(query.ctx).Msgf(
+			"unexpected number of columns in %s aggregation response, len: %d, rows[0]: %v", query.String(), len(rows[0].Cols), rows[0])
+		return model.JsonMap{"buckets": []model.JsonMap{}}
+	}
+	if len(rows) == 0 {
+		return model.JsonMap{"buckets": []model.JsonMap{}}
+	}
+
+	buckets := make([]model.JsonMap, 0, len(rows))
+	for _, row := range rows {
+		bucket := model.JsonMap{
+			"doc_count": query.docCount(row),
+			"bg_count":  query.bgCount(row),
+			"score":     query.score(row),
+		}
+
+		// response for bool keys is different
+		key := query.key(row)
+		if boolPtr, isBoolPtr := key.(*bool); isBoolPtr {
+			key = *boolPtr
+		}
+		if keyAsBool, ok := key.(bool); ok {
+			bucket["key"] = util.BoolToInt(keyAsBool)
+			bucket["key_as_string"] = util.BoolToString(keyAsBool)
+		} else {
+			bucket["key"] = key
+		}
+
+		buckets = append(buckets, bucket)
+	}
+
+	return model.JsonMap{
+		"buckets":   buckets,
+		"doc_count": query.subsetSize(rows[0]),
+		"bg_count":  query.supersetSize(rows[0]),
+	}
+}
+
+func (query SignificantTerms) String() string {
+	if query.isText {
+		return fmt.Sprintf("significant_text(size: %d, heuristic: %s)", query.size, query.heuristic)
+	}
+	return fmt.Sprintf("significant_terms(size: %d, heuristic: %s)", query.size, query.heuristic)
+}
+
+func (query SignificantTerms) IsText() bool {
+	return query.isText
+}
+
+// MostSignificantRows returns indexes of rows (buckets) which should be returned, most significant first.
+// Like Elastic, we only return terms with positive score and with at least 'min_doc_count' documents in the foreground set.
+func (query SignificantTerms) MostSignificantRows(rows []model.QueryResultRow) []int {
+	indexes := make([]int, 0, len(rows))
+	scores := make([]float64, len(rows))
+	for i, row := range rows {
+		if len(row.Cols) < 5 {
+			logger.ErrorWithCtx(query.ctx).Msgf("unexpected number of columns in %s aggregation response, row: %v", query.String(), row)
+			return []int{}
+		}
+		scores[i] = query.score(row)
+		if scores[i] > 0 && query.docCount(row) >= int64(query.minDocCount) {
+			indexes = append(indexes, i)
+		}
+	}
+
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return scores[indexes[i]] > scores[indexes[j]]
+	})
+	if len(indexes) > query.size {
+		indexes = indexes[:query.size]
+	}
+	return indexes
+}
+
+// KeyExpr returns SQL expression for the term, given (already processed for missing/include/exclude) field.
+func (query SignificantTerms) KeyExpr(field model.Expr) model.Expr {
+	if !query.isText {
+		return field
+	}
+	// Tokens of a document are distinct, so that count() of the term is the number of documents containing it.
+	tokens := model.NewFunction("splitByNonAlpha", model.NewFunction("lower", model.NewFunction("COALESCE", field, model.NewLiteralSingleQuoteString(""))))
+	return model.NewFunction("arrayJoin", model.NewFunction("arrayDistinct", tokens))
+}
+
+// BackgroundFrequencyExpr returns SQL expression for the number of documents in the background set containing the term.
+// We compute frequencies once (it's a scalar subquery) and look our term up in the result map.
+// Only candidateTerms (terms of the foreground set, see pancake's significantTermsCandidates) are counted,
+// as counting all terms of the index (for significant_text: all tokens of all documents) would be much too expensive.
+func (query SignificantTerms) BackgroundFrequencyExpr(key model.Expr, candidateTerms model.SelectCommand) model.Expr {
+	term := model.NewAliasedExpr(key, "term")
+	count := model.NewAliasedExpr(model.NewCountFunc(), "count")
+	// (NULL is never IN candidates, which is fine, as map keys can't be NULL)
+	isCandidate := model.NewInfixExpr(key, "IN", model.NewParenExpr(candidateTerms))
+	termsFrequencies := model.SelectCommand{
+		Columns:     []model.Expr{term, count},
+		GroupBy:     []model.Expr{key},
+		FromClause:  model.NewTableRef(model.SingleTableNamePlaceHolder),
+		WhereClause: model.And([]model.Expr{query.backgroundFilter, isCandidate}),
+	}
+	// We use aliases as literals, not columns, so that they aren't treated as table columns (e.g. by sampler)
+	termsFrequenciesMap := model.SelectCommand{
+		Columns: []model.Expr{model.NewFunction("mapFromArrays",
+			model.NewFunction("groupArray", term.AliasRef()),
+			model.NewFunction("groupArray", count.AliasRef()),
+		)},
+		FromClause: termsFrequencies,
+	}
+	return model.NewFunction("arrayElement", model.NewParenExpr(termsFrequenciesMap), key)
+}
+
+// BackgroundSizeExpr returns SQL expression for the number of documents in the background set.
+// (for indexes in the common table, the filter by index name is added to this subquery too, like to every query of the table)
+func (query SignificantTerms) BackgroundSizeExpr() model.Expr {
+	return model.NewParenExpr(model.SelectCommand{
+		Columns:     []model.Expr{model.NewCountFunc()},
+		FromClause:  model.NewTableRef(model.SingleTableNamePlaceHolder),
+		WhereClause: query.backgroundFilter,
+	})
+}
+
+func (query SignificantTerms) UpdateFieldForIncludeAndExclude(field model.Expr) (updatedField model.Expr, didWeUpdateField bool) {
+	return updateFieldForIncludeAndExclude(field, query.exclude)
+}
+
+func (query SignificantTerms) score(row model.QueryResultRow) float64 {
+	return query.heuristic.Score(query.docCount(row), query.subsetSize(row), query.bgCount(row), query.supersetSize(row))
+}
+
+func (query SignificantTerms) docCount(row model.QueryResultRow) int64 {
+	return query.extractCount(row.Cols[len(row.Cols)-1].Value)
+}
+
+func (query SignificantTerms) key(row model.QueryResultRow) any {
+	return row.Cols[len(row.Cols)-2].Value
+}
+
+func (query SignificantTerms) subsetSize(row model.QueryResultRow) int64 {
+	return query.extractCount(row.Cols[len(row.Cols)-3].Value)
+}
+
+func (query SignificantTerms) bgCount(row model.QueryResultRow) int64 {
+	return query.extractCount(row.Cols[len(row.Cols)-4].Value)
+}
+
+func (query SignificantTerms) supersetSize(row model.QueryResultRow) int64 {
+	return query.extractCount(row.Cols[len(row.Cols)-5].Value)
+}
+
+func (query SignificantTerms) extractCount(value any) int64 {
+	if value == nil { // e.g. term missing in the background set
+		return 0
+	}
+	count, err := util.ExtractInt64(value)
+	if err != nil {
+		if countAsFloat, ok := util.ExtractNumeric64Maybe(value); ok {
+			return int64(countAsFloat)
+		}
+		logger.WarnWithCtx(query.ctx).Msgf("unexpected type of count in %s: %T, value: %v", query.String(), value, value)
+	}
+	return count
+}
@@ -15,7 +15,6 @@ import (
 // TODO when adding include/exclude, check escaping of ' and \ in those fields
 type Terms struct {
 	ctx         context.Context
-	significant bool // true <=> significant_terms, false <=> terms
 	minDocCount int
 	// include is either:
 	//   - single value: then for strings, it can be a regex.
@@ -29,8 +28,8 @@ type Terms struct {
 	exclude any
 }
 
-func NewTerms(ctx context.Context, significant bool, minDocCount int, include, exclude any) Terms {
-	return Terms{ctx: ctx, significant: significant, minDocCount: minDocCount, include: include, exclude: exclude}
+func NewTerms(ctx context.Context, minDocCount int, include, exclude any) Terms {
+	return Terms{ctx: ctx, minDocCount: minDocCount, include: include, exclude: exclude}
 }
 
 func (query Terms) AggregationType() model.AggregationType {
@@ -52,13 +51,8 @@ func (query Terms) TranslateSqlResponseToJson(rows []model.QueryResultRow) model
 
 	buckets := make([]model.JsonMap, 0, len(rows))
 	for _, row := range rows {
-		docCount := query.docCount(row)
 		bucket := model.JsonMap{
-			"doc_count": docCount,
-		}
-		if query.significant {
-			bucket["score"] = docCount
-			bucket["bg_count"] = docCount
+			"doc_count": query.docCount(row),
 		}
 
 		// response for bool keys is different
@@ -76,33 +70,17 @@ func (query Terms) TranslateSqlResponseToJson(rows []model.QueryResultRow) model
 		buckets = append(buckets, bucket)
 	}
 
-	if !query.significant {
-		parentCountAsInt, _ := util.ExtractInt64(query.parentCount(rows[0]))
-		sumOtherDocCount := int(parentCountAsInt) - query.sumDocCounts(rows)
-		return model.JsonMap{
-			"sum_other_doc_count":         sumOtherDocCount,
-			"doc_count_error_upper_bound": 0,
-			"buckets":                     buckets,
-		}
-	} else {
-		parentDocCount, _ := util.ExtractInt64(query.parentCount(rows[0]))
-		return model.JsonMap{
-			"buckets":   buckets,
-			"doc_count": parentDocCount,
-			"bg_count":  parentDocCount,
-		}
+	parentCountAsInt, _ := util.ExtractInt64(query.parentCount(rows[0]))
+	sumOtherDocCount := int(parentCountAsInt) - query.sumDocCounts(rows)
+	return model.JsonMap{
+		"sum_other_doc_count":         sumOtherDocCount,
+		"doc_count_error_upper_bound": 0,
+		"buckets":                     buckets,
 	}
 }
 
 func (query Terms) String() string {
-	if !query.significant {
-		return "terms"
-	}
-	return "significant_terms"
-}
-
-func (query Terms) IsSignificant() bool {
-	return query.significant
+	return "terms"
 }
 
 func (query Terms) sumDocCounts(rows []model.QueryResultRow) int {
@@ -138,14 +116,18 @@ func (query Terms) parentCount(row model.QueryResultRow) any {
 }
 
 func (query Terms) UpdateFieldForIncludeAndExclude(field model.Expr) (updatedField model.Expr, didWeUpdateField bool) {
+	return updateFieldForIncludeAndExclude(field, query.exclude)
+}
+
+func updateFieldForIncludeAndExclude(field model.Expr, exclude any) (updatedField model.Expr, didWeUpdateField bool) {
 	// We'll use here everywhere Clickhouse 'if' function: if(condition, then, else)
 	// In our case field becomes: if(condition that field is not excluded, field, NULL)
 	ifOrNull := func(condition model.Expr) model.FunctionExpr {
 		return model.NewFunction("if", condition, field, model.NullExpr)
 	}
 
-	hasExclude := query.exclude != nil
-	excludeArr, excludeIsArray := query.exclude.([]any)
+	hasExclude := exclude != nil
+	excludeArr, excludeIsArray := exclude.([]any)
 	switch {
 	case hasExclude && excludeIsArray:
 		if len(excludeArr) == 0 {
@@ -159,12 +141,12 @@ func (query Terms) UpdateFieldForIncludeAndExclude(field model.Expr) (updatedFie
 		}
 		return ifOrNull(model.NewInfixExpr(field, "NOT IN", model.NewTupleExpr(exprs...))), true
 	case hasExclude:
-		switch exclude := query.exclude.(type) {
+		switch excludeTyped := exclude.(type) {
 		case string: // hard case, might be regex
-			funcName, patternExpr := regex.ToClickhouseExpr(exclude)
+			funcName, patternExpr := regex.ToClickhouseExpr(excludeTyped)
 			return ifOrNull(model.NewInfixExpr(field, "NOT "+funcName, patternExpr)), true
 		default: // easy case, never regex
-			return ifOrNull(model.NewInfixExpr(field, "!=", model.NewLiteral(query.exclude))), true
+			return ifOrNull(model.NewInfixExpr(field, "!=", model.NewLiteral(exclude))), true
 		}
 
 	default:
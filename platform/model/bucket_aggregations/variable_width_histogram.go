@@ -0,0 +1,89 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package bucket_aggregations
+
+import (
+	"context"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/model"
+)
+
+// VariableWidthHistogram groups documents into (at most) 'buckets' buckets of different widths, so that
+// close values end up in the same bucket. Each bucket has min, max, and key (centroid = average of its values).
+//
+// Elastic clusters values with a one-pass k-means-like algorithm. We take bucket boundaries from Clickhouse's
+// adaptive histogram() function (computed once, over all documents matching the query), and then group
+// by the number of the bucket, so doc_count, min, max and centroid of each bucket are exact.
+// Limitation: if it's a sub-aggregation, boundaries are the same in all parent buckets.
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/search-aggregations-bucket-variablewidthhistogram-aggregation.html
+type VariableWidthHistogram struct {
+	ctx       context.Context
+	field     model.Expr
+	bucketsNr int
+}
+
+func NewVariableWidthHistogram(ctx context.Context, field model.Expr, bucketsNr int) VariableWidthHistogram {
+	return VariableWidthHistogram{ctx: ctx, field: field, bucketsNr: bucketsNr}
+}
+
+func (query VariableWidthHistogram) AggregationType() model.AggregationType {
+	return model.BucketAggregation
+}
+
+// TranslateSqlResponseToJson expects rows with (in this order): bucket number, min, max, centroid, count
+func (query VariableWidthHistogram) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.JsonMap {
+	buckets := make([]model.JsonMap, 0, len(rows))
+	for _, row := range rows {
+		if len(row.Cols) < 5 {
+			logger.ErrorWithCtx(query.ctx).Msgf(
+				"unexpected number of columns in %s aggregation response, len: %d, row: %v", query.String(), len(row.Cols), row)
+			return model.JsonMap{"buckets": []model.JsonMap{}}
+		}
+		cols := row.Cols[len(row.Cols)-4:]
+		buckets = append(buckets, model.JsonMap{
+			"min":       cols[0].Value,
+			"max":       cols[1].Value,
+			"key":       cols[2].Value,
+			"doc_count": cols[3].Value,
+		})
+	}
+	return model.JsonMap{"buckets": buckets}
+}
+
+func (query VariableWidthHistogram) String() string {
+	return fmt.Sprintf("variable_width_histogram(field: %s, buckets: %d)", model.AsString(query.field), query.bucketsNr)
+}
+
+func (query VariableWidthHistogram) BucketsNr() int {
+	return query.bucketsNr
+}
+
+// KeyExpr returns SQL expression for the (1-based) number of the document's bucket, or NULL if the field is NULL.
+// Bucket boundaries are computed in a scalar subquery, over documents matching whereClause.
+func (query VariableWidthHistogram) KeyExpr(whereClause model.Expr) model.Expr {
+	const binArg, lowerBoundArg = "bin", "lower_bound"
+	histogram := model.NewFunction(fmt.Sprintf("histogram(%d)", query.bucketsNr), query.field)
+	lowerBounds := model.NewParenExpr(model.SelectCommand{
+		Columns: []model.Expr{model.NewFunction("arrayMap",
+			model.NewLambdaExpr([]string{binArg}, model.NewFunction("tupleElement", model.NewLiteral(binArg), model.NewLiteral(1))),
+			histogram,
+		)},
+		FromClause:  model.NewTableRef(model.SingleTableNamePlaceHolder),
+		WhereClause: whereClause,
+	})
+	bucketNr := model.NewFunction("arrayCount",
+		model.NewLambdaExpr([]string{lowerBoundArg}, model.NewInfixExpr(model.NewLiteral(lowerBoundArg), "<=", query.field)),
+		lowerBounds,
+	)
+	return model.NewFunction("if", model.NewInfixExpr(query.field, "IS", model.NewLiteral("NULL")), model.NullExpr, bucketNr)
+}
+
+// StatsExprs returns SQL expressions for min, max, and centroid of a bucket
+func (query VariableWidthHistogram) StatsExprs() []model.Expr {
+	return []model.Expr{
+		model.NewFunction("min", query.field),
+		model.NewFunction("max", query.field),
+		model.NewFunction("avg", query.field),
+	}
+}
@@ -6,9 +6,15 @@ const (
 	SingleTableNamePlaceHolder   = "__quesma_table_name"
 	FullTextFieldNamePlaceHolder = "__quesma_fulltext_field_name"
 	TimestampFieldName           = "@timestamp"
+	ScoreFieldName               = "_score"
+	ScoreColumnName              = "__quesma_score" // alias of the score expression in hits queries
 
 	DateHourFunction           = "__quesma_date_hour"
 	MatchOperator              = "__quesma_match"
+	MatchScoreFunction         = "__quesma_match_score"
 	FromUnixTimeFunction       = "__quesma_from_unixtime"
 	FromUnixTimeFunction64mili = "__quesma_from_unixtime64mili"
+	NestedQueryFunction        = "__quesma_nested" // __quesma_nested('path', condition): condition must hold for a single object of the `path` array
+
+	ArrayJoinParentRowColumnName = "__quesma_parent_row" // identifies the original (parent) row after ARRAY JOIN
 )
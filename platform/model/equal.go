@@ -2,6 +2,8 @@
 // SPDX-License-Identifier: Elastic-2.0
 package model
 
+import "slices"
+
 // Check if two expressions are equal, ignores aliases
 // Partly implemented, can return false even if it should be equal
 func PartlyImplementedIsEqual(a, b Expr) bool {
@@ -58,6 +60,15 @@ func PartlyImplementedIsEqual(a, b Expr) bool {
 			}
 			return true
 		}
+	case LambdaExpr:
+		if bTyped, ok := b.(LambdaExpr); ok {
+			return slices.Equal(aTyped.Args, bTyped.Args) && PartlyImplementedIsEqual(aTyped.Body, bTyped.Body)
+		}
+	case SelectCommand:
+		// (sub)queries are complex, but their SQL is deterministic, so comparing it is enough
+		if bTyped, ok := b.(SelectCommand); ok {
+			return AsString(aTyped) == AsString(bTyped)
+		}
 	}
 	return false
 }
@@ -213,22 +213,34 @@ func (v *renderer) VisitSelectCommand(c SelectCommand) interface{} {
 	//  WHERE ("timestamp">=parseDateTime64BestEffort('2024-02-02T13:47:16.029Z') AND
 	//    "timestamp"<=parseDateTime64BestEffort('2024-02-09T13:47:16.029Z'))
 	//  LIMIT 12)
-	if c.SampleLimit > 0 {
+	// Queries with ARRAY JOIN are printed the same way, as WHERE must be applied to whole arrays, before unnesting, e.g.
+	//SELECT "events_type", count()
+	//FROM (
+	//  SELECT "events_type", rowNumberInAllBlocks() AS "__quesma_parent_row"
+	//  FROM "logs-generic-default"
+	//  WHERE has("events_type", 'click'))
+	//ARRAY JOIN "events_type"
+	//GROUP BY "events_type"
+	hasSubquery := c.SampleLimit > 0 || len(c.ArrayJoin) > 0
+	if hasSubquery {
 		sb.WriteString("(SELECT ")
 		usedColumns := make(map[string]bool)
-		for _, col := range append(c.Columns, c.GroupBy...) {
+		for _, col := range append(append(c.Columns, c.GroupBy...), c.ArrayJoin...) {
 			for _, usedCol := range GetUsedColumns(col) {
 				usedColumns[AsString(usedCol)] = true
 			}
 		}
-		if len(usedColumns) == 0 {
+		usedKeys := make([]string, 0, len(usedColumns)+1)
+		for key := range usedColumns {
+			usedKeys = append(usedKeys, key)
+		}
+		sort.Strings(usedKeys)
+		if len(c.ArrayJoin) > 0 {
+			usedKeys = append(usedKeys, fmt.Sprintf(`rowNumberInAllBlocks() AS "%s"`, ArrayJoinParentRowColumnName))
+		}
+		if len(usedKeys) == 0 {
 			sb.WriteString("1") // if no columns are used, it is simple count, 1 is enough
 		} else {
-			usedKeys := make([]string, 0, len(usedColumns))
-			for key := range usedColumns {
-				usedKeys = append(usedKeys, key)
-			}
-			sort.Strings(usedKeys)
 			sb.WriteString(strings.Join(usedKeys, ", "))
 		}
 		sb.WriteString(" FROM ")
@@ -252,7 +264,21 @@ func (v *renderer) VisitSelectCommand(c SelectCommand) interface{} {
 		sb.WriteString(AsString(c.WhereClause))
 	}
 	if c.SampleLimit > 0 {
-		sb.WriteString(fmt.Sprintf(" LIMIT %d)", c.SampleLimit))
+		if c.SampleLimitBy != nil {
+			sb.WriteString(fmt.Sprintf(" LIMIT %d BY %s", c.SampleLimitPerValue, AsString(c.SampleLimitBy)))
+		}
+		sb.WriteString(fmt.Sprintf(" LIMIT %d", c.SampleLimit))
+	}
+	if hasSubquery {
+		sb.WriteString(")")
+	}
+	if len(c.ArrayJoin) > 0 {
+		arrayJoin := make([]string, 0, len(c.ArrayJoin))
+		for _, col := range c.ArrayJoin {
+			arrayJoin = append(arrayJoin, AsString(col))
+		}
+		sb.WriteString(" ARRAY JOIN ")
+		sb.WriteString(strings.Join(arrayJoin, ", "))
 	}
 
 	groupBy := make([]string, 0, len(c.GroupBy))
@@ -0,0 +1,52 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package metrics_aggregations
+
+import (
+	"context"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/model"
+)
+
+// Boxplot is Elastic's boxplot aggregation.
+// "lower" and "upper" are whiskers: the smallest/largest value within [q1 - 1.5*IQR, q3 + 1.5*IQR].
+type Boxplot struct {
+	ctx context.Context
+}
+
+func NewBoxplot(ctx context.Context) Boxplot {
+	return Boxplot{ctx: ctx}
+}
+
+var boxplotColumnsInOrder = []string{"min", "max", "q1", "q2", "q3", "lower", "upper"} // we always ask for such order of columns
+
+func (query Boxplot) AggregationType() model.AggregationType {
+	return model.MetricsAggregation
+}
+
+func (query Boxplot) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.JsonMap {
+	response := make(model.JsonMap, len(boxplotColumnsInOrder))
+	for _, column := range boxplotColumnsInOrder {
+		response[column] = nil
+	}
+	if !resultRowsAreNonEmpty(query.ctx, rows) {
+		return response
+	}
+	if len(rows[0].Cols) != len(boxplotColumnsInOrder) {
+		logger.WarnWithCtx(query.ctx).Msgf("expected %d columns for boxplot, got %d. Row: %+v", len(boxplotColumnsInOrder), len(rows[0].Cols), rows[0])
+		return response
+	}
+	// min is NULL only if there are no values at all. Then all other columns are meaningless.
+	if rows[0].Cols[0].Value == nil {
+		return response
+	}
+
+	for i, column := range boxplotColumnsInOrder {
+		response[column] = rows[0].Cols[i].Value
+	}
+	return response
+}
+
+func (query Boxplot) String() string {
+	return "boxplot"
+}
@@ -0,0 +1,108 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package metrics_aggregations
+
+import (
+	"context"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"reflect"
+)
+
+// MatrixStats is Elastic's matrix_stats aggregation.
+// As in Elastic, only documents having all the fields are taken into account.
+// We request columns in such order:
+// doc_count, then [avg, varSamp, skewPop, kurtPop] for each field, then covarSampMatrix and corrMatrix of all fields.
+type MatrixStats struct {
+	ctx        context.Context
+	fieldNames []string
+}
+
+func NewMatrixStats(ctx context.Context, fieldNames []string) MatrixStats {
+	return MatrixStats{ctx: ctx, fieldNames: fieldNames}
+}
+
+const matrixStatsColumnsPerField = 4
+
+func (query MatrixStats) AggregationType() model.AggregationType {
+	return model.MetricsAggregation
+}
+
+func (query MatrixStats) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.JsonMap {
+	if !resultRowsAreNonEmpty(query.ctx, rows) {
+		return model.JsonMap{"doc_count": 0}
+	}
+	cols := rows[0].Cols
+	expectedColumnsNr := 1 + matrixStatsColumnsPerField*len(query.fieldNames) + 2
+	if len(cols) != expectedColumnsNr {
+		logger.WarnWithCtx(query.ctx).Msgf("expected %d columns for matrix_stats, got %d. Row: %+v", expectedColumnsNr, len(cols), rows[0])
+		return model.JsonMap{"doc_count": 0}
+	}
+
+	docCount := cols[0].Value
+	if docCount == nil || reflect.ValueOf(docCount).IsZero() {
+		return model.JsonMap{"doc_count": 0}
+	}
+
+	covariance := query.parseMatrix(cols[len(cols)-2].Value)
+	correlation := query.parseMatrix(cols[len(cols)-1].Value)
+	fields := make([]model.JsonMap, 0, len(query.fieldNames))
+	for i, fieldName := range query.fieldNames {
+		firstCol := 1 + i*matrixStatsColumnsPerField
+		field := model.JsonMap{
+			"name":        fieldName,
+			"count":       docCount,
+			"mean":        cols[firstCol].Value,
+			"variance":    cols[firstCol+1].Value,
+			"skewness":    cols[firstCol+2].Value,
+			"kurtosis":    cols[firstCol+3].Value,
+			"covariance":  query.matrixRow(covariance, i),
+			"correlation": query.matrixRow(correlation, i),
+		}
+		fields = append(fields, field)
+	}
+
+	return model.JsonMap{
+		"doc_count": docCount,
+		"fields":    fields,
+	}
+}
+
+func (query MatrixStats) String() string {
+	return "matrix_stats"
+}
+
+// parseMatrix parses ClickHouse's Array(Array(Float64)) into [][]any. Returns nil on error.
+func (query MatrixStats) parseMatrix(matrixRaw any) [][]any {
+	matrix := reflect.ValueOf(matrixRaw)
+	if matrix.Kind() != reflect.Slice || matrix.Len() != len(query.fieldNames) {
+		logger.WarnWithCtx(query.ctx).Msgf("unexpected matrix in matrix_stats: %v (type %T)", matrixRaw, matrixRaw)
+		return nil
+	}
+	result := make([][]any, matrix.Len())
+	for i := range result {
+		row := reflect.ValueOf(matrix.Index(i).Interface())
+		if row.Kind() != reflect.Slice || row.Len() != len(query.fieldNames) {
+			logger.WarnWithCtx(query.ctx).Msgf("unexpected matrix row in matrix_stats: %v (type %T)", matrixRaw, matrixRaw)
+			return nil
+		}
+		result[i] = make([]any, row.Len())
+		for j := range result[i] {
+			result[i][j] = row.Index(j).Interface()
+		}
+	}
+	return result
+}
+
+// matrixRow returns i-th row of the matrix as {fieldName: value} map, e.g. {"income": 1.0, "poverty": -0.8}
+func (query MatrixStats) matrixRow(matrix [][]any, i int) model.JsonMap {
+	row := make(model.JsonMap, len(query.fieldNames))
+	for j, fieldName := range query.fieldNames {
+		if matrix != nil {
+			row[fieldName] = matrix[i][j]
+		} else {
+			row[fieldName] = nil
+		}
+	}
+	return row
+}
@@ -0,0 +1,43 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package metrics_aggregations
+
+import (
+	"context"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/util"
+)
+
+// MedianAbsoluteDeviation is Elastic's median_absolute_deviation aggregation: median(|x - median(x)|).
+// We request 2 columns: count(x) and the deviation itself (computed with quantileExact).
+// Count is only needed to tell "no values" (Elastic returns null) from a real 0 deviation.
+type MedianAbsoluteDeviation struct {
+	ctx context.Context
+}
+
+func NewMedianAbsoluteDeviation(ctx context.Context) MedianAbsoluteDeviation {
+	return MedianAbsoluteDeviation{ctx: ctx}
+}
+
+func (query MedianAbsoluteDeviation) AggregationType() model.AggregationType {
+	return model.MetricsAggregation
+}
+
+func (query MedianAbsoluteDeviation) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.JsonMap {
+	if !resultRowsAreNonEmpty(query.ctx, rows) {
+		return model.JsonMap{"value": nil}
+	}
+	if len(rows[0].Cols) != 2 {
+		logger.WarnWithCtx(query.ctx).Msgf("expected 2 columns for median_absolute_deviation, got %d. Row: %+v", len(rows[0].Cols), rows[0])
+		return model.JsonMap{"value": nil}
+	}
+	if count, ok := util.ExtractInt64Maybe(rows[0].Cols[0].Value); !ok || count == 0 {
+		return model.JsonMap{"value": nil}
+	}
+	return model.JsonMap{"value": rows[0].Cols[1].Value}
+}
+
+func (query MedianAbsoluteDeviation) String() string {
+	return "median_absolute_deviation"
+}
@@ -0,0 +1,116 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package metrics_aggregations
+
+import (
+	"context"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"math"
+	"reflect"
+)
+
+// StringStats is Elastic's string_stats aggregation.
+// We request 5 columns: count, min/max/avg length, and sumMap(chars, counts), which returns a tuple
+// ([char_1, ..., char_n], [count_1, ..., count_n]). Entropy and distribution are computed from the latter.
+type StringStats struct {
+	ctx              context.Context
+	showDistribution bool
+}
+
+func NewStringStats(ctx context.Context, showDistribution bool) StringStats {
+	return StringStats{ctx: ctx, showDistribution: showDistribution}
+}
+
+const stringStatsColumnsNr = 5
+
+func (query StringStats) AggregationType() model.AggregationType {
+	return model.MetricsAggregation
+}
+
+func (query StringStats) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.JsonMap {
+	response := model.JsonMap{
+		"count":      0,
+		"min_length": nil,
+		"max_length": nil,
+		"avg_length": nil,
+		"entropy":    0.0,
+	}
+	if query.showDistribution {
+		response["distribution"] = model.JsonMap{}
+	}
+	if !resultRowsAreNonEmpty(query.ctx, rows) {
+		return response
+	}
+	cols := rows[0].Cols
+	if len(cols) != stringStatsColumnsNr {
+		logger.WarnWithCtx(query.ctx).Msgf("expected %d columns for string_stats, got %d. Row: %+v", stringStatsColumnsNr, len(cols), rows[0])
+		return response
+	}
+	if count, ok := util.ExtractInt64Maybe(cols[0].Value); !ok || count == 0 {
+		return response
+	}
+
+	response["count"] = cols[0].Value
+	response["min_length"] = cols[1].Value
+	response["max_length"] = cols[2].Value
+	response["avg_length"] = cols[3].Value
+
+	chars, charCounts := query.parseCharCounts(cols[4].Value)
+	var totalChars float64
+	for _, charCount := range charCounts {
+		totalChars += charCount
+	}
+	if totalChars == 0 {
+		return response
+	}
+
+	entropy := 0.0
+	distribution := make(model.JsonMap, len(chars))
+	for i, char := range chars {
+		probability := charCounts[i] / totalChars
+		if probability > 0 {
+			entropy -= probability * math.Log2(probability)
+		}
+		distribution[char] = probability
+	}
+	response["entropy"] = entropy
+	if query.showDistribution {
+		response["distribution"] = distribution
+	}
+	return response
+}
+
+func (query StringStats) String() string {
+	return "string_stats"
+}
+
+// parseCharCounts extracts chars and their counts from sumMap's result: a tuple of 2 arrays.
+// We use reflection, as the driver may return different concrete types for the tuple and both arrays.
+func (query StringStats) parseCharCounts(tuple any) (chars []string, counts []float64) {
+	tupleValue := reflect.ValueOf(tuple)
+	if (tupleValue.Kind() != reflect.Slice && tupleValue.Kind() != reflect.Array) || tupleValue.Len() != 2 {
+		logger.WarnWithCtx(query.ctx).Msgf("unexpected char counts in string_stats: %v (type %T)", tuple, tuple)
+		return nil, nil
+	}
+	keys, values := reflect.ValueOf(tupleValue.Index(0).Interface()), reflect.ValueOf(tupleValue.Index(1).Interface())
+	if keys.Kind() != reflect.Slice || values.Kind() != reflect.Slice || keys.Len() != values.Len() {
+		logger.WarnWithCtx(query.ctx).Msgf("unexpected char counts in string_stats: %v (type %T)", tuple, tuple)
+		return nil, nil
+	}
+
+	chars = make([]string, 0, keys.Len())
+	counts = make([]float64, 0, values.Len())
+	for i := 0; i < keys.Len(); i++ {
+		char, okChar := keys.Index(i).Interface().(string)
+		count, okCount := util.ExtractNumeric64Maybe(values.Index(i).Interface())
+		if !okChar || !okCount {
+			logger.WarnWithCtx(query.ctx).Msgf("unexpected char count in string_stats: %v: %v", keys.Index(i), values.Index(i))
+			continue
+		}
+		chars = append(chars, char)
+		counts = append(counts, count)
+	}
+	return chars, counts
+}
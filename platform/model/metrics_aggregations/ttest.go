@@ -0,0 +1,185 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package metrics_aggregations
+
+import (
+	"context"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"math"
+)
+
+type (
+	// TTest is Elastic's t_test aggregation. It returns the p-value of the test.
+	//
+	// We don't run the test in ClickHouse (studentTTest/welchTTest need both samples in one column, plus
+	// there's no paired variant), but request count, avg and varSamp of each sample instead.
+	// That's enough to compute t-statistic, degrees of freedom and p-value here, for all 3 test types.
+	// Columns: paired: [count, avg, varSamp] of (a - b). Others: [count, avg, varSamp] of a, then the same of b.
+	TTest struct {
+		ctx      context.Context
+		testType TTestType
+		tails    int
+	}
+	TTestType string
+)
+
+const (
+	TTestPaired          TTestType = "paired"
+	TTestHomoscedastic   TTestType = "homoscedastic"
+	TTestHeteroscedastic TTestType = "heteroscedastic"
+	TTestInvalid         TTestType = "invalid"
+)
+
+const TTestDefaultTails = 2
+
+func NewTTest(ctx context.Context, testType TTestType, tails int) TTest {
+	return TTest{ctx: ctx, testType: testType, tails: tails}
+}
+
+// NewTTestType returns TTestInvalid for unknown types. Empty type means default: heteroscedastic.
+func NewTTestType(testType string) TTestType {
+	switch testType {
+	case "paired":
+		return TTestPaired
+	case "homoscedastic":
+		return TTestHomoscedastic
+	case "heteroscedastic", "":
+		return TTestHeteroscedastic
+	default:
+		return TTestInvalid
+	}
+}
+
+func (query TTest) AggregationType() model.AggregationType {
+	return model.MetricsAggregation
+}
+
+func (query TTest) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.JsonMap {
+	if !resultRowsAreNonEmpty(query.ctx, rows) {
+		return model.JsonMap{"value": nil}
+	}
+
+	expectedColumnsNr := 6
+	if query.testType == TTestPaired {
+		expectedColumnsNr = 3
+	}
+	cols := rows[0].Cols
+	if len(cols) != expectedColumnsNr {
+		logger.WarnWithCtx(query.ctx).Msgf("expected %d columns for t_test, got %d. Row: %+v", expectedColumnsNr, len(cols), rows[0])
+		return model.JsonMap{"value": nil}
+	}
+
+	values := make([]float64, len(cols))
+	for i, col := range cols {
+		var ok bool
+		if values[i], ok = util.ExtractNumeric64Maybe(col.Value); !ok {
+			// NULL/NaN, e.g. not enough values in some sample. Elastic returns null then, too.
+			return model.JsonMap{"value": nil}
+		}
+	}
+
+	var t, degreesOfFreedom float64
+	switch query.testType {
+	case TTestPaired:
+		t, degreesOfFreedom = tStatisticOneSample(values[0], values[1], values[2])
+	case TTestHomoscedastic:
+		t, degreesOfFreedom = tStatisticHomoscedastic(values[0], values[1], values[2], values[3], values[4], values[5])
+	default:
+		t, degreesOfFreedom = tStatisticHeteroscedastic(values[0], values[1], values[2], values[3], values[4], values[5])
+	}
+
+	pValue := tTestPValue(t, degreesOfFreedom, query.tails)
+	if math.IsNaN(pValue) || math.IsInf(pValue, 0) {
+		return model.JsonMap{"value": nil}
+	}
+	return model.JsonMap{"value": pValue}
+}
+
+func (query TTest) String() string {
+	return "t_test"
+}
+
+func tStatisticOneSample(n, mean, variance float64) (t, degreesOfFreedom float64) {
+	return mean / math.Sqrt(variance/n), n - 1
+}
+
+func tStatisticHomoscedastic(n1, mean1, variance1, n2, mean2, variance2 float64) (t, degreesOfFreedom float64) {
+	degreesOfFreedom = n1 + n2 - 2
+	pooledVariance := ((n1-1)*variance1 + (n2-1)*variance2) / degreesOfFreedom
+	return (mean1 - mean2) / math.Sqrt(pooledVariance*(1/n1+1/n2)), degreesOfFreedom
+}
+
+// tStatisticHeteroscedastic is Welch's t-test, with Welch–Satterthwaite degrees of freedom.
+func tStatisticHeteroscedastic(n1, mean1, variance1, n2, mean2, variance2 float64) (t, degreesOfFreedom float64) {
+	se1, se2 := variance1/n1, variance2/n2
+	degreesOfFreedom = (se1 + se2) * (se1 + se2) / (se1*se1/(n1-1) + se2*se2/(n2-1))
+	return (mean1 - mean2) / math.Sqrt(se1+se2), degreesOfFreedom
+}
+
+// tTestPValue returns P(|T| >= |t|) for 2 tails, P(T >= |t|) for 1 tail, where T ~ Student's t(degreesOfFreedom).
+func tTestPValue(t, degreesOfFreedom float64, tails int) float64 {
+	if degreesOfFreedom <= 0 || math.IsNaN(t) {
+		return math.NaN()
+	}
+	twoTailed := regularizedIncompleteBeta(degreesOfFreedom/(degreesOfFreedom+t*t), degreesOfFreedom/2, 0.5)
+	if tails == 1 {
+		return twoTailed / 2
+	}
+	return twoTailed
+}
+
+// regularizedIncompleteBeta computes I_x(a, b) using its continued fraction representation
+// (modified Lentz's method, as in Numerical Recipes, 6.4).
+func regularizedIncompleteBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lgammaA, _ := math.Lgamma(a)
+	lgammaB, _ := math.Lgamma(b)
+	lgammaAB, _ := math.Lgamma(a + b)
+	front := math.Exp(lgammaAB - lgammaA - lgammaB + a*math.Log(x) + b*math.Log(1-x))
+	// continued fraction converges quickly only for x < (a+1)/(a+b+2), otherwise use the symmetry I_x(a,b) = 1 - I_{1-x}(b,a)
+	if x < (a+1)/(a+b+2) {
+		return front * incompleteBetaContinuedFraction(x, a, b) / a
+	}
+	return 1 - front*incompleteBetaContinuedFraction(1-x, b, a)/b
+}
+
+func incompleteBetaContinuedFraction(x, a, b float64) float64 {
+	const (
+		maxIterations = 300
+		epsilon       = 1e-15
+		tiny          = 1e-300
+	)
+	clampTiny := func(v float64) float64 {
+		if math.Abs(v) < tiny {
+			return tiny
+		}
+		return v
+	}
+
+	c, d := 1.0, 1/clampTiny(1-(a+b)*x/(a+1))
+	result := d
+	for m := 1.0; m <= maxIterations; m++ {
+		// even step
+		numerator := m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		d = 1 / clampTiny(1+numerator*d)
+		c = clampTiny(1 + numerator/c)
+		result *= d * c
+		// odd step
+		numerator = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		d = 1 / clampTiny(1+numerator*d)
+		c = clampTiny(1 + numerator/c)
+		delta := d * c
+		result *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return result
+}
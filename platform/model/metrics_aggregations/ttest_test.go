@@ -0,0 +1,64 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package metrics_aggregations
+
+import (
+	"context"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestTTestPValue(t *testing.T) {
+	testcases := []struct {
+		t, degreesOfFreedom float64
+		tails               int
+		expectedPValue      float64
+	}{
+		{0, 5, 2, 1},
+		{1, 1, 2, 0.5},                    // Cauchy distribution
+		{-1, 1, 1, 0.25},                  // Cauchy distribution
+		{2, 2, 2, 1 - 2/math.Sqrt(6)},     // closed form for 2 degrees of freedom
+		{2, 10, 2, 0.07338803477074551},   // values below computed with numerical integration
+		{0.5, 3.7, 2, 0.6453356333199289}, // non-integer degrees of freedom (happen in Welch's test)
+		{-1.866277899263374, 8, 2, 0.0989714995},
+	}
+	for _, tc := range testcases {
+		assert.InDelta(t, tc.expectedPValue, tTestPValue(tc.t, tc.degreesOfFreedom, tc.tails), 1e-9, "t: %f, df: %f", tc.t, tc.degreesOfFreedom)
+	}
+}
+
+func TestTTestTranslateSqlResponseToJson(t *testing.T) {
+	// a = [1, 2, 3, 4, 5], b = [2, 4, 6, 8, 11]
+	unpaired := []model.QueryResultRow{{Cols: []model.QueryResultCol{
+		model.NewQueryResultCol("count_a", uint64(5)), model.NewQueryResultCol("avg_a", 3.0), model.NewQueryResultCol("var_a", 2.5),
+		model.NewQueryResultCol("count_b", uint64(5)), model.NewQueryResultCol("avg_b", 6.2), model.NewQueryResultCol("var_b", 12.2),
+	}}}
+	// a - b = [-1, -2, -3, -4, -6]
+	paired := []model.QueryResultRow{{Cols: []model.QueryResultCol{
+		model.NewQueryResultCol("count", uint64(5)), model.NewQueryResultCol("avg", -3.2), model.NewQueryResultCol("var", 3.7),
+	}}}
+
+	testcases := []struct {
+		testType       TTestType
+		rows           []model.QueryResultRow
+		expectedPValue float64
+	}{
+		{TTestHomoscedastic, unpaired, 0.09897149953132411},
+		{TTestHeteroscedastic, unpaired, 0.11499016052991407},
+		{TTestPaired, paired, 0.020475874420926576}, // t = -3.2 / sqrt(3.7/5) = -3.7199, df = 4
+	}
+	for _, tc := range testcases {
+		t.Run(string(tc.testType), func(t *testing.T) {
+			response := NewTTest(context.Background(), tc.testType, TTestDefaultTails).TranslateSqlResponseToJson(tc.rows)
+			assert.InDelta(t, tc.expectedPValue, response["value"], 1e-6)
+		})
+	}
+
+	// not enough values -> NULL variance -> null p-value
+	notEnough := []model.QueryResultRow{{Cols: []model.QueryResultCol{
+		model.NewQueryResultCol("count", uint64(1)), model.NewQueryResultCol("avg", 1.0), model.NewQueryResultCol("var", nil),
+	}}}
+	assert.Nil(t, NewTTest(context.Background(), TTestPaired, TTestDefaultTails).TranslateSqlResponseToJson(notEnough)["value"])
+}
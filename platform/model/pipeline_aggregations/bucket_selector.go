@@ -0,0 +1,95 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package pipeline_aggregations
+
+import (
+	"context"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/parsers/painful"
+	"math"
+)
+
+// BucketSelector keeps only those buckets of its parent bucket aggregation, for which the script returns true.
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/search-aggregations-pipeline-bucket-selector-aggregation.html
+type BucketSelector struct {
+	*PipelineAggregation
+	bucketsPaths map[string]string // script's variable name -> buckets_path to its value
+	source       string
+	script       painful.Expr
+}
+
+func NewBucketSelector(ctx context.Context, bucketsPaths map[string]string, source string, script painful.Expr) (BucketSelector, error) {
+	// bucket_selector always works on buckets of the aggregation it's defined in, so we don't need a real parent path
+	query := BucketSelector{PipelineAggregation: newPipelineAggregation(ctx, BucketsPathCount),
+		bucketsPaths: bucketsPaths, source: source, script: script}
+
+	// evaluate the script once, so that we return an error for unsupported scripts already when parsing the request
+	vars := make(map[string]any, len(bucketsPaths))
+	for variable := range bucketsPaths {
+		vars[variable] = 0.0
+	}
+	if _, err := query.eval(vars); err != nil {
+		return BucketSelector{}, err
+	}
+	return query, nil
+}
+
+func (query BucketSelector) AggregationType() model.AggregationType {
+	return model.PipelineBucketAggregation
+}
+
+// TranslateSqlResponseToJson is never used, bucket_selector doesn't have its own result
+func (query BucketSelector) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.JsonMap {
+	return model.JsonMap{}
+}
+
+func (query BucketSelector) CalculateResultWhenMissing(parentRows []model.QueryResultRow) []model.QueryResultRow {
+	return parentRows
+}
+
+func (query BucketSelector) ModifyBuckets(buckets []model.JsonMap) []model.JsonMap {
+	selectedBuckets := make([]model.JsonMap, 0, len(buckets))
+	for _, bucket := range buckets {
+		vars := make(map[string]any, len(query.bucketsPaths))
+		for variable, bucketsPath := range query.bucketsPaths {
+			value := valueFromRenderedBucket(bucket, bucketsPath)
+			if value == nil {
+				value = math.NaN() // missing values are NaN, like in Elastic
+			}
+			vars[variable] = value
+		}
+		selected, err := query.eval(vars)
+		if err != nil {
+			logger.WarnWithCtx(query.ctx).Msgf("error evaluating %s: %v", query.String(), err)
+			continue
+		}
+		if selected {
+			selectedBuckets = append(selectedBuckets, bucket)
+		}
+	}
+	return selectedBuckets
+}
+
+// eval runs the script with values from buckets_path, which are available both as `params.x` and as `x`
+// (the latter in scripts of the legacy 'expression' language)
+func (query BucketSelector) eval(vars map[string]any) (bool, error) {
+	result, err := query.script.Eval(&painful.Env{Vars: vars, Params: vars})
+	if err != nil {
+		return false, err
+	}
+	selected, ok := result.(bool)
+	if !ok {
+		return false, fmt.Errorf("script returned non-boolean value: %v (type: %T)", result, result)
+	}
+	return selected, nil
+}
+
+func (query BucketSelector) String() string {
+	return fmt.Sprintf("bucket_selector(bucketsPaths: %v, script: %s)", query.bucketsPaths, query.source)
+}
+
+func (query BucketSelector) PipelineAggregationType() model.PipelineAggregationType {
+	return model.PipelineParentAggregation
+}
@@ -0,0 +1,119 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package pipeline_aggregations
+
+import (
+	"context"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"slices"
+	"strings"
+)
+
+// BucketSort sorts buckets of its parent bucket aggregation, and/or truncates them (from, size).
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/search-aggregations-pipeline-bucket-sort-aggregation.html
+type BucketSort struct {
+	*PipelineAggregation
+	sortFields  []BucketSortField
+	from        int
+	size        int  // BucketSortNoSize = return all buckets
+	insertZeros bool // gap_policy: true for insert_zeros, false for skip (buckets with missing values are removed)
+}
+
+type BucketSortField struct {
+	BucketsPath string
+	Desc        bool
+}
+
+const BucketSortNoSize = -1
+
+func NewBucketSort(ctx context.Context, sortFields []BucketSortField, from, size int, insertZeros bool) BucketSort {
+	// bucket_sort always works on buckets of the aggregation it's defined in, so we don't need a real parent path
+	return BucketSort{PipelineAggregation: newPipelineAggregation(ctx, BucketsPathCount),
+		sortFields: sortFields, from: from, size: size, insertZeros: insertZeros}
+}
+
+func (query BucketSort) AggregationType() model.AggregationType {
+	return model.PipelineBucketAggregation
+}
+
+// TranslateSqlResponseToJson is never used, bucket_sort doesn't have its own result
+func (query BucketSort) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.JsonMap {
+	return model.JsonMap{}
+}
+
+func (query BucketSort) CalculateResultWhenMissing(parentRows []model.QueryResultRow) []model.QueryResultRow {
+	return parentRows
+}
+
+func (query BucketSort) ModifyBuckets(buckets []model.JsonMap) []model.JsonMap {
+	type bucketWithSortValues struct {
+		bucket     model.JsonMap
+		sortValues []any
+	}
+
+	toSort := make([]bucketWithSortValues, 0, len(buckets))
+	for _, bucket := range buckets {
+		sortValues := make([]any, 0, len(query.sortFields))
+		for _, sortField := range query.sortFields {
+			value := valueFromRenderedBucket(bucket, sortField.BucketsPath)
+			if value == nil && query.insertZeros {
+				value = 0.0
+			}
+			sortValues = append(sortValues, value)
+		}
+		if !query.insertZeros && slices.Contains(sortValues, nil) {
+			continue
+		}
+		toSort = append(toSort, bucketWithSortValues{bucket: bucket, sortValues: sortValues})
+	}
+
+	slices.SortStableFunc(toSort, func(a, b bucketWithSortValues) int {
+		for i, sortField := range query.sortFields {
+			cmp := compareSortValues(a.sortValues[i], b.sortValues[i])
+			if sortField.Desc {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp
+			}
+		}
+		return 0
+	})
+
+	from := min(query.from, len(toSort))
+	to := len(toSort)
+	if query.size != BucketSortNoSize {
+		to = min(from+query.size, to)
+	}
+	result := make([]model.JsonMap, 0, to-from)
+	for _, bucketToSort := range toSort[from:to] {
+		result = append(result, bucketToSort.bucket)
+	}
+	return result
+}
+
+// compareSortValues compares numbers numerically, and everything else (e.g. terms' keys) as strings
+func compareSortValues(a, b any) int {
+	aNumber, aIsNumber := util.ExtractNumeric64Maybe(a)
+	bNumber, bIsNumber := util.ExtractNumeric64Maybe(b)
+	if aIsNumber && bIsNumber {
+		switch {
+		case aNumber < bNumber:
+			return -1
+		case aNumber > bNumber:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func (query BucketSort) String() string {
+	return fmt.Sprintf("bucket_sort(sort: %v, from: %d, size: %d, insertZeros: %v)", query.sortFields, query.from, query.size, query.insertZeros)
+}
+
+func (query BucketSort) PipelineAggregationType() model.PipelineAggregationType {
+	return model.PipelineParentAggregation
+}
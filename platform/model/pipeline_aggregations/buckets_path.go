@@ -0,0 +1,69 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package pipeline_aggregations
+
+import (
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"strings"
+)
+
+const (
+	bucketsPathKey              = "_key" // special name for `buckets_path` parameter, bucket's key
+	bucketsPathAggDelimiter     = ">"
+	bucketsPathMetricDelimiter  = "."
+	bucketsPathBracketDelimiter = "["
+)
+
+// valueFromRenderedBucket returns value referenced by bucketsPath (e.g. "_count", "the_sum", "the_stats.avg",
+// "the_filter>the_sum", "the_percentiles[99.9]") from a bucket already rendered to JSON.
+// Returns nil if there's no such value, same as Elastic does for missing values.
+func valueFromRenderedBucket(bucket model.JsonMap, bucketsPath string) any {
+	aggNames := strings.Split(bucketsPath, bucketsPathAggDelimiter)
+	for _, aggName := range aggNames[:len(aggNames)-1] {
+		subBucket, ok := bucket[aggName].(model.JsonMap)
+		if !ok {
+			return nil
+		}
+		bucket = subBucket
+	}
+
+	last := aggNames[len(aggNames)-1]
+	switch last {
+	case BucketsPathCount:
+		return bucket["doc_count"]
+	case bucketsPathKey:
+		return bucket["key"]
+	}
+
+	aggName, metricName := last, ""
+	if bracketIdx := strings.Index(last, bucketsPathBracketDelimiter); bracketIdx != -1 && strings.HasSuffix(last, "]") {
+		aggName, metricName = last[:bracketIdx], strings.Trim(last[bracketIdx+1:len(last)-1], `'"`)
+	} else if dotIdx := strings.Index(last, bucketsPathMetricDelimiter); dotIdx != -1 {
+		aggName, metricName = last[:dotIdx], last[dotIdx+1:]
+	}
+
+	agg, ok := bucket[aggName].(model.JsonMap)
+	if !ok {
+		return nil
+	}
+	switch {
+	case metricName == "":
+		if value, exists := agg["value"]; exists {
+			return value
+		}
+		return agg["doc_count"] // single bucket aggregation, e.g. filter
+	case metricName == BucketsPathCount:
+		return agg["doc_count"]
+	}
+	if value, exists := agg[metricName]; exists {
+		return value
+	}
+	// percentiles
+	if percentiles, ok := agg["values"].(model.JsonMap); ok {
+		if value, exists := percentiles[metricName]; exists {
+			return value
+		}
+		return percentiles[metricName+".0"]
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/logger"
 	"github.com/QuesmaOrg/quesma/platform/model"
 	"github.com/QuesmaOrg/quesma/platform/util"
+	"time"
 )
 
 // translateSqlResponseToJsonCommon translates rows from DB (maybe postprocessed later), into JSON's format in which
@@ -102,3 +103,66 @@ func calculateResultWhenMissingCommonForDiffAggregations(ctx context.Context, pa
 	}
 	return resultRows
 }
+
+// translateSqlResponseToJsonMapCommon is common for pipeline aggregations which keep their whole result
+// (a JsonMap) in the last column of a single row, e.g. stats_bucket.
+func translateSqlResponseToJsonMapCommon(ctx context.Context, rows []model.QueryResultRow, aggregationName string) model.JsonMap {
+	if len(rows) == 0 {
+		logger.WarnWithCtx(ctx).Msgf("no rows returned for %s aggregation", aggregationName)
+		return model.JsonMap{}
+	}
+	if len(rows) > 1 {
+		logger.WarnWithCtx(ctx).Msgf("more than one row returned for %s aggregation", aggregationName)
+	}
+	if returnMap, ok := rows[0].LastColValue().(model.JsonMap); ok {
+		return returnMap
+	}
+	logger.WarnWithCtx(ctx).Msgf("could not convert value to JsonMap: %v, type: %T", rows[0].LastColValue(), rows[0].LastColValue())
+	return model.JsonMap{}
+}
+
+// calculateResultPerParentBucketCommon is common for sibling aggregations, which calculate a single value from all
+// buckets of their parent. Rows are [parent_cols..., current_key, current_value], so we split them into buckets
+// based on parent_cols, and calculate the result (JsonMap, stored in the last column) for each of them.
+func calculateResultPerParentBucketCommon(ctx context.Context, parentRows []model.QueryResultRow,
+	calculate func(values []float64) model.JsonMap) []model.QueryResultRow {
+
+	resultRows := make([]model.QueryResultRow, 0)
+	if len(parentRows) == 0 {
+		return resultRows
+	}
+	parentFieldsCnt := len(parentRows[0].Cols) - 2
+	if parentFieldsCnt < 0 {
+		logger.WarnWithCtx(ctx).Msgf("parentFieldsCnt is less than 0: %d", parentFieldsCnt)
+	}
+	for _, parentRowsOneBucket := range model.SplitResultSetIntoBuckets(parentRows, parentFieldsCnt) {
+		if len(parentRowsOneBucket) == 0 {
+			continue
+		}
+		resultRow := parentRowsOneBucket[0].Copy()
+		resultRow.Cols[len(resultRow.Cols)-1].Value = calculate(numericValuesCommon(ctx, parentRowsOneBucket))
+		resultRows = append(resultRows, resultRow)
+	}
+	return resultRows
+}
+
+// numericValuesCommon returns values from the last column of all rows. Nulls are skipped (like with gap_policy=skip),
+// dates are converted to milliseconds since epoch, just like Elastic does.
+func numericValuesCommon(ctx context.Context, rows []model.QueryResultRow) []float64 {
+	values := make([]float64, 0, len(rows))
+	for _, row := range rows {
+		switch value := row.LastColValue().(type) {
+		case nil:
+			continue
+		case time.Time:
+			values = append(values, float64(value.UnixMilli()))
+		default:
+			if valueAsFloat, ok := util.ExtractNumeric64Maybe(value); ok {
+				values = append(values, valueAsFloat)
+			} else {
+				logger.WarnWithCtx(ctx).Msgf("could not convert value to float: %v, type: %T. Skipping", value, value)
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,90 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package pipeline_aggregations
+
+import (
+	"context"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"math"
+)
+
+type ExtendedStatsBucket struct {
+	*PipelineAggregation
+	sigma float64 // how many standard deviations above/below the mean std_deviation_bounds are
+}
+
+func NewExtendedStatsBucket(ctx context.Context, bucketsPath string, sigma float64) ExtendedStatsBucket {
+	return ExtendedStatsBucket{PipelineAggregation: newPipelineAggregation(ctx, bucketsPath), sigma: sigma}
+}
+
+func (query ExtendedStatsBucket) AggregationType() model.AggregationType {
+	return model.PipelineMetricsAggregation
+}
+
+func (query ExtendedStatsBucket) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.JsonMap {
+	return translateSqlResponseToJsonMapCommon(query.ctx, rows, query.String())
+}
+
+func (query ExtendedStatsBucket) CalculateResultWhenMissing(parentRows []model.QueryResultRow) []model.QueryResultRow {
+	return calculateResultPerParentBucketCommon(query.ctx, parentRows, query.calculateExtendedStats)
+}
+
+// calculateExtendedStats returns the same fields as Elastic's extended_stats aggregation.
+// Just like there, values which can't be calculated (e.g. sampling variance of 1 value) are "NaN".
+func (query ExtendedStatsBucket) calculateExtendedStats(values []float64) model.JsonMap {
+	result := calculateStats(values)
+	if len(values) == 0 {
+		for _, key := range []string{"sum_of_squares", "variance", "variance_population", "variance_sampling",
+			"std_deviation", "std_deviation_population", "std_deviation_sampling"} {
+			result[key] = nil
+		}
+		result["std_deviation_bounds"] = model.JsonMap{
+			"upper": nil, "lower": nil, "upper_population": nil, "lower_population": nil, "upper_sampling": nil, "lower_sampling": nil,
+		}
+		return result
+	}
+
+	count := float64(len(values))
+	sum, sumOfSquares := 0.0, 0.0
+	for _, value := range values {
+		sum += value
+		sumOfSquares += value * value
+	}
+	avg := sum / count
+	variance := max(sumOfSquares/count-avg*avg, 0) // max, as it may be slightly negative because of float imprecision
+	stdDeviation := math.Sqrt(variance)
+
+	var varianceSampling, stdDeviationSampling, upperSampling, lowerSampling any = "NaN", "NaN", "NaN", "NaN"
+	if len(values) > 1 {
+		variance := max((sumOfSquares-sum*sum/count)/(count-1), 0)
+		stdDeviation := math.Sqrt(variance)
+		varianceSampling, stdDeviationSampling = variance, stdDeviation
+		upperSampling, lowerSampling = avg+query.sigma*stdDeviation, avg-query.sigma*stdDeviation
+	}
+
+	result["sum_of_squares"] = sumOfSquares
+	result["variance"] = variance
+	result["variance_population"] = variance
+	result["variance_sampling"] = varianceSampling
+	result["std_deviation"] = stdDeviation
+	result["std_deviation_population"] = stdDeviation
+	result["std_deviation_sampling"] = stdDeviationSampling
+	result["std_deviation_bounds"] = model.JsonMap{
+		"upper":            avg + query.sigma*stdDeviation,
+		"lower":            avg - query.sigma*stdDeviation,
+		"upper_population": avg + query.sigma*stdDeviation,
+		"lower_population": avg - query.sigma*stdDeviation,
+		"upper_sampling":   upperSampling,
+		"lower_sampling":   lowerSampling,
+	}
+	return result
+}
+
+func (query ExtendedStatsBucket) String() string {
+	return fmt.Sprintf("extended_stats_bucket(%s, sigma: %f)", query.Parent, query.sigma)
+}
+
+func (query ExtendedStatsBucket) PipelineAggregationType() model.PipelineAggregationType {
+	return model.PipelineSiblingAggregation
+}
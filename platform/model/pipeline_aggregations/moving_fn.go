@@ -0,0 +1,122 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package pipeline_aggregations
+
+import (
+	"context"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/parsers/painful"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"math"
+	"time"
+)
+
+// We fully support this aggregation, as long as the script uses only MovingFunctions (see painful.MovingFunctionsSupported),
+// arithmetic and the 'values' variable.
+// Description: A parent pipeline aggregation which slides a window across the buckets of its parent histogram
+// (or date_histogram) and runs a script (e.g. MovingFunctions.unweightedAvg(values)) on the values in that window.
+// By default (shift = 0), the window contains 'window' previous buckets, excluding the current one.
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/search-aggregations-pipeline-movfn-aggregation.html
+
+type MovingFn struct {
+	script painful.Expr
+	window int
+	shift  int
+	*PipelineAggregation
+}
+
+func NewMovingFn(ctx context.Context, bucketsPath string, script painful.Expr, window, shift int) MovingFn {
+	return MovingFn{script: script, window: window, shift: shift, PipelineAggregation: newPipelineAggregation(ctx, bucketsPath)}
+}
+
+func (query MovingFn) AggregationType() model.AggregationType {
+	return model.PipelineBucketAggregation
+}
+
+func (query MovingFn) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.JsonMap {
+	return translateSqlResponseToJsonCommon(query.ctx, rows, query.String())
+}
+
+func (query MovingFn) CalculateResultWhenMissing(parentRows []model.QueryResultRow) []model.QueryResultRow {
+	resultRows := make([]model.QueryResultRow, 0, len(parentRows))
+
+	// Buckets without a value (null) are skipped: they don't get a result and don't count into any window.
+	values := make([]float64, 0, len(parentRows))
+	for _, parentRow := range parentRows {
+		if value, ok := movingValue(query.ctx, parentRow.LastColValue()); ok {
+			values = append(values, value)
+		}
+	}
+
+	index := 0
+	for _, parentRow := range parentRows {
+		resultRow := parentRow.Copy()
+		resultRow.Cols[len(resultRow.Cols)-1].Value = nil
+		if _, ok := movingValue(query.ctx, parentRow.LastColValue()); ok {
+			from := clampWindowIndex(index-query.window+query.shift, len(values))
+			to := clampWindowIndex(index+query.shift, len(values))
+			resultRow.Cols[len(resultRow.Cols)-1].Value = query.calculate(values[from:to])
+			index++
+		}
+		resultRows = append(resultRows, resultRow)
+	}
+	return resultRows
+}
+
+// calculate runs the script on a single window. Returns nil if the result is NaN/Inf (Elastic returns null then).
+func (query MovingFn) calculate(window []float64) any {
+	result, err := query.script.Eval(&painful.Env{Vars: map[string]any{"values": window}})
+	if err != nil {
+		logger.WarnWithCtx(query.ctx).Msgf("error evaluating %s: %v", query.String(), err)
+		return nil
+	}
+	resultAsFloat, ok := util.ExtractNumeric64Maybe(result)
+	if !ok {
+		logger.WarnWithCtx(query.ctx).Msgf("%s script returned non-numeric value: %v (type: %T)", query.String(), result, result)
+		return nil
+	}
+	if math.IsNaN(resultAsFloat) || math.IsInf(resultAsFloat, 0) {
+		return nil
+	}
+	return resultAsFloat
+}
+
+func (query MovingFn) String() string {
+	return fmt.Sprintf("moving_fn(parent: %s, window: %d, shift: %d)", query.Parent, query.window, query.shift)
+}
+
+func (query MovingFn) PipelineAggregationType() model.PipelineAggregationType {
+	return model.PipelineParentAggregation
+}
+
+// ValidateMovingFnScript evaluates the script on a window of zeros, so that we can return an error
+// for unsupported scripts already when parsing the request.
+func ValidateMovingFnScript(script painful.Expr, window int) error {
+	_, err := script.Eval(&painful.Env{Vars: map[string]any{"values": make([]float64, window)}})
+	return err
+}
+
+// movingValue returns the (numeric) value of a bucket, and false if the bucket has no value.
+func movingValue(ctx context.Context, value any) (float64, bool) {
+	switch valueTyped := value.(type) {
+	case nil:
+		return 0, false
+	case time.Time:
+		return float64(valueTyped.UnixMilli()), true
+	}
+	valueAsFloat, ok := util.ExtractNumeric64Maybe(value)
+	if !ok {
+		logger.WarnWithCtx(ctx).Msgf("could not convert value to float: %v, type: %T. Skipping", value, value)
+		return 0, false
+	}
+	if math.IsNaN(valueAsFloat) {
+		return 0, false
+	}
+	return valueAsFloat, true
+}
+
+func clampWindowIndex(index, length int) int {
+	return max(0, min(index, length))
+}
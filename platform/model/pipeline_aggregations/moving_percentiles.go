@@ -0,0 +1,157 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package pipeline_aggregations
+
+import (
+	"context"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"slices"
+	"strconv"
+)
+
+// We partially support this aggregation.
+// Description: A parent pipeline aggregation which slides a window across the buckets of its parent histogram
+// (or date_histogram), and for each bucket calculates percentiles of all documents in the window.
+// The window contains the current bucket and 'window - 1' previous ones (shifted by 'shift').
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/search-aggregations-pipeline-moving-percentiles-aggregation.html
+//
+// Limitation: Elastic merges the percentiles sketches of buckets in the window, but we only have final percentiles
+// values of each bucket from Clickhouse. So a percentile of a window is approximated by the average of this percentile
+// across all (non-empty) buckets in the window. It's exact when there's 1 bucket in the window.
+//
+// Last column of parent rows is expected to contain already rendered percentiles of a bucket
+// (JsonMap, like returned by metrics_aggregations.Quantile), and we return results in the same (keyed or not) format.
+
+type MovingPercentiles struct {
+	window int
+	shift  int
+	*PipelineAggregation
+}
+
+func NewMovingPercentiles(ctx context.Context, bucketsPath string, window, shift int) MovingPercentiles {
+	return MovingPercentiles{window: window, shift: shift, PipelineAggregation: newPipelineAggregation(ctx, bucketsPath)}
+}
+
+func (query MovingPercentiles) AggregationType() model.AggregationType {
+	return model.PipelineBucketAggregation
+}
+
+func (query MovingPercentiles) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.JsonMap {
+	return translateSqlResponseToJsonMapCommon(query.ctx, rows, query.String())
+}
+
+func (query MovingPercentiles) CalculateResultWhenMissing(parentRows []model.QueryResultRow) []model.QueryResultRow {
+	percentilesPerBucket := make([]renderedPercentiles, len(parentRows))
+	for i, parentRow := range parentRows {
+		percentilesPerBucket[i] = newRenderedPercentiles(parentRow.LastColValue())
+	}
+
+	resultRows := make([]model.QueryResultRow, 0, len(parentRows))
+	for i, parentRow := range parentRows {
+		from := clampWindowIndex(i-query.window+1+query.shift, len(parentRows))
+		to := clampWindowIndex(i+1+query.shift, len(parentRows))
+
+		resultRow := parentRow.Copy()
+		resultRow.Cols[len(resultRow.Cols)-1].Value = query.calculate(percentilesPerBucket[from:to])
+		resultRows = append(resultRows, resultRow)
+	}
+	return resultRows
+}
+
+func (query MovingPercentiles) calculate(window []renderedPercentiles) model.JsonMap {
+	var keys []string
+	keyed := true
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+	for _, bucket := range window {
+		if len(bucket.keys) > len(keys) {
+			keys = bucket.keys
+			keyed = bucket.keyed
+		}
+		for key, value := range bucket.values {
+			sums[key] += value
+			counts[key]++
+		}
+	}
+
+	valueForKey := func(key string) any {
+		if counts[key] == 0 {
+			return nil
+		}
+		return sums[key] / float64(counts[key])
+	}
+	if keyed {
+		values := make(model.JsonMap, len(keys))
+		for _, key := range keys {
+			values[key] = valueForKey(key)
+		}
+		return model.JsonMap{"values": values}
+	}
+	values := make([]model.JsonMap, 0, len(keys))
+	for _, key := range keys {
+		keyAsFloat, _ := strconv.ParseFloat(key, 64)
+		values = append(values, model.JsonMap{"key": keyAsFloat, "value": valueForKey(key)})
+	}
+	return model.JsonMap{"values": values}
+}
+
+func (query MovingPercentiles) String() string {
+	return fmt.Sprintf("moving_percentiles(parent: %s, window: %d, shift: %d)", query.Parent, query.window, query.shift)
+}
+
+func (query MovingPercentiles) PipelineAggregationType() model.PipelineAggregationType {
+	return model.PipelineParentAggregation
+}
+
+// renderedPercentiles are percentiles of a single bucket, parsed from a rendered percentiles aggregation.
+type renderedPercentiles struct {
+	keys   []string           // e.g. ["1.0", "50.0", "99.0"], in ascending order
+	values map[string]float64 // only non-null values
+	keyed  bool
+}
+
+func newRenderedPercentiles(rendered any) (result renderedPercentiles) {
+	result.values = make(map[string]float64)
+	renderedMap, ok := rendered.(model.JsonMap)
+	if !ok { // e.g. empty bucket, added by date_histogram
+		return
+	}
+
+	switch values := renderedMap["values"].(type) {
+	case model.JsonMap:
+		result.keyed = true
+		for key, value := range values {
+			result.keys = append(result.keys, key)
+			if valueAsFloat, ok := util.ExtractNumeric64Maybe(value); ok {
+				result.values[key] = valueAsFloat
+			}
+		}
+	case []model.JsonMap:
+		for _, keyValue := range values {
+			keyAsFloat, ok := util.ExtractNumeric64Maybe(keyValue["key"])
+			if !ok {
+				continue
+			}
+			key := percentileKey(keyAsFloat)
+			result.keys = append(result.keys, key)
+			if valueAsFloat, ok := util.ExtractNumeric64Maybe(keyValue["value"]); ok {
+				result.values[key] = valueAsFloat
+			}
+		}
+	}
+
+	slices.SortFunc(result.keys, func(a, b string) int {
+		aFloat, _ := strconv.ParseFloat(a, 64)
+		bFloat, _ := strconv.ParseFloat(b, 64)
+		switch {
+		case aFloat < bFloat:
+			return -1
+		case aFloat > bFloat:
+			return 1
+		}
+		return 0
+	})
+	return
+}
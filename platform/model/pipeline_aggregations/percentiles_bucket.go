@@ -0,0 +1,83 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package pipeline_aggregations
+
+import (
+	"context"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type PercentilesBucket struct {
+	*PipelineAggregation
+	percents []float64
+	keyed    bool // https://www.elastic.co/guide/en/elasticsearch/reference/current/search-aggregations-pipeline-percentiles-bucket-aggregation.html
+}
+
+// PercentilesBucketDefaultPercents are the same as in Elastic
+var PercentilesBucketDefaultPercents = []float64{1, 5, 25, 50, 75, 95, 99}
+
+func NewPercentilesBucket(ctx context.Context, bucketsPath string, percents []float64, keyed bool) PercentilesBucket {
+	return PercentilesBucket{PipelineAggregation: newPipelineAggregation(ctx, bucketsPath), percents: percents, keyed: keyed}
+}
+
+func (query PercentilesBucket) AggregationType() model.AggregationType {
+	return model.PipelineMetricsAggregation
+}
+
+func (query PercentilesBucket) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.JsonMap {
+	return translateSqlResponseToJsonMapCommon(query.ctx, rows, query.String())
+}
+
+func (query PercentilesBucket) CalculateResultWhenMissing(parentRows []model.QueryResultRow) []model.QueryResultRow {
+	return calculateResultPerParentBucketCommon(query.ctx, parentRows, query.calculatePercentiles)
+}
+
+// calculatePercentiles uses the nearest-rank method, exactly like Elastic: no interpolation, result is always one of the values.
+func (query PercentilesBucket) calculatePercentiles(values []float64) model.JsonMap {
+	sortedValues := slices.Clone(values)
+	slices.Sort(sortedValues)
+
+	percentileValue := func(percent float64) any {
+		if len(sortedValues) == 0 {
+			return nil
+		}
+		index := int(math.Round(percent / 100 * float64(len(sortedValues)-1)))
+		return sortedValues[index]
+	}
+
+	if query.keyed {
+		valueMap := make(model.JsonMap, len(query.percents))
+		for _, percent := range query.percents {
+			valueMap[percentileKey(percent)] = percentileValue(percent)
+		}
+		return model.JsonMap{"values": valueMap}
+	}
+
+	valueList := make([]model.JsonMap, 0, len(query.percents))
+	for _, percent := range query.percents {
+		valueList = append(valueList, model.JsonMap{"key": percent, "value": percentileValue(percent)})
+	}
+	return model.JsonMap{"values": valueList}
+}
+
+// percentileKey returns percent formatted like Elastic (and Kibana expects) does it, e.g. "99.0" or "99.9"
+func percentileKey(percent float64) string {
+	key := strconv.FormatFloat(percent, 'f', -1, 64)
+	if !strings.Contains(key, ".") {
+		key += ".0"
+	}
+	return key
+}
+
+func (query PercentilesBucket) String() string {
+	return fmt.Sprintf("percentiles_bucket(%s, percents: %v, keyed: %v)", query.Parent, query.percents, query.keyed)
+}
+
+func (query PercentilesBucket) PipelineAggregationType() model.PipelineAggregationType {
+	return model.PipelineSiblingAggregation
+}
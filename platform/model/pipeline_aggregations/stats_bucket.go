@@ -0,0 +1,58 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package pipeline_aggregations
+
+import (
+	"context"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/model"
+)
+
+type StatsBucket struct {
+	*PipelineAggregation
+}
+
+func NewStatsBucket(ctx context.Context, bucketsPath string) StatsBucket {
+	return StatsBucket{PipelineAggregation: newPipelineAggregation(ctx, bucketsPath)}
+}
+
+func (query StatsBucket) AggregationType() model.AggregationType {
+	return model.PipelineMetricsAggregation
+}
+
+func (query StatsBucket) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.JsonMap {
+	return translateSqlResponseToJsonMapCommon(query.ctx, rows, query.String())
+}
+
+func (query StatsBucket) CalculateResultWhenMissing(parentRows []model.QueryResultRow) []model.QueryResultRow {
+	return calculateResultPerParentBucketCommon(query.ctx, parentRows, calculateStats)
+}
+
+func (query StatsBucket) String() string {
+	return fmt.Sprintf("stats_bucket(%s)", query.Parent)
+}
+
+func (query StatsBucket) PipelineAggregationType() model.PipelineAggregationType {
+	return model.PipelineSiblingAggregation
+}
+
+// calculateStats returns count/min/max/avg/sum of values, in the same format as Elastic's stats aggregation
+func calculateStats(values []float64) model.JsonMap {
+	if len(values) == 0 {
+		return model.JsonMap{"count": 0, "min": nil, "max": nil, "avg": nil, "sum": 0.0}
+	}
+
+	minValue, maxValue, sum := values[0], values[0], 0.0
+	for _, value := range values {
+		minValue = min(minValue, value)
+		maxValue = max(maxValue, value)
+		sum += value
+	}
+	return model.JsonMap{
+		"count": len(values),
+		"min":   minValue,
+		"max":   maxValue,
+		"avg":   sum / float64(len(values)),
+		"sum":   sum,
+	}
+}
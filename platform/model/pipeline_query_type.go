@@ -29,3 +29,11 @@ type PipelineQueryType interface {
 	GetParentBucketAggregation() QueryType
 	SetParentBucketAggregation(parentBucketAggregation QueryType)
 }
+
+// PipelineBucketsModifierQueryType is a pipeline aggregation which doesn't return a value of its own, but filters
+// and/or reorders buckets of its parent bucket aggregation (e.g. bucket_selector, bucket_sort).
+// It operates on already rendered buckets, so it may reference any (sub)aggregation of the bucket.
+type PipelineBucketsModifierQueryType interface {
+	PipelineQueryType
+	ModifyBuckets(buckets []JsonMap) []JsonMap
+}
@@ -94,7 +94,8 @@ type RuntimeMapping struct {
 	Field                 string
 	Type                  string
 	DatabaseExpression    Expr
-	PostProcessExpression painful.Expr
+	PostProcessExpression painful.Expr // evaluated in Go if the script can't be lowered to DatabaseExpression
+	Params                map[string]any
 }
 
 const MainExecutionPlan = "main"
@@ -185,9 +186,10 @@ func (queryType HitsInfo) String() string {
 type HitsCountInfo struct {
 	Type            HitsInfo
 	RequestedFields []string
-	Size            int // how many hits to return
-	TrackTotalHits  int // >= 0: we want this nr of total hits, TrackTotalHitsTrue: it was "true", TrackTotalHitsFalse: it was "false", in the request
-	SearchAfter     any // Value of query's "search_after" param. Used for pagination of hits. SearchAfterEmpty means no pagination
+	Size            int  // how many hits to return
+	TrackTotalHits  int  // >= 0: we want this nr of total hits, TrackTotalHitsTrue: it was "true", TrackTotalHitsFalse: it was "false", in the request
+	SearchAfter     any  // Value of query's "search_after" param. Used for pagination of hits. SearchAfterEmpty means no pagination
+	TrackScores     bool // Value of query's "track_scores" param. If true, we compute scores even if hits aren't sorted by them
 }
 
 func NewEmptyHitsCountInfo() HitsCountInfo {
@@ -92,7 +92,7 @@ func (r *QueryResultRow) String(ctx context.Context) string {
 	i := 0
 	for _, col := range r.Cols {
 		// skip internal columns
-		if col.ColName == common_table.IndexNameColumn {
+		if col.ColName == common_table.IndexNameColumn || col.ColName == common_table.DocumentIdColumn || col.ColName == ScoreColumnName {
 			continue
 		}
 
@@ -0,0 +1,14 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package model
+
+const QuesmaRangeGteFunction = "__quesma_range_gte"
+const QuesmaRangeLteFunction = "__quesma_range_lte"
+
+func NewRangeGte(propertyName string) Expr {
+	return NewFunction(QuesmaRangeGteFunction, NewColumnRef(propertyName))
+}
+
+func NewRangeLte(propertyName string) Expr {
+	return NewFunction(QuesmaRangeLteFunction, NewColumnRef(propertyName))
+}
@@ -14,6 +14,13 @@ type SelectCommand struct {
 	LimitBy     []Expr // LIMIT BY clause (empty => maybe LIMIT, but no LIMIT BY)
 	Limit       int    // LIMIT clause, noLimit (0) means no limit
 	SampleLimit int    // LIMIT, but before grouping, 0 means no limit
+	// SampleLimitBy, if not nil, limits sampled rows to SampleLimitPerValue for each of its values (LIMIT n BY expr, before grouping).
+	// Only used together with SampleLimit.
+	SampleLimitBy       Expr
+	SampleLimitPerValue int
+	// ArrayJoin, if not empty, unnests these array columns (ARRAY JOIN ...) after WHERE and sampling,
+	// so in all other clauses they refer to single elements. Used by nested aggregations.
+	ArrayJoin []Expr
 
 	NamedCTEs []*CTE // Named Common Table Expressions, so these parts of query: WITH cte_1 AS SELECT ..., cte_2 AS SELECT ...
 }
@@ -9,6 +9,9 @@ type SimpleQuery struct {
 	// This can be different from the OrderBy clause, as it may contain Elasticsearch-internal fields like `_doc`.
 	// In that case, it is not reflected in the OrderBy clause, but is still used for assembling the response.
 	CanParse bool
+	// Score is an SQL approximation of the relevance score (`_score`) of a matching document.
+	// nil means the query doesn't influence scoring, i.e. every matching document has a constant score.
+	Score Expr
 	// NeedCountWithLimit > 0 means we need count(*) LIMIT NeedCountWithLimit
 	// NeedCountWithLimit 0 (WeNeedUnlimitedCount) means we need count(*) (unlimited)
 	// NeedCountWithLimit -1 (WeDontNeedCount) means we don't need a count(*) query
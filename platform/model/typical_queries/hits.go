@@ -35,6 +35,7 @@ type Hits struct {
 	addSource          bool // true <=> we add hit.Source field to the response
 	addScore           bool // true <=> we add hit.Score field to the response (whose value is always 1)
 	addVersion         bool // true <=> we add hit.Version field to the response (whose value is always 1)
+	scoresComputed     bool // true <=> hit.Score is taken from model.ScoreColumnName column computed in SQL (instead of being always 1)
 	indexes            []string
 	timestampFieldName string
 }
@@ -58,6 +59,7 @@ func (query Hits) AggregationType() model.AggregationType {
 func (query Hits) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.JsonMap {
 
 	hits := make([]model.SearchHit, 0, len(rows))
+	var maxScore *float32
 
 	lookForCommonTableIndexColumn := true
 
@@ -83,7 +85,12 @@ func (query Hits) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.
 
 		hit := model.NewSearchHit(indexName)
 
-		if query.addScore {
+		if query.scoresComputed {
+			hit.Score = query.computedScore(row)
+			if maxScore == nil || hit.Score > *maxScore {
+				maxScore = &hit.Score
+			}
+		} else if query.addScore {
 			hit.Score = defaultScore
 		}
 		if query.addVersion {
@@ -94,12 +101,18 @@ func (query Hits) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.
 		}
 		query.addAndHighlightHit(&hit, &row)
 
-		hit.ID = query.computeIdForDocument(hit, strconv.Itoa(i+1))
+		if storedId := query.storedDocumentId(row); storedId != "" {
+			hit.ID = storedId
+		} else {
+			hit.ID = query.computeIdForDocument(hit, strconv.Itoa(i+1))
+		}
 		for _, fieldName := range query.sortFieldNames {
 			if val, ok := hit.Fields[fieldName]; ok {
 				hit.Sort = append(hit.Sort, elasticsearch.FormatSortValue(val[0]))
 			} else if fieldName == "_doc" { // Kibana adds _doc as a tiebreaker field for sorting
 				hit.Sort = append(hit.Sort, hit.ID)
+			} else if fieldName == model.ScoreFieldName && query.scoresComputed {
+				hit.Sort = append(hit.Sort, hit.Score)
 			} else {
 				logger.WarnWithCtx(query.ctx).Msgf("field %s not found in fields", fieldName)
 			}
@@ -113,7 +126,8 @@ func (query Hits) TranslateSqlResponseToJson(rows []model.QueryResultRow) model.
 				Value:    len(rows),
 				Relation: "eq", // TODO fix in next PR
 			},
-			Hits: hits,
+			MaxScore: maxScore,
+			Hits:     hits,
 		},
 		"shards": model.ResponseShards{
 			Total:      1,
@@ -140,7 +154,7 @@ func (query Hits) addAndHighlightHit(hit *model.SearchHit, resultRow *model.Quer
 	for _, col := range resultRow.Cols {
 
 		// skip internal columns
-		if col.ColName == common_table.IndexNameColumn {
+		if col.ColName == common_table.IndexNameColumn || col.ColName == common_table.DocumentIdColumn || col.ColName == model.ScoreColumnName {
 			continue
 		}
 
@@ -232,6 +246,47 @@ func (query Hits) WithTimestampField(fieldName string) Hits {
 	return query
 }
 
+// WithComputedScores makes hits take their score from model.ScoreColumnName column, which then needs to be selected in the SQL query
+func (query Hits) WithComputedScores() Hits {
+	query.scoresComputed = true
+	return query
+}
+
+func (query Hits) computedScore(row model.QueryResultRow) float32 {
+	for _, col := range row.Cols {
+		if col.ColName == model.ScoreColumnName {
+			if col.Value == nil { // e.g. NULL score of a document with NULL field
+				return 0
+			}
+			if score, ok := util.ExtractNumeric64Maybe(col.Value); ok {
+				return float32(score)
+			}
+			logger.WarnWithCtx(query.ctx).Msgf("unexpected score type: %T, value: %v", col.Value, col.Value)
+			return 0
+		}
+	}
+	logger.WarnWithCtx(query.ctx).Msgf("score column %s not found in row", model.ScoreColumnName)
+	return 0
+}
+
+// storedDocumentId returns `_id` supplied when the document was ingested, or "" if there was none
+func (query Hits) storedDocumentId(row model.QueryResultRow) string {
+	for _, col := range row.Cols {
+		if col.ColName == common_table.DocumentIdColumn {
+			switch id := col.Value.(type) {
+			case string:
+				return id
+			case *string:
+				if id != nil {
+					return *id
+				}
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
 func (query Hits) computeIdForDocument(doc model.SearchHit, defaultID string) string {
 
 	if query.timestampFieldName == "" {
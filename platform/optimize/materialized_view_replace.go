@@ -165,7 +165,10 @@ func (s *materializedViewReplace) replace(rule materializedViewReplaceRule, quer
 					if whereReplaced {
 						replaced = true
 						from = model.NewTableRef(rule.materializedView) // config param
-						return model.NewSelectCommand(query.Columns, query.GroupBy, query.OrderBy, from, newWhere, query.LimitBy, query.Limit, query.SampleLimit, query.IsDistinct, namedCTEs)
+						result := model.NewSelectCommand(query.Columns, query.GroupBy, query.OrderBy, from, newWhere, query.LimitBy, query.Limit, query.SampleLimit, query.IsDistinct, namedCTEs)
+						result.SampleLimitBy, result.SampleLimitPerValue = query.SampleLimitBy, query.SampleLimitPerValue
+						result.ArrayJoin = query.ArrayJoin
+						return result
 					}
 				}
 			} else {
@@ -176,7 +179,10 @@ func (s *materializedViewReplace) replace(rule materializedViewReplaceRule, quer
 		if query.WhereClause != nil {
 			where = query.WhereClause.Accept(v).(model.Expr)
 		}
-		return model.NewSelectCommand(query.Columns, query.GroupBy, query.OrderBy, from, where, query.LimitBy, query.Limit, query.SampleLimit, query.IsDistinct, namedCTEs)
+		result := model.NewSelectCommand(query.Columns, query.GroupBy, query.OrderBy, from, where, query.LimitBy, query.Limit, query.SampleLimit, query.IsDistinct, namedCTEs)
+		result.SampleLimitBy, result.SampleLimitPerValue = query.SampleLimitBy, query.SampleLimitPerValue
+		result.ArrayJoin = query.ArrayJoin
+		return result
 
 	}
 
@@ -17,19 +17,24 @@ const keyedDefaultValuePercentileRanks = true
 
 type metricsAggregation struct {
 	AggrType            string
-	Fields              []model.Expr                 // on these fields we're doing aggregation. Array, because e.g. 'top_hits' can have multiple fields
-	OrderBy             []model.OrderByExpr          // only for top_hits
-	FieldType           database_common.DateTimeType // field type of FieldNames[0]. If it's a date field, a slightly different response is needed
-	Percentiles         map[string]float64           // Only for percentiles and percentile_ranks aggregation
-	Keyed               bool                         // Only for percentiles aggregation
-	CutValues           []string                     // Only for percentile_ranks
-	SortBy              string                       // Only for top_metrics
-	Size                int                          // Only for top_metrics
-	Order               string                       // Only for top_metrics
-	IsFieldNameCompound bool                         // Only for a few aggregations, where we have only 1 field. It's a compound, so e.g. toHour(timestamp), not just "timestamp"
-	sigma               float64                      // only for standard deviation
-	unit                string                       // only for rate
-	mode                string                       // only for rate
+	Fields              []model.Expr                   // on these fields we're doing aggregation. Array, because e.g. 'top_hits' can have multiple fields
+	OrderBy             []model.OrderByExpr            // only for top_hits
+	FieldType           database_common.DateTimeType   // field type of FieldNames[0]. If it's a date field, a slightly different response is needed
+	Percentiles         map[string]float64             // Only for percentiles and percentile_ranks aggregation
+	Keyed               bool                           // Only for percentiles aggregation
+	CutValues           []string                       // Only for percentile_ranks
+	SortBy              string                         // Only for top_metrics
+	Size                int                            // Only for top_metrics
+	Order               string                         // Only for top_metrics
+	IsFieldNameCompound bool                           // Only for a few aggregations, where we have only 1 field. It's a compound, so e.g. toHour(timestamp), not just "timestamp"
+	sigma               float64                        // only for standard deviation
+	unit                string                         // only for rate
+	mode                string                         // only for rate
+	showDistribution    bool                           // only for string_stats
+	tTestType           metrics_aggregations.TTestType // only for t_test
+	tails               int                            // only for t_test
+	filters             []model.Expr                   // only for t_test, filters[i] is for Fields[i] (nil means no filter)
+	fieldNames          []string                       // only for matrix_stats, names of Fields, as user specified them
 }
 
 type aggregationParser = func(queryMap QueryMap) (model.QueryType, error)
@@ -187,9 +192,156 @@ func (cw *ClickhouseQueryTranslator) tryMetricsAggregation(queryMap QueryMap) (m
 		}, true
 	}
 
+	for _, aggrType := range []string{"median_absolute_deviation", "string_stats", "boxplot"} {
+		if paramsRaw, exists := queryMap[aggrType]; exists {
+			params, ok := paramsRaw.(QueryMap)
+			if !ok {
+				logger.WarnWithCtx(cw.Ctx).Msgf("%s is not a map, but %T, value: %v. Skipping.", aggrType, paramsRaw, paramsRaw)
+				return metricsAggregation{}, false
+			}
+			field, _ := cw.addMissingParameterIfPresent(cw.parseFieldField(params, aggrType), params)
+			return metricsAggregation{
+				AggrType:         aggrType,
+				Fields:           []model.Expr{field},
+				showDistribution: cw.parseBoolField(params, "show_distribution", false),
+			}, true
+		}
+	}
+	if weightedAvg, exists := queryMap["weighted_avg"]; exists {
+		return cw.parseWeightedAvg(weightedAvg)
+	}
+	if tTest, exists := queryMap["t_test"]; exists {
+		return cw.parseTTest(tTest)
+	}
+	if matrixStats, exists := queryMap["matrix_stats"]; exists {
+		return cw.parseMatrixStats(matrixStats)
+	}
+
 	return metricsAggregation{}, false
 }
 
+// parseWeightedAvg parses e.g. {"value": {"field": "grade"}, "weight": {"field": "weight", "missing": 1}}
+func (cw *ClickhouseQueryTranslator) parseWeightedAvg(paramsRaw any) (metricAggregation metricsAggregation, success bool) {
+	params, ok := paramsRaw.(QueryMap)
+	if !ok {
+		logger.WarnWithCtx(cw.Ctx).Msgf("weighted_avg is not a map, but %T, value: %v. Skipping.", paramsRaw, paramsRaw)
+		return metricsAggregation{}, false
+	}
+	fields := make([]model.Expr, 0, 2)
+	for _, paramName := range []string{"value", "weight"} {
+		sourceParams, ok := params[paramName].(QueryMap)
+		if !ok {
+			logger.WarnWithCtx(cw.Ctx).Msgf("%s in weighted_avg is not a map, but %T, value: %v. Skipping.", paramName, params[paramName], params[paramName])
+			return metricsAggregation{}, false
+		}
+		field := cw.parseFieldField(sourceParams, "weighted_avg")
+		if field == nil {
+			return metricsAggregation{}, false
+		}
+		field, _ = cw.addMissingParameterIfPresent(field, sourceParams)
+		fields = append(fields, field)
+	}
+	return metricsAggregation{
+		AggrType: "weighted_avg",
+		Fields:   fields,
+	}, true
+}
+
+// parseTTest parses e.g. {"a": {"field": "x", "filter": {...}}, "b": {"field": "y"}, "type": "heteroscedastic", "tails": 2}
+func (cw *ClickhouseQueryTranslator) parseTTest(paramsRaw any) (metricAggregation metricsAggregation, success bool) {
+	params, ok := paramsRaw.(QueryMap)
+	if !ok {
+		logger.WarnWithCtx(cw.Ctx).Msgf("t_test is not a map, but %T, value: %v. Skipping.", paramsRaw, paramsRaw)
+		return metricsAggregation{}, false
+	}
+	tTestType := metrics_aggregations.NewTTestType(cw.parseStringField(params, "type", ""))
+	if tTestType == metrics_aggregations.TTestInvalid {
+		logger.WarnWithCtx(cw.Ctx).Msgf("invalid type in t_test: %v. Skipping.", params["type"])
+		return metricsAggregation{}, false
+	}
+	tails := cw.parseIntField(params, "tails", metrics_aggregations.TTestDefaultTails)
+	if tails != 1 && tails != 2 {
+		logger.WarnWithCtx(cw.Ctx).Msgf("tails in t_test must be 1 or 2, got: %d. Skipping.", tails)
+		return metricsAggregation{}, false
+	}
+
+	fields := make([]model.Expr, 0, 2)
+	filters := make([]model.Expr, 0, 2)
+	for _, sampleName := range []string{"a", "b"} {
+		sample, ok := params[sampleName].(QueryMap)
+		if !ok {
+			logger.WarnWithCtx(cw.Ctx).Msgf("%s in t_test is not a map, but %T, value: %v. Skipping.", sampleName, params[sampleName], params[sampleName])
+			return metricsAggregation{}, false
+		}
+		field := cw.parseFieldField(sample, "t_test")
+		if field == nil {
+			return metricsAggregation{}, false
+		}
+		fields = append(fields, field)
+
+		var filter model.Expr
+		if filterRaw, exists := sample["filter"]; exists {
+			filterMap, ok := filterRaw.(QueryMap)
+			if !ok {
+				logger.WarnWithCtx(cw.Ctx).Msgf("filter in t_test is not a map, but %T, value: %v. Skipping.", filterRaw, filterRaw)
+				return metricsAggregation{}, false
+			}
+			if tTestType == metrics_aggregations.TTestPaired {
+				// same as in Elastic: paired samples are always both fields of the same document
+				logger.WarnWithCtx(cw.Ctx).Msg("filters are not allowed in paired t_test. Skipping.")
+				return metricsAggregation{}, false
+			}
+			filter = cw.parseQueryMap(filterMap).WhereClause
+		}
+		filters = append(filters, filter)
+	}
+
+	return metricsAggregation{
+		AggrType:  "t_test",
+		Fields:    fields,
+		tTestType: tTestType,
+		tails:     tails,
+		filters:   filters,
+	}, true
+}
+
+// parseMatrixStats parses e.g. {"fields": ["income", "poverty"], "missing": {"income": 50000}}
+func (cw *ClickhouseQueryTranslator) parseMatrixStats(paramsRaw any) (metricAggregation metricsAggregation, success bool) {
+	params, ok := paramsRaw.(QueryMap)
+	if !ok {
+		logger.WarnWithCtx(cw.Ctx).Msgf("matrix_stats is not a map, but %T, value: %v. Skipping.", paramsRaw, paramsRaw)
+		return metricsAggregation{}, false
+	}
+	fieldsRaw, err := cw.parseArrayField(params, "fields")
+	if err != nil || len(fieldsRaw) == 0 {
+		logger.WarnWithCtx(cw.Ctx).Msgf("invalid fields in matrix_stats: %v, err: %v. Skipping.", params["fields"], err)
+		return metricsAggregation{}, false
+	}
+	missing, _ := params["missing"].(QueryMap)
+
+	fields := make([]model.Expr, 0, len(fieldsRaw))
+	fieldNames := make([]string, 0, len(fieldsRaw))
+	for _, fieldRaw := range fieldsRaw {
+		fieldName, ok := fieldRaw.(string)
+		if !ok {
+			logger.WarnWithCtx(cw.Ctx).Msgf("field in matrix_stats is not a string, but %T, value: %v. Skipping.", fieldRaw, fieldRaw)
+			return metricsAggregation{}, false
+		}
+		var field model.Expr = model.NewColumnRef(ResolveField(cw.Ctx, fieldName, cw.Schema))
+		if missingValue, exists := missing[fieldName]; exists {
+			field = model.NewFunction("COALESCE", field, model.NewLiteral(missingValue))
+		}
+		fields = append(fields, field)
+		fieldNames = append(fieldNames, fieldName)
+	}
+
+	return metricsAggregation{
+		AggrType:   "matrix_stats",
+		Fields:     fields,
+		fieldNames: fieldNames,
+	}, true
+}
+
 func (cw *ClickhouseQueryTranslator) parseTopHits(queryMap QueryMap) (parsedTopHits metricsAggregation, success bool) {
 	paramsRaw, ok := queryMap["top_hits"]
 	if !ok {
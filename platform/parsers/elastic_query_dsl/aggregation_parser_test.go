@@ -664,6 +664,7 @@ func allAggregationTests() []testdata.AggregationTestCase {
 	add(testdata.KibanaSampleDataFlights, "kibana-sample-data-flights")
 	add(testdata.KibanaSampleDataLogs, "kibana-sample-data-logs")
 	add(testdata.PipelineAggregationTests, "pipeline_agg_req")
+	add(testdata.PipelineAggregationTests2, "pipeline_agg_req_2")
 	add(dashboard_1.AggregationTests, "dashboard-1/agg_req")
 	add(kibana_visualize.AggregationTests, "kibana-visualize/agg_req")
 	add(kibana_visualize.PipelineAggregationTests, "kibana-visualize/pipeline_agg_req")
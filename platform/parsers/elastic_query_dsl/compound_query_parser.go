@@ -0,0 +1,611 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package elastic_query_dsl
+
+import (
+	"github.com/QuesmaOrg/quesma/platform/database_common"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"math"
+	"strconv"
+)
+
+// Compound queries (function_score, boosting, dis_max) are translated into a WHERE clause (which documents match)
+// and a score expression (how relevant each matching document is).
+//
+// We don't compute Lucene scores. Instead, every leaf query which doesn't produce its own score is assumed
+// to have a constant score of 1 for matching documents, and compound queries combine those scores
+// with the same arithmetic Elasticsearch uses. The result is deterministic and good enough to drive `ORDER BY _score`,
+// but the absolute values will differ from Elasticsearch's.
+
+const (
+	defaultNegativeBoost = 1.0
+	defaultDecay         = 0.5
+)
+
+// scoreOrConstant returns query's score, or 1 if query doesn't influence scoring
+func scoreOrConstant(query model.SimpleQuery) model.Expr {
+	if query.Score != nil {
+		return query.Score
+	}
+	return model.NewLiteral(1)
+}
+
+// scoreIfMatches returns `if(where, score, 0)`, which is the contribution of an optional clause (e.g. `should`) to the total score
+func scoreIfMatches(query model.SimpleQuery) model.Expr {
+	if query.WhereClause == nil {
+		return scoreOrConstant(query)
+	}
+	return model.NewFunction("if", query.WhereClause, scoreOrConstant(query), model.NewLiteral(0))
+}
+
+// sumScores returns sum of all non-nil scores, or nil if there are none
+func sumScores(scores []model.Expr) model.Expr {
+	var result model.Expr
+	for _, score := range model.FilterOutEmptyStatements(scores) {
+		if result == nil {
+			result = score
+		} else {
+			result = model.NewInfixExpr(result, "+", score)
+		}
+	}
+	if result == nil {
+		return nil
+	}
+	if _, isInfix := result.(model.InfixExpr); isInfix {
+		return model.NewParenExpr(result)
+	}
+	return result
+}
+
+func applyBoost(score model.Expr, boost float64) model.Expr {
+	if boost == 1 {
+		return score
+	}
+	return model.NewInfixExpr(score, "*", model.NewLiteral(boost))
+}
+
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/query-dsl-function-score-query.html
+// Supported: weight, field_value_factor, gauss/exp/linear decay functions, filters, score_mode, boost_mode,
+// max_boost, min_score and boost. Not supported: script_score and random_score (they're ignored with a warning).
+func (cw *ClickhouseQueryTranslator) parseFunctionScore(queryMap QueryMap) model.SimpleQuery {
+	innerQuery := model.NewSimpleQuery(nil, true)
+	if query, ok := queryMap["query"]; ok {
+		queryAsMap, ok := query.(QueryMap)
+		if !ok {
+			logger.WarnWithCtx(cw.Ctx).Msgf("invalid function_score query type: %T, value: %v", query, query)
+			return model.NewSimpleQueryInvalid()
+		}
+		innerQuery = cw.parseQueryMap(queryAsMap)
+		if !innerQuery.CanParse {
+			return innerQuery
+		}
+	}
+
+	// functions can be either listed in "functions" array, or (a single one) inlined into function_score itself
+	var functions []QueryMap
+	if functionsRaw, ok := queryMap["functions"]; ok {
+		functionsAsArray, ok := functionsRaw.([]any)
+		if !ok {
+			logger.WarnWithCtx(cw.Ctx).Msgf("invalid function_score functions type: %T, value: %v", functionsRaw, functionsRaw)
+			return model.NewSimpleQueryInvalid()
+		}
+		for _, function := range functionsAsArray {
+			if functionAsMap, ok := function.(QueryMap); ok {
+				functions = append(functions, functionAsMap)
+			} else {
+				logger.WarnWithCtx(cw.Ctx).Msgf("invalid function_score function type: %T, value: %v", function, function)
+				return model.NewSimpleQueryInvalid()
+			}
+		}
+	} else {
+		functions = append(functions, queryMap)
+	}
+
+	functionScores := make([]model.Expr, 0, len(functions))
+	filters := make([]model.Expr, 0, len(functions))
+	for _, function := range functions {
+		score, ok := cw.parseScoreFunction(function)
+		if !ok {
+			return model.NewSimpleQueryInvalid()
+		}
+		if score == nil {
+			continue
+		}
+		var filter model.Expr
+		if filterRaw, exists := function["filter"]; exists {
+			filterAsMap, ok := filterRaw.(QueryMap)
+			if !ok {
+				logger.WarnWithCtx(cw.Ctx).Msgf("invalid function_score filter type: %T, value: %v", filterRaw, filterRaw)
+				return model.NewSimpleQueryInvalid()
+			}
+			filterQuery := cw.parseQueryMap(filterAsMap)
+			if !filterQuery.CanParse {
+				return filterQuery
+			}
+			filter = filterQuery.WhereClause
+		}
+		functionScores = append(functionScores, score)
+		filters = append(filters, filter)
+	}
+
+	queryScore := scoreOrConstant(innerQuery)
+	score := queryScore
+	if len(functionScores) > 0 {
+		scoreMode := cw.parseStringParam(queryMap, "score_mode", "multiply")
+		functionScore, ok := cw.combineFunctionScores(functionScores, filters, scoreMode)
+		if !ok {
+			return model.NewSimpleQueryInvalid()
+		}
+		if maxBoost, exists := cw.parseFloatParam(queryMap, "max_boost"); exists {
+			functionScore = model.NewFunction("least", functionScore, model.NewLiteral(maxBoost))
+		}
+		boostMode := cw.parseStringParam(queryMap, "boost_mode", "multiply")
+		if boostMode == "multiply" && innerQuery.Score == nil {
+			boostMode = "replace" // query score is constant 1, so multiplying by it is a no-op
+		}
+		if score, ok = combineQueryAndFunctionScore(queryScore, functionScore, boostMode); !ok {
+			logger.WarnWithCtx(cw.Ctx).Msgf("unsupported function_score boost_mode: %s", boostMode)
+			return model.NewSimpleQueryInvalid()
+		}
+	}
+	if boost, exists := cw.parseFloatParam(queryMap, "boost"); exists {
+		score = applyBoost(score, boost)
+	}
+
+	whereClause := innerQuery.WhereClause
+	if minScore, exists := cw.parseFloatParam(queryMap, "min_score"); exists {
+		whereClause = model.And([]model.Expr{whereClause, model.NewInfixExpr(score, ">=", model.NewLiteral(minScore))})
+	}
+
+	result := model.NewSimpleQuery(whereClause, true)
+	result.Score = score
+	return result
+}
+
+// parseScoreFunction returns (score expression, true) for a single function of function_score query.
+// (nil, true) means function is not supported, but can be safely skipped.
+func (cw *ClickhouseQueryTranslator) parseScoreFunction(function QueryMap) (model.Expr, bool) {
+	var score model.Expr
+	for _, decayFunction := range []string{"gauss", "exp", "linear"} {
+		if params, exists := function[decayFunction]; exists {
+			paramsAsMap, ok := params.(QueryMap)
+			if !ok {
+				logger.WarnWithCtx(cw.Ctx).Msgf("invalid %s function type: %T, value: %v", decayFunction, params, params)
+				return nil, false
+			}
+			if score, ok = cw.parseDecayFunction(decayFunction, paramsAsMap); !ok {
+				return nil, false
+			}
+		}
+	}
+	if params, exists := function["field_value_factor"]; exists {
+		paramsAsMap, ok := params.(QueryMap)
+		if !ok {
+			logger.WarnWithCtx(cw.Ctx).Msgf("invalid field_value_factor type: %T, value: %v", params, params)
+			return nil, false
+		}
+		if score, ok = cw.parseFieldValueFactor(paramsAsMap); !ok {
+			return nil, false
+		}
+	}
+	for _, unsupported := range []string{"script_score", "random_score"} {
+		if _, exists := function[unsupported]; exists {
+			logger.WarnWithCtxAndReason(cw.Ctx, logger.ReasonUnsupportedQuery(unsupported)).Msgf("%s function is not supported, ignoring it", unsupported)
+		}
+	}
+
+	if weight, exists := cw.parseFloatParam(function, "weight"); exists {
+		if score == nil {
+			score = model.NewLiteral(weight)
+		} else {
+			score = model.NewInfixExpr(score, "*", model.NewLiteral(weight))
+		}
+	}
+	return score, true
+}
+
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/query-dsl-function-score-query.html#function-field-value-factor
+// modifier(factor * coalesce(field, missing))
+func (cw *ClickhouseQueryTranslator) parseFieldValueFactor(params QueryMap) (model.Expr, bool) {
+	fieldName, ok := params["field"].(string)
+	if !ok {
+		logger.WarnWithCtx(cw.Ctx).Msgf("field_value_factor: field is required, got: %v", params["field"])
+		return nil, false
+	}
+	var value model.Expr = model.NewColumnRef(ResolveField(cw.Ctx, fieldName, cw.Schema))
+	if missing, exists := cw.parseFloatParam(params, "missing"); exists {
+		value = model.NewFunction("coalesce", value, model.NewLiteral(missing))
+	}
+	if factor, exists := cw.parseFloatParam(params, "factor"); exists && factor != 1 {
+		value = model.NewInfixExpr(model.NewLiteral(factor), "*", value)
+	}
+
+	modifier := cw.parseStringParam(params, "modifier", "none")
+	plus := func(n int) model.Expr {
+		return model.NewInfixExpr(value, "+", model.NewLiteral(n))
+	}
+	switch modifier {
+	case "none":
+		return value, true
+	case "log":
+		return model.NewFunction("log10", value), true
+	case "log1p":
+		return model.NewFunction("log10", plus(1)), true
+	case "log2p":
+		return model.NewFunction("log10", plus(2)), true
+	case "ln":
+		return model.NewFunction("log", value), true
+	case "ln1p":
+		return model.NewFunction("log", plus(1)), true
+	case "ln2p":
+		return model.NewFunction("log", plus(2)), true
+	case "square":
+		return model.NewFunction("pow", value, model.NewLiteral(2)), true
+	case "sqrt":
+		return model.NewFunction("sqrt", value), true
+	case "reciprocal":
+		return model.NewInfixExpr(model.NewLiteral(1), "/", value), true
+	default:
+		logger.WarnWithCtx(cw.Ctx).Msgf("field_value_factor: unsupported modifier: %s", modifier)
+		return nil, false
+	}
+}
+
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/query-dsl-function-score-query.html#function-decay
+// With distance = max(0, |field - origin| - offset):
+//   - gauss:  exp(ln(decay) * distance^2 / scale^2)
+//   - exp:    exp(ln(decay) * distance / scale)
+//   - linear: max(0, 1 - (1 - decay) * distance / scale)
+//
+// Dates are compared as milliseconds since epoch, and scale/offset are then parsed as intervals (e.g. "10d").
+func (cw *ClickhouseQueryTranslator) parseDecayFunction(function string, params QueryMap) (model.Expr, bool) {
+	var fieldName string
+	var fieldParams QueryMap
+	for k, v := range params {
+		if k == "multi_value_mode" {
+			continue
+		}
+		asMap, ok := v.(QueryMap)
+		if !ok {
+			logger.WarnWithCtx(cw.Ctx).Msgf("%s: invalid params type for field %s: %T, value: %v", function, k, v, v)
+			return nil, false
+		}
+		fieldName, fieldParams = ResolveField(cw.Ctx, k, cw.Schema), asMap
+	}
+	if fieldParams == nil {
+		logger.WarnWithCtx(cw.Ctx).Msgf("%s: no field in decay function: %v", function, params)
+		return nil, false
+	}
+
+	var value, origin model.Expr
+	var scale, offset float64
+	var ok bool
+	switch dateType := cw.Table.GetDateTimeType(cw.Ctx, fieldName, false); dateType {
+	case database_common.DateTime, database_common.DateTime64:
+		if dateType == database_common.DateTime {
+			value = model.NewInfixExpr(model.NewFunction("toUnixTimestamp", model.NewColumnRef(fieldName)), "*", model.NewLiteral(1000))
+		} else {
+			value = model.NewFunction("toUnixTimestamp64Milli", model.NewColumnRef(fieldName))
+		}
+		if origin, ok = cw.parseDecayDateOrigin(fieldParams["origin"]); !ok {
+			return nil, false
+		}
+		if scale, ok = cw.parseDecayInterval(fieldParams["scale"]); !ok {
+			return nil, false
+		}
+		if _, exists := fieldParams["offset"]; exists {
+			if offset, ok = cw.parseDecayInterval(fieldParams["offset"]); !ok {
+				return nil, false
+			}
+		}
+	default:
+		value = model.NewColumnRef(fieldName)
+		originAsFloat, exists := cw.parseFloatParam(fieldParams, "origin")
+		if !exists {
+			logger.WarnWithCtx(cw.Ctx).Msgf("%s: origin is required for numeric field %s", function, fieldName)
+			return nil, false
+		}
+		origin = model.NewLiteral(originAsFloat)
+		if scale, ok = cw.parseFloatParam(fieldParams, "scale"); !ok {
+			logger.WarnWithCtx(cw.Ctx).Msgf("%s: scale is required for numeric field %s", function, fieldName)
+			return nil, false
+		}
+		offset, _ = cw.parseFloatParam(fieldParams, "offset")
+	}
+	if scale <= 0 {
+		logger.WarnWithCtx(cw.Ctx).Msgf("%s: scale must be positive, got: %v", function, scale)
+		return nil, false
+	}
+
+	decay := defaultDecay
+	if decayParam, exists := cw.parseFloatParam(fieldParams, "decay"); exists {
+		decay = decayParam
+	}
+	if decay <= 0 || decay >= 1 {
+		logger.WarnWithCtx(cw.Ctx).Msgf("%s: decay must be in (0, 1), got: %v", function, decay)
+		return nil, false
+	}
+
+	var distance model.Expr = model.NewFunction("abs", model.NewInfixExpr(value, "-", origin))
+	if offset != 0 {
+		distance = model.NewFunction("greatest", model.NewLiteral(0), model.NewInfixExpr(distance, "-", model.NewLiteral(offset)))
+	}
+
+	switch function {
+	case "gauss":
+		exponent := model.NewInfixExpr(model.NewLiteral(math.Log(decay)/(scale*scale)), "*", model.NewFunction("pow", distance, model.NewLiteral(2)))
+		return model.NewFunction("exp", exponent), true
+	case "exp":
+		exponent := model.NewInfixExpr(model.NewLiteral(math.Log(decay)/scale), "*", distance)
+		return model.NewFunction("exp", exponent), true
+	case "linear":
+		slope := model.NewInfixExpr(model.NewLiteral((1-decay)/scale), "*", distance)
+		return model.NewFunction("greatest", model.NewLiteral(0), model.NewInfixExpr(model.NewLiteral(1), "-", slope)), true
+	default:
+		logger.ErrorWithCtx(cw.Ctx).Msgf("unknown decay function: %s", function)
+		return nil, false
+	}
+}
+
+// parseDecayDateOrigin returns origin as milliseconds since epoch. Missing origin means "now", like in Elasticsearch.
+func (cw *ClickhouseQueryTranslator) parseDecayDateOrigin(origin any) (model.Expr, bool) {
+	if origin == nil || origin == "now" {
+		return model.NewFunction("toUnixTimestamp64Milli", model.NewFunction("now64", model.NewLiteral(3))), true
+	}
+	if originAsTime, ok := NewDateManager(cw.Ctx).parseStrictDateOptionalTimeOrEpochMillis(origin); ok {
+		return model.NewLiteral(originAsTime.UnixMilli()), true
+	}
+	logger.WarnWithCtx(cw.Ctx).Msgf("invalid date origin in decay function: %v", origin)
+	return nil, false
+}
+
+// parseDecayInterval returns interval (e.g. "10d") as milliseconds
+func (cw *ClickhouseQueryTranslator) parseDecayInterval(interval any) (float64, bool) {
+	intervalAsString, ok := interval.(string)
+	if !ok {
+		logger.WarnWithCtx(cw.Ctx).Msgf("invalid date interval in decay function: %v (%T)", interval, interval)
+		return 0, false
+	}
+	duration, err := util.ParseInterval(intervalAsString)
+	if err != nil {
+		logger.WarnWithCtx(cw.Ctx).Msgf("invalid date interval in decay function: %s, err: %v", intervalAsString, err)
+		return 0, false
+	}
+	return float64(duration.Milliseconds()), true
+}
+
+// combineFunctionScores combines scores of all functions according to score_mode.
+// Functions whose filter doesn't match a document don't take part in the computation.
+// If no function matches, the result is 1, like in Elasticsearch.
+func (cw *ClickhouseQueryTranslator) combineFunctionScores(scores, filters []model.Expr, scoreMode string) (model.Expr, bool) {
+	matches := func(i int) model.Expr {
+		if filters[i] == nil {
+			return model.TrueExpr
+		}
+		return filters[i]
+	}
+	ifMatches := func(i int, otherwise model.Expr) model.Expr {
+		if filters[i] == nil {
+			return scores[i]
+		}
+		return model.NewFunction("if", filters[i], scores[i], otherwise)
+	}
+	anyMatches := func() model.Expr {
+		for _, filter := range filters {
+			if filter == nil {
+				return nil // some function always matches
+			}
+		}
+		return model.Or(filters)
+	}
+	withDefault := func(score model.Expr) model.Expr {
+		if anyMatch := anyMatches(); anyMatch != nil {
+			return model.NewFunction("if", anyMatch, score, model.NewLiteral(1))
+		}
+		return score
+	}
+
+	if len(scores) == 1 {
+		return withDefault(ifMatches(0, model.NewLiteral(1))), true
+	}
+
+	args := make([]model.Expr, len(scores))
+	switch scoreMode {
+	case "multiply":
+		for i := range scores {
+			args[i] = ifMatches(i, model.NewLiteral(1))
+		}
+		return model.NewParenExpr(combineWithOperator(args, "*")), true
+	case "sum":
+		for i := range scores {
+			args[i] = ifMatches(i, model.NewLiteral(0))
+		}
+		return withDefault(model.NewParenExpr(combineWithOperator(args, "+"))), true
+	case "avg":
+		matchCount := make([]model.Expr, len(scores))
+		for i := range scores {
+			args[i] = ifMatches(i, model.NewLiteral(0))
+			matchCount[i] = model.NewFunction("toUInt8", matches(i))
+		}
+		avg := model.NewInfixExpr(model.NewParenExpr(combineWithOperator(args, "+")), "/",
+			model.NewFunction("greatest", model.NewLiteral(1), model.NewParenExpr(combineWithOperator(matchCount, "+"))))
+		return withDefault(avg), true
+	case "max":
+		for i := range scores {
+			args[i] = ifMatches(i, model.NewLiteral("-inf"))
+		}
+		return withDefault(model.NewFunction("greatest", args...)), true
+	case "min":
+		for i := range scores {
+			args[i] = ifMatches(i, model.NewLiteral("inf"))
+		}
+		return withDefault(model.NewFunction("least", args...)), true
+	case "first":
+		multiIfArgs := make([]model.Expr, 0, 2*len(scores)+1)
+		for i := range scores {
+			if filters[i] == nil {
+				if len(multiIfArgs) == 0 {
+					return scores[i], true
+				}
+				return model.NewFunction("multiIf", append(multiIfArgs, scores[i])...), true
+			}
+			multiIfArgs = append(multiIfArgs, filters[i], scores[i])
+		}
+		return model.NewFunction("multiIf", append(multiIfArgs, model.NewLiteral(1))...), true
+	default:
+		logger.WarnWithCtx(cw.Ctx).Msgf("unsupported function_score score_mode: %s", scoreMode)
+		return nil, false
+	}
+}
+
+func combineQueryAndFunctionScore(queryScore, functionScore model.Expr, boostMode string) (model.Expr, bool) {
+	switch boostMode {
+	case "multiply":
+		return model.NewInfixExpr(queryScore, "*", functionScore), true
+	case "replace":
+		return functionScore, true
+	case "sum":
+		return model.NewParenExpr(model.NewInfixExpr(queryScore, "+", functionScore)), true
+	case "avg":
+		return model.NewInfixExpr(model.NewParenExpr(model.NewInfixExpr(queryScore, "+", functionScore)), "/", model.NewLiteral(2)), true
+	case "max":
+		return model.NewFunction("greatest", queryScore, functionScore), true
+	case "min":
+		return model.NewFunction("least", queryScore, functionScore), true
+	default:
+		return nil, false
+	}
+}
+
+func combineWithOperator(exprs []model.Expr, operator string) model.Expr {
+	result := exprs[0]
+	for _, expr := range exprs[1:] {
+		result = model.NewInfixExpr(result, operator, expr)
+	}
+	return result
+}
+
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/query-dsl-boosting-query.html
+// Documents matching `positive` are returned, and those which also match `negative` have their score multiplied by `negative_boost`.
+func (cw *ClickhouseQueryTranslator) parseBoosting(queryMap QueryMap) model.SimpleQuery {
+	positiveRaw, ok := queryMap["positive"].(QueryMap)
+	if !ok {
+		logger.WarnWithCtx(cw.Ctx).Msgf("boosting: positive query is required, got: %v", queryMap["positive"])
+		return model.NewSimpleQueryInvalid()
+	}
+	negativeRaw, ok := queryMap["negative"].(QueryMap)
+	if !ok {
+		logger.WarnWithCtx(cw.Ctx).Msgf("boosting: negative query is required, got: %v", queryMap["negative"])
+		return model.NewSimpleQueryInvalid()
+	}
+
+	positive := cw.parseQueryMap(positiveRaw)
+	negative := cw.parseQueryMap(negativeRaw)
+	if !positive.CanParse || !negative.CanParse {
+		return model.NewSimpleQueryInvalid()
+	}
+
+	negativeBoost := defaultNegativeBoost
+	if boost, exists := cw.parseFloatParam(queryMap, "negative_boost"); exists {
+		negativeBoost = boost
+	}
+
+	score := positive.Score
+	if negative.WhereClause != nil && negativeBoost != 1 {
+		penalty := model.NewFunction("if", negative.WhereClause, model.NewLiteral(negativeBoost), model.NewLiteral(1))
+		if score == nil {
+			score = penalty
+		} else {
+			score = model.NewInfixExpr(score, "*", penalty)
+		}
+	}
+
+	result := model.NewSimpleQuery(positive.WhereClause, true)
+	result.Score = score
+	return result
+}
+
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/query-dsl-dis-max-query.html
+// score = max(score of matching queries) + tie_breaker * (sum(score of matching queries) - max(...))
+func (cw *ClickhouseQueryTranslator) parseDisMax(queryMap QueryMap) model.SimpleQuery {
+	queriesRaw, ok := queryMap["queries"].([]any)
+	if !ok {
+		logger.WarnWithCtx(cw.Ctx).Msgf("dis_max: queries array is required, got: %v", queryMap["queries"])
+		return model.NewSimpleQueryInvalid()
+	}
+	if len(queriesRaw) == 0 {
+		return model.NewSimpleQuery(model.FalseExpr, true)
+	}
+
+	whereClauses := make([]model.Expr, 0, len(queriesRaw))
+	scores := make([]model.Expr, 0, len(queriesRaw))
+	for _, queryRaw := range queriesRaw {
+		queryAsMap, ok := queryRaw.(QueryMap)
+		if !ok {
+			logger.WarnWithCtx(cw.Ctx).Msgf("dis_max: invalid query type: %T, value: %v", queryRaw, queryRaw)
+			return model.NewSimpleQueryInvalid()
+		}
+		query := cw.parseQueryMap(queryAsMap)
+		if !query.CanParse {
+			return query
+		}
+		if query.WhereClause == nil {
+			whereClauses = append(whereClauses, model.TrueExpr)
+		} else {
+			whereClauses = append(whereClauses, query.WhereClause)
+		}
+		scores = append(scores, scoreIfMatches(query))
+	}
+
+	var score model.Expr
+	if len(scores) == 1 {
+		score = scores[0]
+	} else {
+		score = model.NewFunction("greatest", scores...)
+		if tieBreaker, exists := cw.parseFloatParam(queryMap, "tie_breaker"); exists && tieBreaker != 0 {
+			rest := model.NewParenExpr(model.NewInfixExpr(sumScores(scores), "-", score))
+			score = model.NewInfixExpr(score, "+", model.NewInfixExpr(model.NewLiteral(tieBreaker), "*", rest))
+		}
+	}
+	if boost, exists := cw.parseFloatParam(queryMap, "boost"); exists {
+		score = applyBoost(score, boost)
+	}
+
+	result := model.NewSimpleQuery(model.Or(whereClauses), true)
+	result.Score = score
+	return result
+}
+
+// parseFloatParam returns (value, true) if param exists in queryMap and is a number (or a string containing a number)
+func (cw *ClickhouseQueryTranslator) parseFloatParam(queryMap QueryMap, paramName string) (float64, bool) {
+	valueRaw, exists := queryMap[paramName]
+	if !exists {
+		return 0, false
+	}
+	switch value := valueRaw.(type) {
+	case float64:
+		return value, true
+	case int:
+		return float64(value), true
+	case string:
+		if valueAsFloat, err := strconv.ParseFloat(value, 64); err == nil {
+			return valueAsFloat, true
+		}
+	}
+	logger.WarnWithCtx(cw.Ctx).Msgf("invalid %s type: %T, value: %v. Expected number", paramName, valueRaw, valueRaw)
+	return 0, false
+}
+
+func (cw *ClickhouseQueryTranslator) parseStringParam(queryMap QueryMap, paramName, defaultValue string) string {
+	valueRaw, exists := queryMap[paramName]
+	if !exists {
+		return defaultValue
+	}
+	if value, ok := valueRaw.(string); ok {
+		return value
+	}
+	logger.WarnWithCtx(cw.Ctx).Msgf("invalid %s type: %T, value: %v. Expected string", paramName, valueRaw, valueRaw)
+	return defaultValue
+}
@@ -0,0 +1,182 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package elastic_query_dsl
+
+import (
+	"context"
+	"github.com/QuesmaOrg/quesma/platform/database_common"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_parseCompoundQueries(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         QueryMap
+		expectedWhere string
+		expectedScore string
+	}{
+		{
+			"function_score with field_value_factor",
+			QueryMap{
+				"function_score": QueryMap{
+					"query": QueryMap{"term": QueryMap{"message": "error"}},
+					"field_value_factor": QueryMap{
+						"field":    "bytes",
+						"factor":   1.2,
+						"modifier": "log1p",
+						"missing":  1.0,
+					},
+				},
+			},
+			`"message"='error'`,
+			`log10(1.2*coalesce("bytes",1)+1)`,
+		},
+		{
+			"function_score with filtered weights, score_mode sum, boost_mode replace, min_score",
+			QueryMap{
+				"function_score": QueryMap{
+					"functions": []any{
+						QueryMap{"filter": QueryMap{"term": QueryMap{"message": "error"}}, "weight": 3.0},
+						QueryMap{"filter": QueryMap{"term": QueryMap{"message": "warn"}}, "weight": 2.0},
+					},
+					"score_mode": "sum",
+					"boost_mode": "replace",
+					"min_score":  2.0,
+				},
+			},
+			`if(("message"='error' OR "message"='warn'),(if("message"='error',3,0)+if("message"='warn',2,0)),1)>=2`,
+			`if(("message"='error' OR "message"='warn'),(if("message"='error',3,0)+if("message"='warn',2,0)),1)`,
+		},
+		{
+			"function_score with numeric gauss decay",
+			QueryMap{
+				"function_score": QueryMap{
+					"gauss":      QueryMap{"bytes": QueryMap{"origin": 100.0, "scale": 10.0, "offset": 5.0}},
+					"boost_mode": "replace",
+				},
+			},
+			``,
+			`exp(-0.006931471805599453*pow(greatest(0,abs("bytes"-100)-5),2))`,
+		},
+		{
+			"function_score with date linear decay",
+			QueryMap{
+				"function_score": QueryMap{
+					"linear":     QueryMap{"@timestamp": QueryMap{"origin": "2024-01-01T00:00:00.000Z", "scale": "1d", "decay": 0.2}},
+					"boost_mode": "replace",
+				},
+			},
+			``,
+			`greatest(0,1-9.259259259259259e-09*abs(toUnixTimestamp64Milli("@timestamp")-1704067200000))`,
+		},
+		{
+			"boosting",
+			QueryMap{
+				"boosting": QueryMap{
+					"positive":       QueryMap{"term": QueryMap{"message": "error"}},
+					"negative":       QueryMap{"term": QueryMap{"host": "test"}},
+					"negative_boost": 0.5,
+				},
+			},
+			`"message"='error'`,
+			`if("host"='test',0.5,1)`,
+		},
+		{
+			"dis_max with tie_breaker",
+			QueryMap{
+				"dis_max": QueryMap{
+					"queries": []any{
+						QueryMap{"term": QueryMap{"message": "error"}},
+						QueryMap{"term": QueryMap{"host": "test"}},
+					},
+					"tie_breaker": 0.7,
+				},
+			},
+			`("message"='error' OR "host"='test')`,
+			`greatest(if("message"='error',1,0),if("host"='test',1,0))+0.7*((if("message"='error',1,0)+if("host"='test',1,0))-greatest(if("message"='error',1,0),if("host"='test',1,0)))`,
+		},
+		{
+			"bool sums scores of must and should clauses",
+			QueryMap{
+				"bool": QueryMap{
+					"must": []any{
+						QueryMap{"function_score": QueryMap{"weight": 2.0, "query": QueryMap{"term": QueryMap{"message": "error"}}}},
+					},
+					"filter": []any{QueryMap{"term": QueryMap{"host": "test"}}},
+					"should": []any{QueryMap{"term": QueryMap{"message": "warn"}}},
+				},
+			},
+			`("message"='error' AND "host"='test')`,
+			`(2+if("message"='warn',1,0))`,
+		},
+		{
+			"bool without scoring clauses has no score",
+			QueryMap{
+				"bool": QueryMap{
+					"must": []any{QueryMap{"term": QueryMap{"message": "error"}}},
+				},
+			},
+			`"message"='error'`,
+			``,
+		},
+	}
+
+	table := database_common.Table{
+		Name: tableName,
+		Cols: map[string]*database_common.Column{
+			"message":    {Name: "message", Type: database_common.NewBaseType("String")},
+			"host":       {Name: "host", Type: database_common.NewBaseType("String")},
+			"bytes":      {Name: "bytes", Type: database_common.NewBaseType("Int64")},
+			"@timestamp": {Name: "@timestamp", Type: database_common.NewBaseType("DateTime64")},
+		},
+		Config: database_common.NewChTableConfigNoAttrs(),
+	}
+	cw := ClickhouseQueryTranslator{Table: &table, Ctx: context.Background()}
+	for i, tt := range tests {
+		t.Run(util.PrettyTestName(tt.name, i), func(t *testing.T) {
+			simpleQuery := cw.parseQueryMap(tt.query)
+			assert.True(t, simpleQuery.CanParse)
+			assert.Equal(t, tt.expectedWhere, simpleQuery.WhereClauseAsString())
+			if tt.expectedScore == "" {
+				assert.Nil(t, simpleQuery.Score)
+			} else {
+				assert.Equal(t, tt.expectedScore, model.AsString(simpleQuery.Score))
+			}
+		})
+	}
+}
+
+func Test_sortByScore(t *testing.T) {
+	table := database_common.Table{
+		Name: tableName,
+		Cols: map[string]*database_common.Column{
+			"message":    {Name: "message", Type: database_common.NewBaseType("String")},
+			"@timestamp": {Name: "@timestamp", Type: database_common.NewBaseType("DateTime64")},
+		},
+		Config: database_common.NewChTableConfigNoAttrs(),
+	}
+	cw := ClickhouseQueryTranslator{Table: &table, Ctx: context.Background()}
+
+	withScore := QueryMap{
+		"query": QueryMap{"dis_max": QueryMap{"queries": []any{QueryMap{"term": QueryMap{"message": "error"}}}}},
+		"sort":  []any{QueryMap{"_score": QueryMap{}}, QueryMap{"@timestamp": "asc"}},
+	}
+	simpleQuery, _, _, err := cw.parseQueryInternal(withScore)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.OrderByExpr{
+		model.NewOrderByExpr(simpleQuery.Score, model.DescOrder),
+		model.NewSortColumn("@timestamp", model.AscOrder),
+	}, simpleQuery.OrderBy)
+	assert.Equal(t, []string{"_score", "@timestamp"}, simpleQuery.SortFieldNames)
+
+	withoutScore := QueryMap{
+		"query": QueryMap{"term": QueryMap{"message": "error"}},
+		"sort":  []any{QueryMap{"_score": "desc"}, QueryMap{"@timestamp": "asc"}},
+	}
+	simpleQuery, _, _, err = cw.parseQueryInternal(withoutScore)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.OrderByExpr{model.NewSortColumn("@timestamp", model.AscOrder)}, simpleQuery.OrderBy)
+}
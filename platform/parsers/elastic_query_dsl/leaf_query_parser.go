@@ -0,0 +1,367 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package elastic_query_dsl
+
+import (
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"maps"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Leaf queries used mostly by search-as-you-type boxes and typo-tolerant search:
+// match_bool_prefix, match_phrase_prefix, fuzzy and terms_set.
+//
+// Elasticsearch runs them against analyzed tokens of text fields, and against the whole value of keyword fields.
+// We do the same: for full-text fields we split the value into tokens with `splitByNonAlpha(lower(field))`,
+// which is close to what the standard analyzer does, and for every other field we use the value as is.
+//
+// `max_expansions` limits how many index terms Elasticsearch expands a prefix/fuzzy term to.
+// We don't have a terms dictionary, so every term matching the prefix/edit distance matches. That gives
+// the same result as Elasticsearch, unless there are more than `max_expansions` such terms.
+
+const termsSetNumTermsParam = "num_terms"
+
+const (
+	fuzzinessAuto            = "AUTO"
+	fuzzinessAutoLowDefault  = 3
+	fuzzinessAutoHighDefault = 6
+	fuzzinessMax             = 2 // Elasticsearch doesn't allow bigger edit distances
+)
+
+// match_phrase_prefix: like match_phrase, but the last term of the phrase is a prefix.
+// E.g. "quick brown f" matches "the quick brown fox".
+// Params other than `query` (`slop`, `analyzer`, `zero_terms_query`) are not supported.
+func (cw *ClickhouseQueryTranslator) parseMatchPhrasePrefix(queryMap QueryMap) model.SimpleQuery {
+	fieldName, params, ok := cw.parseFullTextLeafQuery(queryMap, "match_phrase_prefix")
+	if !ok {
+		return model.NewSimpleQueryInvalid()
+	}
+	phrase, ok := params["query"].(string)
+	if !ok {
+		logger.WarnWithCtx(cw.Ctx).Msgf("invalid query type in match_phrase_prefix: %T, value: %v", params["query"], params["query"])
+		return model.NewSimpleQueryInvalid()
+	}
+	if !cw.validMaxExpansions(params) {
+		return model.NewSimpleQueryInvalid()
+	}
+
+	escapeType := model.NotEscapedLikePrefix
+	if cw.isFullTextField(fieldName) {
+		escapeType = model.NotEscapedLikeFull
+	}
+	result := model.NewSimpleQuery(model.NewInfixExpr(model.NewColumnRef(fieldName), "iLIKE", model.NewLiteralWithEscapeType(phrase, escapeType)), true)
+	result.Score = cw.matchScore(fieldName, phrase)
+	return result
+}
+
+// match_bool_prefix: every term of the query becomes a term query, apart from the last one, which is a prefix query.
+// They are combined with `operator` (default: or). E.g. "quick brown f" matches "brown fox" and "fast quick".
+// For non full-text fields, the whole query is a prefix of the field value (as Elasticsearch uses keyword analyzer there).
+func (cw *ClickhouseQueryTranslator) parseMatchBoolPrefix(queryMap QueryMap) model.SimpleQuery {
+	fieldName, params, ok := cw.parseFullTextLeafQuery(queryMap, "match_bool_prefix")
+	if !ok {
+		return model.NewSimpleQueryInvalid()
+	}
+	query, ok := params["query"].(string)
+	if !ok {
+		logger.WarnWithCtx(cw.Ctx).Msgf("invalid query type in match_bool_prefix: %T, value: %v", params["query"], params["query"])
+		return model.NewSimpleQueryInvalid()
+	}
+	if !cw.validMaxExpansions(params) {
+		return model.NewSimpleQueryInvalid()
+	}
+
+	if !cw.isFullTextField(fieldName) {
+		whereClause := model.NewInfixExpr(model.NewColumnRef(fieldName), "iLIKE", model.NewLiteralWithEscapeType(query, model.NotEscapedLikePrefix))
+		return model.NewSimpleQuery(whereClause, true)
+	}
+
+	terms := strings.FieldsFunc(query, isNotTokenChar)
+	if len(terms) == 0 {
+		return model.NewSimpleQuery(model.FalseExpr, true)
+	}
+	statements := make([]model.Expr, 0, len(terms))
+	scores := make([]model.Expr, 0, len(terms))
+	for i, term := range terms {
+		term = strings.ToLower(term)
+		var termMatches model.Expr
+		if i == len(terms)-1 {
+			termMatches = model.NewFunction("startsWith", model.NewLiteral(tokenLambdaArg), model.NewLiteral(util.SingleQuote(term)))
+		} else {
+			termMatches = model.NewInfixExpr(model.NewLiteral(tokenLambdaArg), "=", model.NewLiteral(util.SingleQuote(term)))
+		}
+		statements = append(statements, anyTokenMatches(fieldName, termMatches))
+		scores = append(scores, cw.matchScore(fieldName, term))
+	}
+
+	var whereClause model.Expr
+	switch operator := strings.ToLower(cw.parseStringParam(params, "operator", "or")); operator {
+	case "or":
+		whereClause = model.Or(statements)
+	case "and":
+		whereClause = model.And(statements)
+	default:
+		logger.WarnWithCtx(cw.Ctx).Msgf("invalid operator in match_bool_prefix: %s", operator)
+		return model.NewSimpleQueryInvalid()
+	}
+	result := model.NewSimpleQuery(whereClause, true)
+	result.Score = sumScores(scores)
+	return result
+}
+
+// fuzzy: matches terms within `fuzziness` edits (Levenshtein distance, or Damerau-Levenshtein if `transpositions`, default)
+// of `value`. First `prefix_length` characters have to match exactly.
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/query-dsl-fuzzy-query.html
+func (cw *ClickhouseQueryTranslator) parseFuzzy(queryMap QueryMap) model.SimpleQuery {
+	if len(queryMap) != 1 {
+		logger.WarnWithCtx(cw.Ctx).Msgf("we expect only 1 fuzzy, got: %d. value: %v", len(queryMap), queryMap)
+		return model.NewSimpleQueryInvalid()
+	}
+
+	for fieldName, v := range queryMap {
+		fieldName = ResolveField(cw.Ctx, fieldName, cw.Schema)
+		params, ok := v.(QueryMap)
+		if !ok {
+			params = QueryMap{"value": v}
+		}
+		value, ok := params["value"].(string)
+		if !ok {
+			logger.WarnWithCtx(cw.Ctx).Msgf("invalid value type in fuzzy query: %T, value: %v", params["value"], params["value"])
+			return model.NewSimpleQueryInvalid()
+		}
+
+		maxEdits, err := parseFuzziness(params["fuzziness"], value)
+		if err != nil {
+			logger.WarnWithCtx(cw.Ctx).Msgf("invalid fuzziness in fuzzy query: %v", err)
+			return model.NewSimpleQueryInvalid()
+		}
+		prefixLength := 0
+		if prefixLengthRaw, exists := cw.parseFloatParam(params, "prefix_length"); exists {
+			if prefixLengthRaw < 0 {
+				logger.WarnWithCtx(cw.Ctx).Msgf("prefix_length in fuzzy query can't be negative, got: %v", prefixLengthRaw)
+				return model.NewSimpleQueryInvalid()
+			}
+			prefixLength = int(prefixLengthRaw)
+		}
+		if !cw.validMaxExpansions(params) {
+			return model.NewSimpleQueryInvalid()
+		}
+		transpositions := true
+		if transpositionsRaw, exists := params["transpositions"]; exists {
+			if transpositions, ok = transpositionsRaw.(bool); !ok {
+				logger.WarnWithCtx(cw.Ctx).Msgf("invalid transpositions type in fuzzy query: %T, value: %v", transpositionsRaw, transpositionsRaw)
+				return model.NewSimpleQueryInvalid()
+			}
+		}
+
+		if cw.isFullTextField(fieldName) {
+			token := model.NewLiteral(tokenLambdaArg)
+			return model.NewSimpleQuery(anyTokenMatches(fieldName, fuzzyMatch(token, value, maxEdits, prefixLength, transpositions)), true)
+		}
+		return model.NewSimpleQuery(fuzzyMatch(model.NewColumnRef(fieldName), value, maxEdits, prefixLength, transpositions), true)
+	}
+
+	// unreachable unless something really weird happens
+	logger.ErrorWithCtx(cw.Ctx).Msg("theoretically unreachable code")
+	return model.NewSimpleQueryInvalid()
+}
+
+// terms_set: matches documents which contain at least `minimum_should_match` of provided `terms`.
+// `minimum_should_match` can be a constant, another field (`minimum_should_match_field`),
+// or a script (`minimum_should_match_script`), which is lowered to SQL like scripts of `script` queries (see LowerPainless),
+// e.g. `Math.min(params.num_terms, doc['required_matches'].value)`.
+func (cw *ClickhouseQueryTranslator) parseTermsSet(queryMap QueryMap) model.SimpleQuery {
+	if len(queryMap) != 1 {
+		logger.WarnWithCtx(cw.Ctx).Msgf("we expect only 1 terms_set, got: %d. value: %v", len(queryMap), queryMap)
+		return model.NewSimpleQueryInvalid()
+	}
+
+	for fieldName, v := range queryMap {
+		fieldName = ResolveField(cw.Ctx, fieldName, cw.Schema)
+		params, ok := v.(QueryMap)
+		if !ok {
+			logger.WarnWithCtx(cw.Ctx).Msgf("invalid terms_set type: %T, value: %v", v, v)
+			return model.NewSimpleQueryInvalid()
+		}
+		terms, ok := params["terms"].([]any)
+		if !ok {
+			logger.WarnWithCtx(cw.Ctx).Msgf("invalid terms type in terms_set query: %T, value: %v", params["terms"], params["terms"])
+			return model.NewSimpleQueryInvalid()
+		}
+		if len(terms) == 0 {
+			return model.NewSimpleQuery(model.FalseExpr, true)
+		}
+
+		var minimumShouldMatch model.Expr
+		if field, exists := params["minimum_should_match_field"]; exists {
+			fieldAsString, ok := field.(string)
+			if !ok {
+				logger.WarnWithCtx(cw.Ctx).Msgf("invalid minimum_should_match_field type: %T, value: %v", field, field)
+				return model.NewSimpleQueryInvalid()
+			}
+			minimumShouldMatch = model.NewColumnRef(ResolveField(cw.Ctx, fieldAsString, cw.Schema))
+		} else if script, exists := params["minimum_should_match_script"]; exists {
+			source, scriptParams, err := cw.parseScriptDefinition(script)
+			if err != nil {
+				return cw.scriptError(err)
+			}
+			// like in Elasticsearch, the script can use the number of terms as `params.num_terms`
+			scriptParams = maps.Clone(scriptParams)
+			if scriptParams == nil {
+				scriptParams = make(map[string]any)
+			}
+			scriptParams[termsSetNumTermsParam] = len(terms)
+			if minimumShouldMatch, err = LowerPainless(source, cw.Schema, scriptParams); err != nil {
+				return cw.scriptError(err)
+			}
+		} else if minimum, exists := cw.parseFloatParam(params, "minimum_should_match"); exists {
+			minimumShouldMatch = model.NewLiteral(int(minimum))
+		} else {
+			logger.WarnWithCtx(cw.Ctx).Msgf("no minimum_should_match_field or minimum_should_match_script in terms_set query: %v", params)
+			return model.NewSimpleQueryInvalid()
+		}
+		return model.NewSimpleQuery(model.NewInfixExpr(cw.termsSetMatchCount(fieldName, terms), ">=", minimumShouldMatch), true)
+	}
+
+	// unreachable unless something really weird happens
+	logger.ErrorWithCtx(cw.Ctx).Msg("theoretically unreachable code")
+	return model.NewSimpleQueryInvalid()
+}
+
+// termsSetMatchCount returns how many of the terms are present in the field.
+// For arrays, it's e.g. `has("tags",'a')+has("tags",'b')`, for scalar columns it's `("tag" IN ('a','b'))` (0 or 1).
+func (cw *ClickhouseQueryTranslator) termsSetMatchCount(fieldName string, terms []any) model.Expr {
+	if column, exists := cw.Table.Cols[fieldName]; exists && strings.HasPrefix(column.Type.String(), "Array(") {
+		hasTerms := make([]model.Expr, len(terms))
+		for i, term := range terms {
+			hasTerms[i] = model.NewFunction("has", model.NewColumnRef(fieldName), model.NewLiteral(sprint(term)))
+		}
+		return sumScores(hasTerms)
+	}
+	values := make([]model.Expr, len(terms))
+	for i, term := range terms {
+		values[i] = model.NewLiteral(sprint(term))
+	}
+	return model.NewParenExpr(model.NewInfixExpr(model.NewColumnRef(fieldName), "IN", model.NewTupleExpr(values...)))
+}
+
+// parseFullTextLeafQuery parses `{"field": "query"}` or `{"field": {"query": "query", ...params}}`,
+// returning (resolved field name, params (always with "query" key), ok)
+func (cw *ClickhouseQueryTranslator) parseFullTextLeafQuery(queryMap QueryMap, queryType string) (fieldName string, params QueryMap, ok bool) {
+	if len(queryMap) != 1 {
+		logger.WarnWithCtx(cw.Ctx).Msgf("we expect only 1 %s, got: %d. value: %v", queryType, len(queryMap), queryMap)
+		return "", nil, false
+	}
+	for field, v := range queryMap {
+		fieldName = ResolveField(cw.Ctx, field, cw.Schema)
+		if params, ok = v.(QueryMap); !ok {
+			params = QueryMap{"query": v}
+		}
+	}
+	return fieldName, params, true
+}
+
+// validMaxExpansions validates `max_expansions`. Valid value is ignored, as explained at the top of this file.
+func (cw *ClickhouseQueryTranslator) validMaxExpansions(params QueryMap) bool {
+	maxExpansions, exists := cw.parseFloatParam(params, "max_expansions")
+	if !exists {
+		return true
+	}
+	if maxExpansions <= 0 {
+		logger.WarnWithCtx(cw.Ctx).Msgf("max_expansions must be positive, got: %v", maxExpansions)
+		return false
+	}
+	logger.WarnWithCtx(cw.Ctx).Msgf("max_expansions (%v) is ignored, all terms matching the prefix or fuzziness match", maxExpansions)
+	return true
+}
+
+// isFullTextField returns true <=> field (internal name) is analyzed into tokens by Elasticsearch (e.g. `text`)
+func (cw *ClickhouseQueryTranslator) isFullTextField(fieldName string) bool {
+	if field, found := cw.Schema.ResolveFieldByInternalName(fieldName); found {
+		return field.Type.IsFullText()
+	}
+	return false
+}
+
+const tokenLambdaArg = "token"
+
+// anyTokenMatches returns `arrayExists((token) -> termMatches, splitByNonAlpha(lower(field)))`
+func anyTokenMatches(fieldName string, termMatches model.Expr) model.Expr {
+	tokens := model.NewFunction("splitByNonAlpha", model.NewFunction("lower", model.NewColumnRef(fieldName)))
+	return model.NewFunction("arrayExists", model.NewLambdaExpr([]string{tokenLambdaArg}, termMatches), tokens)
+}
+
+func isNotTokenChar(r rune) bool {
+	return !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > utf8.RuneSelf)
+}
+
+// fuzzyMatch returns SQL checking if term is within maxEdits edits of value, e.g.
+// `startsWith(term,'ki') AND damerauLevenshteinDistance(term,'kitty')<=2`
+func fuzzyMatch(term model.Expr, value string, maxEdits, prefixLength int, transpositions bool) model.Expr {
+	valueLiteral := model.NewLiteral(util.SingleQuote(value))
+	if maxEdits == 0 || prefixLength >= utf8.RuneCountInString(value) {
+		return model.NewInfixExpr(term, "=", valueLiteral)
+	}
+	distanceFunction := "editDistance"
+	if transpositions {
+		distanceFunction = "damerauLevenshteinDistance"
+	}
+	withinDistance := model.NewInfixExpr(model.NewFunction(distanceFunction, term, valueLiteral), "<=", model.NewLiteral(maxEdits))
+	if prefixLength == 0 {
+		return withinDistance
+	}
+	prefix := string([]rune(value)[:prefixLength])
+	return model.And([]model.Expr{model.NewFunction("startsWith", term, model.NewLiteral(util.SingleQuote(prefix))), withinDistance})
+}
+
+// parseFuzziness returns max number of edits allowed for value.
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/common-options.html#fuzziness
+func parseFuzziness(fuzzinessRaw any, value string) (int, error) {
+	autoFuzziness := func(low, high int) int {
+		switch length := utf8.RuneCountInString(value); {
+		case length < low:
+			return 0
+		case length < high:
+			return 1
+		default:
+			return 2
+		}
+	}
+
+	switch fuzziness := fuzzinessRaw.(type) {
+	case nil:
+		return autoFuzziness(fuzzinessAutoLowDefault, fuzzinessAutoHighDefault), nil
+	case float64:
+		if fuzziness < 0 || fuzziness > fuzzinessMax || fuzziness != float64(int(fuzziness)) {
+			return 0, fmt.Errorf("fuzziness must be 0, 1 or 2, got: %v", fuzziness)
+		}
+		return int(fuzziness), nil
+	case string:
+		if strings.EqualFold(fuzziness, fuzzinessAuto) {
+			return autoFuzziness(fuzzinessAutoLowDefault, fuzzinessAutoHighDefault), nil
+		}
+		if strings.HasPrefix(strings.ToUpper(fuzziness), fuzzinessAuto+":") {
+			bounds := strings.Split(fuzziness[len(fuzzinessAuto)+1:], ",")
+			if len(bounds) != 2 {
+				return 0, fmt.Errorf("invalid fuzziness: %s", fuzziness)
+			}
+			low, errLow := strconv.Atoi(strings.TrimSpace(bounds[0]))
+			high, errHigh := strconv.Atoi(strings.TrimSpace(bounds[1]))
+			if errLow != nil || errHigh != nil || low > high {
+				return 0, fmt.Errorf("invalid fuzziness: %s", fuzziness)
+			}
+			return autoFuzziness(low, high), nil
+		}
+		if asNumber, err := strconv.ParseFloat(fuzziness, 64); err == nil {
+			return parseFuzziness(asNumber, value)
+		}
+		return 0, fmt.Errorf("invalid fuzziness: %s", fuzziness)
+	default:
+		return 0, fmt.Errorf("invalid fuzziness type: %T, value: %v", fuzzinessRaw, fuzzinessRaw)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package elastic_query_dsl
+
+import (
+	"context"
+	"github.com/QuesmaOrg/quesma/platform/database_common"
+	"github.com/QuesmaOrg/quesma/platform/schema"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_parseLeafQueries(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         QueryMap
+		expectedWhere string // empty <=> query can't be parsed
+	}{
+		{
+			"match_phrase_prefix on text field",
+			QueryMap{"match_phrase_prefix": QueryMap{"message": "quick brown f"}},
+			`"message" iLIKE '%quick brown f%'`,
+		},
+		{
+			"match_phrase_prefix on keyword field",
+			QueryMap{"match_phrase_prefix": QueryMap{"host": QueryMap{"query": "web-0", "max_expansions": 10.0}}},
+			`"host" iLIKE 'web-0%'`,
+		},
+		{
+			"match_phrase_prefix with invalid max_expansions",
+			QueryMap{"match_phrase_prefix": QueryMap{"host": QueryMap{"query": "web-0", "max_expansions": 0.0}}},
+			``,
+		},
+		{
+			"match_bool_prefix on text field",
+			QueryMap{"match_bool_prefix": QueryMap{"message": "Quick, brown f"}},
+			`((arrayExists((token) -> token='quick',splitByNonAlpha(lower("message"))) OR ` +
+				`arrayExists((token) -> token='brown',splitByNonAlpha(lower("message")))) OR ` +
+				`arrayExists((token) -> startsWith(token,'f'),splitByNonAlpha(lower("message"))))`,
+		},
+		{
+			"match_bool_prefix with operator and",
+			QueryMap{"match_bool_prefix": QueryMap{"message": QueryMap{"query": "brown f", "operator": "and"}}},
+			`(arrayExists((token) -> token='brown',splitByNonAlpha(lower("message"))) AND ` +
+				`arrayExists((token) -> startsWith(token,'f'),splitByNonAlpha(lower("message"))))`,
+		},
+		{
+			"match_bool_prefix on keyword field",
+			QueryMap{"match_bool_prefix": QueryMap{"host": "web"}},
+			`"host" iLIKE 'web%'`,
+		},
+		{
+			"fuzzy on keyword field, default (AUTO) fuzziness",
+			QueryMap{"fuzzy": QueryMap{"host": "kitten"}},
+			`damerauLevenshteinDistance("host",'kitten')<=2`,
+		},
+		{
+			"fuzzy with fuzziness, prefix_length and no transpositions",
+			QueryMap{"fuzzy": QueryMap{"host": QueryMap{"value": "kitten", "fuzziness": "1", "prefix_length": 2.0, "transpositions": false, "max_expansions": 50.0}}},
+			`(startsWith("host",'ki') AND editDistance("host",'kitten')<=1)`,
+		},
+		{
+			"fuzzy with AUTO:low,high fuzziness on short term",
+			QueryMap{"fuzzy": QueryMap{"host": QueryMap{"value": "ab", "fuzziness": "AUTO:3,6"}}},
+			`"host"='ab'`,
+		},
+		{
+			"fuzzy on text field",
+			QueryMap{"fuzzy": QueryMap{"message": QueryMap{"value": "quikc", "fuzziness": 1.0}}},
+			`arrayExists((token) -> damerauLevenshteinDistance(token,'quikc')<=1,splitByNonAlpha(lower("message")))`,
+		},
+		{
+			"fuzzy with invalid fuzziness",
+			QueryMap{"fuzzy": QueryMap{"host": QueryMap{"value": "kitten", "fuzziness": 3.0}}},
+			``,
+		},
+		{
+			"terms_set with minimum_should_match_field on array",
+			QueryMap{"terms_set": QueryMap{"tags": QueryMap{"terms": []any{"a", "b", "c"}, "minimum_should_match_field": "required_matches"}}},
+			`(has("tags",'a')+has("tags",'b')+has("tags",'c'))>="required_matches"`,
+		},
+		{
+			"terms_set with minimum_should_match_script",
+			QueryMap{"terms_set": QueryMap{"tags": QueryMap{
+				"terms":                       []any{"a", "b", "c"},
+				"minimum_should_match_script": QueryMap{"source": "Math.min(params.num_terms, doc['required_matches'].value)"},
+			}}},
+			`(has("tags",'a')+has("tags",'b')+has("tags",'c'))>=least(3,"required_matches")`,
+		},
+		{
+			"terms_set with minimum_should_match_script using params, on scalar field",
+			QueryMap{"terms_set": QueryMap{"host": QueryMap{
+				"terms":                       []any{"a", "b"},
+				"minimum_should_match_script": QueryMap{"source": "params.min", "params": QueryMap{"min": 1.0}},
+			}}},
+			`("host" IN tuple('a', 'b'))>=1`,
+		},
+		{
+			"terms_set with minimum_should_match_script using arithmetic",
+			QueryMap{"terms_set": QueryMap{"tags": QueryMap{
+				"terms":                       []any{"a", "b"},
+				"minimum_should_match_script": QueryMap{"source": "return Math.max(1, params['num_terms'] - 1);"},
+			}}},
+			`(has("tags",'a')+has("tags",'b'))>=greatest(1,(2-1))`,
+		},
+		{
+			"terms_set with unsupported script",
+			QueryMap{"terms_set": QueryMap{"tags": QueryMap{
+				"terms":                       []any{"a"},
+				"minimum_should_match_script": QueryMap{"source": "doc['x'].size() * 2"},
+			}}},
+			``,
+		},
+	}
+
+	table := database_common.Table{
+		Name: tableName,
+		Cols: map[string]*database_common.Column{
+			"message":          {Name: "message", Type: database_common.NewBaseType("String")},
+			"host":             {Name: "host", Type: database_common.NewBaseType("String")},
+			"tags":             {Name: "tags", Type: database_common.CompoundType{Name: "Array", BaseType: database_common.NewBaseType("String")}},
+			"required_matches": {Name: "required_matches", Type: database_common.NewBaseType("Int64")},
+		},
+		Config: database_common.NewChTableConfigNoAttrs(),
+	}
+	currentSchema := schema.Schema{Fields: map[schema.FieldName]schema.Field{
+		"message":          {PropertyName: "message", InternalPropertyName: "message", Type: schema.QuesmaTypeText},
+		"host":             {PropertyName: "host", InternalPropertyName: "host", Type: schema.QuesmaTypeKeyword},
+		"tags":             {PropertyName: "tags", InternalPropertyName: "tags", Type: schema.QuesmaTypeKeyword},
+		"required_matches": {PropertyName: "required_matches", InternalPropertyName: "required_matches", Type: schema.QuesmaTypeLong},
+	}}
+	cw := ClickhouseQueryTranslator{Table: &table, Ctx: context.Background(), Schema: currentSchema}
+	for i, tt := range tests {
+		t.Run(util.PrettyTestName(tt.name, i), func(t *testing.T) {
+			simpleQuery := cw.parseQueryMap(tt.query)
+			if tt.expectedWhere == "" {
+				assert.False(t, simpleQuery.CanParse)
+			} else {
+				assert.True(t, simpleQuery.CanParse)
+				assert.Equal(t, tt.expectedWhere, simpleQuery.WhereClauseAsString())
+			}
+		})
+	}
+}
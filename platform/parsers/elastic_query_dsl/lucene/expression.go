@@ -3,119 +3,328 @@
 package lucene
 
 import (
-	"github.com/QuesmaOrg/quesma/platform/logger"
+	"path"
+	"slices"
+	"strings"
+
 	"github.com/QuesmaOrg/quesma/platform/model"
 )
 
 var invalidStatement = model.NewLiteral("false")
 
+// occur says how a clause influences the result, like Lucene's BooleanClause.Occur
+type occur int
+
+const (
+	occurShould occur = iota
+	occurMust
+	occurMustNot
+)
+
+type clause struct {
+	expr  model.Expr
+	occur occur
+}
+
+// conjunction and modifier preceding a clause, e.g. for `a AND NOT b` it's (AND, NOT) for `b`
+type (
+	conjunction int
+	modifier    int
+)
+
+const (
+	conjunctionNone conjunction = iota
+	conjunctionAnd
+	conjunctionOr
+)
+
+const (
+	modifierNone modifier = iota
+	modifierRequired
+	modifierNot
+)
+
 func (p *luceneParser) BuildWhereStatement() model.Expr {
-	for len(p.tokens) > 0 {
-		p.WhereStatement = p.buildWhereStatement(true)
+	statement := p.buildQuery(p.defaultFieldNames, false)
+	if p.err != nil {
+		return invalidStatement
 	}
-	if p.WhereStatement == nil {
+	if statement == nil {
 		return model.NewLiteral("true")
 	}
-	return p.WhereStatement
+	return statement
 }
 
 // LeafStatement is the smallest part of a query that can be translated into SQL,
-// e.g. "title:abc", or "abc", or "title:(abc OR def)".
-func newLeafStatement(fieldNames []string, value value) model.Expr {
+// e.g. "title:abc", or "abc", or "title:[a TO b]".
+func (p *luceneParser) newLeafStatement(fieldNames []string, value value) model.Expr {
 	if len(fieldNames) == 0 {
 		return invalidStatement
 	}
 
-	var newStatement model.Expr
-	if len(fieldNames) > 0 {
-		newStatement = value.toExpression(fieldNames[0])
-		for _, fieldName := range fieldNames[1:] {
-			newStatement = model.NewInfixExpr(newStatement, "OR", value.toExpression(fieldName))
+	statement := p.analyzedValue(value, fieldNames[0]).toExpression(fieldNames[0])
+	for _, fieldName := range fieldNames[1:] {
+		statement = model.NewInfixExpr(statement, "OR", p.analyzedValue(value, fieldName).toExpression(fieldName))
+	}
+	return statement
+}
+
+// analyzedValue returns the value we search for in the field. With analyze_wildcard, Elasticsearch analyzes wildcard terms
+// like other terms, so for full-text fields we lowercase them. (without it, they're normalized, so lowercased too, but
+// our full-text matching is case-insensitive anyway)
+func (p *luceneParser) analyzedValue(v value, fieldName string) value {
+	term, isTerm := v.(termValue)
+	if !p.analyzeWildcard || !isTerm || !term.hasWildcard() {
+		return v
+	}
+	if fieldName != model.FullTextFieldNamePlaceHolder {
+		if field, found := p.currentSchema.ResolveFieldByInternalName(fieldName); !found || !field.Type.IsFullText() {
+			return v
 		}
 	}
-	if len(fieldNames) == 1 {
-		return value.toExpression(fieldNames[0])
+	return newTermValue(strings.ToLower(term.term))
+}
+
+// buildQuery builds a WHERE statement from clauses in p.tokens, until the end of the query,
+// or until the closing parenthesis, if we're inside a group (e.g. "(a OR b)" or "title:(a OR b)").
+// fieldNames are fields we query for clauses without explicit field, e.g. [title] for "title:(a OR b)".
+// Returns nil for empty query.
+func (p *luceneParser) buildQuery(fieldNames []string, insideGroup bool) model.Expr {
+	var clauses []clause
+	currentConjunction := conjunctionNone
+	for len(p.tokens) > 0 && p.err == nil {
+		switch p.tokens[0].(type) {
+		case rightParenthesisToken:
+			p.tokens = p.tokens[1:]
+			if !insideGroup {
+				p.reportError("unexpected ')'")
+				return invalidStatement
+			}
+			if currentConjunction != conjunctionNone {
+				p.reportError("missing clause after AND/OR")
+				return invalidStatement
+			}
+			if len(clauses) == 0 {
+				p.reportError("empty group '()'")
+				return invalidStatement
+			}
+			return p.combineClauses(clauses)
+		case andToken, orToken:
+			if len(clauses) == 0 || currentConjunction != conjunctionNone {
+				p.reportError("missing clause before AND/OR")
+				return invalidStatement
+			}
+			if _, isAnd := p.tokens[0].(andToken); isAnd {
+				currentConjunction = conjunctionAnd
+			} else {
+				currentConjunction = conjunctionOr
+			}
+			p.tokens = p.tokens[1:]
+			continue
+		}
+
+		currentModifier := modifierNone
+		switch p.tokens[0].(type) {
+		case requiredToken:
+			currentModifier = modifierRequired
+			p.tokens = p.tokens[1:]
+		case notToken:
+			currentModifier = modifierNot
+			p.tokens = p.tokens[1:]
+		}
+
+		expr := p.buildClause(fieldNames)
+		clauses = p.addClause(clauses, currentConjunction, currentModifier, expr)
+		currentConjunction = conjunctionNone
+	}
+
+	if p.err != nil {
+		return invalidStatement
+	}
+	if insideGroup {
+		p.reportError("missing ')'")
+		return invalidStatement
+	}
+	if currentConjunction != conjunctionNone {
+		p.reportError("missing clause after AND/OR")
+		return invalidStatement
 	}
-	return newStatement
+	return p.combineClauses(clauses)
 }
 
-// buildWhereStatement builds a WHERE statement from the tokens.
-// During parsing, we only keep one expression, because we're combining leafExpressions into
-// a tree of expressions. We keep the lastExpression to combine it with the next one.
-// E.g. "title:abc AND text:def" is parsed into andExpression(title:abc, text:def)".
-func (p *luceneParser) buildWhereStatement(addDefaultOperator bool) model.Expr {
+// buildClause builds a single clause (without +/-/NOT modifier), e.g. "abc", "title:abc", "title:(a OR b)", "(a AND b)"
+func (p *luceneParser) buildClause(fieldNames []string) model.Expr {
 	if len(p.tokens) == 0 {
+		p.reportError("missing clause after operator")
 		return invalidStatement
 	}
 
 	tok := p.tokens[0]
 	p.tokens = p.tokens[1:]
-	var currentStatement model.Expr
 
 	switch currentToken := tok.(type) {
 	case fieldNameToken:
 		if len(p.tokens) <= 1 {
-			logger.Error().Msgf("invalid expression, missing value, tokens: %v", p.tokens)
-			p.tokens = p.tokens[:0]
+			p.reportError("missing value for field %s", currentToken.fieldName)
 			return invalidStatement
 		}
 		if _, isNextTokenSeparator := p.tokens[0].(separatorToken); !isNextTokenSeparator {
-			logger.Error().Msgf("invalid expression, missing separator, tokens: %v", p.tokens)
+			p.reportError("missing ':' after field %s", currentToken.fieldName)
 			return invalidStatement
 		}
 		p.tokens = p.tokens[1:]
-		if name, resolved := p.currentSchema.ResolveField(currentToken.fieldName); resolved {
-			currentStatement = newLeafStatement([]string{name.InternalPropertyName.AsString()}, p.buildValue([]value{}, 0))
-		} else {
-			currentStatement = newLeafStatement([]string{currentToken.fieldName}, p.buildValue([]value{}, 0))
+		fields := p.resolveFieldNames(currentToken.fieldName)
+		if _, isGroup := p.tokens[0].(leftParenthesisToken); isGroup {
+			p.tokens = p.tokens[1:]
+			return parenthesize(p.buildQuery(fields, true))
 		}
-	case separatorToken:
-		currentStatement = newLeafStatement(
-			p.defaultFieldNames,
-			p.buildValue([]value{}, 0),
-		)
-	case termToken:
-		currentStatement = newLeafStatement(p.defaultFieldNames, newTermValue(currentToken.term))
-	case fuzzyToken:
-		currentStatement = newLeafStatement(p.defaultFieldNames, newFuzzyValue(currentToken.term, currentToken.distance))
-	case andToken:
-		return model.NewInfixExpr(p.WhereStatement, "AND", p.buildWhereStatement(false))
-	case orToken:
-		return model.NewInfixExpr(p.WhereStatement, "OR", p.buildWhereStatement(false))
-	case notToken:
-		latterExp := p.buildWhereStatement(false)
-		currentStatement = model.NewPrefixExpr("NOT", []model.Expr{latterExp})
+		valueTok := p.tokens[0]
+		p.tokens = p.tokens[1:]
+		if value, ok := p.buildValue(valueTok); ok {
+			return p.newLeafStatement(fields, value)
+		}
+		p.reportError("invalid value for field %s", currentToken.fieldName)
+		return invalidStatement
 	case existsToken:
-		fieldName, ok := p.buildValue([]value{}, 0).(termValue)
-		if !ok {
-			logger.Error().Msgf("buildExpression: invalid expression, unexpected token: %#v, tokens: %v", currentToken, p.tokens)
+		if len(p.tokens) == 0 {
+			p.reportError("missing field name after _exists_:")
 			return invalidStatement
 		}
-		currentStatement = model.NewInfixExpr(model.NewColumnRef(fieldName.term), " IS NOT ", model.NewLiteral("NULL"))
-	case leftParenthesisToken:
-		currentStatement = model.NewParenExpr(p.buildWhereStatement(false))
-	case rightParenthesisToken:
-		if p.WhereStatement == nil {
+		fieldName, ok := p.tokens[0].(termToken)
+		if !ok {
+			p.reportError("invalid field name after _exists_:")
 			return invalidStatement
 		}
-		return p.WhereStatement
+		p.tokens = p.tokens[1:]
+		return p.existsStatement(p.resolveFieldNames(fieldName.term))
+	case leftParenthesisToken:
+		return parenthesize(p.buildQuery(fieldNames, true))
+	case separatorToken:
+		p.reportError("missing field name before ':'")
+		return invalidStatement
 	default:
-		logger.Error().Msgf("buildExpression: invalid expression, unexpected token: %#v, tokens: %v", currentToken, p.tokens)
+		if value, ok := p.buildValue(tok); ok {
+			return p.newLeafStatement(fieldNames, value)
+		}
+		p.reportError("unexpected token: %v", currentToken)
 		return invalidStatement
 	}
+}
+
+// buildValue returns (value, true) if tok is a value (e.g. term, range), (nil, false) otherwise
+func (p *luceneParser) buildValue(tok token) (value, bool) {
+	switch currentToken := tok.(type) {
+	case termToken:
+		return newTermValue(currentToken.term), true
+	case fuzzyToken:
+		return newFuzzyValue(currentToken.term, currentToken.distance), true
+	case proximityToken:
+		return newProximityValue(currentToken.phrase, currentToken.slop), true
+	case regexpToken:
+		return newRegexpValue(currentToken.pattern), true
+	case rangeToken:
+		return currentToken.rangeValue, true
+	default:
+		return nil, false
+	}
+}
 
-	if !addDefaultOperator || p.WhereStatement == nil {
-		return currentStatement
+func (p *luceneParser) existsStatement(fieldNames []string) model.Expr {
+	statements := make([]model.Expr, 0, len(fieldNames))
+	for _, fieldName := range fieldNames {
+		statements = append(statements, model.NewInfixExpr(model.NewColumnRef(fieldName), " IS NOT ", model.NewLiteral("NULL")))
+	}
+	if len(statements) == 0 {
+		return invalidStatement
 	}
+	return model.Or(statements)
+}
 
-	switch stmt := currentStatement.(type) {
-	case model.PrefixExpr:
-		if stmt.Op == "NOT" {
-			return model.NewInfixExpr(p.WhereStatement, "AND", currentStatement)
-		} else {
-			return model.NewInfixExpr(p.WhereStatement, "OR", currentStatement)
+// addClause is an equivalent of Lucene's QueryParserBase.addClause: it decides if the clause is required, optional
+// or prohibited, based on the conjunction before it, its modifier and the default operator.
+// Conjunction can also change the previous clause, e.g. "a AND b" makes both a and b required.
+func (p *luceneParser) addClause(clauses []clause, conj conjunction, mod modifier, expr model.Expr) []clause {
+	if len(clauses) > 0 {
+		last := &clauses[len(clauses)-1]
+		if conj == conjunctionAnd && last.occur != occurMustNot {
+			last.occur = occurMust
+		}
+		if p.defaultOperator == DefaultOperatorAnd && conj == conjunctionOr && last.occur != occurMustNot {
+			last.occur = occurShould
 		}
+	}
+
+	var required, prohibited bool
+	if p.defaultOperator == DefaultOperatorOr {
+		prohibited = mod == modifierNot
+		required = mod == modifierRequired || (conj == conjunctionAnd && !prohibited)
+	} else {
+		prohibited = mod == modifierNot
+		required = !prohibited && conj != conjunctionOr
+	}
+
+	switch {
+	case prohibited:
+		return append(clauses, clause{expr: expr, occur: occurMustNot})
+	case required:
+		return append(clauses, clause{expr: expr, occur: occurMust})
 	default:
-		return model.NewInfixExpr(p.WhereStatement, "OR", currentStatement)
+		return append(clauses, clause{expr: expr, occur: occurShould})
+	}
+}
+
+// combineClauses combines clauses like Lucene's BooleanQuery does: all required clauses must match, none of the
+// prohibited ones can, and if there are no required clauses, at least one of the optional ones must match.
+// Query with only prohibited clauses matches everything else (as in Elasticsearch).
+func (p *luceneParser) combineClauses(clauses []clause) model.Expr {
+	var must, should, mustNot []model.Expr
+	for _, c := range clauses {
+		switch c.occur {
+		case occurMust:
+			must = append(must, c.expr)
+		case occurShould:
+			should = append(should, c.expr)
+		case occurMustNot:
+			mustNot = append(mustNot, model.NewPrefixExpr("NOT", []model.Expr{c.expr}))
+		}
+	}
+
+	var statements []model.Expr
+	if len(must) > 0 {
+		statements = must
+	} else if len(should) > 0 {
+		statements = []model.Expr{model.Or(should)}
+	}
+	return model.And(append(statements, mustNot...))
+}
+
+// resolveFieldNames returns internal names of fields matching fieldName. fieldName may contain wildcards, e.g. "book.*"
+func (p *luceneParser) resolveFieldNames(fieldName string) []string {
+	fieldName = strings.ReplaceAll(fieldName, `\`, "")
+	if !strings.Contains(fieldName, "*") {
+		if field, resolved := p.currentSchema.ResolveField(fieldName); resolved {
+			return []string{field.InternalPropertyName.AsString()}
+		}
+		return []string{fieldName}
+	}
+
+	var fieldNames []string
+	for name, field := range p.currentSchema.Fields {
+		if matches, _ := path.Match(fieldName, name.AsString()); matches {
+			fieldNames = append(fieldNames, field.InternalPropertyName.AsString())
+		}
+	}
+	if len(fieldNames) == 0 {
+		p.reportError("no fields match %s", fieldName)
+	}
+	slices.Sort(fieldNames)
+	return fieldNames
+}
+
+// parenthesize wraps the expression in parentheses, unless it's already rendered in them (AND/OR)
+func parenthesize(expr model.Expr) model.Expr {
+	if infix, ok := expr.(model.InfixExpr); ok && (infix.Op == "AND" || infix.Op == "OR") {
+		return expr
 	}
+	return model.NewParenExpr(expr)
 }
@@ -4,12 +4,14 @@ package lucene
 
 import (
 	"context"
+	"fmt"
 	"math"
 	"slices"
 	"strconv"
 	"strings"
 	"unicode"
 
+	"github.com/QuesmaOrg/quesma/platform/end_user_errors"
 	"github.com/QuesmaOrg/quesma/platform/logger"
 	"github.com/QuesmaOrg/quesma/platform/model"
 	"github.com/QuesmaOrg/quesma/platform/schema"
@@ -18,41 +20,53 @@ import (
 // Mainly based on this doc: https://lucene.apache.org/core/2_9_4/queryparsersyntax.html
 // Alternatively: https://www.elastic.co/guide/en/elasticsearch/reference/current/query-dsl-query-string-query.html
 
-// We don't support:
-// - Fuzzy search (e.g. roam~0.8, ~0.8 is simply removed)
-// - Wildcards ? and * - they are treated as regular characters
-//   (I think I'll add at least some basic support for them quite soon, it's needed for sample dashboards)
+// Clauses are combined like in Lucene's classic QueryParser (which Elasticsearch uses): every clause is either
+// required (+, or AND), prohibited (-, !, NOT), or optional (default operator OR). If there are any required clauses,
+// optional ones don't change which documents match (they only influence scoring, which we don't do here).
+//
+// Approximations:
+// - Fuzzy search (e.g. roam~2) uses Damerau-Levenshtein distance on the whole field value
+// - Proximity search (e.g. "quick fox"~5) matches terms in the given order, with at most `slop` words between each of them
+// - Regular expressions (e.g. /joh?n/) use the same translation as `regexp` query. Lucene-only operators
+//   (@, &, #, ~, <>) aren't supported.
+// - Boosts (e.g. abc^2) are ignored
+// - With analyze_wildcard, wildcard terms (e.g. Qu?ck*) of full-text fields are lowercased, but not split into tokens
 // - escaped " inside quoted fieldnames, so e.g.
 //     * "a\"b" - not supported
 //     * abc"def - supported
-// - +, -, &&, ||, operators. But AND, OR, NOT are fully supported and they seem equivalent.
 
 // Date ranges are only in format YYYY-MM-DD, as in docs there are no other examples. That can be changed if needed.
 
-// Used in parsing one Lucene query. During parsing WhereStatement keeps parsed part of the query,
-// and tokens keep the rest (unparsed yet) part of the query.
-// After parsing, the result statement is kept in p.WhereStatement (we should change it in the future)
+// Used in parsing one Lucene query. During parsing, tokens keep the rest (unparsed yet) part of the query.
 // If you have multiple queries to parse, create a new luceneParser for each query.
 type (
 	luceneParser struct {
 		ctx               context.Context
 		tokens            []token
 		defaultFieldNames []string
-		// This is a little awkward, at some point we should remove `WhereStatement` and just return the statement from `BuildWhereStatement`
-		// However, given parsing implementation, it's easier to keep it for now.
-		WhereStatement model.Expr
+		defaultOperator   string                        // DefaultOperatorOr or DefaultOperatorAnd, operator used between clauses without explicit AND/OR
+		analyzeWildcard   bool                          // analyze_wildcard: wildcard terms of full-text fields are lowercased, see analyzedValue
+		err               *end_user_errors.EndUserError // first error encountered during parsing, if any
 
 		currentSchema schema.Schema
 	}
 )
 
+const (
+	DefaultOperatorOr  = "OR"
+	DefaultOperatorAnd = "AND"
+)
+
 func newLuceneParser(ctx context.Context, defaultFieldNames []string, currentSchema schema.Schema) luceneParser {
-	return luceneParser{ctx: ctx, defaultFieldNames: defaultFieldNames, tokens: make([]token, 0), currentSchema: currentSchema}
+	return luceneParser{ctx: ctx, defaultFieldNames: defaultFieldNames, defaultOperator: DefaultOperatorOr, tokens: make([]token, 0), currentSchema: currentSchema}
 }
 
 const fuzzyOperator = '~'
 const boostingOperator = '^'
 const escapeCharacter = '\\'
+const requiredOperator = '+'
+const prohibitedOperator = '-'
+const regexpDelimiter = '/'
 
 const delimiterCharacter = ':'
 
@@ -69,15 +83,34 @@ var specialOperators = map[string]token{
 	"AND ":                   andToken{},
 	"OR ":                    orToken{},
 	"NOT ":                   notToken{},
+	"&&":                     andToken{},
+	"||":                     orToken{},
 	"!":                      notToken{},
 	"_exists_:":              existsToken{},
 	string(leftParenthesis):  leftParenthesisToken{},
 	string(rightParenthesis): rightParenthesisToken{},
 }
 
-func TranslateToSQL(ctx context.Context, query string, fields []string, currentSchema schema.Schema) model.Expr {
+// TranslateToSQL translates Lucene query into SQL. Returned error (if any) explains to the end user
+// what's wrong with the query, or which construct we don't support.
+func TranslateToSQL(ctx context.Context, query string, fields []string, defaultOperator string, analyzeWildcard bool,
+	currentSchema schema.Schema) (model.Expr, error) {
+
 	parser := newLuceneParser(ctx, fields, currentSchema)
-	return parser.translateToSQL(query)
+	parser.analyzeWildcard = analyzeWildcard
+	switch strings.ToUpper(defaultOperator) {
+	case DefaultOperatorOr, "":
+	case DefaultOperatorAnd:
+		parser.defaultOperator = DefaultOperatorAnd
+	default:
+		parser.reportUnsupported("default_operator %s", defaultOperator)
+		return invalidStatement, parser.err
+	}
+	expr := parser.translateToSQL(query)
+	if parser.err != nil {
+		return invalidStatement, parser.err
+	}
+	return expr, nil
 }
 
 func (p *luceneParser) translateToSQL(query string) model.Expr {
@@ -85,12 +118,30 @@ func (p *luceneParser) translateToSQL(query string) model.Expr {
 	p.tokenizeQuery(query)
 	if len(p.tokens) == 1 {
 		if _, isInvalidToken := p.tokens[0].(invalidToken); isInvalidToken {
-			logger.WarnWithCtx(p.ctx).Msgf("Invalid query, can't tokenize: %s", query)
+			p.reportError("invalid query, can't tokenize it")
+			return invalidStatement
 		}
 	}
 	return p.BuildWhereStatement()
 }
 
+// reportError remembers the first error encountered during parsing. Invalid parts of the query are translated to `false`.
+func (p *luceneParser) reportError(format string, args ...any) {
+	p.setError(end_user_errors.ErrQuerySyntax, fmt.Errorf(format, args...))
+}
+
+// reportUnsupported is like reportError, but for valid queries using constructs we don't support
+func (p *luceneParser) reportUnsupported(format string, args ...any) {
+	p.setError(end_user_errors.ErrSearchCondition, fmt.Errorf("unsupported in query_string: "+format, args...))
+}
+
+func (p *luceneParser) setError(errorType *end_user_errors.ErrorType, err error) {
+	logger.WarnWithCtx(p.ctx).Msgf("invalid Lucene query: %v", err)
+	if p.err == nil {
+		p.err = errorType.New(err).Details(" %v", err)
+	}
+}
+
 // tokenizeQuery splits the query into tokens, which are stored in p.tokens.
 // If query is invalid, p.tokens contains only one invalidToken.
 func (p *luceneParser) tokenizeQuery(query string) {
@@ -109,13 +160,35 @@ func (p *luceneParser) tokenizeQuery(query string) {
 }
 
 func (p *luceneParser) nextToken(query string) (tokens []token, remainingQuery string) {
-	// parsing special operators
+	// parsing special operators. Operator at the end of the query (e.g. "a AND") is still an operator (and a syntax error later on).
+	if operatorToken, isOperator := specialOperators[query+" "]; isOperator {
+		return []token{operatorToken}, ""
+	}
 	for operator, operatorToken := range specialOperators {
 		if strings.HasPrefix(query, operator) {
 			return []token{operatorToken}, query[len(operator):]
 		}
 	}
 
+	// parsing +/- prefixes. They can't follow ':' (e.g. age:-10 is a negative number), and '-' followed by a digit is a number.
+	afterSeparator := false
+	if len(p.tokens) > 0 {
+		_, afterSeparator = p.tokens[len(p.tokens)-1].(separatorToken)
+	}
+	if !afterSeparator && len(query) > 1 && query[1] != ' ' {
+		switch {
+		case query[0] == requiredOperator:
+			return []token{requiredToken{}}, query[1:]
+		case query[0] == prohibitedOperator && !unicode.IsDigit(rune(query[1])):
+			return []token{notToken{}}, query[1:]
+		}
+	}
+
+	if query[0] == regexpDelimiter {
+		regexp, remainingQuery := p.parseRegexp(query)
+		return []token{regexp}, remainingQuery
+	}
+
 	// parsing term(:value)
 	term, remainingQuery := p.parseTerm(query, false)
 
@@ -144,32 +217,36 @@ func (p *luceneParser) parseTerm(query string, closingBoundTerm bool) (token tok
 			if r == '"' {
 				term := query[:i+2]
 				remainingQuery = query[i+2:]
-				// Check for fuzzy operator after quoted term (e.g., "term"~2)
+				// Check for proximity operator after quoted term (e.g., "quick fox"~2)
 				if strings.HasPrefix(remainingQuery, string(fuzzyOperator)) {
-					// Parse fuzzy operator from remaining query
-					distanceEnd := 1 // Start after ~
-
-					// Find where distance ends (space, delimiter, etc.)
-					for distanceEnd < len(remainingQuery) {
-						r := remainingQuery[distanceEnd]
+					slopEnd := 1 // Start after ~
+					for slopEnd < len(remainingQuery) {
+						r := remainingQuery[slopEnd]
 						if r == ' ' || r == delimiterCharacter || r == rightParenthesis {
 							break
 						}
-						distanceEnd++
+						slopEnd++
 					}
-
-					distanceStr := remainingQuery[1:distanceEnd] // Skip the ~
-					distance := p.parseFuzzyDistance(distanceStr)
-
-					// Remove quotes from term for fuzzy search
-					cleanTerm := term[1 : len(term)-1] // Remove quotes
-					logger.InfoWithCtx(p.ctx).Msgf("Parsed fuzzy term: %s with distance: %d", cleanTerm, distance)
-					return newFuzzyToken(cleanTerm, distance), remainingQuery[distanceEnd:]
+					slop := 0 // Lucene's default for "phrase"~
+					if slopStr := remainingQuery[1:slopEnd]; slopStr != "" {
+						slopAsFloat, err := strconv.ParseFloat(slopStr, 64)
+						if err != nil || slopAsFloat < 0 {
+							p.reportError("invalid proximity: %s", remainingQuery[:slopEnd])
+							return newInvalidToken(), ""
+						}
+						slop = int(slopAsFloat)
+					}
+					phrase := term[1 : len(term)-1] // Remove quotes
+					if len(strings.FieldsFunc(phrase, isNotWordCharacter)) > 1 {
+						return newProximityToken(phrase, slop), remainingQuery[slopEnd:]
+					}
+					// proximity of a single term is the term itself
+					return newTermToken(term), remainingQuery[slopEnd:]
 				}
 				return newTermToken(term), remainingQuery
 			}
 		}
-		logger.Error().Msgf("unterminated quoted term, query: %s", query)
+		p.reportError("unterminated quoted term: %s", query)
 		return newInvalidToken(), ""
 	case '>', '<', inclusiveRangeOpeningCharacter, exclusiveRangeOpeningCharacter:
 		return p.parseRange(query)
@@ -196,6 +273,23 @@ func (p *luceneParser) parseTerm(query string, closingBoundTerm bool) (token tok
 	}
 }
 
+// parseRegexp parses /regexp/ (query[0] == '/'). Only a subset of Lucene regexp syntax, which is common with RE2, is supported.
+func (p *luceneParser) parseRegexp(query string) (token token, remainingQuery string) {
+	for i := 1; i < len(query); i++ {
+		switch query[i] {
+		case escapeCharacter:
+			i++ // skip escaped character
+		case '@', '&', '#', '<', '~':
+			p.reportUnsupported("regexp operator '%c' in: %s", query[i], query)
+			return newInvalidToken(), ""
+		case regexpDelimiter:
+			return newRegexpToken(strings.ReplaceAll(query[1:i], `\/`, "/")), query[i+1:]
+		}
+	}
+	p.reportError("unterminated regexp: %s", query)
+	return newInvalidToken(), ""
+}
+
 // parseFuzzyIfPresent checks if the term contains fuzzy operator and parses it
 // Returns fuzzy token if fuzzy operator found, nil otherwise
 func (p *luceneParser) parseFuzzyIfPresent(term string, remainingQuery string) (token, string) {
@@ -249,7 +343,7 @@ func (p *luceneParser) parseRange(query string) (token token, remainingQuery str
 	switch query[0] {
 	case '>', '<':
 		if len(query) == 1 {
-			logger.Error().Msgf("parseRange: invalid range, missing value, query: %s", query)
+			p.reportError("invalid range, missing value: %s", query)
 			return newInvalidToken(), ""
 		}
 		acceptableCharactersAfterNumber := []rune{' ', rightParenthesis}
@@ -287,7 +381,7 @@ func (p *luceneParser) parseRange(query string) (token token, remainingQuery str
 		inclusiveClosing := remainingQuery[0] == inclusiveRangeClosingCharacter
 		return newRangeToken(newRangeValue(lowerBound, inclusiveOpening, upperBound, inclusiveClosing)), remainingQuery[1:]
 	}
-	logger.Error().Msgf("parseRange: invalid range, query: %s", query)
+	p.reportError("invalid range: %s", query)
 	return newInvalidToken(), ""
 }
 
@@ -306,7 +400,7 @@ func (p *luceneParser) parseNumber(query string, reportErrors bool, acceptableCh
 			dotCount++
 			if dotCount > 1 {
 				if reportErrors {
-					logger.Error().Msgf("invalid number, multiple dots, query: %s", query)
+					p.reportError("invalid number, multiple dots: %s", query)
 				}
 				return math.NaN(), ""
 			}
@@ -315,7 +409,7 @@ func (p *luceneParser) parseNumber(query string, reportErrors bool, acceptableCh
 		if !unicode.IsDigit(r) {
 			if !slices.Contains(acceptableCharsAfterNumber, r) {
 				if reportErrors {
-					logger.Error().Msgf("invalid number, query: %s", query)
+					p.reportError("invalid number: %s", query)
 				}
 				return math.NaN(), ""
 			}
@@ -326,7 +420,7 @@ func (p *luceneParser) parseNumber(query string, reportErrors bool, acceptableCh
 	number, err = strconv.ParseFloat(query[:i], 64)
 	if err != nil {
 		if reportErrors {
-			logger.Error().Msgf("invalid number, query: %s, error: %v", query, err)
+			p.reportError("invalid number: %s, error: %v", query, err)
 		}
 		return math.NaN(), ""
 	}
@@ -359,7 +453,7 @@ func (p *luceneParser) parseOneBound(query string, closingBound bool) (bound any
 		}
 		return bound, remainingQuery
 	} else {
-		logger.Error().Msgf("parseRange: invalid range, query: %s", query)
+		p.reportError("invalid range: %s", query)
 		return newInvalidToken(), ""
 	}
 }
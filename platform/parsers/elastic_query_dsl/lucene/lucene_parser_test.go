@@ -4,8 +4,10 @@ package lucene
 
 import (
 	"context"
+	"errors"
 	"testing"
 
+	"github.com/QuesmaOrg/quesma/platform/end_user_errors"
 	"github.com/QuesmaOrg/quesma/platform/model"
 	"github.com/QuesmaOrg/quesma/platform/schema"
 	"github.com/QuesmaOrg/quesma/platform/util"
@@ -19,14 +21,14 @@ func TestTranslatingLuceneQueriesToSQL(t *testing.T) {
 		want  string
 	}{
 		{`title:"The Right Way" AND text:go!!`, `("title" __quesma_match 'The Right Way' AND "text" __quesma_match 'go!!')`},
-		{`title:Do it right AND right`, `((("title" __quesma_match 'Do' OR ("title" __quesma_match 'it' OR "text" __quesma_match 'it')) OR ("title" __quesma_match 'right' OR "text" __quesma_match 'right')) AND ("title" __quesma_match 'right' OR "text" __quesma_match 'right'))`},
+		{`title:Do it right AND right`, `(("title" __quesma_match 'right' OR "text" __quesma_match 'right') AND ("title" __quesma_match 'right' OR "text" __quesma_match 'right'))`},
 		{`roam~`, `(damerauLevenshteinDistance("title",'roam') <= 2 OR damerauLevenshteinDistance("text",'roam') <= 2)`},
 		{`query: roam~323`, `damerauLevenshteinDistance("query",'roam') <= 323`},
 		{`roam~0.8`, `(damerauLevenshteinDistance("title",'roam') <= 1 OR damerauLevenshteinDistance("text",'roam') <= 1)`},
 		{`query:google.cmo~1`, `damerauLevenshteinDistance("query",'google.cmo') <= 1`},
 		{`jakarta^4 apache`, `(("title" __quesma_match 'jakarta' OR "text" __quesma_match 'jakarta') OR ("title" __quesma_match 'apache' OR "text" __quesma_match 'apache'))`},
 		{`"jakarta apache"^10`, `("title" __quesma_match 'jakarta apache' OR "text" __quesma_match 'jakarta apache')`},
-		{`"jakarta apache"~10`, `(damerauLevenshteinDistance("title",'jakarta apache') <= 10 OR damerauLevenshteinDistance("text",'jakarta apache') <= 10)`},
+		{`"jakarta apache"~10`, `(match("title",'(?i)\\bjakarta(?:\\W+\\w+){0,10}\\W+apache\\b') OR match("text",'(?i)\\bjakarta(?:\\W+\\w+){0,10}\\W+apache\\b'))`},
 		{`mod_date:[2002-01-01 TO 2003-02-15]`, `("mod_date" >= '2002-01-01' AND "mod_date" <= '2003-02-15')`}, // 7
 		{`mod_date:[2002-01-01 TO 2003-02-15}`, `("mod_date" >= '2002-01-01' AND "mod_date" < '2003-02-15')`},
 		{`age:>10`, `"age" > '10'`},
@@ -48,15 +50,15 @@ func TestTranslatingLuceneQueriesToSQL(t *testing.T) {
 		{`"jakarta apache" AND "Apache Lucene"`, `(("title" __quesma_match 'jakarta apache' OR "text" __quesma_match 'jakarta apache') AND ("title" __quesma_match 'Apache Lucene' OR "text" __quesma_match 'Apache Lucene'))`},
 		{`NOT status:"jakarta apache"`, `NOT ("status" __quesma_match 'jakarta apache')`},
 		{`"jakarta apache" NOT "Apache Lucene"`, `(("title" __quesma_match 'jakarta apache' OR "text" __quesma_match 'jakarta apache') AND NOT (("title" __quesma_match 'Apache Lucene' OR "text" __quesma_match 'Apache Lucene')))`},
-		{`(jakarta OR apache) AND website`, `(((("title" __quesma_match 'jakarta' OR "text" __quesma_match 'jakarta')) OR ("title" __quesma_match 'apache' OR "text" __quesma_match 'apache')) AND ("title" __quesma_match 'website' OR "text" __quesma_match 'website'))`},
+		{`(jakarta OR apache) AND website`, `((("title" __quesma_match 'jakarta' OR "text" __quesma_match 'jakarta') OR ("title" __quesma_match 'apache' OR "text" __quesma_match 'apache')) AND ("title" __quesma_match 'website' OR "text" __quesma_match 'website'))`},
 		{`title:(return "pink panther")`, `("title" __quesma_match 'return' OR "title" __quesma_match 'pink panther')`},
 		{`status:(active OR pending) title:(full text search)^2`, `(("status" __quesma_match 'active' OR "status" __quesma_match 'pending') OR (("title" __quesma_match 'full' OR "title" __quesma_match 'text') OR "title" __quesma_match 'search'))`},
-		{`status:(active OR NOT (pending AND in-progress)) title:(full text search)^2`, `(("status" __quesma_match 'active' OR NOT (("status" __quesma_match 'pending' AND "status" __quesma_match 'in-progress'))) OR (("title" __quesma_match 'full' OR "title" __quesma_match 'text') OR "title" __quesma_match 'search'))`},
-		{`status:(NOT active OR NOT (pending AND in-progress)) title:(full text search)^2`, `((NOT ("status" __quesma_match 'active') OR NOT (("status" __quesma_match 'pending' AND "status" __quesma_match 'in-progress'))) OR (("title" __quesma_match 'full' OR "title" __quesma_match 'text') OR "title" __quesma_match 'search'))`},
+		{`status:(active OR NOT (pending AND in-progress)) title:(full text search)^2`, `(("status" __quesma_match 'active' AND NOT (("status" __quesma_match 'pending' AND "status" __quesma_match 'in-progress'))) OR (("title" __quesma_match 'full' OR "title" __quesma_match 'text') OR "title" __quesma_match 'search'))`},
+		{`status:(NOT active OR NOT (pending AND in-progress)) title:(full text search)^2`, `((NOT ("status" __quesma_match 'active') AND NOT (("status" __quesma_match 'pending' AND "status" __quesma_match 'in-progress'))) OR (("title" __quesma_match 'full' OR "title" __quesma_match 'text') OR "title" __quesma_match 'search'))`},
 		{`status:(active OR (pending AND in-progress)) title:(full text search)^2`, `(("status" __quesma_match 'active' OR ("status" __quesma_match 'pending' AND "status" __quesma_match 'in-progress')) OR (("title" __quesma_match 'full' OR "title" __quesma_match 'text') OR "title" __quesma_match 'search'))`},
 		{`status:((a OR (b AND c)) AND d)`, `(("status" __quesma_match 'a' OR ("status" __quesma_match 'b' AND "status" __quesma_match 'c')) AND "status" __quesma_match 'd')`},
 		{`title:(return [Aida TO Carmen])`, `("title" __quesma_match 'return' OR ("title" >= 'Aida' AND "title" <= 'Carmen'))`},
-		{`host.name:(NOT active OR NOT (pending OR in-progress)) (full text search)^2`, `((((NOT ("host.name" __quesma_match 'active') OR NOT (("host.name" __quesma_match 'pending' OR "host.name" __quesma_match 'in-progress'))) OR (("title" __quesma_match 'full' OR "text" __quesma_match 'full'))) OR ("title" __quesma_match 'text' OR "text" __quesma_match 'text')) OR ("title" __quesma_match 'search' OR "text" __quesma_match 'search'))`},
+		{`host.name:(NOT active OR NOT (pending OR in-progress)) (full text search)^2`, `((NOT ("host.name" __quesma_match 'active') AND NOT (("host.name" __quesma_match 'pending' OR "host.name" __quesma_match 'in-progress'))) OR ((("title" __quesma_match 'full' OR "text" __quesma_match 'full') OR ("title" __quesma_match 'text' OR "text" __quesma_match 'text')) OR ("title" __quesma_match 'search' OR "text" __quesma_match 'search')))`},
 		{`host.name:(active AND NOT (pending OR in-progress)) hermes nemesis^2`, `((("host.name" __quesma_match 'active' AND NOT (("host.name" __quesma_match 'pending' OR "host.name" __quesma_match 'in-progress'))) OR ("title" __quesma_match 'hermes' OR "text" __quesma_match 'hermes')) OR ("title" __quesma_match 'nemesis' OR "text" __quesma_match 'nemesis'))`},
 
 		// special characters
@@ -82,10 +84,20 @@ func TestTranslatingLuceneQueriesToSQL(t *testing.T) {
 		{`!_exists_:title`, `NOT ("title" IS NOT NULL)`},
 		{"db.str:*weaver%12*", `"db.str" __quesma_match '%weaver\%12%'`},
 		{"(db.str:*weaver*)", `("db.str" __quesma_match '%weaver%')`},
-		{"(a.type:*ab* OR a.type:*Ab*)", `(("a.type" __quesma_match '%ab%') OR "a.type" __quesma_match '%Ab%')`},
+		{"(a.type:*ab* OR a.type:*Ab*)", `("a.type" __quesma_match '%ab%' OR "a.type" __quesma_match '%Ab%')`},
 		{"log:  \"lalala lala la\" AND log: \"troll\"", `("log" __quesma_match 'lalala lala la' AND "log" __quesma_match 'troll')`},
 		{"int: 20", `"int" = 20`},
 		{`int: "20"`, `"int" __quesma_match '20'`},
+		{`title:a && text:b`, `("title" __quesma_match 'a' AND "text" __quesma_match 'b')`},
+		{`title:a || text:b`, `("title" __quesma_match 'a' OR "text" __quesma_match 'b')`},
+		{`+title:a -text:b title:c`, `("title" __quesma_match 'a' AND NOT ("text" __quesma_match 'b'))`},
+		{`-title:a`, `NOT ("title" __quesma_match 'a')`},
+		{`title:a OR -text:b`, `("title" __quesma_match 'a' AND NOT ("text" __quesma_match 'b'))`},
+		{`title:(a b)^2 text:"c d"~0`, `(("title" __quesma_match 'a' OR "title" __quesma_match 'b') OR match("text",'(?i)\\bc\\W+d\\b'))`},
+		{`title:/jo[hn]+/`, `"title" REGEXP 'jo[hn]+'`},
+		{`title:/a\/b/`, `"title" LIKE 'a/b'`},
+		{`int:-10`, `"int" = -10`},
+		{`title:roam~2`, `damerauLevenshteinDistance("title",'roam') <= 2`},
 	}
 	var randomQueriesWithPossiblyIncorrectInput = []struct {
 		query string
@@ -94,7 +106,7 @@ func TestTranslatingLuceneQueriesToSQL(t *testing.T) {
 		{``, `true`},
 		{`          `, `true`},
 		{`  2 `, `("title" = 2 OR "text" = 2)`},
-		{`  2df$ ! `, `(("title" __quesma_match '2df$' OR "text" __quesma_match '2df$') AND NOT (false))`}, // TODO: this should probably just be "false"
+		{`  2df$ ! `, `false`},
 		{`title:`, `false`},
 		{`title: abc`, `"title" __quesma_match 'abc'`},
 		{`title[`, `("title" __quesma_match 'title[' OR "text" __quesma_match 'title[')`},
@@ -102,9 +114,9 @@ func TestTranslatingLuceneQueriesToSQL(t *testing.T) {
 		{`title[ TO ]`, `((("title" __quesma_match 'title[' OR "text" __quesma_match 'title[') OR ("title" __quesma_match 'TO' OR "text" __quesma_match 'TO')) OR ("title" __quesma_match ']' OR "text" __quesma_match ']'))`},
 		{`title:[ TO 2]`, `("title" >= '' AND "title" <= '2')`},
 		{`  title       `, `("title" __quesma_match 'title' OR "text" __quesma_match 'title')`},
-		{`  title : (+a -b c)`, `(("title" __quesma_match '+a' OR "title" __quesma_match '-b') OR "title" __quesma_match 'c')`}, // we don't support '+', '-' operators, but in that case the answer seems good enough + nothing crashes
+		{`  title : (+a -b c)`, `("title" __quesma_match 'a' AND NOT ("title" __quesma_match 'b'))`},
 		{`title:()`, `false`},
-		{`() a`, `((false) OR ("title" __quesma_match 'a' OR "text" __quesma_match 'a'))`}, // a bit weird, but '(false)' is OK as I think nothing should match '()'
+		{`() a`, `false`}, // empty group is a syntax error
 	}
 
 	currentSchema := schema.Schema{
@@ -151,3 +163,50 @@ func TestResolvePropertyNamesWhenTranslatingToSQL(t *testing.T) {
 		})
 	}
 }
+
+func TestTranslateToSQLWithOptions(t *testing.T) {
+	currentSchema := schema.Schema{Fields: map[schema.FieldName]schema.Field{
+		"book.title":  {PropertyName: "book.title", InternalPropertyName: "book_title"},
+		"book.author": {PropertyName: "book.author", InternalPropertyName: "book_author"},
+		"title":       {PropertyName: "title", InternalPropertyName: "title"},
+	}}
+	tests := []struct {
+		query           string
+		defaultOperator string
+		want            string // empty <=> we expect an error
+	}{
+		{`a b`, DefaultOperatorAnd, `("title" __quesma_match 'a' AND "title" __quesma_match 'b')`},
+		{`a OR b c`, DefaultOperatorAnd, `"title" __quesma_match 'c'`}, // same as Lucene: `c` is required, `a` and `b` are optional
+		{`a b`, "or", `("title" __quesma_match 'a' OR "title" __quesma_match 'b')`},
+		{`book.\*:abc`, DefaultOperatorOr, `("book_author" __quesma_match 'abc' OR "book_title" __quesma_match 'abc')`},
+		{`_exists_:book.*`, DefaultOperatorOr, `("book_author" IS NOT NULL OR "book_title" IS NOT NULL)`},
+		{`a b`, "XOR", ``},
+		{`title:"abc`, DefaultOperatorOr, ``},
+		{`title:`, DefaultOperatorOr, ``},
+		{`(a OR b`, DefaultOperatorOr, ``},
+		{`a OR b)`, DefaultOperatorOr, ``},
+		{`a AND`, DefaultOperatorOr, ``},
+		{`title:/ab@c/`, DefaultOperatorOr, ``},
+		{`title:/abc`, DefaultOperatorOr, ``},
+		{`"a b"~x`, DefaultOperatorOr, ``},
+		{`nothing.*:abc`, DefaultOperatorOr, ``},
+	}
+	for i, tt := range tests {
+		t.Run(util.PrettyTestName(tt.query, i), func(t *testing.T) {
+			got, err := TranslateToSQL(context.Background(), tt.query, []string{"title"}, tt.defaultOperator, false, currentSchema)
+			if tt.want == "" {
+				var endUserError *end_user_errors.EndUserError
+				if !errors.As(err, &endUserError) {
+					t.Errorf("expected end user error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if model.AsString(got) != tt.want {
+				t.Errorf("\ngot  [%q]\nwant [%q]", model.AsString(got), tt.want)
+			}
+		})
+	}
+}
@@ -63,3 +63,25 @@ func newFuzzyToken(term string, distance int) fuzzyToken {
 	}
 	return fuzzyToken{term: term, distance: distance}
 }
+
+// requiredToken is the `+` prefix, e.g. `+title:abc` (document must match the clause)
+type requiredToken struct{}
+
+// proximityToken is a phrase with slop, e.g. `"quick fox"~5`
+type proximityToken struct {
+	phrase string // without quotes
+	slop   int
+}
+
+func newProximityToken(phrase string, slop int) proximityToken {
+	return proximityToken{phrase: phrase, slop: slop}
+}
+
+// regexpToken is a regular expression, e.g. `/joh?n(ath[oa]n)/`
+type regexpToken struct {
+	pattern string // without slashes
+}
+
+func newRegexpToken(pattern string) regexpToken {
+	return regexpToken{pattern: pattern}
+}
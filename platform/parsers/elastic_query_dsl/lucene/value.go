@@ -4,18 +4,20 @@ package lucene
 
 import (
 	"fmt"
+	"regexp"
 	"slices"
 	"strconv"
 	"strings"
+	"unicode"
 
-	"github.com/QuesmaOrg/quesma/platform/logger"
 	"github.com/QuesmaOrg/quesma/platform/model"
 	"github.com/QuesmaOrg/quesma/platform/util"
+	"github.com/QuesmaOrg/quesma/platform/util/regex"
 )
 
 // value is a part of an expression, representing what we query for (expression without fields for which we query).
-// e.g. for expression "abc", value is "abc", for expression "title:abc", value is also "abc",
-// and for expression "title:(abc OR (def AND ghi))", value is "(abc OR (def AND ghi))".
+// e.g. for expression "abc", value is "abc", for expression "title:abc", value is also "abc".
+// Groups, e.g. "title:(abc OR def)", are built from multiple values, see buildQuery.
 
 var charTransformations = map[rune]string{
 	'*':  `%`,
@@ -25,7 +27,7 @@ var charTransformations = map[rune]string{
 	'\'': `\'`,
 }
 
-var specialCharacters = []rune{'+', '-', '!', '(', ')', '{', '}', '[', ']', '^', '"', '~', '*', '?', ':', '\\'} // they can be escaped in query string
+var specialCharacters = []rune{'+', '-', '!', '(', ')', '{', '}', '[', ']', '^', '"', '~', '*', '?', ':', '\\', '/', '&', '|', '=', '<', '>'} // they can be escaped in query string
 
 type value interface {
 	toExpression(fieldName string) model.Expr
@@ -68,6 +70,20 @@ func (v termValue) toExpression(fieldName string) model.Expr {
 	}
 }
 
+// hasWildcard returns true <=> the term has unescaped * or ?
+func (v termValue) hasWildcard() bool {
+	strAsRunes := []rune(v.term)
+	for i := 0; i < len(strAsRunes); i++ {
+		switch strAsRunes[i] {
+		case escapeCharacter:
+			i++
+		case '*', '?':
+			return true
+		}
+	}
+	return false
+}
+
 // transformSpecialCharacters transforms special characters in term to their SQL equivalents.
 // - Removes escaping, so \[special character] -> [special character]
 // - * and ? are transformed to % and _
@@ -231,142 +247,47 @@ func (v rangeValue) toExpression(fieldName string) model.Expr {
 
 }
 
-type andValue struct {
-	left  value
-	right value
-}
-
-func newAndValue(left, right value) andValue {
-	return andValue{left: left, right: right}
-}
-
-func (v andValue) toExpression(fieldName string) model.Expr {
-	return model.NewInfixExpr(v.left.toExpression(fieldName), "AND", v.right.toExpression(fieldName))
-}
-
-type orValue struct {
-	left  value
-	right value
-}
-
-func newOrValue(left, right value) orValue {
-	return orValue{left: left, right: right}
-}
-
-func (v orValue) toExpression(fieldName string) model.Expr {
-	return model.NewInfixExpr(v.left.toExpression(fieldName), "OR", v.right.toExpression(fieldName))
+type proximityValue struct {
+	phrase string
+	slop   int
 }
 
-type notValue struct {
-	value value
+func newProximityValue(phrase string, slop int) proximityValue {
+	return proximityValue{phrase: phrase, slop: slop}
 }
 
-func newNotValue(value value) notValue {
-	return notValue{value: value}
-}
-
-func (v notValue) toExpression(fieldName string) model.Expr {
-	return model.NewPrefixExpr("NOT", []model.Expr{v.value.toExpression(fieldName)})
-}
-
-type invalidValue struct {
+// toExpression returns e.g. match(field, '(?i)\bquick(?:\W+\w+){0,5}\W+fox\b') for "quick fox"~5:
+// terms have to appear in the given order, with at most `slop` other words between each two of them.
+func (v proximityValue) toExpression(fieldName string) model.Expr {
+	terms := strings.FieldsFunc(v.phrase, isNotWordCharacter)
+	for i, term := range terms {
+		terms[i] = regexp.QuoteMeta(term)
+	}
+	separator := `\W+`
+	if v.slop > 0 {
+		separator = fmt.Sprintf(`(?:\W+\w+){0,%d}\W+`, v.slop)
+	}
+	pattern := `(?i)\b` + strings.Join(terms, separator) + `\b`
+	return model.NewFunction("match", model.NewColumnRef(fieldName), model.NewLiteral(util.SingleQuote(pattern)))
 }
 
-func newInvalidValue() invalidValue {
-	return invalidValue{}
+type regexpValue struct {
+	pattern string
 }
 
-func (v invalidValue) toExpression(fieldName string) model.Expr {
-	return model.NewLiteral("false")
+func newRegexpValue(pattern string) regexpValue {
+	return regexpValue{pattern: pattern}
 }
 
-// buildValue builds a value from p.tokens
-// stack is a stack of previous values from the last ( (opening parenthesis)
-// parenthesisLevel == 0 <=> we're not inside parenthesis, 1 otherwise
-func (p *luceneParser) buildValue(stack []value, parenthesisLevel int) value {
-	for {
-		if len(p.tokens) == 0 {
-			logger.Error().Msgf("invalid Lucene expression, missing value, stack: %v, parenthesisLevel: %v", stack, parenthesisLevel)
-			return newInvalidValue()
-		}
-
-		tok := p.tokens[0]
-		p.tokens = p.tokens[1:]
-
-		// let's add the default OR separator, unless last token wasn't already an operator
-		var addOrSeparator bool
-		if _, currentTokenIsRightParenthesis := tok.(rightParenthesisToken); !currentTokenIsRightParenthesis && len(stack) > 0 {
-			switch stack[len(stack)-1].(type) {
-			case andValue, orValue, notValue:
-				addOrSeparator = false
-			default:
-				addOrSeparator = true
-			}
-		}
-
-		switch currentToken := tok.(type) {
-		case leftParenthesisToken:
-			stack = append(stack, p.buildValue([]value{}, 1))
-		case rightParenthesisToken:
-			if parenthesisLevel == 0 {
-				logger.Error().Msgf("invalid expression, unexpected right parenthesis, tokens: %v", p.tokens)
-				return newInvalidValue()
-			}
-			if len(stack) == 0 {
-				logger.Error().Msgf("invalid expression, can't have ) with an empty stack, tokens: %v", p.tokens)
-				return newInvalidValue()
-			}
-			for len(stack) > 1 {
-				stack = orLastTwoValues(stack)
-			}
-			return stack[0]
-		case andToken:
-			addOrSeparator = false
-			if len(stack) == 0 {
-				logger.Error().Msgf("invalid expression, can't have AND with an empty stack, tokens: %v", p.tokens)
-				return newInvalidValue()
-			}
-			stack = append(stack, p.buildValue([]value{}, 0))
-			and := newAndValue(stack[len(stack)-2], stack[len(stack)-1])
-			stack = stack[:len(stack)-2]
-			stack = append(stack, and)
-		case orToken:
-			addOrSeparator = false
-			if len(stack) == 0 {
-				logger.Error().Msgf("invalid expression, can't have OR with an empty stack, tokens: %v", p.tokens)
-				return newInvalidValue()
-			}
-			stack = append(stack, p.buildValue([]value{}, 0))
-			stack = orLastTwoValues(stack)
-		case notToken:
-			addOrSeparator = false
-			stack = append(stack, newNotValue(p.buildValue([]value{}, 0)))
-		case termToken:
-			stack = append(stack, newTermValue(currentToken.term))
-		case fuzzyToken:
-			stack = append(stack, newFuzzyValue(currentToken.term, currentToken.distance))
-		case rangeToken:
-			stack = append(stack, currentToken.rangeValue)
-		default:
-			logger.Error().Msgf("invalid expression, unexpected token %v, tokens: %v", currentToken, p.tokens)
-			return newInvalidValue()
-		}
-
-		if addOrSeparator {
-			stack = orLastTwoValues(stack)
-		}
-
-		if parenthesisLevel == 0 {
-			return stack[0]
-		}
-	}
+// toExpression translates regexp the same way `regexp` query does
+func (v regexpValue) toExpression(fieldName string) model.Expr {
+	clickhouseFuncName, patternExpr := regex.ToClickhouseExpr(v.pattern)
+	return model.NewInfixExpr(model.NewColumnRef(fieldName), clickhouseFuncName, patternExpr)
 }
 
-// orLastTwoValues pops the last two values from the stack, ORs them and pushes the result back to the stack.
-func orLastTwoValues(stack []value) []value {
-	or := newOrValue(stack[len(stack)-2], stack[len(stack)-1])
-	stack = stack[:len(stack)-2]
-	return append(stack, or)
+// isNotWordCharacter returns true <=> r separates words in a phrase
+func isNotWordCharacter(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
 }
 
 // alreadyQuoted returns true <=> len(s) >= 2 && s is already quoted (e.g. "abc")
@@ -0,0 +1,551 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package elastic_query_dsl
+
+import (
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/parsers/painful"
+	"github.com/QuesmaOrg/quesma/platform/schema"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"math"
+	"strings"
+)
+
+// Painless scripts are lowered to SQL expressions, so that runtime fields can be filtered, sorted and aggregated
+// by the database. Constructs, which can't be lowered, are reported as errors. Such scripts are evaluated
+// row by row in Go instead.
+
+// painlessType is a static type of lowered Painless expression, it's needed to pick the right SQL operator or function
+type painlessType int
+
+const (
+	painlessTypeUnknown painlessType = iota
+	painlessTypeNull
+	painlessTypeBool
+	painlessTypeInt
+	painlessTypeFloat
+	painlessTypeString
+	painlessTypeDate
+)
+
+func (t painlessType) isNumber() bool {
+	return t == painlessTypeInt || t == painlessTypeFloat
+}
+
+type loweredPainlessExpr struct {
+	expr model.Expr
+	typ  painlessType
+}
+
+type painlessLowering struct {
+	schema schema.Schema
+	params map[string]any
+}
+
+// LowerPainless translates a Painless script into an SQL expression. The value of the script is the emitted value,
+// or the value of the expression itself for scripts without `emit`, e.g. scripted fields.
+func LowerPainless(script painful.Expr, indexSchema schema.Schema, params map[string]any) (model.Expr, error) {
+	lowered, err := lowerPainlessScript(script, indexSchema, params)
+	if err != nil {
+		return nil, err
+	}
+	return lowered.expr, nil
+}
+
+// LowerPainlessCondition is like LowerPainless, but the script must be a boolean condition, e.g. of a `script` query
+func LowerPainlessCondition(script painful.Expr, indexSchema schema.Schema, params map[string]any) (model.Expr, error) {
+	lowered, err := lowerPainlessScript(script, indexSchema, params)
+	if err != nil {
+		return nil, err
+	}
+	if lowered.typ != painlessTypeBool {
+		return nil, fmt.Errorf("script must return a boolean")
+	}
+	return lowered.expr, nil
+}
+
+func lowerPainlessScript(script painful.Expr, indexSchema schema.Schema, params map[string]any) (loweredPainlessExpr, error) {
+	l := painlessLowering{schema: indexSchema, params: params}
+
+	// only scripts with a single statement can be lowered, e.g. `return doc['x'].value;`
+	if statements, ok := script.(*painful.ScriptExpr); ok && len(statements.Statements) == 1 {
+		if ret, ok := statements.Statements[0].(*painful.ReturnStatement); ok && ret.Expr != nil {
+			script = ret.Expr
+		}
+	}
+
+	if emit, ok := script.(*painful.EmitExpr); ok {
+		script = emit.Expr
+	}
+
+	return l.lower(script)
+}
+
+func (l painlessLowering) lower(expr painful.Expr) (loweredPainlessExpr, error) {
+
+	switch e := expr.(type) {
+
+	case *painful.LiteralExpr:
+		return lowerPainlessValue(e.Value)
+
+	case *painful.DocExpr:
+		return l.lowerDoc(e)
+
+	case *painful.AccessorExpr:
+		return l.lowerAccessor(e)
+
+	case *painful.IndexExpr:
+		if name, ok := l.paramsKey(e.Expr, e.Index); ok {
+			return l.lowerParam(name)
+		}
+		return loweredPainlessExpr{}, fmt.Errorf("%s: indexing is supported only for params", e.Position)
+
+	case *painful.MethodCallExpr:
+		return l.lowerMethodCall(e)
+
+	case *painful.InfixOpExpr:
+		return l.lowerInfix(e)
+
+	case *painful.PrefixOpExpr:
+		inner, err := l.lower(e.Expr)
+		if err != nil {
+			return loweredPainlessExpr{}, err
+		}
+		switch {
+		case e.Op == "!" && inner.typ == painlessTypeBool:
+			return loweredPainlessExpr{model.NewPrefixExpr("NOT", []model.Expr{inner.expr}), painlessTypeBool}, nil
+		case e.Op == "-" && inner.typ.isNumber():
+			return loweredPainlessExpr{model.NewFunction("negate", inner.expr), inner.typ}, nil
+		}
+		return loweredPainlessExpr{}, fmt.Errorf("%s: '%s' operator can't be lowered for this operand", e.Position, e.Op)
+
+	case *painful.ConditionalExpr:
+		return l.lowerConditional(e)
+
+	case *painful.UrlEncodeExpr:
+		inner, err := l.lower(e.Expr)
+		if err != nil {
+			return loweredPainlessExpr{}, err
+		}
+		// encodes spaces as '+', like Java's URLEncoder
+		return loweredPainlessExpr{model.NewFunction("encodeURLFormComponent", l.toString(inner)), painlessTypeString}, nil
+
+	case *painful.EmitExpr:
+		return loweredPainlessExpr{}, fmt.Errorf("emit is supported only as the outermost expression")
+
+	case *painful.VariableExpr:
+		return loweredPainlessExpr{}, fmt.Errorf("%s: variable '%s' can't be lowered", e.Position, e.Name)
+
+	default:
+		return loweredPainlessExpr{}, fmt.Errorf("unsupported painless expression: %T", expr)
+	}
+}
+
+// lowerPainlessValue lowers literals and values of params
+func lowerPainlessValue(value any) (loweredPainlessExpr, error) {
+	switch v := value.(type) {
+	case nil:
+		return loweredPainlessExpr{model.NewLiteral("NULL"), painlessTypeNull}, nil
+	case bool:
+		return loweredPainlessExpr{model.NewLiteral(v), painlessTypeBool}, nil
+	case string:
+		return loweredPainlessExpr{model.NewLiteral(util.SingleQuote(v)), painlessTypeString}, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return loweredPainlessExpr{model.NewLiteral(v), painlessTypeInt}, nil
+	case float32:
+		return lowerPainlessValue(float64(v))
+	case float64:
+		// numbers of params come from JSON, integral ones are integers in Painless
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return loweredPainlessExpr{model.NewLiteral(int64(v)), painlessTypeInt}, nil
+		}
+		return loweredPainlessExpr{model.NewLiteral(v), painlessTypeFloat}, nil
+	default:
+		return loweredPainlessExpr{}, fmt.Errorf("value of type %T can't be lowered", value)
+	}
+}
+
+func (l painlessLowering) lowerDoc(doc *painful.DocExpr) (loweredPainlessExpr, error) {
+
+	var fieldName string
+	switch name := doc.FieldName.(type) {
+	case *painful.LiteralExpr:
+		fieldName, _ = name.Value.(string)
+	// doc[params.field] and doc[params['field']] are fine, as long as the param is a string
+	case *painful.AccessorExpr:
+		if key, ok := l.paramsKey(name.Expr, &painful.LiteralExpr{Value: name.PropertyName}); ok {
+			fieldName, _ = l.params[key].(string)
+		}
+	case *painful.IndexExpr:
+		if key, ok := l.paramsKey(name.Expr, name.Index); ok {
+			fieldName, _ = l.params[key].(string)
+		}
+	}
+	if fieldName == "" {
+		return loweredPainlessExpr{}, fmt.Errorf("doc field name must be a string literal")
+	}
+
+	field, ok := l.schema.ResolveField(fieldName)
+	if !ok {
+		return loweredPainlessExpr{}, fmt.Errorf("field '%s' not found in schema", fieldName)
+	}
+
+	var typ painlessType
+	switch field.Type.Name {
+	case schema.QuesmaTypeText.Name, schema.QuesmaTypeKeyword.Name, schema.QuesmaTypeWildcard.Name, schema.QuesmaTypeVersion.Name,
+		schema.QuesmaTypeConstantKeyword.Name, schema.QuesmaTypeMatchOnlyText.Name:
+		typ = painlessTypeString
+	case schema.QuesmaTypeInteger.Name, schema.QuesmaTypeLong.Name, schema.QuesmaTypeUnsignedLong.Name:
+		typ = painlessTypeInt
+	case schema.QuesmaTypeFloat.Name, schema.QuesmaTypeScaledFloat.Name:
+		typ = painlessTypeFloat
+	case schema.QuesmaTypeTimestamp.Name, schema.QuesmaTypeDate.Name:
+		typ = painlessTypeDate
+	case schema.QuesmaTypeBoolean.Name:
+		typ = painlessTypeBool
+	default:
+		return loweredPainlessExpr{}, fmt.Errorf("field '%s' of type %s can't be used in scripts", fieldName, field.Type.Name)
+	}
+
+	return loweredPainlessExpr{model.NewColumnRef(fieldName), typ}, nil
+}
+
+// datePainlessGetters maps getters of ZonedDateTime to SQL functions. Painless allows both `value.getHour()` and `value.hour`.
+var datePainlessGetters = map[string]string{
+	"getYear":       "toYear",
+	"getMonthValue": "toMonth",
+	"getDayOfMonth": "toDayOfMonth",
+	"getDayOfYear":  "toDayOfYear",
+	"getHour":       model.DateHourFunction,
+	"getMinute":     "toMinute",
+	"getSecond":     "toSecond",
+}
+
+func (l painlessLowering) lowerAccessor(accessor *painful.AccessorExpr) (loweredPainlessExpr, error) {
+
+	if name, ok := l.paramsKey(accessor.Expr, &painful.LiteralExpr{Value: accessor.PropertyName}); ok {
+		return l.lowerParam(name)
+	}
+
+	inner, err := l.lower(accessor.Expr)
+	if err != nil {
+		return loweredPainlessExpr{}, err
+	}
+
+	if _, isDoc := accessor.Expr.(*painful.DocExpr); isDoc {
+		switch accessor.PropertyName {
+		case "value":
+			return inner, nil
+		case "empty":
+			return loweredPainlessExpr{model.NewInfixExpr(inner.expr, "IS", model.NewLiteral("NULL")), painlessTypeBool}, nil
+		}
+	}
+
+	if inner.typ == painlessTypeDate {
+		switch accessor.PropertyName {
+		case "millis":
+			return l.lowerDateMethod(accessor.Position, inner, "toEpochMilli")
+		default:
+			getter := "get" + strings.ToUpper(accessor.PropertyName[:1]) + accessor.PropertyName[1:]
+			if _, ok := datePainlessGetters[getter]; ok {
+				return l.lowerDateMethod(accessor.Position, inner, getter)
+			}
+		}
+	}
+
+	return loweredPainlessExpr{}, fmt.Errorf("%s: property '%s' can't be lowered", accessor.Position, accessor.PropertyName)
+}
+
+// paramsKey checks if the expression is `params.key` or `params['key']`
+func (l painlessLowering) paramsKey(expr painful.Expr, index painful.Expr) (string, bool) {
+	variable, ok := expr.(*painful.VariableExpr)
+	if !ok || variable.Name != painful.ParamsVariableName {
+		return "", false
+	}
+	literal, ok := index.(*painful.LiteralExpr)
+	if !ok {
+		return "", false
+	}
+	key, ok := literal.Value.(string)
+	return key, ok
+}
+
+func (l painlessLowering) lowerParam(name string) (loweredPainlessExpr, error) {
+	value, ok := l.params[name]
+	if !ok {
+		return loweredPainlessExpr{}, fmt.Errorf("param '%s' is not defined", name)
+	}
+	return lowerPainlessValue(value)
+}
+
+func (l painlessLowering) lowerMethodCall(call *painful.MethodCallExpr) (loweredPainlessExpr, error) {
+
+	// static methods of Math
+	if variable, ok := call.Expr.(*painful.VariableExpr); ok && variable.Name == "Math" {
+		return l.lowerMathMethod(call)
+	}
+
+	target, err := l.lower(call.Expr)
+	if err != nil {
+		return loweredPainlessExpr{}, err
+	}
+
+	// doc values are lists in Elasticsearch, `doc['field'].size() == 0` checks if the document has the field
+	if _, isDoc := call.Expr.(*painful.DocExpr); isDoc && len(call.Args) == 0 {
+		switch call.MethodName {
+		case "size":
+			return loweredPainlessExpr{model.NewFunction("if", model.NewInfixExpr(target.expr, "IS", model.NewLiteral("NULL")), model.NewLiteral(0), model.NewLiteral(1)), painlessTypeInt}, nil
+		case "isEmpty":
+			return loweredPainlessExpr{model.NewInfixExpr(target.expr, "IS", model.NewLiteral("NULL")), painlessTypeBool}, nil
+		}
+	}
+
+	args := make([]loweredPainlessExpr, 0, len(call.Args))
+	for _, arg := range call.Args {
+		lowered, err := l.lower(arg)
+		if err != nil {
+			return loweredPainlessExpr{}, err
+		}
+		args = append(args, lowered)
+	}
+
+	switch target.typ {
+	case painlessTypeDate:
+		if len(args) == 0 {
+			return l.lowerDateMethod(call.Position, target, call.MethodName)
+		}
+	case painlessTypeString:
+		return l.lowerStringMethod(call, target, args)
+	}
+
+	return loweredPainlessExpr{}, fmt.Errorf("%s: method '%s' can't be lowered", call.Position, call.MethodName)
+}
+
+func (l painlessLowering) lowerDateMethod(position painful.Position, date loweredPainlessExpr, method string) (loweredPainlessExpr, error) {
+	if function, ok := datePainlessGetters[method]; ok {
+		return loweredPainlessExpr{model.NewFunction(function, date.expr), painlessTypeInt}, nil
+	}
+	switch method {
+	case "toEpochMilli", "getMillis":
+		return loweredPainlessExpr{model.NewFunction("toUnixTimestamp64Milli", model.NewFunction("toDateTime64", date.expr, model.NewLiteral(3))), painlessTypeInt}, nil
+	}
+	return loweredPainlessExpr{}, fmt.Errorf("%s: date method '%s' can't be lowered", position, method)
+}
+
+func (l painlessLowering) lowerStringMethod(call *painful.MethodCallExpr, str loweredPainlessExpr, args []loweredPainlessExpr) (loweredPainlessExpr, error) {
+
+	stringArg := func(i int) model.Expr {
+		return l.toString(args[i])
+	}
+	// Java indexes are 0-based, SQL ones are 1-based
+	indexArg := func(i int) model.Expr {
+		return model.NewParenExpr(model.NewInfixExpr(args[i].expr, "+", model.NewLiteral(1)))
+	}
+
+	switch {
+	case call.MethodName == "length" && len(args) == 0:
+		return loweredPainlessExpr{model.NewFunction("lengthUTF8", str.expr), painlessTypeInt}, nil
+	case call.MethodName == "isEmpty" && len(args) == 0:
+		return loweredPainlessExpr{model.NewFunction("empty", str.expr), painlessTypeBool}, nil
+	case call.MethodName == "toLowerCase" && len(args) == 0:
+		return loweredPainlessExpr{model.NewFunction("lowerUTF8", str.expr), painlessTypeString}, nil
+	case call.MethodName == "toUpperCase" && len(args) == 0:
+		return loweredPainlessExpr{model.NewFunction("upperUTF8", str.expr), painlessTypeString}, nil
+	case call.MethodName == "trim" && len(args) == 0:
+		return loweredPainlessExpr{model.NewFunction("trimBoth", str.expr), painlessTypeString}, nil
+	case call.MethodName == "contains" && len(args) == 1:
+		return loweredPainlessExpr{model.NewParenExpr(model.NewInfixExpr(model.NewFunction("position", str.expr, stringArg(0)), ">", model.NewLiteral(0))), painlessTypeBool}, nil
+	case call.MethodName == "startsWith" && len(args) == 1:
+		return loweredPainlessExpr{model.NewFunction("startsWith", str.expr, stringArg(0)), painlessTypeBool}, nil
+	case call.MethodName == "endsWith" && len(args) == 1:
+		return loweredPainlessExpr{model.NewFunction("endsWith", str.expr, stringArg(0)), painlessTypeBool}, nil
+	case call.MethodName == "equals" && len(args) == 1:
+		return loweredPainlessExpr{model.NewParenExpr(model.NewInfixExpr(str.expr, "=", args[0].expr)), painlessTypeBool}, nil
+	case call.MethodName == "indexOf" && len(args) == 1:
+		return loweredPainlessExpr{model.NewParenExpr(model.NewInfixExpr(model.NewFunction("positionUTF8", str.expr, stringArg(0)), "-", model.NewLiteral(1))), painlessTypeInt}, nil
+	case call.MethodName == "replace" && len(args) == 2:
+		return loweredPainlessExpr{model.NewFunction("replaceAll", str.expr, stringArg(0), stringArg(1)), painlessTypeString}, nil
+	case call.MethodName == "substring" && len(args) == 1 && args[0].typ == painlessTypeInt:
+		return loweredPainlessExpr{model.NewFunction("substringUTF8", str.expr, indexArg(0)), painlessTypeString}, nil
+	case call.MethodName == "substring" && len(args) == 2 && args[0].typ == painlessTypeInt && args[1].typ == painlessTypeInt:
+		length := model.NewParenExpr(model.NewInfixExpr(args[1].expr, "-", args[0].expr))
+		return loweredPainlessExpr{model.NewFunction("substringUTF8", str.expr, indexArg(0), length), painlessTypeString}, nil
+	}
+
+	return loweredPainlessExpr{}, fmt.Errorf("%s: string method '%s' with %d arguments can't be lowered", call.Position, call.MethodName, len(args))
+}
+
+func (l painlessLowering) lowerMathMethod(call *painful.MethodCallExpr) (loweredPainlessExpr, error) {
+
+	args := make([]model.Expr, 0, len(call.Args))
+	resultType := painlessTypeInt
+	for _, arg := range call.Args {
+		lowered, err := l.lower(arg)
+		if err != nil {
+			return loweredPainlessExpr{}, err
+		}
+		if !lowered.typ.isNumber() {
+			return loweredPainlessExpr{}, fmt.Errorf("%s: Math.%s accepts only numbers", call.Position, call.MethodName)
+		}
+		if lowered.typ == painlessTypeFloat {
+			resultType = painlessTypeFloat
+		}
+		args = append(args, lowered.expr)
+	}
+
+	switch {
+	case call.MethodName == "abs" && len(args) == 1:
+		return loweredPainlessExpr{model.NewFunction("abs", args...), resultType}, nil
+	case (call.MethodName == "max" || call.MethodName == "min") && len(args) == 2:
+		function := map[string]string{"max": "greatest", "min": "least"}[call.MethodName]
+		return loweredPainlessExpr{model.NewFunction(function, args...), resultType}, nil
+	case call.MethodName == "round" && len(args) == 1:
+		// Java rounds half up, while ClickHouse's round uses banker's rounding for floats
+		return loweredPainlessExpr{model.NewFunction("toInt64", model.NewFunction("floor", model.NewParenExpr(model.NewInfixExpr(args[0], "+", model.NewLiteral(0.5))))), painlessTypeInt}, nil
+	case (call.MethodName == "floor" || call.MethodName == "ceil" || call.MethodName == "sqrt") && len(args) == 1:
+		return loweredPainlessExpr{model.NewFunction(call.MethodName, args...), painlessTypeFloat}, nil
+	case call.MethodName == "pow" && len(args) == 2:
+		return loweredPainlessExpr{model.NewFunction("pow", args...), painlessTypeFloat}, nil
+	}
+
+	return loweredPainlessExpr{}, fmt.Errorf("%s: Math.%s with %d arguments can't be lowered", call.Position, call.MethodName, len(args))
+}
+
+var painlessComparisonOperators = map[string]string{
+	"==": "=",
+	"!=": "!=",
+	"<":  "<",
+	"<=": "<=",
+	">":  ">",
+	">=": ">=",
+}
+
+func (l painlessLowering) lowerInfix(infix *painful.InfixOpExpr) (loweredPainlessExpr, error) {
+
+	left, err := l.lower(infix.Left)
+	if err != nil {
+		return loweredPainlessExpr{}, err
+	}
+	right, err := l.lower(infix.Right)
+	if err != nil {
+		return loweredPainlessExpr{}, err
+	}
+
+	cannotLower := fmt.Errorf("%s: '%s' operator can't be lowered for these operands", infix.Position, infix.Op)
+
+	switch infix.Op {
+
+	case "&&", "||":
+		if left.typ != painlessTypeBool || right.typ != painlessTypeBool {
+			return loweredPainlessExpr{}, cannotLower
+		}
+		op := map[string]string{"&&": "AND", "||": "OR"}[infix.Op]
+		return loweredPainlessExpr{model.NewInfixExpr(left.expr, op, right.expr), painlessTypeBool}, nil
+
+	case "==", "!=":
+		// comparing with null checks presence of the value
+		if left.typ == painlessTypeNull || right.typ == painlessTypeNull {
+			value := left
+			if left.typ == painlessTypeNull {
+				value = right
+			}
+			check := map[string]string{"==": "NULL", "!=": "NOT NULL"}[infix.Op]
+			return loweredPainlessExpr{model.NewInfixExpr(value.expr, "IS", model.NewLiteral(check)), painlessTypeBool}, nil
+		}
+		if left.typ != right.typ && !(left.typ.isNumber() && right.typ.isNumber()) {
+			return loweredPainlessExpr{}, cannotLower
+		}
+		return loweredPainlessExpr{model.NewParenExpr(model.NewInfixExpr(left.expr, painlessComparisonOperators[infix.Op], right.expr)), painlessTypeBool}, nil
+
+	case "<", "<=", ">", ">=":
+		comparable := (left.typ.isNumber() && right.typ.isNumber()) ||
+			(left.typ == right.typ && (left.typ == painlessTypeString || left.typ == painlessTypeDate))
+		if !comparable {
+			return loweredPainlessExpr{}, cannotLower
+		}
+		return loweredPainlessExpr{model.NewParenExpr(model.NewInfixExpr(left.expr, painlessComparisonOperators[infix.Op], right.expr)), painlessTypeBool}, nil
+
+	case "+":
+		if left.typ == painlessTypeString || right.typ == painlessTypeString {
+			return loweredPainlessExpr{model.NewFunction("concat", l.toString(left), l.toString(right)), painlessTypeString}, nil
+		}
+		fallthrough
+
+	case "-", "*", "/", "%":
+		if !left.typ.isNumber() || !right.typ.isNumber() {
+			return loweredPainlessExpr{}, cannotLower
+		}
+		typ := painlessTypeInt
+		if left.typ == painlessTypeFloat || right.typ == painlessTypeFloat {
+			typ = painlessTypeFloat
+		}
+		// division of integers is an integer in Java
+		if infix.Op == "/" && typ == painlessTypeInt {
+			return loweredPainlessExpr{model.NewFunction("intDiv", left.expr, right.expr), typ}, nil
+		}
+		return loweredPainlessExpr{model.NewParenExpr(model.NewInfixExpr(left.expr, infix.Op, right.expr)), typ}, nil
+	}
+
+	return loweredPainlessExpr{}, cannotLower
+}
+
+func (l painlessLowering) lowerConditional(conditional *painful.ConditionalExpr) (loweredPainlessExpr, error) {
+
+	cond, err := l.lower(conditional.Cond)
+	if err != nil {
+		return loweredPainlessExpr{}, err
+	}
+	if cond.typ != painlessTypeBool {
+		return loweredPainlessExpr{}, fmt.Errorf("%s: condition must be a boolean", conditional.Position)
+	}
+
+	then, err := l.lower(conditional.Then)
+	if err != nil {
+		return loweredPainlessExpr{}, err
+	}
+	els, err := l.lower(conditional.Else)
+	if err != nil {
+		return loweredPainlessExpr{}, err
+	}
+
+	typ := then.typ
+	switch {
+	case then.typ == els.typ || els.typ == painlessTypeNull:
+	case then.typ == painlessTypeNull:
+		typ = els.typ
+	case then.typ.isNumber() && els.typ.isNumber():
+		typ = painlessTypeFloat
+	default:
+		return loweredPainlessExpr{}, fmt.Errorf("%s: branches of the conditional have different types", conditional.Position)
+	}
+
+	return loweredPainlessExpr{model.NewFunction("if", cond.expr, then.expr, els.expr), typ}, nil
+}
+
+// toString converts the value for string concatenation, like Java does
+func (l painlessLowering) toString(expr loweredPainlessExpr) model.Expr {
+	if expr.typ == painlessTypeString {
+		return expr.expr
+	}
+	return model.NewFunction("toString", expr.expr)
+}
+
+// LowerPainlessSortKey lowers the script of a `_script` sort. The sort type is either `number` or `string`, like in Elasticsearch.
+func LowerPainlessSortKey(script painful.Expr, indexSchema schema.Schema, params map[string]any, sortType string) (model.Expr, error) {
+	lowered, err := lowerPainlessScript(script, indexSchema, params)
+	if err != nil {
+		return nil, err
+	}
+	switch sortType {
+	case "number":
+		if !lowered.typ.isNumber() {
+			return nil, fmt.Errorf("script of a number sort must return a number")
+		}
+		return lowered.expr, nil
+	case "string":
+		return painlessLowering{}.toString(lowered), nil
+	default:
+		return nil, fmt.Errorf("unsupported script sort type: [%s]", sortType)
+	}
+}
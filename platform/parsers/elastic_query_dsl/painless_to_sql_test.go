@@ -0,0 +1,142 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package elastic_query_dsl
+
+import (
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/parsers/painful"
+	"github.com/QuesmaOrg/quesma/platform/schema"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+var painlessTestSchema = schema.Schema{Fields: map[schema.FieldName]schema.Field{
+	"message":    {PropertyName: "message", InternalPropertyName: "message", Type: schema.QuesmaTypeText},
+	"host":       {PropertyName: "host", InternalPropertyName: "host", Type: schema.QuesmaTypeKeyword},
+	"bytes":      {PropertyName: "bytes", InternalPropertyName: "bytes", Type: schema.QuesmaTypeLong},
+	"price":      {PropertyName: "price", InternalPropertyName: "price", Type: schema.QuesmaTypeFloat},
+	"@timestamp": {PropertyName: "@timestamp", InternalPropertyName: "@timestamp", Type: schema.QuesmaTypeTimestamp},
+	"is_error":   {PropertyName: "is_error", InternalPropertyName: "is_error", Type: schema.QuesmaTypeBoolean},
+	"location":   {PropertyName: "location", InternalPropertyName: "location", Type: schema.QuesmaTypePoint},
+}}
+
+func TestLowerPainless(t *testing.T) {
+	tests := []struct {
+		script      string
+		params      map[string]any
+		expectedSQL string
+	}{
+		{"emit(doc['bytes'].value)", nil, `"bytes"`},
+		{"emit(doc['bytes'].value * 8 + 1)", nil, `(("bytes"*8)+1)`},
+		{"emit(doc['bytes'].value / 1024)", nil, `intDiv("bytes",1024)`},
+		{"emit(doc['price'].value / 2)", nil, `("price"/2)`},
+		{"emit(-doc['price'].value % 2)", nil, `(negate("price")%2)`},
+		{"emit(doc['host'].value + ':' + doc['bytes'].value)", nil, `concat(concat("host",':'),toString("bytes"))`},
+		{"emit(doc['host'].value.toLowerCase().substring(0, 3))", nil, `substringUTF8(lowerUTF8("host"),(0+1),(3-0))`},
+		{"emit(doc['message'].value.contains('error'))", nil, `(position("message",'error')>0)`},
+		{"emit(doc['message'].value.length())", nil, `lengthUTF8("message")`},
+		{"emit(doc['bytes'].value > 1000 ? 'big' : 'small')", nil, `if(("bytes">1000),'big','small')`},
+		{"emit(doc['host'].value == null || !doc['is_error'].value)", nil, `("host" IS NULL OR NOT ("is_error"))`},
+		{"emit(doc['@timestamp'].value.getHour())", nil, `__quesma_date_hour("@timestamp")`},
+		{"emit(doc['@timestamp'].value.dayOfMonth)", nil, `toDayOfMonth("@timestamp")`},
+		{"emit(doc['@timestamp'].value.toEpochMilli())", nil, `toUnixTimestamp64Milli(toDateTime64("@timestamp",3))`},
+		{"emit(doc['bytes'].value * params.factor)", map[string]any{"factor": 2.0}, `("bytes"*2)`},
+		{"emit(doc[params['field']].value + params.suffix)", map[string]any{"field": "host", "suffix": "'s"}, `concat("host",'\'s')`},
+		{"emit(Math.round(doc['price'].value))", nil, `toInt64(floor(("price"+0.5)))`},
+		{"doc['bytes'].value * 2", nil, `("bytes"*2)`},
+		{"return doc['host'].empty ? 'none' : doc['host'].value;", nil, `if("host" IS NULL,'none',"host")`},
+		{"emit(doc['host'].size() == 0)", nil, `(if("host" IS NULL,0,1)=0)`},
+	}
+
+	for i, tt := range tests {
+		t.Run(util.PrettyTestName(tt.script, i), func(t *testing.T) {
+			script, err := painful.ParsePainless(tt.script)
+			require.NoError(t, err)
+
+			sql, err := LowerPainless(script, painlessTestSchema, tt.params)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedSQL, model.AsString(sql))
+		})
+	}
+}
+
+func TestLowerPainlessUnsupported(t *testing.T) {
+	scripts := []string{
+		"emit(doc['unknown'].value)",
+		"emit(doc['location'].value)",
+		"emit(doc['host'].value - 1)",
+		"emit(doc['@timestamp'].value.formatISO8601())",
+		"emit(doc['bytes'].value > 0 ? 'positive' : 0)",
+		"emit(params.missing)",
+		"emit(values)",
+		"def x = doc['bytes'].value; return x * 2;",
+	}
+
+	for i, s := range scripts {
+		t.Run(util.PrettyTestName(s, i), func(t *testing.T) {
+			script, err := painful.ParsePainless(s)
+			require.NoError(t, err)
+
+			_, err = LowerPainless(script, painlessTestSchema, nil)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLowerPainlessDocFieldFromParams(t *testing.T) {
+	indexSchema := schema.Schema{Fields: map[schema.FieldName]schema.Field{
+		`user's \name`: {PropertyName: `user's \name`, InternalPropertyName: `user's \name`, Type: schema.QuesmaTypeKeyword},
+	}}
+
+	for _, source := range []string{"emit(doc[params.field].value)", "emit(doc[params['field']].value)"} {
+		script, err := painful.ParsePainless(source)
+		require.NoError(t, err)
+
+		sql, err := LowerPainless(script, indexSchema, map[string]any{"field": `user's \name`})
+		require.NoError(t, err, source)
+		assert.Equal(t, model.NewColumnRef(`user's \name`), sql, source)
+
+		_, err = LowerPainless(script, indexSchema, map[string]any{"field": 1.0})
+		assert.Error(t, err, "field name must be a string")
+	}
+}
+
+func TestParseRuntimeMappings(t *testing.T) {
+	body := types.JSON{
+		"runtime_mappings": map[string]any{
+			"kilobytes": map[string]any{
+				"type":   "long",
+				"script": map[string]any{"source": "emit(doc['bytes'].value / params.unit)", "params": map[string]any{"unit": 1024.0}},
+			},
+			"iso_date": map[string]any{
+				"type":   "keyword",
+				"script": map[string]any{"source": "emit(doc['@timestamp'].value.formatISO8601())"},
+			},
+		},
+		"script_fields": map[string]any{
+			"host_upper": map[string]any{
+				"script": map[string]any{"source": "doc['host'].value.toUpperCase()", "lang": "painless"},
+			},
+			"not_parsed": map[string]any{
+				"script": map[string]any{"source": "doc['host'].value +"},
+			},
+		},
+	}
+
+	mappings, err := ParseRuntimeMappings(body, painlessTestSchema)
+	require.NoError(t, err)
+	require.Len(t, mappings, 3)
+
+	assert.Equal(t, `intDiv("bytes",1024)`, model.AsString(mappings["kilobytes"].DatabaseExpression))
+	assert.Nil(t, mappings["kilobytes"].PostProcessExpression)
+
+	// falls back to evaluation in Go
+	assert.Equal(t, `NULL`, model.AsString(mappings["iso_date"].DatabaseExpression))
+	assert.NotNil(t, mappings["iso_date"].PostProcessExpression)
+
+	assert.Equal(t, `upperUTF8("host")`, model.AsString(mappings["host_upper"].DatabaseExpression))
+}
@@ -28,24 +28,30 @@ func (cw *ClickhouseQueryTranslator) pancakeTryBucketAggregation(aggregation *pa
 	}{
 		{"histogram", cw.parseHistogram},
 		{"date_histogram", cw.parseDateHistogram},
-		{"terms", func(node *pancakeAggregationTreeNode, params QueryMap) error {
-			return cw.parseTermsAggregation(node, params, "terms")
-		}},
+		{"terms", cw.parseTermsAggregation},
+		{"rare_terms", cw.parseRareTerms},
 		{"filters", cw.parseFilters},
 		{"sampler", cw.parseSampler},
+		{"diversified_sampler", cw.parseDiversifiedSampler},
 		{"random_sampler", cw.parseRandomSampler},
+		{"variable_width_histogram", cw.parseVariableWidthHistogram},
 		{"date_range", cw.parseDateRangeAggregation},
 		{"range", cw.parseRangeAggregation},
 		{"auto_date_histogram", cw.parseAutoDateHistogram},
 		{"geotile_grid", cw.parseGeotileGrid},
 		{"geohash_grid", cw.parseGeohashGrid},
 		{"significant_terms", func(node *pancakeAggregationTreeNode, params QueryMap) error {
-			return cw.parseTermsAggregation(node, params, "significant_terms")
+			return cw.parseSignificantTerms(node, params, "significant_terms")
+		}},
+		{"significant_text", func(node *pancakeAggregationTreeNode, params QueryMap) error {
+			return cw.parseSignificantTerms(node, params, "significant_text")
 		}},
 		{"multi_terms", cw.parseMultiTerms},
 		{"composite", cw.parseComposite},
 		{"ip_range", cw.parseIpRange},
 		{"ip_prefix", cw.parseIpPrefix},
+		{"nested", cw.parseNestedAggregation},
+		{"reverse_nested", cw.parseReverseNested},
 	}
 
 	for _, aggr := range aggregationHandlers {
@@ -105,6 +111,25 @@ func (cw *ClickhouseQueryTranslator) parseHistogram(aggregation *pancakeAggregat
 	return nil
 }
 
+// parseVariableWidthHistogram only parses params. Bucket key depends on the query's WHERE clause,
+// so it's set later, in pancakeTransformer.transformVariableWidthHistogram.
+func (cw *ClickhouseQueryTranslator) parseVariableWidthHistogram(aggregation *pancakeAggregationTreeNode, params QueryMap) error {
+	const defaultBucketsNr = 10
+	field := cw.parseFieldField(params, "variable_width_histogram")
+	if field == nil {
+		return fmt.Errorf("field is required in variable_width_histogram: %v", params)
+	}
+	for _, ignoredParam := range []string{"shard_size", "initial_buffer"} {
+		if _, exists := params[ignoredParam]; exists {
+			logger.WarnWithCtx(cw.Ctx).Msgf("%s is not supported in variable_width_histogram, ignoring it. params: %v", ignoredParam, params)
+		}
+	}
+
+	aggregation.queryType = bucket_aggregations.NewVariableWidthHistogram(cw.Ctx, field, cw.parseIntField(params, "buckets", defaultBucketsNr))
+	aggregation.filterOutEmptyKeyBucket = true
+	return nil
+}
+
 func (cw *ClickhouseQueryTranslator) parseDateHistogram(aggregation *pancakeAggregationTreeNode, params QueryMap) (err error) {
 	field := cw.parseFieldField(params, "date_histogram")
 	dateTimeType := cw.Table.GetDateTimeTypeFromExpr(cw.Ctx, field)
@@ -154,8 +179,7 @@ func (cw *ClickhouseQueryTranslator) parseDateHistogram(aggregation *pancakeAggr
 	return nil
 }
 
-// aggrName - "terms" or "significant_terms"
-func (cw *ClickhouseQueryTranslator) parseTermsAggregation(aggregation *pancakeAggregationTreeNode, params QueryMap, aggrName string) error {
+func (cw *ClickhouseQueryTranslator) parseTermsAggregation(aggregation *pancakeAggregationTreeNode, params QueryMap) error {
 	if err := bucket_aggregations.CheckParamsTerms(cw.Ctx, params); err != nil {
 		return err
 	}
@@ -166,12 +190,10 @@ func (cw *ClickhouseQueryTranslator) parseTermsAggregation(aggregation *pancakeA
 	)
 
 	minDocCount := cw.parseIntField(params, "min_doc_count", defaultMinDocCount)
-	terms := bucket_aggregations.NewTerms(
-		cw.Ctx, aggrName == "significant_terms", minDocCount, params["include"], params["exclude"],
-	)
+	terms := bucket_aggregations.NewTerms(cw.Ctx, minDocCount, params["include"], params["exclude"])
 
 	var didWeAddMissing, didWeUpdateFieldHere bool
-	field, isFromScript := cw.parseFieldFieldMaybeScript(params, aggrName)
+	field, isFromScript := cw.parseFieldFieldMaybeScript(params, "terms")
 	if !isFromScript {
 		// We currently don't support both 'script' and any of ['include', 'exclude', 'missing'] at the same time
 		// as it's not completely obvious how to handle it. Let's wait for a use case.
@@ -219,6 +241,133 @@ func (cw *ClickhouseQueryTranslator) parseTermsAggregation(aggregation *pancakeA
 	return nil
 }
 
+func (cw *ClickhouseQueryTranslator) parseRareTerms(aggregation *pancakeAggregationTreeNode, params QueryMap) error {
+	const defaultMaxDocCount = 1
+
+	maxDocCount := cw.parseIntField(params, "max_doc_count", defaultMaxDocCount)
+	if maxDocCount < 1 || maxDocCount > bucket_aggregations.RareTermsMaxDocCountLimit {
+		return fmt.Errorf("max_doc_count in rare_terms must be between 1 and %d, got: %d",
+			bucket_aggregations.RareTermsMaxDocCountLimit, maxDocCount)
+	}
+	if _, exists := params["include"]; exists {
+		logger.WarnWithCtx(cw.Ctx).Msgf("include is not supported in rare_terms, ignoring it. params: %v", params)
+	}
+	rareTerms := bucket_aggregations.NewRareTerms(cw.Ctx, maxDocCount, params["exclude"])
+
+	field := cw.parseFieldField(params, "rare_terms")
+	if field == nil {
+		return fmt.Errorf("field is required in rare_terms: %v", params)
+	}
+	field, didWeAddMissing := cw.addMissingParameterIfPresent(field, params)
+	field, didWeUpdateFieldHere := rareTerms.UpdateFieldForIncludeAndExclude(field)
+	if !didWeAddMissing || didWeUpdateFieldHere {
+		aggregation.filterOutEmptyKeyBucket = true
+	}
+
+	aggregation.queryType = rareTerms
+	aggregation.selectedColumns = append(aggregation.selectedColumns, field)
+	// least frequent first, then by key (key is added to ORDER BY by default)
+	aggregation.orderBy = []model.OrderByExpr{model.NewOrderByExpr(model.NewCountFunc(), model.AscOrder)}
+	return nil
+}
+
+// aggrName - "significant_terms" or "significant_text"
+func (cw *ClickhouseQueryTranslator) parseSignificantTerms(aggregation *pancakeAggregationTreeNode, params QueryMap, aggrName string) error {
+	const (
+		defaultSize        = 10
+		defaultMinDocCount = 3
+	)
+	isText := aggrName == "significant_text"
+
+	heuristic, err := cw.parseSignificanceHeuristic(params)
+	if err != nil {
+		return err
+	}
+
+	var backgroundFilter model.Expr
+	if backgroundFilterRaw, exists := params["background_filter"]; exists {
+		backgroundFilterMap, ok := backgroundFilterRaw.(QueryMap)
+		if !ok {
+			return fmt.Errorf("background_filter is not a map, but %T, value: %v", backgroundFilterRaw, backgroundFilterRaw)
+		}
+		backgroundFilterQuery := cw.parseQueryMap(backgroundFilterMap)
+		if !backgroundFilterQuery.CanParse {
+			return fmt.Errorf("cannot parse background_filter of %s: %v", aggrName, backgroundFilterMap)
+		}
+		backgroundFilter = backgroundFilterQuery.WhereClause
+	}
+	for _, ignoredParam := range []string{"filter_duplicate_text", "source_fields", "shard_min_doc_count", "execution_hint"} {
+		if _, exists := params[ignoredParam]; exists {
+			logger.WarnWithCtx(cw.Ctx).Msgf("%s is not supported in %s, ignoring it. params: %v", ignoredParam, aggrName, params)
+		}
+	}
+
+	size := cw.parseSize(params, defaultSize)
+	// Same default as Elastic's. It's bigger than for terms, as the most significant terms don't have to be the most frequent ones.
+	shardSize := cw.parseIntField(params, "shard_size", 2*(size*3/2+10))
+	minDocCount := cw.parseIntField(params, "min_doc_count", defaultMinDocCount)
+	significantTerms := bucket_aggregations.NewSignificantTerms(cw.Ctx, isText, size, minDocCount, heuristic,
+		backgroundFilter, params["include"], params["exclude"])
+
+	var field model.Expr
+	if isText {
+		field = cw.parseFieldField(params, aggrName)
+		if field == nil {
+			return fmt.Errorf("field is required in %s: %v", aggrName, params)
+		}
+	} else {
+		var isFromScript bool
+		field, isFromScript = cw.parseFieldFieldMaybeScript(params, aggrName)
+		if field == nil {
+			return fmt.Errorf("field or script is required in %s: %v", aggrName, params)
+		}
+		if !isFromScript {
+			field, _ = cw.addMissingParameterIfPresent(field, params)
+		}
+	}
+	field, _ = significantTerms.UpdateFieldForIncludeAndExclude(field)
+	key := significantTerms.KeyExpr(field)
+
+	aggregation.queryType = significantTerms
+	aggregation.selectedColumns = append(aggregation.selectedColumns, key)
+	aggregation.limit = shardSize
+	aggregation.orderBy = []model.OrderByExpr{model.NewOrderByExpr(model.NewCountFunc(), model.DescOrder)}
+	// Terms without a value (null key) are never significant
+	aggregation.filterOutEmptyKeyBucket = true
+	return nil
+}
+
+// parseSignificanceHeuristic returns the heuristic requested in significant_terms/significant_text params (JLH if none).
+func (cw *ClickhouseQueryTranslator) parseSignificanceHeuristic(params QueryMap) (bucket_aggregations.SignificanceHeuristic, error) {
+	if _, exists := params["script_heuristic"]; exists {
+		return bucket_aggregations.SignificanceHeuristic{}, fmt.Errorf("script_heuristic is not supported")
+	}
+
+	heuristicNames := []bucket_aggregations.SignificanceHeuristicName{bucket_aggregations.JLH, bucket_aggregations.MutualInformation,
+		bucket_aggregations.ChiSquare, bucket_aggregations.GND, bucket_aggregations.Percentage}
+	var requested []bucket_aggregations.SignificanceHeuristicName
+	for _, name := range heuristicNames {
+		if _, exists := params[string(name)]; exists {
+			requested = append(requested, name)
+		}
+	}
+	switch len(requested) {
+	case 0:
+		return bucket_aggregations.NewSignificanceHeuristic(bucket_aggregations.DefaultSignificanceHeuristic, false, true)
+	case 1:
+		heuristicParams, ok := params[string(requested[0])].(QueryMap)
+		if !ok {
+			return bucket_aggregations.SignificanceHeuristic{}, fmt.Errorf("%s is not a map, but %T, value: %v",
+				requested[0], params[string(requested[0])], params[string(requested[0])])
+		}
+		includeNegatives := cw.parseBoolField(heuristicParams, "include_negatives", false)
+		backgroundIsSuperset := cw.parseBoolField(heuristicParams, "background_is_superset", true)
+		return bucket_aggregations.NewSignificanceHeuristic(requested[0], includeNegatives, backgroundIsSuperset)
+	default:
+		return bucket_aggregations.SignificanceHeuristic{}, fmt.Errorf("more than one significance heuristic requested: %v", requested)
+	}
+}
+
 func (cw *ClickhouseQueryTranslator) parseFilters(aggregation *pancakeAggregationTreeNode, params QueryMap) error {
 	filtersParamRaw, exists := params["filters"]
 	if !exists {
@@ -257,6 +406,26 @@ func (cw *ClickhouseQueryTranslator) parseSampler(aggregation *pancakeAggregatio
 	return nil
 }
 
+func (cw *ClickhouseQueryTranslator) parseDiversifiedSampler(aggregation *pancakeAggregationTreeNode, params QueryMap) error {
+	const (
+		defaultSize            = 100
+		defaultMaxDocsPerValue = 1
+	)
+	field, _ := cw.parseFieldFieldMaybeScript(params, "diversified_sampler")
+	if field == nil {
+		return fmt.Errorf("field or script is required in diversified_sampler: %v", params)
+	}
+	if _, exists := params["execution_hint"]; exists {
+		logger.WarnWithCtx(cw.Ctx).Msgf("execution_hint is not supported in diversified_sampler, ignoring it. params: %v", params)
+	}
+	aggregation.queryType = bucket_aggregations.NewDiversifiedSampler(cw.Ctx,
+		cw.parseIntField(params, "shard_size", defaultSize),
+		field,
+		cw.parseIntField(params, "max_docs_per_value", defaultMaxDocsPerValue),
+	)
+	return nil
+}
+
 func (cw *ClickhouseQueryTranslator) parseRandomSampler(aggregation *pancakeAggregationTreeNode, params QueryMap) error {
 	const defaultProbability = 0.0 // theoretically it's required
 	const defaultSeed = 0
@@ -267,6 +436,38 @@ func (cw *ClickhouseQueryTranslator) parseRandomSampler(aggregation *pancakeAggr
 	return nil
 }
 
+func (cw *ClickhouseQueryTranslator) parseNestedAggregation(aggregation *pancakeAggregationTreeNode, params QueryMap) error {
+	path, exists := cw.parseStringFieldExistCheck(params, "path")
+	if !exists {
+		return fmt.Errorf("path is required in nested aggregation: %v", params)
+	}
+
+	// all array columns of nested objects' fields need to be unnested together
+	arrayJoinColumns := make([]model.Expr, 0)
+	for _, field := range cw.Schema.Fields {
+		if strings.HasPrefix(field.PropertyName.AsString(), path+".") && strings.HasPrefix(field.InternalPropertyType, "Array") {
+			arrayJoinColumns = append(arrayJoinColumns, model.NewColumnRef(field.InternalPropertyName.AsString()))
+		}
+	}
+	if len(arrayJoinColumns) == 0 {
+		return fmt.Errorf("no array fields found for nested aggregation path: %s", path)
+	}
+	sort.Slice(arrayJoinColumns, func(i, j int) bool {
+		return arrayJoinColumns[i].(model.ColumnRef).ColumnName < arrayJoinColumns[j].(model.ColumnRef).ColumnName
+	})
+
+	aggregation.queryType = bucket_aggregations.NewNested(cw.Ctx, path, arrayJoinColumns)
+	return nil
+}
+
+func (cw *ClickhouseQueryTranslator) parseReverseNested(aggregation *pancakeAggregationTreeNode, params QueryMap) error {
+	if path, exists := params["path"]; exists {
+		return fmt.Errorf("reverse_nested with path (%v) is not supported, only joining back to the root document", path)
+	}
+	aggregation.queryType = bucket_aggregations.NewReverseNested(cw.Ctx)
+	return nil
+}
+
 func (cw *ClickhouseQueryTranslator) parseRangeAggregation(aggregation *pancakeAggregationTreeNode, params QueryMap) error {
 	ranges, err := cw.parseArrayField(params, "ranges")
 	if err != nil {
@@ -422,11 +623,9 @@ func (cw *ClickhouseQueryTranslator) parseComposite(aggregation *pancakeAggregat
 	// 1) Terms (but NOT Significant Terms) 2) Histogram 3) Date histogram 4) GeoTile grid
 	// https://www.elastic.co/guide/en/elasticsearch/reference/current/search-aggregations-bucket-composite-aggregation.html
 	isValidSourceType := func(queryType model.QueryType) bool {
-		switch typed := queryType.(type) {
-		case *bucket_aggregations.Histogram, *bucket_aggregations.DateHistogram, bucket_aggregations.GeoTileGrid:
+		switch queryType.(type) {
+		case *bucket_aggregations.Histogram, *bucket_aggregations.DateHistogram, bucket_aggregations.GeoTileGrid, bucket_aggregations.Terms:
 			return true
-		case bucket_aggregations.Terms:
-			return !typed.IsSignificant()
 		default:
 			return false
 		}
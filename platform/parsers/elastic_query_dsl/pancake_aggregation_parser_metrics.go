@@ -143,6 +143,99 @@ func generateMetricSelectedColumns(ctx context.Context, metricsAggr metricsAggre
 			result = append(result, model.NewFunction("minOrNull", latColumn))
 			result = append(result, model.NewFunction("argMinOrNull", lonColumn, latColumn))
 		}
+	case "weighted_avg":
+		if len(metricsAggr.Fields) != 2 {
+			return nil, fmt.Errorf("weighted_avg needs 2 fields (value and weight), got %d", len(metricsAggr.Fields))
+		}
+		result = []model.Expr{model.NewFunction("avgWeightedOrNull", metricsAggr.Fields[0], metricsAggr.Fields[1])}
+	case "median_absolute_deviation":
+		// median(|x - median(x)|). We can't nest aggregate functions, so we do it on arrays:
+		// arrayReduce('quantileExact(0.5)', arrayMap((v, median) -> abs(v-median), groupArray(x), arrayWithConstant(count(x), quantileExact(0.5)(x))))
+		expr := getFirstExpression()
+		deviations := model.NewFunction("arrayMap",
+			model.NewLambdaExpr([]string{"v", "median"}, model.NewFunction("abs", model.NewInfixExpr(model.NewLiteral("v"), "-", model.NewLiteral("median")))),
+			model.NewFunction("groupArray", expr),
+			arrayWithConstantPerValue(expr, quantileExact(0.5, expr)),
+		)
+		result = []model.Expr{
+			model.NewCountFunc(expr),
+			model.NewFunction("arrayReduce", model.NewLiteral("'quantileExact(0.5)'"), deviations),
+		}
+	case "string_stats":
+		expr := getFirstExpression()
+		length := model.NewFunction("lengthUTF8", expr)
+		chars := model.NewFunction("ngrams", model.NewFunction("COALESCE", expr, model.NewLiteral("''")), model.NewLiteral(1))
+		result = []model.Expr{
+			model.NewCountFunc(expr),
+			model.NewFunction("minOrNull", length),
+			model.NewFunction("maxOrNull", length),
+			model.NewFunction("avgOrNull", length),
+			// ([char_1, ..., char_n], [count_1, ..., count_n])
+			model.NewFunction("sumMap", chars, model.NewFunction("arrayWithConstant", model.NewFunction("length", chars), model.NewLiteral(1))),
+		}
+	case "boxplot":
+		expr := getFirstExpression()
+		q1, q2, q3 := quantileExact(0.25, expr), quantileExact(0.5, expr), quantileExact(0.75, expr)
+		iqrTimes1_5 := model.NewInfixExpr(model.NewLiteral(1.5), "*", model.NewParenExpr(model.NewInfixExpr(q3, "-", q1)))
+		// whisker = the most extreme value within the fence, e.g. lower = arrayMin(arrayFilter((v, fence) -> v>=fence, groupArray(x), [fence, ..., fence]))
+		whisker := func(arrayFunc, comparison string, fence model.Expr) model.Expr {
+			return model.NewFunction(arrayFunc, model.NewFunction("arrayFilter",
+				model.NewLambdaExpr([]string{"v", "fence"}, model.NewInfixExpr(model.NewLiteral("v"), comparison, model.NewLiteral("fence"))),
+				model.NewFunction("groupArray", expr),
+				arrayWithConstantPerValue(expr, fence),
+			))
+		}
+		result = []model.Expr{
+			model.NewFunction("minOrNull", expr),
+			model.NewFunction("maxOrNull", expr),
+			q1, q2, q3,
+			whisker("arrayMin", ">=", model.NewInfixExpr(q1, "-", iqrTimes1_5)),
+			whisker("arrayMax", "<=", model.NewInfixExpr(q3, "+", iqrTimes1_5)),
+		}
+	case "t_test":
+		if len(metricsAggr.Fields) != 2 || len(metricsAggr.filters) != 2 {
+			return nil, fmt.Errorf("t_test needs 2 fields and 2 filters, got %d and %d", len(metricsAggr.Fields), len(metricsAggr.filters))
+		}
+		// count, avg and variance of each sample are enough to compute p-value, see metrics_aggregations.TTest
+		sampleStats := func(expr, filter model.Expr) []model.Expr {
+			if filter == nil {
+				return []model.Expr{model.NewCountFunc(expr), model.NewFunction("avgOrNull", expr), model.NewFunction("varSamp", expr)}
+			}
+			return []model.Expr{
+				model.NewFunction("countIf", model.And([]model.Expr{model.NewInfixExpr(expr, "IS", model.NewLiteral("NOT NULL")), filter})),
+				model.NewFunction("avgOrNullIf", expr, filter),
+				model.NewFunction("varSampIf", expr, filter),
+			}
+		}
+		if metricsAggr.tTestType == metrics_aggregations.TTestPaired {
+			result = sampleStats(model.NewInfixExpr(metricsAggr.Fields[0], "-", metricsAggr.Fields[1]), nil)
+		} else {
+			result = append(sampleStats(metricsAggr.Fields[0], metricsAggr.filters[0]), sampleStats(metricsAggr.Fields[1], metricsAggr.filters[1])...)
+		}
+	case "matrix_stats":
+		// Same as Elastic, we only take documents with all fields present into account.
+		allFieldsPresent := make([]model.Expr, 0, len(metricsAggr.Fields))
+		for _, field := range metricsAggr.Fields {
+			allFieldsPresent = append(allFieldsPresent, model.NewInfixExpr(field, "IS", model.NewLiteral("NOT NULL")))
+		}
+		condition := model.And(allFieldsPresent)
+
+		result = make([]model.Expr, 0, 1+4*len(metricsAggr.Fields)+2)
+		result = append(result, model.NewFunction("countIf", condition))
+		for _, field := range metricsAggr.Fields {
+			// skewness and kurtosis in Elastic are population ones, variance (and covariance below) - sample ones.
+			result = append(result,
+				model.NewFunction("avgIf", field, condition),
+				model.NewFunction("varSampIf", field, condition),
+				model.NewFunction("skewPopIf", field, condition),
+				model.NewFunction("kurtPopIf", field, condition),
+			)
+		}
+		// those 2 skip rows with any NULL argument by themselves
+		result = append(result,
+			model.NewFunction("covarSampMatrix", metricsAggr.Fields...),
+			model.NewFunction("corrMatrix", metricsAggr.Fields...),
+		)
 	default:
 		logger.WarnWithCtx(ctx).Msgf("unknown metrics aggregation: %s", metricsAggr.AggrType)
 		return nil, fmt.Errorf("unknown metrics aggregation %s", metricsAggr.AggrType)
@@ -150,6 +243,17 @@ func generateMetricSelectedColumns(ctx context.Context, metricsAggr metricsAggre
 	return
 }
 
+// quantileExact returns quantileExact(level)(expr)
+func quantileExact(level float64, expr model.Expr) model.FunctionExpr {
+	return model.FunctionExpr{Name: fmt.Sprintf("quantileExact(%s)", strconv.FormatFloat(level, 'f', -1, 64)), Args: []model.Expr{expr}}
+}
+
+// arrayWithConstantPerValue returns arrayWithConstant(count(expr), value), so an array which can be zipped with groupArray(expr).
+// We need that to use an aggregate (e.g. median) inside a lambda over groupArray's values.
+func arrayWithConstantPerValue(expr, value model.Expr) model.FunctionExpr {
+	return model.NewFunction("arrayWithConstant", model.NewCountFunc(expr), value)
+}
+
 func (cw *ClickhouseQueryTranslator) generateMetricsType(metricsAggr metricsAggregation) model.QueryType {
 	switch metricsAggr.AggrType {
 	case "sum":
@@ -180,6 +284,18 @@ func (cw *ClickhouseQueryTranslator) generateMetricsType(metricsAggr metricsAggr
 		return metrics_aggregations.NewGeoCentroid(cw.Ctx)
 	case "geo_bounds":
 		return metrics_aggregations.NewGeoBounds(cw.Ctx)
+	case "weighted_avg":
+		return metrics_aggregations.NewWeightedAvg(cw.Ctx)
+	case "median_absolute_deviation":
+		return metrics_aggregations.NewMedianAbsoluteDeviation(cw.Ctx)
+	case "string_stats":
+		return metrics_aggregations.NewStringStats(cw.Ctx, metricsAggr.showDistribution)
+	case "boxplot":
+		return metrics_aggregations.NewBoxplot(cw.Ctx)
+	case "t_test":
+		return metrics_aggregations.NewTTest(cw.Ctx, metricsAggr.tTestType, metricsAggr.tails)
+	case "matrix_stats":
+		return metrics_aggregations.NewMatrixStats(cw.Ctx, metricsAggr.fieldNames)
 	case "rate":
 		isFieldPresent := len(metricsAggr.Fields) > 0
 		if rate, err := metrics_aggregations.NewRate(cw.Ctx, metricsAggr.unit, isFieldPresent); err == nil {
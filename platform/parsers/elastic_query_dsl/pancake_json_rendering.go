@@ -117,6 +117,9 @@ func (p *pancakeJSONRenderer) splitBucketRows(bucket *pancakeModelBucketAggregat
 	bucketKeyName := bucket.InternalNameForKeyPrefix()
 	bucketCountName := bucket.InternalNameForCount()
 	bucketParentCountName := bucket.InternalNameForParentCount()
+	bucketBackgroundCountName := bucket.InternalNameForBackgroundCount()
+	bucketParentBackgroundCountName := bucket.InternalNameForParentBackgroundCount()
+	bucketStatName := bucket.InternalNameForStatPrefix()
 	indexName := rows[0].Index
 	for rowIdx, row := range rows {
 		isNewBucket := rowIdx == 0 // first row is always new bucket
@@ -150,7 +153,8 @@ func (p *pancakeJSONRenderer) splitBucketRows(bucket *pancakeModelBucketAggregat
 			lastIdx := len(buckets) - 1
 			for _, cols := range row.Cols {
 				if strings.HasPrefix(cols.ColName, bucketKeyName) || strings.HasPrefix(cols.ColName, bucketCountName) ||
-					strings.HasPrefix(cols.ColName, bucketParentCountName) {
+					strings.HasPrefix(cols.ColName, bucketParentCountName) || strings.HasPrefix(cols.ColName, bucketBackgroundCountName) ||
+					strings.HasPrefix(cols.ColName, bucketParentBackgroundCountName) || strings.HasPrefix(cols.ColName, bucketStatName) {
 					buckets[lastIdx].Cols = append(buckets[lastIdx].Cols, cols)
 				}
 			}
@@ -201,7 +205,7 @@ func (p *pancakeJSONRenderer) potentiallyRemoveExtraBucket(layer *pancakeModelLa
 func (p *pancakeJSONRenderer) combinatorBucketToJSON(remainingLayers []*pancakeModelLayer, rows []model.QueryResultRow) (model.JsonMap, error) {
 	layer := remainingLayers[0]
 	switch queryType := layer.nextBucketAggregation.queryType.(type) {
-	case bucket_aggregations.SamplerInterface, bucket_aggregations.FilterAgg:
+	case bucket_aggregations.SamplerInterface, bucket_aggregations.FilterAgg, bucket_aggregations.Nested, bucket_aggregations.ReverseNested:
 		selectedRows := p.selectMetricRows(layer.nextBucketAggregation.InternalNameForCount(), rows)
 		aggJson := layer.nextBucketAggregation.queryType.TranslateSqlResponseToJson(selectedRows)
 		subAggr, err := p.layerToJSON(remainingLayers[1:], rows, layer.nextBucketAggregation)
@@ -327,7 +331,8 @@ func (p *pancakeJSONRenderer) layerToJSON(remainingLayers []*pancakeModelLayer,
 			nextLayer = remainingLayers[1]
 			anyPipelineParentAggregation := false
 			for _, pipeline := range nextLayer.childrenPipelineAggregations {
-				if pipeline.queryType.PipelineAggregationType() == model.PipelineParentAggregation {
+				_, isBucketsModifier := pipeline.queryType.(model.PipelineBucketsModifierQueryType)
+				if pipeline.queryType.PipelineAggregationType() == model.PipelineParentAggregation && !isBucketsModifier {
 					anyPipelineParentAggregation = true
 					break
 				}
@@ -344,6 +349,9 @@ func (p *pancakeJSONRenderer) layerToJSON(remainingLayers []*pancakeModelLayer,
 
 		bucketRows, subAggrRows := p.splitBucketRows(layer.nextBucketAggregation, rows)
 		bucketRows, subAggrRows = p.potentiallyRemoveExtraBucket(layer, bucketRows, subAggrRows)
+		if significantTerms, ok := layer.nextBucketAggregation.queryType.(bucket_aggregations.SignificantTerms); ok {
+			bucketRows, subAggrRows = p.selectSignificantBuckets(significantTerms, bucketRows, subAggrRows)
+		}
 
 		buckets := layer.nextBucketAggregation.queryType.TranslateSqlResponseToJson(bucketRows)
 
@@ -429,6 +437,7 @@ func (p *pancakeJSONRenderer) layerToJSON(remainingLayers []*pancakeModelLayer,
 			for i := 0; i < len(bucketArr); i++ {
 				delete(bucketArr[i], bucket_aggregations.OriginalKeyName)
 			}
+			buckets["buckets"] = p.pipeline.modifyBuckets(nextLayer, bucketArr)
 		}
 
 		if layer.nextBucketAggregation.metadata != nil {
@@ -440,6 +449,20 @@ func (p *pancakeJSONRenderer) layerToJSON(remainingLayers []*pancakeModelLayer,
 	return result, nil
 }
 
+// selectSignificantBuckets keeps only the most significant buckets (and their sub-aggregations), sorted by their score.
+func (p *pancakeJSONRenderer) selectSignificantBuckets(significantTerms bucket_aggregations.SignificantTerms,
+	bucketRows []model.QueryResultRow, subAggrRows [][]model.QueryResultRow) ([]model.QueryResultRow, [][]model.QueryResultRow) {
+
+	indexes := significantTerms.MostSignificantRows(bucketRows)
+	newBucketRows := make([]model.QueryResultRow, 0, len(indexes))
+	newSubAggrRows := make([][]model.QueryResultRow, 0, len(indexes))
+	for _, idx := range indexes {
+		newBucketRows = append(newBucketRows, bucketRows[idx])
+		newSubAggrRows = append(newSubAggrRows, subAggrRows[idx])
+	}
+	return newBucketRows, newSubAggrRows
+}
+
 // valueForColumn returns value for a given column name in the first row of the result set (if it exists, it's the same for all rows)
 func (p *pancakeJSONRenderer) valueForColumn(rows []model.QueryResultRow, columnName string) (value interface{}, found bool) {
 	if len(rows) == 0 {
@@ -18,6 +18,11 @@ type pancakeModel struct {
 
 	whereClause model.Expr
 	sampleLimit int
+	// sampleLimitBy and sampleLimitPerValue are set for diversified_sampler: at most sampleLimitPerValue rows for each value of sampleLimitBy are sampled
+	sampleLimitBy       model.Expr
+	sampleLimitPerValue int
+	// arrayJoin is set for nested aggregation: these array columns are unnested (ARRAY JOIN) after filtering
+	arrayJoin []model.Expr
 }
 
 // Clone isn't a shallow copy, isn't also a full deep copy, but it's enough for our purposes.
@@ -30,9 +35,12 @@ func (p *pancakeModel) Clone() *pancakeModel {
 		layers[i].childrenPipelineAggregations = p.layers[i].childrenPipelineAggregations
 	}
 	return &pancakeModel{
-		layers:      layers,
-		whereClause: p.whereClause,
-		sampleLimit: p.sampleLimit,
+		layers:              layers,
+		whereClause:         p.whereClause,
+		sampleLimit:         p.sampleLimit,
+		sampleLimitBy:       p.sampleLimitBy,
+		sampleLimitPerValue: p.sampleLimitPerValue,
+		arrayJoin:           p.arrayJoin,
 	}
 }
 
@@ -177,6 +185,25 @@ func (p pancakeModelBucketAggregation) InternalNameForParentCount() string {
 	return fmt.Sprintf("%sparent_count", p.internalName)
 }
 
+// Used by significant_terms aggregation to get the frequency of the term in the background set
+func (p pancakeModelBucketAggregation) InternalNameForBackgroundCount() string {
+	return fmt.Sprintf("%sbg_count", p.internalName)
+}
+
+// Used by significant_terms aggregation to get the size of the background set
+func (p pancakeModelBucketAggregation) InternalNameForParentBackgroundCount() string {
+	return fmt.Sprintf("%sparent_bg_count", p.internalName)
+}
+
+// Used by variable_width_histogram to get min/max/centroid of the bucket
+func (p pancakeModelBucketAggregation) InternalNameForStatPrefix() string {
+	return fmt.Sprintf("%sstat", p.internalName)
+}
+
+func (p pancakeModelBucketAggregation) InternalNameForStat(id int) string {
+	return fmt.Sprintf("%s_%d", p.InternalNameForStatPrefix(), id)
+}
+
 func (p pancakeModelBucketAggregation) isInternalNameCountColumn(internalName string) bool {
 	return strings.HasSuffix(internalName, "count")
 }
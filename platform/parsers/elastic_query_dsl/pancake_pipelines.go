@@ -8,7 +8,9 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/logger"
 	"github.com/QuesmaOrg/quesma/platform/model"
 	"github.com/QuesmaOrg/quesma/platform/model/bucket_aggregations"
+	"github.com/QuesmaOrg/quesma/platform/model/pipeline_aggregations"
 	"github.com/QuesmaOrg/quesma/platform/util"
+	"strings"
 )
 
 type pancakePipelinesProcessor struct {
@@ -96,10 +98,15 @@ func (p pancakePipelinesProcessor) currentPipelineBucketAggregations(layer, next
 		if childPipeline.queryType.AggregationType() != model.PipelineBucketAggregation {
 			continue
 		}
+		if _, isBucketsModifier := childPipeline.queryType.(model.PipelineBucketsModifierQueryType); isBucketsModifier {
+			continue // they don't add anything to buckets, see modifyBuckets
+		}
 
 		bucketRowsWithRightLastColumn := bucketRows
 		needToAddProperMetricColumn := !childPipeline.queryType.IsCount() // If count, last column of bucketRows is already count we need.
-		if needToAddProperMetricColumn {
+		if _, needsRenderedParent := childPipeline.queryType.(pipeline_aggregations.MovingPercentiles); needsRenderedParent {
+			bucketRowsWithRightLastColumn = p.replaceCountColumnWithRenderedMetric(nextLayer, childPipeline.parentInternalName, bucketRows, subAggrRows)
+		} else if needToAddProperMetricColumn {
 			bucketRowsWithRightLastColumn = p.replaceCountColumnWithMetricColumn(childPipeline.parentInternalName, bucketRows, subAggrRows)
 		}
 
@@ -185,3 +192,49 @@ func (p pancakePipelinesProcessor) replaceCountColumnWithMetricColumn(parentColu
 	}
 	return
 }
+
+// replaceCountColumnWithRenderedMetric is like replaceCountColumnWithMetricColumn, but instead of a single column,
+// it puts the whole rendered result (JsonMap) of the parent metric aggregation into the last column.
+// It's needed for pipelines, which use multi-value metrics as their parent (e.g. moving_percentiles).
+func (p pancakePipelinesProcessor) replaceCountColumnWithRenderedMetric(nextLayer *pancakeModelLayer, parentColumnName string,
+	bucketRows []model.QueryResultRow, subAggrRows [][]model.QueryResultRow) (newBucketRows []model.QueryResultRow) {
+
+	var parentMetric *pancakeModelMetricAggregation
+	for _, metric := range nextLayer.currentMetricAggregations {
+		if metric.InternalNamePrefix()+"0" == parentColumnName {
+			parentMetric = metric
+			break
+		}
+	}
+	if parentMetric == nil {
+		logger.WarnWithCtx(p.ctx).Msgf("could not find parent metric for column %s", parentColumnName)
+		return bucketRows
+	}
+
+	newBucketRows = make([]model.QueryResultRow, len(bucketRows))
+	for i, origRow := range bucketRows {
+		metricRow := model.QueryResultRow{Index: origRow.Index}
+		if i < len(subAggrRows) && len(subAggrRows[i]) > 0 {
+			for _, col := range subAggrRows[i][0].Cols {
+				if strings.HasPrefix(col.ColName, parentMetric.InternalNamePrefix()) {
+					metricRow.Cols = append(metricRow.Cols, col)
+				}
+			}
+		}
+
+		newBucketRows[i] = origRow.Copy()
+		newBucketRows[i].Cols[len(origRow.Cols)-1].Value = parentMetric.queryType.TranslateSqlResponseToJson([]model.QueryResultRow{metricRow})
+	}
+	return
+}
+
+// modifyBuckets applies all pipelines which filter/reorder buckets of the parent aggregation (e.g. bucket_selector, bucket_sort).
+// They need to be applied at the end, when buckets are already rendered with all their subaggregations.
+func (p pancakePipelinesProcessor) modifyBuckets(nextLayer *pancakeModelLayer, buckets []model.JsonMap) []model.JsonMap {
+	for _, childPipeline := range nextLayer.childrenPipelineAggregations {
+		if modifier, isBucketsModifier := childPipeline.queryType.(model.PipelineBucketsModifierQueryType); isBucketsModifier {
+			buckets = modifier.ModifyBuckets(buckets)
+		}
+	}
+	return buckets
+}
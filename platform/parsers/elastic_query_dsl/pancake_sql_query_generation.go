@@ -55,7 +55,8 @@ func (p *pancakeSqlQueryGenerator) generateAccumAggrFunctions(origExpr model.Exp
 			return origExpr, origFunc.Name, nil
 		case "count", "countIf":
 			return model.NewFunction(origFunc.Name, origFunc.Args...), "sum", nil
-		case "avg", "avgOrNull", "varPop", "varSamp", "stddevPop", "stddevSamp", "uniq":
+		case "avg", "avgOrNull", "varPop", "varSamp", "stddevPop", "stddevSamp", "uniq",
+			"avgWeightedOrNull", "avgIf", "avgOrNullIf", "varSampIf", "skewPopIf", "kurtPopIf", "sumMap", "covarSampMatrix", "corrMatrix":
 			// TODO: I debate whether make that default
 			// This is ClickHouse specific: https://clickhouse.com/docs/en/sql-reference/aggregate-functions/combinators
 			return model.NewFunction(origFunc.Name+"State", origFunc.Args...), origFunc.Name + "Merge", nil
@@ -117,22 +118,115 @@ func (p *pancakeSqlQueryGenerator) isPartOfOrderBy(alias model.AliasedExpr, orde
 	return false
 }
 
-func (p *pancakeSqlQueryGenerator) addPotentialParentCount(bucketAggregation *pancakeModelBucketAggregation, groupByColumns []model.AliasedExpr) []model.AliasedExpr {
+func (p *pancakeSqlQueryGenerator) addPotentialParentCount(query *pancakeModel, bucketAggregation *pancakeModelBucketAggregation, groupByColumns []model.AliasedExpr) []model.AliasedExpr {
 	if query_util.IsAnyKindOfTerms(bucketAggregation.queryType) {
-		parentCountColumn := model.NewWindowFunction("sum",
+		var parentCountColumn model.Expr = model.NewWindowFunction("sum",
 			[]model.Expr{model.NewCountFunc()},
 			p.generatePartitionBy(groupByColumns), []model.OrderByExpr{})
+		if significantText := p.significantTextSubsetSize(query); significantText != nil {
+			parentCountColumn = significantText
+		}
 		parentCountAliasedColumn := model.NewAliasedExpr(parentCountColumn, bucketAggregation.InternalNameForParentCount())
 		return []model.AliasedExpr{parentCountAliasedColumn}
 	}
 	return []model.AliasedExpr{}
 }
 
+// addPotentialBackgroundCounts adds background set size and background frequency of the term for significant_terms/text.
+func (p *pancakeSqlQueryGenerator) addPotentialBackgroundCounts(query *pancakeModel, bucketAggregation *pancakeModelBucketAggregation,
+	groupByColumns []model.AliasedExpr) []model.AliasedExpr {
+
+	significantTerms, ok := bucketAggregation.queryType.(bucket_aggregations.SignificantTerms)
+	if !ok || len(bucketAggregation.selectedColumns) == 0 {
+		return []model.AliasedExpr{}
+	}
+	candidateTerms := p.significantTermsCandidates(query, bucketAggregation, groupByColumns)
+	return []model.AliasedExpr{
+		model.NewAliasedExpr(significantTerms.BackgroundSizeExpr(), bucketAggregation.InternalNameForParentBackgroundCount()),
+		model.NewAliasedExpr(significantTerms.BackgroundFrequencyExpr(bucketAggregation.selectedColumns[0], candidateTerms),
+			bucketAggregation.InternalNameForBackgroundCount()),
+	}
+}
+
+// significantTermsCandidates returns SQL query for terms, whose background frequencies we need.
+// If significant_terms/text is the top-level aggregation, these are exactly terms of its buckets:
+// most frequent terms in the foreground set, in the same order and with the same limit as in the main query.
+// Otherwise (parent buckets or sampling, which may sample different rows), these are all terms of the foreground set.
+func (p *pancakeSqlQueryGenerator) significantTermsCandidates(query *pancakeModel, bucketAggregation *pancakeModelBucketAggregation,
+	groupByColumns []model.AliasedExpr) model.SelectCommand {
+
+	key := bucketAggregation.selectedColumns[0]
+	candidates := model.SelectCommand{
+		Columns:     []model.Expr{key},
+		FromClause:  model.NewTableRef(model.SingleTableNamePlaceHolder),
+		WhereClause: query.whereClause,
+		ArrayJoin:   query.arrayJoin,
+	}
+	isTopLevel := len(groupByColumns) == 0 && query.layers[0].nextBucketAggregation == bucketAggregation
+	if isTopLevel && query.sampleLimit == 0 && bucketAggregation.limit != pancakeBucketAggregationNoLimit {
+		candidates.GroupBy = []model.Expr{key}
+		candidates.OrderBy = []model.OrderByExpr{model.NewOrderByExpr(model.NewCountFunc(), model.DescOrder), model.NewOrderByExpr(key, model.AscOrder)}
+		candidates.Limit = bucketAggregation.limit
+		if bucketAggregation.filterOurEmptyKeyBucket {
+			candidates.Limit += 1
+		}
+	}
+	return candidates
+}
+
+// addPotentialBucketStats adds min, max, and centroid of each bucket for variable_width_histogram.
+// groupByColumns should include the bucket's own key.
+func (p *pancakeSqlQueryGenerator) addPotentialBucketStats(bucketAggregation *pancakeModelBucketAggregation, groupByColumns []model.AliasedExpr,
+	hasMoreBucketAggregations bool) ([]model.AliasedExpr, error) {
+
+	variableWidthHistogram, ok := bucketAggregation.queryType.(bucket_aggregations.VariableWidthHistogram)
+	if !ok {
+		return []model.AliasedExpr{}, nil
+	}
+	stats := make([]model.AliasedExpr, 0, 3)
+	for statId, stat := range variableWidthHistogram.StatsExprs() {
+		if hasMoreBucketAggregations {
+			partColumn, aggFunctionName, err := p.generateAccumAggrFunctions(stat, bucketAggregation.queryType)
+			if err != nil {
+				return nil, err
+			}
+			stat = model.NewWindowFunction(aggFunctionName, []model.Expr{partColumn}, p.generatePartitionBy(groupByColumns), []model.OrderByExpr{})
+		}
+		stats = append(stats, model.NewAliasedExpr(stat, bucketAggregation.InternalNameForStat(statId)))
+	}
+	return stats, nil
+}
+
+// significantTextSubsetSize returns nil if there's no significant_text in the pancake.
+// Otherwise, it returns SQL expression for the number of documents in the foreground set.
+// significant_text multiplies every row by its number of tokens (arrayJoin), so we can't simply count(*) rows.
+// (pancakeTransformer.checkIfSupported ensures there are no other aggregations which could be affected by that)
+func (p *pancakeSqlQueryGenerator) significantTextSubsetSize(query *pancakeModel) model.Expr {
+	for _, layer := range query.layers {
+		if layer.nextBucketAggregation == nil {
+			continue
+		}
+		if significantTerms, ok := layer.nextBucketAggregation.queryType.(bucket_aggregations.SignificantTerms); ok && significantTerms.IsText() {
+			return model.NewParenExpr(model.SelectCommand{
+				Columns:             []model.Expr{model.NewCountFunc()},
+				FromClause:          model.NewTableRef(model.SingleTableNamePlaceHolder),
+				WhereClause:         query.whereClause,
+				SampleLimit:         query.sampleLimit,
+				SampleLimitBy:       query.sampleLimitBy,
+				SampleLimitPerValue: query.sampleLimitPerValue,
+			})
+		}
+	}
+	return nil
+}
+
 func (p *pancakeSqlQueryGenerator) generateBucketSqlParts(query *pancakeModel, bucketAggregation *pancakeModelBucketAggregation, groupByColumns []model.AliasedExpr, hasMoreBucketAggregations bool) (
 	addSelectColumns, addGroupBys, addRankColumns []model.AliasedExpr, addRankWheres []model.Expr, addRankOrderBys []model.OrderByExpr, err error) {
 
-	// For some group by such as terms, we need total count. We add it in this method.
-	addSelectColumns = append(addSelectColumns, p.addPotentialParentCount(bucketAggregation, groupByColumns)...)
+	// For significant_terms, we need background counts, and for some group by such as terms, we need total count.
+	// We add them in these methods.
+	addSelectColumns = append(addSelectColumns, p.addPotentialBackgroundCounts(query, bucketAggregation, groupByColumns)...)
+	addSelectColumns = append(addSelectColumns, p.addPotentialParentCount(query, bucketAggregation, groupByColumns)...)
 
 	for columnId, column := range bucketAggregation.selectedColumns {
 		aliasedColumn := model.NewAliasedExpr(column, bucketAggregation.InternalNameForKey(columnId))
@@ -140,6 +234,12 @@ func (p *pancakeSqlQueryGenerator) generateBucketSqlParts(query *pancakeModel, b
 		addGroupBys = append(addGroupBys, aliasedColumn)
 	}
 
+	addBucketStats, err := p.addPotentialBucketStats(bucketAggregation, append(groupByColumns, addGroupBys...), hasMoreBucketAggregations)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+	addSelectColumns = append(addSelectColumns, addBucketStats...)
+
 	// build count for aggr
 	var countColumn model.Expr
 	if hasMoreBucketAggregations {
@@ -150,6 +250,14 @@ func (p *pancakeSqlQueryGenerator) generateBucketSqlParts(query *pancakeModel, b
 	} else {
 		countColumn = model.NewCountFunc()
 	}
+	if subsetSize := p.significantTextSubsetSize(query); subsetSize != nil && !bucketAggregation.DoesHaveGroupBy() {
+		// e.g. sampler above significant_text
+		countColumn = subsetSize
+	}
+	if reverseNested, ok := bucketAggregation.queryType.(bucket_aggregations.ReverseNested); ok {
+		// (pancakeTransformer.checkIfSupported ensures there are no more bucket aggregations after reverse_nested)
+		countColumn = reverseNested.DocCountExpr()
+	}
 	countAliasedColumn := model.NewAliasedExpr(countColumn, bucketAggregation.InternalNameForCount())
 	addSelectColumns = append(addSelectColumns, countAliasedColumn)
 
@@ -203,6 +311,11 @@ func (p *pancakeSqlQueryGenerator) generateBucketSqlParts(query *pancakeModel, b
 		aliasedRank := model.NewAliasedExpr(rankColumn, bucketAggregation.InternalNameForOrderBy(1)+"_rank")
 		addRankColumns = append(addRankColumns, aliasedRank)
 
+		if rareTerms, ok := bucketAggregation.queryType.(bucket_aggregations.RareTerms); ok {
+			// It's HAVING count() <= max_doc_count, but done on the outer query, as in deeper layers count is a window function
+			addRankWheres = append(addRankWheres, rareTerms.MaxDocCountCondition(countAliasedColumn.AliasRef()))
+		}
+
 		if bucketAggregation.limit != pancakeBucketAggregationNoLimit {
 			// if where not null, increase limit by 1
 			limit := bucketAggregation.limit
@@ -277,6 +390,17 @@ func (p *pancakeSqlQueryGenerator) countRealBucketAggregations(aggregation *panc
 	return bucketAggregationCount
 }
 
+func (p *pancakeSqlQueryGenerator) hasRareTerms(aggregation *pancakeModel) bool {
+	for _, layer := range aggregation.layers {
+		if layer.nextBucketAggregation != nil {
+			if _, ok := layer.nextBucketAggregation.queryType.(bucket_aggregations.RareTerms); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (p *pancakeSqlQueryGenerator) generateSelectCommand(aggregation *pancakeModel) (resultQuery *model.SelectCommand, optimizerName string, err error) {
 	if aggregation == nil {
 		return nil, "", errors.New("aggregation is nil in generateQuery")
@@ -375,7 +499,8 @@ func (p *pancakeSqlQueryGenerator) generateSelectCommand(aggregation *pancakeMod
 	}
 
 	// if we have single layer we can emit simpler query
-	if bucketAggregationCount <= 1 {
+	// (unless we need to filter the groups, like in rare_terms, which we do in the outer query)
+	if bucketAggregationCount <= 1 && !p.hasRareTerms(aggregation) {
 		limit := 0
 		for _, layer := range aggregation.layers {
 			if layer.nextBucketAggregation != nil && layer.nextBucketAggregation.DoesHaveGroupBy() {
@@ -396,22 +521,28 @@ func (p *pancakeSqlQueryGenerator) generateSelectCommand(aggregation *pancakeMod
 		rankColumns = []model.AliasedExpr{} // needed if there would be top hits
 
 		resultQuery = &model.SelectCommand{
-			Columns:     p.aliasedExprArrayToExpr(selectColumns),
-			GroupBy:     p.aliasedExprArrayToExpr(groupBys),
-			WhereClause: aggregation.whereClause,
-			FromClause:  model.NewTableRef(model.SingleTableNamePlaceHolder),
-			OrderBy:     orderBy,
-			Limit:       limit,
-			SampleLimit: aggregation.sampleLimit,
+			Columns:             p.aliasedExprArrayToExpr(selectColumns),
+			GroupBy:             p.aliasedExprArrayToExpr(groupBys),
+			WhereClause:         aggregation.whereClause,
+			FromClause:          model.NewTableRef(model.SingleTableNamePlaceHolder),
+			OrderBy:             orderBy,
+			Limit:               limit,
+			SampleLimit:         aggregation.sampleLimit,
+			SampleLimitBy:       aggregation.sampleLimitBy,
+			SampleLimitPerValue: aggregation.sampleLimitPerValue,
+			ArrayJoin:           aggregation.arrayJoin,
 		}
 		optimizerName = PancakeOptimizerName + "(half)"
 	} else {
 		windowCte := model.SelectCommand{
-			Columns:     p.aliasedExprArrayToExpr(selectColumns),
-			GroupBy:     p.aliasedExprArrayToExpr(groupBys),
-			WhereClause: aggregation.whereClause,
-			FromClause:  model.NewTableRef(model.SingleTableNamePlaceHolder),
-			SampleLimit: aggregation.sampleLimit,
+			Columns:             p.aliasedExprArrayToExpr(selectColumns),
+			GroupBy:             p.aliasedExprArrayToExpr(groupBys),
+			WhereClause:         aggregation.whereClause,
+			FromClause:          model.NewTableRef(model.SingleTableNamePlaceHolder),
+			SampleLimit:         aggregation.sampleLimit,
+			SampleLimitBy:       aggregation.sampleLimitBy,
+			SampleLimitPerValue: aggregation.sampleLimitPerValue,
+			ArrayJoin:           aggregation.arrayJoin,
 		}
 
 		rankCte := model.SelectCommand{
@@ -37,7 +37,11 @@ func TestPancakeQueryGeneration(t *testing.T) {
 	}
 
 	currentSchema := schema.Schema{
-		Fields:             nil,
+		Fields: map[schema.FieldName]schema.Field{
+			// array of objects, for nested aggregations
+			"events.type":     {PropertyName: "events.type", InternalPropertyName: "events_type", InternalPropertyType: "Array(String)", Type: schema.QuesmaTypeKeyword},
+			"events.duration": {PropertyName: "events.duration", InternalPropertyName: "events_duration", InternalPropertyType: "Array(Int64)", Type: schema.QuesmaTypeLong},
+		},
 		Aliases:            nil,
 		ExistsInDataSource: false,
 		DatabaseName:       "",
@@ -143,7 +147,7 @@ func TestPancakeQueryGeneration(t *testing.T) {
 
 			// FIXME we can quite easily remove 'probability' and 'seed' from above - just start remembering them in RandomSampler struct and print in JSON response.
 			acceptableDifference := []string{"probability", "seed", bucket_aggregations.OriginalKeyName, "_id", "_score",
-				"bg_count", "doc_count_error_upper_bound"} // Don't know why, but those 2 are still needed in new (clients/ophelia) tests. Let's fix it in another PR
+				"doc_count_error_upper_bound"} // Don't know why, but this one is still needed in new (clients/ophelia) tests. Let's fix it in another PR
 			if len(test.AdditionalAcceptableDifference) > 0 {
 				acceptableDifference = append(acceptableDifference, test.AdditionalAcceptableDifference...)
 			}
@@ -312,3 +316,31 @@ LIMIT 4`, // we increased limit by 1 to allow filtering of nulls druing json ren
 	}
 
 }
+
+func TestPancakeQueryGeneration_unparsableBackgroundFilter(t *testing.T) {
+	table := database_common.Table{
+		Cols: map[string]*database_common.Column{
+			"message": {Name: "message", Type: database_common.NewBaseType("String")},
+		},
+		Name:   tableName,
+		Config: database_common.NewDefaultCHConfig(),
+	}
+	cw := ClickhouseQueryTranslator{Table: &table, Ctx: context.Background(), Schema: schema.Schema{}}
+
+	jsonp, err := types.ParseJSON(`
+	{
+		"aggs": {
+			"keywords": {
+				"significant_terms": {
+					"field": "message",
+					"background_filter": {"no_such_query": {"message": "x"}}
+				}
+			}
+		},
+		"size": 0
+	}`)
+	assert.NoError(t, err)
+
+	_, err = cw.PancakeParseAggregationJson(jsonp, false)
+	assert.ErrorContains(t, err, "cannot parse background_filter of significant_terms")
+}
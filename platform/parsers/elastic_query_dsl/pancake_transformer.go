@@ -256,8 +256,72 @@ func (a *pancakeTransformer) aggregationChildrenToLayers(aggrNames []string, chi
 }
 
 func (a *pancakeTransformer) checkIfSupported(layers []*pancakeModelLayer) error {
-	// Let's say we support everything. That'll be true when I add support for filters/date_range/range in the middle of aggregation tree (@trzysiek)
-	// Erase this function by then.
+	if err := a.checkIfNestedSupported(layers); err != nil {
+		return err
+	}
+
+	// Let's say we support everything else. That'll be true when I add support for filters/date_range/range in the middle of aggregation tree (@trzysiek)
+	for i, layer := range layers {
+		if layer.nextBucketAggregation == nil {
+			continue
+		}
+		significantTerms, ok := layer.nextBucketAggregation.queryType.(bucket_aggregations.SignificantTerms)
+		if !ok || !significantTerms.IsText() {
+			continue
+		}
+
+		// significant_text splits text into tokens with arrayJoin, which multiplies rows in the whole SQL query.
+		// So we can't compute anything else in the same query, except for counts of samplers above, which we handle.
+		for _, previousLayer := range layers[:i] {
+			if _, isSampler := previousLayer.nextBucketAggregation.queryType.(bucket_aggregations.SamplerInterface); !isSampler {
+				return fmt.Errorf("significant_text is only supported as a top-level aggregation or in sampler, not in %s",
+					previousLayer.nextBucketAggregation.queryType.String())
+			}
+		}
+		for j, otherLayer := range layers {
+			if len(otherLayer.currentMetricAggregations) > 0 || (j > i && otherLayer.nextBucketAggregation != nil) {
+				return fmt.Errorf("significant_text (%s) is not supported together with metric aggregations or sub-aggregations",
+					layer.nextBucketAggregation.name)
+			}
+		}
+	}
+	return nil
+}
+
+// checkIfNestedSupported checks restrictions of nested/reverse_nested aggregations.
+// nested unnests arrays with ARRAY JOIN, which multiplies rows in the whole SQL query, so it needs to be top-level
+// (top-level metrics are moved to a separate query), and reverse_nested can only count parent documents.
+func (a *pancakeTransformer) checkIfNestedSupported(layers []*pancakeModelLayer) error {
+	isNested := false
+	for i, layer := range layers {
+		for _, metric := range layer.currentMetricAggregations {
+			switch metric.queryType.(type) {
+			case *metrics_aggregations.TopMetrics, *metrics_aggregations.TopHits:
+				if isNested {
+					return fmt.Errorf("%s is not supported inside nested aggregation", metric.name)
+				}
+			}
+		}
+		if layer.nextBucketAggregation == nil {
+			continue
+		}
+		switch layer.nextBucketAggregation.queryType.(type) {
+		case bucket_aggregations.Nested:
+			if i > 0 {
+				return fmt.Errorf("nested aggregation (%s) is only supported as a top-level aggregation", layer.nextBucketAggregation.name)
+			}
+			isNested = true
+		case bucket_aggregations.ReverseNested:
+			if !isNested {
+				return fmt.Errorf("reverse_nested aggregation (%s) is only supported inside nested aggregation", layer.nextBucketAggregation.name)
+			}
+			hasSubAggregations := i+2 < len(layers) ||
+				(i+1 < len(layers) && (layers[i+1].nextBucketAggregation != nil || len(layers[i+1].currentMetricAggregations) > 0))
+			if hasSubAggregations {
+				return fmt.Errorf("reverse_nested aggregation (%s) with sub-aggregations is not supported", layer.nextBucketAggregation.name)
+			}
+		}
+	}
 	return nil
 }
 
@@ -364,9 +428,12 @@ func (a *pancakeTransformer) createTopHitAndTopMetricsPancakes(pancake *pancakeM
 						newLayers = append(newLayers, newLayer)
 
 						newPancake := pancakeModel{
-							layers:      newLayers,
-							whereClause: pancake.whereClause,
-							sampleLimit: pancake.sampleLimit,
+							layers:              newLayers,
+							whereClause:         pancake.whereClause,
+							sampleLimit:         pancake.sampleLimit,
+							sampleLimitBy:       pancake.sampleLimitBy,
+							sampleLimitPerValue: pancake.sampleLimitPerValue,
+							arrayJoin:           pancake.arrayJoin,
 						}
 						result = append(result, &newPancake)
 					}
@@ -397,6 +464,21 @@ func (a *pancakeTransformer) transformAutoDateHistogram(layers []*pancakeModelLa
 	}
 }
 
+// Variable width histogram computes bucket boundaries from documents matching the query, so its key depends on WHERE clause.
+func (a *pancakeTransformer) transformVariableWidthHistogram(layers []*pancakeModelLayer, whereClause model.Expr) {
+	for _, layer := range layers {
+		if layer.nextBucketAggregation == nil {
+			continue
+		}
+		if variableWidthHistogram, ok := layer.nextBucketAggregation.queryType.(bucket_aggregations.VariableWidthHistogram); ok {
+			key := variableWidthHistogram.KeyExpr(whereClause)
+			layer.nextBucketAggregation.selectedColumns = []model.Expr{key}
+			layer.nextBucketAggregation.orderBy = []model.OrderByExpr{model.NewOrderByExprWithoutOrder(key)}
+			layer.nextBucketAggregation.limit = variableWidthHistogram.BucketsNr()
+		}
+	}
+}
+
 // Auto date histogram is a date histogram, that automatically creates buckets based on time range.
 // To do that we need parse WHERE clause which happens in this method.
 func (a *pancakeTransformer) transformRate(layers []*pancakeModelLayer) {
@@ -429,26 +511,56 @@ func (a *pancakeTransformer) aggregationTreeToPancakes(topLevel pancakeAggregati
 
 	for _, layers := range resultLayers {
 		sampleLimit := noSampleLimit
+		var sampleLimitBy model.Expr
+		var sampleLimitPerValue int
 		if layers[0].nextBucketAggregation != nil {
 			if sampler, ok := layers[0].nextBucketAggregation.queryType.(bucket_aggregations.SamplerInterface); ok {
 				sampleLimit = sampler.GetSampleLimit()
 			}
+			if sampler, ok := layers[0].nextBucketAggregation.queryType.(bucket_aggregations.DiversifiedSamplerInterface); ok {
+				sampleLimitBy, sampleLimitPerValue = sampler.GetSampleLimitBy(), sampler.GetMaxDocsPerValue()
+			}
 		}
 
 		if err := a.checkIfSupported(layers); err != nil {
 			return nil, err
 		}
 
+		var arrayJoin []model.Expr
+		var topLevelMetricsPancake *pancakeModel
+		if layers[0].nextBucketAggregation != nil {
+			if nested, ok := layers[0].nextBucketAggregation.queryType.(bucket_aggregations.Nested); ok {
+				arrayJoin = nested.GetArrayJoinColumns()
+				if len(layers[0].currentMetricAggregations) > 0 {
+					// top-level metrics are computed over documents, not nested objects, so we need a separate query for them
+					metricsLayer := newPancakeModelLayer(nil)
+					metricsLayer.currentMetricAggregations = layers[0].currentMetricAggregations
+					layers[0].currentMetricAggregations = make([]*pancakeModelMetricAggregation, 0)
+					topLevelMetricsPancake = &pancakeModel{
+						layers:      []*pancakeModelLayer{metricsLayer},
+						whereClause: topLevel.whereClause,
+					}
+				}
+			}
+		}
+
 		a.connectPipelineAggregations(layers)
 		a.transformAutoDateHistogram(layers, topLevel.whereClause)
+		a.transformVariableWidthHistogram(layers, topLevel.whereClause)
 		a.transformRate(layers)
 
 		newPancake := pancakeModel{
-			layers:      layers,
-			whereClause: topLevel.whereClause,
-			sampleLimit: sampleLimit,
+			layers:              layers,
+			whereClause:         topLevel.whereClause,
+			sampleLimit:         sampleLimit,
+			sampleLimitBy:       sampleLimitBy,
+			sampleLimitPerValue: sampleLimitPerValue,
+			arrayJoin:           arrayJoin,
 		}
 		pancakeResults = append(pancakeResults, &newPancake)
+		if topLevelMetricsPancake != nil {
+			pancakeResults = append(pancakeResults, topLevelMetricsPancake)
+		}
 
 		// TODO: if both top_hits/top_metrics, and filters, it probably won't work...
 		// Care: order of these two functions is unfortunately important.
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/QuesmaOrg/quesma/platform/model"
 	"github.com/QuesmaOrg/quesma/platform/model/pipeline_aggregations"
+	"github.com/QuesmaOrg/quesma/platform/parsers/painful"
 	"github.com/QuesmaOrg/quesma/platform/util"
 	"strings"
 )
@@ -23,6 +24,14 @@ func (cw *ClickhouseQueryTranslator) parsePipelineAggregations(queryMap QueryMap
 		"min_bucket":     cw.parseMinBucket,
 		"max_bucket":     cw.parseMaxBucket,
 		"sum_bucket":     cw.parseSumBucket,
+
+		"stats_bucket":          cw.parseStatsBucket,
+		"extended_stats_bucket": cw.parseExtendedStatsBucket,
+		"percentiles_bucket":    cw.parsePercentilesBucket,
+		"bucket_selector":       cw.parseBucketSelector,
+		"bucket_sort":           cw.parseBucketSort,
+		"moving_fn":             cw.parseMovingFn,
+		"moving_percentiles":    cw.parseMovingPercentiles,
 	}
 
 	for aggrName, aggrParser := range parsers {
@@ -86,6 +95,242 @@ func (cw *ClickhouseQueryTranslator) parseSumBucket(params QueryMap) (model.Quer
 	return pipeline_aggregations.NewSumBucket(cw.Ctx, bucketsPath), nil
 }
 
+func (cw *ClickhouseQueryTranslator) parseStatsBucket(params QueryMap) (model.QueryType, error) {
+	bucketsPath, err := cw.parseBucketsPath(params, "stats_bucket")
+	if err != nil {
+		return nil, err
+	}
+	return pipeline_aggregations.NewStatsBucket(cw.Ctx, bucketsPath), nil
+}
+
+func (cw *ClickhouseQueryTranslator) parseExtendedStatsBucket(params QueryMap) (model.QueryType, error) {
+	bucketsPath, err := cw.parseBucketsPath(params, "extended_stats_bucket")
+	if err != nil {
+		return nil, err
+	}
+
+	const defaultSigma = 2.0
+	sigma := defaultSigma
+	if sigmaRaw, exists := params["sigma"]; exists {
+		var ok bool
+		if sigma, ok = sigmaRaw.(float64); !ok || sigma < 0 {
+			return nil, fmt.Errorf("sigma must be a non-negative number, got: %v (type: %T)", sigmaRaw, sigmaRaw)
+		}
+	}
+	return pipeline_aggregations.NewExtendedStatsBucket(cw.Ctx, bucketsPath, sigma), nil
+}
+
+func (cw *ClickhouseQueryTranslator) parsePercentilesBucket(params QueryMap) (model.QueryType, error) {
+	bucketsPath, err := cw.parseBucketsPath(params, "percentiles_bucket")
+	if err != nil {
+		return nil, err
+	}
+
+	percents := pipeline_aggregations.PercentilesBucketDefaultPercents
+	if percentsRaw, exists := params["percents"]; exists {
+		percentsArr, ok := percentsRaw.([]any)
+		if !ok {
+			return nil, fmt.Errorf("percents is not an array, but %T, value: %v", percentsRaw, percentsRaw)
+		}
+		percents = make([]float64, 0, len(percentsArr))
+		for _, percentRaw := range percentsArr {
+			percent, ok := percentRaw.(float64)
+			if !ok || percent < 0 || percent > 100 {
+				return nil, fmt.Errorf("percent must be a number in [0, 100], got: %v (type: %T)", percentRaw, percentRaw)
+			}
+			percents = append(percents, percent)
+		}
+	}
+
+	const defaultKeyed = true
+	keyed := defaultKeyed
+	if keyedRaw, exists := params["keyed"]; exists {
+		var ok bool
+		if keyed, ok = keyedRaw.(bool); !ok {
+			return nil, fmt.Errorf("keyed is not a bool, but %T, value: %v", keyedRaw, keyedRaw)
+		}
+	}
+	return pipeline_aggregations.NewPercentilesBucket(cw.Ctx, bucketsPath, percents, keyed), nil
+}
+
+func (cw *ClickhouseQueryTranslator) parseBucketSelector(params QueryMap) (model.QueryType, error) {
+	bucketsPathsRaw, ok := params["buckets_path"].(QueryMap)
+	if !ok {
+		return nil, fmt.Errorf("buckets_path in bucket_selector is not a map, but %T, value: %v", params["buckets_path"], params["buckets_path"])
+	}
+	bucketsPaths := make(map[string]string, len(bucketsPathsRaw))
+	for variable, pathRaw := range bucketsPathsRaw {
+		path, ok := pathRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("buckets_path is not a map with string values, but %T %v", pathRaw, pathRaw)
+		}
+		bucketsPaths[variable] = path
+	}
+
+	source, err := cw.parseScriptSource(params, "bucket_selector")
+	if err != nil {
+		return nil, err
+	}
+
+	script, err := painful.ParsePainless(source)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse bucket_selector script '%s': %v", source, err)
+	}
+
+	if err = cw.checkGapPolicy(params); err != nil {
+		return nil, err
+	}
+	bucketSelector, err := pipeline_aggregations.NewBucketSelector(cw.Ctx, bucketsPaths, source, script)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported bucket_selector script '%s': %v", source, err)
+	}
+	return bucketSelector, nil
+}
+
+func (cw *ClickhouseQueryTranslator) parseBucketSort(params QueryMap) (model.QueryType, error) {
+	var sortFields []pipeline_aggregations.BucketSortField
+	if sortRaw, exists := params["sort"]; exists {
+		sortArr, ok := sortRaw.([]any)
+		if !ok {
+			sortArr = []any{sortRaw} // single sort field doesn't need to be in an array
+		}
+		for _, sortFieldRaw := range sortArr {
+			switch sortField := sortFieldRaw.(type) {
+			case string: // default order is ascending
+				sortFields = append(sortFields, pipeline_aggregations.BucketSortField{BucketsPath: sortField})
+			case QueryMap:
+				for path, orderRaw := range sortField {
+					if orderMap, ok := orderRaw.(QueryMap); ok {
+						orderRaw = orderMap["order"]
+					}
+					order, ok := orderRaw.(string)
+					if !ok || (order != "asc" && order != "desc") {
+						return nil, fmt.Errorf("invalid order in bucket_sort: %v", orderRaw)
+					}
+					sortFields = append(sortFields, pipeline_aggregations.BucketSortField{BucketsPath: path, Desc: order == "desc"})
+				}
+			default:
+				return nil, fmt.Errorf("invalid sort field in bucket_sort: %v (type: %T)", sortFieldRaw, sortFieldRaw)
+			}
+		}
+	}
+
+	from := 0
+	if fromRaw, exists := params["from"]; exists {
+		fromFloat, ok := fromRaw.(float64)
+		if !ok || fromFloat < 0 {
+			return nil, fmt.Errorf("from in bucket_sort must be a non-negative number, got: %v (type: %T)", fromRaw, fromRaw)
+		}
+		from = int(fromFloat)
+	}
+	size := pipeline_aggregations.BucketSortNoSize
+	if sizeRaw, exists := params["size"]; exists {
+		sizeFloat, ok := sizeRaw.(float64)
+		if !ok || sizeFloat < 0 {
+			return nil, fmt.Errorf("size in bucket_sort must be a non-negative number, got: %v (type: %T)", sizeRaw, sizeRaw)
+		}
+		size = int(sizeFloat)
+	}
+
+	if err := cw.checkGapPolicy(params); err != nil {
+		return nil, err
+	}
+	insertZeros := params["gap_policy"] == "insert_zeros"
+	return pipeline_aggregations.NewBucketSort(cw.Ctx, sortFields, from, size, insertZeros), nil
+}
+
+func (cw *ClickhouseQueryTranslator) parseMovingFn(params QueryMap) (model.QueryType, error) {
+	bucketsPath, err := cw.parseBucketsPath(params, "moving_fn")
+	if err != nil {
+		return nil, err
+	}
+	window, shift, err := cw.parseWindowAndShift(params, "moving_fn")
+	if err != nil {
+		return nil, err
+	}
+	source, err := cw.parseScriptSource(params, "moving_fn")
+	if err != nil {
+		return nil, err
+	}
+
+	script, err := painful.ParsePainless(source)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse moving_fn script '%s': %v", source, err)
+	}
+	if err = pipeline_aggregations.ValidateMovingFnScript(script, window); err != nil {
+		return nil, fmt.Errorf("unsupported moving_fn script '%s': %v", source, err)
+	}
+
+	if err = cw.checkGapPolicy(params); err != nil {
+		return nil, err
+	}
+	return pipeline_aggregations.NewMovingFn(cw.Ctx, bucketsPath, script, window, shift), nil
+}
+
+func (cw *ClickhouseQueryTranslator) parseMovingPercentiles(params QueryMap) (model.QueryType, error) {
+	bucketsPath, err := cw.parseBucketsPath(params, "moving_percentiles")
+	if err != nil {
+		return nil, err
+	}
+	window, shift, err := cw.parseWindowAndShift(params, "moving_percentiles")
+	if err != nil {
+		return nil, err
+	}
+	return pipeline_aggregations.NewMovingPercentiles(cw.Ctx, bucketsPath, window, shift), nil
+}
+
+// parseWindowAndShift parses params of moving_* aggregations: window (required, positive) and shift (optional, default 0)
+func (cw *ClickhouseQueryTranslator) parseWindowAndShift(params QueryMap, aggregationName string) (window, shift int, err error) {
+	windowRaw, exists := params["window"]
+	if !exists {
+		return 0, 0, fmt.Errorf("no window in %s", aggregationName)
+	}
+	windowFloat, ok := windowRaw.(float64)
+	if !ok || windowFloat <= 0 {
+		return 0, 0, fmt.Errorf("window in %s must be a positive number, got: %v (type: %T)", aggregationName, windowRaw, windowRaw)
+	}
+
+	if shiftRaw, exists := params["shift"]; exists {
+		shiftFloat, ok := shiftRaw.(float64)
+		if !ok {
+			return 0, 0, fmt.Errorf("shift in %s is not a number, but %T, value: %v", aggregationName, shiftRaw, shiftRaw)
+		}
+		shift = int(shiftFloat)
+	}
+	return int(windowFloat), shift, nil
+}
+
+// parseScriptSource returns source of the script, which can be either a string, or a map with "source" key.
+func (cw *ClickhouseQueryTranslator) parseScriptSource(params QueryMap, aggregationName string) (source string, err error) {
+	switch script := params["script"].(type) {
+	case string:
+		return script, nil
+	case QueryMap:
+		if lang, exists := script["lang"]; exists && lang != "painless" && lang != "expression" {
+			return "", fmt.Errorf("unsupported script language in %s: %v", aggregationName, lang)
+		}
+		if source, ok := script["source"].(string); ok {
+			return source, nil
+		}
+		return "", fmt.Errorf("source in %s's script is not a string, but %T, value: %v", aggregationName, script["source"], script["source"])
+	default:
+		return "", fmt.Errorf("script in %s is not a string or a map, but %T, value: %v", aggregationName, script, script)
+	}
+}
+
+// checkGapPolicy returns error if gap_policy is present and invalid. Default (and most common) gap_policy is "skip".
+func (cw *ClickhouseQueryTranslator) checkGapPolicy(params QueryMap) error {
+	gapPolicyRaw, exists := params["gap_policy"]
+	if !exists {
+		return nil
+	}
+	switch gapPolicyRaw {
+	case "skip", "insert_zeros", "keep_values":
+		return nil
+	}
+	return fmt.Errorf("invalid gap_policy: %v", gapPolicyRaw)
+}
+
 func (cw *ClickhouseQueryTranslator) parseSerialDiff(params QueryMap) (model.QueryType, error) {
 	// buckets_path
 	bucketsPath, err := cw.parseBucketsPath(params, "serial_diff")
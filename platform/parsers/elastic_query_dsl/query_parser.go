@@ -7,6 +7,7 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/common_table"
 	"github.com/QuesmaOrg/quesma/platform/database_common"
 	"github.com/QuesmaOrg/quesma/platform/logger"
 	"github.com/QuesmaOrg/quesma/platform/model"
@@ -21,6 +22,7 @@ import (
 	"github.com/k0kubun/pp"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -44,6 +46,9 @@ func (cw *ClickhouseQueryTranslator) ParseQuery(body types.JSON) (*model.Executi
 
 	simpleQuery, hitsInfo, highlighter, err := cw.parseQueryInternal(body)
 	if err != nil || !simpleQuery.CanParse {
+		if err == nil && cw.endUserError != nil {
+			err = cw.endUserError
+		}
 		logger.WarnWithCtx(cw.Ctx).Msgf("error parsing query: %v", err)
 		return model.NewExecutionPlan(nil, nil), err
 	}
@@ -74,7 +79,7 @@ func (cw *ClickhouseQueryTranslator) ParseQuery(body types.JSON) (*model.Executi
 		queries = append(queries, listQuery)
 	}
 
-	runtimeMappings, err := ParseRuntimeMappings(body) // we apply post query transformer for certain aggregation types
+	runtimeMappings, err := ParseRuntimeMappings(body, cw.Schema) // we apply post query transformer for certain aggregation types
 	if err != nil {
 		return model.NewExecutionPlan(nil, nil), err
 	}
@@ -120,6 +125,10 @@ func (cw *ClickhouseQueryTranslator) buildListQueryIfNeeded(
 	if fullQuery != nil {
 		highlighter.SetTokensToHighlight(fullQuery.SelectCommand)
 		queryType := typical_queries.NewHits(cw.Ctx, cw.Table, &highlighter, simpleQuery.SortFieldNames, true, false, false, cw.Indexes)
+		if cw.shouldComputeScores(simpleQuery, queryInfo) {
+			cw.addScoreToHitsQuery(fullQuery, simpleQuery)
+			queryType = queryType.WithComputedScores()
+		}
 		fullQuery.Type = &queryType
 		fullQuery.Highlighter = highlighter
 		fullQuery.SearchAfterFieldNames = simpleQuery.SortFieldNames
@@ -156,6 +165,10 @@ func (cw *ClickhouseQueryTranslator) parseQueryInternal(body types.JSON) (*model
 
 	if sortPart, ok := queryAsMap["sort"]; ok {
 		parsedQuery.OrderBy, parsedQuery.SortFieldNames = cw.parseSortFields(sortPart)
+		parsedQuery.OrderBy = resolveScoreInOrderBy(parsedQuery.OrderBy, parsedQuery.Score)
+		if cw.endUserError != nil { // e.g. script of `_script` sort can't be translated
+			parsedQuery.CanParse = false
+		}
 	}
 	size := cw.parseSize(queryAsMap, defaultQueryResultSize)
 
@@ -176,10 +189,18 @@ func (cw *ClickhouseQueryTranslator) parseQueryInternal(body types.JSON) (*model
 		}
 	}
 
+	trackScores := false
+	if trackScoresRaw, ok := queryAsMap["track_scores"]; ok {
+		if trackScores, ok = trackScoresRaw.(bool); !ok {
+			logger.WarnWithCtx(cw.Ctx).Msgf("invalid track_scores type: %T, value: %v. Expected bool", trackScoresRaw, trackScoresRaw)
+		}
+	}
+
 	queryInfo := cw.tryProcessSearchMetadata(queryAsMap)
 	queryInfo.Size = size
 	queryInfo.TrackTotalHits = trackTotalHits
 	queryInfo.SearchAfter = queryAsMap["search_after"]
+	queryInfo.TrackScores = trackScores
 
 	return &parsedQuery, queryInfo, highlighter, nil
 }
@@ -282,6 +303,14 @@ func (cw *ClickhouseQueryTranslator) parseQueryMap(queryMap QueryMap) model.Simp
 		"simple_query_string": cw.parseQueryString,
 		"regexp":              cw.parseRegexp,
 		"geo_bounding_box":    cw.parseGeoBoundingBox,
+		"function_score":      cw.parseFunctionScore,
+		"boosting":            cw.parseBoosting,
+		"dis_max":             cw.parseDisMax,
+		"match_bool_prefix":   cw.parseMatchBoolPrefix,
+		"match_phrase_prefix": cw.parseMatchPhrasePrefix,
+		"fuzzy":               cw.parseFuzzy,
+		"terms_set":           cw.parseTermsSet,
+		"script":              cw.parseScript,
 	}
 	for k, v := range queryMap {
 		if f, ok := parseMap[k]; ok {
@@ -307,16 +336,52 @@ func (cw *ClickhouseQueryTranslator) parseQueryMap(queryMap QueryMap) model.Simp
 	return model.NewSimpleQueryInvalid()
 }
 
-// `constant_score` query is just a wrapper for filter query which returns constant relevance score, which we ignore anyway
+// `constant_score` query is just a wrapper for filter query which returns constant relevance score.
+// If `boost` is present, it's the score of every matching document.
 func (cw *ClickhouseQueryTranslator) parseConstantScore(queryMap QueryMap) model.SimpleQuery {
-	if _, ok := queryMap["filter"]; ok {
-		return cw.parseBool(queryMap)
+	if filter, ok := queryMap["filter"]; ok {
+		result := cw.parseBool(QueryMap{"filter": filter})
+		if boost, exists := cw.parseFloatParam(queryMap, "boost"); exists {
+			result.Score = model.NewLiteral(boost)
+		}
+		return result
 	} else {
 		logger.Error().Msgf("parsing error: `constant_score` needs to wrap `filter` query")
 		return model.NewSimpleQueryInvalid()
 	}
 }
 
+// IsGeneratedDocumentId returns true if id has the format of ids we return in search hits:
+// `<hex-encoded timestamp>qqq<hex-encoded source hash>`. Other ids (e.g. supplied by clients) never point to a ClickHouse row
+// by their timestamp, even if they look similar, like `beefqqq1`.
+func IsGeneratedDocumentId(id string) bool {
+	_, isGenerated := generatedIdTimestamp(context.Background(), id)
+	return isGenerated
+}
+
+// generatedIdTimestamp returns the timestamp of the document, encoded in the id we've generated
+func generatedIdTimestamp(ctx context.Context, id string) (time.Time, bool) {
+	timestampInHex, hash, found := strings.Cut(id, uuidSeparator)
+	if !found || len(timestampInHex) == 0 || len(hash) == 0 {
+		return time.Time{}, false
+	}
+	timestamp, err := hex.DecodeString(timestampInHex)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return NewDateManager(ctx).parseStrictDateOptionalTimeOrEpochMillis(string(timestamp))
+}
+
+// ParseIds translates document ids to a WHERE clause, exactly like `ids` query does.
+// Beware: for generated ids it only compares timestamps, as source hash can't be verified in SQL.
+func (cw *ClickhouseQueryTranslator) ParseIds(ids []string) model.SimpleQuery {
+	values := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id)
+	}
+	return cw.parseIds(QueryMap{"values": values})
+}
+
 func (cw *ClickhouseQueryTranslator) parseIds(queryMap QueryMap) model.SimpleQuery {
 	idsRaw, err := cw.parseArrayField(queryMap, "values")
 	if err != nil {
@@ -334,32 +399,29 @@ func (cw *ClickhouseQueryTranslator) parseIds(queryMap QueryMap) model.SimpleQue
 		}
 	}
 
+	// Ids supplied at ingest are compared with the column storing them. Other ids, which we didn't generate, can't match any row.
+	_, hasStoredIds := cw.Table.Cols[common_table.DocumentIdColumn]
+	var storedIds []model.Expr
+	generatedIds := make([]string, 0, len(ids))
+	generatedTimestamps := make([]time.Time, 0, len(ids))
+	for _, id := range ids {
+		if timestamp, isGenerated := generatedIdTimestamp(cw.Ctx, id); isGenerated {
+			generatedIds = append(generatedIds, id)
+			generatedTimestamps = append(generatedTimestamps, timestamp)
+		} else if hasStoredIds {
+			storedIds = append(storedIds, model.NewLiteralSingleQuoteString(id))
+			uniqueIds = append(uniqueIds, id)
+		}
+	}
+	ids = generatedIds
+
 	// when our generated ID appears in query looks like this:
 	// `<hex-encoded timestamp>qqq<hex-encoded source hash>`
-	// Therefore we need to convert the hex-encoded timestamp to assemble the SQL query
+	// Therefore we need the decoded timestamp (output in UTC) to assemble the SQL query
 	for i, id := range ids {
-		idInHex := strings.Split(id, uuidSeparator)[0]
-		if idAsStr, err := hex.DecodeString(idInHex); err != nil {
-			logger.ErrorWithCtx(cw.Ctx).Msgf("error parsing document id %s: %v", id, err)
-			return model.NewSimpleQueryInvalid()
-		} else {
-			// Before:
-			// tsWithoutTZ := strings.TrimSuffix(string(idAsStr), " +0000 UTC")
-			// ids[i] = fmt.Sprintf("'%s'", tsWithoutTZ)
-			//
-			// Now we stop trimming, instead parse the date ourselves, and then output in UTC.
-			dm := NewDateManager(cw.Ctx)
-
-			if tsAsTime, ok := dm.parseStrictDateOptionalTimeOrEpochMillis(string(idAsStr)); ok {
-				tsUTC := tsAsTime.UTC()
-				tsGoodFormat := tsUTC.Format("2006-01-02 15:04:05.000000000")
-				tsTrimmedNano := strings.TrimRight(tsGoodFormat, "0")
-				ids[i] = fmt.Sprintf("'%s'", tsTrimmedNano)
-			} else {
-				logger.ErrorWithCtx(cw.Ctx).Msgf("error parsing document id %s:, idAsStr: %v", id, idAsStr)
-				return model.NewSimpleQueryInvalid()
-			}
-		}
+		tsGoodFormat := generatedTimestamps[i].UTC().Format("2006-01-02 15:04:05.000000000")
+		tsTrimmedNano := strings.TrimRight(tsGoodFormat, "0")
+		ids[i] = fmt.Sprintf("'%s'", tsTrimmedNano)
 		uniqueIds = append(uniqueIds, id)
 	}
 
@@ -371,7 +433,9 @@ func (cw *ClickhouseQueryTranslator) parseIds(queryMap QueryMap) model.SimpleQue
 		timestampColumnName = model.TimestampFieldName
 	}
 
-	if column, ok := cw.Table.Cols[timestampColumnName]; ok {
+	if len(ids) == 0 {
+		// no generated ids, we don't need the timestamp column
+	} else if column, ok := cw.Table.Cols[timestampColumnName]; ok {
 		switch column.Type.String() {
 		case database_common.DateTime64.String():
 			idToSql = func(id string) (model.Expr, error) {
@@ -417,19 +481,31 @@ func (cw *ClickhouseQueryTranslator) parseIds(queryMap QueryMap) model.SimpleQue
 		idsTuple := model.NewTupleExpr(idsAsExprs...)
 		whereStmt = model.NewInfixExpr(model.NewColumnRef(timestampColumnName), " IN ", idsTuple)
 	}
+	if len(storedIds) > 0 {
+		var storedIdsStmt model.Expr
+		if len(storedIds) == 1 {
+			storedIdsStmt = model.NewInfixExpr(model.NewColumnRef(common_table.DocumentIdColumn), " = ", storedIds[0])
+		} else {
+			storedIdsStmt = model.NewInfixExpr(model.NewColumnRef(common_table.DocumentIdColumn), " IN ", model.NewTupleExpr(storedIds...))
+		}
+		if len(ids) == 0 {
+			whereStmt = storedIdsStmt
+		} else {
+			whereStmt = model.Or([]model.Expr{whereStmt, storedIdsStmt})
+		}
+	}
 	cw.UniqueIDsUsedInTheQuery = uniqueIds // a crucial side effect here - queries against _id field requires special treatment
 	return model.NewSimpleQuery(whereStmt, true)
 }
 
 // Parses each model.SimpleQuery separately, returns list of translated SQLs
-func (cw *ClickhouseQueryTranslator) parseQueryMapArray(queryMaps []interface{}) (stmts []model.Expr, canParse bool) {
-	stmts = make([]model.Expr, len(queryMaps))
+func (cw *ClickhouseQueryTranslator) parseQueryMapArray(queryMaps []interface{}) (queries []model.SimpleQuery, canParse bool) {
+	queries = make([]model.SimpleQuery, len(queryMaps))
 	canParse = true
 	for i, v := range queryMaps {
 		if vAsMap, ok := v.(QueryMap); ok {
-			query := cw.parseQueryMap(vAsMap)
-			stmts[i] = query.WhereClause
-			if !query.CanParse {
+			queries[i] = cw.parseQueryMap(vAsMap)
+			if !queries[i].CanParse {
 				canParse = false
 			}
 		} else {
@@ -437,33 +513,53 @@ func (cw *ClickhouseQueryTranslator) parseQueryMapArray(queryMaps []interface{})
 			canParse = false
 		}
 	}
-	return stmts, canParse
+	return queries, canParse
 }
 
 func (cw *ClickhouseQueryTranslator) iterateListOrDictAndParse(queryMaps interface{}) (stmts []model.Expr, canParse bool) {
+	queries, canParse := cw.iterateListOrDictAndParseQueries(queryMaps)
+	stmts = make([]model.Expr, len(queries))
+	for i, query := range queries {
+		stmts[i] = query.WhereClause
+	}
+	return stmts, canParse
+}
+
+// iterateListOrDictAndParseQueries is like iterateListOrDictAndParse, but returns whole parsed queries (e.g. with their scores),
+// not only WHERE clauses.
+func (cw *ClickhouseQueryTranslator) iterateListOrDictAndParseQueries(queryMaps interface{}) (queries []model.SimpleQuery, canParse bool) {
 	switch queryMapsTyped := queryMaps.(type) {
 	case []interface{}:
 		return cw.parseQueryMapArray(queryMapsTyped)
 	case QueryMap:
 		simpleQuery := cw.parseQueryMap(queryMapsTyped)
-		if simpleQuery.WhereClause != nil {
-			return []model.Expr{simpleQuery.WhereClause}, simpleQuery.CanParse
+		if simpleQuery.WhereClause != nil || simpleQuery.Score != nil {
+			return []model.SimpleQuery{simpleQuery}, simpleQuery.CanParse
 		}
-		return []model.Expr{}, simpleQuery.CanParse
+		return []model.SimpleQuery{}, simpleQuery.CanParse
 	default:
 		logger.WarnWithCtx(cw.Ctx).Msgf("Invalid query type: %T, value: %v", queryMapsTyped, queryMapsTyped)
-		return []model.Expr{}, false
+		return []model.SimpleQuery{}, false
 	}
 }
 
 // TODO: minimum_should_match parameter. Now only ints supported and >1 changed into 1
+// Score of a bool query is the sum of scores of its `must` and matching `should` clauses (`filter` and `must_not` don't score).
+// It's nil if none of the clauses influences scoring.
 func (cw *ClickhouseQueryTranslator) parseBool(queryMap QueryMap) model.SimpleQuery {
-	var andStmts []model.Expr
+	var andStmts, scores []model.Expr
+	scoreNeeded := false
 	canParse := true // will stay true only if all subqueries can be parsed
 	for _, andPhrase := range []string{"must", "filter"} {
 		if queries, ok := queryMap[andPhrase]; ok {
-			newAndStmts, canParseThis := cw.iterateListOrDictAndParse(queries)
-			andStmts = append(andStmts, newAndStmts...)
+			newAndQueries, canParseThis := cw.iterateListOrDictAndParseQueries(queries)
+			for _, query := range newAndQueries {
+				andStmts = append(andStmts, query.WhereClause)
+				if andPhrase == "must" {
+					scores = append(scores, scoreOrConstant(query))
+					scoreNeeded = scoreNeeded || query.Score != nil
+				}
+			}
 			canParse = canParse && canParseThis
 		}
 	}
@@ -484,14 +580,22 @@ func (cw *ClickhouseQueryTranslator) parseBool(queryMap QueryMap) model.SimpleQu
 		logger.WarnWithCtx(cw.Ctx).Msgf("minimum_should_match > 1 not supported, changed to 1")
 		minimumShouldMatch = 1
 	}
-	if queries, ok := queryMap["should"]; ok && minimumShouldMatch == 1 {
-		orSqls, canParseThis := cw.iterateListOrDictAndParse(queries)
-		orSql := model.Or(orSqls)
-		canParse = canParse && canParseThis
-		if len(andStmts) == 0 {
-			sql = orSql
-		} else if orSql != nil {
-			sql = model.And([]model.Expr{sql, orSql})
+	if queries, ok := queryMap["should"]; ok {
+		orQueries, canParseThis := cw.iterateListOrDictAndParseQueries(queries)
+		orSqls := make([]model.Expr, len(orQueries))
+		for i, query := range orQueries {
+			orSqls[i] = query.WhereClause
+			scores = append(scores, scoreIfMatches(query))
+			scoreNeeded = scoreNeeded || query.Score != nil
+		}
+		if minimumShouldMatch == 1 {
+			orSql := model.Or(orSqls)
+			canParse = canParse && canParseThis
+			if len(andStmts) == 0 {
+				sql = orSql
+			} else if orSql != nil {
+				sql = model.And([]model.Expr{sql, orSql})
+			}
 		}
 	}
 
@@ -504,7 +608,11 @@ func (cw *ClickhouseQueryTranslator) parseBool(queryMap QueryMap) model.SimpleQu
 			sql = model.And([]model.Expr{sql, sqlNot})
 		}
 	}
-	return model.NewSimpleQuery(sql, canParse)
+	result := model.NewSimpleQuery(sql, canParse)
+	if scoreNeeded {
+		result.Score = sumScores(scores)
+	}
+	return result
 }
 
 func (cw *ClickhouseQueryTranslator) parseTerm(queryMap QueryMap) model.SimpleQuery {
@@ -515,7 +623,11 @@ func (cw *ClickhouseQueryTranslator) parseTerm(queryMap QueryMap) model.SimpleQu
 				logger.WarnWithCtx(cw.Ctx).Msgf("term %s=%v in query body, ignoring in result SQL", k, v)
 				return model.NewSimpleQuery(model.TrueExpr, true)
 			}
+			boundType, isRangeField := cw.rangeFieldBoundType(k)
 			fieldName := ResolveField(cw.Ctx, k, cw.Schema)
+			if isRangeField {
+				return cw.rangeFieldTermQuery(fieldName, boundType, model.NewLiteral(sprint(v)))
+			}
 			whereClause = model.NewInfixExpr(model.NewColumnRef(fieldName), "=", model.NewLiteral(sprint(v)))
 			return model.NewSimpleQuery(whereClause, true)
 		}
@@ -596,7 +708,9 @@ func (cw *ClickhouseQueryTranslator) parseMatch(queryMap QueryMap, matchPhrase b
 				subQueries = strings.Split(vAsString, " ")
 			}
 			statements := make([]model.Expr, 0, len(subQueries))
+			scores := make([]model.Expr, 0, len(subQueries))
 			for _, subQuery := range subQueries {
+				scores = append(scores, cw.matchScore(fieldName, subQuery))
 				if fieldName == "_id" { // We compute this field on the fly using our custom logic, so we have to parse it differently
 					computedIdMatchingQuery := cw.parseIds(QueryMap{"values": []interface{}{subQuery}})
 					statements = append(statements, computedIdMatchingQuery.WhereClause)
@@ -606,7 +720,9 @@ func (cw *ClickhouseQueryTranslator) parseMatch(queryMap QueryMap, matchPhrase b
 					statements = append(statements, simpleStat)
 				}
 			}
-			return model.NewSimpleQuery(model.Or(statements), true)
+			result := model.NewSimpleQuery(model.Or(statements), true)
+			result.Score = sumScores(scores)
+			return result
 		}
 
 		statement := model.NewInfixExpr(model.NewColumnRef(fieldName), model.MatchOperator, model.NewLiteral(sprint(vUnNested)))
@@ -662,15 +778,33 @@ func (cw *ClickhouseQueryTranslator) parseMultiMatch(queryMap QueryMap) model.Si
 	}
 
 	sqls := make([]model.Expr, len(fields)*len(subQueries))
+	fieldScores := make([]model.Expr, 0, len(fields))
 	i := 0
 	for _, field := range fields {
+		termScores := make([]model.Expr, 0, len(subQueries))
 		for _, subQ := range subQueries {
 			simpleStat := model.NewInfixExpr(model.NewColumnRef(field), "iLIKE", model.NewLiteral("'%"+subQ+"%'"))
 			sqls[i] = simpleStat
 			i++
+			termScores = append(termScores, cw.matchScore(field, subQ))
 		}
+		if fieldScore := sumScores(termScores); fieldScore != nil {
+			fieldScores = append(fieldScores, fieldScore)
+		}
+	}
+
+	result := model.NewSimpleQuery(model.Or(sqls), true)
+	// "best_fields" (default) takes score of the best matching field, other types sum scores of all fields
+	switch {
+	case len(fieldScores) == 0:
+	case len(fieldScores) == 1:
+		result.Score = fieldScores[0]
+	case cw.parseStringParam(queryMap, "type", "best_fields") == "best_fields" || wereDone:
+		result.Score = model.NewFunction("greatest", fieldScores...)
+	default:
+		result.Score = sumScores(fieldScores)
 	}
-	return model.NewSimpleQuery(model.Or(sqls), true)
+	return result
 }
 
 // prefix works only on strings
@@ -737,26 +871,42 @@ func (cw *ClickhouseQueryTranslator) parseWildcard(queryMap QueryMap) model.Simp
 }
 
 // This one is really complicated (https://www.elastic.co/guide/en/elasticsearch/reference/current/query-dsl-query-string-query.html)
-// `query` uses Lucene language, we don't support 100% of it, but most.
+// `query` uses Lucene language, see lucene package for what we support.
+// Supported parameters: `query`, `fields` (with optional boosts, which are ignored), `default_field` and `default_operator`.
+// Queries we can't translate end the whole request with a user-facing error, as silently returning wrong results is worse.
 func (cw *ClickhouseQueryTranslator) parseQueryString(queryMap QueryMap) model.SimpleQuery {
 	var fields []string
 	if fieldsRaw, ok := queryMap["fields"]; ok {
 		fields = cw.extractFields(fieldsRaw.([]interface{}))
+	} else if defaultField, ok := queryMap["default_field"].(string); ok {
+		fields = cw.extractFields([]interface{}{defaultField})
 	} else {
 		fields = []string{model.FullTextFieldNamePlaceHolder}
 	}
 
 	query := queryMap["query"].(string) // query: (Required, string)
+	defaultOperator := cw.parseStringParam(queryMap, "default_operator", lucene.DefaultOperatorOr)
+	analyzeWildcard := cw.parseBoolField(queryMap, "analyze_wildcard", false)
 
-	// we always call `TranslateToSQL` - Lucene parser returns "false" in case of invalid query
-	whereStmtFromLucene := lucene.TranslateToSQL(cw.Ctx, query, fields, cw.Schema)
+	whereStmtFromLucene, err := lucene.TranslateToSQL(cw.Ctx, query, fields, defaultOperator, analyzeWildcard, cw.Schema)
+	if err != nil {
+		logger.WarnWithCtx(cw.Ctx).Msgf("can't translate query_string query: %v", err)
+		cw.endUserError = err
+		return model.NewSimpleQueryInvalid()
+	}
 	return model.NewSimpleQuery(whereStmtFromLucene, true)
 }
 
+// parseNested wraps the inner query, as it must match a single object of the nested array, not the whole document.
+// (translated to arrayExists in schema transformations, where we know which columns are arrays)
 func (cw *ClickhouseQueryTranslator) parseNested(queryMap QueryMap) model.SimpleQuery {
 	if query, ok := queryMap["query"]; ok {
 		if queryAsMap, ok := query.(QueryMap); ok {
-			return cw.parseQueryMap(queryAsMap)
+			simpleQuery := cw.parseQueryMap(queryAsMap)
+			if simpleQuery.WhereClause != nil {
+				simpleQuery.WhereClause = model.NewFunction(model.NestedQueryFunction, simpleQuery.WhereClause)
+			}
+			return simpleQuery
 		} else {
 			logger.WarnWithCtx(cw.Ctx).Msgf("invalid nested query type: %T, value: %v", query, query)
 			return model.NewSimpleQueryInvalid()
@@ -803,10 +953,20 @@ func (cw *ClickhouseQueryTranslator) parseRange(queryMap QueryMap) model.SimpleQ
 	const dateInSchemaExpected = true
 
 	for fieldName, v := range queryMap {
+		boundType, isRangeField := cw.rangeFieldBoundType(fieldName)
 		fieldName = ResolveField(cw.Ctx, fieldName, cw.Schema)
 
-		fieldType := cw.Table.GetDateTimeType(cw.Ctx, ResolveField(cw.Ctx, fieldName, cw.Schema), dateInSchemaExpected)
+		var fieldType database_common.DateTimeType
+		switch {
+		case !isRangeField:
+			fieldType = cw.Table.GetDateTimeType(cw.Ctx, ResolveField(cw.Ctx, fieldName, cw.Schema), dateInSchemaExpected)
+		case boundType.Name == schema.QuesmaTypeTimestamp.Name:
+			fieldType = database_common.DateTime64
+		default:
+			fieldType = database_common.Invalid
+		}
 		stmts := make([]model.Expr, 0)
+		rangeFieldBounds := make(map[string]model.Expr)
 		if _, ok := v.(QueryMap); !ok {
 			logger.WarnWithCtx(cw.Ctx).Msgf("invalid range type: %T, value: %v", v, v)
 			continue
@@ -814,6 +974,9 @@ func (cw *ClickhouseQueryTranslator) parseRange(queryMap QueryMap) model.SimpleQ
 
 		keysSorted := util.MapKeysSorted(v.(QueryMap))
 		for _, op := range keysSorted {
+			if op == "relation" { // only meaningful for range fields, handled below
+				continue
+			}
 			valueRaw := v.(QueryMap)[op]
 			value := sprint(valueRaw)
 			formatAny, formatExists := v.(QueryMap)["format"]
@@ -876,6 +1039,11 @@ func (cw *ClickhouseQueryTranslator) parseRange(queryMap QueryMap) model.SimpleQ
 				finalValue = defaultValue
 			}
 
+			if isRangeField {
+				rangeFieldBounds[op] = finalValue
+				continue
+			}
+
 			field := model.NewColumnRef(fieldName)
 			switch op {
 			case "gte":
@@ -896,6 +1064,11 @@ func (cw *ClickhouseQueryTranslator) parseRange(queryMap QueryMap) model.SimpleQ
 				logger.WarnWithCtx(cw.Ctx).Msgf("invalid range operator: %s", op)
 			}
 		}
+		if isRangeField {
+			delete(rangeFieldBounds, "format")
+			relation, _ := v.(QueryMap)["relation"].(string)
+			return cw.rangeFieldQuery(fieldName, boundType, rangeFieldBounds, relation)
+		}
 		return model.NewSimpleQuery(model.And(stmts), true)
 	}
 
@@ -918,6 +1091,10 @@ func (cw *ClickhouseQueryTranslator) parseExists(queryMap QueryMap) model.Simple
 			return model.NewSimpleQueryInvalid()
 		}
 
+		if _, isRangeField := cw.rangeFieldBoundType(fieldName); isRangeField {
+			sql = rangeFieldExists(ResolveField(cw.Ctx, fieldName, cw.Schema))
+			continue
+		}
 		sql = model.NewInfixExpr(model.NewColumnRef(fieldName), "IS", model.NewLiteral("NOT NULL"))
 	}
 
@@ -971,6 +1148,11 @@ func (cw *ClickhouseQueryTranslator) extractFields(fields []interface{}) []strin
 			logger.WarnWithCtx(cw.Ctx).Msgf("invalid field type: %T, value: %v", field, field)
 			continue
 		}
+		if boostIdx := strings.LastIndex(fieldStr, "^"); boostIdx > 0 {
+			if _, err := strconv.ParseFloat(fieldStr[boostIdx+1:], 64); err == nil {
+				fieldStr = fieldStr[:boostIdx] // we don't support boosting fields, so we simply ignore it
+			}
+		}
 		if fieldStr == "*" {
 			return []string{model.FullTextFieldNamePlaceHolder}
 		}
@@ -1125,6 +1307,28 @@ func (cw *ClickhouseQueryTranslator) parseSortFields(sortMaps any) (sortColumns
 			// sortMap has only 1 key, so we can just iterate over it
 			for k, v := range sortMap {
 				sortFieldNames = append(sortFieldNames, k)
+				if k == scriptSortFieldName {
+					if col, ok := cw.parseScriptSort(v); ok {
+						sortColumns = append(sortColumns, col)
+					}
+					continue
+				}
+				if k == model.ScoreFieldName && cw.Table.GetFieldInfo(cw.Ctx, k) == database_common.NotExists {
+					order := "desc"
+					if vAsMap, ok := v.(QueryMap); ok {
+						if orderAsString, ok := vAsMap["order"].(string); ok {
+							order = orderAsString
+						}
+					} else if vAsString, ok := v.(string); ok {
+						order = vAsString
+					}
+					if col, err := createSortColumn(model.ScoreFieldName, order); err == nil {
+						sortColumns = append(sortColumns, col)
+					} else {
+						logger.WarnWithCtx(cw.Ctx).Msg(err.Error())
+					}
+					continue
+				}
 				// TODO replace cw.Table.GetFieldInfo with schema.Field[]
 				if strings.HasPrefix(k, "_") && cw.Table.GetFieldInfo(cw.Ctx, ResolveField(cw.Ctx, k, cw.Schema)) == database_common.NotExists {
 					// we're skipping ELK internal fields, like "_doc", "_id", etc.
@@ -1161,7 +1365,13 @@ func (cw *ClickhouseQueryTranslator) parseSortFields(sortMaps any) (sortColumns
 	case map[string]interface{}:
 		for fieldName, fieldValue := range sortMaps {
 			sortFieldNames = append(sortFieldNames, fieldName)
-			if strings.HasPrefix(fieldName, "_") && cw.Table.GetFieldInfo(cw.Ctx, ResolveField(cw.Ctx, fieldName, cw.Schema)) == database_common.NotExists {
+			if fieldName == scriptSortFieldName {
+				if col, ok := cw.parseScriptSort(fieldValue); ok {
+					sortColumns = append(sortColumns, col)
+				}
+				continue
+			}
+			if strings.HasPrefix(fieldName, "_") && cw.Table.GetFieldInfo(cw.Ctx, ResolveField(cw.Ctx, fieldName, cw.Schema)) == database_common.NotExists && fieldName != model.ScoreFieldName {
 				// TODO Elastic internal fields will need to be supported in the future
 				continue
 			}
@@ -1197,6 +1407,22 @@ func (cw *ClickhouseQueryTranslator) parseSortFields(sortMaps any) (sortColumns
 	}
 }
 
+// resolveScoreInOrderBy replaces `_score` placeholders created by parseSortFields with the score expression.
+// If the query doesn't influence scoring (score == nil), all documents have the same score, so we just drop them.
+func resolveScoreInOrderBy(orderBy []model.OrderByExpr, score model.Expr) []model.OrderByExpr {
+	result := make([]model.OrderByExpr, 0, len(orderBy))
+	for _, orderByExpr := range orderBy {
+		if col, ok := orderByExpr.Expr.(model.ColumnRef); ok && col.ColumnName == model.ScoreFieldName {
+			if score != nil {
+				result = append(result, model.NewOrderByExpr(score, orderByExpr.Direction))
+			}
+			continue
+		}
+		result = append(result, orderByExpr)
+	}
+	return result
+}
+
 func createSortColumn(fieldName, ordering string) (model.OrderByExpr, error) {
 	ordering = strings.ToLower(ordering)
 	switch ordering {
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/QuesmaOrg/quesma/platform/config"
 	"github.com/QuesmaOrg/quesma/platform/database_common"
+	"github.com/QuesmaOrg/quesma/platform/end_user_errors"
 	"github.com/QuesmaOrg/quesma/platform/logger"
 	"github.com/QuesmaOrg/quesma/platform/model"
 	"github.com/QuesmaOrg/quesma/platform/model/typical_queries"
@@ -333,3 +334,73 @@ func TestInvalidQueryRequests(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryStringQuery(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		expectedWhere string // empty <=> query is invalid and we should return end user error
+	}{
+		{
+			name:          "fields with boosts, default_operator AND",
+			query:         `{"query_string": {"query": "quick fox", "fields": ["message^2", "title"], "default_operator": "AND", "analyze_wildcard": true}}`,
+			expectedWhere: `(("message" __quesma_match 'quick' OR "title" __quesma_match 'quick') AND ("message" __quesma_match 'fox' OR "title" __quesma_match 'fox'))`,
+		},
+		{
+			name:          "default_field",
+			query:         `{"query_string": {"query": "title:(quick OR brown)", "default_field": "message"}}`,
+			expectedWhere: `("title" __quesma_match 'quick' OR "title" __quesma_match 'brown')`,
+		},
+		{
+			name:          "analyze_wildcard lowercases wildcard terms of full-text fields",
+			query:         `{"query_string": {"query": "Qu?ck* AND host:Web-*", "default_field": "message", "analyze_wildcard": true}}`,
+			expectedWhere: `("message" __quesma_match 'qu_ck%' AND "host" __quesma_match 'Web-%')`,
+		},
+		{
+			name:          "wildcard terms without analyze_wildcard",
+			query:         `{"query_string": {"query": "Qu?ck*", "default_field": "message"}}`,
+			expectedWhere: `"message" __quesma_match 'Qu_ck%'`,
+		},
+		{
+			name:  "syntax error",
+			query: `{"query_string": {"query": "title:(quick OR", "default_field": "message"}}`,
+		},
+		{
+			name:  "unsupported regexp",
+			query: `{"query_string": {"query": "title:/qu<1-5>ck/"}}`,
+		},
+	}
+
+	table := database_common.Table{
+		Name: tableName,
+		Cols: map[string]*database_common.Column{
+			"message": {Name: "message", Type: database_common.NewBaseType("String")},
+			"title":   {Name: "title", Type: database_common.NewBaseType("String")},
+			"host":    {Name: "host", Type: database_common.NewBaseType("LowCardinality(String)")},
+		},
+		Config: database_common.NewChTableConfigNoAttrs(),
+	}
+	currentSchema := schema.Schema{Fields: map[schema.FieldName]schema.Field{
+		"message": {PropertyName: "message", InternalPropertyName: "message", Type: schema.QuesmaTypeText},
+		"title":   {PropertyName: "title", InternalPropertyName: "title", Type: schema.QuesmaTypeText},
+		"host":    {PropertyName: "host", InternalPropertyName: "host", Type: schema.QuesmaTypeKeyword},
+	}}
+	for i, tt := range tests {
+		t.Run(util.PrettyTestName(tt.name, i), func(t *testing.T) {
+			cw := ClickhouseQueryTranslator{Table: &table, Ctx: context.Background(), Schema: currentSchema, Indexes: []string{tableName}}
+			body, err := types.ParseJSON(`{"query": ` + tt.query + `}`)
+			assert.NoError(t, err)
+
+			plan, err := cw.ParseQuery(body)
+			if tt.expectedWhere == "" {
+				var endUserError *end_user_errors.EndUserError
+				assert.ErrorAs(t, err, &endUserError)
+				return
+			}
+			assert.NoError(t, err)
+			if assert.NotEmpty(t, plan.Queries) {
+				assert.Equal(t, tt.expectedWhere, model.AsString(plan.Queries[0].SelectCommand.WhereClause))
+			}
+		})
+	}
+}
@@ -25,6 +25,10 @@ type ClickhouseQueryTranslator struct {
 
 	Indexes []string
 
+	RelevanceScoring bool // if true, we compute relevance score (`_score`) of hits, see relevance_scoring.go
+
+	endUserError error // set if the query can't be parsed for a reason we can explain to the user, e.g. query_string syntax error
+
 	// TODO this will be removed
 	Table                   *database_common.Table
 	UniqueIDsUsedInTheQuery []string // A list of UniqueIDs used in the query (via `_id` field), which has to be passed to the JSON response rendering stage.
@@ -142,18 +146,22 @@ func (cw *ClickhouseQueryTranslator) makeHits(queries []*model.Query, results []
 // FilterOutHitsIfThisIsIdQuery - If during parsing we have found that this is a query for _id,
 // we filter out hits that are not in the list of UniqueIDsUsedInTheQuery.
 // we only do this filtering based on the doc.Source hash comparison, ignoring the two first UUID parts.
+// Ids supplied at ingest are compared as they are.
 func (cw *ClickhouseQueryTranslator) FilterOutHitsIfThisIsIdQuery(hits model.SearchHits) model.SearchHits {
 	if len(cw.UniqueIDsUsedInTheQuery) == 0 {
 		return hits // not _id query, proceed as usual
 	}
 	hashesFromQuery := make([]string, 0, len(cw.UniqueIDsUsedInTheQuery))
 	for _, id := range cw.UniqueIDsUsedInTheQuery {
-		hashesFromQuery = append(hashesFromQuery, strings.Split(id, uuidSeparator)[1])
+		if _, hash, isGenerated := strings.Cut(id, uuidSeparator); isGenerated {
+			hashesFromQuery = append(hashesFromQuery, hash)
+		}
 	}
 	filteredHits := make([]model.SearchHit, 0, len(hits.Hits))
 	for _, hit := range hits.Hits {
-		hash := strings.Split(hit.ID, uuidSeparator)[1]
-		if slices.Contains(hashesFromQuery, hash) {
+		if slices.Contains(cw.UniqueIDsUsedInTheQuery, hit.ID) {
+			filteredHits = append(filteredHits, hit)
+		} else if _, hash, isGenerated := strings.Cut(hit.ID, uuidSeparator); isGenerated && slices.Contains(hashesFromQuery, hash) {
 			filteredHits = append(filteredHits, hit)
 		}
 	}
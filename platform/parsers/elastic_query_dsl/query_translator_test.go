@@ -4,6 +4,7 @@ package elastic_query_dsl
 
 import (
 	"context"
+	"github.com/QuesmaOrg/quesma/platform/common_table"
 	"github.com/QuesmaOrg/quesma/platform/database_common"
 	"github.com/QuesmaOrg/quesma/platform/model"
 	"github.com/QuesmaOrg/quesma/platform/model/typical_queries"
@@ -451,3 +452,46 @@ func TestMakeResponseSearchQueryIsProperJson(t *testing.T) {
 		_ = cw.MakeSearchResponse([]*model.Query{{Highlighter: NewEmptyHighlighter()}}, [][]model.QueryResultRow{{resultRow}})
 	}
 }
+
+func TestIdsQueryWithStoredIds(t *testing.T) {
+	const generatedId = "323032342d30312d30312030303a30303a30302e303030202b3030303020555443qqq61"
+	table := &database_common.Table{
+		Name: "logs",
+		Cols: map[string]*database_common.Column{
+			"@timestamp":                  {Name: "@timestamp", Type: database_common.NewBaseType("DateTime64")},
+			common_table.DocumentIdColumn: {Name: common_table.DocumentIdColumn, Type: database_common.NewBaseType("String")},
+		},
+	}
+	cw := ClickhouseQueryTranslator{Ctx: context.Background(), Table: table}
+
+	query := cw.ParseIds([]string{"a", "b"})
+	require.True(t, query.CanParse)
+	assert.Equal(t, `"__quesma_id" IN tuple('a', 'b')`, model.AsString(query.WhereClause))
+
+	query = cw.ParseIds([]string{"a", generatedId})
+	require.True(t, query.CanParse)
+	assert.Equal(t, `("@timestamp" = toDateTime64('2024-01-01 00:00:00.',0) OR "__quesma_id" = 'a')`, model.AsString(query.WhereClause))
+
+	// looks like a generated id, but the prefix isn't a timestamp
+	assert.False(t, IsGeneratedDocumentId("beefqqq1"))
+	query = cw.ParseIds([]string{"beefqqq1", generatedId})
+	require.True(t, query.CanParse)
+	assert.Equal(t, `("@timestamp" = toDateTime64('2024-01-01 00:00:00.',0) OR "__quesma_id" = 'beefqqq1')`, model.AsString(query.WhereClause))
+
+	query = cw.ParseIds([]string{"a", generatedId})
+	hits := cw.FilterOutHitsIfThisIsIdQuery(model.SearchHits{Hits: []model.SearchHit{
+		{ID: "a"},
+		{ID: "c"},
+		{ID: "323032342d30312d30312030303a30303a30302e303030202b3030303020555443qqq61"},
+		{ID: "323032342d30312d30312030303a30303a30302e303030202b3030303020555443qqq62"},
+	}})
+	require.Len(t, hits.Hits, 2)
+	assert.Equal(t, "a", hits.Hits[0].ID)
+	assert.Equal(t, generatedId, hits.Hits[1].ID)
+
+	// without the column, ids we didn't generate can't match any row
+	delete(table.Cols, common_table.DocumentIdColumn)
+	query = cw.ParseIds([]string{"a"})
+	require.True(t, query.CanParse)
+	assert.Equal(t, "false", model.AsString(query.WhereClause))
+}
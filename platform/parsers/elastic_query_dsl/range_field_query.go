@@ -0,0 +1,115 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package elastic_query_dsl
+
+import (
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/schema"
+	"strings"
+)
+
+// Range fields (e.g. integer_range, date_range) are stored in two columns with inclusive bounds, where NULL
+// means an unbounded side (see model.NewRangeGte/model.NewRangeLte). Queries on them compare the range
+// of the query with the range of the document, according to the `relation` parameter of the `range` query.
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/range.html
+
+const (
+	rangeRelationIntersects = "intersects"
+	rangeRelationContains   = "contains"
+	rangeRelationWithin     = "within"
+)
+
+// rangeFieldBoundType returns the type of bounds of the field, if it's a range field
+func (cw *ClickhouseQueryTranslator) rangeFieldBoundType(fieldName string) (schema.QuesmaType, bool) {
+	field, ok := cw.Schema.ResolveField(fieldName)
+	if !ok {
+		return schema.QuesmaType{}, false
+	}
+	return field.Type.RangeBoundType()
+}
+
+// rangeFieldQuery returns the condition of the `range` query on a range field.
+// bounds are values of the query, keyed by their operators (gte, gt, lte, lt).
+func (cw *ClickhouseQueryTranslator) rangeFieldQuery(fieldName string, boundType schema.QuesmaType, bounds map[string]model.Expr, relation string) model.SimpleQuery {
+	asBound := func(expr model.Expr) model.Expr {
+		if boundType.Name == schema.QuesmaTypeIp.Name {
+			return model.NewFunction("toIPv6", expr)
+		}
+		return expr
+	}
+	fieldGte, fieldLte := model.NewRangeGte(fieldName), model.NewRangeLte(fieldName)
+	isUnbounded := func(fieldBound model.Expr) model.Expr {
+		return model.NewInfixExpr(fieldBound, "IS", model.NewLiteral("NULL"))
+	}
+	// unboundedOr is true, if the field is unbounded on that side, or its bound compares to the query bound
+	unboundedOr := func(fieldBound model.Expr, op string, queryBound model.Expr) model.Expr {
+		return model.Or([]model.Expr{isUnbounded(fieldBound), model.NewInfixExpr(asBound(fieldBound), op, asBound(queryBound))})
+	}
+
+	lower, lowerOp, hasLower := bounds["gte"], ">=", true
+	if gt, ok := bounds["gt"]; ok {
+		lower, lowerOp = gt, ">"
+	} else if lower == nil {
+		hasLower = false
+	}
+	upper, upperOp, hasUpper := bounds["lte"], "<=", true
+	if lt, ok := bounds["lt"]; ok {
+		upper, upperOp = lt, "<"
+	} else if upper == nil {
+		hasUpper = false
+	}
+
+	// documents without the field have both bounds NULL
+	fieldExists := rangeFieldExists(fieldName)
+
+	stmts := []model.Expr{fieldExists}
+	switch strings.ToLower(relation) {
+	case "", rangeRelationIntersects:
+		if hasLower {
+			stmts = append(stmts, unboundedOr(fieldLte, lowerOp, lower))
+		}
+		if hasUpper {
+			stmts = append(stmts, unboundedOr(fieldGte, upperOp, upper))
+		}
+	case rangeRelationContains:
+		if hasLower {
+			stmts = append(stmts, unboundedOr(fieldGte, "<=", lower))
+		} else {
+			stmts = append(stmts, isUnbounded(fieldGte))
+		}
+		if hasUpper {
+			stmts = append(stmts, unboundedOr(fieldLte, ">=", upper))
+		} else {
+			stmts = append(stmts, isUnbounded(fieldLte))
+		}
+	case rangeRelationWithin:
+		stmts = stmts[:0]
+		if hasLower {
+			stmts = append(stmts, model.NewInfixExpr(asBound(fieldGte), lowerOp, asBound(lower)))
+		}
+		if hasUpper {
+			stmts = append(stmts, model.NewInfixExpr(asBound(fieldLte), upperOp, asBound(upper)))
+		}
+		if len(stmts) == 0 {
+			stmts = append(stmts, fieldExists)
+		}
+	default:
+		logger.WarnWithCtx(cw.Ctx).Msgf("invalid range relation: %s", relation)
+		return model.NewSimpleQueryInvalid()
+	}
+	return model.NewSimpleQuery(model.And(stmts), true)
+}
+
+// rangeFieldTermQuery returns the condition of the `term` query on a range field, which matches ranges containing the value
+func (cw *ClickhouseQueryTranslator) rangeFieldTermQuery(fieldName string, boundType schema.QuesmaType, value model.Expr) model.SimpleQuery {
+	return cw.rangeFieldQuery(fieldName, boundType, map[string]model.Expr{"gte": value, "lte": value}, rangeRelationIntersects)
+}
+
+// rangeFieldExists returns the condition of the `exists` query on a range field
+func rangeFieldExists(fieldName string) model.Expr {
+	return model.Or([]model.Expr{
+		model.NewInfixExpr(model.NewRangeGte(fieldName), "IS", model.NewLiteral("NOT NULL")),
+		model.NewInfixExpr(model.NewRangeLte(fieldName), "IS", model.NewLiteral("NOT NULL")),
+	})
+}
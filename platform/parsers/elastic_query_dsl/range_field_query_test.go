@@ -0,0 +1,85 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package elastic_query_dsl
+
+import (
+	"context"
+	"github.com/QuesmaOrg/quesma/platform/database_common"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/schema"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRangeFieldQueries(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		expectedWhere string
+	}{
+		{
+			name:          "range query intersecting by default",
+			query:         `{"range": {"ages": {"gte": 10, "lt": 20}}}`,
+			expectedWhere: `(((__quesma_range_gte("ages") IS NOT NULL OR __quesma_range_lte("ages") IS NOT NULL) AND (__quesma_range_lte("ages") IS NULL OR __quesma_range_lte("ages")>=10)) AND (__quesma_range_gte("ages") IS NULL OR __quesma_range_gte("ages")<20))`,
+		},
+		{
+			name:          "range query containing the query range",
+			query:         `{"range": {"ages": {"gte": 10, "relation": "contains"}}}`,
+			expectedWhere: `(((__quesma_range_gte("ages") IS NOT NULL OR __quesma_range_lte("ages") IS NOT NULL) AND (__quesma_range_gte("ages") IS NULL OR __quesma_range_gte("ages")<=10)) AND __quesma_range_lte("ages") IS NULL)`,
+		},
+		{
+			name:          "range query within the query range",
+			query:         `{"range": {"ages": {"gt": 10, "lte": 20, "relation": "within"}}}`,
+			expectedWhere: `(__quesma_range_gte("ages")>10 AND __quesma_range_lte("ages")<=20)`,
+		},
+		{
+			name:          "range query of date range",
+			query:         `{"range": {"validity": {"lte": "2024-01-01T00:00:00Z", "relation": "WITHIN"}}}`,
+			expectedWhere: `__quesma_range_lte("validity")<=__quesma_from_unixtime64mili(1704067200000)`,
+		},
+		{
+			name:          "term query of ip range",
+			query:         `{"term": {"subnet": "10.0.0.1"}}`,
+			expectedWhere: `(((__quesma_range_gte("subnet") IS NOT NULL OR __quesma_range_lte("subnet") IS NOT NULL) AND (__quesma_range_lte("subnet") IS NULL OR toIPv6(__quesma_range_lte("subnet"))>=toIPv6('10.0.0.1'))) AND (__quesma_range_gte("subnet") IS NULL OR toIPv6(__quesma_range_gte("subnet"))<=toIPv6('10.0.0.1')))`,
+		},
+		{
+			name:          "exists query of range",
+			query:         `{"exists": {"field": "ages"}}`,
+			expectedWhere: `(__quesma_range_gte("ages") IS NOT NULL OR __quesma_range_lte("ages") IS NOT NULL)`,
+		},
+		{
+			name:          "range query of a regular field",
+			query:         `{"range": {"bytes": {"gte": 10}}}`,
+			expectedWhere: `"bytes">=10`,
+		},
+	}
+
+	rangeTestSchema := schema.Schema{Fields: map[schema.FieldName]schema.Field{
+		"ages":     {PropertyName: "ages", InternalPropertyName: "ages", Type: schema.QuesmaTypeIntegerRange},
+		"validity": {PropertyName: "validity", InternalPropertyName: "validity", Type: schema.QuesmaTypeDateRange},
+		"subnet":   {PropertyName: "subnet", InternalPropertyName: "subnet", Type: schema.QuesmaTypeIpRange},
+		"bytes":    {PropertyName: "bytes", InternalPropertyName: "bytes", Type: schema.QuesmaTypeLong},
+	}}
+	table := database_common.Table{
+		Name: tableName,
+		Cols: map[string]*database_common.Column{
+			"bytes": {Name: "bytes", Type: database_common.NewBaseType("Int64")},
+		},
+		Config: database_common.NewChTableConfigNoAttrs(),
+	}
+
+	for i, tt := range tests {
+		t.Run(util.PrettyTestName(tt.name, i), func(t *testing.T) {
+			cw := ClickhouseQueryTranslator{Table: &table, Ctx: context.Background(), Schema: rangeTestSchema, Indexes: []string{tableName}}
+			query, err := types.ParseJSON(tt.query)
+			require.NoError(t, err)
+
+			simpleQuery := cw.parseQueryMap(query)
+			require.True(t, simpleQuery.CanParse)
+			assert.Equal(t, tt.expectedWhere, model.AsString(simpleQuery.WhereClause))
+		})
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package elastic_query_dsl
+
+import (
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"slices"
+)
+
+// Relevance scoring (`_score`) of hits, enabled per index by IndexConfiguration.EnableRelevanceScoring.
+//
+// Every term searched by a full-text query (match, match_phrase, multi_match) contributes
+// `__quesma_match_score(field, 'term')` to the score of a document. It's lowered by SchemaCheckPass
+// (only then we know which columns are full-text ones) into a BM25-like term frequency formula.
+// Scores of compound queries are combined as described in compound_query_parser.go.
+//
+// Score is computed only when it's needed, so like in Elasticsearch:
+//   - when there's no `sort`, hits are sorted by score,
+//   - when hits are sorted by `_score`, or `track_scores` is true, score is returned in hits, but they're sorted as requested,
+//   - otherwise, score isn't computed at all.
+
+// matchScore returns score of a single term matched against a field, or nil if scoring is disabled
+func (cw *ClickhouseQueryTranslator) matchScore(fieldName, term string) model.Expr {
+	if !cw.RelevanceScoring || fieldName == "_id" {
+		return nil
+	}
+	return model.NewFunction(model.MatchScoreFunction, model.NewColumnRef(fieldName), model.NewLiteral(util.SingleQuote(term)))
+}
+
+// shouldComputeScores returns true <=> we should compute scores of hits and return them in the response
+func (cw *ClickhouseQueryTranslator) shouldComputeScores(simpleQuery *model.SimpleQuery, queryInfo model.HitsCountInfo) bool {
+	if !cw.RelevanceScoring || simpleQuery.Score == nil {
+		return false
+	}
+	return len(simpleQuery.SortFieldNames) == 0 || slices.Contains(simpleQuery.SortFieldNames, model.ScoreFieldName) || queryInfo.TrackScores
+}
+
+// addScoreToHitsQuery selects score of every hit (as model.ScoreColumnName), and sorts by it, if no other sort was requested
+func (cw *ClickhouseQueryTranslator) addScoreToHitsQuery(hitsQuery *model.Query, simpleQuery *model.SimpleQuery) {
+	hitsQuery.SelectCommand.Columns = append(hitsQuery.SelectCommand.Columns, model.NewAliasedExpr(simpleQuery.Score, model.ScoreColumnName))
+	if len(simpleQuery.SortFieldNames) == 0 {
+		hitsQuery.SelectCommand.OrderBy = []model.OrderByExpr{model.NewOrderByExpr(simpleQuery.Score, model.DescOrder)}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package elastic_query_dsl
+
+import (
+	"context"
+	"github.com/QuesmaOrg/quesma/platform/database_common"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/model/typical_queries"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRelevanceScoring(t *testing.T) {
+	tests := []struct {
+		name             string
+		relevanceScoring bool
+		query            string
+		expectedSql      string
+	}{
+		{
+			name:             "scoring disabled",
+			relevanceScoring: false,
+			query:            `{"query": {"match": {"message": "quick fox"}}}`,
+			expectedSql: `SELECT * FROM __quesma_table_name ` +
+				`WHERE ("message" __quesma_match '%quick%' OR "message" __quesma_match '%fox%') LIMIT 10`,
+		},
+		{
+			name:             "no sort: hits sorted by score",
+			relevanceScoring: true,
+			query:            `{"query": {"match": {"message": "quick fox"}}}`,
+			expectedSql: `SELECT *, (__quesma_match_score("message",'quick')+__quesma_match_score("message",'fox')) AS "__quesma_score" ` +
+				`FROM __quesma_table_name ` +
+				`WHERE ("message" __quesma_match '%quick%' OR "message" __quesma_match '%fox%') ` +
+				`ORDER BY (__quesma_match_score("message",'quick')+__quesma_match_score("message",'fox')) DESC LIMIT 10`,
+		},
+		{
+			name:             "sort by field without track_scores: no score",
+			relevanceScoring: true,
+			query:            `{"query": {"match": {"message": "fox"}}, "sort": [{"@timestamp": "desc"}]}`,
+			expectedSql: `SELECT * FROM __quesma_table_name ` +
+				`WHERE "message" __quesma_match '%fox%' ORDER BY "@timestamp" DESC LIMIT 10`,
+		},
+		{
+			name:             "sort by field with track_scores",
+			relevanceScoring: true,
+			query:            `{"query": {"match": {"message": "fox"}}, "sort": [{"@timestamp": "desc"}], "track_scores": true}`,
+			expectedSql: `SELECT *, __quesma_match_score("message",'fox') AS "__quesma_score" FROM __quesma_table_name ` +
+				`WHERE "message" __quesma_match '%fox%' ORDER BY "@timestamp" DESC LIMIT 10`,
+		},
+		{
+			name:             "multi_match best_fields",
+			relevanceScoring: true,
+			query:            `{"query": {"multi_match": {"query": "fox", "fields": ["message", "title"]}}, "sort": [{"_score": "desc"}]}`,
+			expectedSql: `SELECT *, greatest(__quesma_match_score("message",'fox'),__quesma_match_score("title",'fox')) AS "__quesma_score" ` +
+				`FROM __quesma_table_name ` +
+				`WHERE ("message" iLIKE '%fox%' OR "title" iLIKE '%fox%') ` +
+				`ORDER BY greatest(__quesma_match_score("message",'fox'),__quesma_match_score("title",'fox')) DESC LIMIT 10`,
+		},
+	}
+
+	table := database_common.Table{
+		Name: tableName,
+		Cols: map[string]*database_common.Column{
+			"message":    {Name: "message", Type: database_common.NewBaseType("String")},
+			"title":      {Name: "title", Type: database_common.NewBaseType("String")},
+			"@timestamp": {Name: "@timestamp", Type: database_common.NewBaseType("DateTime64")},
+		},
+		Config: database_common.NewChTableConfigNoAttrs(),
+	}
+	for i, tt := range tests {
+		t.Run(util.PrettyTestName(tt.name, i), func(t *testing.T) {
+			cw := ClickhouseQueryTranslator{Table: &table, Ctx: context.Background(), Indexes: []string{tableName}, RelevanceScoring: tt.relevanceScoring}
+			body, err := types.ParseJSON(tt.query)
+			assert.NoError(t, err)
+
+			plan, err := cw.ParseQuery(body)
+			assert.NoError(t, err)
+
+			var hitsQuery *model.Query
+			for _, query := range plan.Queries {
+				if _, isHits := query.Type.(*typical_queries.Hits); isHits {
+					hitsQuery = query
+				}
+			}
+			if assert.NotNil(t, hitsQuery) {
+				assert.Equal(t, tt.expectedSql, model.AsString(hitsQuery.SelectCommand))
+			}
+		})
+	}
+}
+
+func TestRelevanceScoringInResponse(t *testing.T) {
+	table := database_common.Table{
+		Name: tableName,
+		Cols: map[string]*database_common.Column{
+			"message": {Name: "message", Type: database_common.NewBaseType("String")},
+		},
+		Config: database_common.NewChTableConfigNoAttrs(),
+	}
+	cw := ClickhouseQueryTranslator{Table: &table, Ctx: context.Background(), Indexes: []string{tableName}, RelevanceScoring: true}
+	body, err := types.ParseJSON(`{"query": {"match": {"message": "fox"}}, "sort": [{"_score": "desc"}]}`)
+	assert.NoError(t, err)
+	plan, err := cw.ParseQuery(body)
+	assert.NoError(t, err)
+
+	rows := [][]model.QueryResultRow{}
+	for _, query := range plan.Queries {
+		switch query.Type.(type) {
+		case *typical_queries.Hits:
+			rows = append(rows, []model.QueryResultRow{
+				{Cols: []model.QueryResultCol{model.NewQueryResultCol("message", "fox fox"), model.NewQueryResultCol(model.ScoreColumnName, 1.5)}},
+				{Cols: []model.QueryResultCol{model.NewQueryResultCol("message", "fox"), model.NewQueryResultCol(model.ScoreColumnName, 1.0)}},
+			})
+		default:
+			rows = append(rows, []model.QueryResultRow{{Cols: []model.QueryResultCol{model.NewQueryResultCol("count()", uint64(2))}}})
+		}
+	}
+
+	response := cw.MakeSearchResponse(plan.Queries, rows)
+	if assert.NotNil(t, response.Hits.MaxScore) {
+		assert.Equal(t, float32(1.5), *response.Hits.MaxScore)
+	}
+	assert.Len(t, response.Hits.Hits, 2)
+	assert.Equal(t, float32(1.5), response.Hits.Hits[0].Score)
+	assert.Equal(t, []any{float32(1.5)}, response.Hits.Hits[0].Sort)
+	assert.NotContains(t, response.Hits.Hits[0].Fields, model.ScoreColumnName)
+	assert.NotContains(t, string(response.Hits.Hits[0].Source), model.ScoreColumnName)
+}
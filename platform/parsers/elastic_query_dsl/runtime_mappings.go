@@ -3,12 +3,16 @@
 package elastic_query_dsl
 
 import (
+	"github.com/QuesmaOrg/quesma/platform/logger"
 	"github.com/QuesmaOrg/quesma/platform/model"
 	"github.com/QuesmaOrg/quesma/platform/parsers/painful"
+	"github.com/QuesmaOrg/quesma/platform/schema"
 	"github.com/QuesmaOrg/quesma/platform/types"
 )
 
-func ParseRuntimeMappings(body types.JSON) (map[string]model.RuntimeMapping, error) {
+// ParseRuntimeMappings parses `runtime_mappings` and `script_fields` (Kibana's scripted fields) of the request.
+// Both are treated as runtime fields.
+func ParseRuntimeMappings(body types.JSON, indexSchema schema.Schema) (map[string]model.RuntimeMapping, error) {
 
 	result := make(map[string]model.RuntimeMapping)
 
@@ -24,22 +28,8 @@ func ParseRuntimeMappings(body types.JSON) (map[string]model.RuntimeMapping, err
 							mapping.Type = typAsString
 						}
 					}
-					if script, ok := vAsMap["script"]; ok {
-						if scriptAsMap, ok := script.(map[string]interface{}); ok {
-							if source, ok := scriptAsMap["source"]; ok {
-								if sourceAsString, ok := source.(string); ok {
-
-									dbExpr, postProcesExpr, err := ParseScript(sourceAsString)
-
-									if err != nil {
-										return nil, err
-									}
-
-									mapping.DatabaseExpression = dbExpr
-									mapping.PostProcessExpression = postProcesExpr
-								}
-							}
-						}
+					if err := parseRuntimeMappingScript(vAsMap["script"], indexSchema, &mapping); err != nil {
+						return nil, err
 					}
 				}
 				if mapping.DatabaseExpression != nil {
@@ -48,12 +38,58 @@ func ParseRuntimeMappings(body types.JSON) (map[string]model.RuntimeMapping, err
 			}
 		}
 	}
+
+	if scriptFields, ok := body["script_fields"]; ok {
+		if scriptFieldsMap, ok := scriptFields.(map[string]interface{}); ok {
+			for k, v := range scriptFieldsMap {
+				mapping := model.RuntimeMapping{
+					Field: k,
+				}
+				if vAsMap, ok := v.(map[string]interface{}); ok {
+					// scripted fields are only returned with hits, so we don't fail the whole request because of them
+					if err := parseRuntimeMappingScript(vAsMap["script"], indexSchema, &mapping); err != nil {
+						logger.Warn().Msgf("skipping script field '%s': %v", k, err)
+						continue
+					}
+				}
+				if _, exists := result[k]; !exists && mapping.DatabaseExpression != nil {
+					result[k] = mapping
+				}
+			}
+		}
+	}
 	return result, nil
 }
 
-func ParseScript(s string) (model.Expr, painful.Expr, error) {
+func parseRuntimeMappingScript(script any, indexSchema schema.Schema, mapping *model.RuntimeMapping) error {
 
-	// TODO: add a real parser here
+	scriptAsMap, ok := script.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	source, ok := scriptAsMap["source"].(string)
+	if !ok {
+		return nil
+	}
+	if params, ok := scriptAsMap["params"].(map[string]interface{}); ok {
+		mapping.Params = painful.NormalizeParams(params)
+	}
+
+	dbExpr, postProcessExpr, err := ParseScript(source, indexSchema, mapping.Params)
+	if err != nil {
+		return err
+	}
+
+	mapping.DatabaseExpression = dbExpr
+	mapping.PostProcessExpression = postProcessExpr
+	return nil
+}
+
+// ParseScript parses a Painless script and lowers it to an SQL expression. If that's not possible,
+// the script is returned to be evaluated row by row on the results.
+func ParseScript(s string, indexSchema schema.Schema, params map[string]any) (model.Expr, painful.Expr, error) {
+
+	// `timestamp` isn't always a field of the index, it stands for the timestamp field here
 	if s == "emit(doc['timestamp'].value.getHour());" {
 		return model.NewFunction(model.DateHourFunction, model.NewColumnRef(model.TimestampFieldName)), nil, nil
 	}
@@ -63,7 +99,11 @@ func ParseScript(s string) (model.Expr, painful.Expr, error) {
 		return nil, nil, err
 	}
 
-	// TODO here we can transform the parsed expression to an SQL
+	sqlExpr, err := LowerPainless(expr, indexSchema, params)
+	if err == nil {
+		return sqlExpr, nil, nil
+	}
+	logger.Debug().Msgf("script '%s' will be evaluated in Quesma: %v", s, err)
 
 	// we return an empty SQL expression for given field, it'll make a column in the result set
 	return model.NewLiteral("NULL"), expr, nil
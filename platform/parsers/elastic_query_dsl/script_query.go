@@ -0,0 +1,123 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package elastic_query_dsl
+
+import (
+	"errors"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/end_user_errors"
+	"github.com/QuesmaOrg/quesma/platform/logger"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/parsers/painful"
+	"strings"
+)
+
+// Scripts of `script` queries and `_script` sorts are lowered to SQL (see LowerPainless), so they're evaluated
+// by the database. Unlike runtime fields, we can't evaluate them in Go after the query, as they decide
+// which rows are returned. Scripts, which can't be lowered, fail the whole query with an end user error.
+
+const scriptSortFieldName = "_script"
+
+// parseScript parses `script` query, e.g. {"script": {"script": "doc['bytes'].value > doc['limit'].value"}}
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/query-dsl-script-query.html
+func (cw *ClickhouseQueryTranslator) parseScript(queryMap QueryMap) model.SimpleQuery {
+	script, ok := queryMap["script"]
+	if !ok {
+		logger.WarnWithCtx(cw.Ctx).Msgf("no script in script query: %v", queryMap)
+		return model.NewSimpleQueryInvalid()
+	}
+
+	source, params, err := cw.parseScriptDefinition(script)
+	if err != nil {
+		return cw.scriptError(err)
+	}
+
+	whereClause, err := LowerPainlessCondition(source, cw.Schema, params)
+	if err != nil {
+		return cw.scriptError(err)
+	}
+	return model.NewSimpleQuery(whereClause, true)
+}
+
+// parseScriptSort parses value of `_script` sort, e.g. {"type": "number", "script": {"source": "..."}, "order": "desc"}
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/sort-search-results.html#script-based-sorting
+func (cw *ClickhouseQueryTranslator) parseScriptSort(value any) (model.OrderByExpr, bool) {
+	sortMap, ok := value.(QueryMap)
+	if !ok {
+		logger.WarnWithCtx(cw.Ctx).Msgf("unexpected type of %s sort: %T, value: %v", scriptSortFieldName, value, value)
+		return model.OrderByExpr{}, false
+	}
+
+	direction := model.AscOrder
+	if order, ok := sortMap["order"].(string); ok {
+		switch strings.ToLower(order) {
+		case "asc":
+		case "desc":
+			direction = model.DescOrder
+		default:
+			logger.WarnWithCtx(cw.Ctx).Msgf("unexpected order value: [%s] for %s sort. Skipping", order, scriptSortFieldName)
+			return model.OrderByExpr{}, false
+		}
+	}
+
+	sortType, _ := sortMap["type"].(string)
+
+	source, params, err := cw.parseScriptDefinition(sortMap["script"])
+	if err != nil {
+		cw.scriptError(err)
+		return model.OrderByExpr{}, false
+	}
+
+	sortKey, err := LowerPainlessSortKey(source, cw.Schema, params, sortType)
+	if err != nil {
+		cw.scriptError(err)
+		return model.OrderByExpr{}, false
+	}
+	return model.NewOrderByExpr(sortKey, direction), true
+}
+
+// parseScriptDefinition parses a script given either as a string with its source,
+// or as an object, e.g. {"source": "...", "params": {...}, "lang": "painless"}.
+func (cw *ClickhouseQueryTranslator) parseScriptDefinition(script any) (source painful.Expr, params map[string]any, err error) {
+	var sourceAsString string
+	switch script := script.(type) {
+	case string:
+		sourceAsString = script
+	case QueryMap:
+		if lang, ok := script["lang"].(string); ok && lang != "painless" {
+			return nil, nil, fmt.Errorf("unsupported script language: [%s]", lang)
+		}
+		if _, ok := script["id"]; ok {
+			return nil, nil, fmt.Errorf("stored scripts are not supported")
+		}
+		var ok bool
+		if sourceAsString, ok = script["source"].(string); !ok {
+			return nil, nil, fmt.Errorf("invalid script source type: %T, value: %v", script["source"], script["source"])
+		}
+		if paramsAsMap, ok := script["params"].(QueryMap); ok {
+			params = painful.NormalizeParams(paramsAsMap)
+		}
+	default:
+		return nil, nil, fmt.Errorf("invalid script type: %T, value: %v", script, script)
+	}
+
+	source, err = painful.ParsePainless(sourceAsString)
+	if err != nil {
+		return nil, nil, fmt.Errorf("compile error in script [%s]: %w", sourceAsString, err)
+	}
+	return source, params, nil
+}
+
+// scriptError remembers the first script error, which will be returned to the user instead of the query results
+func (cw *ClickhouseQueryTranslator) scriptError(err error) model.SimpleQuery {
+	logger.WarnWithCtx(cw.Ctx).Msgf("can't translate script: %v", err)
+	if cw.endUserError == nil {
+		errorType := end_user_errors.ErrUnsupportedScript
+		var scriptErr *painful.ScriptError
+		if errors.As(err, &scriptErr) && scriptErr.Compile {
+			errorType = end_user_errors.ErrQuerySyntax
+		}
+		cw.endUserError = errorType.New(err).Details(" %v", err)
+	}
+	return model.NewSimpleQueryInvalid()
+}
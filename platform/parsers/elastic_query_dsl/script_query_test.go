@@ -0,0 +1,118 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package elastic_query_dsl
+
+import (
+	"context"
+	"github.com/QuesmaOrg/quesma/platform/database_common"
+	"github.com/QuesmaOrg/quesma/platform/end_user_errors"
+	"github.com/QuesmaOrg/quesma/platform/model"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/QuesmaOrg/quesma/platform/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestScriptQueryAndSort(t *testing.T) {
+	tests := []struct {
+		name            string
+		request         string
+		expectedWhere   string
+		expectedOrderBy string
+		expectedError   *end_user_errors.ErrorType // nil <=> no error expected
+	}{
+		{
+			name:          "script query with source only",
+			request:       `{"query": {"script": {"script": "doc['bytes'].value > doc['price'].value"}}}`,
+			expectedWhere: `("bytes">"price")`,
+		},
+		{
+			name: "script query with params in bool filter",
+			request: `{"query": {"bool": {"filter": [{"script": {"script": {
+				"source": "doc['host'].value.startsWith(params.prefix) && doc['bytes'].value * 8 >= params.bits",
+				"params": {"prefix": "web-", "bits": 8000},
+				"lang": "painless"}}}]}}}`,
+			expectedWhere: `(startsWith("host",'web-') AND (("bytes"*8)>=8000))`,
+		},
+		{
+			name: "script sort",
+			request: `{"sort": [
+				{"_script": {"type": "number", "script": {"source": "doc['bytes'].value * params.factor", "params": {"factor": 2}}, "order": "desc"}},
+				{"@timestamp": "asc"}]}`,
+			expectedOrderBy: `("bytes"*2) DESC, "@timestamp" ASC`,
+		},
+		{
+			name:            "script sort of string type",
+			request:         `{"sort": {"_script": {"type": "string", "script": "doc['bytes'].value"}}}`,
+			expectedOrderBy: `toString("bytes") ASC`,
+		},
+		{
+			name:          "script query not returning a boolean",
+			request:       `{"query": {"script": {"script": "doc['bytes'].value + 1"}}}`,
+			expectedError: end_user_errors.ErrUnsupportedScript,
+		},
+		{
+			name:          "script query which can't be lowered",
+			request:       `{"query": {"script": {"script": "def x = doc['bytes'].value; return x > 0;"}}}`,
+			expectedError: end_user_errors.ErrUnsupportedScript,
+		},
+		{
+			name:          "script query with syntax error",
+			request:       `{"query": {"script": {"script": "doc['bytes'].value >"}}}`,
+			expectedError: end_user_errors.ErrQuerySyntax,
+		},
+		{
+			name:          "stored script",
+			request:       `{"query": {"script": {"script": {"id": "my-script"}}}}`,
+			expectedError: end_user_errors.ErrUnsupportedScript,
+		},
+		{
+			name:          "number script sort returning a string",
+			request:       `{"sort": [{"_script": {"type": "number", "script": "doc['host'].value"}}]}`,
+			expectedError: end_user_errors.ErrUnsupportedScript,
+		},
+	}
+
+	table := database_common.Table{
+		Name: tableName,
+		Cols: map[string]*database_common.Column{
+			"@timestamp": {Name: "@timestamp", Type: database_common.NewBaseType("DateTime64")},
+			"host":       {Name: "host", Type: database_common.NewBaseType("String")},
+			"bytes":      {Name: "bytes", Type: database_common.NewBaseType("Int64")},
+			"price":      {Name: "price", Type: database_common.NewBaseType("Float64")},
+		},
+		Config: database_common.NewChTableConfigNoAttrs(),
+	}
+
+	for i, tt := range tests {
+		t.Run(util.PrettyTestName(tt.name, i), func(t *testing.T) {
+			cw := ClickhouseQueryTranslator{Table: &table, Ctx: context.Background(), Schema: painlessTestSchema, Indexes: []string{tableName}}
+			body, err := types.ParseJSON(tt.request)
+			require.NoError(t, err)
+
+			plan, err := cw.ParseQuery(body)
+			if tt.expectedError != nil {
+				var endUserError *end_user_errors.EndUserError
+				require.ErrorAs(t, err, &endUserError)
+				assert.Equal(t, tt.expectedError, endUserError.ErrorType())
+				return
+			}
+			require.NoError(t, err)
+			require.NotEmpty(t, plan.Queries)
+
+			selectCommand := plan.Queries[len(plan.Queries)-1].SelectCommand // list query, after the count query
+			if tt.expectedWhere != "" {
+				assert.Equal(t, tt.expectedWhere, model.AsString(selectCommand.WhereClause))
+			}
+			if tt.expectedOrderBy != "" {
+				orderBy := make([]string, 0, len(selectCommand.OrderBy))
+				for _, expr := range selectCommand.OrderBy {
+					orderBy = append(orderBy, model.AsString(expr))
+				}
+				assert.Equal(t, tt.expectedOrderBy, strings.Join(orderBy, ", "))
+			}
+		})
+	}
+}
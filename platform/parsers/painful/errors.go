@@ -0,0 +1,111 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package painful
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Position is a position in the script source
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+func newPosition(pos position) Position {
+	return Position{Line: pos.line, Column: pos.col, Offset: pos.offset}
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d [%d]", p.Line, p.Column, p.Offset)
+}
+
+// Java exceptions reported by Elasticsearch as `caused_by` of script errors
+const (
+	illegalArgumentException  = "illegal_argument_exception"
+	classCastException        = "class_cast_exception"
+	arithmeticException       = "arithmetic_exception"
+	nullPointerException      = "null_pointer_exception"
+	indexOutOfBoundsException = "index_out_of_bounds_exception"
+)
+
+// ScriptError is an error of compilation or execution of a script, at the given position of the script
+type ScriptError struct {
+	Position  Position
+	Exception string
+	Reason    string
+	Compile   bool
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Position, e.Reason)
+}
+
+func errorAt(position Position, format string, args ...any) error {
+	return exceptionAt(position, illegalArgumentException, format, args...)
+}
+
+func exceptionAt(position Position, exception string, format string, args ...any) error {
+	return &ScriptError{Position: position, Exception: exception, Reason: fmt.Sprintf(format, args...)}
+}
+
+// asScriptError converts errors of the generated parser, which have their position, to ScriptError
+func asScriptError(err error) *ScriptError {
+
+	var scriptErr *ScriptError
+	if errors.As(err, &scriptErr) {
+		return scriptErr
+	}
+
+	var list errList
+	if errors.As(err, &list) && len(list) > 0 {
+		err = list[0]
+	}
+
+	var parserErr *parserError
+	if errors.As(err, &parserErr) {
+		if errors.As(parserErr.Inner, &scriptErr) {
+			scriptErr.Compile = true // reported by actions of the grammar
+			return scriptErr
+		}
+		return &ScriptError{Position: newPosition(parserErr.pos), Exception: illegalArgumentException, Reason: parserErr.Inner.Error(), Compile: true}
+	}
+
+	return &ScriptError{Exception: illegalArgumentException, Reason: err.Error()}
+}
+
+// javaTypeName names the type of the value like Painless does in its errors
+func javaTypeName(val any) string {
+	switch val.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case int, int32:
+		return "int"
+	case int8:
+		return "byte"
+	case int16:
+		return "short"
+	case int64, uint, uint8, uint16, uint32, uint64:
+		return "long"
+	case float32:
+		return "float"
+	case float64:
+		return "double"
+	case string:
+		return "String"
+	case time.Time:
+		return "ZonedDateTime"
+	case *List, []any, []float64:
+		return "List"
+	default:
+		if _, ok := asMap(val); ok {
+			return "Map"
+		}
+		return fmt.Sprintf("%T", val)
+	}
+}
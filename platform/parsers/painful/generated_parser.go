@@ -22,118 +22,226 @@ import (
 var g = &grammar{
 	rules: []*rule{
 		{
-			name: "Expr",
-			pos:  position{line: 8, col: 1, offset: 123},
-			expr: &choiceExpr{
-				pos: position{line: 8, col: 9, offset: 131},
-				alternatives: []any{
-					&labeledExpr{
-						pos:   position{line: 8, col: 9, offset: 131},
-						label: "expr",
-						expr: &ruleRefExpr{
-							pos:  position{line: 8, col: 14, offset: 136},
-							name: "OpExpr",
+			name: "Script",
+			pos:  position{line: 7, col: 1, offset: 122},
+			expr: &actionExpr{
+				pos: position{line: 7, col: 10, offset: 131},
+				run: (*parser).callonScript1,
+				expr: &seqExpr{
+					pos: position{line: 7, col: 10, offset: 131},
+					exprs: []any{
+						&labeledExpr{
+							pos:   position{line: 7, col: 10, offset: 131},
+							label: "stmts",
+							expr: &ruleRefExpr{
+								pos:  position{line: 7, col: 16, offset: 137},
+								name: "Statements",
+							},
 						},
-					},
-					&labeledExpr{
-						pos:   position{line: 8, col: 23, offset: 145},
-						label: "expr",
-						expr: &ruleRefExpr{
-							pos:  position{line: 8, col: 28, offset: 150},
-							name: "MethodCall",
+						&ruleRefExpr{
+							pos:  position{line: 7, col: 27, offset: 148},
+							name: "_",
 						},
-					},
-					&labeledExpr{
-						pos:   position{line: 8, col: 41, offset: 163},
-						label: "expr",
-						expr: &ruleRefExpr{
-							pos:  position{line: 8, col: 46, offset: 168},
-							name: "Accessor",
+						&ruleRefExpr{
+							pos:  position{line: 7, col: 29, offset: 150},
+							name: "EOF",
 						},
 					},
-					&labeledExpr{
-						pos:   position{line: 8, col: 57, offset: 179},
-						label: "expr",
-						expr: &ruleRefExpr{
-							pos:  position{line: 8, col: 62, offset: 184},
-							name: "Doc",
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Statements",
+			pos:  position{line: 11, col: 1, offset: 187},
+			expr: &actionExpr{
+				pos: position{line: 11, col: 14, offset: 200},
+				run: (*parser).callonStatements1,
+				expr: &labeledExpr{
+					pos:   position{line: 11, col: 14, offset: 200},
+					label: "stmts",
+					expr: &zeroOrMoreExpr{
+						pos: position{line: 11, col: 20, offset: 206},
+						expr: &seqExpr{
+							pos: position{line: 11, col: 21, offset: 207},
+							exprs: []any{
+								&ruleRefExpr{
+									pos:  position{line: 11, col: 21, offset: 207},
+									name: "_",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 11, col: 23, offset: 209},
+									name: "Statement",
+								},
+							},
 						},
 					},
-					&labeledExpr{
-						pos:   position{line: 8, col: 68, offset: 190},
-						label: "expr",
-						expr: &ruleRefExpr{
-							pos:  position{line: 8, col: 73, offset: 195},
-							name: "Emit",
-						},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Statement",
+			pos:  position{line: 15, col: 1, offset: 248},
+			expr: &choiceExpr{
+				pos: position{line: 15, col: 13, offset: 260},
+				alternatives: []any{
+					&ruleRefExpr{
+						pos:  position{line: 15, col: 13, offset: 260},
+						name: "Block",
 					},
-					&labeledExpr{
-						pos:   position{line: 8, col: 80, offset: 202},
-						label: "expr",
-						expr: &ruleRefExpr{
-							pos:  position{line: 8, col: 85, offset: 207},
-							name: "String",
+					&ruleRefExpr{
+						pos:  position{line: 15, col: 21, offset: 268},
+						name: "If",
+					},
+					&ruleRefExpr{
+						pos:  position{line: 15, col: 26, offset: 273},
+						name: "For",
+					},
+					&ruleRefExpr{
+						pos:  position{line: 15, col: 32, offset: 279},
+						name: "ForEach",
+					},
+					&ruleRefExpr{
+						pos:  position{line: 15, col: 42, offset: 289},
+						name: "While",
+					},
+					&ruleRefExpr{
+						pos:  position{line: 15, col: 50, offset: 297},
+						name: "Return",
+					},
+					&ruleRefExpr{
+						pos:  position{line: 15, col: 59, offset: 306},
+						name: "Break",
+					},
+					&ruleRefExpr{
+						pos:  position{line: 15, col: 67, offset: 314},
+						name: "Continue",
+					},
+					&actionExpr{
+						pos: position{line: 15, col: 78, offset: 325},
+						run: (*parser).callonStatement10,
+						expr: &seqExpr{
+							pos: position{line: 15, col: 78, offset: 325},
+							exprs: []any{
+								&labeledExpr{
+									pos:   position{line: 15, col: 78, offset: 325},
+									label: "stmt",
+									expr: &ruleRefExpr{
+										pos:  position{line: 15, col: 83, offset: 330},
+										name: "Declaration",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 15, col: 95, offset: 342},
+									name: "End",
+								},
+							},
 						},
 					},
 					&actionExpr{
-						pos: position{line: 8, col: 94, offset: 216},
-						run: (*parser).callonExpr14,
-						expr: &labeledExpr{
-							pos:   position{line: 8, col: 94, offset: 216},
-							label: "expr",
-							expr: &ruleRefExpr{
-								pos:  position{line: 8, col: 99, offset: 221},
-								name: "UrlEncoder",
+						pos: position{line: 17, col: 5, offset: 373},
+						run: (*parser).callonStatement15,
+						expr: &seqExpr{
+							pos: position{line: 17, col: 5, offset: 373},
+							exprs: []any{
+								&labeledExpr{
+									pos:   position{line: 17, col: 5, offset: 373},
+									label: "stmt",
+									expr: &ruleRefExpr{
+										pos:  position{line: 17, col: 10, offset: 378},
+										name: "Assignment",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 17, col: 21, offset: 389},
+									name: "End",
+								},
 							},
 						},
 					},
+					&ruleRefExpr{
+						pos:  position{line: 19, col: 5, offset: 420},
+						name: "ExprStatement",
+					},
 				},
 			},
-			leader:        true,
-			leftRecursive: true,
+			leader:        false,
+			leftRecursive: false,
 		},
 		{
-			name: "Emit",
-			pos:  position{line: 12, col: 1, offset: 258},
+			name: "End",
+			pos:  position{line: 22, col: 1, offset: 510},
+			expr: &seqExpr{
+				pos: position{line: 22, col: 7, offset: 516},
+				exprs: []any{
+					&ruleRefExpr{
+						pos:  position{line: 22, col: 7, offset: 516},
+						name: "_",
+					},
+					&choiceExpr{
+						pos: position{line: 22, col: 10, offset: 519},
+						alternatives: []any{
+							&litMatcher{
+								pos:        position{line: 22, col: 10, offset: 519},
+								val:        ";",
+								ignoreCase: false,
+								want:       "\";\"",
+							},
+							&andExpr{
+								pos: position{line: 22, col: 16, offset: 525},
+								expr: &litMatcher{
+									pos:        position{line: 22, col: 17, offset: 526},
+									val:        "}",
+									ignoreCase: false,
+									want:       "\"}\"",
+								},
+							},
+							&ruleRefExpr{
+								pos:  position{line: 22, col: 23, offset: 532},
+								name: "EOF",
+							},
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Block",
+			pos:  position{line: 24, col: 1, offset: 538},
 			expr: &actionExpr{
-				pos: position{line: 12, col: 8, offset: 265},
-				run: (*parser).callonEmit1,
+				pos: position{line: 24, col: 9, offset: 546},
+				run: (*parser).callonBlock1,
 				expr: &seqExpr{
-					pos: position{line: 12, col: 8, offset: 265},
+					pos: position{line: 24, col: 9, offset: 546},
 					exprs: []any{
 						&litMatcher{
-							pos:        position{line: 12, col: 8, offset: 265},
-							val:        "emit",
-							ignoreCase: false,
-							want:       "\"emit\"",
-						},
-						&litMatcher{
-							pos:        position{line: 12, col: 15, offset: 272},
-							val:        "(",
+							pos:        position{line: 24, col: 9, offset: 546},
+							val:        "{",
 							ignoreCase: false,
-							want:       "\"(\"",
-						},
-						&ruleRefExpr{
-							pos:  position{line: 12, col: 19, offset: 276},
-							name: "_",
+							want:       "\"{\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 12, col: 21, offset: 278},
-							label: "expr",
+							pos:   position{line: 24, col: 13, offset: 550},
+							label: "stmts",
 							expr: &ruleRefExpr{
-								pos:  position{line: 12, col: 26, offset: 283},
-								name: "Expr",
+								pos:  position{line: 24, col: 19, offset: 556},
+								name: "Statements",
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 12, col: 31, offset: 288},
+							pos:  position{line: 24, col: 30, offset: 567},
 							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 12, col: 33, offset: 290},
-							val:        ")",
+							pos:        position{line: 24, col: 32, offset: 569},
+							val:        "}",
 							ignoreCase: false,
-							want:       "\")\"",
+							want:       "\"}\"",
 						},
 					},
 				},
@@ -142,253 +250,227 @@ var g = &grammar{
 			leftRecursive: false,
 		},
 		{
-			name: "Doc",
-			pos:  position{line: 22, col: 1, offset: 429},
+			name: "If",
+			pos:  position{line: 34, col: 1, offset: 729},
 			expr: &actionExpr{
-				pos: position{line: 22, col: 7, offset: 435},
-				run: (*parser).callonDoc1,
+				pos: position{line: 34, col: 6, offset: 734},
+				run: (*parser).callonIf1,
 				expr: &seqExpr{
-					pos: position{line: 22, col: 7, offset: 435},
+					pos: position{line: 34, col: 6, offset: 734},
 					exprs: []any{
 						&litMatcher{
-							pos:        position{line: 22, col: 7, offset: 435},
-							val:        "doc",
+							pos:        position{line: 34, col: 6, offset: 734},
+							val:        "if",
 							ignoreCase: false,
-							want:       "\"doc\"",
+							want:       "\"if\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 34, col: 11, offset: 739},
+							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 22, col: 13, offset: 441},
-							val:        "[",
+							pos:        position{line: 34, col: 13, offset: 741},
+							val:        "(",
 							ignoreCase: false,
-							want:       "\"[\"",
+							want:       "\"(\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 34, col: 17, offset: 745},
+							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 22, col: 17, offset: 445},
-							label: "key",
+							pos:   position{line: 34, col: 19, offset: 747},
+							label: "cond",
 							expr: &ruleRefExpr{
-								pos:  position{line: 22, col: 21, offset: 449},
+								pos:  position{line: 34, col: 24, offset: 752},
 								name: "Expr",
 							},
 						},
+						&ruleRefExpr{
+							pos:  position{line: 34, col: 29, offset: 757},
+							name: "_",
+						},
 						&litMatcher{
-							pos:        position{line: 22, col: 27, offset: 455},
-							val:        "]",
+							pos:        position{line: 34, col: 31, offset: 759},
+							val:        ")",
 							ignoreCase: false,
-							want:       "\"]\"",
+							want:       "\")\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 34, col: 35, offset: 763},
+							name: "_",
 						},
-					},
-				},
-			},
-			leader:        false,
-			leftRecursive: false,
-		},
-		{
-			name: "Accessor",
-			pos:  position{line: 32, col: 1, offset: 597},
-			expr: &actionExpr{
-				pos: position{line: 32, col: 12, offset: 608},
-				run: (*parser).callonAccessor1,
-				expr: &seqExpr{
-					pos: position{line: 32, col: 12, offset: 608},
-					exprs: []any{
 						&labeledExpr{
-							pos:   position{line: 32, col: 12, offset: 608},
-							label: "expr",
+							pos:   position{line: 34, col: 37, offset: 765},
+							label: "then",
 							expr: &ruleRefExpr{
-								pos:  position{line: 32, col: 17, offset: 613},
-								name: "Expr",
+								pos:  position{line: 34, col: 42, offset: 770},
+								name: "Statement",
 							},
 						},
-						&litMatcher{
-							pos:        position{line: 32, col: 22, offset: 618},
-							val:        ".",
-							ignoreCase: false,
-							want:       "\".\"",
-						},
 						&labeledExpr{
-							pos:   position{line: 32, col: 26, offset: 622},
-							label: "field",
-							expr: &ruleRefExpr{
-								pos:  position{line: 32, col: 32, offset: 628},
-								name: "Identifier",
+							pos:   position{line: 34, col: 52, offset: 780},
+							label: "els",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 34, col: 56, offset: 784},
+								expr: &seqExpr{
+									pos: position{line: 34, col: 57, offset: 785},
+									exprs: []any{
+										&ruleRefExpr{
+											pos:  position{line: 34, col: 57, offset: 785},
+											name: "_",
+										},
+										&litMatcher{
+											pos:        position{line: 34, col: 59, offset: 787},
+											val:        "else",
+											ignoreCase: false,
+											want:       "\"else\"",
+										},
+										&notExpr{
+											pos: position{line: 34, col: 66, offset: 794},
+											expr: &ruleRefExpr{
+												pos:  position{line: 34, col: 67, offset: 795},
+												name: "IdentifierChar",
+											},
+										},
+										&ruleRefExpr{
+											pos:  position{line: 34, col: 82, offset: 810},
+											name: "_",
+										},
+										&ruleRefExpr{
+											pos:  position{line: 34, col: 84, offset: 812},
+											name: "Statement",
+										},
+									},
+								},
 							},
 						},
 					},
 				},
 			},
 			leader:        false,
-			leftRecursive: true,
+			leftRecursive: false,
 		},
 		{
-			name: "MethodCall",
-			pos:  position{line: 47, col: 1, offset: 914},
+			name: "For",
+			pos:  position{line: 56, col: 1, offset: 1278},
 			expr: &actionExpr{
-				pos: position{line: 47, col: 14, offset: 927},
-				run: (*parser).callonMethodCall1,
+				pos: position{line: 56, col: 7, offset: 1284},
+				run: (*parser).callonFor1,
 				expr: &seqExpr{
-					pos: position{line: 47, col: 14, offset: 927},
+					pos: position{line: 56, col: 7, offset: 1284},
 					exprs: []any{
-						&labeledExpr{
-							pos:   position{line: 47, col: 14, offset: 927},
-							label: "expr",
-							expr: &ruleRefExpr{
-								pos:  position{line: 47, col: 19, offset: 932},
-								name: "Expr",
-							},
-						},
 						&litMatcher{
-							pos:        position{line: 47, col: 24, offset: 937},
-							val:        ".",
+							pos:        position{line: 56, col: 7, offset: 1284},
+							val:        "for",
 							ignoreCase: false,
-							want:       "\".\"",
+							want:       "\"for\"",
 						},
-						&labeledExpr{
-							pos:   position{line: 47, col: 28, offset: 941},
-							label: "method",
-							expr: &ruleRefExpr{
-								pos:  position{line: 47, col: 35, offset: 948},
-								name: "Identifier",
-							},
+						&ruleRefExpr{
+							pos:  position{line: 56, col: 13, offset: 1290},
+							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 47, col: 46, offset: 959},
+							pos:        position{line: 56, col: 15, offset: 1292},
 							val:        "(",
 							ignoreCase: false,
 							want:       "\"(\"",
 						},
+						&ruleRefExpr{
+							pos:  position{line: 56, col: 19, offset: 1296},
+							name: "_",
+						},
 						&labeledExpr{
-							pos:   position{line: 47, col: 50, offset: 963},
-							label: "args",
-							expr: &zeroOrMoreExpr{
-								pos: position{line: 47, col: 55, offset: 968},
-								expr: &ruleRefExpr{
-									pos:  position{line: 47, col: 55, offset: 968},
-									name: "Expr",
+							pos:   position{line: 56, col: 21, offset: 1298},
+							label: "init",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 56, col: 26, offset: 1303},
+								expr: &choiceExpr{
+									pos: position{line: 56, col: 27, offset: 1304},
+									alternatives: []any{
+										&ruleRefExpr{
+											pos:  position{line: 56, col: 27, offset: 1304},
+											name: "Declaration",
+										},
+										&ruleRefExpr{
+											pos:  position{line: 56, col: 41, offset: 1318},
+											name: "Assignment",
+										},
+									},
 								},
 							},
 						},
-						&zeroOrOneExpr{
-							pos: position{line: 47, col: 61, offset: 974},
-							expr: &litMatcher{
-								pos:        position{line: 47, col: 61, offset: 974},
-								val:        ",",
-								ignoreCase: false,
-								want:       "\",\"",
-							},
+						&ruleRefExpr{
+							pos:  position{line: 56, col: 54, offset: 1331},
+							name: "_",
 						},
 						&litMatcher{
-							pos:        position{line: 47, col: 67, offset: 980},
-							val:        ")",
+							pos:        position{line: 56, col: 56, offset: 1333},
+							val:        ";",
 							ignoreCase: false,
-							want:       "\")\"",
-						},
-					},
-				},
-			},
-			leader:        false,
-			leftRecursive: true,
-		},
-		{
-			name: "OpExpr",
-			pos:  position{line: 91, col: 1, offset: 1910},
-			expr: &actionExpr{
-				pos: position{line: 91, col: 10, offset: 1919},
-				run: (*parser).callonOpExpr1,
-				expr: &seqExpr{
-					pos: position{line: 91, col: 10, offset: 1919},
-					exprs: []any{
-						&labeledExpr{
-							pos:   position{line: 91, col: 10, offset: 1919},
-							label: "left",
-							expr: &ruleRefExpr{
-								pos:  position{line: 91, col: 15, offset: 1924},
-								name: "Expr",
-							},
+							want:       "\";\"",
 						},
 						&ruleRefExpr{
-							pos:  position{line: 91, col: 20, offset: 1929},
+							pos:  position{line: 56, col: 60, offset: 1337},
 							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 91, col: 23, offset: 1932},
-							label: "op",
-							expr: &ruleRefExpr{
-								pos:  position{line: 91, col: 26, offset: 1935},
-								name: "Op",
+							pos:   position{line: 56, col: 62, offset: 1339},
+							label: "cond",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 56, col: 67, offset: 1344},
+								expr: &ruleRefExpr{
+									pos:  position{line: 56, col: 67, offset: 1344},
+									name: "Expr",
+								},
 							},
 						},
 						&ruleRefExpr{
-							pos:  position{line: 91, col: 29, offset: 1938},
+							pos:  position{line: 56, col: 73, offset: 1350},
 							name: "_",
 						},
-						&labeledExpr{
-							pos:   position{line: 91, col: 32, offset: 1941},
-							label: "right",
-							expr: &ruleRefExpr{
-								pos:  position{line: 91, col: 38, offset: 1947},
-								name: "Expr",
-							},
-						},
-					},
-				},
-			},
-			leader:        false,
-			leftRecursive: true,
-		},
-		{
-			name: "Op",
-			pos:  position{line: 110, col: 1, offset: 2316},
-			expr: &actionExpr{
-				pos: position{line: 110, col: 6, offset: 2321},
-				run: (*parser).callonOp1,
-				expr: &labeledExpr{
-					pos:   position{line: 110, col: 6, offset: 2321},
-					label: "op",
-					expr: &litMatcher{
-						pos:        position{line: 110, col: 9, offset: 2324},
-						val:        "+",
-						ignoreCase: false,
-						want:       "\"+\"",
-					},
-				},
-			},
-			leader:        false,
-			leftRecursive: false,
-		},
-		{
-			name: "String",
-			pos:  position{line: 114, col: 1, offset: 2365},
-			expr: &actionExpr{
-				pos: position{line: 114, col: 10, offset: 2374},
-				run: (*parser).callonString1,
-				expr: &seqExpr{
-					pos: position{line: 114, col: 10, offset: 2374},
-					exprs: []any{
 						&litMatcher{
-							pos:        position{line: 114, col: 10, offset: 2374},
-							val:        "'",
+							pos:        position{line: 56, col: 75, offset: 1352},
+							val:        ";",
 							ignoreCase: false,
-							want:       "\"'\"",
+							want:       "\";\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 56, col: 79, offset: 1356},
+							name: "_",
 						},
 						&labeledExpr{
-							pos:   position{line: 114, col: 15, offset: 2379},
-							label: "s",
-							expr: &zeroOrMoreExpr{
-								pos: position{line: 114, col: 17, offset: 2381},
-								expr: &charClassMatcher{
-									pos:        position{line: 114, col: 17, offset: 2381},
-									val:        "[^']",
-									chars:      []rune{'\''},
-									ignoreCase: false,
-									inverted:   true,
+							pos:   position{line: 56, col: 81, offset: 1358},
+							label: "update",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 56, col: 88, offset: 1365},
+								expr: &ruleRefExpr{
+									pos:  position{line: 56, col: 88, offset: 1365},
+									name: "Assignment",
 								},
 							},
 						},
+						&ruleRefExpr{
+							pos:  position{line: 56, col: 100, offset: 1377},
+							name: "_",
+						},
 						&litMatcher{
-							pos:        position{line: 114, col: 23, offset: 2387},
-							val:        "'",
+							pos:        position{line: 56, col: 102, offset: 1379},
+							val:        ")",
 							ignoreCase: false,
-							want:       "\"'\"",
+							want:       "\")\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 56, col: 106, offset: 1383},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 56, col: 108, offset: 1385},
+							label: "body",
+							expr: &ruleRefExpr{
+								pos:  position{line: 56, col: 113, offset: 1390},
+								name: "Statement",
+							},
 						},
 					},
 				},
@@ -397,61 +479,2609 @@ var g = &grammar{
 			leftRecursive: false,
 		},
 		{
-			name: "Identifier",
-			pos:  position{line: 121, col: 1, offset: 2510},
-			expr: &actionExpr{
-				pos: position{line: 121, col: 14, offset: 2523},
-				run: (*parser).callonIdentifier1,
+			name: "ForEach",
+			pos:  position{line: 83, col: 1, offset: 1957},
+			expr: &choiceExpr{
+				pos: position{line: 83, col: 11, offset: 1967},
+				alternatives: []any{
+					&actionExpr{
+						pos: position{line: 83, col: 11, offset: 1967},
+						run: (*parser).callonForEach2,
+						expr: &seqExpr{
+							pos: position{line: 83, col: 11, offset: 1967},
+							exprs: []any{
+								&litMatcher{
+									pos:        position{line: 83, col: 11, offset: 1967},
+									val:        "for",
+									ignoreCase: false,
+									want:       "\"for\"",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 83, col: 17, offset: 1973},
+									name: "_",
+								},
+								&litMatcher{
+									pos:        position{line: 83, col: 19, offset: 1975},
+									val:        "(",
+									ignoreCase: false,
+									want:       "\"(\"",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 83, col: 23, offset: 1979},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 83, col: 25, offset: 1981},
+									label: "typ",
+									expr: &ruleRefExpr{
+										pos:  position{line: 83, col: 29, offset: 1985},
+										name: "Type",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 83, col: 34, offset: 1990},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 83, col: 36, offset: 1992},
+									label: "name",
+									expr: &ruleRefExpr{
+										pos:  position{line: 83, col: 41, offset: 1997},
+										name: "Identifier",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 83, col: 52, offset: 2008},
+									name: "_",
+								},
+								&litMatcher{
+									pos:        position{line: 83, col: 54, offset: 2010},
+									val:        ":",
+									ignoreCase: false,
+									want:       "\":\"",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 83, col: 58, offset: 2014},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 83, col: 60, offset: 2016},
+									label: "collection",
+									expr: &ruleRefExpr{
+										pos:  position{line: 83, col: 71, offset: 2027},
+										name: "Expr",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 83, col: 76, offset: 2032},
+									name: "_",
+								},
+								&litMatcher{
+									pos:        position{line: 83, col: 78, offset: 2034},
+									val:        ")",
+									ignoreCase: false,
+									want:       "\")\"",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 83, col: 82, offset: 2038},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 83, col: 84, offset: 2040},
+									label: "body",
+									expr: &ruleRefExpr{
+										pos:  position{line: 83, col: 89, offset: 2045},
+										name: "Statement",
+									},
+								},
+							},
+						},
+					},
+					&actionExpr{
+						pos: position{line: 85, col: 5, offset: 2141},
+						run: (*parser).callonForEach23,
+						expr: &seqExpr{
+							pos: position{line: 85, col: 5, offset: 2141},
+							exprs: []any{
+								&litMatcher{
+									pos:        position{line: 85, col: 5, offset: 2141},
+									val:        "for",
+									ignoreCase: false,
+									want:       "\"for\"",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 85, col: 11, offset: 2147},
+									name: "_",
+								},
+								&litMatcher{
+									pos:        position{line: 85, col: 13, offset: 2149},
+									val:        "(",
+									ignoreCase: false,
+									want:       "\"(\"",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 85, col: 17, offset: 2153},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 85, col: 19, offset: 2155},
+									label: "name",
+									expr: &ruleRefExpr{
+										pos:  position{line: 85, col: 24, offset: 2160},
+										name: "Identifier",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 85, col: 35, offset: 2171},
+									name: "_",
+								},
+								&litMatcher{
+									pos:        position{line: 85, col: 37, offset: 2173},
+									val:        "in",
+									ignoreCase: false,
+									want:       "\"in\"",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 85, col: 42, offset: 2178},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 85, col: 44, offset: 2180},
+									label: "collection",
+									expr: &ruleRefExpr{
+										pos:  position{line: 85, col: 55, offset: 2191},
+										name: "Expr",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 85, col: 60, offset: 2196},
+									name: "_",
+								},
+								&litMatcher{
+									pos:        position{line: 85, col: 62, offset: 2198},
+									val:        ")",
+									ignoreCase: false,
+									want:       "\")\"",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 85, col: 66, offset: 2202},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 85, col: 68, offset: 2204},
+									label: "body",
+									expr: &ruleRefExpr{
+										pos:  position{line: 85, col: 73, offset: 2209},
+										name: "Statement",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "While",
+			pos:  position{line: 89, col: 1, offset: 2306},
+			expr: &actionExpr{
+				pos: position{line: 89, col: 9, offset: 2314},
+				run: (*parser).callonWhile1,
+				expr: &seqExpr{
+					pos: position{line: 89, col: 9, offset: 2314},
+					exprs: []any{
+						&litMatcher{
+							pos:        position{line: 89, col: 9, offset: 2314},
+							val:        "while",
+							ignoreCase: false,
+							want:       "\"while\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 89, col: 17, offset: 2322},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 89, col: 19, offset: 2324},
+							val:        "(",
+							ignoreCase: false,
+							want:       "\"(\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 89, col: 23, offset: 2328},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 89, col: 25, offset: 2330},
+							label: "cond",
+							expr: &ruleRefExpr{
+								pos:  position{line: 89, col: 30, offset: 2335},
+								name: "Expr",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 89, col: 35, offset: 2340},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 89, col: 37, offset: 2342},
+							val:        ")",
+							ignoreCase: false,
+							want:       "\")\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 89, col: 41, offset: 2346},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 89, col: 43, offset: 2348},
+							label: "body",
+							expr: &ruleRefExpr{
+								pos:  position{line: 89, col: 48, offset: 2353},
+								name: "Statement",
+							},
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Return",
+			pos:  position{line: 104, col: 1, offset: 2640},
+			expr: &actionExpr{
+				pos: position{line: 104, col: 10, offset: 2649},
+				run: (*parser).callonReturn1,
+				expr: &seqExpr{
+					pos: position{line: 104, col: 10, offset: 2649},
+					exprs: []any{
+						&litMatcher{
+							pos:        position{line: 104, col: 10, offset: 2649},
+							val:        "return",
+							ignoreCase: false,
+							want:       "\"return\"",
+						},
+						&notExpr{
+							pos: position{line: 104, col: 19, offset: 2658},
+							expr: &ruleRefExpr{
+								pos:  position{line: 104, col: 20, offset: 2659},
+								name: "IdentifierChar",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 104, col: 35, offset: 2674},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 104, col: 37, offset: 2676},
+							label: "expr",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 104, col: 42, offset: 2681},
+								expr: &ruleRefExpr{
+									pos:  position{line: 104, col: 42, offset: 2681},
+									name: "Expr",
+								},
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 104, col: 48, offset: 2687},
+							name: "End",
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Break",
+			pos:  position{line: 118, col: 1, offset: 2900},
+			expr: &actionExpr{
+				pos: position{line: 118, col: 9, offset: 2908},
+				run: (*parser).callonBreak1,
+				expr: &seqExpr{
+					pos: position{line: 118, col: 9, offset: 2908},
+					exprs: []any{
+						&litMatcher{
+							pos:        position{line: 118, col: 9, offset: 2908},
+							val:        "break",
+							ignoreCase: false,
+							want:       "\"break\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 118, col: 17, offset: 2916},
+							name: "End",
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Continue",
+			pos:  position{line: 122, col: 1, offset: 2976},
+			expr: &actionExpr{
+				pos: position{line: 122, col: 12, offset: 2987},
+				run: (*parser).callonContinue1,
+				expr: &seqExpr{
+					pos: position{line: 122, col: 12, offset: 2987},
+					exprs: []any{
+						&litMatcher{
+							pos:        position{line: 122, col: 12, offset: 2987},
+							val:        "continue",
+							ignoreCase: false,
+							want:       "\"continue\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 122, col: 23, offset: 2998},
+							name: "End",
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Declaration",
+			pos:  position{line: 126, col: 1, offset: 3059},
+			expr: &actionExpr{
+				pos: position{line: 126, col: 15, offset: 3073},
+				run: (*parser).callonDeclaration1,
+				expr: &seqExpr{
+					pos: position{line: 126, col: 15, offset: 3073},
+					exprs: []any{
+						&labeledExpr{
+							pos:   position{line: 126, col: 15, offset: 3073},
+							label: "typ",
+							expr: &ruleRefExpr{
+								pos:  position{line: 126, col: 19, offset: 3077},
+								name: "Type",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 126, col: 24, offset: 3082},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 126, col: 26, offset: 3084},
+							label: "name",
+							expr: &ruleRefExpr{
+								pos:  position{line: 126, col: 31, offset: 3089},
+								name: "Identifier",
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 126, col: 42, offset: 3100},
+							label: "init",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 126, col: 47, offset: 3105},
+								expr: &seqExpr{
+									pos: position{line: 126, col: 48, offset: 3106},
+									exprs: []any{
+										&ruleRefExpr{
+											pos:  position{line: 126, col: 48, offset: 3106},
+											name: "_",
+										},
+										&litMatcher{
+											pos:        position{line: 126, col: 50, offset: 3108},
+											val:        "=",
+											ignoreCase: false,
+											want:       "\"=\"",
+										},
+										&notExpr{
+											pos: position{line: 126, col: 54, offset: 3112},
+											expr: &litMatcher{
+												pos:        position{line: 126, col: 55, offset: 3113},
+												val:        "=",
+												ignoreCase: false,
+												want:       "\"=\"",
+											},
+										},
+										&ruleRefExpr{
+											pos:  position{line: 126, col: 59, offset: 3117},
+											name: "_",
+										},
+										&ruleRefExpr{
+											pos:  position{line: 126, col: 61, offset: 3119},
+											name: "Expr",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Assignment",
+			pos:  position{line: 141, col: 1, offset: 3450},
+			expr: &choiceExpr{
+				pos: position{line: 141, col: 14, offset: 3463},
+				alternatives: []any{
+					&actionExpr{
+						pos: position{line: 141, col: 14, offset: 3463},
+						run: (*parser).callonAssignment2,
+						expr: &seqExpr{
+							pos: position{line: 141, col: 14, offset: 3463},
+							exprs: []any{
+								&labeledExpr{
+									pos:   position{line: 141, col: 14, offset: 3463},
+									label: "target",
+									expr: &ruleRefExpr{
+										pos:  position{line: 141, col: 21, offset: 3470},
+										name: "Postfix",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 141, col: 29, offset: 3478},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 141, col: 31, offset: 3480},
+									label: "op",
+									expr: &choiceExpr{
+										pos: position{line: 141, col: 35, offset: 3484},
+										alternatives: []any{
+											&litMatcher{
+												pos:        position{line: 141, col: 35, offset: 3484},
+												val:        "++",
+												ignoreCase: false,
+												want:       "\"++\"",
+											},
+											&litMatcher{
+												pos:        position{line: 141, col: 42, offset: 3491},
+												val:        "--",
+												ignoreCase: false,
+												want:       "\"--\"",
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					&actionExpr{
+						pos: position{line: 144, col: 5, offset: 3592},
+						run: (*parser).callonAssignment11,
+						expr: &seqExpr{
+							pos: position{line: 144, col: 5, offset: 3592},
+							exprs: []any{
+								&labeledExpr{
+									pos:   position{line: 144, col: 5, offset: 3592},
+									label: "target",
+									expr: &ruleRefExpr{
+										pos:  position{line: 144, col: 12, offset: 3599},
+										name: "Postfix",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 144, col: 20, offset: 3607},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 144, col: 22, offset: 3609},
+									label: "op",
+									expr: &ruleRefExpr{
+										pos:  position{line: 144, col: 25, offset: 3612},
+										name: "AssignmentOp",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 144, col: 38, offset: 3625},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 144, col: 40, offset: 3627},
+									label: "expr",
+									expr: &ruleRefExpr{
+										pos:  position{line: 144, col: 45, offset: 3632},
+										name: "Expr",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "AssignmentOp",
+			pos:  position{line: 149, col: 1, offset: 3724},
+			expr: &actionExpr{
+				pos: position{line: 149, col: 16, offset: 3739},
+				run: (*parser).callonAssignmentOp1,
+				expr: &choiceExpr{
+					pos: position{line: 149, col: 17, offset: 3740},
+					alternatives: []any{
+						&litMatcher{
+							pos:        position{line: 149, col: 17, offset: 3740},
+							val:        "+=",
+							ignoreCase: false,
+							want:       "\"+=\"",
+						},
+						&litMatcher{
+							pos:        position{line: 149, col: 24, offset: 3747},
+							val:        "-=",
+							ignoreCase: false,
+							want:       "\"-=\"",
+						},
+						&litMatcher{
+							pos:        position{line: 149, col: 31, offset: 3754},
+							val:        "*=",
+							ignoreCase: false,
+							want:       "\"*=\"",
+						},
+						&litMatcher{
+							pos:        position{line: 149, col: 38, offset: 3761},
+							val:        "/=",
+							ignoreCase: false,
+							want:       "\"/=\"",
+						},
+						&litMatcher{
+							pos:        position{line: 149, col: 45, offset: 3768},
+							val:        "%=",
+							ignoreCase: false,
+							want:       "\"%=\"",
+						},
+						&seqExpr{
+							pos: position{line: 149, col: 52, offset: 3775},
+							exprs: []any{
+								&litMatcher{
+									pos:        position{line: 149, col: 52, offset: 3775},
+									val:        "=",
+									ignoreCase: false,
+									want:       "\"=\"",
+								},
+								&notExpr{
+									pos: position{line: 149, col: 56, offset: 3779},
+									expr: &litMatcher{
+										pos:        position{line: 149, col: 57, offset: 3780},
+										val:        "=",
+										ignoreCase: false,
+										want:       "\"=\"",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "ExprStatement",
+			pos:  position{line: 153, col: 1, offset: 3821},
+			expr: &actionExpr{
+				pos: position{line: 153, col: 17, offset: 3837},
+				run: (*parser).callonExprStatement1,
+				expr: &seqExpr{
+					pos: position{line: 153, col: 17, offset: 3837},
+					exprs: []any{
+						&labeledExpr{
+							pos:   position{line: 153, col: 17, offset: 3837},
+							label: "expr",
+							expr: &ruleRefExpr{
+								pos:  position{line: 153, col: 22, offset: 3842},
+								name: "Expr",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 153, col: 27, offset: 3847},
+							name: "End",
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Type",
+			pos:  position{line: 163, col: 1, offset: 3991},
+			expr: &actionExpr{
+				pos: position{line: 163, col: 8, offset: 3998},
+				run: (*parser).callonType1,
+				expr: &seqExpr{
+					pos: position{line: 163, col: 8, offset: 3998},
+					exprs: []any{
+						&choiceExpr{
+							pos: position{line: 163, col: 9, offset: 3999},
+							alternatives: []any{
+								&litMatcher{
+									pos:        position{line: 163, col: 9, offset: 3999},
+									val:        "def",
+									ignoreCase: false,
+									want:       "\"def\"",
+								},
+								&litMatcher{
+									pos:        position{line: 163, col: 17, offset: 4007},
+									val:        "int",
+									ignoreCase: false,
+									want:       "\"int\"",
+								},
+								&litMatcher{
+									pos:        position{line: 163, col: 25, offset: 4015},
+									val:        "long",
+									ignoreCase: false,
+									want:       "\"long\"",
+								},
+								&litMatcher{
+									pos:        position{line: 163, col: 34, offset: 4024},
+									val:        "short",
+									ignoreCase: false,
+									want:       "\"short\"",
+								},
+								&litMatcher{
+									pos:        position{line: 163, col: 44, offset: 4034},
+									val:        "byte",
+									ignoreCase: false,
+									want:       "\"byte\"",
+								},
+								&litMatcher{
+									pos:        position{line: 163, col: 53, offset: 4043},
+									val:        "double",
+									ignoreCase: false,
+									want:       "\"double\"",
+								},
+								&litMatcher{
+									pos:        position{line: 163, col: 64, offset: 4054},
+									val:        "float",
+									ignoreCase: false,
+									want:       "\"float\"",
+								},
+								&litMatcher{
+									pos:        position{line: 163, col: 74, offset: 4064},
+									val:        "boolean",
+									ignoreCase: false,
+									want:       "\"boolean\"",
+								},
+								&litMatcher{
+									pos:        position{line: 163, col: 86, offset: 4076},
+									val:        "String",
+									ignoreCase: false,
+									want:       "\"String\"",
+								},
+								&litMatcher{
+									pos:        position{line: 163, col: 97, offset: 4087},
+									val:        "Object",
+									ignoreCase: false,
+									want:       "\"Object\"",
+								},
+								&litMatcher{
+									pos:        position{line: 163, col: 108, offset: 4098},
+									val:        "List",
+									ignoreCase: false,
+									want:       "\"List\"",
+								},
+								&litMatcher{
+									pos:        position{line: 163, col: 117, offset: 4107},
+									val:        "Map",
+									ignoreCase: false,
+									want:       "\"Map\"",
+								},
+							},
+						},
+						&notExpr{
+							pos: position{line: 163, col: 124, offset: 4114},
+							expr: &ruleRefExpr{
+								pos:  position{line: 163, col: 125, offset: 4115},
+								name: "IdentifierChar",
+							},
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Keyword",
+			pos:  position{line: 167, col: 1, offset: 4166},
+			expr: &seqExpr{
+				pos: position{line: 167, col: 11, offset: 4176},
+				exprs: []any{
+					&choiceExpr{
+						pos: position{line: 167, col: 12, offset: 4177},
+						alternatives: []any{
+							&litMatcher{
+								pos:        position{line: 167, col: 12, offset: 4177},
+								val:        "if",
+								ignoreCase: false,
+								want:       "\"if\"",
+							},
+							&litMatcher{
+								pos:        position{line: 167, col: 19, offset: 4184},
+								val:        "else",
+								ignoreCase: false,
+								want:       "\"else\"",
+							},
+							&litMatcher{
+								pos:        position{line: 167, col: 28, offset: 4193},
+								val:        "for",
+								ignoreCase: false,
+								want:       "\"for\"",
+							},
+							&litMatcher{
+								pos:        position{line: 167, col: 36, offset: 4201},
+								val:        "in",
+								ignoreCase: false,
+								want:       "\"in\"",
+							},
+							&litMatcher{
+								pos:        position{line: 167, col: 43, offset: 4208},
+								val:        "while",
+								ignoreCase: false,
+								want:       "\"while\"",
+							},
+							&litMatcher{
+								pos:        position{line: 167, col: 53, offset: 4218},
+								val:        "return",
+								ignoreCase: false,
+								want:       "\"return\"",
+							},
+							&litMatcher{
+								pos:        position{line: 167, col: 64, offset: 4229},
+								val:        "break",
+								ignoreCase: false,
+								want:       "\"break\"",
+							},
+							&litMatcher{
+								pos:        position{line: 167, col: 74, offset: 4239},
+								val:        "continue",
+								ignoreCase: false,
+								want:       "\"continue\"",
+							},
+							&litMatcher{
+								pos:        position{line: 167, col: 87, offset: 4252},
+								val:        "new",
+								ignoreCase: false,
+								want:       "\"new\"",
+							},
+							&litMatcher{
+								pos:        position{line: 167, col: 95, offset: 4260},
+								val:        "def",
+								ignoreCase: false,
+								want:       "\"def\"",
+							},
+						},
+					},
+					&notExpr{
+						pos: position{line: 167, col: 102, offset: 4267},
+						expr: &ruleRefExpr{
+							pos:  position{line: 167, col: 103, offset: 4268},
+							name: "IdentifierChar",
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Expr",
+			pos:  position{line: 169, col: 1, offset: 4284},
+			expr: &ruleRefExpr{
+				pos:  position{line: 169, col: 8, offset: 4291},
+				name: "Conditional",
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Conditional",
+			pos:  position{line: 171, col: 1, offset: 4304},
+			expr: &choiceExpr{
+				pos: position{line: 171, col: 15, offset: 4318},
+				alternatives: []any{
+					&actionExpr{
+						pos: position{line: 171, col: 15, offset: 4318},
+						run: (*parser).callonConditional2,
+						expr: &seqExpr{
+							pos: position{line: 171, col: 15, offset: 4318},
+							exprs: []any{
+								&labeledExpr{
+									pos:   position{line: 171, col: 15, offset: 4318},
+									label: "cond",
+									expr: &ruleRefExpr{
+										pos:  position{line: 171, col: 20, offset: 4323},
+										name: "LogicalOr",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 171, col: 30, offset: 4333},
+									name: "_",
+								},
+								&litMatcher{
+									pos:        position{line: 171, col: 32, offset: 4335},
+									val:        "?",
+									ignoreCase: false,
+									want:       "\"?\"",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 171, col: 36, offset: 4339},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 171, col: 38, offset: 4341},
+									label: "then",
+									expr: &ruleRefExpr{
+										pos:  position{line: 171, col: 43, offset: 4346},
+										name: "Expr",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 171, col: 48, offset: 4351},
+									name: "_",
+								},
+								&litMatcher{
+									pos:        position{line: 171, col: 50, offset: 4353},
+									val:        ":",
+									ignoreCase: false,
+									want:       "\":\"",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 171, col: 54, offset: 4357},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 171, col: 56, offset: 4359},
+									label: "els",
+									expr: &ruleRefExpr{
+										pos:  position{line: 171, col: 60, offset: 4363},
+										name: "Expr",
+									},
+								},
+							},
+						},
+					},
+					&ruleRefExpr{
+						pos:  position{line: 189, col: 5, offset: 4746},
+						name: "LogicalOr",
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "LogicalOr",
+			pos:  position{line: 191, col: 1, offset: 4757},
+			expr: &choiceExpr{
+				pos: position{line: 191, col: 13, offset: 4769},
+				alternatives: []any{
+					&actionExpr{
+						pos: position{line: 191, col: 13, offset: 4769},
+						run: (*parser).callonLogicalOr2,
+						expr: &seqExpr{
+							pos: position{line: 191, col: 13, offset: 4769},
+							exprs: []any{
+								&labeledExpr{
+									pos:   position{line: 191, col: 13, offset: 4769},
+									label: "left",
+									expr: &ruleRefExpr{
+										pos:  position{line: 191, col: 18, offset: 4774},
+										name: "LogicalOr",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 191, col: 28, offset: 4784},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 191, col: 30, offset: 4786},
+									label: "op",
+									expr: &litMatcher{
+										pos:        position{line: 191, col: 33, offset: 4789},
+										val:        "||",
+										ignoreCase: false,
+										want:       "\"||\"",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 191, col: 38, offset: 4794},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 191, col: 40, offset: 4796},
+									label: "right",
+									expr: &ruleRefExpr{
+										pos:  position{line: 191, col: 46, offset: 4802},
+										name: "LogicalAnd",
+									},
+								},
+							},
+						},
+					},
+					&ruleRefExpr{
+						pos:  position{line: 193, col: 5, offset: 4882},
+						name: "LogicalAnd",
+					},
+				},
+			},
+			leader:        true,
+			leftRecursive: true,
+		},
+		{
+			name: "LogicalAnd",
+			pos:  position{line: 195, col: 1, offset: 4894},
+			expr: &choiceExpr{
+				pos: position{line: 195, col: 14, offset: 4907},
+				alternatives: []any{
+					&actionExpr{
+						pos: position{line: 195, col: 14, offset: 4907},
+						run: (*parser).callonLogicalAnd2,
+						expr: &seqExpr{
+							pos: position{line: 195, col: 14, offset: 4907},
+							exprs: []any{
+								&labeledExpr{
+									pos:   position{line: 195, col: 14, offset: 4907},
+									label: "left",
+									expr: &ruleRefExpr{
+										pos:  position{line: 195, col: 19, offset: 4912},
+										name: "LogicalAnd",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 195, col: 30, offset: 4923},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 195, col: 32, offset: 4925},
+									label: "op",
+									expr: &litMatcher{
+										pos:        position{line: 195, col: 35, offset: 4928},
+										val:        "&&",
+										ignoreCase: false,
+										want:       "\"&&\"",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 195, col: 40, offset: 4933},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 195, col: 42, offset: 4935},
+									label: "right",
+									expr: &ruleRefExpr{
+										pos:  position{line: 195, col: 48, offset: 4941},
+										name: "Equality",
+									},
+								},
+							},
+						},
+					},
+					&ruleRefExpr{
+						pos:  position{line: 197, col: 5, offset: 5019},
+						name: "Equality",
+					},
+				},
+			},
+			leader:        true,
+			leftRecursive: true,
+		},
+		{
+			name: "Equality",
+			pos:  position{line: 199, col: 1, offset: 5029},
+			expr: &choiceExpr{
+				pos: position{line: 199, col: 12, offset: 5040},
+				alternatives: []any{
+					&actionExpr{
+						pos: position{line: 199, col: 12, offset: 5040},
+						run: (*parser).callonEquality2,
+						expr: &seqExpr{
+							pos: position{line: 199, col: 12, offset: 5040},
+							exprs: []any{
+								&labeledExpr{
+									pos:   position{line: 199, col: 12, offset: 5040},
+									label: "left",
+									expr: &ruleRefExpr{
+										pos:  position{line: 199, col: 17, offset: 5045},
+										name: "Equality",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 199, col: 26, offset: 5054},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 199, col: 28, offset: 5056},
+									label: "op",
+									expr: &ruleRefExpr{
+										pos:  position{line: 199, col: 31, offset: 5059},
+										name: "EqualityOp",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 199, col: 42, offset: 5070},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 199, col: 44, offset: 5072},
+									label: "right",
+									expr: &ruleRefExpr{
+										pos:  position{line: 199, col: 50, offset: 5078},
+										name: "Relational",
+									},
+								},
+							},
+						},
+					},
+					&ruleRefExpr{
+						pos:  position{line: 201, col: 5, offset: 5158},
+						name: "Relational",
+					},
+				},
+			},
+			leader:        true,
+			leftRecursive: true,
+		},
+		{
+			name: "EqualityOp",
+			pos:  position{line: 203, col: 1, offset: 5170},
+			expr: &actionExpr{
+				pos: position{line: 203, col: 14, offset: 5183},
+				run: (*parser).callonEqualityOp1,
+				expr: &choiceExpr{
+					pos: position{line: 203, col: 15, offset: 5184},
+					alternatives: []any{
+						&litMatcher{
+							pos:        position{line: 203, col: 15, offset: 5184},
+							val:        "==",
+							ignoreCase: false,
+							want:       "\"==\"",
+						},
+						&litMatcher{
+							pos:        position{line: 203, col: 22, offset: 5191},
+							val:        "!=",
+							ignoreCase: false,
+							want:       "\"!=\"",
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Relational",
+			pos:  position{line: 207, col: 1, offset: 5233},
+			expr: &choiceExpr{
+				pos: position{line: 207, col: 14, offset: 5246},
+				alternatives: []any{
+					&actionExpr{
+						pos: position{line: 207, col: 14, offset: 5246},
+						run: (*parser).callonRelational2,
+						expr: &seqExpr{
+							pos: position{line: 207, col: 14, offset: 5246},
+							exprs: []any{
+								&labeledExpr{
+									pos:   position{line: 207, col: 14, offset: 5246},
+									label: "left",
+									expr: &ruleRefExpr{
+										pos:  position{line: 207, col: 19, offset: 5251},
+										name: "Relational",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 207, col: 30, offset: 5262},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 207, col: 32, offset: 5264},
+									label: "op",
+									expr: &ruleRefExpr{
+										pos:  position{line: 207, col: 35, offset: 5267},
+										name: "RelationalOp",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 207, col: 48, offset: 5280},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 207, col: 50, offset: 5282},
+									label: "right",
+									expr: &ruleRefExpr{
+										pos:  position{line: 207, col: 56, offset: 5288},
+										name: "Additive",
+									},
+								},
+							},
+						},
+					},
+					&ruleRefExpr{
+						pos:  position{line: 209, col: 5, offset: 5366},
+						name: "Additive",
+					},
+				},
+			},
+			leader:        true,
+			leftRecursive: true,
+		},
+		{
+			name: "RelationalOp",
+			pos:  position{line: 211, col: 1, offset: 5376},
+			expr: &actionExpr{
+				pos: position{line: 211, col: 16, offset: 5391},
+				run: (*parser).callonRelationalOp1,
+				expr: &choiceExpr{
+					pos: position{line: 211, col: 17, offset: 5392},
+					alternatives: []any{
+						&litMatcher{
+							pos:        position{line: 211, col: 17, offset: 5392},
+							val:        "<=",
+							ignoreCase: false,
+							want:       "\"<=\"",
+						},
+						&litMatcher{
+							pos:        position{line: 211, col: 24, offset: 5399},
+							val:        ">=",
+							ignoreCase: false,
+							want:       "\">=\"",
+						},
+						&litMatcher{
+							pos:        position{line: 211, col: 31, offset: 5406},
+							val:        "<",
+							ignoreCase: false,
+							want:       "\"<\"",
+						},
+						&litMatcher{
+							pos:        position{line: 211, col: 37, offset: 5412},
+							val:        ">",
+							ignoreCase: false,
+							want:       "\">\"",
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Additive",
+			pos:  position{line: 215, col: 1, offset: 5453},
+			expr: &choiceExpr{
+				pos: position{line: 215, col: 12, offset: 5464},
+				alternatives: []any{
+					&actionExpr{
+						pos: position{line: 215, col: 12, offset: 5464},
+						run: (*parser).callonAdditive2,
+						expr: &seqExpr{
+							pos: position{line: 215, col: 12, offset: 5464},
+							exprs: []any{
+								&labeledExpr{
+									pos:   position{line: 215, col: 12, offset: 5464},
+									label: "left",
+									expr: &ruleRefExpr{
+										pos:  position{line: 215, col: 17, offset: 5469},
+										name: "Additive",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 215, col: 26, offset: 5478},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 215, col: 28, offset: 5480},
+									label: "op",
+									expr: &ruleRefExpr{
+										pos:  position{line: 215, col: 31, offset: 5483},
+										name: "AdditiveOp",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 215, col: 42, offset: 5494},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 215, col: 44, offset: 5496},
+									label: "right",
+									expr: &ruleRefExpr{
+										pos:  position{line: 215, col: 50, offset: 5502},
+										name: "Multiplicative",
+									},
+								},
+							},
+						},
+					},
+					&ruleRefExpr{
+						pos:  position{line: 217, col: 5, offset: 5586},
+						name: "Multiplicative",
+					},
+				},
+			},
+			leader:        true,
+			leftRecursive: true,
+		},
+		{
+			name: "AdditiveOp",
+			pos:  position{line: 219, col: 1, offset: 5602},
+			expr: &actionExpr{
+				pos: position{line: 219, col: 14, offset: 5615},
+				run: (*parser).callonAdditiveOp1,
+				expr: &choiceExpr{
+					pos: position{line: 219, col: 15, offset: 5616},
+					alternatives: []any{
+						&litMatcher{
+							pos:        position{line: 219, col: 15, offset: 5616},
+							val:        "+",
+							ignoreCase: false,
+							want:       "\"+\"",
+						},
+						&litMatcher{
+							pos:        position{line: 219, col: 21, offset: 5622},
+							val:        "-",
+							ignoreCase: false,
+							want:       "\"-\"",
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Multiplicative",
+			pos:  position{line: 223, col: 1, offset: 5663},
+			expr: &choiceExpr{
+				pos: position{line: 223, col: 18, offset: 5680},
+				alternatives: []any{
+					&actionExpr{
+						pos: position{line: 223, col: 18, offset: 5680},
+						run: (*parser).callonMultiplicative2,
+						expr: &seqExpr{
+							pos: position{line: 223, col: 18, offset: 5680},
+							exprs: []any{
+								&labeledExpr{
+									pos:   position{line: 223, col: 18, offset: 5680},
+									label: "left",
+									expr: &ruleRefExpr{
+										pos:  position{line: 223, col: 23, offset: 5685},
+										name: "Multiplicative",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 223, col: 38, offset: 5700},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 223, col: 40, offset: 5702},
+									label: "op",
+									expr: &ruleRefExpr{
+										pos:  position{line: 223, col: 43, offset: 5705},
+										name: "MultiplicativeOp",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 223, col: 60, offset: 5722},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 223, col: 62, offset: 5724},
+									label: "right",
+									expr: &ruleRefExpr{
+										pos:  position{line: 223, col: 68, offset: 5730},
+										name: "Unary",
+									},
+								},
+							},
+						},
+					},
+					&ruleRefExpr{
+						pos:  position{line: 225, col: 5, offset: 5805},
+						name: "Unary",
+					},
+				},
+			},
+			leader:        true,
+			leftRecursive: true,
+		},
+		{
+			name: "MultiplicativeOp",
+			pos:  position{line: 227, col: 1, offset: 5812},
+			expr: &actionExpr{
+				pos: position{line: 227, col: 20, offset: 5831},
+				run: (*parser).callonMultiplicativeOp1,
+				expr: &choiceExpr{
+					pos: position{line: 227, col: 21, offset: 5832},
+					alternatives: []any{
+						&litMatcher{
+							pos:        position{line: 227, col: 21, offset: 5832},
+							val:        "*",
+							ignoreCase: false,
+							want:       "\"*\"",
+						},
+						&litMatcher{
+							pos:        position{line: 227, col: 27, offset: 5838},
+							val:        "/",
+							ignoreCase: false,
+							want:       "\"/\"",
+						},
+						&litMatcher{
+							pos:        position{line: 227, col: 33, offset: 5844},
+							val:        "%",
+							ignoreCase: false,
+							want:       "\"%\"",
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Unary",
+			pos:  position{line: 231, col: 1, offset: 5885},
+			expr: &choiceExpr{
+				pos: position{line: 231, col: 9, offset: 5893},
+				alternatives: []any{
+					&actionExpr{
+						pos: position{line: 231, col: 9, offset: 5893},
+						run: (*parser).callonUnary2,
+						expr: &seqExpr{
+							pos: position{line: 231, col: 9, offset: 5893},
+							exprs: []any{
+								&litMatcher{
+									pos:        position{line: 231, col: 9, offset: 5893},
+									val:        "(",
+									ignoreCase: false,
+									want:       "\"(\"",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 231, col: 13, offset: 5897},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 231, col: 15, offset: 5899},
+									label: "typ",
+									expr: &ruleRefExpr{
+										pos:  position{line: 231, col: 19, offset: 5903},
+										name: "Type",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 231, col: 24, offset: 5908},
+									name: "_",
+								},
+								&litMatcher{
+									pos:        position{line: 231, col: 26, offset: 5910},
+									val:        ")",
+									ignoreCase: false,
+									want:       "\")\"",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 231, col: 30, offset: 5914},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 231, col: 32, offset: 5916},
+									label: "expr",
+									expr: &ruleRefExpr{
+										pos:  position{line: 231, col: 37, offset: 5921},
+										name: "Unary",
+									},
+								},
+							},
+						},
+					},
+					&actionExpr{
+						pos: position{line: 239, col: 5, offset: 6113},
+						run: (*parser).callonUnary13,
+						expr: &seqExpr{
+							pos: position{line: 239, col: 5, offset: 6113},
+							exprs: []any{
+								&labeledExpr{
+									pos:   position{line: 239, col: 5, offset: 6113},
+									label: "op",
+									expr: &ruleRefExpr{
+										pos:  position{line: 239, col: 8, offset: 6116},
+										name: "UnaryOp",
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 239, col: 16, offset: 6124},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 239, col: 18, offset: 6126},
+									label: "expr",
+									expr: &ruleRefExpr{
+										pos:  position{line: 239, col: 23, offset: 6131},
+										name: "Unary",
+									},
+								},
+							},
+						},
+					},
+					&ruleRefExpr{
+						pos:  position{line: 247, col: 5, offset: 6324},
+						name: "Postfix",
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "UnaryOp",
+			pos:  position{line: 249, col: 1, offset: 6333},
+			expr: &actionExpr{
+				pos: position{line: 249, col: 11, offset: 6343},
+				run: (*parser).callonUnaryOp1,
+				expr: &choiceExpr{
+					pos: position{line: 249, col: 12, offset: 6344},
+					alternatives: []any{
+						&litMatcher{
+							pos:        position{line: 249, col: 12, offset: 6344},
+							val:        "!",
+							ignoreCase: false,
+							want:       "\"!\"",
+						},
+						&litMatcher{
+							pos:        position{line: 249, col: 18, offset: 6350},
+							val:        "-",
+							ignoreCase: false,
+							want:       "\"-\"",
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Postfix",
+			pos:  position{line: 253, col: 1, offset: 6391},
+			expr: &choiceExpr{
+				pos: position{line: 253, col: 11, offset: 6401},
+				alternatives: []any{
+					&labeledExpr{
+						pos:   position{line: 253, col: 11, offset: 6401},
+						label: "expr",
+						expr: &ruleRefExpr{
+							pos:  position{line: 253, col: 16, offset: 6406},
+							name: "MethodCall",
+						},
+					},
+					&labeledExpr{
+						pos:   position{line: 253, col: 29, offset: 6419},
+						label: "expr",
+						expr: &ruleRefExpr{
+							pos:  position{line: 253, col: 34, offset: 6424},
+							name: "Accessor",
+						},
+					},
+					&labeledExpr{
+						pos:   position{line: 253, col: 45, offset: 6435},
+						label: "expr",
+						expr: &ruleRefExpr{
+							pos:  position{line: 253, col: 50, offset: 6440},
+							name: "Index",
+						},
+					},
+					&actionExpr{
+						pos: position{line: 253, col: 58, offset: 6448},
+						run: (*parser).callonPostfix8,
+						expr: &labeledExpr{
+							pos:   position{line: 253, col: 58, offset: 6448},
+							label: "expr",
+							expr: &ruleRefExpr{
+								pos:  position{line: 253, col: 63, offset: 6453},
+								name: "Primary",
+							},
+						},
+					},
+				},
+			},
+			leader:        true,
+			leftRecursive: true,
+		},
+		{
+			name: "Accessor",
+			pos:  position{line: 257, col: 1, offset: 6487},
+			expr: &actionExpr{
+				pos: position{line: 257, col: 12, offset: 6498},
+				run: (*parser).callonAccessor1,
+				expr: &seqExpr{
+					pos: position{line: 257, col: 12, offset: 6498},
+					exprs: []any{
+						&labeledExpr{
+							pos:   position{line: 257, col: 12, offset: 6498},
+							label: "expr",
+							expr: &ruleRefExpr{
+								pos:  position{line: 257, col: 17, offset: 6503},
+								name: "Postfix",
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 257, col: 25, offset: 6511},
+							label: "op",
+							expr: &ruleRefExpr{
+								pos:  position{line: 257, col: 28, offset: 6514},
+								name: "NavigationOp",
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 257, col: 41, offset: 6527},
+							label: "field",
+							expr: &ruleRefExpr{
+								pos:  position{line: 257, col: 47, offset: 6533},
+								name: "Identifier",
+							},
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: true,
+		},
+		{
+			name: "NavigationOp",
+			pos:  position{line: 273, col: 1, offset: 6900},
+			expr: &actionExpr{
+				pos: position{line: 273, col: 16, offset: 6915},
+				run: (*parser).callonNavigationOp1,
 				expr: &labeledExpr{
-					pos:   position{line: 121, col: 14, offset: 2523},
-					label: "id",
-					expr: &oneOrMoreExpr{
-						pos: position{line: 121, col: 17, offset: 2526},
-						expr: &charClassMatcher{
-							pos:        position{line: 121, col: 17, offset: 2526},
-							val:        "[a-zA-Z0-9_]",
+					pos:   position{line: 273, col: 16, offset: 6915},
+					label: "op",
+					expr: &choiceExpr{
+						pos: position{line: 273, col: 20, offset: 6919},
+						alternatives: []any{
+							&litMatcher{
+								pos:        position{line: 273, col: 20, offset: 6919},
+								val:        "?.",
+								ignoreCase: false,
+								want:       "\"?.\"",
+							},
+							&litMatcher{
+								pos:        position{line: 273, col: 27, offset: 6926},
+								val:        ".",
+								ignoreCase: false,
+								want:       "\".\"",
+							},
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "MethodCall",
+			pos:  position{line: 277, col: 1, offset: 6980},
+			expr: &actionExpr{
+				pos: position{line: 277, col: 14, offset: 6993},
+				run: (*parser).callonMethodCall1,
+				expr: &seqExpr{
+					pos: position{line: 277, col: 14, offset: 6993},
+					exprs: []any{
+						&labeledExpr{
+							pos:   position{line: 277, col: 14, offset: 6993},
+							label: "expr",
+							expr: &ruleRefExpr{
+								pos:  position{line: 277, col: 19, offset: 6998},
+								name: "Postfix",
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 277, col: 27, offset: 7006},
+							label: "op",
+							expr: &ruleRefExpr{
+								pos:  position{line: 277, col: 30, offset: 7009},
+								name: "NavigationOp",
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 277, col: 43, offset: 7022},
+							label: "method",
+							expr: &ruleRefExpr{
+								pos:  position{line: 277, col: 50, offset: 7029},
+								name: "Identifier",
+							},
+						},
+						&litMatcher{
+							pos:        position{line: 277, col: 61, offset: 7040},
+							val:        "(",
+							ignoreCase: false,
+							want:       "\"(\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 277, col: 65, offset: 7044},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 277, col: 67, offset: 7046},
+							label: "args",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 277, col: 72, offset: 7051},
+								expr: &ruleRefExpr{
+									pos:  position{line: 277, col: 72, offset: 7051},
+									name: "ArgList",
+								},
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 277, col: 81, offset: 7060},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 277, col: 83, offset: 7062},
+							val:        ")",
+							ignoreCase: false,
+							want:       "\")\"",
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: true,
+		},
+		{
+			name: "Index",
+			pos:  position{line: 312, col: 1, offset: 7833},
+			expr: &actionExpr{
+				pos: position{line: 312, col: 9, offset: 7841},
+				run: (*parser).callonIndex1,
+				expr: &seqExpr{
+					pos: position{line: 312, col: 9, offset: 7841},
+					exprs: []any{
+						&labeledExpr{
+							pos:   position{line: 312, col: 9, offset: 7841},
+							label: "expr",
+							expr: &ruleRefExpr{
+								pos:  position{line: 312, col: 14, offset: 7846},
+								name: "Postfix",
+							},
+						},
+						&litMatcher{
+							pos:        position{line: 312, col: 22, offset: 7854},
+							val:        "[",
+							ignoreCase: false,
+							want:       "\"[\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 312, col: 26, offset: 7858},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 312, col: 28, offset: 7860},
+							label: "index",
+							expr: &ruleRefExpr{
+								pos:  position{line: 312, col: 34, offset: 7866},
+								name: "Expr",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 312, col: 39, offset: 7871},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 312, col: 41, offset: 7873},
+							val:        "]",
+							ignoreCase: false,
+							want:       "\"]\"",
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: true,
+		},
+		{
+			name: "ArgList",
+			pos:  position{line: 327, col: 1, offset: 8150},
+			expr: &actionExpr{
+				pos: position{line: 327, col: 11, offset: 8160},
+				run: (*parser).callonArgList1,
+				expr: &seqExpr{
+					pos: position{line: 327, col: 11, offset: 8160},
+					exprs: []any{
+						&labeledExpr{
+							pos:   position{line: 327, col: 11, offset: 8160},
+							label: "first",
+							expr: &ruleRefExpr{
+								pos:  position{line: 327, col: 17, offset: 8166},
+								name: "Expr",
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 327, col: 22, offset: 8171},
+							label: "rest",
+							expr: &zeroOrMoreExpr{
+								pos: position{line: 327, col: 27, offset: 8176},
+								expr: &seqExpr{
+									pos: position{line: 327, col: 28, offset: 8177},
+									exprs: []any{
+										&ruleRefExpr{
+											pos:  position{line: 327, col: 28, offset: 8177},
+											name: "_",
+										},
+										&litMatcher{
+											pos:        position{line: 327, col: 30, offset: 8179},
+											val:        ",",
+											ignoreCase: false,
+											want:       "\",\"",
+										},
+										&ruleRefExpr{
+											pos:  position{line: 327, col: 34, offset: 8183},
+											name: "_",
+										},
+										&ruleRefExpr{
+											pos:  position{line: 327, col: 36, offset: 8185},
+											name: "Expr",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Primary",
+			pos:  position{line: 336, col: 1, offset: 8335},
+			expr: &choiceExpr{
+				pos: position{line: 336, col: 11, offset: 8345},
+				alternatives: []any{
+					&labeledExpr{
+						pos:   position{line: 336, col: 11, offset: 8345},
+						label: "expr",
+						expr: &ruleRefExpr{
+							pos:  position{line: 336, col: 16, offset: 8350},
+							name: "Parens",
+						},
+					},
+					&labeledExpr{
+						pos:   position{line: 336, col: 25, offset: 8359},
+						label: "expr",
+						expr: &ruleRefExpr{
+							pos:  position{line: 336, col: 30, offset: 8364},
+							name: "Doc",
+						},
+					},
+					&labeledExpr{
+						pos:   position{line: 336, col: 36, offset: 8370},
+						label: "expr",
+						expr: &ruleRefExpr{
+							pos:  position{line: 336, col: 41, offset: 8375},
+							name: "Emit",
+						},
+					},
+					&labeledExpr{
+						pos:   position{line: 336, col: 48, offset: 8382},
+						label: "expr",
+						expr: &ruleRefExpr{
+							pos:  position{line: 336, col: 53, offset: 8387},
+							name: "UrlEncoder",
+						},
+					},
+					&labeledExpr{
+						pos:   position{line: 336, col: 66, offset: 8400},
+						label: "expr",
+						expr: &ruleRefExpr{
+							pos:  position{line: 336, col: 71, offset: 8405},
+							name: "New",
+						},
+					},
+					&labeledExpr{
+						pos:   position{line: 336, col: 77, offset: 8411},
+						label: "expr",
+						expr: &ruleRefExpr{
+							pos:  position{line: 336, col: 82, offset: 8416},
+							name: "MapInit",
+						},
+					},
+					&labeledExpr{
+						pos:   position{line: 336, col: 92, offset: 8426},
+						label: "expr",
+						expr: &ruleRefExpr{
+							pos:  position{line: 336, col: 97, offset: 8431},
+							name: "ListInit",
+						},
+					},
+					&labeledExpr{
+						pos:   position{line: 336, col: 108, offset: 8442},
+						label: "expr",
+						expr: &ruleRefExpr{
+							pos:  position{line: 336, col: 113, offset: 8447},
+							name: "String",
+						},
+					},
+					&labeledExpr{
+						pos:   position{line: 336, col: 122, offset: 8456},
+						label: "expr",
+						expr: &ruleRefExpr{
+							pos:  position{line: 336, col: 127, offset: 8461},
+							name: "Number",
+						},
+					},
+					&labeledExpr{
+						pos:   position{line: 336, col: 136, offset: 8470},
+						label: "expr",
+						expr: &ruleRefExpr{
+							pos:  position{line: 336, col: 141, offset: 8475},
+							name: "Boolean",
+						},
+					},
+					&labeledExpr{
+						pos:   position{line: 336, col: 151, offset: 8485},
+						label: "expr",
+						expr: &ruleRefExpr{
+							pos:  position{line: 336, col: 156, offset: 8490},
+							name: "Null",
+						},
+					},
+					&actionExpr{
+						pos: position{line: 336, col: 163, offset: 8497},
+						run: (*parser).callonPrimary24,
+						expr: &labeledExpr{
+							pos:   position{line: 336, col: 163, offset: 8497},
+							label: "expr",
+							expr: &ruleRefExpr{
+								pos:  position{line: 336, col: 168, offset: 8502},
+								name: "Variable",
+							},
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "New",
+			pos:  position{line: 340, col: 1, offset: 8537},
+			expr: &actionExpr{
+				pos: position{line: 340, col: 7, offset: 8543},
+				run: (*parser).callonNew1,
+				expr: &seqExpr{
+					pos: position{line: 340, col: 7, offset: 8543},
+					exprs: []any{
+						&litMatcher{
+							pos:        position{line: 340, col: 7, offset: 8543},
+							val:        "new",
+							ignoreCase: false,
+							want:       "\"new\"",
+						},
+						&notExpr{
+							pos: position{line: 340, col: 13, offset: 8549},
+							expr: &ruleRefExpr{
+								pos:  position{line: 340, col: 14, offset: 8550},
+								name: "IdentifierChar",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 340, col: 29, offset: 8565},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 340, col: 31, offset: 8567},
+							label: "class",
+							expr: &ruleRefExpr{
+								pos:  position{line: 340, col: 37, offset: 8573},
+								name: "Identifier",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 340, col: 48, offset: 8584},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 340, col: 50, offset: 8586},
+							val:        "(",
+							ignoreCase: false,
+							want:       "\"(\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 340, col: 54, offset: 8590},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 340, col: 56, offset: 8592},
+							val:        ")",
+							ignoreCase: false,
+							want:       "\")\"",
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "ListInit",
+			pos:  position{line: 344, col: 1, offset: 8683},
+			expr: &actionExpr{
+				pos: position{line: 344, col: 12, offset: 8694},
+				run: (*parser).callonListInit1,
+				expr: &seqExpr{
+					pos: position{line: 344, col: 12, offset: 8694},
+					exprs: []any{
+						&litMatcher{
+							pos:        position{line: 344, col: 12, offset: 8694},
+							val:        "[",
+							ignoreCase: false,
+							want:       "\"[\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 344, col: 16, offset: 8698},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 344, col: 18, offset: 8700},
+							label: "args",
+							expr: &zeroOrOneExpr{
+								pos: position{line: 344, col: 23, offset: 8705},
+								expr: &ruleRefExpr{
+									pos:  position{line: 344, col: 23, offset: 8705},
+									name: "ArgList",
+								},
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 344, col: 32, offset: 8714},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 344, col: 34, offset: 8716},
+							val:        "]",
+							ignoreCase: false,
+							want:       "\"]\"",
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "MapInit",
+			pos:  position{line: 360, col: 1, offset: 9028},
+			expr: &choiceExpr{
+				pos: position{line: 360, col: 11, offset: 9038},
+				alternatives: []any{
+					&actionExpr{
+						pos: position{line: 360, col: 11, offset: 9038},
+						run: (*parser).callonMapInit2,
+						expr: &seqExpr{
+							pos: position{line: 360, col: 11, offset: 9038},
+							exprs: []any{
+								&litMatcher{
+									pos:        position{line: 360, col: 11, offset: 9038},
+									val:        "[",
+									ignoreCase: false,
+									want:       "\"[\"",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 360, col: 15, offset: 9042},
+									name: "_",
+								},
+								&litMatcher{
+									pos:        position{line: 360, col: 17, offset: 9044},
+									val:        ":",
+									ignoreCase: false,
+									want:       "\":\"",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 360, col: 21, offset: 9048},
+									name: "_",
+								},
+								&litMatcher{
+									pos:        position{line: 360, col: 23, offset: 9050},
+									val:        "]",
+									ignoreCase: false,
+									want:       "\"]\"",
+								},
+							},
+						},
+					},
+					&actionExpr{
+						pos: position{line: 362, col: 5, offset: 9087},
+						run: (*parser).callonMapInit9,
+						expr: &seqExpr{
+							pos: position{line: 362, col: 5, offset: 9087},
+							exprs: []any{
+								&litMatcher{
+									pos:        position{line: 362, col: 5, offset: 9087},
+									val:        "[",
+									ignoreCase: false,
+									want:       "\"[\"",
+								},
+								&ruleRefExpr{
+									pos:  position{line: 362, col: 9, offset: 9091},
+									name: "_",
+								},
+								&labeledExpr{
+									pos:   position{line: 362, col: 11, offset: 9093},
+									label: "first",
+									expr: &ruleRefExpr{
+										pos:  position{line: 362, col: 17, offset: 9099},
+										name: "MapEntry",
+									},
+								},
+								&labeledExpr{
+									pos:   position{line: 362, col: 26, offset: 9108},
+									label: "rest",
+									expr: &zeroOrMoreExpr{
+										pos: position{line: 362, col: 31, offset: 9113},
+										expr: &seqExpr{
+											pos: position{line: 362, col: 32, offset: 9114},
+											exprs: []any{
+												&ruleRefExpr{
+													pos:  position{line: 362, col: 32, offset: 9114},
+													name: "_",
+												},
+												&litMatcher{
+													pos:        position{line: 362, col: 34, offset: 9116},
+													val:        ",",
+													ignoreCase: false,
+													want:       "\",\"",
+												},
+												&ruleRefExpr{
+													pos:  position{line: 362, col: 38, offset: 9120},
+													name: "_",
+												},
+												&ruleRefExpr{
+													pos:  position{line: 362, col: 40, offset: 9122},
+													name: "MapEntry",
+												},
+											},
+										},
+									},
+								},
+								&ruleRefExpr{
+									pos:  position{line: 362, col: 51, offset: 9133},
+									name: "_",
+								},
+								&litMatcher{
+									pos:        position{line: 362, col: 53, offset: 9135},
+									val:        "]",
+									ignoreCase: false,
+									want:       "\"]\"",
+								},
+							},
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "MapEntry",
+			pos:  position{line: 378, col: 1, offset: 9498},
+			expr: &actionExpr{
+				pos: position{line: 378, col: 12, offset: 9509},
+				run: (*parser).callonMapEntry1,
+				expr: &seqExpr{
+					pos: position{line: 378, col: 12, offset: 9509},
+					exprs: []any{
+						&labeledExpr{
+							pos:   position{line: 378, col: 12, offset: 9509},
+							label: "key",
+							expr: &ruleRefExpr{
+								pos:  position{line: 378, col: 16, offset: 9513},
+								name: "Expr",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 378, col: 21, offset: 9518},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 378, col: 23, offset: 9520},
+							val:        ":",
+							ignoreCase: false,
+							want:       "\":\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 378, col: 27, offset: 9524},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 378, col: 29, offset: 9526},
+							label: "value",
+							expr: &ruleRefExpr{
+								pos:  position{line: 378, col: 35, offset: 9532},
+								name: "Expr",
+							},
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Parens",
+			pos:  position{line: 393, col: 1, offset: 9760},
+			expr: &actionExpr{
+				pos: position{line: 393, col: 10, offset: 9769},
+				run: (*parser).callonParens1,
+				expr: &seqExpr{
+					pos: position{line: 393, col: 10, offset: 9769},
+					exprs: []any{
+						&litMatcher{
+							pos:        position{line: 393, col: 10, offset: 9769},
+							val:        "(",
+							ignoreCase: false,
+							want:       "\"(\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 393, col: 14, offset: 9773},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 393, col: 16, offset: 9775},
+							label: "expr",
+							expr: &ruleRefExpr{
+								pos:  position{line: 393, col: 21, offset: 9780},
+								name: "Expr",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 393, col: 26, offset: 9785},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 393, col: 28, offset: 9787},
+							val:        ")",
+							ignoreCase: false,
+							want:       "\")\"",
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Emit",
+			pos:  position{line: 397, col: 1, offset: 9817},
+			expr: &actionExpr{
+				pos: position{line: 397, col: 8, offset: 9824},
+				run: (*parser).callonEmit1,
+				expr: &seqExpr{
+					pos: position{line: 397, col: 8, offset: 9824},
+					exprs: []any{
+						&litMatcher{
+							pos:        position{line: 397, col: 8, offset: 9824},
+							val:        "emit",
+							ignoreCase: false,
+							want:       "\"emit\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 397, col: 15, offset: 9831},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 397, col: 17, offset: 9833},
+							val:        "(",
+							ignoreCase: false,
+							want:       "\"(\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 397, col: 21, offset: 9837},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 397, col: 23, offset: 9839},
+							label: "expr",
+							expr: &ruleRefExpr{
+								pos:  position{line: 397, col: 28, offset: 9844},
+								name: "Expr",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 397, col: 33, offset: 9849},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 397, col: 35, offset: 9851},
+							val:        ")",
+							ignoreCase: false,
+							want:       "\")\"",
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Doc",
+			pos:  position{line: 407, col: 1, offset: 9990},
+			expr: &actionExpr{
+				pos: position{line: 407, col: 7, offset: 9996},
+				run: (*parser).callonDoc1,
+				expr: &seqExpr{
+					pos: position{line: 407, col: 7, offset: 9996},
+					exprs: []any{
+						&litMatcher{
+							pos:        position{line: 407, col: 7, offset: 9996},
+							val:        "doc",
+							ignoreCase: false,
+							want:       "\"doc\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 407, col: 13, offset: 10002},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 407, col: 15, offset: 10004},
+							val:        "[",
+							ignoreCase: false,
+							want:       "\"[\"",
+						},
+						&ruleRefExpr{
+							pos:  position{line: 407, col: 19, offset: 10008},
+							name: "_",
+						},
+						&labeledExpr{
+							pos:   position{line: 407, col: 21, offset: 10010},
+							label: "key",
+							expr: &ruleRefExpr{
+								pos:  position{line: 407, col: 25, offset: 10014},
+								name: "Expr",
+							},
+						},
+						&ruleRefExpr{
+							pos:  position{line: 407, col: 30, offset: 10019},
+							name: "_",
+						},
+						&litMatcher{
+							pos:        position{line: 407, col: 32, offset: 10021},
+							val:        "]",
+							ignoreCase: false,
+							want:       "\"]\"",
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "String",
+			pos:  position{line: 417, col: 1, offset: 10163},
+			expr: &actionExpr{
+				pos: position{line: 417, col: 10, offset: 10172},
+				run: (*parser).callonString1,
+				expr: &choiceExpr{
+					pos: position{line: 417, col: 11, offset: 10173},
+					alternatives: []any{
+						&seqExpr{
+							pos: position{line: 417, col: 11, offset: 10173},
+							exprs: []any{
+								&litMatcher{
+									pos:        position{line: 417, col: 11, offset: 10173},
+									val:        "'",
+									ignoreCase: false,
+									want:       "\"'\"",
+								},
+								&zeroOrMoreExpr{
+									pos: position{line: 417, col: 16, offset: 10178},
+									expr: &choiceExpr{
+										pos: position{line: 417, col: 17, offset: 10179},
+										alternatives: []any{
+											&seqExpr{
+												pos: position{line: 417, col: 17, offset: 10179},
+												exprs: []any{
+													&litMatcher{
+														pos:        position{line: 417, col: 17, offset: 10179},
+														val:        "\\",
+														ignoreCase: false,
+														want:       "\"\\\\\"",
+													},
+													&anyMatcher{
+														line: 417, col: 22, offset: 10184,
+													},
+												},
+											},
+											&charClassMatcher{
+												pos:        position{line: 417, col: 26, offset: 10188},
+												val:        "[^'\\\\]",
+												chars:      []rune{'\'', '\\'},
+												ignoreCase: false,
+												inverted:   true,
+											},
+										},
+									},
+								},
+								&litMatcher{
+									pos:        position{line: 417, col: 35, offset: 10197},
+									val:        "'",
+									ignoreCase: false,
+									want:       "\"'\"",
+								},
+							},
+						},
+						&seqExpr{
+							pos: position{line: 417, col: 42, offset: 10204},
+							exprs: []any{
+								&litMatcher{
+									pos:        position{line: 417, col: 42, offset: 10204},
+									val:        "\"",
+									ignoreCase: false,
+									want:       "\"\\\"\"",
+								},
+								&zeroOrMoreExpr{
+									pos: position{line: 417, col: 46, offset: 10208},
+									expr: &choiceExpr{
+										pos: position{line: 417, col: 47, offset: 10209},
+										alternatives: []any{
+											&seqExpr{
+												pos: position{line: 417, col: 47, offset: 10209},
+												exprs: []any{
+													&litMatcher{
+														pos:        position{line: 417, col: 47, offset: 10209},
+														val:        "\\",
+														ignoreCase: false,
+														want:       "\"\\\\\"",
+													},
+													&anyMatcher{
+														line: 417, col: 52, offset: 10214,
+													},
+												},
+											},
+											&charClassMatcher{
+												pos:        position{line: 417, col: 56, offset: 10218},
+												val:        "[^\"\\\\]",
+												chars:      []rune{'"', '\\'},
+												ignoreCase: false,
+												inverted:   true,
+											},
+										},
+									},
+								},
+								&litMatcher{
+									pos:        position{line: 417, col: 65, offset: 10227},
+									val:        "\"",
+									ignoreCase: false,
+									want:       "\"\\\"\"",
+								},
+							},
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Number",
+			pos:  position{line: 423, col: 1, offset: 10346},
+			expr: &actionExpr{
+				pos: position{line: 423, col: 10, offset: 10355},
+				run: (*parser).callonNumber1,
+				expr: &seqExpr{
+					pos: position{line: 423, col: 10, offset: 10355},
+					exprs: []any{
+						&oneOrMoreExpr{
+							pos: position{line: 423, col: 10, offset: 10355},
+							expr: &charClassMatcher{
+								pos:        position{line: 423, col: 10, offset: 10355},
+								val:        "[0-9]",
+								ranges:     []rune{'0', '9'},
+								ignoreCase: false,
+								inverted:   false,
+							},
+						},
+						&zeroOrOneExpr{
+							pos: position{line: 423, col: 17, offset: 10362},
+							expr: &seqExpr{
+								pos: position{line: 423, col: 18, offset: 10363},
+								exprs: []any{
+									&litMatcher{
+										pos:        position{line: 423, col: 18, offset: 10363},
+										val:        ".",
+										ignoreCase: false,
+										want:       "\".\"",
+									},
+									&oneOrMoreExpr{
+										pos: position{line: 423, col: 22, offset: 10367},
+										expr: &charClassMatcher{
+											pos:        position{line: 423, col: 22, offset: 10367},
+											val:        "[0-9]",
+											ranges:     []rune{'0', '9'},
+											ignoreCase: false,
+											inverted:   false,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Boolean",
+			pos:  position{line: 440, col: 1, offset: 10754},
+			expr: &actionExpr{
+				pos: position{line: 440, col: 11, offset: 10764},
+				run: (*parser).callonBoolean1,
+				expr: &seqExpr{
+					pos: position{line: 440, col: 11, offset: 10764},
+					exprs: []any{
+						&choiceExpr{
+							pos: position{line: 440, col: 12, offset: 10765},
+							alternatives: []any{
+								&litMatcher{
+									pos:        position{line: 440, col: 12, offset: 10765},
+									val:        "true",
+									ignoreCase: false,
+									want:       "\"true\"",
+								},
+								&litMatcher{
+									pos:        position{line: 440, col: 21, offset: 10774},
+									val:        "false",
+									ignoreCase: false,
+									want:       "\"false\"",
+								},
+							},
+						},
+						&notExpr{
+							pos: position{line: 440, col: 30, offset: 10783},
+							expr: &ruleRefExpr{
+								pos:  position{line: 440, col: 31, offset: 10784},
+								name: "IdentifierChar",
+							},
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Null",
+			pos:  position{line: 444, col: 1, offset: 10866},
+			expr: &actionExpr{
+				pos: position{line: 444, col: 8, offset: 10873},
+				run: (*parser).callonNull1,
+				expr: &seqExpr{
+					pos: position{line: 444, col: 8, offset: 10873},
+					exprs: []any{
+						&litMatcher{
+							pos:        position{line: 444, col: 8, offset: 10873},
+							val:        "null",
+							ignoreCase: false,
+							want:       "\"null\"",
+						},
+						&notExpr{
+							pos: position{line: 444, col: 15, offset: 10880},
+							expr: &ruleRefExpr{
+								pos:  position{line: 444, col: 16, offset: 10881},
+								name: "IdentifierChar",
+							},
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Variable",
+			pos:  position{line: 448, col: 1, offset: 10942},
+			expr: &actionExpr{
+				pos: position{line: 448, col: 12, offset: 10953},
+				run: (*parser).callonVariable1,
+				expr: &seqExpr{
+					pos: position{line: 448, col: 12, offset: 10953},
+					exprs: []any{
+						&notExpr{
+							pos: position{line: 448, col: 12, offset: 10953},
+							expr: &ruleRefExpr{
+								pos:  position{line: 448, col: 13, offset: 10954},
+								name: "Keyword",
+							},
+						},
+						&labeledExpr{
+							pos:   position{line: 448, col: 21, offset: 10962},
+							label: "name",
+							expr: &ruleRefExpr{
+								pos:  position{line: 448, col: 26, offset: 10967},
+								name: "Identifier",
+							},
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Identifier",
+			pos:  position{line: 458, col: 1, offset: 11147},
+			expr: &actionExpr{
+				pos: position{line: 458, col: 14, offset: 11160},
+				run: (*parser).callonIdentifier1,
+				expr: &seqExpr{
+					pos: position{line: 458, col: 14, offset: 11160},
+					exprs: []any{
+						&charClassMatcher{
+							pos:        position{line: 458, col: 14, offset: 11160},
+							val:        "[a-zA-Z_]",
 							chars:      []rune{'_'},
-							ranges:     []rune{'a', 'z', 'A', 'Z', '0', '9'},
+							ranges:     []rune{'a', 'z', 'A', 'Z'},
 							ignoreCase: false,
 							inverted:   false,
 						},
+						&zeroOrMoreExpr{
+							pos: position{line: 458, col: 24, offset: 11170},
+							expr: &ruleRefExpr{
+								pos:  position{line: 458, col: 24, offset: 11170},
+								name: "IdentifierChar",
+							},
+						},
 					},
 				},
 			},
 			leader:        false,
 			leftRecursive: false,
 		},
+		{
+			name: "IdentifierChar",
+			pos:  position{line: 462, col: 1, offset: 11221},
+			expr: &charClassMatcher{
+				pos:        position{line: 462, col: 18, offset: 11238},
+				val:        "[a-zA-Z0-9_]",
+				chars:      []rune{'_'},
+				ranges:     []rune{'a', 'z', 'A', 'Z', '0', '9'},
+				ignoreCase: false,
+				inverted:   false,
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
 		{
 			name: "UrlEncoder",
-			pos:  position{line: 125, col: 1, offset: 2575},
+			pos:  position{line: 464, col: 1, offset: 11252},
 			expr: &actionExpr{
-				pos: position{line: 125, col: 14, offset: 2588},
+				pos: position{line: 464, col: 14, offset: 11265},
 				run: (*parser).callonUrlEncoder1,
 				expr: &seqExpr{
-					pos: position{line: 125, col: 14, offset: 2588},
+					pos: position{line: 464, col: 14, offset: 11265},
 					exprs: []any{
 						&litMatcher{
-							pos:        position{line: 125, col: 14, offset: 2588},
+							pos:        position{line: 464, col: 14, offset: 11265},
 							val:        "URLEncoder.encode",
 							ignoreCase: false,
 							want:       "\"URLEncoder.encode\"",
 						},
 						&litMatcher{
-							pos:        position{line: 125, col: 34, offset: 2608},
+							pos:        position{line: 464, col: 34, offset: 11285},
 							val:        "(",
 							ignoreCase: false,
 							want:       "\"(\"",
 						},
 						&labeledExpr{
-							pos:   position{line: 125, col: 38, offset: 2612},
+							pos:   position{line: 464, col: 38, offset: 11289},
 							label: "expr",
 							expr: &ruleRefExpr{
-								pos:  position{line: 125, col: 43, offset: 2617},
+								pos:  position{line: 464, col: 43, offset: 11294},
 								name: "Expr",
 							},
 						},
 						&litMatcher{
-							pos:        position{line: 125, col: 48, offset: 2622},
+							pos:        position{line: 464, col: 48, offset: 11299},
 							val:        ")",
 							ignoreCase: false,
 							want:       "\")\"",
@@ -465,15 +3095,93 @@ var g = &grammar{
 		{
 			name:        "_",
 			displayName: "\"whitespace\"",
-			pos:         position{line: 135, col: 1, offset: 2766},
+			pos:         position{line: 474, col: 1, offset: 11443},
 			expr: &zeroOrMoreExpr{
-				pos: position{line: 135, col: 19, offset: 2784},
-				expr: &charClassMatcher{
-					pos:        position{line: 135, col: 19, offset: 2784},
-					val:        "[ \\n\\t\\r]",
-					chars:      []rune{' ', '\n', '\t', '\r'},
-					ignoreCase: false,
-					inverted:   false,
+				pos: position{line: 474, col: 19, offset: 11461},
+				expr: &choiceExpr{
+					pos: position{line: 474, col: 20, offset: 11462},
+					alternatives: []any{
+						&charClassMatcher{
+							pos:        position{line: 474, col: 20, offset: 11462},
+							val:        "[ \\n\\t\\r]",
+							chars:      []rune{' ', '\n', '\t', '\r'},
+							ignoreCase: false,
+							inverted:   false,
+						},
+						&ruleRefExpr{
+							pos:  position{line: 474, col: 32, offset: 11474},
+							name: "Comment",
+						},
+					},
+				},
+			},
+			leader:        false,
+			leftRecursive: false,
+		},
+		{
+			name: "Comment",
+			pos:  position{line: 476, col: 1, offset: 11485},
+			expr: &choiceExpr{
+				pos: position{line: 476, col: 11, offset: 11495},
+				alternatives: []any{
+					&seqExpr{
+						pos: position{line: 476, col: 11, offset: 11495},
+						exprs: []any{
+							&litMatcher{
+								pos:        position{line: 476, col: 11, offset: 11495},
+								val:        "//",
+								ignoreCase: false,
+								want:       "\"//\"",
+							},
+							&zeroOrMoreExpr{
+								pos: position{line: 476, col: 16, offset: 11500},
+								expr: &charClassMatcher{
+									pos:        position{line: 476, col: 16, offset: 11500},
+									val:        "[^\\n]",
+									chars:      []rune{'\n'},
+									ignoreCase: false,
+									inverted:   true,
+								},
+							},
+						},
+					},
+					&seqExpr{
+						pos: position{line: 476, col: 25, offset: 11509},
+						exprs: []any{
+							&litMatcher{
+								pos:        position{line: 476, col: 25, offset: 11509},
+								val:        "/*",
+								ignoreCase: false,
+								want:       "\"/*\"",
+							},
+							&zeroOrMoreExpr{
+								pos: position{line: 476, col: 30, offset: 11514},
+								expr: &seqExpr{
+									pos: position{line: 476, col: 31, offset: 11515},
+									exprs: []any{
+										&notExpr{
+											pos: position{line: 476, col: 31, offset: 11515},
+											expr: &litMatcher{
+												pos:        position{line: 476, col: 32, offset: 11516},
+												val:        "*/",
+												ignoreCase: false,
+												want:       "\"*/\"",
+											},
+										},
+										&anyMatcher{
+											line: 476, col: 37, offset: 11521,
+										},
+									},
+								},
+							},
+							&litMatcher{
+								pos:        position{line: 476, col: 41, offset: 11525},
+								val:        "*/",
+								ignoreCase: false,
+								want:       "\"*/\"",
+							},
+						},
+					},
 				},
 			},
 			leader:        false,
@@ -481,11 +3189,11 @@ var g = &grammar{
 		},
 		{
 			name: "EOF",
-			pos:  position{line: 137, col: 1, offset: 2796},
+			pos:  position{line: 478, col: 1, offset: 11531},
 			expr: &notExpr{
-				pos: position{line: 138, col: 5, offset: 2805},
+				pos: position{line: 479, col: 5, offset: 11539},
 				expr: &anyMatcher{
-					line: 138, col: 6, offset: 2806,
+					line: 479, col: 6, offset: 11540,
 				},
 			},
 			leader:        false,
@@ -494,49 +3202,462 @@ var g = &grammar{
 	},
 }
 
-func (c *current) onExpr14(expr any) (any, error) {
-	return expr, nil
+func (c *current) onScript1(stmts any) (any, error) {
+	return newScript(stmts)
+}
+
+func (p *parser) callonScript1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onScript1(stack["stmts"])
+}
+
+func (c *current) onStatements1(stmts any) (any, error) {
+	return stmts, nil
+}
+
+func (p *parser) callonStatements1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onStatements1(stack["stmts"])
+}
+
+func (c *current) onStatement10(stmt any) (any, error) {
+	return stmt, nil
+}
+
+func (p *parser) callonStatement10() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onStatement10(stack["stmt"])
+}
+
+func (c *current) onStatement15(stmt any) (any, error) {
+	return stmt, nil
+}
+
+func (p *parser) callonStatement15() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onStatement15(stack["stmt"])
+}
+
+func (c *current) onBlock1(stmts any) (any, error) {
+
+	stmtsVal, err := expectStatements(stmts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BlockStatement{Statements: stmtsVal}, nil
+}
+
+func (p *parser) callonBlock1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onBlock1(stack["stmts"])
+}
+
+func (c *current) onIf1(cond, then, els any) (any, error) {
+
+	condVal, err := ExpectExpr(cond)
+	if err != nil {
+		return nil, err
+	}
+
+	thenVal, err := ExpectStatement(then)
+	if err != nil {
+		return nil, err
+	}
+
+	var elseVal Statement
+	if els != nil {
+		if elseVal, err = ExpectStatement(els.([]any)[4]); err != nil {
+			return nil, err
+		}
+	}
+
+	return &IfStatement{Position: newPosition(c.pos), Cond: condVal, Then: thenVal, Else: elseVal}, nil
+}
+
+func (p *parser) callonIf1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onIf1(stack["cond"], stack["then"], stack["els"])
+}
+
+func (c *current) onFor1(init, cond, update, body any) (any, error) {
+
+	initVal, err := ExpectStatement(init)
+	if err != nil {
+		return nil, err
+	}
+
+	var condVal Expr
+	if cond != nil {
+		if condVal, err = ExpectExpr(cond); err != nil {
+			return nil, err
+		}
+	}
+
+	updateVal, err := ExpectStatement(update)
+	if err != nil {
+		return nil, err
+	}
+
+	bodyVal, err := ExpectStatement(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ForStatement{Position: newPosition(c.pos), Init: initVal, Cond: condVal, Update: updateVal, Body: bodyVal}, nil
+}
+
+func (p *parser) callonFor1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onFor1(stack["init"], stack["cond"], stack["update"], stack["body"])
+}
+
+func (c *current) onForEach2(typ, name, collection, body any) (any, error) {
+	return newForEachStatement(newPosition(c.pos), typ, name, collection, body)
+}
+
+func (p *parser) callonForEach2() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onForEach2(stack["typ"], stack["name"], stack["collection"], stack["body"])
+}
+
+func (c *current) onForEach23(name, collection, body any) (any, error) {
+	return newForEachStatement(newPosition(c.pos), "def", name, collection, body)
+}
+
+func (p *parser) callonForEach23() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onForEach23(stack["name"], stack["collection"], stack["body"])
+}
+
+func (c *current) onWhile1(cond, body any) (any, error) {
+
+	condVal, err := ExpectExpr(cond)
+	if err != nil {
+		return nil, err
+	}
+
+	bodyVal, err := ExpectStatement(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ForStatement{Position: newPosition(c.pos), Cond: condVal, Body: bodyVal}, nil
+}
+
+func (p *parser) callonWhile1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onWhile1(stack["cond"], stack["body"])
+}
+
+func (c *current) onReturn1(expr any) (any, error) {
+
+	if expr == nil {
+		return &ReturnStatement{}, nil
+	}
+
+	exprVal, err := ExpectExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReturnStatement{Expr: exprVal}, nil
+}
+
+func (p *parser) callonReturn1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onReturn1(stack["expr"])
+}
+
+func (c *current) onBreak1() (any, error) {
+	return &LoopControlStatement{Break: true}, nil
+}
+
+func (p *parser) callonBreak1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onBreak1()
+}
+
+func (c *current) onContinue1() (any, error) {
+	return &LoopControlStatement{Break: false}, nil
+}
+
+func (p *parser) callonContinue1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onContinue1()
+}
+
+func (c *current) onDeclaration1(typ, name, init any) (any, error) {
+
+	declaration := &DeclarationStatement{Position: newPosition(c.pos), Type: typ.(string), Name: name.(string)}
+
+	if init != nil {
+		exprVal, err := ExpectExpr(init.([]any)[4])
+		if err != nil {
+			return nil, err
+		}
+		declaration.Expr = exprVal
+	}
+
+	return declaration, nil
+}
+
+func (p *parser) callonDeclaration1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onDeclaration1(stack["typ"], stack["name"], stack["init"])
+}
+
+func (c *current) onAssignment2(target, op any) (any, error) {
+
+	return newAssignmentStatement(newPosition(c.pos), target, string(op.([]byte)), nil)
+}
+
+func (p *parser) callonAssignment2() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onAssignment2(stack["target"], stack["op"])
+}
+
+func (c *current) onAssignment11(target, op, expr any) (any, error) {
+
+	return newAssignmentStatement(newPosition(c.pos), target, op.(string), expr)
+}
+
+func (p *parser) callonAssignment11() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onAssignment11(stack["target"], stack["op"], stack["expr"])
+}
+
+func (c *current) onAssignmentOp1() (any, error) {
+	return string(c.text), nil
+}
+
+func (p *parser) callonAssignmentOp1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onAssignmentOp1()
+}
+
+func (c *current) onExprStatement1(expr any) (any, error) {
+
+	exprVal, err := ExpectExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExprStatement{Expr: exprVal}, nil
+}
+
+func (p *parser) callonExprStatement1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onExprStatement1(stack["expr"])
+}
+
+func (c *current) onType1() (any, error) {
+	return string(c.text), nil
 }
 
-func (p *parser) callonExpr14() (any, error) {
+func (p *parser) callonType1() (any, error) {
 	stack := p.vstack[len(p.vstack)-1]
 	_ = stack
-	return p.cur.onExpr14(stack["expr"])
+	return p.cur.onType1()
 }
 
-func (c *current) onEmit1(expr any) (any, error) {
+func (c *current) onConditional2(cond, then, els any) (any, error) {
+
+	condVal, err := ExpectExpr(cond)
+	if err != nil {
+		return nil, err
+	}
+
+	thenVal, err := ExpectExpr(then)
+	if err != nil {
+		return nil, err
+	}
+
+	elseVal, err := ExpectExpr(els)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConditionalExpr{Position: newPosition(c.pos), Cond: condVal, Then: thenVal, Else: elseVal}, nil
+}
+
+func (p *parser) callonConditional2() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onConditional2(stack["cond"], stack["then"], stack["els"])
+}
+
+func (c *current) onLogicalOr2(left, op, right any) (any, error) {
+	return newInfixOpExpr(newPosition(c.pos), left, op, right)
+}
+
+func (p *parser) callonLogicalOr2() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onLogicalOr2(stack["left"], stack["op"], stack["right"])
+}
+
+func (c *current) onLogicalAnd2(left, op, right any) (any, error) {
+	return newInfixOpExpr(newPosition(c.pos), left, op, right)
+}
+
+func (p *parser) callonLogicalAnd2() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onLogicalAnd2(stack["left"], stack["op"], stack["right"])
+}
+
+func (c *current) onEquality2(left, op, right any) (any, error) {
+	return newInfixOpExpr(newPosition(c.pos), left, op, right)
+}
+
+func (p *parser) callonEquality2() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onEquality2(stack["left"], stack["op"], stack["right"])
+}
+
+func (c *current) onEqualityOp1() (any, error) {
+	return string(c.text), nil
+}
+
+func (p *parser) callonEqualityOp1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onEqualityOp1()
+}
+
+func (c *current) onRelational2(left, op, right any) (any, error) {
+	return newInfixOpExpr(newPosition(c.pos), left, op, right)
+}
+
+func (p *parser) callonRelational2() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onRelational2(stack["left"], stack["op"], stack["right"])
+}
+
+func (c *current) onRelationalOp1() (any, error) {
+	return string(c.text), nil
+}
+
+func (p *parser) callonRelationalOp1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onRelationalOp1()
+}
+
+func (c *current) onAdditive2(left, op, right any) (any, error) {
+	return newInfixOpExpr(newPosition(c.pos), left, op, right)
+}
+
+func (p *parser) callonAdditive2() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onAdditive2(stack["left"], stack["op"], stack["right"])
+}
+
+func (c *current) onAdditiveOp1() (any, error) {
+	return string(c.text), nil
+}
+
+func (p *parser) callonAdditiveOp1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onAdditiveOp1()
+}
+
+func (c *current) onMultiplicative2(left, op, right any) (any, error) {
+	return newInfixOpExpr(newPosition(c.pos), left, op, right)
+}
+
+func (p *parser) callonMultiplicative2() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onMultiplicative2(stack["left"], stack["op"], stack["right"])
+}
+
+func (c *current) onMultiplicativeOp1() (any, error) {
+	return string(c.text), nil
+}
+
+func (p *parser) callonMultiplicativeOp1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onMultiplicativeOp1()
+}
+
+func (c *current) onUnary2(typ, expr any) (any, error) {
 
 	exprVal, err := ExpectExpr(expr)
 	if err != nil {
 		return nil, err
 	}
 
-	return &EmitExpr{Expr: exprVal}, nil
+	return &CastExpr{Position: newPosition(c.pos), Type: typ.(string), Expr: exprVal}, nil
 }
 
-func (p *parser) callonEmit1() (any, error) {
+func (p *parser) callonUnary2() (any, error) {
 	stack := p.vstack[len(p.vstack)-1]
 	_ = stack
-	return p.cur.onEmit1(stack["expr"])
+	return p.cur.onUnary2(stack["typ"], stack["expr"])
 }
 
-func (c *current) onDoc1(key any) (any, error) {
+func (c *current) onUnary13(op, expr any) (any, error) {
 
-	exprVal, err := ExpectExpr(key)
+	exprVal, err := ExpectExpr(expr)
 	if err != nil {
 		return nil, err
 	}
 
-	return &DocExpr{FieldName: exprVal}, nil
+	return &PrefixOpExpr{Position: newPosition(c.pos), Op: op.(string), Expr: exprVal}, nil
 }
 
-func (p *parser) callonDoc1() (any, error) {
+func (p *parser) callonUnary13() (any, error) {
 	stack := p.vstack[len(p.vstack)-1]
 	_ = stack
-	return p.cur.onDoc1(stack["key"])
+	return p.cur.onUnary13(stack["op"], stack["expr"])
+}
+
+func (c *current) onUnaryOp1() (any, error) {
+	return string(c.text), nil
+}
+
+func (p *parser) callonUnaryOp1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onUnaryOp1()
+}
+
+func (c *current) onPostfix8(expr any) (any, error) {
+	return expr, nil
+}
+
+func (p *parser) callonPostfix8() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onPostfix8(stack["expr"])
 }
 
-func (c *current) onAccessor1(expr, field any) (any, error) {
+func (c *current) onAccessor1(expr, op, field any) (any, error) {
 
 	exprVal, err := ExpectExpr(expr)
 	if err != nil {
@@ -548,16 +3669,26 @@ func (c *current) onAccessor1(expr, field any) (any, error) {
 		return nil, err
 	}
 
-	return &AccessorExpr{Position: c.pos.String(), Expr: exprVal, PropertyName: strVal}, nil
+	return &AccessorExpr{Position: newPosition(c.pos), Expr: exprVal, PropertyName: strVal, NullSafe: op.(bool)}, nil
 }
 
 func (p *parser) callonAccessor1() (any, error) {
 	stack := p.vstack[len(p.vstack)-1]
 	_ = stack
-	return p.cur.onAccessor1(stack["expr"], stack["field"])
+	return p.cur.onAccessor1(stack["expr"], stack["op"], stack["field"])
+}
+
+func (c *current) onNavigationOp1(op any) (any, error) {
+	return string(op.([]byte)) == "?.", nil
+}
+
+func (p *parser) callonNavigationOp1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onNavigationOp1(stack["op"])
 }
 
-func (c *current) onMethodCall1(expr, method, args any) (any, error) {
+func (c *current) onMethodCall1(expr, op, method, args any) (any, error) {
 
 	exprVal, err := ExpectExpr(expr)
 	if err != nil {
@@ -589,79 +3720,267 @@ func (c *current) onMethodCall1(expr, method, args any) (any, error) {
 		return nil, fmt.Errorf("internal parser error. '%T' is not valid method argument", args)
 	}
 
-	for _, arg := range argsVal {
-		argVal, err := ExpectExpr(arg)
-		if err != nil {
-			return nil, err
+	return &MethodCallExpr{Position: newPosition(c.pos), Expr: exprVal, MethodName: strVal, Args: argsVal, NullSafe: op.(bool)}, nil
+}
+
+func (p *parser) callonMethodCall1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onMethodCall1(stack["expr"], stack["op"], stack["method"], stack["args"])
+}
+
+func (c *current) onIndex1(expr, index any) (any, error) {
+
+	exprVal, err := ExpectExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	indexVal, err := ExpectExpr(index)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IndexExpr{Position: newPosition(c.pos), Expr: exprVal, Index: indexVal}, nil
+}
+
+func (p *parser) callonIndex1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onIndex1(stack["expr"], stack["index"])
+}
+
+func (c *current) onArgList1(first, rest any) (any, error) {
+
+	args := []any{first}
+	for _, next := range rest.([]any) {
+		args = append(args, next.([]any)[3])
+	}
+	return args, nil
+}
+
+func (p *parser) callonArgList1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onArgList1(stack["first"], stack["rest"])
+}
+
+func (c *current) onPrimary24(expr any) (any, error) {
+	return expr, nil
+}
+
+func (p *parser) callonPrimary24() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onPrimary24(stack["expr"])
+}
+
+func (c *current) onNew1(class any) (any, error) {
+	return &NewExpr{Position: newPosition(c.pos), ClassName: class.(string)}, nil
+}
+
+func (p *parser) callonNew1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onNew1(stack["class"])
+}
+
+func (c *current) onListInit1(args any) (any, error) {
+
+	items := []Expr{}
+	if args != nil {
+		for _, arg := range args.([]any) {
+			argVal, err := ExpectExpr(arg)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, argVal)
 		}
-		argsVal = append(argsVal, argVal)
 	}
 
-	return &MethodCallExpr{Position: c.pos.String(), Expr: exprVal, MethodName: strVal, Args: argsVal}, nil
+	return &ListExpr{Items: items}, nil
 }
 
-func (p *parser) callonMethodCall1() (any, error) {
+func (p *parser) callonListInit1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onListInit1(stack["args"])
+}
+
+func (c *current) onMapInit2() (any, error) {
+	return &MapExpr{}, nil
+}
+
+func (p *parser) callonMapInit2() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onMapInit2()
+}
+
+func (c *current) onMapInit9(first, rest any) (any, error) {
+
+	result := &MapExpr{}
+	entries := []any{first}
+	for _, next := range rest.([]any) {
+		entries = append(entries, next.([]any)[3])
+	}
+	for _, entry := range entries {
+		pair := entry.([]Expr)
+		result.Keys = append(result.Keys, pair[0])
+		result.Values = append(result.Values, pair[1])
+	}
+
+	return result, nil
+}
+
+func (p *parser) callonMapInit9() (any, error) {
 	stack := p.vstack[len(p.vstack)-1]
 	_ = stack
-	return p.cur.onMethodCall1(stack["expr"], stack["method"], stack["args"])
+	return p.cur.onMapInit9(stack["first"], stack["rest"])
 }
 
-func (c *current) onOpExpr1(left, op, right any) (any, error) {
-	leftVal, err := ExpectExpr(left)
+func (c *current) onMapEntry1(key, value any) (any, error) {
+
+	keyVal, err := ExpectExpr(key)
 	if err != nil {
 		return nil, err
 	}
 
-	rightVal, err := ExpectExpr(right)
+	valueVal, err := ExpectExpr(value)
 	if err != nil {
 		return nil, err
 	}
 
-	opVal, err := ExpectString(op)
+	return []Expr{keyVal, valueVal}, nil
+}
+
+func (p *parser) callonMapEntry1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onMapEntry1(stack["key"], stack["value"])
+}
+
+func (c *current) onParens1(expr any) (any, error) {
+	return expr, nil
+}
+
+func (p *parser) callonParens1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onParens1(stack["expr"])
+}
+
+func (c *current) onEmit1(expr any) (any, error) {
+
+	exprVal, err := ExpectExpr(expr)
 	if err != nil {
 		return nil, err
 	}
 
-	return &InfixOpExpr{Position: c.pos.String(), Left: leftVal, Op: opVal, Right: rightVal}, nil
+	return &EmitExpr{Expr: exprVal}, nil
 }
 
-func (p *parser) callonOpExpr1() (any, error) {
+func (p *parser) callonEmit1() (any, error) {
 	stack := p.vstack[len(p.vstack)-1]
 	_ = stack
-	return p.cur.onOpExpr1(stack["left"], stack["op"], stack["right"])
+	return p.cur.onEmit1(stack["expr"])
 }
 
-func (c *current) onOp1(op any) (any, error) {
-	return string(c.text), nil
+func (c *current) onDoc1(key any) (any, error) {
+
+	exprVal, err := ExpectExpr(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DocExpr{FieldName: exprVal}, nil
 }
 
-func (p *parser) callonOp1() (any, error) {
+func (p *parser) callonDoc1() (any, error) {
 	stack := p.vstack[len(p.vstack)-1]
 	_ = stack
-	return p.cur.onOp1(stack["op"])
+	return p.cur.onDoc1(stack["key"])
 }
 
-func (c *current) onString1(s any) (any, error) {
+func (c *current) onString1() (any, error) {
 
 	strVal := string(c.text)
-	strVal = strings.Trim(strVal, "'")
-	return &LiteralExpr{Value: strVal}, nil
+	return &LiteralExpr{Value: unescapeString(strVal[1 : len(strVal)-1])}, nil
 }
 
 func (p *parser) callonString1() (any, error) {
 	stack := p.vstack[len(p.vstack)-1]
 	_ = stack
-	return p.cur.onString1(stack["s"])
+	return p.cur.onString1()
+}
+
+func (c *current) onNumber1() (any, error) {
+
+	if strings.Contains(string(c.text), ".") {
+		floatVal, err := strconv.ParseFloat(string(c.text), 64)
+		if err != nil {
+			return nil, err
+		}
+		return &LiteralExpr{Value: floatVal}, nil
+	}
+
+	intVal, err := strconv.Atoi(string(c.text))
+	if err != nil {
+		return nil, err
+	}
+	return &LiteralExpr{Value: intVal}, nil
+}
+
+func (p *parser) callonNumber1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onNumber1()
+}
+
+func (c *current) onBoolean1() (any, error) {
+	return &LiteralExpr{Value: string(c.text) == "true"}, nil
+}
+
+func (p *parser) callonBoolean1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onBoolean1()
+}
+
+func (c *current) onNull1() (any, error) {
+	return &LiteralExpr{Value: nil}, nil
+}
+
+func (p *parser) callonNull1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onNull1()
+}
+
+func (c *current) onVariable1(name any) (any, error) {
+
+	strVal, err := ExpectString(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VariableExpr{Position: newPosition(c.pos), Name: strVal}, nil
+}
+
+func (p *parser) callonVariable1() (any, error) {
+	stack := p.vstack[len(p.vstack)-1]
+	_ = stack
+	return p.cur.onVariable1(stack["name"])
 }
 
-func (c *current) onIdentifier1(id any) (any, error) {
+func (c *current) onIdentifier1() (any, error) {
 	return string(c.text), nil
 }
 
 func (p *parser) callonIdentifier1() (any, error) {
 	stack := p.vstack[len(p.vstack)-1]
 	_ = stack
-	return p.cur.onIdentifier1(stack["id"])
+	return p.cur.onIdentifier1()
 }
 
 func (c *current) onUrlEncoder1(expr any) (any, error) {
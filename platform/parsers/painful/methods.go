@@ -0,0 +1,400 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package painful
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// dateMethods are methods of ZonedDateTime, dates stored as strings are parsed first
+var dateMethods = map[string]func(t time.Time) any{
+	"getYear":                 func(t time.Time) any { return t.Year() },
+	"getMonthValue":           func(t time.Time) any { return int(t.Month()) },
+	"getDayOfMonth":           func(t time.Time) any { return t.Day() },
+	"getDayOfYear":            func(t time.Time) any { return t.YearDay() },
+	"getHour":                 func(t time.Time) any { return t.Hour() },
+	"getMinute":               func(t time.Time) any { return t.Minute() },
+	"getSecond":               func(t time.Time) any { return t.Second() },
+	"getNano":                 func(t time.Time) any { return t.Nanosecond() },
+	"toEpochMilli":            func(t time.Time) any { return t.UnixMilli() },
+	"getMillis":               func(t time.Time) any { return t.UnixMilli() },
+	"toEpochSecond":           func(t time.Time) any { return t.Unix() },
+	"formatISO8601":           func(t time.Time) any { return t.Format(time.RFC3339) },
+	"formatISO8601NoTimezone": func(t time.Time) any { return t.Format("2006-01-02T15:04:05") },
+}
+
+// callMethod calls the method on the value. Errors use Java types, as users write Java-like code.
+func callMethod(position Position, target any, name string, args []any) (any, error) {
+
+	if dateMethod, ok := dateMethods[name]; ok && len(args) == 0 {
+		date, err := ExpectDate(target)
+		if err != nil {
+			return nil, errorAt(position, "method [%s] failed to coerce '%v' into a datetime: %v", name, target, err)
+		}
+		return dateMethod(date), nil
+	}
+
+	if target == nil {
+		return nil, exceptionAt(position, nullPointerException, "cannot invoke [%s] on null", name)
+	}
+
+	if name == "toString" && len(args) == 0 {
+		return toJavaString(target), nil
+	}
+
+	switch t := target.(type) {
+	case string:
+		return callStringMethod(position, t, name, args)
+	case *List:
+		if name == "add" && len(args) == 1 {
+			t.Items = append(t.Items, args[0])
+			return true, nil
+		}
+	}
+
+	if list, ok := asList(target); ok {
+		return callListMethod(position, list, name, args)
+	}
+
+	if m, ok := asMap(target); ok {
+		return callMapMethod(position, m, name, args)
+	}
+
+	return nil, unknownMethodError(position, target, name, args)
+}
+
+func unknownMethodError(position Position, target any, name string, args []any) error {
+	return errorAt(position, "unknown call [%s] with [%d] arguments on type [%s]", name, len(args), javaTypeName(target))
+}
+
+func callStringMethod(position Position, s string, name string, args []any) (any, error) {
+
+	stringArg := func(i int) (string, error) {
+		str, ok := args[i].(string)
+		if !ok {
+			return "", exceptionAt(position, classCastException, "cannot cast [%s] to [String]", javaTypeName(args[i]))
+		}
+		return str, nil
+	}
+	intArg := func(i int) (int, error) {
+		n, ok := toInt64(args[i])
+		if !ok {
+			return 0, exceptionAt(position, classCastException, "cannot cast [%s] to [int]", javaTypeName(args[i]))
+		}
+		return int(n), nil
+	}
+
+	switch {
+	case name == "length" && len(args) == 0:
+		return utf8.RuneCountInString(s), nil
+	case name == "isEmpty" && len(args) == 0:
+		return s == "", nil
+	case name == "toLowerCase" && len(args) == 0:
+		return strings.ToLower(s), nil
+	case name == "toUpperCase" && len(args) == 0:
+		return strings.ToUpper(s), nil
+	case name == "trim" && len(args) == 0:
+		return strings.TrimSpace(s), nil
+
+	case name == "substring" && (len(args) == 1 || len(args) == 2):
+		runes := []rune(s)
+		begin, err := intArg(0)
+		if err != nil {
+			return nil, err
+		}
+		end := len(runes)
+		if len(args) == 2 {
+			if end, err = intArg(1); err != nil {
+				return nil, err
+			}
+		}
+		if begin < 0 || end > len(runes) || begin > end {
+			return nil, exceptionAt(position, indexOutOfBoundsException, "begin %d, end %d, length %d", begin, end, len(runes))
+		}
+		return string(runes[begin:end]), nil
+
+	case name == "charAt" && len(args) == 1:
+		runes := []rune(s)
+		index, err := intArg(0)
+		if err != nil {
+			return nil, err
+		}
+		if index < 0 || index >= len(runes) {
+			return nil, exceptionAt(position, indexOutOfBoundsException, "index %d out of bounds for length %d", index, len(runes))
+		}
+		return string(runes[index]), nil
+	}
+
+	if stringArgsCount, ok := stringMethodsWithStringArgs[name]; ok && len(args) == stringArgsCount {
+		arg, err := stringArg(0)
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "contains":
+			return strings.Contains(s, arg), nil
+		case "startsWith":
+			return strings.HasPrefix(s, arg), nil
+		case "endsWith":
+			return strings.HasSuffix(s, arg), nil
+		case "equals":
+			return s == arg, nil
+		case "equalsIgnoreCase":
+			return strings.EqualFold(s, arg), nil
+		case "compareTo":
+			return strings.Compare(s, arg), nil
+		case "indexOf":
+			return runeIndex(s, strings.Index(s, arg)), nil
+		case "lastIndexOf":
+			return runeIndex(s, strings.LastIndex(s, arg)), nil
+		case "splitOnToken":
+			parts := strings.Split(s, arg)
+			result := make([]any, len(parts))
+			for i, part := range parts {
+				result[i] = part
+			}
+			return result, nil
+		case "replace":
+			replacement, err := stringArg(1)
+			if err != nil {
+				return nil, err
+			}
+			return strings.ReplaceAll(s, arg, replacement), nil
+		}
+	}
+
+	return nil, unknownMethodError(position, s, name, args)
+}
+
+var stringMethodsWithStringArgs = map[string]int{
+	"contains":         1,
+	"startsWith":       1,
+	"endsWith":         1,
+	"equals":           1,
+	"equalsIgnoreCase": 1,
+	"compareTo":        1,
+	"indexOf":          1,
+	"lastIndexOf":      1,
+	"splitOnToken":     1,
+	"replace":          2,
+}
+
+// runeIndex converts a byte index to an index of the character
+func runeIndex(s string, byteIndex int) int {
+	if byteIndex < 0 {
+		return byteIndex
+	}
+	return utf8.RuneCountInString(s[:byteIndex])
+}
+
+func callListMethod(position Position, list []any, name string, args []any) (any, error) {
+
+	switch {
+	case name == "size" && len(args) == 0:
+		return len(list), nil
+	case name == "isEmpty" && len(args) == 0:
+		return len(list) == 0, nil
+	case name == "contains" && len(args) == 1:
+		for _, item := range list {
+			if equals(item, args[0]) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case name == "indexOf" && len(args) == 1:
+		for i, item := range list {
+			if equals(item, args[0]) {
+				return i, nil
+			}
+		}
+		return -1, nil
+	case name == "get" && len(args) == 1:
+		index, ok := toInt64(args[0])
+		if !ok {
+			return nil, exceptionAt(position, classCastException, "cannot cast [%s] to [int]", javaTypeName(args[0]))
+		}
+		if index < 0 || index >= int64(len(list)) {
+			return nil, exceptionAt(position, indexOutOfBoundsException, "index %d out of bounds for length %d", index, len(list))
+		}
+		return list[index], nil
+	case name == "add":
+		return nil, errorAt(position, "the list can't be modified")
+	}
+
+	return nil, unknownMethodError(position, list, name, args)
+}
+
+func callMapMethod(position Position, m map[string]any, name string, args []any) (any, error) {
+
+	switch {
+	case name == "size" && len(args) == 0:
+		return len(m), nil
+	case name == "isEmpty" && len(args) == 0:
+		return len(m) == 0, nil
+	case name == "keySet" && len(args) == 0:
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		result := make([]any, len(keys))
+		for i, key := range keys {
+			result[i] = key
+		}
+		return result, nil
+	case name == "containsKey" && len(args) == 1:
+		_, ok := m[toJavaString(args[0])]
+		return ok, nil
+	case name == "get" && len(args) == 1:
+		return m[toJavaString(args[0])], nil
+	case name == "getOrDefault" && len(args) == 2:
+		if val, ok := m[toJavaString(args[0])]; ok {
+			return val, nil
+		}
+		return args[1], nil
+	case name == "put" && len(args) == 2:
+		key := toJavaString(args[0])
+		previous := m[key]
+		m[key] = args[1]
+		return previous, nil
+	case name == "remove" && len(args) == 1:
+		key := toJavaString(args[0])
+		previous := m[key]
+		delete(m, key)
+		return previous, nil
+	}
+
+	return nil, unknownMethodError(position, m, name, args)
+}
+
+// staticMethods lists built-in classes with their static methods
+var staticMethods = map[string]map[string]func(position Position, args []any) (any, error){
+	"Math": {
+		"abs":   mathFunction("abs", math.Abs, true),
+		"floor": mathFunction("floor", math.Floor, false),
+		"ceil":  mathFunction("ceil", math.Ceil, false),
+		"sqrt":  mathFunction("sqrt", math.Sqrt, false),
+		"log":   mathFunction("log", math.Log, false),
+		"log10": mathFunction("log10", math.Log10, false),
+		"exp":   mathFunction("exp", math.Exp, false),
+		"round": func(position Position, args []any) (any, error) {
+			x, err := floatArgs(position, "round", 1, args)
+			if err != nil {
+				return nil, err
+			}
+			// Java rounds half up
+			return int64(math.Floor(x[0] + 0.5)), nil
+		},
+		"pow": func(position Position, args []any) (any, error) {
+			x, err := floatArgs(position, "pow", 2, args)
+			if err != nil {
+				return nil, err
+			}
+			return math.Pow(x[0], x[1]), nil
+		},
+		"max": mathMinMax("max", func(cmp int) bool { return cmp > 0 }),
+		"min": mathMinMax("min", func(cmp int) bool { return cmp < 0 }),
+	},
+	"Integer": {
+		"parseInt": parseNumber("parseInt", func(s string) (any, error) {
+			n, err := strconv.ParseInt(s, 10, 32)
+			return int(n), err
+		}),
+	},
+	"Long": {
+		"parseLong": parseNumber("parseLong", func(s string) (any, error) { return strconv.ParseInt(s, 10, 64) }),
+	},
+	"Double": {
+		"parseDouble": parseNumber("parseDouble", func(s string) (any, error) { return strconv.ParseFloat(strings.TrimSpace(s), 64) }),
+	},
+	"String": {
+		"valueOf": func(position Position, args []any) (any, error) {
+			if len(args) != 1 {
+				return nil, errorAt(position, "unknown call [valueOf] with [%d] arguments on type [String]", len(args))
+			}
+			return toJavaString(args[0]), nil
+		},
+	},
+}
+
+// staticFields are constants of built-in classes
+var staticFields = map[string]map[string]any{
+	"Math": {"PI": math.Pi, "E": math.E},
+}
+
+func callStaticMethod(position Position, class, name string, args []any) (any, error) {
+	method, ok := staticMethods[class][name]
+	if !ok {
+		return nil, errorAt(position, "unknown static method [%s.%s]", class, name)
+	}
+	return method(position, args)
+}
+
+func floatArgs(position Position, name string, count int, args []any) ([]float64, error) {
+	if len(args) != count {
+		return nil, errorAt(position, "unknown call [%s] with [%d] arguments on type [Math]", name, len(args))
+	}
+	result := make([]float64, 0, count)
+	for _, arg := range args {
+		x, ok := toFloat64(arg)
+		if !ok {
+			return nil, exceptionAt(position, classCastException, "cannot cast [%s] to [double]", javaTypeName(arg))
+		}
+		result = append(result, x)
+	}
+	return result, nil
+}
+
+// mathFunction wraps the function of doubles, keepIntegers keeps the type of integer arguments, like Math.abs does
+func mathFunction(name string, fn func(float64) float64, keepIntegers bool) func(position Position, args []any) (any, error) {
+	return func(position Position, args []any) (any, error) {
+		if keepIntegers && len(args) == 1 {
+			if n, ok := toInt64(args[0]); ok {
+				return int64(fn(float64(n))), nil
+			}
+		}
+		x, err := floatArgs(position, name, 1, args)
+		if err != nil {
+			return nil, err
+		}
+		return fn(x[0]), nil
+	}
+}
+
+func mathMinMax(name string, pickFirst func(cmp int) bool) func(position Position, args []any) (any, error) {
+	return func(position Position, args []any) (any, error) {
+		if _, err := floatArgs(position, name, 2, args); err != nil {
+			return nil, err
+		}
+		cmp, err := compare(position, args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+		if pickFirst(cmp) || cmp == 0 {
+			return args[0], nil
+		}
+		return args[1], nil
+	}
+}
+
+func parseNumber(name string, parse func(string) (any, error)) func(position Position, args []any) (any, error) {
+	return func(position Position, args []any) (any, error) {
+		if len(args) != 1 {
+			return nil, errorAt(position, "unknown call [%s] with [%d] arguments", name, len(args))
+		}
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, exceptionAt(position, classCastException, "cannot cast [%s] to [String]", javaTypeName(args[0]))
+		}
+		n, err := parse(s)
+		if err != nil {
+			return nil, exceptionAt(position, "number_format_exception", "for input string: \"%s\"", s)
+		}
+		return n, nil
+	}
+}
@@ -7,6 +7,7 @@ package painful
 import (
 	"fmt"
 	"net/url"
+	"strings"
 	"time"
 )
 
@@ -14,7 +15,7 @@ func ParsePainless(script string) (Expr, error) {
 
 	evalTree, err := Parse("", []byte(script))
 	if err != nil {
-		return nil, err
+		return nil, asScriptError(err)
 	}
 
 	switch expr := evalTree.(type) {
@@ -30,7 +31,54 @@ func ParsePainless(script string) (Expr, error) {
 type Env struct {
 	Doc map[string]any
 
+	// Vars are variables available to the script, e.g. 'values' in moving_fn scripts
+	Vars map[string]any
+
+	// Params are `params` of the script
+	Params map[string]any
+
 	EmitValue any
+
+	// scopes of variables declared by the script, the innermost is the last one
+	scopes []map[string]any
+}
+
+func (e *Env) pushScope() {
+	e.scopes = append(e.scopes, make(map[string]any))
+}
+
+func (e *Env) popScope() {
+	e.scopes = e.scopes[:len(e.scopes)-1]
+}
+
+func (e *Env) lookup(name string) (any, bool) {
+	for i := len(e.scopes) - 1; i >= 0; i-- {
+		if val, ok := e.scopes[i][name]; ok {
+			return val, true
+		}
+	}
+	return nil, false
+}
+
+func (e *Env) declare(position Position, name string, val any) error {
+	if _, ok := e.lookup(name); ok {
+		return errorAt(position, "variable [%s] is already defined", name)
+	}
+	if len(e.scopes) == 0 {
+		e.pushScope()
+	}
+	e.scopes[len(e.scopes)-1][name] = val
+	return nil
+}
+
+func (e *Env) assign(position Position, name string, val any) error {
+	for i := len(e.scopes) - 1; i >= 0; i-- {
+		if _, ok := e.scopes[i][name]; ok {
+			e.scopes[i][name] = val
+			return nil
+		}
+	}
+	return errorAt(position, "cannot resolve symbol [%s]", name)
 }
 
 type Expr interface {
@@ -46,12 +94,36 @@ func (l *LiteralExpr) Eval(env *Env) (any, error) {
 }
 
 type InfixOpExpr struct {
-	Position string
+	Position Position
 	Left     Expr
 	Op       string
 	Right    Expr
 }
 
+func newInfixOpExpr(position Position, left, op, right any) (*InfixOpExpr, error) {
+	leftVal, err := ExpectExpr(left)
+	if err != nil {
+		return nil, err
+	}
+
+	rightVal, err := ExpectExpr(right)
+	if err != nil {
+		return nil, err
+	}
+
+	var opVal string
+	switch op := op.(type) {
+	case string:
+		opVal = op
+	case []byte:
+		opVal = string(op)
+	default:
+		return nil, fmt.Errorf("internal parser error. '%T' is not valid operator", op)
+	}
+
+	return &InfixOpExpr{Position: position, Left: leftVal, Op: opVal, Right: rightVal}, nil
+}
+
 func (i *InfixOpExpr) Eval(env *Env) (any, error) {
 
 	left, err := i.Left.Eval(env)
@@ -59,6 +131,26 @@ func (i *InfixOpExpr) Eval(env *Env) (any, error) {
 		return nil, err
 	}
 
+	// logical operators are short-circuit
+	if i.Op == "&&" || i.Op == "||" {
+		leftBool, ok := left.(bool)
+		if !ok {
+			return nil, exceptionAt(i.Position, classCastException, "cannot cast [%s] to [boolean]", javaTypeName(left))
+		}
+		if leftBool == (i.Op == "||") {
+			return leftBool, nil
+		}
+		right, err := i.Right.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		rightBool, ok := right.(bool)
+		if !ok {
+			return nil, exceptionAt(i.Position, classCastException, "cannot cast [%s] to [boolean]", javaTypeName(right))
+		}
+		return rightBool, nil
+	}
+
 	right, err := i.Right.Eval(env)
 	if err != nil {
 		return nil, err
@@ -67,27 +159,81 @@ func (i *InfixOpExpr) Eval(env *Env) (any, error) {
 	switch i.Op {
 
 	case "+":
+		if isNumber(left) && isNumber(right) {
+			return arithmetic(i.Position, i.Op, left, right)
+		}
+		// any other type is converted to a string, like in Java
+		return toJavaString(left) + toJavaString(right), nil
 
-		switch left.(type) {
+	case "-", "*", "/", "%":
+		return arithmetic(i.Position, i.Op, left, right)
 
-		case string:
-			return fmt.Sprintf("%v%v", left, right), nil
+	case "==", "!=":
+		equal := equals(left, right)
+		if i.Op == "!=" {
+			return !equal, nil
+		}
+		return equal, nil
 
+	case "<", "<=", ">", ">=":
+		// like in Java, comparisons with NaN are false
+		if isNaN(left) || isNaN(right) {
+			return false, nil
+		}
+		cmp, err := compare(i.Position, left, right)
+		if err != nil {
+			return nil, err
+		}
+		switch i.Op {
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
 		default:
-			return fmt.Sprintf("%v%v", left, right), nil
+			return cmp >= 0, nil
 		}
 
 	default:
 
-		return nil, fmt.Errorf("%s: '%s' operator is not supported", i.Position, i.Op)
+		return nil, errorAt(i.Position, "'%s' operator is not supported", i.Op)
 
 	}
 }
 
+type PrefixOpExpr struct {
+	Position Position
+	Op       string
+	Expr     Expr
+}
+
+func (p *PrefixOpExpr) Eval(env *Env) (any, error) {
+
+	val, err := p.Expr.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch p.Op {
+	case "!":
+		boolVal, ok := val.(bool)
+		if !ok {
+			return nil, exceptionAt(p.Position, classCastException, "cannot cast [%s] to [boolean]", javaTypeName(val))
+		}
+		return !boolVal, nil
+	case "-":
+		return arithmetic(p.Position, "-", 0, val)
+	default:
+		return nil, errorAt(p.Position, "'%s' operator is not supported", p.Op)
+	}
+}
+
 type ConditionalExpr struct {
-	Cond Expr
-	Then Expr
-	Else Expr
+	Position Position
+	Cond     Expr
+	Then     Expr
+	Else     Expr
 }
 
 func (c *ConditionalExpr) Eval(env *Env) (any, error) {
@@ -97,13 +243,43 @@ func (c *ConditionalExpr) Eval(env *Env) (any, error) {
 		return nil, err
 	}
 
-	if cond.(bool) {
+	condBool, ok := cond.(bool)
+	if !ok {
+		return nil, exceptionAt(c.Position, classCastException, "cannot cast [%s] to [boolean]", javaTypeName(cond))
+	}
+
+	if condBool {
 		return c.Then.Eval(env)
 	}
 
 	return c.Else.Eval(env)
 }
 
+type VariableExpr struct {
+	Position Position
+	Name     string
+}
+
+func (v *VariableExpr) Eval(env *Env) (any, error) {
+
+	if val, ok := env.lookup(v.Name); ok {
+		return val, nil
+	}
+
+	if val, ok := env.Vars[v.Name]; ok {
+		return val, nil
+	}
+
+	switch v.Name {
+	case ParamsVariableName:
+		return env.Params, nil
+	case docVariableName:
+		return env.Doc, nil
+	}
+
+	return nil, errorAt(v.Position, "cannot resolve symbol [%s]", v.Name)
+}
+
 type DocExpr struct {
 	FieldName Expr
 }
@@ -130,24 +306,38 @@ func (e *EmitExpr) Eval(env *Env) (any, error) {
 		return nil, err
 	}
 
-	env.EmitValue = val
+	env.EmitValue = unwrapList(val)
 
 	return val, nil
 }
 
 type AccessorExpr struct {
-	Position     string
+	Position     Position
 	Expr         Expr
 	PropertyName string
+	NullSafe     bool // `?.` returns null instead of failing on null
 }
 
 func (a *AccessorExpr) Eval(env *Env) (any, error) {
 
+	// constants of built-in classes, e.g. Math.PI
+	if class, ok := a.Expr.(*VariableExpr); ok {
+		if _, isVariable := env.lookup(class.Name); !isVariable {
+			if val, ok := staticFields[class.Name][a.PropertyName]; ok {
+				return val, nil
+			}
+		}
+	}
+
 	val, err := a.Expr.Eval(env)
 	if err != nil {
 		return nil, err
 	}
 
+	if val == nil && a.NullSafe {
+		return nil, nil
+	}
+
 	// value property is a special case
 	// it's just a current value of the expression
 	if a.PropertyName == "value" {
@@ -159,59 +349,122 @@ func (a *AccessorExpr) Eval(env *Env) (any, error) {
 		return fmt.Sprintf("%T", val), nil
 	}
 
-	return nil, fmt.Errorf("%s: '%s' property is not supported", a.Position, a.PropertyName)
+	// doc values are lists in Elasticsearch, `doc['field'].empty` checks if the document has the field
+	if _, isDoc := a.Expr.(*DocExpr); isDoc && a.PropertyName == "empty" {
+		return docValuesCount(val) == 0, nil
+	}
+
+	// fields of maps, e.g. `params.threshold`
+	if m, ok := asMap(val); ok {
+		return m[a.PropertyName], nil
+	}
+
+	// getters can be called as properties, e.g. `value.hour` is `value.getHour()`
+	getter := "get" + strings.ToUpper(a.PropertyName[:1]) + a.PropertyName[1:]
+	if _, ok := dateMethods[getter]; ok {
+		return callMethod(a.Position, val, getter, nil)
+	}
+	if a.PropertyName == "millis" {
+		return callMethod(a.Position, val, "toEpochMilli", nil)
+	}
+
+	if val == nil {
+		return nil, exceptionAt(a.Position, nullPointerException, "cannot access property [%s] of null", a.PropertyName)
+	}
+	return nil, errorAt(a.Position, "property [%s] of [%s] is not supported", a.PropertyName, javaTypeName(val))
 
 }
 
-type MethodCallExpr struct {
-	Position   string
-	Expr       Expr
-	MethodName string
-	Args       []Expr
+type IndexExpr struct {
+	Position Position
+	Expr     Expr
+	Index    Expr
 }
 
-func (m *MethodCallExpr) Eval(env *Env) (any, error) {
+func (i *IndexExpr) Eval(env *Env) (any, error) {
 
-	val, err := m.Expr.Eval(env)
+	val, err := i.Expr.Eval(env)
 	if err != nil {
 		return nil, err
 	}
 
-	switch m.MethodName {
-
-	case "getHour":
+	index, err := i.Index.Eval(env)
+	if err != nil {
+		return nil, err
+	}
 
-		typeVal, err := ExpectDate(val)
+	if m, ok := asMap(val); ok {
+		return m[fmt.Sprintf("%v", index)], nil
+	}
 
-		if err != nil {
-			return nil, fmt.Errorf("%s: method '%s' failed to coerce '%v' into a datetime: %v ", m.Position, m.MethodName, val, err)
+	if list, ok := asList(val); ok {
+		n, ok := toInt64(index)
+		if !ok {
+			return nil, exceptionAt(i.Position, classCastException, "cannot cast [%s] to [int]", javaTypeName(index))
 		}
+		if n < 0 || n >= int64(len(list)) {
+			return nil, exceptionAt(i.Position, indexOutOfBoundsException, "index %d out of bounds for length %d", n, len(list))
+		}
+		return list[n], nil
+	}
 
-		return typeVal.Hour(), nil
+	if val == nil {
+		return nil, exceptionAt(i.Position, nullPointerException, "cannot index null")
+	}
+	return nil, errorAt(i.Position, "[%s] cannot be indexed", javaTypeName(val))
+}
 
-	case "formatISO8601": // TODO maybe more easier to remember name
+type MethodCallExpr struct {
+	Position   Position
+	Expr       Expr
+	MethodName string
+	Args       []Expr
+	NullSafe   bool // `?.` returns null instead of failing on null
+}
 
-		typeVal, err := ExpectDate(val)
+func (m *MethodCallExpr) Eval(env *Env) (any, error) {
 
+	args := make([]any, 0, len(m.Args))
+	for _, arg := range m.Args {
+		argVal, err := arg.Eval(env)
 		if err != nil {
-			return nil, fmt.Errorf("%s: method '%s' failed to coerce '%v' into a datetime: %v ", m.Position, m.MethodName, val, err)
+			return nil, err
 		}
+		args = append(args, argVal)
+	}
 
-		return typeVal.Format(time.RFC3339), nil
+	// static methods of built-in classes, e.g. Math.round(x)
+	if class, ok := m.Expr.(*VariableExpr); ok {
+		if _, isVariable := env.lookup(class.Name); !isVariable {
+			if class.Name == movingFunctionsClassName {
+				return callMovingFunction(m.Position, m.MethodName, args)
+			}
+			if _, isClass := staticMethods[class.Name]; isClass {
+				return callStaticMethod(m.Position, class.Name, m.MethodName, args)
+			}
+		}
+	}
 
-	case "formatISO8601NoTimezone":
+	val, err := m.Expr.Eval(env)
+	if err != nil {
+		return nil, err
+	}
 
-		typeVal, err := ExpectDate(val)
+	if val == nil && m.NullSafe {
+		return nil, nil
+	}
 
-		if err != nil {
-			return nil, fmt.Errorf("%s: method '%s' failed to coerce '%v' into a datetime: %v ", m.Position, m.MethodName, val, err)
+	// doc values are lists in Elasticsearch, `doc['field'].size() == 0` checks if the document has the field
+	if _, isDoc := m.Expr.(*DocExpr); isDoc && len(args) == 0 {
+		switch m.MethodName {
+		case "size":
+			return docValuesCount(val), nil
+		case "isEmpty":
+			return docValuesCount(val) == 0, nil
 		}
-
-		return typeVal.Format("2006-01-02T15:04:05"), nil
-
-	default:
-		return nil, fmt.Errorf("%s: '%s' method is not supported", m.Position, m.MethodName)
 	}
+
+	return callMethod(m.Position, val, m.MethodName, args)
 }
 
 type UrlEncodeExpr struct {
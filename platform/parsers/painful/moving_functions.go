@@ -0,0 +1,282 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package painful
+
+import (
+	"fmt"
+	"math"
+)
+
+// movingFunctionsClassName is the Painless class with functions available in moving_fn scripts.
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/search-aggregations-pipeline-movfn-aggregation.html#_pre_built_functions
+const movingFunctionsClassName = "MovingFunctions"
+
+// MovingFunctionsSupported lists all MovingFunctions methods we support, with their number of arguments.
+var MovingFunctionsSupported = map[string]int{
+	"max":               1,
+	"min":               1,
+	"sum":               1,
+	"stdDev":            2,
+	"unweightedAvg":     1,
+	"linearWeightedAvg": 1,
+	"ewma":              2,
+	"holt":              3,
+	"holtWinters":       6,
+}
+
+func callMovingFunction(position Position, name string, args []any) (any, error) {
+	argsCnt, supported := MovingFunctionsSupported[name]
+	if !supported {
+		return nil, errorAt(position, "'%s.%s' method is not supported", movingFunctionsClassName, name)
+	}
+	if len(args) != argsCnt {
+		return nil, errorAt(position, "'%s.%s' expects %d argument(s), got %d", movingFunctionsClassName, name, argsCnt, len(args))
+	}
+
+	values, err := ExpectFloatArray(args[0])
+	if err != nil {
+		return nil, errorAt(position, "'%s.%s' first argument: %v", movingFunctionsClassName, name, err)
+	}
+	params := make([]float64, 0, len(args)-1)
+	for i, arg := range args[1:] {
+		if name == "holtWinters" && i == 4 { // 'multiplicative' is a boolean
+			break
+		}
+		param, err := ExpectFloat(arg)
+		if err != nil {
+			return nil, errorAt(position, "'%s.%s' argument %d: %v", movingFunctionsClassName, name, i+2, err)
+		}
+		params = append(params, param)
+	}
+
+	switch name {
+	case "max":
+		return MovingMax(values), nil
+	case "min":
+		return MovingMin(values), nil
+	case "sum":
+		return MovingSum(values), nil
+	case "stdDev":
+		return MovingStdDev(values, params[0]), nil
+	case "unweightedAvg":
+		return MovingUnweightedAvg(values), nil
+	case "linearWeightedAvg":
+		return MovingLinearWeightedAvg(values), nil
+	case "ewma":
+		return MovingEwma(values, params[0]), nil
+	case "holt":
+		return MovingHolt(values, params[0], params[1]), nil
+	default: // holtWinters
+		multiplicative, ok := args[5].(bool)
+		if !ok {
+			return nil, errorAt(position, "'%s.%s' argument 6: expected boolean, got %T", movingFunctionsClassName, name, args[5])
+		}
+		return MovingHoltWinters(values, params[0], params[1], params[2], int(params[3]), multiplicative)
+	}
+}
+
+// All functions below follow Elastic's MovingFunctions implementation: NaN values are ignored,
+// and NaN is returned when there's nothing to compute the result from.
+
+func MovingMax(values []float64) float64 {
+	result := math.Inf(-1)
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			result = math.Max(result, v)
+		}
+	}
+	if math.IsInf(result, -1) {
+		return math.NaN()
+	}
+	return result
+}
+
+func MovingMin(values []float64) float64 {
+	result := math.Inf(1)
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			result = math.Min(result, v)
+		}
+	}
+	if math.IsInf(result, 1) {
+		return math.NaN()
+	}
+	return result
+}
+
+func MovingSum(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			sum += v
+		}
+	}
+	return sum
+}
+
+func MovingUnweightedAvg(values []float64) float64 {
+	sum, count := 0.0, 0
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			sum += v
+			count++
+		}
+	}
+	if count == 0 {
+		return math.NaN()
+	}
+	return sum / float64(count)
+}
+
+// MovingStdDev returns the population standard deviation of values, 'avg' is their (pre-computed) average.
+func MovingStdDev(values []float64, avg float64) float64 {
+	if math.IsNaN(avg) {
+		return math.NaN()
+	}
+	variance, count := 0.0, 0
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			variance += (v - avg) * (v - avg)
+			count++
+		}
+	}
+	if count == 0 {
+		return math.NaN()
+	}
+	return math.Sqrt(variance / float64(count))
+}
+
+// MovingLinearWeightedAvg weights older values linearly less than newer ones.
+func MovingLinearWeightedAvg(values []float64) float64 {
+	avg, totalWeight, current := 0.0, 1.0, 1.0
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			avg += v * current
+			totalWeight += current
+			current++
+		}
+	}
+	if totalWeight == 1 {
+		return math.NaN()
+	}
+	return avg / totalWeight
+}
+
+// MovingEwma is an exponentially weighted moving average, 'alpha' is the decay (0..1).
+func MovingEwma(values []float64, alpha float64) float64 {
+	avg, first := math.NaN(), true
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			if first {
+				avg = v
+				first = false
+			} else {
+				avg = v*alpha + avg*(1-alpha)
+			}
+		}
+	}
+	return avg
+}
+
+// MovingHolt is a double exponential smoothing, 'alpha' is the level decay and 'beta' the trend decay.
+func MovingHolt(values []float64, alpha, beta float64) float64 {
+	var s, lastS, b, lastB float64
+	counter := 0
+	for _, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		if counter == 0 {
+			s = v
+			b = 0
+		} else {
+			s = alpha*v + (1-alpha)*(lastS+lastB)
+			b = beta*(s-lastS) + (1-beta)*lastB
+		}
+		counter++
+		lastS, lastB = s, b
+	}
+	if counter == 0 {
+		return math.NaN()
+	}
+	return s
+}
+
+// MovingHoltWinters is a triple exponential smoothing, with 'gamma' as the seasonality decay and 'period' as its length.
+func MovingHoltWinters(values []float64, alpha, beta, gamma float64, period int, multiplicative bool) (float64, error) {
+	vs := make([]float64, 0, len(values))
+	padding := 0.0
+	if multiplicative {
+		padding = 0.0000000001 // avoids division by 0
+	}
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			vs = append(vs, v+padding)
+		}
+	}
+	if period <= 0 || len(vs) < 2*period {
+		return 0, fmt.Errorf("holtWinters requires at least (2 * period == %d) data-points to function, only [%d] were provided", 2*period, len(vs))
+	}
+
+	// initial level is the average of the first season, initial trend is the average slope between the first two seasons
+	var s, b float64
+	for i := 0; i < period; i++ {
+		s += vs[i]
+		b += (vs[i+period] - vs[i]) / float64(period)
+	}
+	s /= float64(period)
+	b /= float64(period)
+	lastS, lastB := s, 0.0
+
+	seasonal := make([]float64, len(vs))
+	if s != 0 {
+		for i := 0; i < period; i++ {
+			seasonal[i] = vs[i] / s
+		}
+	}
+
+	for i := period; i < len(vs); i++ {
+		if multiplicative {
+			s = alpha*(vs[i]/seasonal[i-period]) + (1-alpha)*(lastS+lastB)
+		} else {
+			s = alpha*(vs[i]-seasonal[i-period]) + (1-alpha)*(lastS+lastB)
+		}
+		b = beta*(s-lastS) + (1-beta)*lastB
+		if multiplicative {
+			seasonal[i] = gamma*(vs[i]/(lastS+lastB)) + (1-gamma)*seasonal[i-period]
+		} else {
+			seasonal[i] = gamma*(vs[i]-(lastS-lastB)) + (1-gamma)*seasonal[i-period]
+		}
+		lastS, lastB = s, b
+	}
+
+	idx := len(vs) - period
+	if multiplicative {
+		return (s + b) * seasonal[idx], nil
+	}
+	return s + b + seasonal[idx], nil
+}
+
+func ExpectFloat(potentialFloat any) (float64, error) {
+
+	switch val := potentialFloat.(type) {
+	case float64:
+		return val, nil
+	case int:
+		return float64(val), nil
+	case int64:
+		return float64(val), nil
+	default:
+		return 0, fmt.Errorf("expected number, got %T", potentialFloat)
+	}
+}
+
+func ExpectFloatArray(potentialArray any) ([]float64, error) {
+
+	switch val := potentialArray.(type) {
+	case []float64:
+		return val, nil
+	default:
+		return nil, fmt.Errorf("expected double[], got %T", potentialArray)
+	}
+}
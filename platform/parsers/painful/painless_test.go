@@ -4,6 +4,9 @@ package painful
 
 import (
 	"github.com/QuesmaOrg/quesma/platform/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
 	"reflect"
 	"testing"
 )
@@ -13,6 +16,7 @@ func TestPainless(t *testing.T) {
 	tests := []struct {
 		name   string
 		input  map[string]any
+		params map[string]any
 		script string
 		output any
 	}{
@@ -79,6 +83,55 @@ func TestPainless(t *testing.T) {
 			script: "emit(URLEncoder.encode(doc['foo'].value + doc['bar'].value))",
 			output: "%2B%40",
 		},
+
+		{
+			name: "arithmetic",
+			input: map[string]any{
+				"bytes": int64(1500),
+			},
+			script: "emit((doc['bytes'].value + 500) / 1000 * 2 - 1 % 3);",
+			output: int64(3),
+		},
+
+		{
+			name: "arithmetic with floats",
+			input: map[string]any{
+				"price": 2.5,
+			},
+			script: "emit(-doc['price'].value * 2)",
+			output: -5.0,
+		},
+
+		{
+			name: "ternary",
+			input: map[string]any{
+				"bytes": 1500,
+			},
+			script: "emit(doc['bytes'].value > 1000 && !(doc['bytes'].value == 0) ? 'big' : \"small\")",
+			output: "big",
+		},
+
+		{
+			name:   "params",
+			input:  map[string]any{"bytes": 10},
+			params: map[string]any{"factor": 3, "suffix": "b"},
+			script: "emit(doc['bytes'].value * params.factor + params['suffix'])",
+			output: "30b",
+		},
+
+		{
+			name:   "null check",
+			input:  map[string]any{"name": nil},
+			script: "emit(doc['name'].value != null ? doc['name'].value : 'n/a')",
+			output: "n/a",
+		},
+
+		{
+			name:   "escaped string",
+			input:  map[string]any{},
+			script: "emit('it\\'s')",
+			output: "it's",
+		},
 	}
 
 	for i, tt := range tests {
@@ -89,7 +142,8 @@ func TestPainless(t *testing.T) {
 			}
 
 			env := &Env{
-				Doc: tt.input,
+				Doc:    tt.input,
+				Params: tt.params,
 			}
 
 			switch expr := res.(type) {
@@ -110,3 +164,317 @@ func TestPainless(t *testing.T) {
 		})
 	}
 }
+
+func TestPainlessErrors(t *testing.T) {
+
+	tests := []struct {
+		script    string
+		err       string
+		offset    int
+		exception string
+		compile   bool
+	}{
+		{script: "emit(1 / 0)", err: "/ by zero", offset: 5, exception: "arithmetic_exception"},
+		{script: "emit(1 ? 2 : 3)", err: "cannot cast [int] to [boolean]", offset: 5, exception: "class_cast_exception"},
+		{script: "emit('a' - 1)", err: "[-] cannot be applied to [String]", offset: 5, exception: "illegal_argument_exception"},
+		{script: "emit('a' < 1)", err: "cannot compare [String] with [int]", offset: 5, exception: "class_cast_exception"},
+		{script: "def x = 1;\nreturn y;", err: "cannot resolve symbol [y]", offset: 18, exception: "illegal_argument_exception"},
+		{script: "def x = 1; def x = 2;", err: "variable [x] is already defined", offset: 11, exception: "illegal_argument_exception"},
+		{script: "int x = 2.5;", err: "cannot cast [double] to [int]", offset: 0, exception: "class_cast_exception"},
+		{script: "doc['name'].value.toLowerCase()", err: "cannot invoke [toLowerCase] on null", offset: 0, exception: "null_pointer_exception"},
+		{script: "'abc'.substring(2, 5)", err: "begin 2, end 5, length 3", offset: 0, exception: "index_out_of_bounds_exception"},
+		{script: "'abc'.foo(1)", err: "unknown call [foo] with [1] arguments on type [String]", offset: 0, exception: "illegal_argument_exception"},
+		{script: "while (true) {}", err: "maximum number of statements", offset: 0, exception: "illegal_argument_exception"},
+		{script: "params.a.b = 1", err: "cannot access property [b] of null", offset: 0, exception: "null_pointer_exception"},
+		{script: "def x = 'a'; x.length = 1", err: "cannot assign property [length] of [String]", offset: 13, exception: "illegal_argument_exception"},
+		{script: "def x = ;", err: "no match found", offset: 8, compile: true},
+		{script: "params?.a = 1", err: "cannot assign a value to a null safe operation", offset: 0, compile: true},
+		{script: "'a'.trim() = 1", err: "only variables, fields and list or map elements can be assigned", offset: 0, compile: true},
+		{script: "emit(doc['a'].value", err: "no match found", offset: 19, compile: true},
+	}
+
+	for i, tt := range tests {
+		t.Run(util.PrettyTestName(tt.script, i), func(t *testing.T) {
+			res, err := ParsePainless(tt.script)
+			if err == nil {
+				_, err = res.Eval(&Env{Doc: map[string]any{}})
+			}
+
+			var scriptErr *ScriptError
+			require.ErrorAs(t, err, &scriptErr)
+			assert.Contains(t, scriptErr.Reason, tt.err)
+			assert.Equal(t, tt.offset, scriptErr.Position.Offset)
+			assert.Equal(t, tt.compile, scriptErr.Compile)
+			if tt.exception != "" {
+				assert.Equal(t, tt.exception, scriptErr.Exception)
+			}
+		})
+	}
+}
+
+func TestPainlessStatements(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		input  map[string]any
+		params map[string]any
+		script string
+		output any
+	}{
+		{
+			name:   "variables and return",
+			input:  map[string]any{"bytes": int64(2048)},
+			script: "def kb = doc['bytes'].value / 1024; long total = kb + 1; return total;",
+			output: int64(3),
+		},
+		{
+			name:   "if else",
+			input:  map[string]any{"response": "404"},
+			params: map[string]any{"threshold": 400.0},
+			script: `
+				// responses above the threshold are errors
+				int code = Integer.parseInt(doc['response'].value);
+				if (code >= params.threshold) {
+					return 'error';
+				} else if (code >= 300) {
+					return 'redirect';
+				}
+				return 'ok';`,
+			output: "error",
+		},
+		{
+			name:   "for loop",
+			script: "int sum = 0; for (int i = 0; i < 10; i++) { if (i % 2 == 0) { continue; } sum += i; } return sum;",
+			output: int64(25),
+		},
+		{
+			name:   "for each loop with break",
+			input:  map[string]any{"tags": []any{"a", "bb", "ccc", "dddd"}},
+			script: "String longest = ''; for (def tag : doc['tags']) { if (tag.length() > 2) { longest = tag; break; } } return longest;",
+			output: "ccc",
+		},
+		{
+			name:   "while loop",
+			script: "def n = 1; while (n < 100) { n *= 3 } n",
+			output: int64(243),
+		},
+		{
+			name:   "implicit return of the last expression",
+			input:  map[string]any{"host": " Web-01.example.com "},
+			script: "def host = doc['host'].value.trim().toLowerCase(); host.substring(0, host.indexOf('.'))",
+			output: "web-01",
+		},
+		{
+			name:   "string methods",
+			input:  map[string]any{"url": "/api/v1/users"},
+			script: "def url = doc['url'].value; emit(url.startsWith('/api') && url.contains('users') && !url.endsWith('/') ? url.replace('/', '_') : url)",
+			output: "_api_v1_users",
+		},
+		{
+			name:   "Math",
+			input:  map[string]any{"price": 2.5},
+			script: "emit(Math.round(doc['price'].value) + Math.max(1, 2) + Math.abs(-3) + (int) Math.floor(Math.PI))",
+			output: int64(11),
+		},
+		{
+			name:   "casts",
+			input:  map[string]any{"ratio": 0.75},
+			script: "(int) (doc['ratio'].value * 100) + '%'",
+			output: "75%",
+		},
+		{
+			name:   "missing doc values",
+			input:  map[string]any{"name": nil},
+			script: "if (doc['name'].size() == 0 || doc['name'].empty) { return 'unknown' } return doc['name'].value",
+			output: "unknown",
+		},
+		{
+			name:   "doc and params as maps",
+			input:  map[string]any{"a": 1},
+			params: map[string]any{"fields": []any{"a", "b"}},
+			script: "def found = []; for (f in params.fields) { if (doc.containsKey(f)) { found.add(f) } } return found;",
+			output: []any{"a"},
+		},
+		{
+			name:   "collections",
+			script: "def m = ['x': 1, 'y': 2]; def l = new ArrayList(); for (k in m.keySet()) { l.add(k + '=' + m.get(k)) } m.put('z', l.size()); return m.get('z') + ':' + l;",
+			output: "2:[x=1, y=2]",
+		},
+		{
+			name:   "dates",
+			input:  map[string]any{"@timestamp": "2022-09-22T12:16:59.985Z"},
+			script: "def ts = doc['@timestamp'].value; return ts.getYear() + '-' + ts.monthValue + '-' + ts.dayOfMonth + ' ' + ts.hour;",
+			output: "2022-9-22 12",
+		},
+		{
+			name:   "comparisons with NaN",
+			params: map[string]any{"missing": math.NaN()},
+			script: "params.missing > 1 || params.missing <= 1",
+			output: false,
+		},
+		{
+			name:   "double to string",
+			script: "emit('' + 2.0 / 4 + ' ' + 3.0)",
+			output: "0.5 3.0",
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(util.PrettyTestName(tt.name, i), func(t *testing.T) {
+			script, err := ParsePainless(tt.script)
+			require.NoError(t, err)
+
+			env := &Env{Doc: tt.input, Params: tt.params}
+			res, err := script.Eval(env)
+			require.NoError(t, err)
+			if env.EmitValue != nil {
+				res = env.EmitValue
+			}
+			assert.Equal(t, tt.output, res)
+		})
+	}
+}
+
+func TestPainlessCtx(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		ctx    map[string]any
+		params map[string]any
+		script string
+		output map[string]any
+	}{
+		{
+			name:   "assignments to fields",
+			ctx:    map[string]any{"price": 2.5, "id": 7, "count": 1},
+			params: map[string]any{"quantity": 3},
+			script: "ctx.total = ctx.price * params.quantity; ctx['label'] = 'item-' + ctx.id; ctx.count += 1; ctx.count++",
+			output: map[string]any{"price": 2.5, "id": 7, "count": int64(3), "total": 7.5, "label": "item-7"},
+		},
+		{
+			name:   "nested fields and lists",
+			ctx:    map[string]any{"user": map[string]any{"name": "john"}, "tags": []any{"a", "b"}},
+			script: "ctx.user.name = ctx.user.name.toUpperCase(); ctx.tags[1] = 'c'; ctx['user']['id'] = 1",
+			output: map[string]any{"user": map[string]any{"name": "JOHN", "id": 1}, "tags": []any{"a", "c"}},
+		},
+		{
+			name:   "null safe operator",
+			ctx:    map[string]any{"tmp": 1},
+			script: "def name = ctx.user?.name; ctx.found = name?.toLowerCase() != null; if (ctx.containsKey('tmp')) { ctx.remove('tmp') }",
+			output: map[string]any{"found": false},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(util.PrettyTestName(tt.name, i), func(t *testing.T) {
+			script, err := ParsePainless(tt.script)
+			require.NoError(t, err)
+
+			_, err = script.Eval(&Env{Vars: map[string]any{"ctx": tt.ctx}, Params: tt.params})
+			require.NoError(t, err)
+			assert.Equal(t, tt.output, tt.ctx)
+		})
+	}
+}
+
+func TestPainlessMovingFunctions(t *testing.T) {
+
+	tests := []struct {
+		script string
+		values []float64
+		output float64
+	}{
+		{"MovingFunctions.max(values)", []float64{1, 5, math.NaN(), 3}, 5},
+		{"MovingFunctions.min(values)", []float64{4, 5, 3}, 3},
+		{"MovingFunctions.sum(values)", []float64{4, 5, 3}, 12},
+		{"MovingFunctions.sum(values)", []float64{}, 0},
+		{"MovingFunctions.unweightedAvg(values)", []float64{1, 2, 6}, 3},
+		{"MovingFunctions.unweightedAvg(values)", []float64{}, math.NaN()},
+		{"MovingFunctions.linearWeightedAvg(values)", []float64{1, 2, 3}, 14.0 / 7},
+		{"MovingFunctions.stdDev(values, MovingFunctions.unweightedAvg(values))", []float64{2, 4, 4, 4, 5, 5, 7, 9}, 2},
+		{"MovingFunctions.ewma(values, 0.5)", []float64{1, 2, 3}, 2.25},
+		{"MovingFunctions.holt(values, 0.5, 0.5)", []float64{1, 2, 3}, 2.375},
+		{"MovingFunctions.holtWinters(values, 0.5, 0.5, 0.5, 2, false)", []float64{1, 2, 3, 4}, 3.822916666666667},
+	}
+
+	for i, tt := range tests {
+		t.Run(util.PrettyTestName(tt.script, i), func(t *testing.T) {
+			expr, err := ParsePainless(tt.script)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := expr.Eval(&Env{Vars: map[string]any{"values": tt.values}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resFloat, ok := res.(float64)
+			if !ok {
+				t.Fatalf("expected float64, got %T", res)
+			}
+			if math.IsNaN(tt.output) {
+				if !math.IsNaN(resFloat) {
+					t.Errorf("expected NaN, got %v", resFloat)
+				}
+			} else if math.Abs(tt.output-resFloat) > 1e-9 {
+				t.Errorf("expected %v, got %v", tt.output, resFloat)
+			}
+		})
+	}
+}
+
+func TestPainlessMovingFunctionsErrors(t *testing.T) {
+
+	tests := []string{
+		"MovingFunctions.median(values)",
+		"MovingFunctions.ewma(values)",
+		"MovingFunctions.max(foo)",
+		"MovingFunctions.holtWinters(values, 0.5, 0.5, 0.5, 4, false)",
+	}
+
+	for i, script := range tests {
+		t.Run(util.PrettyTestName(script, i), func(t *testing.T) {
+			expr, err := ParsePainless(script)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = expr.Eval(&Env{Vars: map[string]any{"values": []float64{1, 2, 3}}})
+			if err == nil {
+				t.Errorf("expected error for %s", script)
+			}
+		})
+	}
+}
+
+func TestScriptRequest(t *testing.T) {
+	var request ScriptRequest
+	request.Script.Source = "doc['name'].value.substring(0, params.length)"
+	request.Script.Params = map[string]any{"length": 3.0}
+	request.ContextSetup.Document = map[string]any{"name": "quesma"}
+
+	res, err := request.Eval()
+	require.NoError(t, err)
+	assert.Equal(t, []any{"que"}, res.Result)
+
+	request.Script.Source = "def x = 1;\nreturn doc['name'].value.substring(x, 10);"
+	_, err = request.Eval()
+	require.Error(t, err)
+
+	errorResponse := RenderErrorResponse(request.Script.Source, err)
+	assert.Equal(t, 400, errorResponse.Status)
+	assert.Equal(t, "runtime error", errorResponse.Error.Reason)
+	assert.Equal(t, "index_out_of_bounds_exception", errorResponse.Error.CausedBy.Type)
+	assert.Equal(t, "begin 1, end 10, length 6", errorResponse.Error.CausedBy.Reason)
+	assert.Equal(t, 18, errorResponse.Error.Position.Offset)
+	assert.Equal(t, []string{"def x = 1;\nreturn doc['name'].value.substri", "                  ^---- HERE"}, errorResponse.Error.ScriptStack)
+
+	request.Script.Source = "emit(doc['name'].value"
+	_, err = request.Eval()
+	require.Error(t, err)
+
+	errorResponse = RenderErrorResponse(request.Script.Source, err)
+	assert.Equal(t, "compile error", errorResponse.Error.Reason)
+	assert.Equal(t, len(request.Script.Source), errorResponse.Error.Position.Offset)
+}
@@ -5,12 +5,14 @@ package painful
 import (
 	"github.com/QuesmaOrg/quesma/platform/types"
 	"net/http"
+	"strings"
 )
 
 type ScriptRequest struct {
 	Context string `json:"context"`
 	Script  struct {
-		Source string `json:"source"`
+		Source string         `json:"source"`
+		Params map[string]any `json:"params"`
 	} `json:"script"`
 
 	ContextSetup struct {
@@ -58,29 +60,46 @@ type ScriptErrorResponse struct {
 	Status int `json:"status"`
 }
 
+// scriptStackContext is the number of characters around the error shown in `script_stack`, like in Elasticsearch
+const scriptStackContext = 25
+
 func RenderErrorResponse(script string, err error) ScriptErrorResponse {
 	res := ScriptErrorResponse{}
 
+	scriptErr := asScriptError(err)
+
+	reason := "runtime error"
+	if scriptErr.Compile {
+		reason = "compile error"
+	}
+
+	offset := min(max(scriptErr.Position.Offset, 0), len(script))
+	start := max(offset-scriptStackContext, 0)
+	end := min(offset+scriptStackContext, len(script))
+	scriptStack := []string{script[start:end], strings.Repeat(" ", offset-start) + "^---- HERE"}
+
 	rootCause := ScriptErrorErrorElement{}
-	rootCause.Reason = err.Error()
+	rootCause.Reason = reason
 	rootCause.Type = "script_exception"
 	rootCause.Lang = "painless"
-	rootCause.Position.Start = 0
-	rootCause.Position.End = 0
-	rootCause.Position.Offset = 0
+	rootCause.Position.Start = start
+	rootCause.Position.End = end
+	rootCause.Position.Offset = offset
 	rootCause.Script = script
-	rootCause.ScriptStack = []string{script}
+	rootCause.ScriptStack = scriptStack
 
-	res.Error.CausedBy.Reason = err.Error()
-	res.Error.CausedBy.Type = "illegal_argument_exception"
+	res.Error.CausedBy.Reason = scriptErr.Reason
+	res.Error.CausedBy.Type = scriptErr.Exception
 	res.Error.Lang = "painless"
 
-	res.Error.Position.Start = 0
-	res.Error.Position.End = 0
-	res.Error.Position.Offset = 0
+	res.Error.Position.Start = start
+	res.Error.Position.End = end
+	res.Error.Position.Offset = offset
 
 	res.Error.Type = "script_exception"
-	res.Error.Reason = "compile error"
+	res.Error.Reason = reason
+	res.Error.Script = script
+	res.Error.ScriptStack = scriptStack
 
 	res.Error.RootCause = []ScriptErrorErrorElement{rootCause}
 
@@ -91,7 +110,8 @@ func RenderErrorResponse(script string, err error) ScriptErrorResponse {
 
 func (s ScriptRequest) Eval() (res ScriptResponse, err error) {
 	env := &Env{
-		Doc: s.ContextSetup.Document,
+		Doc:    s.ContextSetup.Document,
+		Params: NormalizeParams(s.Script.Params),
 	}
 
 	evalTree, err := ParsePainless(s.Script.Source)
@@ -99,11 +119,16 @@ func (s ScriptRequest) Eval() (res ScriptResponse, err error) {
 		return res, err
 	}
 
-	_, err = evalTree.Eval(env)
+	value, err := evalTree.Eval(env)
 	if err != nil {
 		return res, err
 	}
 
+	// scripts of runtime fields emit their values, other scripts return them
+	if env.EmitValue == nil {
+		env.EmitValue = unwrapList(value)
+	}
+
 	res.Result = []any{env.EmitValue}
 
 	return res, nil
@@ -0,0 +1,616 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package painful
+
+import (
+	"fmt"
+	"math"
+)
+
+// maxLoopIterations limits loops, like `script.painless.max_loop_counter` of Elasticsearch does
+const maxLoopIterations = 1000000
+
+// controlFlow tells what to do after a statement has been executed
+type controlFlow int
+
+const (
+	flowNext controlFlow = iota
+	flowReturn
+	flowBreak
+	flowContinue
+)
+
+type Statement interface {
+	// Exec executes the statement, value is the value of the expression statement or the returned value
+	Exec(env *Env) (flow controlFlow, value any, err error)
+}
+
+// ScriptExpr is a script with many statements. Like in Painless, the value of the script is the returned value,
+// or the value of the last expression statement.
+type ScriptExpr struct {
+	Statements []Statement
+}
+
+func newScript(statements any) (Expr, error) {
+	stmts, err := expectStatements(statements)
+	if err != nil {
+		return nil, err
+	}
+
+	// scripts with a single expression are the expression itself
+	if len(stmts) == 1 {
+		if expr, ok := stmts[0].(*ExprStatement); ok {
+			return expr.Expr, nil
+		}
+	}
+
+	return &ScriptExpr{Statements: stmts}, nil
+}
+
+func (s *ScriptExpr) Eval(env *Env) (any, error) {
+	env.pushScope()
+	defer env.popScope()
+
+	flow, val, err := execStatements(env, s.Statements)
+	if err != nil {
+		return nil, err
+	}
+	if flow == flowBreak || flow == flowContinue {
+		return nil, fmt.Errorf("break and continue are allowed only in loops")
+	}
+	return unwrapList(val), nil
+}
+
+func execStatements(env *Env, statements []Statement) (controlFlow, any, error) {
+	var last any
+	for _, statement := range statements {
+		flow, val, err := statement.Exec(env)
+		if err != nil {
+			return flowNext, nil, err
+		}
+		if flow != flowNext {
+			return flow, val, nil
+		}
+		last = val
+	}
+	return flowNext, last, nil
+}
+
+// expectStatements collects statements of `(_ Statement)*` grammar rules
+func expectStatements(potentialStatements any) ([]Statement, error) {
+	var result []Statement
+	items, ok := potentialStatements.([]any)
+	if !ok {
+		return nil, fmt.Errorf("internal parser error. '%T' is not valid list of statements", potentialStatements)
+	}
+	for _, item := range items {
+		if pair, ok := item.([]any); ok && len(pair) == 2 {
+			item = pair[1]
+		}
+		statement, ok := item.(Statement)
+		if !ok {
+			return nil, fmt.Errorf("internal parser error. '%T' is not valid statement", item)
+		}
+		result = append(result, statement)
+	}
+	return result, nil
+}
+
+func ExpectStatement(potentialStatement any) (Statement, error) {
+	switch statement := potentialStatement.(type) {
+	case Statement:
+		return statement, nil
+	case nil:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("expected statement, got %T", potentialStatement)
+	}
+}
+
+type ExprStatement struct {
+	Expr Expr
+}
+
+func (s *ExprStatement) Exec(env *Env) (controlFlow, any, error) {
+	val, err := s.Expr.Eval(env)
+	return flowNext, val, err
+}
+
+type BlockStatement struct {
+	Statements []Statement
+}
+
+func (b *BlockStatement) Exec(env *Env) (controlFlow, any, error) {
+	env.pushScope()
+	defer env.popScope()
+	return execStatements(env, b.Statements)
+}
+
+type DeclarationStatement struct {
+	Position Position
+	Type     string
+	Name     string
+	Expr     Expr // nil if the variable isn't initialized
+}
+
+func (d *DeclarationStatement) Exec(env *Env) (controlFlow, any, error) {
+	var val any
+	if d.Expr != nil {
+		var err error
+		if val, err = d.Expr.Eval(env); err != nil {
+			return flowNext, nil, err
+		}
+		if val, err = castValue(d.Position, d.Type, val, false); err != nil {
+			return flowNext, nil, err
+		}
+	} else {
+		val = zeroValue(d.Type)
+	}
+	return flowNext, nil, env.declare(d.Position, d.Name, val)
+}
+
+type AssignmentStatement struct {
+	Position Position
+	Target   Expr   // VariableExpr, AccessorExpr or IndexExpr, e.g. `x`, `ctx.count` or `ctx['count']`
+	Op       string // "=", compound operators like "+=", or "++" and "--"
+	Expr     Expr   // nil for "++" and "--"
+}
+
+func newAssignmentStatement(position Position, target any, op string, expr any) (*AssignmentStatement, error) {
+	targetVal, err := ExpectExpr(target)
+	if err != nil {
+		return nil, err
+	}
+	switch targetVal := targetVal.(type) {
+	case *VariableExpr, *IndexExpr:
+	case *AccessorExpr:
+		if targetVal.NullSafe {
+			return nil, errorAt(position, "invalid assignment: cannot assign a value to a null safe operation [?.]")
+		}
+	default:
+		return nil, errorAt(position, "invalid assignment: only variables, fields and list or map elements can be assigned")
+	}
+
+	assignment := &AssignmentStatement{Position: position, Target: targetVal, Op: op}
+	if expr != nil {
+		if assignment.Expr, err = ExpectExpr(expr); err != nil {
+			return nil, err
+		}
+	}
+	return assignment, nil
+}
+
+func (a *AssignmentStatement) Exec(env *Env) (controlFlow, any, error) {
+
+	var val any
+	if a.Expr != nil {
+		var err error
+		if val, err = a.Expr.Eval(env); err != nil {
+			return flowNext, nil, err
+		}
+	}
+
+	if a.Op != "=" {
+		current, err := a.current(env)
+		if err != nil {
+			return flowNext, nil, err
+		}
+
+		op := a.Op[:1]
+		if a.Op == "++" || a.Op == "--" {
+			val = 1
+		}
+
+		if op == "+" && !(isNumber(current) && isNumber(val)) {
+			val = toJavaString(current) + toJavaString(val)
+		} else if val, err = arithmetic(a.Position, op, current, val); err != nil {
+			return flowNext, nil, err
+		}
+	}
+
+	return flowNext, val, a.store(env, val)
+}
+
+// current is the value of the target before a compound assignment
+func (a *AssignmentStatement) current(env *Env) (any, error) {
+	if variable, ok := a.Target.(*VariableExpr); ok {
+		current, ok := env.lookup(variable.Name)
+		if !ok {
+			return nil, errorAt(a.Position, "cannot resolve symbol [%s]", variable.Name)
+		}
+		return current, nil
+	}
+	return a.Target.Eval(env)
+}
+
+func (a *AssignmentStatement) store(env *Env, val any) error {
+	switch target := a.Target.(type) {
+	case *VariableExpr:
+		return env.assign(a.Position, target.Name, val)
+
+	case *AccessorExpr:
+		object, err := target.Expr.Eval(env)
+		if err != nil {
+			return err
+		}
+		m, isMap := asMap(object)
+		if isMap && m != nil {
+			m[target.PropertyName] = val
+			return nil
+		}
+		if object == nil || isMap {
+			return exceptionAt(a.Position, nullPointerException, "cannot access property [%s] of null", target.PropertyName)
+		}
+		return errorAt(a.Position, "cannot assign property [%s] of [%s]", target.PropertyName, javaTypeName(object))
+
+	case *IndexExpr:
+		object, err := target.Expr.Eval(env)
+		if err != nil {
+			return err
+		}
+		index, err := target.Index.Eval(env)
+		if err != nil {
+			return err
+		}
+		m, isMap := asMap(object)
+		if isMap && m != nil {
+			m[fmt.Sprintf("%v", index)] = val
+			return nil
+		}
+
+		var items []any
+		switch list := object.(type) {
+		case *List:
+			items = list.Items
+		case []any:
+			items = list
+		default:
+			if object == nil || isMap {
+				return exceptionAt(a.Position, nullPointerException, "cannot index null")
+			}
+			return errorAt(a.Position, "[%s] cannot be indexed", javaTypeName(object))
+		}
+		n, ok := toInt64(index)
+		if !ok {
+			return exceptionAt(a.Position, classCastException, "cannot cast [%s] to [int]", javaTypeName(index))
+		}
+		if n < 0 || n >= int64(len(items)) {
+			return exceptionAt(a.Position, indexOutOfBoundsException, "index %d out of bounds for length %d", n, len(items))
+		}
+		items[n] = val
+		return nil
+	}
+
+	return errorAt(a.Position, "invalid assignment target")
+}
+
+type IfStatement struct {
+	Position Position
+	Cond     Expr
+	Then     Statement
+	Else     Statement // may be nil
+}
+
+func (i *IfStatement) Exec(env *Env) (controlFlow, any, error) {
+	cond, err := evalCondition(env, i.Position, i.Cond)
+	if err != nil {
+		return flowNext, nil, err
+	}
+	if cond {
+		return i.Then.Exec(env)
+	}
+	if i.Else != nil {
+		return i.Else.Exec(env)
+	}
+	return flowNext, nil, nil
+}
+
+func evalCondition(env *Env, position Position, cond Expr) (bool, error) {
+	val, err := cond.Eval(env)
+	if err != nil {
+		return false, err
+	}
+	boolVal, ok := val.(bool)
+	if !ok {
+		return false, exceptionAt(position, classCastException, "cannot cast [%s] to [boolean]", javaTypeName(val))
+	}
+	return boolVal, nil
+}
+
+// ForStatement is both `for (init; cond; update)` and `while (cond)` loop
+type ForStatement struct {
+	Position Position
+	Init     Statement // may be nil
+	Cond     Expr      // may be nil
+	Update   Statement // may be nil
+	Body     Statement
+}
+
+func (f *ForStatement) Exec(env *Env) (controlFlow, any, error) {
+	env.pushScope()
+	defer env.popScope()
+
+	if f.Init != nil {
+		if _, _, err := f.Init.Exec(env); err != nil {
+			return flowNext, nil, err
+		}
+	}
+
+	for iteration := 0; ; iteration++ {
+		if iteration >= maxLoopIterations {
+			return flowNext, nil, errorAt(f.Position, "the maximum number of statements that can be executed in a loop has been reached")
+		}
+
+		if f.Cond != nil {
+			cond, err := evalCondition(env, f.Position, f.Cond)
+			if err != nil {
+				return flowNext, nil, err
+			}
+			if !cond {
+				break
+			}
+		}
+
+		flow, val, err := f.Body.Exec(env)
+		if err != nil {
+			return flowNext, nil, err
+		}
+		if flow == flowReturn {
+			return flow, val, nil
+		}
+		if flow == flowBreak {
+			break
+		}
+
+		if f.Update != nil {
+			if _, _, err := f.Update.Exec(env); err != nil {
+				return flowNext, nil, err
+			}
+		}
+	}
+	return flowNext, nil, nil
+}
+
+// ForEachStatement is `for (def item : collection)` or `for (item in collection)` loop
+type ForEachStatement struct {
+	Position   Position
+	Type       string
+	Name       string
+	Collection Expr
+	Body       Statement
+}
+
+func (f *ForEachStatement) Exec(env *Env) (controlFlow, any, error) {
+
+	collection, err := f.Collection.Eval(env)
+	if err != nil {
+		return flowNext, nil, err
+	}
+
+	var items []any
+	if m, ok := asMap(collection); ok {
+		keys, _ := callMapMethod(f.Position, m, "keySet", nil)
+		items = keys.([]any)
+	} else if items, ok = asList(collection); !ok {
+		if collection == nil {
+			return flowNext, nil, exceptionAt(f.Position, nullPointerException, "cannot iterate over null")
+		}
+		return flowNext, nil, errorAt(f.Position, "cannot iterate over [%s]", javaTypeName(collection))
+	}
+
+	if len(items) > maxLoopIterations {
+		return flowNext, nil, errorAt(f.Position, "the maximum number of statements that can be executed in a loop has been reached")
+	}
+
+	for _, item := range items {
+		flow, val, err := f.execBody(env, item)
+		if err != nil {
+			return flowNext, nil, err
+		}
+		if flow == flowReturn {
+			return flow, val, nil
+		}
+		if flow == flowBreak {
+			break
+		}
+	}
+	return flowNext, nil, nil
+}
+
+func (f *ForEachStatement) execBody(env *Env, item any) (controlFlow, any, error) {
+	env.pushScope()
+	defer env.popScope()
+
+	item, err := castValue(f.Position, f.Type, item, false)
+	if err != nil {
+		return flowNext, nil, err
+	}
+	if err = env.declare(f.Position, f.Name, item); err != nil {
+		return flowNext, nil, err
+	}
+	return f.Body.Exec(env)
+}
+
+type ReturnStatement struct {
+	Expr Expr // may be nil
+}
+
+func (r *ReturnStatement) Exec(env *Env) (controlFlow, any, error) {
+	if r.Expr == nil {
+		return flowReturn, nil, nil
+	}
+	val, err := r.Expr.Eval(env)
+	return flowReturn, val, err
+}
+
+// LoopControlStatement is `break` or `continue`
+type LoopControlStatement struct {
+	Break bool
+}
+
+func (l *LoopControlStatement) Exec(env *Env) (controlFlow, any, error) {
+	if l.Break {
+		return flowBreak, nil, nil
+	}
+	return flowContinue, nil, nil
+}
+
+// CastExpr is an explicit cast, e.g. `(int) x`
+type CastExpr struct {
+	Position Position
+	Type     string
+	Expr     Expr
+}
+
+func (c *CastExpr) Eval(env *Env) (any, error) {
+	val, err := c.Expr.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return castValue(c.Position, c.Type, val, true)
+}
+
+// castValue converts the value to the type. Implicit casts, e.g. in declarations, allow only widening of numbers.
+func castValue(position Position, typ string, val any, explicit bool) (any, error) {
+
+	cannotCast := exceptionAt(position, classCastException, "cannot cast [%s] to [%s]", javaTypeName(val), typ)
+
+	switch typ {
+	case "def", "Object":
+		return val, nil
+
+	case "int", "long", "short", "byte":
+		if val == nil {
+			return nil, cannotCast
+		}
+		if n, ok := toInt64(val); ok {
+			if typ == "long" {
+				return n, nil
+			}
+			return int(n), nil
+		}
+		if f, ok := toFloat64(val); ok && explicit {
+			if typ == "long" {
+				return int64(math.Trunc(f)), nil
+			}
+			return int(math.Trunc(f)), nil
+		}
+		return nil, cannotCast
+
+	case "double", "float":
+		if f, ok := toFloat64(val); ok {
+			return f, nil
+		}
+		return nil, cannotCast
+
+	case "boolean":
+		if _, ok := val.(bool); ok {
+			return val, nil
+		}
+		return nil, cannotCast
+
+	case "String":
+		if _, ok := val.(string); ok || val == nil {
+			return val, nil
+		}
+		return nil, cannotCast
+
+	case "List":
+		if _, ok := asList(val); ok || val == nil {
+			return val, nil
+		}
+		return nil, cannotCast
+
+	case "Map":
+		if _, ok := asMap(val); ok || val == nil {
+			return val, nil
+		}
+		return nil, cannotCast
+	}
+
+	return nil, errorAt(position, "unknown type [%s]", typ)
+}
+
+func zeroValue(typ string) any {
+	switch typ {
+	case "int", "short", "byte":
+		return 0
+	case "long":
+		return int64(0)
+	case "double", "float":
+		return 0.0
+	case "boolean":
+		return false
+	}
+	return nil
+}
+
+// ListExpr is a list initializer, e.g. `[1, 2, 3]`
+type ListExpr struct {
+	Items []Expr
+}
+
+func (l *ListExpr) Eval(env *Env) (any, error) {
+	list := &List{Items: make([]any, 0, len(l.Items))}
+	for _, item := range l.Items {
+		val, err := item.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, val)
+	}
+	return list, nil
+}
+
+// MapExpr is a map initializer, e.g. `['a': 1, 'b': 2]` or `[:]`
+type MapExpr struct {
+	Keys   []Expr
+	Values []Expr
+}
+
+func (m *MapExpr) Eval(env *Env) (any, error) {
+	result := make(map[string]any, len(m.Keys))
+	for i := range m.Keys {
+		key, err := m.Keys[i].Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		val, err := m.Values[i].Eval(env)
+		if err != nil {
+			return nil, err
+		}
+		result[toJavaString(key)] = val
+	}
+	return result, nil
+}
+
+// NewExpr creates an empty collection, e.g. `new ArrayList()`
+type NewExpr struct {
+	Position  Position
+	ClassName string
+}
+
+func (n *NewExpr) Eval(env *Env) (any, error) {
+	switch n.ClassName {
+	case "ArrayList", "LinkedList":
+		return &List{}, nil
+	case "HashMap", "TreeMap", "LinkedHashMap":
+		return make(map[string]any), nil
+	}
+	return nil, errorAt(n.Position, "cannot create [%s]", n.ClassName)
+}
+
+func newForEachStatement(position Position, typ, name, collection, body any) (*ForEachStatement, error) {
+
+	collectionVal, err := ExpectExpr(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	bodyVal, err := ExpectStatement(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ForEachStatement{Position: position, Type: typ.(string), Name: name.(string), Collection: collectionVal, Body: bodyVal}, nil
+}
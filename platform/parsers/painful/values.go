@@ -0,0 +1,325 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package painful
+
+import (
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/types"
+	"github.com/goccy/go-json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParamsVariableName is the name of the variable holding `params` of the script
+const ParamsVariableName = "params"
+
+// NormalizeParams converts numbers of params decoded from JSON, which are all float64, to integers when they are
+// integral. Painless sees them as integers as well.
+func NormalizeParams(params map[string]any) map[string]any {
+	if params == nil {
+		return nil
+	}
+	result := make(map[string]any, len(params))
+	for key, val := range params {
+		result[key] = normalizeParam(val)
+	}
+	return result
+}
+
+func normalizeParam(val any) any {
+	switch v := val.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			if v >= math.MinInt32 && v <= math.MaxInt32 {
+				return int(v)
+			}
+			return int64(v)
+		}
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = normalizeParam(item)
+		}
+		return items
+	case map[string]any:
+		return NormalizeParams(v)
+	}
+	return val
+}
+
+// JSONValue converts a value of the script back to a value decoded from JSON, which is the reverse of NormalizeParams:
+// numbers are float64, lists are []any and maps are map[string]any.
+func JSONValue(val any) any {
+	switch v := val.(type) {
+	case *List:
+		return JSONValue(v.Items)
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = JSONValue(item)
+		}
+		return items
+	}
+	if f, ok := toFloat64(val); ok {
+		return f
+	}
+	if m, ok := asMap(val); ok {
+		result := make(map[string]any, len(m))
+		for key, item := range m {
+			result[key] = JSONValue(item)
+		}
+		return result
+	}
+	return val
+}
+
+// docVariableName is the name of the variable holding the document, `doc['field']` is handled by DocExpr
+const docVariableName = "doc"
+
+// List is a list created by the script, e.g. with `[1, 2]` or `new ArrayList()`.
+// Unlike lists coming from documents or params, it can be modified.
+type List struct {
+	Items []any
+}
+
+func (l *List) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.Items)
+}
+
+func asList(val any) ([]any, bool) {
+	switch list := val.(type) {
+	case *List:
+		return list.Items, true
+	case []any:
+		return list, true
+	case []float64:
+		items := make([]any, len(list))
+		for i, item := range list {
+			items[i] = item
+		}
+		return items, true
+	}
+	return nil, false
+}
+
+// unwrapList returns script lists as plain slices, so that results don't depend on how the list was made
+func unwrapList(val any) any {
+	if list, ok := val.(*List); ok {
+		return list.Items
+	}
+	return val
+}
+
+// docValuesCount is the number of values of the field in the document
+func docValuesCount(val any) int {
+	if val == nil {
+		return 0
+	}
+	if list, ok := asList(val); ok {
+		return len(list)
+	}
+	return 1
+}
+
+// toJavaString formats the value like Java's String.valueOf
+func toJavaString(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return "null"
+	case float32:
+		return toJavaString(float64(v))
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1e7 {
+			return strconv.FormatFloat(v, 'f', 1, 64)
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case *List:
+		return toJavaString(v.Items)
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = toJavaString(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func isInteger(val any) bool {
+	switch val.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return true
+	}
+	return false
+}
+
+func isNumber(val any) bool {
+	switch val.(type) {
+	case float32, float64:
+		return true
+	}
+	return isInteger(val)
+}
+
+func isNaN(val any) bool {
+	f, ok := val.(float64)
+	return ok && math.IsNaN(f)
+}
+
+func toInt64(val any) (int64, bool) {
+	if !isInteger(val) {
+		return 0, false
+	}
+	v := reflect.ValueOf(val)
+	if v.CanInt() {
+		return v.Int(), true
+	}
+	return int64(v.Uint()), true
+}
+
+func toFloat64(val any) (float64, bool) {
+	if n, ok := toInt64(val); ok {
+		return float64(n), true
+	}
+	switch v := val.(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// arithmetic follows Java numeric promotion: integers stay int64 unless one of the operands is floating point
+func arithmetic(position Position, op string, left, right any) (any, error) {
+
+	if leftInt, ok := toInt64(left); ok {
+		if rightInt, ok := toInt64(right); ok {
+			switch op {
+			case "+":
+				return leftInt + rightInt, nil
+			case "-":
+				return leftInt - rightInt, nil
+			case "*":
+				return leftInt * rightInt, nil
+			case "/", "%":
+				if rightInt == 0 {
+					return nil, exceptionAt(position, arithmeticException, "/ by zero")
+				}
+				if op == "/" {
+					return leftInt / rightInt, nil
+				}
+				return leftInt % rightInt, nil
+			}
+		}
+	}
+
+	leftFloat, ok := toFloat64(left)
+	if !ok {
+		return nil, errorAt(position, "[%s] cannot be applied to [%s]", op, javaTypeName(left))
+	}
+	rightFloat, ok := toFloat64(right)
+	if !ok {
+		return nil, errorAt(position, "[%s] cannot be applied to [%s]", op, javaTypeName(right))
+	}
+
+	switch op {
+	case "+":
+		return leftFloat + rightFloat, nil
+	case "-":
+		return leftFloat - rightFloat, nil
+	case "*":
+		return leftFloat * rightFloat, nil
+	case "/":
+		return leftFloat / rightFloat, nil
+	case "%":
+		return math.Mod(leftFloat, rightFloat), nil
+	}
+	return nil, errorAt(position, "[%s] operator is not supported", op)
+}
+
+func equals(left, right any) bool {
+	if isNumber(left) && isNumber(right) {
+		leftFloat, _ := toFloat64(left)
+		rightFloat, _ := toFloat64(right)
+		return leftFloat == rightFloat
+	}
+	if leftTime, ok := left.(time.Time); ok {
+		if rightTime, ok := right.(time.Time); ok {
+			return leftTime.Equal(rightTime)
+		}
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+// compare returns -1, 0 or 1 for numbers, strings and dates
+func compare(position Position, left, right any) (int, error) {
+
+	if isNumber(left) && isNumber(right) {
+		leftFloat, _ := toFloat64(left)
+		rightFloat, _ := toFloat64(right)
+		switch {
+		case leftFloat < rightFloat:
+			return -1, nil
+		case leftFloat > rightFloat:
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	switch leftVal := left.(type) {
+	case string:
+		if rightVal, ok := right.(string); ok {
+			return strings.Compare(leftVal, rightVal), nil
+		}
+	case time.Time:
+		if rightVal, ok := right.(time.Time); ok {
+			return leftVal.Compare(rightVal), nil
+		}
+	}
+
+	return 0, exceptionAt(position, classCastException, "cannot compare [%s] with [%s]", javaTypeName(left), javaTypeName(right))
+}
+
+func asMap(val any) (map[string]any, bool) {
+	switch m := val.(type) {
+	case map[string]any:
+		return m, true
+	case types.JSON:
+		return m, true
+	}
+	return nil, false
+}
+
+// unescapeString resolves backslash escapes of string literals
+func unescapeString(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		if !escaped && r == '\\' {
+			escaped = true
+			continue
+		}
+		if escaped {
+			switch r {
+			case 'n':
+				r = '\n'
+			case 't':
+				r = '\t'
+			case 'r':
+				r = '\r'
+			}
+			escaped = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -130,6 +130,30 @@ func (p *ElasticJSONDatabase) Get(key string) (string, bool, error) {
 	return wrapper.Content, true, err
 }
 
+func (p *ElasticJSONDatabase) Delete(key string) (bool, error) {
+	elasticsearchURL := fmt.Sprintf("%s/_doc/%s", p.indexName, key)
+
+	resp, err := p.httpClient.Request(context.Background(), "DELETE", elasticsearchURL, nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, p.refresh()
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return false, err
+		}
+		logger.Error().Msgf("Failed to delete from elastic: %s", string(respBody))
+		return false, fmt.Errorf("failed to delete from elastic: %v", resp.Status)
+	}
+}
+
 func (p *ElasticJSONDatabase) List() ([]string, error) {
 
 	// Define the Elasticsearch endpoint and the index you want to query
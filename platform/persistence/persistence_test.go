@@ -79,4 +79,19 @@ func TestNewElasticPersistence(t *testing.T) {
 		t.Fatal("expected bar")
 	}
 
+	found, err := p.Delete("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Fatal("expected found")
+	}
+
+	_, ok, err = p.Get("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("expected not ok after delete")
+	}
 }
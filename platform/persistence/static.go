@@ -45,3 +45,12 @@ func (db *StaticJSONDatabase) Put(key string, val string) error {
 	db.data[key] = val
 	return nil
 }
+
+func (db *StaticJSONDatabase) Delete(key string) (bool, error) {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	_, ok := db.data[key]
+	delete(db.data, key)
+	return ok, nil
+}
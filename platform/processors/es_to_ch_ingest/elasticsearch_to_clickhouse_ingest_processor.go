@@ -108,7 +108,7 @@ func (p *ElasticsearchToClickHouseIngestProcessor) Handle(metadata map[string]in
 			if err != nil {
 				return metadata, nil, err
 			}
-			res, err := frontend_connectors.HandleIndexDoc(ctx, indexPatterFromRequestUri, payloadJson, p.legacyIngestProcessor, ingestStats, esConn, p.legacyDependencies, p.legacyIngestProcessor.GetTableResolver())
+			res, err := frontend_connectors.HandleIndexDoc(ctx, indexPatterFromRequestUri, req.URL.Query().Get("pipeline"), payloadJson, p.legacyIngestProcessor, ingestStats, esConn, p.legacyDependencies, p.legacyIngestProcessor.GetTableResolver())
 			if err != nil {
 				return metadata, nil, err
 			}
@@ -125,7 +125,7 @@ func (p *ElasticsearchToClickHouseIngestProcessor) Handle(metadata map[string]in
 			if err != nil {
 				return metadata, nil, err
 			}
-			res, err := frontend_connectors.HandleBulkIndex(ctx, indexPatterFromRequestUri, payloadNDJson, p.legacyIngestProcessor, ingestStats, esConn, p.legacyDependencies, p.legacyIngestProcessor.GetTableResolver())
+			res, err := frontend_connectors.HandleBulkIndex(ctx, indexPatterFromRequestUri, req.URL.Query().Get("pipeline"), payloadNDJson, p.legacyIngestProcessor, ingestStats, esConn, p.legacyDependencies, p.legacyIngestProcessor.GetTableResolver())
 			if err != nil {
 				return metadata, nil, err
 			}
@@ -136,7 +136,7 @@ func (p *ElasticsearchToClickHouseIngestProcessor) Handle(metadata map[string]in
 			if err != nil {
 				return metadata, nil, err
 			}
-			res, err := frontend_connectors.HandleBulk(ctx, payloadNDJson, p.legacyIngestProcessor, ingestStats, esConn, p.legacyDependencies, p.legacyIngestProcessor.GetTableResolver())
+			res, err := frontend_connectors.HandleBulk(ctx, req.URL.Query().Get("pipeline"), payloadNDJson, p.legacyIngestProcessor, ingestStats, esConn, p.legacyDependencies, p.legacyIngestProcessor.GetTableResolver())
 			if err != nil {
 				return metadata, nil, err
 			}
@@ -146,7 +146,7 @@ func (p *ElasticsearchToClickHouseIngestProcessor) Handle(metadata map[string]in
 			if err != nil {
 				return metadata, nil, err
 			}
-			res, err := frontend_connectors.HandlePutIndex(indexPatterFromRequestUri, payloadJson, p.GetSchemaRegistry())
+			res, err := frontend_connectors.HandlePutIndexMapping(indexPatterFromRequestUri, payloadJson, p.GetSchemaRegistry())
 			if err != nil {
 				return metadata, nil, err
 			}
@@ -182,8 +182,16 @@ func (p *ElasticsearchToClickHouseQueryProcessor) Handle(metadata map[string]int
 					keepOnCompletion = true
 				}
 			}
+			var keepAlive time.Duration // zero means the configured default
+			if v := queryParams.Get("keep_alive"); v != "" {
+				if k, err := util.ParseInterval(v); err == nil {
+					keepAlive = k
+				} else {
+					logger.Warn().Msgf("Can't parse keep_alive value: %s", v)
+				}
+			}
 			metadata[es_to_ch_common.RealSourceHeader] = es_to_ch_common.RealSourceClickHouse
-			res, _ := frontend_connectors.HandleIndexAsyncSearch(ctx, indexPattern, query, waitForResultsMs, keepOnCompletion, p.queryRunner)
+			res, _ := frontend_connectors.HandleIndexAsyncSearch(ctx, indexPattern, query, waitForResultsMs, keepOnCompletion, keepAlive, p.queryRunner)
 			return metadata, res, nil
 		case es_to_ch_common.AsyncSearchIdPath:
 			if !strings.Contains(id, tracing.AsyncIdPrefix) {
@@ -0,0 +1,106 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package schema
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Dynamic templates and `_source` come from mappings of `PUT /:index` or `PUT /:index/_mapping`,
+// and are kept in the dynamic configuration (see Table).
+// Ref: https://www.elastic.co/guide/en/elasticsearch/reference/current/dynamic-templates.html
+
+const MatchPatternRegex = "regex"
+
+type (
+	// DynamicTemplate chooses the type of new fields of ingested documents, like `dynamic_templates` in Elasticsearch.
+	DynamicTemplate struct {
+		Name               string
+		Match              []string // patterns of the field name (the last component of its path)
+		Unmatch            []string
+		PathMatch          []string // patterns of the full, dot-separated path of the field
+		PathUnmatch        []string
+		MatchPattern       string   // MatchPatternRegex makes Match and Unmatch regular expressions, otherwise they're simple `*` wildcards
+		MatchMappingType   []string // JSON types detected in documents: string, long, double, boolean, date, object or `*`
+		UnmatchMappingType []string
+		Type               string // Quesma type of matching fields, empty if the template doesn't set it (e.g. `{dynamic_type}`)
+	}
+
+	// SourceMapping is the `_source` field of a mapping, it filters `_source` returned by document APIs.
+	SourceMapping struct {
+		Disabled bool
+		Includes []string
+		Excludes []string
+	}
+)
+
+// Matches checks if the template applies to a new field, mappingType being the JSON type detected in the document.
+func (t DynamicTemplate) Matches(path, mappingType string) bool {
+	name := path
+	if i := strings.LastIndexByte(path, '.'); i >= 0 {
+		name = path[i+1:]
+	}
+	matchName := func(patterns []string) bool {
+		if t.MatchPattern == MatchPatternRegex {
+			return slices.ContainsFunc(patterns, func(pattern string) bool { return matchesRegex(pattern, name) })
+		}
+		return slices.ContainsFunc(patterns, func(pattern string) bool { return matchesWildcard(pattern, name) })
+	}
+	matchPath := func(patterns []string) bool {
+		return slices.ContainsFunc(patterns, func(pattern string) bool { return matchesWildcard(pattern, path) })
+	}
+	matchType := func(types []string) bool {
+		return slices.Contains(types, "*") || slices.Contains(types, mappingType)
+	}
+
+	if len(t.Match) > 0 && !matchName(t.Match) || matchName(t.Unmatch) {
+		return false
+	}
+	if len(t.PathMatch) > 0 && !matchPath(t.PathMatch) || matchPath(t.PathUnmatch) {
+		return false
+	}
+	if len(t.MatchMappingType) > 0 && !matchType(t.MatchMappingType) || matchType(t.UnmatchMappingType) {
+		return false
+	}
+	return true
+}
+
+// MatchDynamicTemplate returns the first template, which applies to a new field, like Elasticsearch does.
+func MatchDynamicTemplate(templates []DynamicTemplate, path, mappingType string) (DynamicTemplate, bool) {
+	for _, template := range templates {
+		if template.Matches(path, mappingType) {
+			return template, true
+		}
+	}
+	return DynamicTemplate{}, false
+}
+
+func matchesWildcard(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(value, part)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(part):]
+	}
+	return strings.HasSuffix(value, parts[len(parts)-1])
+}
+
+func matchesRegex(pattern, value string) bool {
+	// like Java's Pattern.matches, the whole value has to match
+	compiled, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return false
+	}
+	return compiled.MatchString(value)
+}
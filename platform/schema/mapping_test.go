@@ -0,0 +1,51 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package schema
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDynamicTemplateMatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		template    DynamicTemplate
+		path        string
+		mappingType string
+		matches     bool
+	}{
+		{"match name", DynamicTemplate{Match: []string{"*_id"}}, "user.account_id", "long", true},
+		{"match is not a path", DynamicTemplate{Match: []string{"user.*"}}, "user.name", "string", false},
+		{"unmatch", DynamicTemplate{Match: []string{"*_id"}, Unmatch: []string{"trace_*"}}, "trace_id", "string", false},
+		{"path match", DynamicTemplate{PathMatch: []string{"user.*"}}, "user.name", "string", true},
+		{"path unmatch", DynamicTemplate{PathMatch: []string{"user.*"}, PathUnmatch: []string{"*.middle"}}, "user.middle", "string", false},
+		{"any of patterns", DynamicTemplate{PathMatch: []string{"host.*", "user.*"}}, "user.name", "string", true},
+		{"middle wildcard", DynamicTemplate{Match: []string{"a*b*c"}}, "abxbc", "string", true},
+		{"middle wildcard, no suffix", DynamicTemplate{Match: []string{"a*b*c"}}, "abxb", "string", false},
+		{"mapping type", DynamicTemplate{MatchMappingType: []string{"string"}}, "message", "long", false},
+		{"any mapping type", DynamicTemplate{MatchMappingType: []string{"*"}}, "message", "long", true},
+		{"unmatch mapping type", DynamicTemplate{UnmatchMappingType: []string{"boolean"}}, "enabled", "boolean", false},
+		{"regex", DynamicTemplate{Match: []string{`^profit_\d+$`}, MatchPattern: MatchPatternRegex}, "profit_10", "long", true},
+		{"regex matches whole name", DynamicTemplate{Match: []string{`profit`}, MatchPattern: MatchPatternRegex}, "profit_10", "long", false},
+		{"no conditions", DynamicTemplate{}, "anything", "object", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.matches, tt.template.Matches(tt.path, tt.mappingType))
+		})
+	}
+}
+
+func TestMatchDynamicTemplate(t *testing.T) {
+	templates := []DynamicTemplate{
+		{Name: "ids", Match: []string{"*_id"}, Type: "keyword"},
+		{Name: "strings", MatchMappingType: []string{"string"}, Type: "text"},
+	}
+	template, found := MatchDynamicTemplate(templates, "session_id", "string")
+	assert.True(t, found)
+	assert.Equal(t, "ids", template.Name, "the first matching template should be used")
+
+	_, found = MatchDynamicTemplate(templates, "count", "long")
+	assert.False(t, found)
+}
@@ -25,6 +25,7 @@ type (
 		FindSchema(name IndexName) (Schema, bool)
 		UpdateFieldsOrigins(name IndexName, fields map[FieldName]FieldSource)
 		UpdateDynamicConfiguration(name IndexName, table Table)
+		GetDynamicConfiguration(name IndexName) (Table, bool)
 		UpdateFieldEncodings(encodings map[FieldEncodingKey]EncodedFieldName)
 		GetFieldEncodings() map[FieldEncodingKey]EncodedFieldName
 	}
@@ -62,12 +63,23 @@ type (
 	Table struct {
 		Columns      map[string]Column
 		DatabaseName string
+
+		// fields below are set only in the dynamic configuration, which comes from index mappings
+		DynamicTemplates []DynamicTemplate
+		Dynamic          string // `dynamic` of the mapping, empty if not set
+		Source           SourceMapping
 	}
 	Column struct {
 		Name    string
 		Type    string // FIXME: change to schema.Type
 		Comment string
 		Origin  FieldSource // TODO this field is just added to have way to forward information to the schema registry and should be considered as a technical debt
+
+		MultiFields     []string // names of multi-fields (`fields` of the mapping), they're aliases of the field
+		NotSearchable   bool     // `index: false` in the mapping
+		NotAggregatable bool     // `doc_values: false` in the mapping
+		ScalingFactor   float64  // `scaling_factor` of scaled_float fields
+		ConstantValue   string   // `value` of constant_keyword fields
 	}
 )
 
@@ -166,28 +178,38 @@ func (s *schemaRegistry) loadSchemas() (map[IndexName]Schema, error) {
 	}
 
 	for indexName, indexConfiguration := range *s.indexConfiguration {
-		fields := make(map[FieldName]Field)
-		aliases := make(map[FieldName]FieldName)
-		s.populateSchemaFromDynamicConfiguration(indexName, fields)
-		s.populateSchemaFromStaticConfiguration(indexConfiguration.SchemaOverrides, fields)
-		internalToPublicFieldsEncodings := s.getInternalToPublicFieldEncodings(indexName)
-		tableName := indexConfiguration.TableName(indexName)
-		existsInDataSource := s.populateSchemaFromTableDefinition(definitions, tableName, fields, internalToPublicFieldsEncodings)
-		s.populateAliases(indexConfiguration, fields, aliases)
-		s.removeIgnoredFields(indexConfiguration, fields, aliases)
-		s.removeGeoPhysicalFields(fields)
-		s.populateFieldsOrigins(indexName, fields)
-		if tableDefinition, ok := definitions[indexName]; ok {
-			schemas[IndexName(indexName)] = NewSchemaWithAliases(fields, aliases, existsInDataSource, tableDefinition.DatabaseName)
-		} else {
-			schemas[IndexName(indexName)] = NewSchemaWithAliases(fields, aliases, existsInDataSource, "")
+		schemas[IndexName(indexName)] = s.indexSchema(indexName, indexConfiguration, definitions)
+	}
+
+	// indexes, which aren't configured, but have a mapping (e.g. created with `PUT /:index` or from an index template)
+	for indexName := range s.dynamicConfiguration {
+		if _, hasConfig := (*s.indexConfiguration)[indexName]; !hasConfig {
+			schemas[IndexName(indexName)] = s.indexSchema(indexName, config.IndexConfiguration{SchemaOverrides: s.defaultSchemaOverrides}, definitions)
 		}
 	}
 
 	return schemas, nil
 }
 
-func (s *schemaRegistry) populateSchemaFromDynamicConfiguration(indexName string, fields map[FieldName]Field) {
+func (s *schemaRegistry) indexSchema(indexName string, indexConfiguration config.IndexConfiguration, definitions map[string]Table) Schema {
+	fields := make(map[FieldName]Field)
+	aliases := make(map[FieldName]FieldName)
+	s.populateSchemaFromDynamicConfiguration(indexName, fields, aliases)
+	s.populateSchemaFromStaticConfiguration(indexConfiguration.SchemaOverrides, fields)
+	internalToPublicFieldsEncodings := s.getInternalToPublicFieldEncodings(indexName)
+	tableName := indexConfiguration.TableName(indexName)
+	existsInDataSource := s.populateSchemaFromTableDefinition(definitions, tableName, fields, internalToPublicFieldsEncodings)
+	s.populateAliases(indexConfiguration, fields, aliases)
+	s.removeIgnoredFields(indexConfiguration, fields, aliases)
+	s.removeGeoPhysicalFields(fields)
+	s.populateFieldsOrigins(indexName, fields)
+	if tableDefinition, ok := definitions[indexName]; ok {
+		return NewSchemaWithAliases(fields, aliases, existsInDataSource, tableDefinition.DatabaseName)
+	}
+	return NewSchemaWithAliases(fields, aliases, existsInDataSource, "")
+}
+
+func (s *schemaRegistry) populateSchemaFromDynamicConfiguration(indexName string, fields map[FieldName]Field, aliases map[FieldName]FieldName) {
 	d, found := s.dynamicConfiguration[indexName]
 	if !found {
 		return
@@ -199,7 +221,18 @@ func (s *schemaRegistry) populateSchemaFromDynamicConfiguration(indexName string
 			continue
 		}
 
-		fields[FieldName(column.Name)] = Field{PropertyName: FieldName(column.Name), InternalPropertyName: FieldName(column.Name), Type: columnType, Origin: FieldSourceMapping}
+		if column.NotSearchable {
+			columnType = columnType.WithoutProperty(Searchable)
+		}
+		if column.NotAggregatable {
+			columnType = columnType.WithoutProperty(Aggregatable)
+		}
+
+		fields[FieldName(column.Name)] = Field{PropertyName: FieldName(column.Name), InternalPropertyName: FieldName(column.Name), Type: columnType, Origin: FieldSourceMapping,
+			ScalingFactor: column.ScalingFactor, ConstantValue: column.ConstantValue}
+		for _, multiField := range column.MultiFields {
+			aliases[FieldName(column.Name+"."+multiField)] = FieldName(column.Name)
+		}
 	}
 }
 
@@ -236,6 +269,14 @@ func (s *schemaRegistry) UpdateDynamicConfiguration(name IndexName, table Table)
 	s.invalidateCache()
 }
 
+func (s *schemaRegistry) GetDynamicConfiguration(name IndexName) (Table, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	table, found := s.dynamicConfiguration[name.AsString()]
+	return table, found
+}
+
 func (s *schemaRegistry) updateFieldEncodingsInternal(encodings map[FieldEncodingKey]EncodedFieldName) {
 
 	for key, value := range encodings {
@@ -312,6 +353,9 @@ func (s *schemaRegistry) populateSchemaFromTableDefinition(definitions map[strin
 		logger.Debug().Msgf("loading schema for table %s", indexName)
 
 		for _, column := range tableDefinition.Columns {
+			if column.Name == common_table.DocumentIdColumn { // technical column, exposed only as `_id` of hits
+				continue
+			}
 			var propertyName FieldName
 			if internalField, ok := internalToPublicFieldsEncodings[EncodedFieldName(column.Name)]; ok {
 				propertyName = FieldName(internalField)
@@ -343,7 +387,8 @@ func (s *schemaRegistry) populateSchemaFromTableDefinition(definitions map[strin
 					fields[propertyName] = Field{PropertyName: propertyName, InternalPropertyName: FieldName(column.Name), InternalPropertyType: column.Type, Type: QuesmaTypeKeyword, Origin: column.Origin}
 				}
 			} else {
-				fields[propertyName] = Field{PropertyName: propertyName, InternalPropertyName: FieldName(column.Name), InternalPropertyType: column.Type, Type: existing.Type, Origin: existing.Origin}
+				fields[propertyName] = Field{PropertyName: propertyName, InternalPropertyName: FieldName(column.Name), InternalPropertyType: column.Type, Type: existing.Type, Origin: existing.Origin,
+					ScalingFactor: existing.ScalingFactor, ConstantValue: existing.ConstantValue}
 			}
 		}
 	}
@@ -370,6 +415,11 @@ func (s *schemaRegistry) removeGeoPhysicalFields(fields map[FieldName]Field) {
 			delete(fields, fieldName+".lat")
 			delete(fields, fieldName+".lon")
 		}
+		if field.Type.IsRange() {
+			// same for bounds of ranges
+			delete(fields, fieldName+"."+RangeLowerBound)
+			delete(fields, fieldName+"."+RangeUpperBound)
+		}
 	}
 }
 
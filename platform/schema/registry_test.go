@@ -344,6 +344,39 @@ func Test_schemaRegistry_UpdateDynamicConfiguration(t *testing.T) {
 	}
 }
 
+func Test_schemaRegistry_DynamicConfigurationFromMapping(t *testing.T) {
+	tableName := "some_table"
+	cfg := config.QuesmaConfiguration{
+		IndexConfig: map[string]config.IndexConfiguration{
+			tableName: {QueryTarget: []string{config.ClickhouseTarget}, IngestTarget: []string{config.ClickhouseTarget}},
+		},
+	}
+	s := schema.NewSchemaRegistry(fixedTableProvider{tables: map[string]schema.Table{}}, &cfg, clickhouse.ClickhouseSchemaTypeAdapter{})
+
+	mapping := schema.Table{
+		Columns: map[string]schema.Column{
+			"message": {Name: "message", Type: "text", MultiFields: []string{"raw"}},
+			"payload": {Name: "payload", Type: "keyword", NotSearchable: true, NotAggregatable: true},
+		},
+		DynamicTemplates: []schema.DynamicTemplate{{Name: "ids", Match: []string{"*_id"}, Type: "keyword"}},
+	}
+	s.UpdateDynamicConfiguration(schema.IndexName(tableName), mapping)
+
+	stored, found := s.GetDynamicConfiguration(schema.IndexName(tableName))
+	assert.True(t, found)
+	assert.Equal(t, mapping, stored)
+
+	resultSchema, found := s.FindSchema(schema.IndexName(tableName))
+	assert.True(t, found)
+	assert.Equal(t, map[schema.FieldName]schema.FieldName{"message.raw": "message"}, resultSchema.Aliases)
+	assert.True(t, resultSchema.Fields["message"].Type.IsSearchable())
+	payloadType := resultSchema.Fields["payload"].Type
+	assert.Equal(t, schema.QuesmaTypeKeyword.Name, payloadType.Name)
+	assert.False(t, payloadType.IsSearchable())
+	assert.False(t, payloadType.IsAggregatable())
+	assert.True(t, schema.QuesmaTypeKeyword.IsSearchable(), "the shared type shouldn't be modified")
+}
+
 type fixedTableProvider struct {
 	tables map[string]schema.Table
 }
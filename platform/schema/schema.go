@@ -32,6 +32,8 @@ type (
 		InternalPropertyType string
 		Type                 QuesmaType
 		Origin               FieldSource
+		ScalingFactor        float64 // only for scaled_float fields
+		ConstantValue        string  // only for constant_keyword fields, empty if the value isn't set in the mapping
 	}
 	IndexName string
 	FieldName string
@@ -96,6 +98,17 @@ func (s Schema) ResolveField(fieldName string) (Field, bool) {
 	return field, exists
 }
 
+// ResolveFlattenedSubfield resolves a path inside a flattened field, e.g. `labels.env` of flattened `labels`.
+// It returns the flattened field and the key of the subfield in its map.
+func (s Schema) ResolveFlattenedSubfield(fieldName string) (field Field, key string, found bool) {
+	for i := strings.LastIndexByte(fieldName, '.'); i > 0; i = strings.LastIndexByte(fieldName[:i], '.') {
+		if field, ok := s.ResolveField(fieldName[:i]); ok && field.Type.Name == QuesmaTypeFlattened.Name {
+			return field, fieldName[i+1:], true
+		}
+	}
+	return Field{}, "", false
+}
+
 func (s Schema) IsInt(fieldName string) bool {
 	field, ok := s.ResolveField(fieldName)
 	return ok && field.Type.Name == QuesmaTypeInteger.Name
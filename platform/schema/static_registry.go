@@ -39,6 +39,11 @@ func (e *StaticRegistry) UpdateDynamicConfiguration(name IndexName, table Table)
 	e.DynamicConfiguration[name.AsString()] = table
 }
 
+func (e *StaticRegistry) GetDynamicConfiguration(name IndexName) (Table, bool) {
+	table, found := e.DynamicConfiguration[name.AsString()]
+	return table, found
+}
+
 func (e *StaticRegistry) UpdateFieldEncodings(encodings map[FieldEncodingKey]EncodedFieldName) {
 	if e.FieldEncodings == nil {
 		e.FieldEncodings = map[FieldEncodingKey]EncodedFieldName{}
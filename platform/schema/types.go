@@ -30,12 +30,43 @@ var (
 	QuesmaTypeIp           = QuesmaType{Name: "ip", Properties: []QuesmaTypeProperty{Searchable, Aggregatable}}
 	QuesmaTypePoint        = QuesmaType{Name: "point", Properties: []QuesmaTypeProperty{Searchable, Aggregatable}}
 	QuesmaTypeUnknown      = QuesmaType{Name: "unknown", Properties: []QuesmaTypeProperty{Searchable}}
+
+	QuesmaTypeFlattened       = QuesmaType{Name: "flattened", Properties: []QuesmaTypeProperty{Searchable, Aggregatable}}
+	QuesmaTypeWildcard        = QuesmaType{Name: "wildcard", Properties: []QuesmaTypeProperty{Searchable, Aggregatable}}
+	QuesmaTypeVersion         = QuesmaType{Name: "version", Properties: []QuesmaTypeProperty{Searchable, Aggregatable}}
+	QuesmaTypeScaledFloat     = QuesmaType{Name: "scaled_float", Properties: []QuesmaTypeProperty{Searchable, Aggregatable}}
+	QuesmaTypeConstantKeyword = QuesmaType{Name: "constant_keyword", Properties: []QuesmaTypeProperty{Searchable, Aggregatable}}
+	QuesmaTypeMatchOnlyText   = QuesmaType{Name: "match_only_text", Properties: []QuesmaTypeProperty{Searchable, FullText}}
+
+	// Range fields are stored in two columns, `<field>.gte` and `<field>.lte`, like points are stored in `.lat` and `.lon`
+	QuesmaTypeIntegerRange = QuesmaType{Name: "integer_range", Properties: []QuesmaTypeProperty{Searchable, Range}}
+	QuesmaTypeLongRange    = QuesmaType{Name: "long_range", Properties: []QuesmaTypeProperty{Searchable, Range}}
+	QuesmaTypeFloatRange   = QuesmaType{Name: "float_range", Properties: []QuesmaTypeProperty{Searchable, Range}}
+	QuesmaTypeDoubleRange  = QuesmaType{Name: "double_range", Properties: []QuesmaTypeProperty{Searchable, Range}}
+	QuesmaTypeDateRange    = QuesmaType{Name: "date_range", Properties: []QuesmaTypeProperty{Searchable, Range}}
+	QuesmaTypeIpRange      = QuesmaType{Name: "ip_range", Properties: []QuesmaTypeProperty{Searchable, Range}}
+)
+
+// rangeBoundTypes are types of bounds of range types
+var rangeBoundTypes = map[string]QuesmaType{
+	QuesmaTypeIntegerRange.Name: QuesmaTypeLong,
+	QuesmaTypeLongRange.Name:    QuesmaTypeLong,
+	QuesmaTypeFloatRange.Name:   QuesmaTypeFloat,
+	QuesmaTypeDoubleRange.Name:  QuesmaTypeFloat,
+	QuesmaTypeDateRange.Name:    QuesmaTypeTimestamp,
+	QuesmaTypeIpRange.Name:      QuesmaTypeIp,
+}
+
+const (
+	RangeLowerBound = "gte"
+	RangeUpperBound = "lte"
 )
 
 const (
 	Aggregatable QuesmaTypeProperty = "aggregatable"
 	Searchable   QuesmaTypeProperty = "searchable"
 	FullText     QuesmaTypeProperty = "full_text"
+	Range        QuesmaTypeProperty = "range"
 )
 
 func (t QuesmaType) Equal(t2 QuesmaType) bool {
@@ -54,6 +85,31 @@ func (t QuesmaType) IsFullText() bool {
 	return slices.Contains(t.Properties, FullText)
 }
 
+func (t QuesmaType) IsRange() bool {
+	return slices.Contains(t.Properties, Range)
+}
+
+// RangeBoundType returns the type of bounds of a range type, e.g. long for long_range
+func (t QuesmaType) RangeBoundType() (QuesmaType, bool) {
+	boundType, ok := rangeBoundTypes[t.Name]
+	return boundType, ok
+}
+
+// IsKeywordLike is true for types, which are searched and aggregated like keywords, by their exact values
+func (t QuesmaType) IsKeywordLike() bool {
+	switch t.Name {
+	case QuesmaTypeKeyword.Name, QuesmaTypeWildcard.Name, QuesmaTypeVersion.Name, QuesmaTypeConstantKeyword.Name:
+		return true
+	default:
+		return false
+	}
+}
+
+// WithoutProperty returns a copy of the type without the property, e.g. a field with `index: false` isn't Searchable
+func (t QuesmaType) WithoutProperty(property QuesmaTypeProperty) QuesmaType {
+	return QuesmaType{Name: t.Name, Properties: slices.DeleteFunc(slices.Clone(t.Properties), func(p QuesmaTypeProperty) bool { return p == property })}
+}
+
 func (t QuesmaType) String() string {
 	return t.Name
 }
@@ -86,6 +142,30 @@ func ParseQuesmaType(t string) (QuesmaType, bool) {
 		return QuesmaTypeIp, true
 	case QuesmaTypePoint.Name, "geo_point":
 		return QuesmaTypePoint, true
+	case QuesmaTypeFlattened.Name:
+		return QuesmaTypeFlattened, true
+	case QuesmaTypeWildcard.Name:
+		return QuesmaTypeWildcard, true
+	case QuesmaTypeVersion.Name:
+		return QuesmaTypeVersion, true
+	case QuesmaTypeScaledFloat.Name:
+		return QuesmaTypeScaledFloat, true
+	case QuesmaTypeConstantKeyword.Name:
+		return QuesmaTypeConstantKeyword, true
+	case QuesmaTypeMatchOnlyText.Name:
+		return QuesmaTypeMatchOnlyText, true
+	case QuesmaTypeIntegerRange.Name:
+		return QuesmaTypeIntegerRange, true
+	case QuesmaTypeLongRange.Name:
+		return QuesmaTypeLongRange, true
+	case QuesmaTypeFloatRange.Name:
+		return QuesmaTypeFloatRange, true
+	case QuesmaTypeDoubleRange.Name:
+		return QuesmaTypeDoubleRange, true
+	case QuesmaTypeDateRange.Name:
+		return QuesmaTypeDateRange, true
+	case QuesmaTypeIpRange.Name:
+		return QuesmaTypeIpRange, true
 	default:
 		return QuesmaTypeUnknown, false
 	}
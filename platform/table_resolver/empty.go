@@ -4,6 +4,7 @@ package table_resolver
 
 import (
 	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/index_templates"
 	"github.com/QuesmaOrg/quesma/platform/v2/core"
 )
 
@@ -40,6 +41,9 @@ func (r *EmptyTableResolver) Pipelines() []string {
 	return r.PipelinesList
 }
 
+func (r *EmptyTableResolver) SetIndexTemplates(_ *index_templates.Store) {
+}
+
 func (r *EmptyTableResolver) Start() {
 }
 
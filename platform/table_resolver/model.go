@@ -3,6 +3,7 @@
 package table_resolver
 
 import (
+	"github.com/QuesmaOrg/quesma/platform/index_templates"
 	"github.com/QuesmaOrg/quesma/platform/v2/core"
 )
 
@@ -14,4 +15,7 @@ type TableResolver interface {
 
 	Pipelines() []string
 	RecentDecisions() []quesma_api.PatternDecisions
+
+	// SetIndexTemplates makes the resolver apply table settings of index templates to new indexes
+	SetIndexTemplates(store *index_templates.Store)
 }
@@ -164,6 +164,43 @@ func makeDefaultWildcard(quesmaConf config.QuesmaConfiguration, pipeline string)
 	}
 }
 
+// indexTemplate applies `tableName` and `useCommonTable` settings of index templates to indexes, which aren't configured.
+// Like Elasticsearch, templates are meant for new indexes, so indexes having their own tables keep using them.
+func (r *tableRegistryImpl) indexTemplate(quesmaConf config.QuesmaConfiguration, pipeline string) func(part string) *quesma_api.Decision {
+	defaultWildcard := makeDefaultWildcard(quesmaConf, pipeline)
+
+	return func(part string) *quesma_api.Decision {
+		if r.indexTemplates == nil {
+			return nil
+		}
+		if existing, ok := r.clickhouseIndexes[part]; ok && !existing.isVirtual {
+			return nil
+		}
+		resolved, ok := r.indexTemplates.Resolve(part)
+		if !ok || (resolved.Quesma.TableName == "" && !resolved.Quesma.UseCommonTable) {
+			return nil
+		}
+
+		decision := defaultWildcard(part)
+		if decision.Err != nil {
+			return decision
+		}
+		for _, connector := range decision.UseConnectors {
+			if clickhouseDecision, ok := connector.(*quesma_api.ConnectorDecisionClickhouse); ok {
+				if resolved.Quesma.UseCommonTable {
+					clickhouseDecision.ClickhouseTableName = common_table.TableName
+					clickhouseDecision.IsCommonTable = true
+				} else {
+					clickhouseDecision.ClickhouseTableName = resolved.Quesma.TableName
+					clickhouseDecision.IsCommonTable = false
+				}
+			}
+		}
+		decision.Reason = fmt.Sprintf("Using index template '%s' for %s processor", resolved.IndexTemplate, pipeline)
+		return decision
+	}
+}
+
 func (r *tableRegistryImpl) singleIndex(indexConfig map[string]config.IndexConfiguration, pipeline string) func(part string) *quesma_api.Decision {
 
 	return func(part string) *quesma_api.Decision {
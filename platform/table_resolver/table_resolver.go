@@ -9,6 +9,7 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/config"
 	"github.com/QuesmaOrg/quesma/platform/database_common"
 	"github.com/QuesmaOrg/quesma/platform/elasticsearch"
+	"github.com/QuesmaOrg/quesma/platform/index_templates"
 	"github.com/QuesmaOrg/quesma/platform/logger"
 	"github.com/QuesmaOrg/quesma/platform/recovery"
 	"github.com/QuesmaOrg/quesma/platform/types"
@@ -118,6 +119,8 @@ type tableRegistryImpl struct {
 
 	pipelineResolvers map[string]*pipelineResolver
 	conf              config.QuesmaConfiguration
+
+	indexTemplates *index_templates.Store
 }
 
 func (r *tableRegistryImpl) Resolve(pipeline string, indexPattern string) *quesma_api.Decision {
@@ -267,6 +270,24 @@ func (r *tableRegistryImpl) RecentDecisions() []quesma_api.PatternDecisions {
 	return res
 }
 
+func (r *tableRegistryImpl) SetIndexTemplates(store *index_templates.Store) {
+	r.m.Lock()
+	r.indexTemplates = store
+	r.m.Unlock()
+
+	// decisions depend on templates, so they're made again when templates change
+	store.AddChangeListener(r.forgetRecentDecisions)
+	r.forgetRecentDecisions()
+}
+
+func (r *tableRegistryImpl) forgetRecentDecisions() {
+	r.m.Lock()
+	defer r.m.Unlock()
+	for _, res := range r.pipelineResolvers {
+		res.recentDecisions = make(map[string]*quesma_api.Decision)
+	}
+}
+
 func (r *tableRegistryImpl) Pipelines() []string {
 
 	r.m.Lock()
@@ -316,6 +337,7 @@ func NewTableResolver(quesmaConf config.QuesmaConfiguration, discovery database_
 				{"singleIndex", res.singleIndex(indexConf, quesma_api.IngestPipeline)},
 				{"commonTable", res.makeCommonTableResolver(indexConf, quesma_api.IngestPipeline)},
 
+				{"indexTemplate", res.indexTemplate(quesmaConf, quesma_api.IngestPipeline)},
 				{"defaultWildcard", makeDefaultWildcard(quesmaConf, quesma_api.IngestPipeline)},
 			},
 			decisionMerger: &basicDecisionMerger{checkIfMatchingDifferentTables: true},
@@ -341,6 +363,8 @@ func NewTableResolver(quesmaConf config.QuesmaConfiguration, discovery database_
 				{"singleIndex", res.singleIndex(indexConf, quesma_api.QueryPipeline)},
 				{"commonTable", res.makeCommonTableResolver(indexConf, quesma_api.QueryPipeline)},
 
+				{"indexTemplate", res.indexTemplate(quesmaConf, quesma_api.QueryPipeline)},
+
 				// default action
 				{"defaultWildcard", makeDefaultWildcard(quesmaConf, quesma_api.QueryPipeline)},
 			},
@@ -367,6 +391,8 @@ func NewTableResolver(quesmaConf config.QuesmaConfiguration, discovery database_
 				{"singleIndex", res.singleIndex(indexConf, quesma_api.QueryPipeline)},
 				{"commonTable", res.makeCommonTableResolver(indexConf, quesma_api.QueryPipeline)},
 
+				{"indexTemplate", res.indexTemplate(quesmaConf, quesma_api.QueryPipeline)},
+
 				// default action
 				{"defaultWildcard", makeDefaultWildcard(quesmaConf, quesma_api.QueryPipeline)},
 			},
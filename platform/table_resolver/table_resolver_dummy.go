@@ -7,6 +7,7 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/common_table"
 	"github.com/QuesmaOrg/quesma/platform/config"
 	"github.com/QuesmaOrg/quesma/platform/elasticsearch"
+	"github.com/QuesmaOrg/quesma/platform/index_templates"
 	mux "github.com/QuesmaOrg/quesma/platform/v2/core"
 )
 
@@ -25,6 +26,8 @@ func (t DummyTableResolver) Start() {}
 
 func (t DummyTableResolver) Stop() {}
 
+func (t DummyTableResolver) SetIndexTemplates(_ *index_templates.Store) {}
+
 func (t DummyTableResolver) Resolve(_ string, indexPattern string) *mux.Decision {
 	if elasticsearch.IsInternalIndex(indexPattern) { // e.g. `.kibana_analytics_8.11.1`
 		return t.resolveElastic()
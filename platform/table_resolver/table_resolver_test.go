@@ -8,6 +8,9 @@ import (
 	"github.com/QuesmaOrg/quesma/platform/config"
 	"github.com/QuesmaOrg/quesma/platform/database_common"
 	"github.com/QuesmaOrg/quesma/platform/elasticsearch"
+	"github.com/QuesmaOrg/quesma/platform/index_templates"
+	"github.com/QuesmaOrg/quesma/platform/persistence"
+	"github.com/QuesmaOrg/quesma/platform/types"
 	"github.com/QuesmaOrg/quesma/platform/util"
 	mux "github.com/QuesmaOrg/quesma/platform/v2/core"
 	"github.com/k0kubun/pp"
@@ -448,3 +451,59 @@ func TestTableResolver(t *testing.T) {
 	}
 
 }
+
+func TestTableResolverIndexTemplates(t *testing.T) {
+	cfg := config.QuesmaConfiguration{DefaultQueryTarget: []string{config.ClickhouseTarget}, DefaultIngestTarget: []string{config.ClickhouseTarget}}
+
+	tableDiscovery := database_common.NewEmptyTableDiscovery()
+	tableDiscovery.TableMap.Store("logs-old", &database_common.Table{Name: "logs-old"})
+
+	store := index_templates.NewStore(persistence.NewStaticJSONDatabase(), persistence.NewStaticJSONDatabase())
+	assert.NoError(t, store.PutIndexTemplate("logs", types.MustJSON(`{"index_patterns":["logs-main"],"template":{"settings":{"index.quesma.tableName":"logs"}}}`)))
+	assert.NoError(t, store.PutIndexTemplate("metrics", types.MustJSON(`{"index_patterns":["metrics-*"],"template":{"settings":{"quesma":{"useCommonTable":true}}}}`)))
+	assert.NoError(t, store.PutIndexTemplate("app", types.MustJSON(`{"index_patterns":["app-*"],"template":{"settings":{"index.quesma.partitioningStrategy":"daily"}}}`)))
+
+	resolver := NewTableResolver(cfg, tableDiscovery, elasticsearch.NewFixedIndexManagement())
+	resolver.SetIndexTemplates(store)
+
+	tableOf := func(pipeline, index string) (string, bool) {
+		decision := resolver.Resolve(pipeline, index)
+		assert.NoError(t, decision.Err)
+		assert.Len(t, decision.UseConnectors, 1)
+		clickhouseDecision := decision.UseConnectors[0].(*mux.ConnectorDecisionClickhouse)
+		return clickhouseDecision.ClickhouseTableName, clickhouseDecision.IsCommonTable
+	}
+
+	for _, pipeline := range []string{mux.IngestPipeline, mux.QueryPipeline} {
+		tableName, isCommonTable := tableOf(pipeline, "logs-main")
+		assert.Equal(t, "logs", tableName)
+		assert.False(t, isCommonTable)
+
+		// indexes matching one template are kept apart: in the common table by their index name column, otherwise in their own tables
+		for _, index := range []string{"metrics-a", "metrics-b"} {
+			tableName, isCommonTable = tableOf(pipeline, index)
+			assert.Equal(t, common_table.TableName, tableName)
+			assert.True(t, isCommonTable)
+		}
+		tableName, isCommonTable = tableOf(pipeline, "app-a")
+		assert.Equal(t, "app-a", tableName)
+		assert.False(t, isCommonTable)
+		tableName, isCommonTable = tableOf(pipeline, "app-b")
+		assert.Equal(t, "app-b", tableName)
+		assert.False(t, isCommonTable)
+
+		// templates are applied to new indexes only
+		tableName, _ = tableOf(pipeline, "logs-old")
+		assert.Equal(t, "logs-old", tableName)
+
+		tableName, _ = tableOf(pipeline, "other")
+		assert.Equal(t, "other", tableName)
+	}
+
+	// decisions are made again when templates change
+	found, err := store.DeleteIndexTemplate("logs")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	tableName, _ := tableOf(mux.IngestPipeline, "logs-main")
+	assert.Equal(t, "logs-main", tableName)
+}
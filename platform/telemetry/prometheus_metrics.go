@@ -32,6 +32,36 @@ var (
 			Buckets: prometheus.DefBuckets,
 		},
 	)
+
+	ingestBufferDocuments = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "quesma_ingest_buffer_documents",
+			Help: "Number of documents waiting in the ingest buffer, per index",
+		},
+		[]string{"index"},
+	)
+
+	ingestBufferBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "quesma_ingest_buffer_bytes",
+			Help: "Size of documents waiting in the ingest buffer",
+		},
+	)
+
+	ingestBufferRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "quesma_ingest_buffer_retries_total",
+			Help: "Total number of failed inserts from the ingest buffer, which have been retried",
+		},
+		[]string{"index"},
+	)
+
+	ingestBufferRejected = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "quesma_ingest_buffer_rejected_total",
+			Help: "Total number of ingest requests rejected, because the ingest buffer was full",
+		},
+	)
 )
 
 type ingestionCounterWrapper struct {
@@ -87,9 +117,36 @@ func (w *spanDurationWrapper) End(err error) time.Duration {
 	return duration
 }
 
+// IngestBufferMetrics reports the state of the ingest buffer, see buffer.Metrics
+type IngestBufferMetrics struct{}
+
+func NewIngestBufferMetrics() *IngestBufferMetrics {
+	return &IngestBufferMetrics{}
+}
+
+func (m *IngestBufferMetrics) SetQueueDepth(index string, documents int) {
+	ingestBufferDocuments.WithLabelValues(index).Set(float64(documents))
+}
+
+func (m *IngestBufferMetrics) SetSize(bytes int64) {
+	ingestBufferBytes.Set(float64(bytes))
+}
+
+func (m *IngestBufferMetrics) Retried(index string) {
+	ingestBufferRetries.WithLabelValues(index).Inc()
+}
+
+func (m *IngestBufferMetrics) Rejected() {
+	ingestBufferRejected.Inc()
+}
+
 func init() {
 	// Register metrics with Prometheus
 	prometheus.MustRegister(ingestionTotalCount)
 	prometheus.MustRegister(clickHouseRequestQueryDuration)
 	prometheus.MustRegister(clickHouseRequestIngestDuration)
+	prometheus.MustRegister(ingestBufferDocuments)
+	prometheus.MustRegister(ingestBufferBytes)
+	prometheus.MustRegister(ingestBufferRetries)
+	prometheus.MustRegister(ingestBufferRejected)
 }
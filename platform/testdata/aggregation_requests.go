@@ -4495,7 +4495,7 @@ var AggregationTests = []AggregationTestCase{
 			WHERE ("timestamp">=__quesma_from_unixtime64mili(1712388530059) AND "timestamp"<=__quesma_from_unixtime64mili(1713288530059))`,
 	},
 	{ // [23]
-		TestName: "significant terms aggregation: same as terms for now",
+		TestName: "significant terms aggregation: JLH score from foreground and background frequencies",
 		QueryRequestJson: `
 		{
 			"_source": {
@@ -4509,35 +4509,26 @@ var AggregationTests = []AggregationTestCase{
 					}
 				}
 			},
-			"docvalue_fields": [
-				{
-					"field": "epoch_time",
-					"format": "date_time"
-				},
-				{
-					"field": "ts_time_druid",
-					"format": "date_time"
-				}
-			],
 			"query": {
 				"bool": {
-					"filter": [],
-					"must": [
+					"filter": [
 						{
-							"match_all": {}
+							"range": {
+								"timestamp": {
+									"format": "strict_date_optional_time",
+									"gte": "2024-04-06T07:28:50.059Z",
+									"lte": "2024-04-16T17:28:50.059Z"
+								}
+							}
 						}
-					],
-					"must_not": [],
-					"should": []
+					]
 				}
 			},
-			"script_fields": {},
 			"size": 0,
-			"stored_fields": [
-				"*"
-			],
 			"track_total_hits": true
 		}`,
+		// "b" isn't returned, as it's less frequent in the foreground set than in the background set (score is 0),
+		// and "x", as it's in fewer than 3 (default min_doc_count) documents.
 		ExpectedResponse: `
 		{
 			"is_partial": false,
@@ -4554,20 +4545,20 @@ var AggregationTests = []AggregationTestCase{
 				},
 				"aggregations": {
 					"2": {
-						"bg_count": 825,
+						"bg_count": 10000,
 						"doc_count": 825,
 						"buckets": [
-							{
-								"bg_count": 619,
-								"doc_count": 619,
-								"key": "a",
-								"score": 619
-							},
 							{
 								"bg_count": 206,
 								"doc_count": 206,
 								"key": "zip",
-								"score": 206
+								"score": 2.776932966023875
+							},
+							{
+								"bg_count": 1200,
+								"doc_count": 500,
+								"key": "a",
+								"score": 2.4548515457606372
 							}
 						]
 					}
@@ -4577,7 +4568,7 @@ var AggregationTests = []AggregationTestCase{
 					"max_score": null,
 					"total": {
 						"relation": "eq",
-						"value": 1608
+						"value": 825
 					}
 				},
 				"timed_out": false,
@@ -4586,24 +4577,50 @@ var AggregationTests = []AggregationTestCase{
 		}`,
 		ExpectedPancakeResults: []model.QueryResultRow{
 			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__2__parent_bg_count", uint64(10000)),
+				model.NewQueryResultCol("aggr__2__bg_count", uint64(1200)),
 				model.NewQueryResultCol("aggr__2__parent_count", uint64(825)),
 				model.NewQueryResultCol("aggr__2__key_0", "a"),
-				model.NewQueryResultCol("aggr__2__count", uint64(619)),
+				model.NewQueryResultCol("aggr__2__count", uint64(500)),
 			}},
 			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__2__parent_bg_count", uint64(10000)),
+				model.NewQueryResultCol("aggr__2__bg_count", uint64(206)),
 				model.NewQueryResultCol("aggr__2__parent_count", uint64(825)),
 				model.NewQueryResultCol("aggr__2__key_0", "zip"),
 				model.NewQueryResultCol("aggr__2__count", uint64(206)),
 			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__2__parent_bg_count", uint64(10000)),
+				model.NewQueryResultCol("aggr__2__bg_count", uint64(8000)),
+				model.NewQueryResultCol("aggr__2__parent_count", uint64(825)),
+				model.NewQueryResultCol("aggr__2__key_0", "b"),
+				model.NewQueryResultCol("aggr__2__count", uint64(100)),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__2__parent_bg_count", uint64(10000)),
+				model.NewQueryResultCol("aggr__2__bg_count", uint64(2)),
+				model.NewQueryResultCol("aggr__2__parent_count", uint64(825)),
+				model.NewQueryResultCol("aggr__2__key_0", "x"),
+				model.NewQueryResultCol("aggr__2__count", uint64(2)),
+			}},
 		},
 		ExpectedPancakeSQL: `
-			SELECT sum(count(*)) OVER () AS "aggr__2__parent_count",
-			  "message" AS "aggr__2__key_0", 
+			SELECT (SELECT count(*) FROM ` + TableName + `) AS "aggr__2__parent_bg_count",
+			  arrayElement((SELECT mapFromArrays(groupArray("term"), groupArray("count"))
+			  FROM (SELECT "message" AS "term", count(*) AS "count" FROM ` + TableName + `
+			  WHERE "message" IN (SELECT "message" FROM ` + TableName + `
+			    WHERE ("timestamp">=__quesma_from_unixtime64mili(1712388530059) AND "timestamp"<=__quesma_from_unixtime64mili(1713288530059))
+			    GROUP BY "message" ORDER BY count(*) DESC, "message" ASC LIMIT 33)
+			  GROUP BY "message")), "message") AS "aggr__2__bg_count",
+			  sum(count(*)) OVER () AS "aggr__2__parent_count",
+			  "message" AS "aggr__2__key_0",
 			  count(*) AS "aggr__2__count"
 			FROM ` + TableName + `
+			WHERE ("timestamp">=__quesma_from_unixtime64mili(1712388530059) AND "timestamp"<=__quesma_from_unixtime64mili(1713288530059))
 			GROUP BY "message" AS "aggr__2__key_0"
 			ORDER BY "aggr__2__count" DESC, "aggr__2__key_0" ASC
-			LIMIT 5`,
+			LIMIT 33`,
 	},
 	{ // [24]
 		TestName: "meta field in aggregation",
@@ -550,7 +550,7 @@ var AggregationTests2 = []AggregationTestCase{
 			},
 			"aggregations": {
 				"2": {
-					"bg_count": 2786,
+					"bg_count": 14074,
 					"doc_count": 2786,
 					"buckets": [
 						{
@@ -571,10 +571,10 @@ var AggregationTests2 = []AggregationTestCase{
 							"2": {
 								"value": 10
 							},
-							"bg_count": 2570,
+							"bg_count": 12832,
 							"doc_count": 2570,
 							"key": "200",
-							"score": 2570
+							"score": 0.010843301523130379
 						}
 					]
 				}
@@ -592,6 +592,8 @@ var AggregationTests2 = []AggregationTestCase{
 		}`,
 		ExpectedPancakeResults: []model.QueryResultRow{
 			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__2__parent_bg_count", uint64(14074)),
+				model.NewQueryResultCol("aggr__2__bg_count", uint64(12832)),
 				model.NewQueryResultCol("aggr__2__parent_count", 2786),
 				model.NewQueryResultCol("aggr__2__key_0", "200"),
 				model.NewQueryResultCol("aggr__2__count", 2570),
@@ -601,7 +603,14 @@ var AggregationTests2 = []AggregationTestCase{
 			}},
 		},
 		ExpectedPancakeSQL: `
-			SELECT sum(count(*)) OVER () AS "aggr__2__parent_count",
+			SELECT (SELECT count(*) FROM __quesma_table_name) AS "aggr__2__parent_bg_count",
+			  arrayElement((SELECT mapFromArrays(groupArray("term"), groupArray("count"))
+			  FROM (SELECT "response" AS "term", count(*) AS "count" FROM __quesma_table_name
+			  WHERE "response" IN (SELECT "response" FROM __quesma_table_name
+			    WHERE ("timestamp">=__quesma_from_unixtime64mili(1713401475845) AND "timestamp"<=__quesma_from_unixtime64mili(1714697475845))
+			    GROUP BY "response" ORDER BY count(*) DESC, "response" ASC LIMIT 29)
+			  GROUP BY "response")), "response") AS "aggr__2__bg_count",
+			  sum(count(*)) OVER () AS "aggr__2__parent_count",
 			  "response" AS "aggr__2__key_0", count(*) AS "aggr__2__count",
 			  quantiles(0.010000)("timestamp") AS "metric__2__1_col_0",
 			  quantiles(0.020000)("timestamp") AS "metric__2__1_col_1",
@@ -610,7 +619,7 @@ var AggregationTests2 = []AggregationTestCase{
 			WHERE ("timestamp">=__quesma_from_unixtime64mili(1713401475845) AND "timestamp"<=__quesma_from_unixtime64mili(1714697475845))
 			GROUP BY "response" AS "aggr__2__key_0"
 			ORDER BY "aggr__2__count" DESC, "aggr__2__key_0" ASC
-			LIMIT 4`,
+			LIMIT 29`,
 	},
 	{ // [44]
 		TestName: "2x terms with nulls 1/4, nulls in second aggregation, with missing parameter",
@@ -5439,4 +5448,1036 @@ var AggregationTests2 = []AggregationTestCase{
 			ORDER BY "aggr__terms__count" DESC, "aggr__terms__key_0" ASC
 			LIMIT 1`,
 	},
+	{ // [79]
+		TestName: "significant_text in sampler",
+		QueryRequestJson: `
+		{
+			"query": {
+				"match": {
+					"message": "flu"
+				}
+			},
+			"aggs": {
+				"my_sample": {
+					"sampler": {
+						"shard_size": 100
+					},
+					"aggs": {
+						"keywords": {
+							"significant_text": {
+								"field": "message"
+							}
+						}
+					}
+				}
+			},
+			"size": 0,
+			"track_total_hits": false
+		}`,
+		// "the" is in most documents in the background set, so it isn't significant.
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"my_sample": {
+					"doc_count": 60,
+					"keywords": {
+						"doc_count": 60,
+						"bg_count": 10000,
+						"buckets": [
+							{
+								"key": "bird",
+								"doc_count": 60,
+								"score": 124.0,
+								"bg_count": 80
+							},
+							{
+								"key": "flu",
+								"doc_count": 58,
+								"score": 123.62592592592593,
+								"bg_count": 75
+							},
+							{
+								"key": "h5n1",
+								"doc_count": 20,
+								"score": 55.222222222222214,
+								"bg_count": 20
+							}
+						]
+					}
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__my_sample__count", uint64(60)),
+				model.NewQueryResultCol("aggr__my_sample__keywords__parent_bg_count", uint64(10000)),
+				model.NewQueryResultCol("aggr__my_sample__keywords__bg_count", uint64(80)),
+				model.NewQueryResultCol("aggr__my_sample__keywords__parent_count", uint64(60)),
+				model.NewQueryResultCol("aggr__my_sample__keywords__key_0", "bird"),
+				model.NewQueryResultCol("aggr__my_sample__keywords__count", uint64(60)),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__my_sample__count", uint64(60)),
+				model.NewQueryResultCol("aggr__my_sample__keywords__parent_bg_count", uint64(10000)),
+				model.NewQueryResultCol("aggr__my_sample__keywords__bg_count", uint64(75)),
+				model.NewQueryResultCol("aggr__my_sample__keywords__parent_count", uint64(60)),
+				model.NewQueryResultCol("aggr__my_sample__keywords__key_0", "flu"),
+				model.NewQueryResultCol("aggr__my_sample__keywords__count", uint64(58)),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__my_sample__count", uint64(60)),
+				model.NewQueryResultCol("aggr__my_sample__keywords__parent_bg_count", uint64(10000)),
+				model.NewQueryResultCol("aggr__my_sample__keywords__bg_count", uint64(9500)),
+				model.NewQueryResultCol("aggr__my_sample__keywords__parent_count", uint64(60)),
+				model.NewQueryResultCol("aggr__my_sample__keywords__key_0", "the"),
+				model.NewQueryResultCol("aggr__my_sample__keywords__count", uint64(55)),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__my_sample__count", uint64(60)),
+				model.NewQueryResultCol("aggr__my_sample__keywords__parent_bg_count", uint64(10000)),
+				model.NewQueryResultCol("aggr__my_sample__keywords__bg_count", uint64(20)),
+				model.NewQueryResultCol("aggr__my_sample__keywords__parent_count", uint64(60)),
+				model.NewQueryResultCol("aggr__my_sample__keywords__key_0", "h5n1"),
+				model.NewQueryResultCol("aggr__my_sample__keywords__count", uint64(20)),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT (SELECT count(*) FROM (SELECT 1 FROM __quesma_table_name
+			  WHERE "message" __quesma_match '%flu%' LIMIT 400)) AS "aggr__my_sample__count",
+			  (SELECT count(*) FROM __quesma_table_name) AS "aggr__my_sample__keywords__parent_bg_count",
+			  arrayElement((SELECT mapFromArrays(groupArray("term"), groupArray("count"))
+			  FROM (SELECT arrayJoin(arrayDistinct(splitByNonAlpha(lower(COALESCE("message", ''))))) AS "term", count(*) AS "count"
+			  FROM __quesma_table_name
+			  WHERE arrayJoin(arrayDistinct(splitByNonAlpha(lower(COALESCE("message", ''))))) IN (
+			    SELECT arrayJoin(arrayDistinct(splitByNonAlpha(lower(COALESCE("message", '')))))
+			    FROM __quesma_table_name WHERE "message" __quesma_match '%flu%')
+			  GROUP BY arrayJoin(arrayDistinct(splitByNonAlpha(lower(COALESCE("message", ''))))))),
+			  arrayJoin(arrayDistinct(splitByNonAlpha(lower(COALESCE("message", '')))))) AS "aggr__my_sample__keywords__bg_count",
+			  (SELECT count(*) FROM (SELECT 1 FROM __quesma_table_name
+			  WHERE "message" __quesma_match '%flu%' LIMIT 400)) AS "aggr__my_sample__keywords__parent_count",
+			  arrayJoin(arrayDistinct(splitByNonAlpha(lower(COALESCE("message", ''))))) AS "aggr__my_sample__keywords__key_0",
+			  count(*) AS "aggr__my_sample__keywords__count"
+			FROM (
+			  SELECT "message"
+			  FROM __quesma_table_name
+			  WHERE "message" __quesma_match '%flu%'
+			  LIMIT 400)
+			GROUP BY arrayJoin(arrayDistinct(splitByNonAlpha(lower(COALESCE("message", ''))))) AS "aggr__my_sample__keywords__key_0"
+			ORDER BY "aggr__my_sample__keywords__count" DESC,
+			  "aggr__my_sample__keywords__key_0" ASC
+			LIMIT 51`,
+	},
+	{ // [80]
+		TestName: "rare_terms",
+		QueryRequestJson: `
+		{
+			"aggs": {
+				"rare_processes": {
+					"rare_terms": {
+						"field": "process.name",
+						"max_doc_count": 2
+					}
+				}
+			},
+			"size": 0,
+			"track_total_hits": false
+		}`,
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"rare_processes": {
+					"buckets": [
+						{
+							"key": "mimikatz.exe",
+							"doc_count": 1
+						},
+						{
+							"key": "psexec.exe",
+							"doc_count": 1
+						},
+						{
+							"key": "nc.exe",
+							"doc_count": 2
+						}
+					]
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__rare_processes__key_0", "mimikatz.exe"),
+				model.NewQueryResultCol("aggr__rare_processes__count", uint64(1)),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__rare_processes__key_0", "psexec.exe"),
+				model.NewQueryResultCol("aggr__rare_processes__count", uint64(1)),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__rare_processes__key_0", "nc.exe"),
+				model.NewQueryResultCol("aggr__rare_processes__count", uint64(2)),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT "aggr__rare_processes__key_0", "aggr__rare_processes__count"
+			FROM (
+			  SELECT "aggr__rare_processes__key_0", "aggr__rare_processes__count",
+			    dense_rank() OVER (ORDER BY "aggr__rare_processes__count" ASC,
+			    "aggr__rare_processes__key_0" ASC) AS "aggr__rare_processes__order_1_rank"
+			  FROM (
+			    SELECT "process.name" AS "aggr__rare_processes__key_0",
+			      count(*) AS "aggr__rare_processes__count"
+			    FROM __quesma_table_name
+			    GROUP BY "process.name" AS "aggr__rare_processes__key_0"))
+			WHERE "aggr__rare_processes__count"<=2
+			ORDER BY "aggr__rare_processes__order_1_rank" ASC`,
+	},
+	{ // [81]
+		TestName: "rare_terms inside terms, with missing",
+		QueryRequestJson: `
+		{
+			"aggs": {
+				"hosts": {
+					"terms": {
+						"field": "host.name",
+						"size": 2
+					},
+					"aggs": {
+						"rare_processes": {
+							"rare_terms": {
+								"field": "process.name",
+								"missing": "N/A"
+							}
+						}
+					}
+				}
+			},
+			"size": 0,
+			"track_total_hits": false
+		}`,
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"hosts": {
+					"doc_count_error_upper_bound": 0,
+					"sum_other_doc_count": 100,
+					"buckets": [
+						{
+							"key": "host-a",
+							"doc_count": 500,
+							"rare_processes": {
+								"buckets": [
+									{
+										"key": "N/A",
+										"doc_count": 1
+									},
+									{
+										"key": "psexec.exe",
+										"doc_count": 1
+									}
+								]
+							}
+						},
+						{
+							"key": "host-b",
+							"doc_count": 400,
+							"rare_processes": {
+								"buckets": [
+									{
+										"key": "nc.exe",
+										"doc_count": 1
+									}
+								]
+							}
+						}
+					]
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__hosts__parent_count", uint64(1000)),
+				model.NewQueryResultCol("aggr__hosts__key_0", "host-a"),
+				model.NewQueryResultCol("aggr__hosts__count", uint64(500)),
+				model.NewQueryResultCol("aggr__hosts__rare_processes__key_0", "N/A"),
+				model.NewQueryResultCol("aggr__hosts__rare_processes__count", uint64(1)),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__hosts__parent_count", uint64(1000)),
+				model.NewQueryResultCol("aggr__hosts__key_0", "host-a"),
+				model.NewQueryResultCol("aggr__hosts__count", uint64(500)),
+				model.NewQueryResultCol("aggr__hosts__rare_processes__key_0", "psexec.exe"),
+				model.NewQueryResultCol("aggr__hosts__rare_processes__count", uint64(1)),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__hosts__parent_count", uint64(1000)),
+				model.NewQueryResultCol("aggr__hosts__key_0", "host-b"),
+				model.NewQueryResultCol("aggr__hosts__count", uint64(400)),
+				model.NewQueryResultCol("aggr__hosts__rare_processes__key_0", "nc.exe"),
+				model.NewQueryResultCol("aggr__hosts__rare_processes__count", uint64(1)),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT "aggr__hosts__parent_count", "aggr__hosts__key_0", "aggr__hosts__count",
+			  "aggr__hosts__rare_processes__key_0", "aggr__hosts__rare_processes__count"
+			FROM (
+			  SELECT "aggr__hosts__parent_count", "aggr__hosts__key_0",
+			    "aggr__hosts__count", "aggr__hosts__rare_processes__key_0",
+			    "aggr__hosts__rare_processes__count",
+			    dense_rank() OVER (ORDER BY "aggr__hosts__count" DESC, "aggr__hosts__key_0"
+			    ASC) AS "aggr__hosts__order_1_rank",
+			    dense_rank() OVER (PARTITION BY "aggr__hosts__key_0" ORDER BY
+			    "aggr__hosts__rare_processes__count" ASC,
+			    "aggr__hosts__rare_processes__key_0" ASC) AS
+			    "aggr__hosts__rare_processes__order_1_rank"
+			  FROM (
+			    SELECT sum(count(*)) OVER () AS "aggr__hosts__parent_count",
+			      "host.name" AS "aggr__hosts__key_0",
+			      sum(count(*)) OVER (PARTITION BY "aggr__hosts__key_0") AS
+			      "aggr__hosts__count",
+			      COALESCE("process.name", 'N/A') AS "aggr__hosts__rare_processes__key_0",
+			      count(*) AS "aggr__hosts__rare_processes__count"
+			    FROM __quesma_table_name
+			    GROUP BY "host.name" AS "aggr__hosts__key_0",
+			      COALESCE("process.name", 'N/A') AS "aggr__hosts__rare_processes__key_0"))
+			WHERE ("aggr__hosts__order_1_rank"<=3 AND "aggr__hosts__rare_processes__count"<=1)
+			ORDER BY "aggr__hosts__order_1_rank" ASC,
+			  "aggr__hosts__rare_processes__order_1_rank" ASC`,
+	},
+	{ // [82]
+		TestName: "diversified_sampler",
+		QueryRequestJson: `
+		{
+			"query": {
+				"match": {
+					"message": "elasticsearch"
+				}
+			},
+			"aggs": {
+				"my_unbiased_sample": {
+					"diversified_sampler": {
+						"shard_size": 200,
+						"field": "author",
+						"max_docs_per_value": 3
+					},
+					"aggs": {
+						"tags": {
+							"terms": {
+								"field": "tags",
+								"size": 2
+							}
+						}
+					}
+				}
+			},
+			"size": 0,
+			"track_total_hits": false
+		}`,
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"my_unbiased_sample": {
+					"doc_count": 151,
+					"tags": {
+						"doc_count_error_upper_bound": 0,
+						"sum_other_doc_count": 71,
+						"buckets": [
+							{
+								"key": "kibana",
+								"doc_count": 50
+							},
+							{
+								"key": "logstash",
+								"doc_count": 30
+							}
+						]
+					}
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__my_unbiased_sample__count", uint64(151)),
+				model.NewQueryResultCol("aggr__my_unbiased_sample__tags__parent_count", uint64(151)),
+				model.NewQueryResultCol("aggr__my_unbiased_sample__tags__key_0", "kibana"),
+				model.NewQueryResultCol("aggr__my_unbiased_sample__tags__count", uint64(50)),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__my_unbiased_sample__count", uint64(151)),
+				model.NewQueryResultCol("aggr__my_unbiased_sample__tags__parent_count", uint64(151)),
+				model.NewQueryResultCol("aggr__my_unbiased_sample__tags__key_0", "logstash"),
+				model.NewQueryResultCol("aggr__my_unbiased_sample__tags__count", uint64(30)),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT sum(count(*)) OVER () AS "aggr__my_unbiased_sample__count",
+			  sum(count(*)) OVER () AS "aggr__my_unbiased_sample__tags__parent_count",
+			  "tags" AS "aggr__my_unbiased_sample__tags__key_0",
+			  count(*) AS "aggr__my_unbiased_sample__tags__count"
+			FROM (
+			  SELECT "tags"
+			  FROM __quesma_table_name
+			  WHERE "message" __quesma_match '%elasticsearch%'
+			  LIMIT 3 BY "author"
+			  LIMIT 800)
+			GROUP BY "tags" AS "aggr__my_unbiased_sample__tags__key_0"
+			ORDER BY "aggr__my_unbiased_sample__tags__count" DESC,
+			  "aggr__my_unbiased_sample__tags__key_0" ASC
+			LIMIT 3`,
+	},
+	{ // [83]
+		TestName: "variable_width_histogram with a metric sub-aggregation",
+		QueryRequestJson: `
+		{
+			"query": {
+				"range": {
+					"price": {
+						"gte": 0
+					}
+				}
+			},
+			"aggs": {
+				"prices": {
+					"variable_width_histogram": {
+						"field": "price",
+						"buckets": 3
+					},
+					"aggs": {
+						"total": {
+							"sum": {
+								"field": "price"
+							}
+						}
+					}
+				}
+			},
+			"size": 0,
+			"track_total_hits": false
+		}`,
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"prices": {
+					"buckets": [
+						{
+							"min": 1.0,
+							"key": 3.0,
+							"max": 5.0,
+							"doc_count": 3,
+							"total": {
+								"value": 9.0
+							}
+						},
+						{
+							"min": 100.0,
+							"key": 150.0,
+							"max": 200.0,
+							"doc_count": 2,
+							"total": {
+								"value": 300.0
+							}
+						},
+						{
+							"min": 1000.0,
+							"key": 1000.0,
+							"max": 1000.0,
+							"doc_count": 1,
+							"total": {
+								"value": 1000.0
+							}
+						}
+					]
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__prices__key_0", uint64(1)),
+				model.NewQueryResultCol("aggr__prices__stat_0", 1.0),
+				model.NewQueryResultCol("aggr__prices__stat_1", 5.0),
+				model.NewQueryResultCol("aggr__prices__stat_2", 3.0),
+				model.NewQueryResultCol("aggr__prices__count", uint64(3)),
+				model.NewQueryResultCol("metric__prices__total_col_0", 9.0),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__prices__key_0", uint64(2)),
+				model.NewQueryResultCol("aggr__prices__stat_0", 100.0),
+				model.NewQueryResultCol("aggr__prices__stat_1", 200.0),
+				model.NewQueryResultCol("aggr__prices__stat_2", 150.0),
+				model.NewQueryResultCol("aggr__prices__count", uint64(2)),
+				model.NewQueryResultCol("metric__prices__total_col_0", 300.0),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__prices__key_0", uint64(3)),
+				model.NewQueryResultCol("aggr__prices__stat_0", 1000.0),
+				model.NewQueryResultCol("aggr__prices__stat_1", 1000.0),
+				model.NewQueryResultCol("aggr__prices__stat_2", 1000.0),
+				model.NewQueryResultCol("aggr__prices__count", uint64(1)),
+				model.NewQueryResultCol("metric__prices__total_col_0", 1000.0),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT if("price" IS NULL, NULL, arrayCount((lower_bound) -> lower_bound<="price",
+			  (SELECT arrayMap((bin) -> tupleElement(bin, 1), histogram(3)("price"))
+			  FROM __quesma_table_name
+			  WHERE "price">=0))) AS "aggr__prices__key_0",
+			  min("price") AS "aggr__prices__stat_0",
+			  max("price") AS "aggr__prices__stat_1",
+			  avg("price") AS "aggr__prices__stat_2",
+			  count(*) AS "aggr__prices__count",
+			  sumOrNull("price") AS "metric__prices__total_col_0"
+			FROM __quesma_table_name
+			WHERE "price">=0
+			GROUP BY if("price" IS NULL, NULL, arrayCount((lower_bound) -> lower_bound<="price",
+			  (SELECT arrayMap((bin) -> tupleElement(bin, 1), histogram(3)("price"))
+			  FROM __quesma_table_name
+			  WHERE "price">=0))) AS "aggr__prices__key_0"
+			ORDER BY "aggr__prices__key_0" ASC
+			LIMIT 4`,
+	},
+	{ // [84]
+		TestName: "weighted_avg with missing weight",
+		QueryRequestJson: `
+		{
+			"aggs": {
+				"weighted_grade": {
+					"weighted_avg": {
+						"value": {
+							"field": "grade"
+						},
+						"weight": {
+							"field": "weight",
+							"missing": 2
+						}
+					}
+				}
+			},
+			"size": 0,
+			"track_total_hits": false
+		}`,
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"weighted_grade": {
+					"value": 70.0
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("metric__weighted_grade_col_0", 70.0),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT avgWeightedOrNull("grade", COALESCE("weight", 2)) AS "metric__weighted_grade_col_0"
+			FROM __quesma_table_name`,
+	},
+	{ // [85]
+		TestName: "median_absolute_deviation",
+		QueryRequestJson: `
+		{
+			"aggs": {
+				"review_average": {
+					"avg": {
+						"field": "rating"
+					}
+				},
+				"review_variability": {
+					"median_absolute_deviation": {
+						"field": "rating"
+					}
+				}
+			},
+			"size": 0,
+			"track_total_hits": false
+		}`,
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"review_average": {
+					"value": 3.0
+				},
+				"review_variability": {
+					"value": 2.0
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("metric__review_average_col_0", 3.0),
+				model.NewQueryResultCol("metric__review_variability_col_0", uint64(5)),
+				model.NewQueryResultCol("metric__review_variability_col_1", 2.0),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT avgOrNull("rating") AS "metric__review_average_col_0",
+			  count("rating") AS "metric__review_variability_col_0",
+			  arrayReduce('quantileExact(0.5)', arrayMap((v, median) -> abs(v-median),
+			  groupArray("rating"), arrayWithConstant(count("rating"),
+			  quantileExact(0.5)("rating")))) AS "metric__review_variability_col_1"
+			FROM __quesma_table_name`,
+	},
+	{ // [86]
+		TestName: "string_stats with distribution",
+		QueryRequestJson: `
+		{
+			"aggs": {
+				"message_stats": {
+					"string_stats": {
+						"field": "message",
+						"show_distribution": true
+					}
+				}
+			},
+			"size": 0,
+			"track_total_hits": false
+		}`,
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"message_stats": {
+					"count": 2,
+					"min_length": 1,
+					"max_length": 2,
+					"avg_length": 1.5,
+					"entropy": 0.9182958340544896,
+					"distribution": {
+						"b": 0.6666666666666666,
+						"a": 0.3333333333333333
+					}
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("metric__message_stats_col_0", uint64(2)),
+				model.NewQueryResultCol("metric__message_stats_col_1", uint64(1)),
+				model.NewQueryResultCol("metric__message_stats_col_2", uint64(2)),
+				model.NewQueryResultCol("metric__message_stats_col_3", 1.5),
+				model.NewQueryResultCol("metric__message_stats_col_4", []any{[]string{"a", "b"}, []uint64{1, 2}}),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT count("message") AS "metric__message_stats_col_0",
+			  minOrNull(lengthUTF8("message")) AS "metric__message_stats_col_1",
+			  maxOrNull(lengthUTF8("message")) AS "metric__message_stats_col_2",
+			  avgOrNull(lengthUTF8("message")) AS "metric__message_stats_col_3",
+			  sumMap(ngrams(COALESCE("message", ''), 1), arrayWithConstant(length(ngrams(
+			  COALESCE("message", ''), 1)), 1)) AS "metric__message_stats_col_4"
+			FROM __quesma_table_name`,
+	},
+	{ // [87]
+		TestName: "boxplot",
+		QueryRequestJson: `
+		{
+			"aggs": {
+				"load_time_boxplot": {
+					"boxplot": {
+						"field": "load_time"
+					}
+				}
+			},
+			"size": 0,
+			"track_total_hits": false
+		}`,
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"load_time_boxplot": {
+					"min": 0.0,
+					"max": 990.0,
+					"q1": 167.5,
+					"q2": 445.0,
+					"q3": 722.5,
+					"lower": 0.0,
+					"upper": 990.0
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("metric__load_time_boxplot_col_0", 0.0),
+				model.NewQueryResultCol("metric__load_time_boxplot_col_1", 990.0),
+				model.NewQueryResultCol("metric__load_time_boxplot_col_2", 167.5),
+				model.NewQueryResultCol("metric__load_time_boxplot_col_3", 445.0),
+				model.NewQueryResultCol("metric__load_time_boxplot_col_4", 722.5),
+				model.NewQueryResultCol("metric__load_time_boxplot_col_5", 0.0),
+				model.NewQueryResultCol("metric__load_time_boxplot_col_6", 990.0),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT minOrNull("load_time") AS "metric__load_time_boxplot_col_0",
+			  maxOrNull("load_time") AS "metric__load_time_boxplot_col_1",
+			  quantileExact(0.25)("load_time") AS "metric__load_time_boxplot_col_2",
+			  quantileExact(0.5)("load_time") AS "metric__load_time_boxplot_col_3",
+			  quantileExact(0.75)("load_time") AS "metric__load_time_boxplot_col_4",
+			  arrayMin(arrayFilter((v, fence) -> v>=fence, groupArray("load_time"),
+			  arrayWithConstant(count("load_time"), quantileExact(0.25)("load_time")-1.5*(
+			  quantileExact(0.75)("load_time")-quantileExact(0.25)("load_time"))))) AS
+			  "metric__load_time_boxplot_col_5",
+			  arrayMax(arrayFilter((v, fence) -> v<=fence, groupArray("load_time"),
+			  arrayWithConstant(count("load_time"), quantileExact(0.75)("load_time")+1.5*(
+			  quantileExact(0.75)("load_time")-quantileExact(0.25)("load_time"))))) AS
+			  "metric__load_time_boxplot_col_6"
+			FROM __quesma_table_name`,
+	},
+	{ // [88]
+		TestName: "t_test with filters",
+		QueryRequestJson: `
+		{
+			"aggs": {
+				"startup_time_ttest": {
+					"t_test": {
+						"a": {
+							"field": "startup_time_before",
+							"filter": {
+								"term": {
+									"group": "A"
+								}
+							}
+						},
+						"b": {
+							"field": "startup_time_before",
+							"filter": {
+								"term": {
+									"group": "B"
+								}
+							}
+						},
+						"type": "heteroscedastic"
+					}
+				}
+			},
+			"size": 0,
+			"track_total_hits": false
+		}`,
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"startup_time_ttest": {
+					"value": 0.11499016052991407
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("metric__startup_time_ttest_col_0", uint64(5)),
+				model.NewQueryResultCol("metric__startup_time_ttest_col_1", 3.0),
+				model.NewQueryResultCol("metric__startup_time_ttest_col_2", 2.5),
+				model.NewQueryResultCol("metric__startup_time_ttest_col_3", uint64(5)),
+				model.NewQueryResultCol("metric__startup_time_ttest_col_4", 6.2),
+				model.NewQueryResultCol("metric__startup_time_ttest_col_5", 12.2),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT countIf(("startup_time_before" IS NOT NULL AND "group"='A')) AS
+			  "metric__startup_time_ttest_col_0",
+			  avgOrNullIf("startup_time_before", "group"='A') AS
+			  "metric__startup_time_ttest_col_1",
+			  varSampIf("startup_time_before", "group"='A') AS
+			  "metric__startup_time_ttest_col_2",
+			  countIf(("startup_time_before" IS NOT NULL AND "group"='B')) AS
+			  "metric__startup_time_ttest_col_3",
+			  avgOrNullIf("startup_time_before", "group"='B') AS
+			  "metric__startup_time_ttest_col_4",
+			  varSampIf("startup_time_before", "group"='B') AS
+			  "metric__startup_time_ttest_col_5"
+			FROM __quesma_table_name`,
+	},
+	{ // [89]
+		TestName: "matrix_stats with missing",
+		QueryRequestJson: `
+		{
+			"aggs": {
+				"statistics": {
+					"matrix_stats": {
+						"fields": ["poverty", "income"],
+						"missing": {
+							"income": 50000
+						}
+					}
+				}
+			},
+			"size": 0,
+			"track_total_hits": false
+		}`,
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"statistics": {
+					"doc_count": 50,
+					"fields": [
+						{
+							"name": "poverty",
+							"count": 50,
+							"mean": 12.732,
+							"variance": 8.137,
+							"skewness": 0.971,
+							"kurtosis": 3.644,
+							"covariance": {
+								"poverty": 8.137,
+								"income": -15067.0
+							},
+							"correlation": {
+								"poverty": 1.0,
+								"income": -0.845
+							}
+						},
+						{
+							"name": "income",
+							"count": 50,
+							"mean": 51985.1,
+							"variance": 3.9e7,
+							"skewness": 0.589,
+							"kurtosis": 2.794,
+							"covariance": {
+								"poverty": -15067.0,
+								"income": 3.9e7
+							},
+							"correlation": {
+								"poverty": -0.845,
+								"income": 1.0
+							}
+						}
+					]
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("metric__statistics_col_0", uint64(50)),
+				model.NewQueryResultCol("metric__statistics_col_1", 12.732),
+				model.NewQueryResultCol("metric__statistics_col_2", 8.137),
+				model.NewQueryResultCol("metric__statistics_col_3", 0.971),
+				model.NewQueryResultCol("metric__statistics_col_4", 3.644),
+				model.NewQueryResultCol("metric__statistics_col_5", 51985.1),
+				model.NewQueryResultCol("metric__statistics_col_6", 3.9e7),
+				model.NewQueryResultCol("metric__statistics_col_7", 0.589),
+				model.NewQueryResultCol("metric__statistics_col_8", 2.794),
+				model.NewQueryResultCol("metric__statistics_col_9", [][]float64{{8.137, -15067.0}, {-15067.0, 3.9e7}}),
+				model.NewQueryResultCol("metric__statistics_col_10", [][]float64{{1.0, -0.845}, {-0.845, 1.0}}),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT countIf(("poverty" IS NOT NULL AND COALESCE("income", 50000) IS NOT NULL))
+			  AS "metric__statistics_col_0",
+			  avgIf("poverty", ("poverty" IS NOT NULL AND COALESCE("income", 50000) IS NOT
+			  NULL)) AS "metric__statistics_col_1",
+			  varSampIf("poverty", ("poverty" IS NOT NULL AND COALESCE("income", 50000) IS
+			  NOT NULL)) AS "metric__statistics_col_2",
+			  skewPopIf("poverty", ("poverty" IS NOT NULL AND COALESCE("income", 50000) IS
+			  NOT NULL)) AS "metric__statistics_col_3",
+			  kurtPopIf("poverty", ("poverty" IS NOT NULL AND COALESCE("income", 50000) IS
+			  NOT NULL)) AS "metric__statistics_col_4",
+			  avgIf(COALESCE("income", 50000), ("poverty" IS NOT NULL AND COALESCE("income",
+			  50000) IS NOT NULL)) AS "metric__statistics_col_5",
+			  varSampIf(COALESCE("income", 50000), ("poverty" IS NOT NULL AND COALESCE(
+			  "income", 50000) IS NOT NULL)) AS "metric__statistics_col_6",
+			  skewPopIf(COALESCE("income", 50000), ("poverty" IS NOT NULL AND COALESCE(
+			  "income", 50000) IS NOT NULL)) AS "metric__statistics_col_7",
+			  kurtPopIf(COALESCE("income", 50000), ("poverty" IS NOT NULL AND COALESCE(
+			  "income", 50000) IS NOT NULL)) AS "metric__statistics_col_8",
+			  covarSampMatrix("poverty", COALESCE("income", 50000)) AS
+			  "metric__statistics_col_9",
+			  corrMatrix("poverty", COALESCE("income", 50000)) AS "metric__statistics_col_10"
+			FROM __quesma_table_name`,
+	},
+	{ // [90]
+		TestName: "nested with terms and sub-metric, and a top-level metric",
+		QueryRequestJson: `
+		{
+			"aggs": {
+				"events": {
+					"nested": {
+						"path": "events"
+					},
+					"aggs": {
+						"types": {
+							"terms": {
+								"field": "events.type",
+								"size": 2
+							},
+							"aggs": {
+								"avg_duration": {
+									"avg": {
+										"field": "events.duration"
+									}
+								}
+							}
+						}
+					}
+				},
+				"max_bytes": {
+					"max": {
+						"field": "bytes_gauge"
+					}
+				}
+			},
+			"size": 0,
+			"track_total_hits": false
+		}`,
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"events": {
+					"doc_count": 7,
+					"types": {
+						"doc_count_error_upper_bound": 0,
+						"sum_other_doc_count": 1,
+						"buckets": [
+							{
+								"key": "click",
+								"doc_count": 4,
+								"avg_duration": {
+									"value": 12.5
+								}
+							},
+							{
+								"key": "view",
+								"doc_count": 2,
+								"avg_duration": {
+									"value": 3.0
+								}
+							}
+						]
+					}
+				},
+				"max_bytes": {
+					"value": 1024.0
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__events__count", uint64(7)),
+				model.NewQueryResultCol("aggr__events__types__parent_count", uint64(7)),
+				model.NewQueryResultCol("aggr__events__types__key_0", "click"),
+				model.NewQueryResultCol("aggr__events__types__count", uint64(4)),
+				model.NewQueryResultCol("metric__events__types__avg_duration_col_0", 12.5),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__events__count", uint64(7)),
+				model.NewQueryResultCol("aggr__events__types__parent_count", uint64(7)),
+				model.NewQueryResultCol("aggr__events__types__key_0", "view"),
+				model.NewQueryResultCol("aggr__events__types__count", uint64(2)),
+				model.NewQueryResultCol("metric__events__types__avg_duration_col_0", 3.0),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT sum(count(*)) OVER () AS "aggr__events__count",
+			  sum(count(*)) OVER () AS "aggr__events__types__parent_count",
+			  "events_type" AS "aggr__events__types__key_0",
+			  count(*) AS "aggr__events__types__count",
+			  avgOrNull("events_duration") AS "metric__events__types__avg_duration_col_0"
+			FROM (
+			  SELECT "events_duration", "events_type",
+			    rowNumberInAllBlocks() AS "__quesma_parent_row"
+			  FROM __quesma_table_name) ARRAY JOIN "events_duration", "events_type"
+			GROUP BY "events_type" AS "aggr__events__types__key_0"
+			ORDER BY "aggr__events__types__count" DESC, "aggr__events__types__key_0" ASC
+			LIMIT 3`,
+		ExpectedAdditionalPancakeResults: [][]model.QueryResultRow{
+			{{Cols: []model.QueryResultCol{model.NewQueryResultCol("metric__max_bytes_col_0", 1024.0)}}},
+		},
+		ExpectedAdditionalPancakeSQLs: []string{`
+			SELECT maxOrNull("bytes_gauge") AS "metric__max_bytes_col_0"
+			FROM __quesma_table_name`},
+	},
+	{ // [91]
+		TestName: "reverse_nested inside nested terms",
+		QueryRequestJson: `
+		{
+			"aggs": {
+				"events": {
+					"nested": {
+						"path": "events"
+					},
+					"aggs": {
+						"types": {
+							"terms": {
+								"field": "events.type"
+							},
+							"aggs": {
+								"documents": {
+									"reverse_nested": {}
+								}
+							}
+						}
+					}
+				}
+			},
+			"query": {
+				"nested": {
+					"path": "events",
+					"query": {
+						"range": {
+							"events.duration": {
+								"gte": 5
+							}
+						}
+					}
+				}
+			},
+			"size": 0,
+			"track_total_hits": false
+		}`,
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"events": {
+					"doc_count": 5,
+					"types": {
+						"doc_count_error_upper_bound": 0,
+						"sum_other_doc_count": 0,
+						"buckets": [
+							{
+								"key": "click",
+								"doc_count": 3,
+								"documents": {
+									"doc_count": 2
+								}
+							},
+							{
+								"key": "view",
+								"doc_count": 2,
+								"documents": {
+									"doc_count": 2
+								}
+							}
+						]
+					}
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__events__count", uint64(5)),
+				model.NewQueryResultCol("aggr__events__types__parent_count", uint64(5)),
+				model.NewQueryResultCol("aggr__events__types__key_0", "click"),
+				model.NewQueryResultCol("aggr__events__types__count", uint64(3)),
+				model.NewQueryResultCol("aggr__events__types__documents__count", uint64(2)),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__events__count", uint64(5)),
+				model.NewQueryResultCol("aggr__events__types__parent_count", uint64(5)),
+				model.NewQueryResultCol("aggr__events__types__key_0", "view"),
+				model.NewQueryResultCol("aggr__events__types__count", uint64(2)),
+				model.NewQueryResultCol("aggr__events__types__documents__count", uint64(2)),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT sum(count(*)) OVER () AS "aggr__events__count",
+			  sum(count(*)) OVER () AS "aggr__events__types__parent_count",
+			  "events_type" AS "aggr__events__types__key_0",
+			  count(*) AS "aggr__events__types__count",
+			  uniqExact("__quesma_parent_row") AS "aggr__events__types__documents__count"
+			FROM (
+			  SELECT "events_duration", "events_type",
+			    rowNumberInAllBlocks() AS "__quesma_parent_row"
+			  FROM __quesma_table_name
+			  WHERE __quesma_nested("events_duration">=5)) ARRAY JOIN "events_duration",
+			  "events_type"
+			GROUP BY "events_type" AS "aggr__events__types__key_0"
+			ORDER BY "aggr__events__types__count" DESC, "aggr__events__types__key_0" ASC
+			LIMIT 11`,
+	},
 }
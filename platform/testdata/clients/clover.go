@@ -1215,7 +1215,7 @@ var CloverTests = []testdata.AggregationTestCase{
 			  count(*) AS "aggr__q__time__count",
 			  uniq("a.b") AS "metric__q__time__cardinality(a.b.keyword)_col_0"
 			FROM __quesma_table_name
-			WHERE (("a.b" __quesma_match '%c%') OR "a.b" __quesma_match '%d%')
+			WHERE ("a.b" __quesma_match '%c%' OR "a.b" __quesma_match '%d%')
 			GROUP BY toInt64((toUnixTimestamp64Milli("@timestamp")+timeZoneOffset(toTimezone
 			  ("@timestamp", 'Europe/Warsaw'))*1000) / 43200000) AS "aggr__q__time__key_0"
 			ORDER BY "aggr__q__time__key_0" ASC`,
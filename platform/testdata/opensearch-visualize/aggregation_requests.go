@@ -705,8 +705,8 @@ var AggregationTests = []testdata.AggregationTestCase{
 			],
 			"track_total_hits": true
 		}`,
-		ExpectedResponse: // erased "2": "bg_count": 14074, "doc_count": 2786 from the real response. It should be there in 'significant_terms' (not in 'terms'), but it seems to work without it.
-		`{
+		ExpectedResponse: `
+		{
 			"_shards": {
 				"failed": 0,
 				"skipped": 0,
@@ -721,24 +721,24 @@ var AggregationTests = []testdata.AggregationTestCase{
 								"value": 1714687096297.0,
 								"value_as_string": "2024-05-02T21:58:16.297"
 							},
-							"bg_count": 2570,
+							"bg_count": 12832,
 							"doc_count": 2570,
 							"key": "200",
-							"score": 2570
+							"score": 0.010843301523130379
 						},
 						{
 							"1": {
 								"value": 1714665552949.0,
 								"value_as_string": "2024-05-02T15:59:12.949"
 							},
-							"bg_count": 94,
+							"bg_count": 441,
 							"doc_count": 94,
 							"key": "503",
-							"score": 94
+							"score": 0.0025904599032482625
 						}
 					],
-					"doc_count": 5000,
-					"bg_count": 5000
+					"doc_count": 2786,
+					"bg_count": 14074
 				}
 			},
 			"hits": {
@@ -754,20 +754,31 @@ var AggregationTests = []testdata.AggregationTestCase{
 		}`,
 		ExpectedPancakeResults: []model.QueryResultRow{
 			{Cols: []model.QueryResultCol{
-				model.NewQueryResultCol("aggr__2__parent_count", 5000),
+				model.NewQueryResultCol("aggr__2__parent_bg_count", uint64(14074)),
+				model.NewQueryResultCol("aggr__2__bg_count", uint64(12832)),
+				model.NewQueryResultCol("aggr__2__parent_count", 2786),
 				model.NewQueryResultCol("aggr__2__key_0", "200"),
 				model.NewQueryResultCol("aggr__2__count", int64(2570)),
 				model.NewQueryResultCol("metric__2__1_col_0", util.ParseTime("2024-05-02T21:58:16.297Z")),
 			}},
 			{Cols: []model.QueryResultCol{
-				model.NewQueryResultCol("aggr__2__parent_count", 5000),
+				model.NewQueryResultCol("aggr__2__parent_bg_count", uint64(14074)),
+				model.NewQueryResultCol("aggr__2__bg_count", uint64(441)),
+				model.NewQueryResultCol("aggr__2__parent_count", 2786),
 				model.NewQueryResultCol("aggr__2__key_0", "503"),
 				model.NewQueryResultCol("aggr__2__count", int64(94)),
 				model.NewQueryResultCol("metric__2__1_col_0", util.ParseTime("2024-05-02T15:59:12.949Z")),
 			}},
 		},
 		ExpectedPancakeSQL: `
-			SELECT sum(count(*)) OVER () AS "aggr__2__parent_count",
+			SELECT (SELECT count(*) FROM __quesma_table_name) AS "aggr__2__parent_bg_count",
+			  arrayElement((SELECT mapFromArrays(groupArray("term"), groupArray("count"))
+			  FROM (SELECT "response" AS "term", count(*) AS "count" FROM __quesma_table_name
+			  WHERE "response" IN (SELECT "response" FROM __quesma_table_name
+			    WHERE ("timestamp">=__quesma_from_unixtime64mili(1713401399517) AND "timestamp"<=__quesma_from_unixtime64mili(1714697399517))
+			    GROUP BY "response" ORDER BY count(*) DESC, "response" ASC LIMIT 29)
+			  GROUP BY "response")), "response") AS "aggr__2__bg_count",
+			  sum(count(*)) OVER () AS "aggr__2__parent_count",
 			  "response" AS "aggr__2__key_0", count(*) AS "aggr__2__count",
 			  maxOrNull("timestamp") AS "metric__2__1_col_0"
 			FROM __quesma_table_name
@@ -775,7 +786,7 @@ var AggregationTests = []testdata.AggregationTestCase{
 			  __quesma_from_unixtime64mili(1714697399517))
 			GROUP BY "response" AS "aggr__2__key_0"
 			ORDER BY "aggr__2__count" DESC, "aggr__2__key_0" ASC
-			LIMIT 4`,
+			LIMIT 29`,
 	},
 	{ // [5]
 		TestName: "Min on DateTime field. Reproduce: Visualize -> Line: Metrics -> Min @timestamp, Buckets: Add X-Asis, Aggregation: Significant Terms",
@@ -859,30 +870,30 @@ var AggregationTests = []testdata.AggregationTestCase{
 			},
 			"aggregations": {
 				"2": {
-					"bg_count": 5300,
+					"bg_count": 14074,
 					"buckets": [
 						{
 							"1": {
 								"value": 1713659942912.0,
 								"value_as_string": "2024-04-21T00:39:02.912"
 							},
-							"bg_count": 2570,
+							"bg_count": 12832,
 							"doc_count": 2570,
 							"key": "200",
-							"score": 2570
+							"score": 0.010843301523130379
 						},
 						{
 							"1": {
 								"value": 1713670225131.0,
 								"value_as_string": "2024-04-21T03:30:25.131"
 							},
-							"bg_count": 94,
+							"bg_count": 441,
 							"doc_count": 94,
 							"key": "503",
-							"score": 94
+							"score": 0.0025904599032482625
 						}
 					],
-					"doc_count": 5300
+					"doc_count": 2786
 				}
 			},
 			"hits": {
@@ -898,20 +909,31 @@ var AggregationTests = []testdata.AggregationTestCase{
 		}`,
 		ExpectedPancakeResults: []model.QueryResultRow{
 			{Cols: []model.QueryResultCol{
-				model.NewQueryResultCol("aggr__2__parent_count", 5300),
+				model.NewQueryResultCol("aggr__2__parent_bg_count", uint64(14074)),
+				model.NewQueryResultCol("aggr__2__bg_count", uint64(12832)),
+				model.NewQueryResultCol("aggr__2__parent_count", 2786),
 				model.NewQueryResultCol("aggr__2__key_0", "200"),
 				model.NewQueryResultCol("aggr__2__count", uint64(2570)),
 				model.NewQueryResultCol("metric__2__1_col_0", util.ParseTime("2024-04-21T00:39:02.912Z")),
 			}},
 			{Cols: []model.QueryResultCol{
-				model.NewQueryResultCol("aggr__2__parent_count", 5300),
+				model.NewQueryResultCol("aggr__2__parent_bg_count", uint64(14074)),
+				model.NewQueryResultCol("aggr__2__bg_count", uint64(441)),
+				model.NewQueryResultCol("aggr__2__parent_count", 2786),
 				model.NewQueryResultCol("aggr__2__key_0", "503"),
 				model.NewQueryResultCol("aggr__2__count", uint64(94)),
 				model.NewQueryResultCol("metric__2__1_col_0", util.ParseTime("2024-04-21T03:30:25.131Z")),
 			}},
 		},
 		ExpectedPancakeSQL: `
-			SELECT sum(count(*)) OVER () AS "aggr__2__parent_count",
+			SELECT (SELECT count(*) FROM __quesma_table_name) AS "aggr__2__parent_bg_count",
+			  arrayElement((SELECT mapFromArrays(groupArray("term"), groupArray("count"))
+			  FROM (SELECT "response" AS "term", count(*) AS "count" FROM __quesma_table_name
+			  WHERE "response" IN (SELECT "response" FROM __quesma_table_name
+			    WHERE ("timestamp">=__quesma_from_unixtime64mili(1713401460471) AND "timestamp"<=__quesma_from_unixtime64mili(1714697460471))
+			    GROUP BY "response" ORDER BY count(*) DESC, "response" ASC LIMIT 29)
+			  GROUP BY "response")), "response") AS "aggr__2__bg_count",
+			  sum(count(*)) OVER () AS "aggr__2__parent_count",
 			  "response" AS "aggr__2__key_0", count(*) AS "aggr__2__count",
 			  minOrNull("timestamp") AS "metric__2__1_col_0"
 			FROM __quesma_table_name
@@ -919,7 +941,7 @@ var AggregationTests = []testdata.AggregationTestCase{
 			  __quesma_from_unixtime64mili(1714697460471))
 			GROUP BY "response" AS "aggr__2__key_0"
 			ORDER BY "aggr__2__count" DESC, "aggr__2__key_0" ASC
-			LIMIT 4`,
+			LIMIT 29`,
 	},
 	{ // [6]
 		TestName: "Percentiles on DateTime field. Reproduce: Visualize -> Line: Metrics -> Percentiles (or Median, it's the same aggregation) @timestamp, Buckets: Add X-Asis, Aggregation: Significant Terms",
@@ -1005,7 +1027,7 @@ var AggregationTests = []testdata.AggregationTestCase{
 			},
 			"aggregations": {
 				"2": {
-					"bg_count": 2786,
+					"bg_count": 14074,
 					"doc_count": 2786,
 					"buckets": [
 						{
@@ -1048,10 +1070,10 @@ var AggregationTests = []testdata.AggregationTestCase{
 									}
 								]
 							},
-							"bg_count": 2570,
+							"bg_count": 12832,
 							"doc_count": 2570,
 							"key": "200",
-							"score": 2570
+							"score": 0.010843301523130379
 						}
 					]
 				}
@@ -1069,6 +1091,8 @@ var AggregationTests = []testdata.AggregationTestCase{
 		}`,
 		ExpectedPancakeResults: []model.QueryResultRow{
 			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__2__parent_bg_count", uint64(14074)),
+				model.NewQueryResultCol("aggr__2__bg_count", uint64(12832)),
 				model.NewQueryResultCol("aggr__2__parent_count", int64(2786)),
 				model.NewQueryResultCol("aggr__2__key_0", "200"),
 				model.NewQueryResultCol("aggr__2__count", int64(2570)),
@@ -1082,7 +1106,14 @@ var AggregationTests = []testdata.AggregationTestCase{
 			}},
 		},
 		ExpectedPancakeSQL: `
-			SELECT sum(count(*)) OVER () AS "aggr__2__parent_count",
+			SELECT (SELECT count(*) FROM __quesma_table_name) AS "aggr__2__parent_bg_count",
+			  arrayElement((SELECT mapFromArrays(groupArray("term"), groupArray("count"))
+			  FROM (SELECT "response" AS "term", count(*) AS "count" FROM __quesma_table_name
+			  WHERE "response" IN (SELECT "response" FROM __quesma_table_name
+			    WHERE ("timestamp">=__quesma_from_unixtime64mili(1713401475845) AND "timestamp"<=__quesma_from_unixtime64mili(1714697475845))
+			    GROUP BY "response" ORDER BY count(*) DESC, "response" ASC LIMIT 29)
+			  GROUP BY "response")), "response") AS "aggr__2__bg_count",
+			  sum(count(*)) OVER () AS "aggr__2__parent_count",
 			  "response" AS "aggr__2__key_0", count(*) AS "aggr__2__count",
 			  quantiles(0.010000)("timestamp") AS "metric__2__1_col_0",
 			  quantiles(0.020000)("timestamp") AS "metric__2__1_col_1",
@@ -1096,7 +1127,7 @@ var AggregationTests = []testdata.AggregationTestCase{
 			  __quesma_from_unixtime64mili(1714697475845))
 			GROUP BY "response" AS "aggr__2__key_0"
 			ORDER BY "aggr__2__count" DESC, "aggr__2__key_0" ASC
-			LIMIT 4`,
+			LIMIT 29`,
 	},
 	{ // [7]
 		TestName: "Percentile_ranks keyed=false. Reproduce: Visualize -> Line -> Metrics: Percentile Ranks, Buckets: X-Asis Date Histogram",
@@ -4373,7 +4373,17 @@ var PipelineAggregationTests = []testdata.AggregationTestCase{
 			],
 			"query": {
 				"bool": {
-					"filter": [],
+					"filter": [
+						{
+							"range": {
+								"timestamp": {
+									"format": "strict_date_optional_time",
+									"gte": "2024-04-27T22:16:26.906Z",
+									"lte": "2024-05-12T22:16:26.906Z"
+								}
+							}
+						}
+					],
 					"must": {
 						"match_all": {}
 					},
@@ -4395,7 +4405,6 @@ var PipelineAggregationTests = []testdata.AggregationTestCase{
 			],
 			"track_total_hits": true
 		}`,
-		// TODO: change 'sum_other_doc_count' to 'doc_count' in response. Significant_terms return that, unlike terms.
 		ExpectedResponse: `
 		{
 			"_shards": {
@@ -4414,32 +4423,32 @@ var PipelineAggregationTests = []testdata.AggregationTestCase{
 							"1-metric": {
 								"value": 12539770587.428572
 							},
-							"bg_count": 224,
+							"bg_count": 1012,
 							"doc_count": 224,
 							"key": "deb",
-							"score": 224
+							"score": 0.08051330302071014
 						},
 						{
 							"1-metric": {
-								"value": 12464949530.168888
+								"value": 12786004614.736841
 							},
-							"bg_count": 225,
-							"doc_count": 225,
-							"key": "zip",
-							"score": 225
+							"bg_count": 301,
+							"doc_count": 76,
+							"key": "rpm",
+							"score": 0.03689559360873267
 						},
 						{
 							"1-metric": {
-								"value": 12786004614.736841
+								"value": 12464949530.168888
 							},
-							"bg_count": 76,
-							"doc_count": 76,
-							"key": "rpm",
-							"score": 76
+							"bg_count": 1480,
+							"doc_count": 225,
+							"key": "zip",
+							"score": 0.017765206430042653
 						}
 					],
 					"doc_count": 1865,
-					"bg_count": 1865
+					"bg_count": 14074
 				}
 			},
 			"hits": {
@@ -4455,6 +4464,8 @@ var PipelineAggregationTests = []testdata.AggregationTestCase{
 		}`,
 		ExpectedPancakeResults: []model.QueryResultRow{
 			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__1-bucket__parent_bg_count", uint64(14074)),
+				model.NewQueryResultCol("aggr__1-bucket__bg_count", uint64(1012)),
 				model.NewQueryResultCol("aggr__1-bucket__parent_count", uint64(1865)),
 				model.NewQueryResultCol("aggr__1-bucket__key_0", "deb"),
 				model.NewQueryResultCol("aggr__1-bucket__count", uint64(224)),
@@ -4462,6 +4473,8 @@ var PipelineAggregationTests = []testdata.AggregationTestCase{
 				model.NewQueryResultCol("metric__1-bucket__1-metric_col_0", 12539770587.428572),
 			}},
 			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__1-bucket__parent_bg_count", uint64(14074)),
+				model.NewQueryResultCol("aggr__1-bucket__bg_count", uint64(1480)),
 				model.NewQueryResultCol("aggr__1-bucket__parent_count", uint64(1865)),
 				model.NewQueryResultCol("aggr__1-bucket__key_0", "zip"),
 				model.NewQueryResultCol("aggr__1-bucket__count", uint64(225)),
@@ -4469,6 +4482,8 @@ var PipelineAggregationTests = []testdata.AggregationTestCase{
 				model.NewQueryResultCol("metric__1-bucket__1-metric_col_0", 12464949530.168888),
 			}},
 			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__1-bucket__parent_bg_count", uint64(14074)),
+				model.NewQueryResultCol("aggr__1-bucket__bg_count", uint64(301)),
 				model.NewQueryResultCol("aggr__1-bucket__parent_count", uint64(1865)),
 				model.NewQueryResultCol("aggr__1-bucket__key_0", "rpm"),
 				model.NewQueryResultCol("aggr__1-bucket__count", uint64(76)),
@@ -4477,13 +4492,22 @@ var PipelineAggregationTests = []testdata.AggregationTestCase{
 			}},
 		},
 		ExpectedPancakeSQL: `
-			SELECT sum(count(*)) OVER () AS "aggr__1-bucket__parent_count",
+			SELECT (SELECT count(*) FROM __quesma_table_name) AS "aggr__1-bucket__parent_bg_count",
+			  arrayElement((SELECT mapFromArrays(groupArray("term"), groupArray("count"))
+			  FROM (SELECT "extension" AS "term", count(*) AS "count" FROM __quesma_table_name
+			  WHERE "extension" IN (SELECT "extension" FROM __quesma_table_name
+			    WHERE ("timestamp">=__quesma_from_unixtime64mili(1714256186906) AND "timestamp"<=__quesma_from_unixtime64mili(1715552186906))
+			    GROUP BY "extension" ORDER BY count(*) DESC, "extension" ASC LIMIT 35)
+			  GROUP BY "extension")), "extension") AS "aggr__1-bucket__bg_count",
+			  sum(count(*)) OVER () AS "aggr__1-bucket__parent_count",
 			  "extension" AS "aggr__1-bucket__key_0", count(*) AS "aggr__1-bucket__count",
 			  avgOrNull("machine.ram") AS "metric__1-bucket__1-metric_col_0"
 			FROM __quesma_table_name
+			WHERE ("timestamp">=__quesma_from_unixtime64mili(1714256186906) AND "timestamp"<=
+			  __quesma_from_unixtime64mili(1715552186906))
 			GROUP BY "extension" AS "aggr__1-bucket__key_0"
 			ORDER BY "aggr__1-bucket__count" DESC, "aggr__1-bucket__key_0" ASC
-			LIMIT 6`,
+			LIMIT 35`,
 	},
 	{ // [25]
 		TestName: "complex sum_bucket. Reproduce: Visualize -> Vertical Bar: Metrics: Sum Bucket (Bucket: Date Histogram, Metric: Average), Buckets: X-Asis: Histogram",
@@ -0,0 +1,824 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package testdata
+
+import "github.com/QuesmaOrg/quesma/platform/model"
+
+var PipelineAggregationTests2 = []AggregationTestCase{
+	{ // [0]
+		TestName: "bucket_selector. Example from Elasticsearch docs",
+		QueryRequestJson: `
+		{
+			"size": 0,
+			"aggs": {
+				"sales_per_month": {
+					"date_histogram": {
+						"field": "@timestamp",
+						"calendar_interval": "month"
+					},
+					"aggs": {
+						"total_sales": {
+							"sum": {
+								"field": "price"
+							}
+						},
+						"sales_bucket_filter": {
+							"bucket_selector": {
+								"buckets_path": {
+									"totalSales": "total_sales",
+									"count": "_count"
+								},
+								"script": "params.totalSales > 200 && params.count <= 3"
+							}
+						}
+					}
+				}
+			}
+		}`,
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"sales_per_month": {
+					"buckets": [
+						{
+							"key_as_string": "2015-01-01T00:00:00.000",
+							"key": 1420070400000,
+							"doc_count": 3,
+							"total_sales": {
+								"value": 550.0
+							}
+						},
+						{
+							"key_as_string": "2015-03-01T00:00:00.000",
+							"key": 1425168000000,
+							"doc_count": 2,
+							"total_sales": {
+								"value": 375.0
+							}
+						}
+					]
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__sales_per_month__key_0", int64(1420070400000)),
+				model.NewQueryResultCol("aggr__sales_per_month__count", int64(3)),
+				model.NewQueryResultCol("metric__sales_per_month__total_sales_col_0", 550.0),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__sales_per_month__key_0", int64(1422748800000)),
+				model.NewQueryResultCol("aggr__sales_per_month__count", int64(2)),
+				model.NewQueryResultCol("metric__sales_per_month__total_sales_col_0", 60.0),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__sales_per_month__key_0", int64(1425168000000)),
+				model.NewQueryResultCol("aggr__sales_per_month__count", int64(2)),
+				model.NewQueryResultCol("metric__sales_per_month__total_sales_col_0", 375.0),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT toInt64(toUnixTimestamp(toStartOfMonth(toTimezone("@timestamp", 'UTC'))))*1000 AS "aggr__sales_per_month__key_0",
+			  count(*) AS "aggr__sales_per_month__count",
+			  sumOrNull("price") AS "metric__sales_per_month__total_sales_col_0"
+			FROM __quesma_table_name
+			GROUP BY toInt64(toUnixTimestamp(toStartOfMonth(toTimezone("@timestamp", 'UTC'))))*1000 AS "aggr__sales_per_month__key_0"
+			ORDER BY "aggr__sales_per_month__key_0" ASC`,
+	},
+	{ // [1]
+		TestName: "bucket_sort: sort terms by metric and truncate",
+		QueryRequestJson: `
+		{
+			"size": 0,
+			"aggs": {
+				"by_host": {
+					"terms": {
+						"field": "host.name",
+						"size": 10
+					},
+					"aggs": {
+						"total_bytes": {
+							"sum": {
+								"field": "bytes"
+							}
+						},
+						"bytes_bucket_sort": {
+							"bucket_sort": {
+								"sort": [
+									{ "total_bytes": { "order": "desc" } },
+									"_key"
+								],
+								"from": 1,
+								"size": 2
+							}
+						}
+					}
+				}
+			}
+		}`,
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"by_host": {
+					"doc_count_error_upper_bound": 0,
+					"sum_other_doc_count": 0,
+					"buckets": [
+						{
+							"key": "b",
+							"doc_count": 5,
+							"total_bytes": {
+								"value": 100.0
+							}
+						},
+						{
+							"key": "d",
+							"doc_count": 1,
+							"total_bytes": {
+								"value": 100.0
+							}
+						}
+					]
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__by_host__parent_count", int64(16)),
+				model.NewQueryResultCol("aggr__by_host__key_0", "a"),
+				model.NewQueryResultCol("aggr__by_host__count", int64(7)),
+				model.NewQueryResultCol("metric__by_host__total_bytes_col_0", 50.0),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__by_host__parent_count", int64(16)),
+				model.NewQueryResultCol("aggr__by_host__key_0", "d"),
+				model.NewQueryResultCol("aggr__by_host__count", int64(1)),
+				model.NewQueryResultCol("metric__by_host__total_bytes_col_0", 100.0),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__by_host__parent_count", int64(16)),
+				model.NewQueryResultCol("aggr__by_host__key_0", "b"),
+				model.NewQueryResultCol("aggr__by_host__count", int64(5)),
+				model.NewQueryResultCol("metric__by_host__total_bytes_col_0", 100.0),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__by_host__parent_count", int64(16)),
+				model.NewQueryResultCol("aggr__by_host__key_0", "c"),
+				model.NewQueryResultCol("aggr__by_host__count", int64(3)),
+				model.NewQueryResultCol("metric__by_host__total_bytes_col_0", 300.0),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT sum(count(*)) OVER () AS "aggr__by_host__parent_count",
+			  "host.name" AS "aggr__by_host__key_0", count(*) AS "aggr__by_host__count",
+			  sumOrNull("bytes") AS "metric__by_host__total_bytes_col_0"
+			FROM __quesma_table_name
+			GROUP BY "host.name" AS "aggr__by_host__key_0"
+			ORDER BY "aggr__by_host__count" DESC, "aggr__by_host__key_0" ASC
+			LIMIT 11`,
+	},
+	{ // [2]
+		TestName: "stats_bucket and extended_stats_bucket",
+		QueryRequestJson: `
+		{
+			"size": 0,
+			"aggs": {
+				"sales_per_month": {
+					"date_histogram": {
+						"field": "@timestamp",
+						"calendar_interval": "month"
+					},
+					"aggs": {
+						"sales": {
+							"sum": {
+								"field": "price"
+							}
+						}
+					}
+				},
+				"stats_monthly_sales": {
+					"stats_bucket": {
+						"buckets_path": "sales_per_month>sales"
+					}
+				},
+				"extended_stats_monthly_sales": {
+					"extended_stats_bucket": {
+						"buckets_path": "sales_per_month>sales",
+						"sigma": 1
+					}
+				}
+			}
+		}`,
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"sales_per_month": {
+					"buckets": [
+						{
+							"key_as_string": "2015-01-01T00:00:00.000",
+							"key": 1420070400000,
+							"doc_count": 3,
+							"sales": {
+								"value": 550.0
+							}
+						},
+						{
+							"key_as_string": "2015-02-01T00:00:00.000",
+							"key": 1422748800000,
+							"doc_count": 2,
+							"sales": {
+								"value": 60.0
+							}
+						},
+						{
+							"key_as_string": "2015-03-01T00:00:00.000",
+							"key": 1425168000000,
+							"doc_count": 2,
+							"sales": {
+								"value": 375.0
+							}
+						}
+					]
+				},
+				"stats_monthly_sales": {
+					"count": 3,
+					"min": 60.0,
+					"max": 550.0,
+					"avg": 328.3333333333333,
+					"sum": 985.0
+				},
+				"extended_stats_monthly_sales": {
+					"count": 3,
+					"min": 60.0,
+					"max": 550.0,
+					"avg": 328.3333333333333,
+					"sum": 985.0,
+					"sum_of_squares": 446725.0,
+					"variance": 41105.55555555558,
+					"variance_population": 41105.55555555558,
+					"variance_sampling": 61658.33333333334,
+					"std_deviation": 202.74505063146566,
+					"std_deviation_population": 202.74505063146566,
+					"std_deviation_sampling": 248.3109609609156,
+					"std_deviation_bounds": {
+						"upper": 531.078383964799,
+						"lower": 125.58828270186766,
+						"upper_population": 531.078383964799,
+						"lower_population": 125.58828270186766,
+						"upper_sampling": 576.6442942942489,
+						"lower_sampling": 80.02237237241772
+					}
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__sales_per_month__key_0", int64(1420070400000)),
+				model.NewQueryResultCol("aggr__sales_per_month__count", int64(3)),
+				model.NewQueryResultCol("metric__sales_per_month__sales_col_0", 550.0),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__sales_per_month__key_0", int64(1422748800000)),
+				model.NewQueryResultCol("aggr__sales_per_month__count", int64(2)),
+				model.NewQueryResultCol("metric__sales_per_month__sales_col_0", 60.0),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__sales_per_month__key_0", int64(1425168000000)),
+				model.NewQueryResultCol("aggr__sales_per_month__count", int64(2)),
+				model.NewQueryResultCol("metric__sales_per_month__sales_col_0", 375.0),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT toInt64(toUnixTimestamp(toStartOfMonth(toTimezone("@timestamp", 'UTC'))))*1000 AS "aggr__sales_per_month__key_0",
+			  count(*) AS "aggr__sales_per_month__count",
+			  sumOrNull("price") AS "metric__sales_per_month__sales_col_0"
+			FROM __quesma_table_name
+			GROUP BY toInt64(toUnixTimestamp(toStartOfMonth(toTimezone("@timestamp", 'UTC'))))*1000 AS "aggr__sales_per_month__key_0"
+			ORDER BY "aggr__sales_per_month__key_0" ASC`,
+	},
+	{ // [3]
+		TestName: "percentiles_bucket (keyed, default percents) on count and stats_bucket, nested in terms",
+		QueryRequestJson: `
+		{
+			"size": 0,
+			"aggs": {
+				"by_host": {
+					"terms": {
+						"field": "host.name",
+						"size": 2
+					},
+					"aggs": {
+						"per_day": {
+							"date_histogram": {
+								"field": "@timestamp",
+								"fixed_interval": "1d"
+							}
+						},
+						"percentiles_daily": {
+							"percentiles_bucket": {
+								"buckets_path": "per_day>_count"
+							}
+						},
+						"stats_daily": {
+							"stats_bucket": {
+								"buckets_path": "per_day>_count"
+							}
+						}
+					}
+				}
+			}
+		}`,
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"by_host": {
+					"doc_count_error_upper_bound": 0,
+					"sum_other_doc_count": 0,
+					"buckets": [
+						{
+							"key": "a",
+							"doc_count": 13,
+							"per_day": {
+								"buckets": [
+									{
+										"key_as_string": "2024-01-01T00:00:00.000",
+										"key": 1704067200000,
+										"doc_count": 10
+									},
+									{
+										"key_as_string": "2024-01-02T00:00:00.000",
+										"key": 1704153600000,
+										"doc_count": 1
+									},
+									{
+										"key_as_string": "2024-01-03T00:00:00.000",
+										"key": 1704240000000,
+										"doc_count": 2
+									}
+								]
+							},
+							"percentiles_daily": {
+								"values": {
+									"1.0": 1,
+									"5.0": 1,
+									"25.0": 2,
+									"50.0": 2,
+									"75.0": 10,
+									"95.0": 10,
+									"99.0": 10
+								}
+							},
+							"stats_daily": {
+								"count": 3,
+								"min": 1.0,
+								"max": 10.0,
+								"avg": 4.333333333333333,
+								"sum": 13.0
+							}
+						},
+						{
+							"key": "b",
+							"doc_count": 4,
+							"per_day": {
+								"buckets": [
+									{
+										"key_as_string": "2024-01-02T00:00:00.000",
+										"key": 1704153600000,
+										"doc_count": 4
+									}
+								]
+							},
+							"percentiles_daily": {
+								"values": {
+									"1.0": 4,
+									"5.0": 4,
+									"25.0": 4,
+									"50.0": 4,
+									"75.0": 4,
+									"95.0": 4,
+									"99.0": 4
+								}
+							},
+							"stats_daily": {
+								"count": 1,
+								"min": 4.0,
+								"max": 4.0,
+								"avg": 4.0,
+								"sum": 4.0
+							}
+						}
+					]
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__by_host__parent_count", int64(17)),
+				model.NewQueryResultCol("aggr__by_host__key_0", "a"),
+				model.NewQueryResultCol("aggr__by_host__count", int64(13)),
+				model.NewQueryResultCol("aggr__by_host__per_day__key_0", int64(1704067200000/86400000)),
+				model.NewQueryResultCol("aggr__by_host__per_day__count", int64(10)),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__by_host__parent_count", int64(17)),
+				model.NewQueryResultCol("aggr__by_host__key_0", "a"),
+				model.NewQueryResultCol("aggr__by_host__count", int64(13)),
+				model.NewQueryResultCol("aggr__by_host__per_day__key_0", int64(1704153600000/86400000)),
+				model.NewQueryResultCol("aggr__by_host__per_day__count", int64(1)),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__by_host__parent_count", int64(17)),
+				model.NewQueryResultCol("aggr__by_host__key_0", "a"),
+				model.NewQueryResultCol("aggr__by_host__count", int64(13)),
+				model.NewQueryResultCol("aggr__by_host__per_day__key_0", int64(1704240000000/86400000)),
+				model.NewQueryResultCol("aggr__by_host__per_day__count", int64(2)),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__by_host__parent_count", int64(17)),
+				model.NewQueryResultCol("aggr__by_host__key_0", "b"),
+				model.NewQueryResultCol("aggr__by_host__count", int64(4)),
+				model.NewQueryResultCol("aggr__by_host__per_day__key_0", int64(1704153600000/86400000)),
+				model.NewQueryResultCol("aggr__by_host__per_day__count", int64(4)),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT "aggr__by_host__parent_count", "aggr__by_host__key_0",
+			  "aggr__by_host__count", "aggr__by_host__per_day__key_0",
+			  "aggr__by_host__per_day__count"
+			FROM (
+			  SELECT "aggr__by_host__parent_count", "aggr__by_host__key_0",
+			    "aggr__by_host__count", "aggr__by_host__per_day__key_0",
+			    "aggr__by_host__per_day__count",
+			    dense_rank() OVER (ORDER BY "aggr__by_host__count" DESC,
+			    "aggr__by_host__key_0" ASC) AS "aggr__by_host__order_1_rank",
+			    dense_rank() OVER (PARTITION BY "aggr__by_host__key_0" ORDER BY
+			    "aggr__by_host__per_day__key_0" ASC) AS "aggr__by_host__per_day__order_1_rank"
+			  FROM (
+			    SELECT sum(count(*)) OVER () AS "aggr__by_host__parent_count",
+			      "host.name" AS "aggr__by_host__key_0",
+			      sum(count(*)) OVER (PARTITION BY "aggr__by_host__key_0") AS
+			      "aggr__by_host__count",
+			      toInt64(toUnixTimestamp64Milli("@timestamp") / 86400000) AS
+			      "aggr__by_host__per_day__key_0",
+			      count(*) AS "aggr__by_host__per_day__count"
+			    FROM __quesma_table_name
+			    GROUP BY "host.name" AS "aggr__by_host__key_0",
+			      toInt64(toUnixTimestamp64Milli("@timestamp") / 86400000) AS
+			      "aggr__by_host__per_day__key_0"))
+			WHERE "aggr__by_host__order_1_rank"<=3
+			ORDER BY "aggr__by_host__order_1_rank" ASC,
+			  "aggr__by_host__per_day__order_1_rank" ASC`,
+	},
+	{ // [4]
+		TestName: "percentiles_bucket (not keyed, custom percents) on metric",
+		QueryRequestJson: `
+		{
+			"size": 0,
+			"aggs": {
+				"sales_per_month": {
+					"date_histogram": {
+						"field": "@timestamp",
+						"calendar_interval": "month"
+					},
+					"aggs": {
+						"sales": {
+							"sum": {
+								"field": "price"
+							}
+						}
+					}
+				},
+				"percentiles_monthly_sales": {
+					"percentiles_bucket": {
+						"buckets_path": "sales_per_month>sales",
+						"percents": [25.0, 50.0, 99.9],
+						"keyed": false
+					}
+				}
+			}
+		}`,
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"sales_per_month": {
+					"buckets": [
+						{
+							"key_as_string": "2015-01-01T00:00:00.000",
+							"key": 1420070400000,
+							"doc_count": 3,
+							"sales": {
+								"value": 550.0
+							}
+						},
+						{
+							"key_as_string": "2015-02-01T00:00:00.000",
+							"key": 1422748800000,
+							"doc_count": 2,
+							"sales": {
+								"value": 60.0
+							}
+						},
+						{
+							"key_as_string": "2015-03-01T00:00:00.000",
+							"key": 1425168000000,
+							"doc_count": 2,
+							"sales": {
+								"value": 375.0
+							}
+						}
+					]
+				},
+				"percentiles_monthly_sales": {
+					"values": [
+						{
+							"key": 25.0,
+							"value": 375.0
+						},
+						{
+							"key": 50.0,
+							"value": 375.0
+						},
+						{
+							"key": 99.9,
+							"value": 550.0
+						}
+					]
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__sales_per_month__key_0", int64(1420070400000)),
+				model.NewQueryResultCol("aggr__sales_per_month__count", int64(3)),
+				model.NewQueryResultCol("metric__sales_per_month__sales_col_0", 550.0),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__sales_per_month__key_0", int64(1422748800000)),
+				model.NewQueryResultCol("aggr__sales_per_month__count", int64(2)),
+				model.NewQueryResultCol("metric__sales_per_month__sales_col_0", 60.0),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__sales_per_month__key_0", int64(1425168000000)),
+				model.NewQueryResultCol("aggr__sales_per_month__count", int64(2)),
+				model.NewQueryResultCol("metric__sales_per_month__sales_col_0", 375.0),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT toInt64(toUnixTimestamp(toStartOfMonth(toTimezone("@timestamp", 'UTC'))))*1000 AS "aggr__sales_per_month__key_0",
+			  count(*) AS "aggr__sales_per_month__count",
+			  sumOrNull("price") AS "metric__sales_per_month__sales_col_0"
+			FROM __quesma_table_name
+			GROUP BY toInt64(toUnixTimestamp(toStartOfMonth(toTimezone("@timestamp", 'UTC'))))*1000 AS "aggr__sales_per_month__key_0"
+			ORDER BY "aggr__sales_per_month__key_0" ASC`,
+	},
+	{ // [5]
+		TestName: "moving_fn: unweightedAvg and max (with shift) on date_histogram",
+		QueryRequestJson: `
+		{
+			"size": 0,
+			"aggs": {
+				"my_date_histo": {
+					"date_histogram": {
+						"field": "@timestamp",
+						"calendar_interval": "month"
+					},
+					"aggs": {
+						"the_sum": {
+							"sum": {
+								"field": "price"
+							}
+						},
+						"the_movavg": {
+							"moving_fn": {
+								"buckets_path": "the_sum",
+								"window": 2,
+								"script": "MovingFunctions.unweightedAvg(values)"
+							}
+						},
+						"the_movmax": {
+							"moving_fn": {
+								"buckets_path": "the_sum",
+								"window": 3,
+								"shift": 1,
+								"script": {
+									"source": "MovingFunctions.max(values)",
+									"lang": "painless"
+								}
+							}
+						}
+					}
+				}
+			}
+		}`,
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"my_date_histo": {
+					"buckets": [
+						{
+							"key_as_string": "2015-01-01T00:00:00.000",
+							"key": 1420070400000,
+							"doc_count": 3,
+							"the_sum": {
+								"value": 550.0
+							},
+							"the_movavg": {
+								"value": null
+							},
+							"the_movmax": {
+								"value": 550.0
+							}
+						},
+						{
+							"key_as_string": "2015-02-01T00:00:00.000",
+							"key": 1422748800000,
+							"doc_count": 2,
+							"the_sum": {
+								"value": 60.0
+							},
+							"the_movavg": {
+								"value": 550.0
+							},
+							"the_movmax": {
+								"value": 550.0
+							}
+						},
+						{
+							"key_as_string": "2015-03-01T00:00:00.000",
+							"key": 1425168000000,
+							"doc_count": 2,
+							"the_sum": {
+								"value": 375.0
+							},
+							"the_movavg": {
+								"value": 305.0
+							},
+							"the_movmax": {
+								"value": 550.0
+							}
+						},
+						{
+							"key_as_string": "2015-04-01T00:00:00.000",
+							"key": 1427846400000,
+							"doc_count": 1,
+							"the_sum": {
+								"value": 100.0
+							},
+							"the_movavg": {
+								"value": 217.5
+							},
+							"the_movmax": {
+								"value": 375.0
+							}
+						}
+					]
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__my_date_histo__key_0", int64(1420070400000)),
+				model.NewQueryResultCol("aggr__my_date_histo__count", int64(3)),
+				model.NewQueryResultCol("metric__my_date_histo__the_sum_col_0", 550.0),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__my_date_histo__key_0", int64(1422748800000)),
+				model.NewQueryResultCol("aggr__my_date_histo__count", int64(2)),
+				model.NewQueryResultCol("metric__my_date_histo__the_sum_col_0", 60.0),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__my_date_histo__key_0", int64(1425168000000)),
+				model.NewQueryResultCol("aggr__my_date_histo__count", int64(2)),
+				model.NewQueryResultCol("metric__my_date_histo__the_sum_col_0", 375.0),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__my_date_histo__key_0", int64(1427846400000)),
+				model.NewQueryResultCol("aggr__my_date_histo__count", int64(1)),
+				model.NewQueryResultCol("metric__my_date_histo__the_sum_col_0", 100.0),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT toInt64(toUnixTimestamp(toStartOfMonth(toTimezone("@timestamp", 'UTC'))))*1000 AS "aggr__my_date_histo__key_0",
+			  count(*) AS "aggr__my_date_histo__count",
+			  sumOrNull("price") AS "metric__my_date_histo__the_sum_col_0"
+			FROM __quesma_table_name
+			GROUP BY toInt64(toUnixTimestamp(toStartOfMonth(toTimezone("@timestamp", 'UTC'))))*1000 AS "aggr__my_date_histo__key_0"
+			ORDER BY "aggr__my_date_histo__key_0" ASC`,
+	},
+	{ // [6]
+		TestName: "moving_percentiles on date_histogram",
+		QueryRequestJson: `
+		{
+			"size": 0,
+			"aggs": {
+				"my_date_histo": {
+					"date_histogram": {
+						"field": "@timestamp",
+						"calendar_interval": "month"
+					},
+					"aggs": {
+						"the_percentile": {
+							"percentiles": {
+								"field": "price",
+								"percents": [10, 90]
+							}
+						},
+						"the_movperc": {
+							"moving_percentiles": {
+								"buckets_path": "the_percentile",
+								"window": 2
+							}
+						}
+					}
+				}
+			}
+		}`,
+		ExpectedResponse: `
+		{
+			"aggregations": {
+				"my_date_histo": {
+					"buckets": [
+						{
+							"key_as_string": "2015-01-01T00:00:00.000",
+							"key": 1420070400000,
+							"doc_count": 3,
+							"the_percentile": {
+								"values": {
+									"10.0": 100.0,
+									"90.0": 300.0
+								}
+							},
+							"the_movperc": {
+								"values": {
+									"10.0": 100.0,
+									"90.0": 300.0
+								}
+							}
+						},
+						{
+							"key_as_string": "2015-02-01T00:00:00.000",
+							"key": 1422748800000,
+							"doc_count": 2,
+							"the_percentile": {
+								"values": {
+									"10.0": 50.0,
+									"90.0": 150.0
+								}
+							},
+							"the_movperc": {
+								"values": {
+									"10.0": 75.0,
+									"90.0": 225.0
+								}
+							}
+						},
+						{
+							"key_as_string": "2015-03-01T00:00:00.000",
+							"key": 1425168000000,
+							"doc_count": 2,
+							"the_percentile": {
+								"values": {
+									"10.0": 200.0,
+									"90.0": 400.0
+								}
+							},
+							"the_movperc": {
+								"values": {
+									"10.0": 125.0,
+									"90.0": 275.0
+								}
+							}
+						}
+					]
+				}
+			}
+		}`,
+		ExpectedPancakeResults: []model.QueryResultRow{
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__my_date_histo__key_0", int64(1420070400000)),
+				model.NewQueryResultCol("aggr__my_date_histo__count", int64(3)),
+				model.NewQueryResultCol("metric__my_date_histo__the_percentile_col_0", []float64{100.0}),
+				model.NewQueryResultCol("metric__my_date_histo__the_percentile_col_1", []float64{300.0}),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__my_date_histo__key_0", int64(1422748800000)),
+				model.NewQueryResultCol("aggr__my_date_histo__count", int64(2)),
+				model.NewQueryResultCol("metric__my_date_histo__the_percentile_col_0", []float64{50.0}),
+				model.NewQueryResultCol("metric__my_date_histo__the_percentile_col_1", []float64{150.0}),
+			}},
+			{Cols: []model.QueryResultCol{
+				model.NewQueryResultCol("aggr__my_date_histo__key_0", int64(1425168000000)),
+				model.NewQueryResultCol("aggr__my_date_histo__count", int64(2)),
+				model.NewQueryResultCol("metric__my_date_histo__the_percentile_col_0", []float64{200.0}),
+				model.NewQueryResultCol("metric__my_date_histo__the_percentile_col_1", []float64{400.0}),
+			}},
+		},
+		ExpectedPancakeSQL: `
+			SELECT toInt64(toUnixTimestamp(toStartOfMonth(toTimezone("@timestamp", 'UTC'))))*1000 AS "aggr__my_date_histo__key_0",
+			  count(*) AS "aggr__my_date_histo__count",
+			  quantiles(0.100000)("price") AS "metric__my_date_histo__the_percentile_col_0",
+			  quantiles(0.900000)("price") AS "metric__my_date_histo__the_percentile_col_1"
+			FROM __quesma_table_name
+			GROUP BY toInt64(toUnixTimestamp(toStartOfMonth(toTimezone("@timestamp", 'UTC'))))*1000 AS "aggr__my_date_histo__key_0"
+			ORDER BY "aggr__my_date_histo__key_0" ASC`,
+	},
+}
@@ -1465,9 +1465,9 @@ var TestsSearch = []SearchTestCase{
 			},
 			"track_total_hits": false
 		}`,
-		[]string{`"references.type"='tag'`},
+		[]string{`__quesma_nested("references.type"='tag')`},
 		model.ListAllFields,
-		[]string{`SELECT "message" FROM ` + TableName + ` WHERE "references.type"='tag'`},
+		[]string{`SELECT "message" FROM ` + TableName + ` WHERE __quesma_nested("references.type"='tag')`},
 		[]string{},
 	},
 	{ // [20]
@@ -69,34 +69,6 @@ var UnsupportedQueriesTests = []UnsupportedQueryTestCase{
 			}
 		}`,
 	},
-	{ // [3]
-		TestName:  "bucket aggregation: diversified_sampler",
-		QueryType: "diversified_sampler",
-		QueryRequestJson: `
-		{
-			"query": {
-				"query_string": {
-					"query": "tags:elasticsearch"
-				}
-			},
-			"aggs": {
-				"my_unbiased_sample": {
-					"diversified_sampler": {
-						"shard_size": 200,
-						"field": "author"
-					},
-					"aggs": {
-						"keywords": {
-							"significant_terms": {
-								"field": "tags",
-								"exclude": [ "elasticsearch" ]
-							}
-						}
-					}
-				}
-			}
-		}`,
-	},
 	{ // [4]
 		TestName:  "bucket aggregation: frequent_item_sets",
 		QueryType: "frequent_item_sets",
@@ -193,32 +165,6 @@ var UnsupportedQueriesTests = []UnsupportedQueryTestCase{
 			}
 		}`,
 	},
-	{ // [10]
-		TestName:  "bucket aggregation: nested",
-		QueryType: "nested",
-		QueryRequestJson: `
-		{
-			"query": {
-				"match": {
-					"name": "led tv"
-				}
-			},
-			"aggs": {
-				"resellers": {
-					"nested": {
-						"path": "resellers"
-					},
-					"aggs": {
-						"min_price": {
-							"min": {
-								"field": "resellers.price"
-							}
-						}
-					}
-				}
-			}
-		}`,
-	},
 	{ // [11]
 		TestName:  "bucket aggregation: parent",
 		QueryType: "parent",
@@ -249,20 +195,6 @@ var UnsupportedQueriesTests = []UnsupportedQueryTestCase{
 			}
 		}`,
 	},
-	{ // [12]
-		TestName:  "bucket aggregation: rare_terms",
-		QueryType: "rare_terms",
-		QueryRequestJson: `
-		{
-			"aggs": {
-				"genres": {
-					"rare_terms": {
-						"field": "genre"
-					}
-				}
-			}
-		}`,
-	},
 	{ // [13]
 		TestName:  "bucket aggregation: reverse_nested",
 		QueryType: "reverse_nested",
@@ -296,28 +228,6 @@ var UnsupportedQueriesTests = []UnsupportedQueryTestCase{
 			}
 		}`,
 	},
-	{ // [14]
-		TestName:  "bucket aggregation: significant_text",
-		QueryType: "significant_text",
-		QueryRequestJson: `
-		{
-			"query": {
-				"match": { "content": "Bird flu" }
-			},
-			"aggs": {
-				"my_sample": {
-					"sampler": {
-						"shard_size": 100
-					},
-					"aggs": {
-						"keywords": {
-							"significant_text": { "field": "content" }
-						}
-					}
-				}
-			}
-		}`,
-	},
 	{ // [15]
 		TestName:  "bucket aggregation: time_series",
 		QueryType: "time_series",
@@ -330,37 +240,7 @@ var UnsupportedQueriesTests = []UnsupportedQueryTestCase{
 			}
 		}`,
 	},
-	{ // [16]
-		TestName:  "bucket aggregation: variable_width_histogram",
-		QueryType: "variable_width_histogram",
-		QueryRequestJson: `
-		{
-			"aggs": {
-				"prices": {
-					"variable_width_histogram": {
-						"field": "price",
-						"buckets": 2
-					}
-				}
-			}
-		}`,
-	},
 	// metrics:
-	{ // [17]
-		TestName:  "metrics aggregation: boxplot",
-		QueryType: "boxplot",
-		QueryRequestJson: `
-		{
-			"size": 0,
-			"aggs": {
-				"load_time_boxplot": {
-					"boxplot": {
-						"field": "load_time" 
-					}
-				}
-			}
-		}`,
-	},
 	{ // [19]
 		TestName:  "metrics aggregation: geo_line",
 		QueryType: "geo_line",
@@ -407,40 +287,6 @@ var UnsupportedQueriesTests = []UnsupportedQueryTestCase{
 			}
 		}`,
 	},
-	{ // [22]
-		TestName:  "metrics aggregation: matrix_stats",
-		QueryType: "matrix_stats",
-		QueryRequestJson: `
-		{
-			"aggs": {
-				"statistics": {
-					"matrix_stats": {
-						"fields": [ "poverty", "income" ]
-					}
-				}
-			}
-		}`,
-	},
-	{ // [23]
-		TestName:  "metrics aggregation: median_absolute_deviation",
-		QueryType: "median_absolute_deviation",
-		QueryRequestJson: `
-		{
-			"size": 0,
-			"aggs": {
-				"review_average": {
-					"avg": {
-						"field": "rating"
-					}
-				},
-				"review_variability": {
-					"median_absolute_deviation": {
-						"field": "rating" 
-					}
-				}
-			}
-		}`,
-	},
 	{ // [25]
 		TestName:  "metrics aggregation: scripted_metric",
 		QueryType: "scripted_metric",
@@ -461,53 +307,6 @@ var UnsupportedQueriesTests = []UnsupportedQueryTestCase{
 			}
 		}`,
 	},
-	{ // [26]
-		TestName:  "metrics aggregation: string_stats",
-		QueryType: "string_stats",
-		QueryRequestJson: `
-		{
-			"aggs": {
-				"message_stats": { "string_stats": { "field": "message.keyword" } }
-			}
-		}`,
-	},
-	{ // [35]
-		TestName:  "metrics aggregation: t_test",
-		QueryType: "t_test",
-		QueryRequestJson: `
-		{
-			"size": 0,
-			"aggs": {
-				"startup_time_ttest": {
-					"t_test": {
-						"a": { "field": "startup_time_before" },  
-						"b": { "field": "startup_time_after" },   
-						"type": "paired"                          
-					}
-				}
-			}
-		}`,
-	},
-	{ // [27]
-		TestName:  "metrics aggregation: weighted_avg",
-		QueryType: "weighted_avg",
-		QueryRequestJson: `
-		{
-			"size": 0,
-			"aggs": {
-				"weighted_grade": {
-					"weighted_avg": {
-						"value": {
-							"field": "grade"
-						},
-						"weight": {
-							"field": "weight"
-						}
-					}
-				}
-			}
-		}`,
-	},
 
 	// pipeline:
 	{ // [38]
@@ -583,68 +382,6 @@ var UnsupportedQueriesTests = []UnsupportedQueryTestCase{
 			}
 		}`,
 	},
-	{ // [40]
-		TestName:  "pipeline aggregation: bucket_selector",
-		QueryType: "bucket_selector",
-		QueryRequestJson: `
-		{
-			"size": 0,
-			"aggs": {
-				"sales_per_month": {
-					"date_histogram": {
-						"field": "date",
-						"calendar_interval": "month"
-					},
-					"aggs": {
-						"total_sales": {
-							"sum": {
-								"field": "price"
-							}
-						},
-						"sales_bucket_filter": {
-							"bucket_selector": {
-								"buckets_path": {
-									"totalSales": "total_sales"
-								},
-								"script": "params.totalSales > 200"
-							}
-						}
-					}
-				}
-			}
-		}`,
-	},
-	{ // [41]
-		TestName:  "pipeline aggregation: bucket_sort",
-		QueryType: "bucket_sort",
-		QueryRequestJson: `
-		{
-			"size": 0,
-			"aggs": {
-				"sales_per_month": {
-					"date_histogram": {
-						"field": "date",
-						"calendar_interval": "month"
-					},
-					"aggs": {
-						"total_sales": {
-							"sum": {
-								"field": "price"
-							}
-						},
-						"sales_bucket_sort": {
-							"bucket_sort": {
-								"sort": [
-				  					{ "total_sales": { "order": "desc" } } 
-								],
-								"size": 3                                
-							}
-						}
-					}
-				}
-			}
-		}`,
-	},
 	{ // [42]
 		TestName:  "pipeline aggregation: change_point",
 		QueryType: "change_point",
@@ -700,34 +437,6 @@ var UnsupportedQueriesTests = []UnsupportedQueryTestCase{
 			}
 		}`,
 	},
-	{ // [46]
-		TestName:  "pipeline aggregation: extended_stats_bucket",
-		QueryType: "extended_stats_bucket",
-		QueryRequestJson: `
-		{
-			"size": 0,
-			"aggs": {
-				"sales_per_month": {
-					"date_histogram": {
-						"field": "date",
-						"calendar_interval": "month"
-					},
-					"aggs": {
-						"sales": {
-							"sum": {
-								"field": "price"
-							}
-						}
-					}
-				},
-				"stats_monthly_sales": {
-					"extended_stats_bucket": {
-						"buckets_path": "sales_per_month>sales" 
-					}
-				}
-			}
-		}`,
-	},
 	{ // [47]
 		TestName:  "pipeline aggregation: inference",
 		QueryType: "inference",
@@ -803,64 +512,6 @@ var UnsupportedQueriesTests = []UnsupportedQueryTestCase{
 			}
 		}`,
 	},
-	{ // [50]
-		TestName:  "pipeline aggregation: moving_fn",
-		QueryType: "moving_fn",
-		QueryRequestJson: `
-		{
-			"size": 0,
-			"aggs": {
-				"my_date_histo": {                  
-					"date_histogram": {
-						"field": "date",
-						"calendar_interval": "1M"
-					},
-					"aggs": {
-						"the_sum": {
-							"sum": { "field": "price" }   
-						},
-						"the_movfn": {
-							"moving_fn": {
-								"buckets_path": "the_sum",  
-								"window": 10,
-								"script": "MovingFunctions.unweightedAvg(values)"
-							}
-						}
-					}
-				}
-			}
-		}`,
-	},
-	{ // [51]
-		TestName:  "pipeline aggregation: moving_percentiles",
-		QueryType: "moving_percentiles",
-		QueryRequestJson: `
-		{
-			"size": 0,
-			"aggs": {
-				"my_date_histo": {                          
-					"date_histogram": {
-						"field": "date",
-						"calendar_interval": "1M"
-					},
-					"aggs": {
-						"the_percentile": {                     
-							"percentiles": {
-								"field": "price",
-								"percents": [ 1.0, 99.0 ]
-							}
-						},
-						"the_movperc": {
-							"moving_percentiles": {
-								"buckets_path": "the_percentile",   
-								"window": 10
-							}
-						}
-					}
-				}
-			}
-		}`,
-	},
 	{ // [52]
 		TestName:  "pipeline aggregation: normalize",
 		QueryType: "normalize",
@@ -891,63 +542,6 @@ var UnsupportedQueriesTests = []UnsupportedQueryTestCase{
 			}
 		}`,
 	},
-	{ // [53]
-		TestName:  "pipeline aggregation: percentiles_bucket",
-		QueryType: "percentiles_bucket",
-		QueryRequestJson: `
-		{
-			"size": 0,
-			"aggs": {
-				"sales_per_month": {
-					"date_histogram": {
-						"field": "date",
-						"calendar_interval": "month"
-					},
-					"aggs": {
-						"sales": {
-							"sum": {
-								"field": "price"
-							}
-						}
-					}
-				},
-				"percentiles_monthly_sales": {
-					"percentiles_bucket": {
-						"buckets_path": "sales_per_month>sales", 
-						"percents": [ 25.0, 50.0, 75.0 ]         
-					}
-				}
-			}
-		}`,
-	},
-	{ // [55]
-		TestName:  "pipeline aggregation: stats_bucket",
-		QueryType: "stats_bucket",
-		QueryRequestJson: `
-		{
-			"size": 0,
-			"aggs": {
-				"sales_per_month": {
-					"date_histogram": {
-						"field": "date",
-						"calendar_interval": "month"
-					},
-					"aggs": {
-						"sales": {
-							"sum": {
-								"field": "price"
-							}
-						}
-					}
-				},
-				"stats_monthly_sales": {
-					"stats_bucket": {
-						"buckets_path": "sales_per_month>sales" 
-					}
-				}
-			}
-		}`,
-	},
 	// random non-existing aggregation:
 	{ // [57]
 		TestName:  "non-existing aggregation: Augustus_Caesar",
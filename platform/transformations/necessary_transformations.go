@@ -27,9 +27,13 @@ func ApplyFieldMapSyntax(indexSchema schema.Schema, query *model.Query, isFieldM
 	visitor.OverrideVisitColumnRef = func(b *model.BaseExprVisitor, e model.ColumnRef) interface{} {
 
 		// we don't want to resolve our well know technical fields
-		if e.ColumnName == model.FullTextFieldNamePlaceHolder || e.ColumnName == common_table.IndexNameColumn {
+		if e.ColumnName == model.FullTextFieldNamePlaceHolder || e.ColumnName == common_table.IndexNameColumn || e.ColumnName == common_table.DocumentIdColumn {
 			return e
 		}
+		// subfields of flattened fields are always keys of their maps
+		if flattenedField, key, ok := indexSchema.ResolveFlattenedSubfield(e.ColumnName); ok {
+			return model.NewFunction("arrayElement", model.NewColumnRef(flattenedField.InternalPropertyName.AsString()), model.NewLiteral(fmt.Sprintf("'%s'", key)))
+		}
 		// 1. we check if the field name point to the map
 		if isFieldMapSyntaxEnabled {
 			elements := strings.Split(e.ColumnName, ".")
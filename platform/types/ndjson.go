@@ -41,7 +41,9 @@ func ParseNDJSON(body string) (NDJSON, error) {
 
 type DocumentTarget struct {
 	Index *string `json:"_index"`
-	Id    *string `json:"_id"` // document's target id in Elasticsearch, we ignore it when writing to Clickhouse.
+	Id    *string `json:"_id"` // document's target id in Elasticsearch, when writing to Clickhouse it's only used by update and delete.
+	// Pipeline is the ingest pipeline to run on the document, overrides the `pipeline` request parameter
+	Pipeline *string `json:"pipeline"`
 }
 
 type BulkOperation map[string]DocumentTarget
@@ -56,6 +58,24 @@ func (op BulkOperation) GetIndex() string {
 	return ""
 }
 
+func (op BulkOperation) GetId() string {
+	for _, target := range op { // this map contains only 1 element though
+		if target.Id != nil {
+			return *target.Id
+		}
+	}
+
+	return ""
+}
+
+// GetPipeline returns the pipeline from operation's metadata, or nil if it's not specified.
+func (op BulkOperation) GetPipeline() *string {
+	for _, target := range op { // this map contains only 1 element though
+		return target.Pipeline
+	}
+	return nil
+}
+
 func (op BulkOperation) GetOperation() string {
 	for operation := range op {
 		return operation
@@ -88,6 +108,9 @@ func (n NDJSON) BulkForEach(f func(entryNumber int, operationParsed BulkOperatio
 				if id, ok := detailsMap["_id"].(string); ok {
 					docTarget.Id = &id
 				}
+				if pipeline, ok := detailsMap["pipeline"].(string); ok {
+					docTarget.Pipeline = &pipeline
+				}
 
 				actionAndMetadataParsed[opType] = docTarget
 			} else {
@@ -207,6 +207,21 @@ func (qmc *QuesmaManagementConsole) createRouting() *mux.Router {
 		_, _ = writer.Write(buf)
 	})
 
+	authenticatedRoutes.HandleFunc(deadLetterPath, func(writer http.ResponseWriter, req *http.Request) {
+		buf := qmc.generateDeadLetter("")
+		_, _ = writer.Write(buf)
+	})
+
+	authenticatedRoutes.HandleFunc(deadLetterPath+"/{id}/replay", func(writer http.ResponseWriter, req *http.Request) {
+		buf := qmc.replayDeadLetter(mux.Vars(req)["id"])
+		_, _ = writer.Write(buf)
+	}).Methods("POST")
+
+	authenticatedRoutes.HandleFunc(deadLetterPath+"/{id}/discard", func(writer http.ResponseWriter, req *http.Request) {
+		buf := qmc.discardDeadLetter(mux.Vars(req)["id"])
+		_, _ = writer.Write(buf)
+	}).Methods("POST")
+
 	authenticatedRoutes.HandleFunc("/statistics-json", func(writer http.ResponseWriter, req *http.Request) {
 		jsonBody, err := json.Marshal(stats.GlobalStatistics)
 		if err != nil {
@@ -0,0 +1,107 @@
+// Copyright Quesma, licensed under the Elastic License 2.0.
+// SPDX-License-Identifier: Elastic-2.0
+package ui
+
+import (
+	"context"
+	"fmt"
+	"github.com/QuesmaOrg/quesma/platform/ingest/dead_letter"
+	"github.com/goccy/go-json"
+	"net/url"
+	"time"
+)
+
+const (
+	deadLetterPath       = "/dead-letter"
+	maxDeadLetterEntries = 100
+)
+
+// DeadLetterQueue gives access to documents rejected by ingest, see ingest.IngestProcessor.
+type DeadLetterQueue interface {
+	DeadLetterEntries(ctx context.Context, limit int) ([]dead_letter.Entry, error)
+	ReplayDeadLetter(ctx context.Context, id string) error
+	DiscardDeadLetter(ctx context.Context, id string) error
+}
+
+func (qmc *QuesmaManagementConsole) SetDeadLetterQueue(queue DeadLetterQueue) {
+	qmc.deadLetterQueue = queue
+}
+
+func (qmc *QuesmaManagementConsole) replayDeadLetter(id string) []byte {
+	if qmc.deadLetterQueue == nil {
+		return qmc.generateDeadLetter("Dead letter queue is not configured.")
+	}
+	if err := qmc.deadLetterQueue.ReplayDeadLetter(context.Background(), id); err != nil {
+		return qmc.generateDeadLetter(fmt.Sprintf("Replaying %s failed: %v", id, err))
+	}
+	return qmc.generateDeadLetter(fmt.Sprintf("Document %s has been ingested again.", id))
+}
+
+func (qmc *QuesmaManagementConsole) discardDeadLetter(id string) []byte {
+	if qmc.deadLetterQueue == nil {
+		return qmc.generateDeadLetter("Dead letter queue is not configured.")
+	}
+	if err := qmc.deadLetterQueue.DiscardDeadLetter(context.Background(), id); err != nil {
+		return qmc.generateDeadLetter(fmt.Sprintf("Discarding %s failed: %v", id, err))
+	}
+	return qmc.generateDeadLetter(fmt.Sprintf("Document %s has been discarded.", id))
+}
+
+func (qmc *QuesmaManagementConsole) generateDeadLetter(message string) []byte {
+	buffer := newBufferWithHead()
+	buffer.Write(qmc.generateTopNavigation("statistics"))
+
+	buffer.Html(`<main id="dead-letter">`)
+	buffer.Html(`<h2>Dead letter queue</h2>`)
+	if message != "" {
+		buffer.Html(`<p class="message">`).Text(message).Html(`</p>`)
+	}
+
+	var entries []dead_letter.Entry
+	var err error
+	if qmc.deadLetterQueue == nil {
+		buffer.Html(`<p>Dead letter queue is not configured, set <code>deadLetter</code> in the ingest processor configuration.</p>`)
+	} else if entries, err = qmc.deadLetterQueue.DeadLetterEntries(context.Background(), maxDeadLetterEntries); err != nil {
+		buffer.Html(`<p>Can't load entries: `).Text(err.Error()).Html(`</p>`)
+	} else if len(entries) == 0 {
+		buffer.Html(`<p>No rejected documents.</p>`)
+	} else {
+		buffer.Html(fmt.Sprintf(`<p>Showing %d most recent rejected documents. Replaying a document ingests it again and removes it from the queue.</p>`, len(entries)))
+		buffer.Html("<table>\n")
+		buffer.Html("<thead>\n<tr>\n")
+		buffer.Html(`<th class="time">Time</th>`)
+		buffer.Html(`<th class="key">Index</th>`)
+		buffer.Html(`<th class="value">Reason</th>`)
+		buffer.Html(`<th class="value">Document</th>`)
+		buffer.Html(`<th></th>`)
+		buffer.Html("\n</tr>\n</thead>\n<tbody>\n")
+		for _, entry := range entries {
+			payload, err := json.MarshalIndent(entry.Payload, "", "  ")
+			if err != nil {
+				payload = []byte(err.Error())
+			}
+			buffer.Html(`<tr>`)
+			buffer.Html(`<td class="time">`).Text(entry.Timestamp.Format(time.RFC3339)).Html(`</td>`)
+			buffer.Html(`<td class="key">`).Text(entry.Index).Html(`</td>`)
+			buffer.Html(`<td class="value">`).Text(entry.Reason).Html(`</td>`)
+			buffer.Html(`<td class="value"><pre>`).Text(string(payload)).Html(`</pre></td>`)
+			buffer.Html(`<td>`)
+			buffer.Html(`<button hx-post="`).Text(deadLetterPath + "/" + url.PathEscape(entry.Id) + "/replay").Html(`" hx-target="body">Replay</button> `)
+			buffer.Html(`<button hx-post="`).Text(deadLetterPath + "/" + url.PathEscape(entry.Id) + "/discard").Html(`" hx-target="body">Discard</button>`)
+			buffer.Html(`</td>`)
+			buffer.Html("</tr>\n")
+		}
+		buffer.Html("</tbody>\n</table>\n")
+	}
+	buffer.Html("\n</main>\n\n")
+
+	buffer.Html(`<div class="menu">`)
+	buffer.Html("\n<h2>Menu</h2>")
+	buffer.Html(`<form action="` + deadLetterPath + `">&nbsp;<input class="btn" type="submit" value="Refresh" /></form>`)
+	buffer.Html(`<form action="/ingest-statistics">&nbsp;<input class="btn" type="submit" value="Back to ingest statistics" /></form>`)
+	buffer.Html("\n</div>")
+
+	buffer.Html("\n</body>")
+	buffer.Html("\n</html>")
+	return buffer.Bytes()
+}
@@ -21,6 +21,7 @@ func (qmc *QuesmaManagementConsole) generateIngestStatistics() []byte {
 	buffer.Html("\n<h2>Menu</h2>")
 
 	buffer.Html(`<form action="/">&nbsp;<input class="btn" type="submit" value="Back to dashboard" /></form>`)
+	buffer.Html(`<form action="` + deadLetterPath + `">&nbsp;<input class="btn" type="submit" value="Dead letter queue" /></form>`)
 
 	buffer.Html("\n</div>")
 
@@ -83,6 +83,7 @@ type (
 		totalUnsupportedQueries   int
 		elasticsearch             *backend_connectors.ElasticsearchBackendConnector
 		tableResolver             table_resolver.TableResolver
+		deadLetterQueue           DeadLetterQueue // nil if disabled
 
 		isAuthEnabled bool
 	}
@@ -12,9 +12,15 @@ const (
 	IndexAsyncSearchPath      = "/:index/_async_search"
 	IndexCountPath            = "/:index/_count"
 	IndexDocPath              = "/:index/_doc"
+	IndexDocIdPath            = "/:index/_doc/:id"
+	IndexSourceIdPath         = "/:index/_source/:id"
+	IndexMgetPath             = "/:index/_mget"
+	GlobalMgetPath            = "/_mget"
 	IndexRefreshPath          = "/:index/_refresh"
 	IndexBulkPath             = "/:index/_bulk"
 	IndexMappingPath          = "/:index/_mapping"
+	IndexDeleteByQueryPath    = "/:index/_delete_by_query"
+	IndexUpdateByQueryPath    = "/:index/_update_by_query"
 	FieldCapsPath             = "/:index/_field_caps"
 	TermsEnumPath             = "/:index/_terms_enum"
 	IndexPatternPitPath       = "/:index/_pit"
@@ -30,6 +36,20 @@ const (
 	IndexPath                 = "/:index"
 	ExecutePainlessScriptPath = "/_scripts/painless/_execute" // This path is used on the Kibana side to evaluate painless scripts when adding a new scripted field.
 
+	TaskPath       = "/_tasks/:id"
+	TaskCancelPath = "/_tasks/:id/_cancel"
+
+	IngestPipelinesPath          = "/_ingest/pipeline"
+	IngestPipelinePath           = "/_ingest/pipeline/:id"
+	IngestPipelineSimulatePath   = "/_ingest/pipeline/_simulate"
+	IngestPipelineIdSimulatePath = "/_ingest/pipeline/:id/_simulate"
+
+	IndexTemplatesPath             = "/_index_template"
+	IndexTemplatePath              = "/_index_template/:name"
+	IndexTemplateSimulateIndexPath = "/_index_template/_simulate_index/:index"
+	ComponentTemplatesPath         = "/_component_template"
+	ComponentTemplatePath          = "/_component_template/:name"
+
 	IndexMsearchPath  = "/:index/_msearch"
 	GlobalMsearchPath = "/_msearch"
 
@@ -41,11 +61,15 @@ const (
 
 var notQueryPaths = []string{
 	"_bulk",
+	"_delete_by_query",
 	"_doc",
 	"_field_caps",
 	"_health",
+	"_ingest",
 	"_resolve",
 	"_refresh",
+	"_tasks",
+	"_update_by_query",
 }
 
 func IsNotQueryPath(path string) bool {